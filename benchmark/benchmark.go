@@ -0,0 +1,408 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+// Package benchmark runs benchmarks of OpenBao from other programs. Tests
+// of the built-in types, or of types registered with RegisterTest, are set
+// up, attacked and optionally cleaned up by Run, which returns the results
+// of every test.
+//
+//	test, err := benchmark.NewTest("kvv2_read", "reads", 100, `numkvs = 100`)
+//	...
+//	result, err := benchmark.Run(ctx, &benchmark.Config{
+//		Client:   client,
+//		Tests:    []*benchmarktests.BenchmarkTarget{test},
+//		Duration: 30 * time.Second,
+//		RPS:      500,
+//	})
+//	...
+//	fmt.Println(result.Tests["reads"].Latencies.P99)
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/openbao/benchmark-openbao/config"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	DefaultDuration = 10 * time.Second
+	DefaultWorkers  = 10
+)
+
+// Config is a benchmark to run. Only Client and Tests are required.
+type Config struct {
+	// Client is the client tests are set up, attacked and cleaned up with
+	Client *api.Client
+
+	// Tests are the tests to run, whose builders have parsed their config,
+	// such as those returned by NewTest or LoadConfig
+	Tests []*benchmarktests.BenchmarkTarget
+
+	// Duration is how long the tests are attacked for, defaulting to
+	// DefaultDuration
+	Duration time.Duration
+
+	// RPS is the rate of requests shared by the tests, or zero to send
+	// them as fast as the workers can
+	RPS int
+
+	// Workers is the number of workers sending requests, defaulting to
+	// DefaultWorkers
+	Workers int
+
+	// AttackMode is benchmarktests.OpenLoopAttackMode, the default, or
+	// benchmarktests.ClosedLoopAttackMode
+	AttackMode string
+
+	// Warmup is the period at the start of the attack whose results are
+	// left out of the results of the tests
+	Warmup time.Duration
+
+	// RandomMounts gives the mounts of tests random names
+	RandomMounts bool
+
+	// Cleanup removes the mounts of the tests once they have been attacked
+	Cleanup bool
+
+	// Logger, when set, is given the logs of setting up and cleaning up
+	// the tests
+	Logger hclog.Logger
+}
+
+// Result is the results of a benchmark
+type Result struct {
+	// Total is the results of all tests together
+	Total *TestResult
+
+	// Tests are the results of each test, by name
+	Tests map[string]*TestResult
+
+	// Report writes the results as the run command does, such as with
+	// ReportJSON or ReportTerse
+	Report *benchmarktests.Reporter
+}
+
+// TestResult is the results of a test
+type TestResult struct {
+	Requests     uint64
+	Rate         float64
+	Throughput   float64
+	SuccessRatio float64
+	Latencies    Latencies
+
+	// StatusCodes counts the responses by status code, with "0" counting
+	// the requests which failed without a response
+	StatusCodes map[string]int
+
+	// Errors are the distinct errors of failed requests
+	Errors []string
+}
+
+// Latencies are the latencies of the requests of a test
+type Latencies struct {
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P95  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+}
+
+// RegisterTest registers a test type, whose tests are built by
+// newBuilder, so it can be used in tests of configs like the built-in
+// types. It must be called before the configs are loaded, such as from
+// an init function, and fails when the type is already registered.
+func RegisterTest(testType string, newBuilder func() benchmarktests.BenchmarkBuilder) error {
+	if testType == "" {
+		return fmt.Errorf("test type is required")
+	}
+	if _, ok := benchmarktests.TestList[testType]; ok {
+		return fmt.Errorf("test type %v is already registered", testType)
+	}
+	benchmarktests.TestList[testType] = newBuilder
+	return nil
+}
+
+// TestTypes returns the registered test types, in order
+func TestTypes() []string {
+	types := make([]string, 0, len(benchmarktests.TestList))
+	for testType := range benchmarktests.TestList {
+		types = append(types, testType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// NewTest returns a test of the given type, weight and name, whose config
+// is the HCL of the body of its config block, such as `numkvs = 100`
+func NewTest(testType, name string, weight int, config string) (*benchmarktests.BenchmarkTarget, error) {
+	newBuilder, ok := benchmarktests.TestList[testType]
+	if !ok {
+		return nil, fmt.Errorf("invalid test type: %v", testType)
+	}
+	file, diags := hclparse.NewParser().ParseHCL([]byte("config {\n"+config+"\n}\n"), name+".hcl")
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("error parsing config of test %v: %v", name, diags)
+	}
+	builder := newBuilder()
+	if err := builder.ParseConfig(file.Body); err != nil {
+		return nil, fmt.Errorf("invalid config for test %v: %w", name, err)
+	}
+	return &benchmarktests.BenchmarkTarget{
+		Type:    testType,
+		Name:    name,
+		Weight:  weight,
+		Builder: builder,
+	}, nil
+}
+
+// loadConfigOptions are the options of a config file supported by
+// LoadConfig, along with those only changing the output of the run
+// command, which are ignored
+var loadConfigOptions = map[string]bool{
+	"vault_addr":      true,
+	"vault_token":     true,
+	"vault_namespace": true,
+	"duration":        true,
+	"rps":             true,
+	"workers":         true,
+	"attack_mode":     true,
+	"warmup":          true,
+	"random_mounts":   true,
+	"cleanup":         true,
+	"test":            true,
+	"plugin":          true,
+
+	"log_level":          true,
+	"report_mode":        true,
+	"report_percentiles": true,
+	"annotate":           true,
+	"labels":             true,
+	"live":               true,
+}
+
+// LoadConfig returns the benchmark of a config file of the run command.
+// Its client uses the vault_addr, vault_token and vault_namespace of the
+// file, falling back to the environment like the clients of the api
+// package. Options of the run command which Config doesn't have, such as
+// vault_addrs or phases, are errors rather than being ignored. Plugins of
+// the file are started, and must be stopped with StopPlugins once the
+// benchmark is done.
+func LoadConfig(path string) (*Config, error) {
+	conf := config.NewVaultBenchmarkCoreConfig()
+	if err := conf.LoadConfig(path); err != nil {
+		return nil, err
+	}
+	if err := checkOptions(conf); err != nil {
+		return nil, err
+	}
+	if err := conf.StartPlugins(); err != nil {
+		return nil, err
+	}
+	if err := conf.ResolveCredentials(); err != nil {
+		return nil, err
+	}
+
+	clientConfig := api.DefaultConfig()
+	if clientConfig.Error != nil {
+		return nil, fmt.Errorf("error creating client config: %w", clientConfig.Error)
+	}
+	if conf.VaultAddr != "" {
+		clientConfig.Address = conf.VaultAddr
+	}
+	client, err := api.NewClient(clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating client: %w", err)
+	}
+	if conf.VaultToken != "" {
+		client.SetToken(conf.VaultToken)
+	}
+	if conf.VaultNamespace != "" {
+		client.SetNamespace(conf.VaultNamespace)
+	}
+
+	duration, err := time.ParseDuration(conf.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration: %w", err)
+	}
+	var warmup time.Duration
+	if conf.Warmup != "" {
+		if warmup, err = time.ParseDuration(conf.Warmup); err != nil {
+			return nil, fmt.Errorf("invalid warmup: %w", err)
+		}
+	}
+	return &Config{
+		Client:       client,
+		Tests:        conf.Tests,
+		Duration:     duration,
+		RPS:          conf.RPS,
+		Workers:      conf.Workers,
+		AttackMode:   conf.AttackMode,
+		Warmup:       warmup,
+		RandomMounts: conf.RandomMounts,
+		Cleanup:      conf.Cleanup,
+	}, nil
+}
+
+// checkOptions returns an error naming the options of the config, and of
+// its tests, which LoadConfig doesn't support
+func checkOptions(conf *config.VaultBenchmarkCoreConfig) error {
+	var unsupported []string
+	defaults := reflect.ValueOf(config.NewVaultBenchmarkCoreConfig()).Elem()
+	options := reflect.ValueOf(conf).Elem()
+	for i := 0; i < options.NumField(); i++ {
+		name, _, _ := strings.Cut(options.Type().Field(i).Tag.Get("hcl"), ",")
+		if name == "" || loadConfigOptions[name] {
+			continue
+		}
+		option, def := options.Field(i), defaults.Field(i)
+		switch option.Kind() {
+		case reflect.Slice, reflect.Map:
+			// Blocks which aren't given decode as empty slices
+			if option.Len() == 0 {
+				continue
+			}
+		default:
+			if reflect.DeepEqual(option.Interface(), def.Interface()) {
+				continue
+			}
+		}
+		unsupported = append(unsupported, name)
+	}
+
+	// Options of tests which the run command handles itself
+	for _, vbTest := range conf.Tests {
+		var testOptions []string
+		if vbTest.TokenSource != nil {
+			testOptions = append(testOptions, "token_source")
+		}
+		if len(vbTest.Before) > 0 {
+			testOptions = append(testOptions, "before")
+		}
+		if len(vbTest.After) > 0 {
+			testOptions = append(testOptions, "after")
+		}
+		if vbTest.ErrorBudget != nil {
+			testOptions = append(testOptions, "error_budget")
+		}
+		if vbTest.Regression != nil {
+			testOptions = append(testOptions, "regression")
+		}
+		if len(vbTest.SLOs) > 0 {
+			testOptions = append(testOptions, "slo")
+		}
+		for _, option := range testOptions {
+			unsupported = append(unsupported, fmt.Sprintf("%v of test %v", option, vbTest.Name))
+		}
+	}
+	if len(unsupported) > 0 {
+		return fmt.Errorf("options not supported by LoadConfig: %v", strings.Join(unsupported, ", "))
+	}
+	return nil
+}
+
+// StopPlugins stops the plugins started by LoadConfig
+func StopPlugins() {
+	benchmarktests.StopPlugins()
+}
+
+// Run sets up the tests of the config, attacks them and, with Cleanup,
+// cleans them up again, returning their results. When ctx is done during
+// the attack, the attack ends early and the results so far are returned
+// along with the error of ctx.
+func Run(ctx context.Context, config *Config) (*Result, error) {
+	if config.Client == nil {
+		return nil, fmt.Errorf("client is required")
+	}
+	if len(config.Tests) == 0 {
+		return nil, fmt.Errorf("at least one test is required")
+	}
+	for _, vbTest := range config.Tests {
+		// The results of all tests together are named total
+		if vbTest.Name == "total" {
+			return nil, fmt.Errorf("test name total is reserved for the results of all tests")
+		}
+	}
+	duration := config.Duration
+	if duration == 0 {
+		duration = DefaultDuration
+	}
+	workers := config.Workers
+	if workers == 0 {
+		workers = DefaultWorkers
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = hclog.NewNullLogger()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	tm, err := benchmarktests.BuildTargets(config.Client, config.Tests, &logger, &benchmarktests.TopLevelTargetConfig{
+		Duration:     duration,
+		RandomMounts: config.RandomMounts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error setting up tests: %w", err)
+	}
+
+	rpt, attackErr := benchmarktests.Attack(tm, config.Client, &benchmarktests.AttackConfig{
+		Duration: duration,
+		RPS:      config.RPS,
+		Workers:  workers,
+		Mode:     config.AttackMode,
+		Warmup:   config.Warmup,
+		Stop:     ctx.Done(),
+	})
+	if config.Cleanup {
+		if err := tm.Cleanup(config.Client); err != nil {
+			logger.Error("error cleaning up tests", "error", err)
+		}
+	}
+	if rpt == nil {
+		return nil, attackErr
+	}
+
+	result := &Result{Tests: make(map[string]*TestResult), Report: rpt}
+	for name, m := range rpt.Metrics() {
+		if name == "total" {
+			result.Total = testResult(m)
+			continue
+		}
+		result.Tests[name] = testResult(m)
+	}
+	if attackErr == nil {
+		attackErr = ctx.Err()
+	}
+	return result, attackErr
+}
+
+func testResult(m *vegeta.Metrics) *TestResult {
+	return &TestResult{
+		Requests:     m.Requests,
+		Rate:         m.Rate,
+		Throughput:   m.Throughput,
+		SuccessRatio: m.Success,
+		Latencies: Latencies{
+			Mean: m.Latencies.Mean,
+			P50:  m.Latencies.P50,
+			P90:  m.Latencies.P90,
+			P95:  m.Latencies.P95,
+			P99:  m.Latencies.P99,
+			Max:  m.Latencies.Max,
+		},
+		StatusCodes: m.StatusCodes,
+		Errors:      m.Errors,
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmark
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// pingTest is a test of a type registered from outside the benchmarktests
+// package, which reads a path of its mount
+type pingTest struct {
+	Path   string `hcl:"path"`
+	mount  string
+	header http.Header
+}
+
+func (p *pingTest) Target(client *api.Client) vegeta.Target {
+	return vegeta.Target{
+		Method: "GET",
+		URL:    client.Address() + "/v1/" + p.mount + "/" + p.Path,
+		Header: p.header,
+	}
+}
+
+func (p *pingTest) Setup(client *api.Client, mountName string, config *benchmarktests.TopLevelTargetConfig) (benchmarktests.BenchmarkBuilder, error) {
+	return &pingTest{Path: p.Path, mount: mountName, header: http.Header{"X-Vault-Token": []string{client.Token()}}}, nil
+}
+
+func (p *pingTest) Cleanup(client *api.Client) error {
+	_, err := client.Logical().Delete("sys/mounts/" + p.mount)
+	return err
+}
+
+func (p *pingTest) ParseConfig(body hcl.Body) error {
+	testConfig := &struct {
+		Config *pingTest `hcl:"config,block"`
+	}{Config: p}
+	if diags := gohcl.DecodeBody(body, nil, testConfig); diags.HasErrors() {
+		return diags
+	}
+	return nil
+}
+
+func (p *pingTest) GetTargetInfo() benchmarktests.TargetInfo {
+	return benchmarktests.NewTargetInfo("GET", "/v1/"+p.mount)
+}
+
+func (p *pingTest) Flags(fs *flag.FlagSet) {}
+
+func testClient(t *testing.T, handler http.HandlerFunc) *api.Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+	return client
+}
+
+func TestRegisterTest(t *testing.T) {
+	if err := RegisterTest("ping", func() benchmarktests.BenchmarkBuilder { return &pingTest{} }); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer delete(benchmarktests.TestList, "ping")
+	if err := RegisterTest("ping", func() benchmarktests.BenchmarkBuilder { return &pingTest{} }); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("expected the test type to conflict, got: %v", err)
+	}
+	if err := RegisterTest(benchmarktests.KVV2ReadTestType, func() benchmarktests.BenchmarkBuilder { return &pingTest{} }); err == nil {
+		t.Fatal("expected built-in test types not to be replaced")
+	}
+
+	types := TestTypes()
+	found := false
+	for _, testType := range types {
+		found = found || testType == "ping"
+	}
+	if !found {
+		t.Fatalf("expected the registered type in %v", types)
+	}
+
+	if _, err := NewTest("ping", "pings", 100, ""); err == nil || !strings.Contains(err.Error(), "invalid config for test pings") {
+		t.Fatalf("expected the missing path to be rejected, got: %v", err)
+	}
+	if _, err := NewTest("missing", "pings", 100, `path = "health"`); err == nil || !strings.Contains(err.Error(), "invalid test type") {
+		t.Fatalf("expected the unknown type to be rejected, got: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if err := RegisterTest("ping", func() benchmarktests.BenchmarkBuilder { return &pingTest{} }); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer delete(benchmarktests.TestList, "ping")
+
+	var reads, deletes atomic.Int64
+	client := testClient(t, func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodDelete && req.URL.Path == "/v1/sys/mounts/pings":
+			deletes.Add(1)
+		case req.Method == http.MethodGet && req.URL.Path == "/v1/pings/health" && req.Header.Get("X-Vault-Token") == "root":
+			reads.Add(1)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	test, err := NewTest("ping", "pings", 100, `path = "health"`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	result, err := Run(context.Background(), &Config{
+		Client:   client,
+		Tests:    []*benchmarktests.BenchmarkTarget{test},
+		Duration: 500 * time.Millisecond,
+		RPS:      20,
+		Workers:  2,
+		Cleanup:  true,
+	})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pings := result.Tests["pings"]
+	if pings == nil || pings.Requests == 0 || pings.Requests != uint64(reads.Load()) || pings.SuccessRatio != 1 {
+		t.Fatalf("expected every read to succeed, got %+v", pings)
+	}
+	if result.Total.Requests != pings.Requests || pings.StatusCodes["204"] != int(pings.Requests) || pings.Latencies.Max == 0 {
+		t.Fatalf("unexpected results: %+v %+v", result.Total, pings)
+	}
+	if deletes.Load() != 1 {
+		t.Fatalf("expected the test to be cleaned up once, got %d", deletes.Load())
+	}
+
+	// A cancelled context ends the attack early, with the results so far
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	began := time.Now()
+	result, err = Run(ctx, &Config{
+		Client:   client,
+		Tests:    []*benchmarktests.BenchmarkTarget{test},
+		Duration: time.Minute,
+		RPS:      20,
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected the context error, got: %v", err)
+	}
+	if time.Since(began) > 10*time.Second {
+		t.Fatalf("expected the attack to end early, took %v", time.Since(began))
+	}
+	if result == nil || result.Tests["pings"] == nil || result.Tests["pings"].Requests == 0 {
+		t.Fatalf("expected the results so far, got %+v", result)
+	}
+
+	if _, err := Run(context.Background(), &Config{Client: client}); err == nil {
+		t.Fatal("expected a benchmark without tests to be rejected")
+	}
+
+	total, err := NewTest("ping", "total", 100, `path = "ping"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Run(context.Background(), &Config{Client: client, Tests: []*benchmarktests.BenchmarkTarget{total}}); err == nil {
+		t.Fatal("expected a test named total to be rejected")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.hcl")
+	config := `
+vault_addr = "http://127.0.0.1:8200"
+vault_token = "root"
+duration = "2s"
+warmup = "500ms"
+workers = 4
+
+test "kvv2_read" "reads" {
+  weight = 100
+  config {
+    numkvs = 10
+  }
+}
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer StopPlugins()
+	if conf.Client.Address() != "http://127.0.0.1:8200" || conf.Client.Token() != "root" {
+		t.Fatalf("unexpected client: %v", conf.Client.Address())
+	}
+	if conf.Duration != 2*time.Second || conf.Warmup != 500*time.Millisecond || conf.Workers != 4 {
+		t.Fatalf("unexpected config: %+v", conf)
+	}
+	if len(conf.Tests) != 1 || conf.Tests[0].Name != "reads" || conf.Tests[0].Builder == nil {
+		t.Fatalf("expected the parsed test, got %+v", conf.Tests)
+	}
+}
+
+func TestLoadConfig_Unsupported(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.hcl")
+	config := `
+vault_addrs  = ["http://127.0.0.1:8200", "http://127.0.0.1:8210"]
+load_balance = "round_robin"
+
+phase "ramp" {
+  rps      = 10
+  duration = "5s"
+}
+
+test "kvv2_read" "reads" {
+  weight = 100
+  token_source "env" {
+    variable = "READ_TOKEN"
+  }
+  config {
+    numkvs = 10
+  }
+}
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadConfig(path)
+	if err == nil {
+		t.Fatal("expected unsupported options to be rejected")
+	}
+	for _, option := range []string{"vault_addrs", "load_balance", "phase", "token_source of test reads"} {
+		if !strings.Contains(err.Error(), option) {
+			t.Errorf("expected %v to be named, got %v", option, err)
+		}
+	}
+}
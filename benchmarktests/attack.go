@@ -4,32 +4,703 @@
 package benchmarktests
 
 import (
+	"fmt"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/openbao/openbao/api/v2"
 	vegeta "github.com/tsenart/vegeta/v12/lib"
 )
 
-func Attack(tm *TargetMulti, client *api.Client, duration time.Duration, rps int, workers int) (*Reporter, error) {
-	rate := vegeta.Rate{Freq: rps, Per: time.Second}
+const (
+	// OpenLoopAttackMode sends requests at the configured rate regardless
+	// of how quickly the target responds
+	OpenLoopAttackMode = "open"
+
+	// ClosedLoopAttackMode has a fixed number of workers which each send
+	// their next request only once the previous one has completed
+	ClosedLoopAttackMode = "closed"
+)
+
+// AttackConfig contains the settings which control how load is generated
+// against the target during an attack
+type AttackConfig struct {
+	// Phase names the stage of a multi-phase run this attack belongs to
+	Phase     string
+	Duration  time.Duration
+	RPS       int
+	Workers   int
+	Mode      string
+	Arrival   string
+	ThinkTime time.Duration
+
+	// ThinkTimeDistribution is how the think time after each request is
+	// chosen, one of fixed, uniform or exponential
+	ThinkTimeDistribution string
+
+	// Requests stops the attack once this many requests have been sent.
+	// A zero Duration then means the attack has no time limit.
+	Requests uint64
+
+	// Warmup is the period at the start of the attack during which results
+	// are reported separately from the main metrics
+	Warmup time.Duration
+
+	// Burst optionally layers periodic spikes of load over the base rate
+	Burst *BurstConfig
+
+	// When set, a summary of the results is written to IntervalOutput every
+	// ReportInterval while the attack runs
+	ReportInterval time.Duration
+	IntervalOutput IntervalOutput
+
+	// ErrorBudget aborts the attack early once too many requests fail
+	ErrorBudget *ErrorBudgetConfig
+
+	// StartOffset delays the start of the attack by the given time
+	StartOffset time.Duration
+
+	// Replay re-drives the request arrivals of a trace instead of pacing
+	// requests at a rate. The attack ends after the last event.
+	Replay []ReplayEvent
+
+	// MaxInFlight caps the number of requests an open loop attack may have
+	// outstanding at once, independently of the rate and of Workers, which
+	// is then only the number of workers the attack starts with. Zero caps
+	// it at the number of workers. The number of requests it delayed is
+	// approximate, as it is sampled by the pacer as each request falls due.
+	MaxInFlight int
+
+	// CorrectOmission measures the latency of open loop requests from the
+	// time they were scheduled to be sent, so requests delayed because the
+	// attack fell behind its schedule are not under-reported
+	CorrectOmission bool
+
+	// Live, when set, is given every result as it arrives so progress can be
+	// shown while the attack runs
+	Live *LiveView
+
+	// ResultLog, when set, is written every individual result
+	ResultLog *ResultLog
+
+	// TimeseriesInterval is the length of each point of the time series of
+	// the results of every test. Zero disables the time series.
+	TimeseriesInterval time.Duration
+
+	// TrackRecovery counts the results of every second of the attack, so
+	// the time taken to recover from chaos events can be found
+	TrackRecovery bool
+
+	// ErrorSamples is the number of response bodies kept for each group of
+	// failed requests with the same status code and error
+	ErrorSamples int
+
+	// LoadBalance, when set, spreads the requests of the attack across
+	// several addresses instead of sending them all to the client's
+	LoadBalance *LoadBalanceConfig
+
+	// Proxy, when set, is an OpenBao Proxy or Agent to send the requests of
+	// the attack through, such as one listening on a unix socket, instead
+	// of sending them straight to the client's address. Tests are still set
+	// up through the client.
+	Proxy *api.Client
+
+	// NodeHeader is the response header naming the node which served each
+	// request, such as one added by a load balancer, so results can be
+	// broken down by node
+	NodeHeader string
+
+	// FollowRedirects follows the redirects of standby nodes to the active
+	// node, recording how many requests were redirected and the latency
+	// it added, instead of reporting the redirects as responses
+	FollowRedirects bool
+
+	// DNSRefresh, when set, is how often the host names requests are sent
+	// to are resolved again, spreading connections across the addresses
+	// they resolve to instead of pinning the first for the whole attack
+	DNSRefresh time.Duration
+
+	// Transport, when set, tunes the HTTP transport requests are sent with
+	Transport *TransportConfig
+
+	// TokenRenewer, when set, keeps the benchmark's token alive; requests
+	// made with its original token are sent with its current one
+	TokenRenewer *TokenRenewer
+
+	// TokenPool, when set, spreads the requests made with the benchmark's
+	// token across the tokens of the pool
+	TokenPool *TokenPool
+
+	// Namespaces, when set, are the namespaces the tests were fanned out
+	// into, whose results are recorded on their own
+	Namespaces []string
+
+	// Checkpoint, when set, is the progress of the attack saved to a
+	// checkpoint file, which an interrupted attack is resumed from
+	Checkpoint *PhaseProgress
+
+	// Stop, when set, ends the attack early once it is closed, with the
+	// results gathered so far
+	Stop <-chan struct{}
+
+	// TestHooks, when set, runs the before and after hooks of the tests
+	// around the runs they are attacked in
+	TestHooks *TestHooks
+
+	// testTLS are the TLS settings of the tests which have their own, by
+	// name, which their requests are sent with
+	testTLS map[string]*TLSConfig
+
+	// testPolicies are the timeouts and retries of the tests which have
+	// their own, by name, which their requests are sent with
+	testPolicies map[string]*RequestPolicy
+
+	// scenarios are the scenario tests of the attack by id, which the
+	// responses to their steps are handed back to
+	scenarios map[int64]*ScenarioTest
+}
+
+// attackRun is a single stream of load: either the weighted mix of all
+// shared targets, or one target which has its own rate and duration
+type attackRun struct {
+	targeter vegeta.Targeter
+	pacer    vegeta.Pacer
+	think    *thinkTimer
+	balance  *balancer
+	limiter  *inFlightLimiter
+	schedule *sendSchedule
+	config   AttackConfig
+
+	// tests are the tests whose hooks are run around the run
+	tests []BenchmarkTarget
+
+	// started is called with the time a run with a start offset or hooks
+	// was started at
+	started func(time.Time)
+}
+
+// runResult is a result of a run along with how long after its scheduled
+// time it was sent
+type runResult struct {
+	*vegeta.Result
+	delay time.Duration
+}
+
+// Attack runs the configured load against the targets in tm and returns a
+// Reporter containing the results. Targets with their own rate or duration
+// are attacked independently and concurrently with the weighted mix of the
+// remaining targets; all results are gathered into the same Reporter.
+//
+// If an error budget is exceeded the attack is stopped early and the
+// results gathered so far are returned along with an error wrapping
+// ErrErrorBudgetExceeded.
+func Attack(tm *TargetMulti, client *api.Client, config *AttackConfig) (*Reporter, error) {
+	if config.Checkpoint != nil && config.Checkpoint.done {
+		// The attack completed before the run was interrupted
+		return config.Checkpoint.report, nil
+	}
+	if testTLS := tm.testTLS(); len(testTLS) > 0 {
+		withTLS := *config
+		withTLS.testTLS = testTLS
+		config = &withTLS
+	}
+	if testPolicies := tm.requestPolicies(); len(testPolicies) > 0 {
+		withPolicies := *config
+		withPolicies.testPolicies = testPolicies
+		config = &withPolicies
+	}
+	if scenarios := tm.scenarios(); len(scenarios) > 0 {
+		withScenarios := *config
+		withScenarios.scenarios = scenarios
+		config = &withScenarios
+	}
+	shared, independent := tm.partition()
+
+	// A resumed attack only runs for what is left of it
+	sharedConfig := *config
+	if !config.Checkpoint.resume(&sharedConfig, shared.names()) {
+		shared = &TargetMulti{}
+	}
+
+	var runs []*attackRun
+	if len(config.Replay) > 0 {
+		// The trace decides which test each request is for, so every
+		// target is part of the same run
+		run, err := newReplayRun(tm, client, config)
+		if err != nil {
+			return nil, err
+		}
+		run.tests = tm.targets
+		runs = append(runs, run)
+		shared, independent = &TargetMulti{}, nil
+	}
+	if len(shared.targets) > 0 {
+		run, err := newAttackRun(shared, client, &sharedConfig)
+		if err != nil {
+			return nil, err
+		}
+		run.tests = shared.targets
+		runs = append(runs, run)
+
+		if config.Burst != nil {
+			run, err := newBurstRun(shared, client, &sharedConfig)
+			if err != nil {
+				return nil, err
+			}
+			runs = append(runs, run)
+		}
+	}
+	for _, target := range independent {
+		targetConfig, err := target.attackConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		if !config.Checkpoint.resume(targetConfig, []string{target.Name}) {
+			continue
+		}
+		// The target is the only one in its run, so always choose it
+		target.Weight = 1
+		run, err := newAttackRun(&TargetMulti{targets: []BenchmarkTarget{target}}, client, targetConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring attack for %v: %w", target.Name, err)
+		}
+		run.tests = []BenchmarkTarget{target}
+		runs = append(runs, run)
+	}
+
+	// Requests sent through a proxy are balanced to its address alone
+	sender, balanceConfig := client, config.LoadBalance
+	if config.Proxy != nil {
+		if config.LoadBalance != nil {
+			return nil, fmt.Errorf("load balancing cannot be combined with a proxy")
+		}
+		sender = config.Proxy
+		balanceConfig = &LoadBalanceConfig{Strategy: RoundRobinBalance, Addrs: []string{config.Proxy.Address()}}
+	}
+	if balanceConfig != nil {
+		balance, err := newBalancer(balanceConfig, client.Address(), config.Mode)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring load balancing: %w", err)
+		}
+		for _, run := range runs {
+			run.balance = balance
+		}
+	}
+
+	rpt := newReporter(tm, client)
+	switch {
+	case config.Proxy != nil:
+		rpt.clientAddr = configuredAddr(config.Proxy)
+		rpt.addrs = balanceConfig.Addrs
+	case config.LoadBalance != nil:
+		rpt.clientAddr = balancedAddr(config.LoadBalance)
+		rpt.addrs = config.LoadBalance.Addrs
+		rpt.nodes = config.LoadBalance.Nodes
+	}
+	rpt.nodeHeader = config.NodeHeader
+	rpt.phase = config.Phase
+	rpt.transport = transportSettings(sender, config.Transport)
+	rpt.tokenPool = config.TokenPool.Size()
+	rpt.tokenType = config.TokenPool.Type()
+	rpt.corrected = config.CorrectOmission
+	rpt.startWarmup(time.Now(), sharedConfig.Warmup)
+	for _, run := range runs {
+		tests := run.tests
+		run.started = func(started time.Time) { rpt.startTests(tests, started) }
+	}
+	rpt.trackBursts(config.Burst)
+	rpt.trackIntervals(config.IntervalOutput, config.ReportInterval)
+	rpt.trackTimeseries(config.TimeseriesInterval)
+	rpt.trackRecovery(config.TrackRecovery)
+	rpt.live = config.Live
+	rpt.resultLog = config.ResultLog
+	rpt.errorSamples = config.ErrorSamples
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	rpt.trackErrorBudgets(config.ErrorBudget, func() {
+		stopOnce.Do(func() { close(stop) })
+	})
+	if config.Stop != nil {
+		finished := make(chan struct{})
+		defer close(finished)
+		go func() {
+			select {
+			case <-config.Stop:
+				stopOnce.Do(func() { close(stop) })
+			case <-finished:
+			}
+		}()
+	}
+
+	if config.Checkpoint != nil {
+		config.Checkpoint.start(time.Now())
+	}
+	streams := make([]<-chan runResult, 0, len(runs))
+	for _, run := range runs {
+		streams = append(streams, run.results(run.start(sender, stop)))
+	}
+
+	for res := range mergeResults(streams...) {
+		rpt.addDelayed(res.Result, res.delay)
+		if config.Checkpoint != nil {
+			config.Checkpoint.update(rpt)
+		}
+	}
+	for _, run := range runs {
+		if run.limiter != nil {
+			rpt.throttled += run.limiter.throttled.Load()
+		}
+	}
+	rpt.Close()
+
+	budgetErr := rpt.budgetErr
+	if config.Checkpoint != nil {
+		rpt = config.Checkpoint.save(rpt, true)
+	}
+	return rpt, budgetErr
+}
+
+func newAttackRun(tm *TargetMulti, client *api.Client, config *AttackConfig) (*attackRun, error) {
+	targeter, err := tm.Targeter(client)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &attackRun{targeter: targeter, config: *config}
+	switch config.Mode {
+	case ClosedLoopAttackMode:
+		addrs := []string{"N/A"}
+		switch {
+		case config.Proxy != nil:
+			addrs = []string{config.Proxy.Address()}
+		case config.LoadBalance != nil:
+			addrs = config.LoadBalance.Addrs
+		case client != nil:
+			addrs = []string{client.Address()}
+		}
+		run.think, err = newThinkTimer(tm, addrs, config)
+		if err != nil {
+			return nil, err
+		}
+		if config.LoadBalance != nil && config.Proxy == nil {
+			run.think.nodes = config.LoadBalance.Nodes
+		}
+	case OpenLoopAttackMode, "":
+		run.pacer, err = newPacer(config)
+		if err != nil {
+			return nil, err
+		}
+		if config.Requests > 0 {
+			run.pacer = &requestLimitPacer{Pacer: run.pacer, Max: config.Requests}
+		}
+		// Without a rate there is no schedule to fall behind
+		if config.RPS > 0 {
+			run.trackSchedule()
+		}
+	default:
+		return nil, fmt.Errorf("unknown attack mode: %v", config.Mode)
+	}
+	return run, nil
+}
+
+// trackSchedule records the intended send time of each hit when the
+// attack corrects for coordinated omission
+func (run *attackRun) trackSchedule() {
+	if run.config.CorrectOmission {
+		run.schedule = newSendSchedule(run.pacer)
+		run.pacer = run.schedule
+	}
+}
+
+// newBurstRun creates the extra stream of load which produces the bursts
+// configured on top of the base rate of the shared targets
+func newBurstRun(tm *TargetMulti, client *api.Client, config *AttackConfig) (*attackRun, error) {
+	if config.Mode == ClosedLoopAttackMode {
+		return nil, fmt.Errorf("bursts require the open attack mode")
+	}
+	if config.Requests > 0 {
+		return nil, fmt.Errorf("bursts cannot be combined with a request count")
+	}
+	if err := config.Burst.validate(); err != nil {
+		return nil, err
+	}
+
+	targeter, err := tm.Targeter(client)
+	if err != nil {
+		return nil, err
+	}
+	run := &attackRun{
+		targeter: targeter,
+		pacer:    &BurstPacer{Burst: *config.Burst},
+		config:   *config,
+	}
+	run.trackSchedule()
+	return run, nil
+}
+
+// newReplayRun creates an open loop run sending requests at the times and
+// for the tests recorded in a trace
+func newReplayRun(tm *TargetMulti, client *api.Client, config *AttackConfig) (*attackRun, error) {
+	if config.Mode == ClosedLoopAttackMode {
+		return nil, fmt.Errorf("replay requires the open attack mode")
+	}
+
+	targeter, err := replayTargeter(tm, client, config.Replay)
+	if err != nil {
+		return nil, err
+	}
+	run := &attackRun{
+		targeter: targeter,
+		pacer:    &replayPacer{events: config.Replay},
+		config:   *config,
+	}
+	run.config.Duration = 0
+	run.trackSchedule()
+	return run, nil
+}
+
+// start begins sending load, stopping early if the stop channel is closed
+func (run *attackRun) start(client *api.Client, stop <-chan struct{}) <-chan *vegeta.Result {
+	if run.config.StartOffset > 0 || run.hasHooks() {
+		return run.startAfter(client, stop)
+	}
+	return run.begin(client, stop)
+}
+
+// hasHooks returns whether any of the tests of the run have hooks to run
+// around it
+func (run *attackRun) hasHooks() bool {
+	if run.config.TestHooks == nil {
+		return false
+	}
+	for _, test := range run.tests {
+		if len(test.Before) > 0 || len(test.After) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (run *attackRun) begin(client *api.Client, stop <-chan struct{}) <-chan *vegeta.Result {
+	if run.config.Mode == ClosedLoopAttackMode {
+		clients := []*http.Client{attackClient(client, &run.config)}
+		if run.config.Transport != nil && run.config.Transport.WorkerConns > 0 {
+			// Each worker sends its requests on connections of its own
+			clients = make([]*http.Client, run.config.Workers)
+			for i := range clients {
+				clients[i] = attackClient(client, &run.config)
+			}
+		}
+		return closedLoopAttack(clients, run.targeter, run.balance, run.think, &run.config, stop)
+	}
+	if run.config.MaxInFlight > 0 {
+		run.limiter = &inFlightLimiter{max: int64(run.config.MaxInFlight)}
+	}
+	targeter := run.targeter
+	if run.balance != nil {
+		targeter = run.balance.targeter(targeter, 0)
+	}
+	return openLoopAttack(client, targeter, run.pacer, run.limiter, &run.config, stop)
+}
+
+// results pairs each result of the run with how late it was sent
+func (run *attackRun) results(results <-chan *vegeta.Result) <-chan runResult {
+	out := make(chan runResult)
+	go func() {
+		defer close(out)
+		for res := range results {
+			var delay time.Duration
+			if run.schedule != nil {
+				delay = run.schedule.delay(res)
+			}
+			out <- runResult{Result: res, delay: delay}
+		}
+	}()
+	return out
+}
+
+// startAfter starts the attack once its start offset has passed and the
+// before hooks of its tests have run, unless it is stopped first. A run
+// whose before hooks fail is not started. The after hooks of its tests are
+// run once it ends.
+func (run *attackRun) startAfter(client *api.Client, stop <-chan struct{}) <-chan *vegeta.Result {
+	delayed := make(chan *vegeta.Result)
+	go func() {
+		defer close(delayed)
+
+		timer := time.NewTimer(run.config.StartOffset)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-stop:
+			return
+		}
+
+		hooks := run.config.TestHooks
+		if err := hooks.before(run.tests, client.Address(), run.config.Duration); err != nil {
+			return
+		}
+		started := time.Now()
+		if run.started != nil {
+			run.started(started)
+		}
+		for res := range run.begin(client, stop) {
+			delayed <- res
+		}
+		hooks.after(run.tests, client.Address(), time.Since(started))
+	}()
+	return delayed
+}
+
+// attackClient returns the HTTP client the requests of an attack are sent
+// with: a copy of the client's, tuned, sending the requests of tests with
+// their own TLS settings with them, re-resolving host names, with the
+// renewed token or the tokens of a pool, tracing the connections they are
+// sent on, naming their namespace, following redirects, with the timeouts
+// and retries of tests with their own, handing the responses to the steps
+// of scenarios back to them and traced when enabled
+func attackClient(client *api.Client, config *AttackConfig) *http.Client {
+	httpClient := client.CloneConfig().HttpClient
+	if transport, ok := httpClient.Transport.(*http.Transport); ok {
+		if config.Transport != nil {
+			transport = config.Transport.tune(transport)
+		}
+		httpClient.Transport = sendingTransport(client, transport, config)
+		if len(config.testTLS) > 0 {
+			tests := make(map[string]http.RoundTripper, len(config.testTLS))
+			for name, tlsConfig := range config.testTLS {
+				// TLS settings are validated when the config is loaded
+				if testTransport, err := tlsConfig.transport(transport); err == nil {
+					tests[name] = sendingTransport(client, testTransport, config)
+				}
+			}
+			httpClient.Transport = &testTLSTransport{base: httpClient.Transport, tests: tests}
+		}
+	}
+	if config.TokenRenewer != nil {
+		base := httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		httpClient.Transport = &tokenTransport{base: base, renewer: config.TokenRenewer}
+	}
+	if config.TokenPool.Size() > 0 {
+		base := httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		httpClient.Transport = &tokenPoolTransport{base: base, pool: config.TokenPool}
+	}
+	// Trace the connection of each hop of redirected requests, which ends
+	// up on the response of the last
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	httpClient.Transport = &connTransport{base: base}
+	if len(config.Namespaces) > 0 {
+		httpClient.Transport = &namespaceTransport{base: httpClient.Transport}
+	}
+	if config.FollowRedirects {
+		httpClient.Transport = &redirectTransport{base: httpClient.Transport}
+	}
+	if len(config.testPolicies) > 0 {
+		// Each attempt of a request is given the client's timeout instead
+		// of the request as a whole
+		httpClient.Transport = newRequestPolicyTransport(httpClient.Transport, httpClient.Timeout, config.testPolicies)
+		httpClient.Timeout = 0
+	}
+	if len(config.scenarios) > 0 {
+		httpClient.Transport = &scenarioTransport{base: httpClient.Transport, scenarios: config.scenarios}
+	}
+	return tracedClient(httpClient)
+}
+
+// sendingTransport returns the transport requests of the client are sent
+// with on top of transport, forcing HTTP/2 or re-resolving host names when
+// enabled
+func sendingTransport(client *api.Client, transport *http.Transport, config *AttackConfig) http.RoundTripper {
+	switch {
+	case config.Transport != nil && config.Transport.HTTP2 == HTTP2Forced:
+		return forcedHTTP2Transport(transport, client.Address())
+	// Sockets aren't resolved, and are dialed by the client's transport
+	case config.DNSRefresh > 0 && !isUnixSocket(client):
+		return newDNSRefreshTransport(transport, config.DNSRefresh)
+	}
+	return transport
+}
+
+func openLoopAttack(client *api.Client, targeter vegeta.Targeter, pacer vegeta.Pacer, limiter *inFlightLimiter, config *AttackConfig, stop <-chan struct{}) <-chan *vegeta.Result {
+	workers, maxWorkers := config.Workers, config.Workers
+	if limiter != nil {
+		// Each attack worker has at most one request in flight, so capping
+		// the workers caps the requests in flight. More workers are started
+		// while every worker is busy, up to the cap.
+		maxWorkers = int(limiter.max)
+		workers = min(workers, maxWorkers)
+		targeter = limiter.targeter(targeter)
+		pacer = limiter.pacer(pacer)
+	}
+
 	opts := []func(*vegeta.Attacker){
 		vegeta.Workers(uint64(workers)),
-		vegeta.MaxWorkers(uint64(workers)),
+		vegeta.MaxWorkers(uint64(maxWorkers)),
 	}
 	if client != nil {
-		opts = append(opts, vegeta.Client(client.CloneConfig().HttpClient))
+		opts = append(opts, vegeta.Client(attackClient(client, config)))
 	}
 	attacker := vegeta.NewAttacker(opts...)
 
-	targeter, err := tm.Targeter(client)
-	if err != nil {
-		return nil, err
+	results := attacker.Attack(targeter, pacer, config.Duration, "Big Bang!")
+
+	// Forward the results so the watcher below exits once the attack ends
+	forwarded := make(chan *vegeta.Result)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-stop:
+			attacker.Stop()
+		case <-done:
+		}
+	}()
+	go func() {
+		defer close(forwarded)
+		defer close(done)
+		for res := range results {
+			if limiter != nil {
+				limiter.done()
+			}
+			forwarded <- res
+		}
+	}()
+	return forwarded
+}
+
+// mergeResults fans in the results of several concurrent attacks so they
+// can be consumed by a single Reporter
+func mergeResults[T any](streams ...<-chan T) <-chan T {
+	if len(streams) == 1 {
+		return streams[0]
 	}
-	rpt := newReporter(tm, client)
-	for res := range attacker.Attack(targeter, rate, duration, "Big Bang!") {
-		rpt.Add(res)
+
+	merged := make(chan T)
+	var wg sync.WaitGroup
+	for _, stream := range streams {
+		wg.Add(1)
+		go func(stream <-chan T) {
+			defer wg.Done()
+			for res := range stream {
+				merged <- res
+			}
+		}(stream)
 	}
-	rpt.Close()
 
-	return rpt, nil
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	return merged
 }
@@ -0,0 +1,120 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// closedLoopAttack starts a fixed number of workers which each issue
+// requests back-to-back until the attack duration has elapsed, the
+// configured number of requests has been sent or the stop channel is
+// closed. A worker waits for the response to its previous request, plus
+// the think time chosen for that request, before sending the next one, so
+// the offered load adapts to how quickly the target is able to respond.
+// Workers send their requests with the clients in turn.
+func closedLoopAttack(clients []*http.Client, tr vegeta.Targeter, balance *balancer, think *thinkTimer, config *AttackConfig, stop <-chan struct{}) <-chan *vegeta.Result {
+	results := make(chan *vegeta.Result)
+	deadline := time.Now().Add(config.Duration)
+
+	var seq atomic.Uint64
+	var wg sync.WaitGroup
+	for i := 0; i < config.Workers; i++ {
+		workerTr := tr
+		if balance != nil {
+			workerTr = balance.targeter(tr, i)
+		}
+		client := clients[i%len(clients)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for config.Duration == 0 || time.Now().Before(deadline) {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				n := seq.Add(1)
+				if config.Requests > 0 && n > config.Requests {
+					return
+				}
+				res := hit(client, workerTr, n-1)
+				results <- res
+				if wait := think.after(res); wait > 0 {
+					select {
+					case <-stop:
+						return
+					case <-time.After(wait):
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// hit sends a single request built from the passed in targeter and
+// records the outcome in the same form the vegeta attacker does, so
+// results from either attack mode can be fed to the same Reporter.
+func hit(client *http.Client, tr vegeta.Targeter, seq uint64) *vegeta.Result {
+	var (
+		res = vegeta.Result{Seq: seq, Timestamp: time.Now()}
+		tgt vegeta.Target
+		err error
+	)
+
+	defer func() {
+		res.Latency = time.Since(res.Timestamp)
+		if err != nil {
+			res.Error = err.Error()
+		}
+	}()
+
+	if err = tr(&tgt); err != nil {
+		return &res
+	}
+
+	res.Method = tgt.Method
+	res.URL = tgt.URL
+
+	req, err := tgt.Request()
+	if err != nil {
+		return &res
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return &res
+	}
+	defer r.Body.Close()
+
+	if res.Body, err = io.ReadAll(r.Body); err != nil {
+		return &res
+	}
+
+	res.BytesIn = uint64(len(res.Body))
+	if req.ContentLength != -1 {
+		res.BytesOut = uint64(req.ContentLength)
+	}
+
+	if res.Code = uint16(r.StatusCode); res.Code < 200 || res.Code >= 400 {
+		res.Error = r.Status
+	}
+	res.Headers = r.Header
+
+	return &res
+}
@@ -0,0 +1,632 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// RecordConfigFile and RecordTraceFile are the names of the config and
+	// trace written for a recorded workload
+	RecordConfigFile = "config.hcl"
+	RecordTraceFile  = "trace.ndjson"
+
+	// RecordDefaultMaxTests is how many tests the busiest kinds of request
+	// are recorded as by default
+	RecordDefaultMaxTests = 20
+
+	// maxAuditLine is the longest audit log entry read
+	maxAuditLine = 16 << 20
+
+	// auditMaxSegments is how many different names a segment of the paths
+	// requested below the same prefix may have before the rest of the paths
+	// are recorded in a data file rather than as tests of their own
+	auditMaxSegments = 10
+
+	// auditMaxRows is how many paths of a test are written to its data file
+	auditMaxRows = 10000
+
+	// auditHMACLength is the length of the random strings sent in place of
+	// values the audit device HMACed
+	auditHMACLength = 16
+)
+
+// auditMethods are the HTTP methods of the operations of audit logs
+var auditMethods = map[string]string{
+	"read":   "GET",
+	"list":   "LIST",
+	"create": "POST",
+	"update": "POST",
+	"patch":  "PATCH",
+	"delete": "DELETE",
+}
+
+// auditNameChars are the characters replaced in the names of recorded tests
+var auditNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// AuditRecorder gathers the requests of OpenBao audit logs to write a
+// workload of raw tests replaying them. It is safe for concurrent use, so
+// the connections of a socket audit device may be read at once.
+type AuditRecorder struct {
+	lock     sync.Mutex
+	requests []auditRequest
+	skipped  int
+}
+
+// auditRequest is a request of an audit log
+type auditRequest struct {
+	time   time.Time
+	method string
+
+	// path includes the namespace of the request, as it may be sent
+	path string
+	data map[string]interface{}
+}
+
+// auditEntry is the part of an audit log entry the recorder reads
+type auditEntry struct {
+	Type    string    `json:"type"`
+	Time    time.Time `json:"time"`
+	Request *struct {
+		Operation string `json:"operation"`
+		Path      string `json:"path"`
+		Namespace *struct {
+			Path string `json:"path"`
+		} `json:"namespace"`
+		Data map[string]interface{} `json:"data"`
+	} `json:"request"`
+}
+
+// Read records the requests of an audit log, written by the file or socket
+// audit device. Responses are ignored, and entries which can't be decoded
+// or whose operation has no HTTP method are skipped.
+func (a *AuditRecorder) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxAuditLine)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		// Entries may be prefixed, as with the prefix option of the devices
+		start := bytes.IndexByte(line, '{')
+		if start < 0 {
+			if len(bytes.TrimSpace(line)) > 0 {
+				a.skip()
+			}
+			continue
+		}
+
+		var entry auditEntry
+		if err := json.Unmarshal(line[start:], &entry); err != nil {
+			a.skip()
+			continue
+		}
+		if entry.Type != "request" {
+			continue
+		}
+		if entry.Request == nil || entry.Time.IsZero() || auditMethods[entry.Request.Operation] == "" {
+			a.skip()
+			continue
+		}
+
+		path := strings.TrimPrefix(entry.Request.Path, "/")
+		if entry.Request.Namespace != nil && strings.Trim(entry.Request.Namespace.Path, "/") != "" {
+			path = strings.Trim(entry.Request.Namespace.Path, "/") + "/" + path
+		}
+		a.lock.Lock()
+		a.requests = append(a.requests, auditRequest{
+			time:   entry.Time,
+			method: auditMethods[entry.Request.Operation],
+			path:   path,
+			data:   entry.Request.Data,
+		})
+		a.lock.Unlock()
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading audit log: %v", err)
+	}
+	return nil
+}
+
+func (a *AuditRecorder) skip() {
+	a.lock.Lock()
+	a.skipped++
+	a.lock.Unlock()
+}
+
+// Requests returns how many requests were recorded
+func (a *AuditRecorder) Requests() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return len(a.requests)
+}
+
+// Skipped returns how many entries were skipped
+func (a *AuditRecorder) Skipped() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.skipped
+}
+
+// RecordOptions are the options of a recorded workload
+type RecordOptions struct {
+	// Dir is the directory the config, trace and data files are written
+	// to, which is created when it doesn't exist
+	Dir string
+
+	// MaxTests is how many tests the busiest kinds of request are recorded
+	// as; requests of any others are left out. Defaults to
+	// RecordDefaultMaxTests.
+	MaxTests int
+
+	// Speed is how many times faster than recorded the trace replays the
+	// requests. Defaults to 1.
+	Speed float64
+
+	// AnonymizePaths writes generated names in place of the segments of
+	// the paths below the prefix of a test requesting more than one path,
+	// such as the names of users or secrets, which are otherwise kept
+	AnonymizePaths bool
+}
+
+// RecordedWorkload describes a workload written from an audit log
+type RecordedWorkload struct {
+	ConfigPath string
+	TracePath  string
+	Tests      int
+
+	// Requests is how many requests are in the trace, and Dropped how many
+	// were left out as they weren't of the busiest kinds
+	Requests int
+	Dropped  int
+
+	// Duration is how long the trace takes to replay
+	Duration time.Duration
+}
+
+// auditGroup is the requests of a recorded test: those with the same
+// method whose paths start with the prefix
+type auditGroup struct {
+	name     string
+	method   string
+	prefix   string
+	requests []*auditRequest
+}
+
+// WriteWorkload writes a config with a raw test for each of the busiest
+// kinds of request recorded and a trace of when they were sent, which the
+// config replays through replay_file. It fails when the config already
+// exists.
+func (a *AuditRecorder) WriteWorkload(opts *RecordOptions) (*RecordedWorkload, error) {
+	maxTests := opts.MaxTests
+	if maxTests == 0 {
+		maxTests = RecordDefaultMaxTests
+	}
+	if maxTests < 1 || maxTests > 100 {
+		return nil, fmt.Errorf("max tests must be between 1 and 100, got %d", maxTests)
+	}
+	speed := opts.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	if speed < 0 {
+		return nil, fmt.Errorf("speed must be positive, got %v", speed)
+	}
+
+	a.lock.Lock()
+	requests := make([]auditRequest, len(a.requests))
+	copy(requests, a.requests)
+	a.lock.Unlock()
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no requests were recorded")
+	}
+	sort.SliceStable(requests, func(i, j int) bool {
+		return requests[i].time.Before(requests[j].time)
+	})
+
+	groups := groupAuditRequests(requests)
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].requests) > len(groups[j].requests)
+	})
+	workload := &RecordedWorkload{}
+	if len(groups) > maxTests {
+		for _, group := range groups[maxTests:] {
+			workload.Dropped += len(group.requests)
+		}
+		groups = groups[:maxTests]
+	}
+	nameAuditGroups(groups)
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating directory: %v", err)
+	}
+	workload.ConfigPath = filepath.Join(opts.Dir, RecordConfigFile)
+	workload.TracePath = filepath.Join(opts.Dir, RecordTraceFile)
+	workload.Tests = len(groups)
+	file, err := os.OpenFile(workload.ConfigPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("config file %v already exists", workload.ConfigPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating config file: %v", err)
+	}
+	defer file.Close()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Recorded from %d requests sent over %v, as %d tests.\n",
+		len(requests), requests[len(requests)-1].time.Sub(requests[0].time).Round(time.Millisecond), len(groups))
+	b.WriteString("# The requests are sent to the paths which were recorded, so the mounts\n")
+	b.WriteString("# and data they use must exist on the server, and their payloads are\n")
+	b.WriteString("# random values in place of the recorded ones.\n")
+	if opts.AnonymizePaths {
+		b.WriteString("# The paths of the data files are generated in place of the recorded\n")
+		b.WriteString("# ones, and the data they use must be created at them.\n")
+	}
+	fmt.Fprintf(&b, "replay_file = %v\n", hclQuote(workload.TracePath))
+
+	weights := auditWeights(groups, len(requests)-workload.Dropped)
+	for i, group := range groups {
+		config, err := writeAuditGroup(opts.Dir, group, opts.AnonymizePaths)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "\ntest %q %q {\n", RawTestType, group.name)
+		fmt.Fprintf(&b, "    weight = %d\n", weights[i])
+		b.WriteString("    config {\n")
+		b.WriteString(config)
+		b.WriteString("    }\n}\n")
+	}
+	if _, err := io.WriteString(file, b.String()); err != nil {
+		return nil, fmt.Errorf("error writing config file: %v", err)
+	}
+
+	names := make(map[*auditRequest]string, len(requests))
+	for _, group := range groups {
+		for _, req := range group.requests {
+			names[req] = group.name
+		}
+	}
+	var trace bytes.Buffer
+	var start time.Time
+	for i := range requests {
+		name, ok := names[&requests[i]]
+		if !ok {
+			continue
+		}
+		if workload.Requests == 0 {
+			// The replay starts with the first request in it
+			start = requests[i].time
+		}
+		offset := time.Duration(float64(requests[i].time.Sub(start)) / speed)
+		record, _ := json.Marshal(map[string]interface{}{
+			"offset": json.Number(strconv.FormatFloat(offset.Seconds(), 'f', -1, 64)),
+			"test":   name,
+		})
+		trace.Write(record)
+		trace.WriteByte('\n')
+		workload.Requests++
+		workload.Duration = offset
+	}
+	if err := os.WriteFile(workload.TracePath, trace.Bytes(), 0o644); err != nil {
+		return nil, fmt.Errorf("error writing trace: %v", err)
+	}
+	return workload, nil
+}
+
+// groupAuditRequests groups the requests into the kinds of request they
+// are recorded as. Paths are split into segments, and below a prefix whose
+// next segment has more than auditMaxSegments different names the paths
+// are all of one kind. The prefix of no kind starts with that of another of
+// the same method, so every result is reported for the test it was sent by.
+func groupAuditRequests(requests []auditRequest) []*auditGroup {
+	type node struct {
+		children map[string]*node
+		requests []*auditRequest
+	}
+	roots := make(map[string]*node)
+	for i := range requests {
+		req := &requests[i]
+		n := roots[req.method]
+		if n == nil {
+			n = &node{children: make(map[string]*node)}
+			roots[req.method] = n
+		}
+		for _, segment := range strings.Split(req.path, "/") {
+			child := n.children[segment]
+			if child == nil {
+				child = &node{children: make(map[string]*node)}
+				n.children[segment] = child
+			}
+			n = child
+		}
+		n.requests = append(n.requests, req)
+	}
+
+	var groups []*auditGroup
+	var walk func(method, prefix string, n *node, group *auditGroup)
+	walk = func(method, prefix string, n *node, group *auditGroup) {
+		if group == nil && len(n.children) > auditMaxSegments {
+			group = &auditGroup{method: method, prefix: prefix}
+			if len(n.requests) > 0 {
+				// The prefix itself was requested too
+				group.prefix = strings.TrimSuffix(prefix, "/")
+			}
+			groups = append(groups, group)
+		}
+		if group != nil {
+			group.requests = append(group.requests, n.requests...)
+		} else if len(n.requests) > 0 {
+			groups = append(groups, &auditGroup{method: method, prefix: strings.TrimSuffix(prefix, "/"), requests: n.requests})
+		}
+		segments := make([]string, 0, len(n.children))
+		for segment := range n.children {
+			segments = append(segments, segment)
+		}
+		sort.Strings(segments)
+		for _, segment := range segments {
+			walk(method, prefix+segment+"/", n.children[segment], group)
+		}
+	}
+	methods := make([]string, 0, len(roots))
+	for method := range roots {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	for _, method := range methods {
+		n := roots[method]
+		// Paths start below the root, which is never requested itself
+		if len(n.children) > auditMaxSegments {
+			walk(method, "", n, nil)
+			continue
+		}
+		for segment, child := range n.children {
+			walk(method, segment+"/", child, nil)
+		}
+	}
+
+	// Kinds whose prefix starts with that of another are merged into it,
+	// the shortest prefixes first so the requests end up in the broadest
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].method != groups[j].method {
+			return groups[i].method < groups[j].method
+		}
+		return len(groups[i].prefix) < len(groups[j].prefix) ||
+			len(groups[i].prefix) == len(groups[j].prefix) && groups[i].prefix < groups[j].prefix
+	})
+	var merged []*auditGroup
+	for _, group := range groups {
+		into := -1
+		for i, kept := range merged {
+			if kept.method == group.method && strings.HasPrefix(group.prefix, kept.prefix) {
+				into = i
+				break
+			}
+		}
+		if into < 0 {
+			merged = append(merged, group)
+			continue
+		}
+		merged[into].requests = append(merged[into].requests, group.requests...)
+	}
+	for _, group := range merged {
+		sort.SliceStable(group.requests, func(i, j int) bool {
+			return group.requests[i].time.Before(group.requests[j].time)
+		})
+	}
+	return merged
+}
+
+// nameAuditGroups names the tests of the groups after their method and
+// prefix
+func nameAuditGroups(groups []*auditGroup) {
+	used := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		base := strings.ToLower(group.method)
+		if prefix := strings.Trim(auditNameChars.ReplaceAllString(strings.ToLower(group.prefix), "_"), "_"); prefix != "" {
+			base += "_" + prefix
+		}
+		if len(base) > 64 {
+			base = strings.TrimRight(base[:64], "_")
+		}
+		name := base
+		for i := 2; used[name]; i++ {
+			name = base + "_" + strconv.Itoa(i)
+		}
+		used[name] = true
+		group.name = name
+	}
+}
+
+// auditWeights returns the weights of the groups as percentages of the
+// requests, adding up to 100 with at least 1 for each group
+func auditWeights(groups []*auditGroup, total int) []int {
+	weights := make([]int, len(groups))
+	sum := 0
+	for i, group := range groups {
+		weights[i] = len(group.requests) * 100 / total
+		if weights[i] == 0 {
+			weights[i] = 1
+		}
+		sum += weights[i]
+	}
+	// The groups are sorted busiest first, which take up the difference
+	for i := 0; sum != 100; i = (i + 1) % len(weights) {
+		switch {
+		case sum < 100:
+			weights[i]++
+			sum++
+		case weights[i] > 1:
+			weights[i]--
+			sum--
+		}
+	}
+	return weights
+}
+
+// writeAuditGroup writes the data file of the group, when its requests are
+// sent to more than one path, and returns the options of its config block.
+// The paths of the data file are anonymized when anonymizePaths is set.
+func writeAuditGroup(dir string, group *auditGroup, anonymizePaths bool) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "        method = %q\n", group.method)
+
+	paths := make(map[string]bool)
+	for _, req := range group.requests {
+		paths[req.path] = true
+	}
+	if len(paths) == 1 {
+		fmt.Fprintf(&b, "        path = %v\n", hclQuote(group.requests[0].path))
+	} else {
+		fmt.Fprintf(&b, "        path = %v\n", hclQuote(group.prefix+"{{field path}}"))
+	}
+
+	for _, req := range group.requests {
+		if len(req.data) == 0 {
+			continue
+		}
+		body, err := anonymizeAuditData(req.data)
+		if err != nil {
+			return "", fmt.Errorf("error anonymizing payload of %v: %v", req.path, err)
+		}
+		fmt.Fprintf(&b, "        body = %v\n", hclQuote(body))
+		break
+	}
+
+	if len(paths) > 1 {
+		dataPath := filepath.Join(dir, group.name+".csv")
+		var data bytes.Buffer
+		w := csv.NewWriter(&data)
+		w.Write([]string{"path"})
+		// The rows are picked at random, so paths are picked as often as
+		// they were recorded
+		placeholders := make(auditPlaceholders)
+		for i, req := range group.requests {
+			if i == auditMaxRows {
+				break
+			}
+			path := strings.TrimPrefix(req.path, group.prefix)
+			if anonymizePaths {
+				path = placeholders.path(path)
+			}
+			w.Write([]string{path})
+		}
+		w.Flush()
+		if err := os.WriteFile(dataPath, data.Bytes(), 0o644); err != nil {
+			return "", fmt.Errorf("error writing data file: %v", err)
+		}
+		b.WriteString("        data_file {\n")
+		fmt.Fprintf(&b, "            path = %v\n", hclQuote(dataPath))
+		b.WriteString("            order = \"random\"\n")
+		b.WriteString("        }\n")
+	}
+	return b.String(), nil
+}
+
+// auditPlaceholders are the generated names of the segments of recorded
+// paths, so a segment recorded more than once is always given the same name
+// and the paths are requested as often as the recorded ones were
+type auditPlaceholders map[string]string
+
+// path returns the path with each of its segments replaced by its name
+func (p auditPlaceholders) path(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment == "" {
+			continue
+		}
+		name, ok := p[segment]
+		if !ok {
+			name = "segment-" + strconv.Itoa(len(p)+1)
+			p[segment] = name
+		}
+		segments[i] = name
+	}
+	return strings.Join(segments, "/")
+}
+
+// anonymizeAuditData returns a JSON payload template of the data of a
+// request, with every string replaced by random letters of the same length,
+// or auditHMACLength for those the audit device HMACed. Numbers, booleans
+// and the names of fields are kept.
+func anonymizeAuditData(data map[string]interface{}) (string, error) {
+	var anonymize func(v interface{}) interface{}
+	anonymize = func(v interface{}) interface{} {
+		switch v := v.(type) {
+		case string:
+			n := utf8.RuneCountInString(v)
+			if strings.HasPrefix(v, "hmac-") {
+				n = auditHMACLength
+			}
+			if n == 0 {
+				return ""
+			}
+			return fmt.Sprintf("{{rand_alpha %d}}", min(n, maxPayloadLength))
+		case map[string]interface{}:
+			values := make(map[string]interface{}, len(v))
+			for k, value := range v {
+				values[k] = anonymize(value)
+			}
+			return values
+		case []interface{}:
+			values := make([]interface{}, len(v))
+			for i, value := range v {
+				values[i] = anonymize(value)
+			}
+			return values
+		default:
+			return v
+		}
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(anonymize(data)); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// hclQuote returns s as an HCL string literal, which mustn't be read as a
+// template
+func hclQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		case (r == '$' || r == '%') && strings.HasPrefix(s[i+1:], "{"):
+			b.WriteRune(r)
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,148 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+func TestAuditRecorder(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var log strings.Builder
+	entry := func(offset time.Duration, op, ns, path, data string) {
+		fmt.Fprintf(&log, `{"type":"request","time":%q,"request":{"operation":%q,"namespace":{"id":"x","path":%q},"path":%q,"data":%v}}`+"\n",
+			start.Add(offset).Format(time.RFC3339Nano), op, ns, path, data)
+		fmt.Fprintf(&log, `{"type":"response","time":%q,"request":{"operation":%q,"path":%q}}`+"\n",
+			start.Add(offset).Format(time.RFC3339Nano), op, path)
+	}
+	// Reads of many secrets, which are recorded as one test
+	for i := 0; i < 30; i++ {
+		entry(time.Duration(i)*100*time.Millisecond, "read", "", fmt.Sprintf("secret/data/app-%d", i%15), "null")
+	}
+	for i := 0; i < 2; i++ {
+		entry(3*time.Second+time.Duration(i)*500*time.Millisecond, "update", "team-a/", "auth/userpass/login/alice", `{"password":"hmac-sha256:abcdef","ttl":60,"tags":["a\"b",""]}`)
+		entry(4*time.Second+time.Duration(i)*500*time.Millisecond, "list", "", "sys/mounts", "null")
+	}
+	entry(5*time.Second, "help", "", "sys/mounts", "null")
+	log.WriteString("not an entry\n")
+	log.WriteString("prefix:{\"type\":\"request\",\"time\":\"2024-01-02T03:04:11Z\",\"request\":{\"operation\":\"read\",\"path\":\"sys/health\"}}\n")
+
+	recorder := &AuditRecorder{}
+	if err := recorder.Read(strings.NewReader(log.String())); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if recorder.Requests() != 35 || recorder.Skipped() != 2 {
+		t.Fatalf("expected 35 requests and 2 skipped entries, got %d and %d", recorder.Requests(), recorder.Skipped())
+	}
+
+	dir := t.TempDir()
+	workload, err := recorder.WriteWorkload(&RecordOptions{Dir: dir, MaxTests: 3, Speed: 2})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if workload.Tests != 3 || workload.Requests != 34 || workload.Dropped != 1 || workload.Duration != 2250*time.Millisecond {
+		t.Fatalf("unexpected workload: %+v", workload)
+	}
+
+	file, diags := hclparse.NewParser().ParseHCLFile(workload.ConfigPath)
+	if diags.HasErrors() {
+		t.Fatalf("err: %v", diags)
+	}
+	config := &struct {
+		ReplayFile string `hcl:"replay_file"`
+		Tests      []*struct {
+			Type   string   `hcl:"type,label"`
+			Name   string   `hcl:"name,label"`
+			Weight int      `hcl:"weight"`
+			Config hcl.Body `hcl:",remain"`
+		} `hcl:"test,block"`
+	}{}
+	if diags := gohcl.DecodeBody(file.Body, nil, config); diags.HasErrors() {
+		t.Fatalf("err: %v", diags)
+	}
+	if config.ReplayFile != workload.TracePath || len(config.Tests) != 3 {
+		t.Fatalf("unexpected config: %+v", config)
+	}
+	// The recorded tests are raw tests
+	tests := make([]*RawTestConfig, len(config.Tests))
+	for i, test := range config.Tests {
+		builder := TestList[test.Type]().(*RawTest)
+		if err := builder.ParseConfig(test.Config); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		tests[i] = builder.config
+	}
+
+	read, login := tests[0], tests[2]
+	if config.Tests[0].Name != "get_secret_data" || config.Tests[0].Weight != 89 || read.Path != "secret/data/{{field path}}" || read.DataFile.Order != "random" {
+		t.Fatalf("unexpected test of the reads: %+v", read)
+	}
+	rows, err := os.ReadFile(filepath.Join(dir, "get_secret_data.csv"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(rows)), "\n"); len(lines) != 31 || lines[0] != "path" || lines[1] != "app-0" {
+		t.Fatalf("unexpected data file: %v", lines)
+	}
+
+	loginName := config.Tests[2].Name
+	if loginName != "post_team_a_auth_userpass_login_alice" || config.Tests[2].Weight != 5 || login.Method != "POST" || login.Path != "team-a/auth/userpass/login/alice" || login.DataFile != nil {
+		t.Fatalf("unexpected test of the login: %+v", login)
+	}
+	if login.Body != `{"password":"{{rand_alpha 16}}","tags":["{{rand_alpha 3}}",""],"ttl":60}` {
+		t.Fatalf("unexpected body: %v", login.Body)
+	}
+
+	events, err := LoadReplayFile(workload.TracePath)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(events) != 34 || events[1].Offset != 50*time.Millisecond || events[30].Test != loginName || events[30].Offset != 1500*time.Millisecond {
+		t.Fatalf("unexpected trace: %v", events)
+	}
+
+	if _, err := recorder.WriteWorkload(&RecordOptions{Dir: dir}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected an existing config to be kept, got: %v", err)
+	}
+
+	// Anonymized paths keep how often each was recorded
+	dir = t.TempDir()
+	if _, err := recorder.WriteWorkload(&RecordOptions{Dir: dir, MaxTests: 3, AnonymizePaths: true}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	rows, err = os.ReadFile(filepath.Join(dir, "get_secret_data.csv"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(rows)), "\n")
+	if len(lines) != 31 || lines[1] != "segment-1" || lines[15] != "segment-15" || lines[16] != "segment-1" || strings.Contains(string(rows), "app-") {
+		t.Fatalf("unexpected anonymized data file: %v", lines)
+	}
+}
+
+func TestHCLQuote(t *testing.T) {
+	s := "a\"b\\c\n${x}%{y}$z\x01"
+	file, diags := hclparse.NewParser().ParseHCL([]byte("v = "+hclQuote(s)+"\n"), "test.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("err: %v", diags)
+	}
+	var v struct {
+		V string `hcl:"v"`
+	}
+	if diags := gohcl.DecodeBody(file.Body, nil, &v); diags.HasErrors() {
+		t.Fatalf("err: %v", diags)
+	}
+	if v.V != s {
+		t.Fatalf("expected %q, got %q", s, v.V)
+	}
+}
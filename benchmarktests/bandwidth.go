@@ -0,0 +1,22 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// bytesPerMB converts bytes to the megabytes bandwidth is reported in
+const bytesPerMB = 1e6
+
+// bandwidth returns the megabytes per second received in responses and
+// sent in requests over the duration of the metrics. Like throughput, the
+// wait for the last responses is included in the duration.
+func bandwidth(m *vegeta.Metrics) (in, out float64) {
+	elapsed := (m.Duration + m.Wait).Seconds()
+	if elapsed <= 0 {
+		return 0, 0
+	}
+	return float64(m.BytesIn.Total) / bytesPerMB / elapsed, float64(m.BytesOut.Total) / bytesPerMB / elapsed
+}
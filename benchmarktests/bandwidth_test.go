@@ -0,0 +1,56 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestReporter_Bandwidth(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "crl", Method: "GET", PathPrefix: "/v1/pki/crl"}}}
+	rpt := newReporter(tm, nil)
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 4MB of CRLs downloaded over 2 seconds, including the wait for the
+	// last response
+	for i := 0; i < 4; i++ {
+		rpt.Add(&vegeta.Result{
+			Method:    "GET",
+			URL:       "N/A/v1/pki/crl",
+			Code:      200,
+			Timestamp: began.Add(time.Duration(i) * 500 * time.Millisecond),
+			Latency:   500 * time.Millisecond,
+			BytesIn:   1000000,
+			BytesOut:  500,
+		})
+	}
+	rpt.Close()
+
+	in, out := bandwidth(rpt.metrics["crl"])
+	if in != 2 || out != 0.001 {
+		t.Fatalf("expected 2 MB/s in and 0.001 MB/s out, got: %v, %v", in, out)
+	}
+
+	var terse bytes.Buffer
+	if err := rpt.ReportTerse(&terse); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(terse.String(), "\n")
+	header, row := strings.Fields(lines[1]), strings.Fields(lines[2])
+	if header[4] != "inMB/s" || row[4] != "2.000" || header[5] != "outMB/s" || row[5] != "0.001" {
+		t.Fatalf("unexpected bandwidth columns in:\n%s", terse.String())
+	}
+
+	var verbose bytes.Buffer
+	if err := rpt.ReportVerbose(&verbose); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(verbose.String(), "2.000 MB/s, 0.001 MB/s") {
+		t.Fatalf("expected the bandwidth in the verbose report, got:\n%s", verbose.String())
+	}
+}
@@ -24,6 +24,10 @@ import (
 type TopLevelTargetConfig struct {
 	Duration     time.Duration
 	RandomMounts bool
+
+	// IgnoreWeights is set when something other than the test weights, such
+	// as a replayed trace, decides which test each request is for
+	IgnoreWeights bool
 }
 
 const (
@@ -52,6 +56,31 @@ type BenchmarkBuilder interface {
 	Flags(fs *flag.FlagSet)
 }
 
+// MountSharer is implemented by tests which can run against the mount set up
+// by another test, so that several tests contend on the same paths
+type MountSharer interface {
+	// SetupShared is used instead of Setup when the test is configured with
+	// shared_mount. It configures the test against the mount of owner, which
+	// has already been set up, without creating a mount of its own. Cleanup
+	// of a shared test must leave the mount for the owner to remove.
+	SetupShared(client *api.Client, owner BenchmarkBuilder, config *TopLevelTargetConfig) (BenchmarkBuilder, error)
+}
+
+// EnvVarReader is implemented by tests which read options of their config,
+// such as credentials, from environment variables when they are not set
+type EnvVarReader interface {
+	// EnvVars returns the environment variables read by ParseConfig
+	EnvVars() []string
+}
+
+// FileReader is implemented by tests which read files named by their
+// config, such as a data_file or a script
+type FileReader interface {
+	// Files returns the paths of the files read by the test, as written in
+	// its config
+	Files() []string
+}
+
 var (
 	TestList     = make(map[string]func() BenchmarkBuilder)
 	targetLogger hclog.Logger
@@ -66,7 +95,67 @@ type BenchmarkTarget struct {
 	MountName  string   `hcl:"mount_name,optional"`
 	Method     string
 	PathPrefix string
-	Weight     int `hcl:"weight,optional"`
+	Weight     int    `hcl:"weight,optional"`
+	Warmup     string `hcl:"warmup,optional"`
+	RPS        *int   `hcl:"rps,optional"`
+	Duration   string `hcl:"duration,optional"`
+	Requests   int    `hcl:"requests,optional"`
+	Workers    int    `hcl:"workers,optional"`
+	ThinkTime  string `hcl:"think_time,optional"`
+	StartAfter string `hcl:"start_offset,optional"`
+
+	// Timeout, Retries, RetryBackoff and RetryMaxBackoff are the timeout
+	// and retries of the requests of this test
+	Timeout         string `hcl:"timeout,optional"`
+	Retries         int    `hcl:"retries,optional"`
+	RetryBackoff    string `hcl:"retry_backoff,optional"`
+	RetryMaxBackoff string `hcl:"retry_max_backoff,optional"`
+
+	// SharedMount names another test whose mount this test runs against
+	SharedMount string `hcl:"shared_mount,optional"`
+
+	// RandomMount overrides random_mounts for the mount of this test, and
+	// SkipCleanup leaves the mount in place when the run is cleaned up, so
+	// it can be attacked again by later runs
+	RandomMount *bool `hcl:"random_mount,optional"`
+	SkipCleanup bool  `hcl:"skip_cleanup,optional"`
+
+	ErrorBudget *ErrorBudgetConfig `hcl:"error_budget,block"`
+
+	// Regression overrides the limits checked against a baseline run for
+	// this test
+	Regression *RegressionConfig `hcl:"regression,block"`
+
+	// SLOs are objectives the results of this test must meet for the run
+	// to pass
+	SLOs []*SLOConfig `hcl:"slo,block"`
+
+	// TLS, when set, configures the TLS client the test is set up, sent
+	// and cleaned up with on its own
+	TLS *TLSConfig `hcl:"tls,block"`
+
+	// Headers are set on the requests the test is set up, sent and
+	// cleaned up with
+	Headers map[string]string `hcl:"headers,optional"`
+
+	// Token, or the token TokenSource gets when the run starts, is sent
+	// with the requests of the attack in place of the benchmark's token,
+	// while the test is still set up and cleaned up with the benchmark's
+	Token       string             `hcl:"token,optional"`
+	TokenSource *TokenSourceConfig `hcl:"token_source,block"`
+
+	// CredentialHelper is a command run when the config is loaded, whose
+	// JSON output the config of the test refers to as credential
+	CredentialHelper string `hcl:"credential_helper,optional"`
+
+	// Expect is the rules a sample of the responses of the test are
+	// checked against
+	Expect *ExpectConfig `hcl:"expect,block"`
+
+	// Before and After are hooks run right before each attack of the test
+	// starts, after any start offset, and right after it ends
+	Before []*HookConfig `hcl:"before,block"`
+	After  []*HookConfig `hcl:"after,block"`
 }
 
 type TargetInfo struct {
@@ -74,13 +163,157 @@ type TargetInfo struct {
 	pathPrefix string
 }
 
+// NewTargetInfo returns the method and path prefix of the requests of a
+// test, which results are matched to the test by, for builders of tests
+// outside this package
+func NewTargetInfo(method, pathPrefix string) TargetInfo {
+	return TargetInfo{method: method, pathPrefix: pathPrefix}
+}
+
+// WarmupDuration returns the period at the start of an attack during which
+// results for this target are excluded from the main metrics
+func (bt *BenchmarkTarget) WarmupDuration() (time.Duration, error) {
+	if bt.Warmup == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(bt.Warmup)
+}
+
+// ThinkTimeDuration returns the time a closed loop worker waits after a
+// request to this target, or zero if it uses the global think time
+func (bt *BenchmarkTarget) ThinkTimeDuration() (time.Duration, error) {
+	if bt.ThinkTime == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(bt.ThinkTime)
+}
+
+// StartOffset returns how long after the start of the attack this target
+// starts being attacked
+func (bt *BenchmarkTarget) StartOffset() (time.Duration, error) {
+	if bt.StartAfter == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(bt.StartAfter)
+}
+
+// UsesRandomMount reports whether the mount of the target gets a random
+// name, going by its own random_mount or else the global random_mounts
+func (bt *BenchmarkTarget) UsesRandomMount(randomMounts bool) bool {
+	if bt.RandomMount != nil {
+		return *bt.RandomMount
+	}
+	return randomMounts
+}
+
+// Independent reports whether the target sets its own rate, duration,
+// request count, workers or start offset, in which case it is attacked
+// separately rather than as part of the weighted mix of targets
+func (bt *BenchmarkTarget) Independent() bool {
+	return bt.RPS != nil || bt.Duration != "" || bt.Requests != 0 || bt.Workers != 0 || bt.StartAfter != ""
+}
+
+// AttackDuration returns the duration set on the target itself, or zero if
+// it uses the global duration
+func (bt *BenchmarkTarget) AttackDuration() (time.Duration, error) {
+	if bt.Duration == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(bt.Duration)
+}
+
+// attackConfig returns a copy of the passed in attack configuration with
+// the target's own rate, duration, request count and start offset applied.
+// A target with a request count but no duration of its own runs until all
+// of its requests have been sent. A target with a start offset but no
+// duration of its own stops along with the rest of the attack.
+func (bt *BenchmarkTarget) attackConfig(config *AttackConfig) (*AttackConfig, error) {
+	targetConfig := *config
+	if bt.RPS != nil {
+		targetConfig.RPS = *bt.RPS
+	}
+	if bt.Workers > 0 {
+		targetConfig.Workers = bt.Workers
+	}
+
+	duration, err := bt.AttackDuration()
+	if err != nil {
+		return nil, fmt.Errorf("invalid duration for %v: %w", bt.Name, err)
+	}
+	if duration != 0 {
+		targetConfig.Duration = duration
+	}
+
+	if bt.Requests > 0 {
+		targetConfig.Requests = uint64(bt.Requests)
+		if duration == 0 {
+			targetConfig.Duration = 0
+		}
+	}
+
+	offset, err := bt.StartOffset()
+	if err != nil {
+		return nil, fmt.Errorf("invalid start_offset for %v: %w", bt.Name, err)
+	}
+	if offset > 0 {
+		targetConfig.StartOffset = offset
+		if duration == 0 && targetConfig.Duration > 0 {
+			if offset >= targetConfig.Duration {
+				return nil, fmt.Errorf("start_offset for %v must be shorter than the duration %v", bt.Name, targetConfig.Duration)
+			}
+			targetConfig.Duration -= offset
+		}
+	}
+	return &targetConfig, nil
+}
+
 func (bt *BenchmarkTarget) ConfigureTarget(client *api.Client) {
 	bt.Target = bt.Builder.Target
+	if bt.TLS != nil {
+		bt.Target = tlsTarget(bt.Name, bt.Builder.Target)
+	}
+	if policy, _ := bt.RequestPolicy(); policy != nil {
+		bt.Target = policyTarget(bt.Name, bt.Target)
+	}
+	if len(bt.Headers) > 0 {
+		bt.Target = headersTarget(bt.Headers, bt.Target)
+	}
+	if bt.Token != "" {
+		bt.Target = tokenTarget(bt.Token, bt.Target)
+	}
 	tInfo := bt.Builder.GetTargetInfo()
 	bt.PathPrefix = tInfo.pathPrefix
 	bt.Method = tInfo.method
 }
 
+// sharedMountOwners checks the shared_mount references between tests and
+// returns the test owning the mount of each test which shares one
+func sharedMountOwners(tests []*BenchmarkTarget) (map[string]*BenchmarkTarget, error) {
+	byName := make(map[string]*BenchmarkTarget, len(tests))
+	for _, bvTest := range tests {
+		byName[bvTest.Name] = bvTest
+	}
+
+	owners := make(map[string]*BenchmarkTarget)
+	for _, bvTest := range tests {
+		if bvTest.SharedMount == "" {
+			continue
+		}
+		owner, ok := byName[bvTest.SharedMount]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("test %v shares the mount of unknown test: %v", bvTest.Name, bvTest.SharedMount)
+		case owner.SharedMount != "":
+			return nil, fmt.Errorf("test %v shares the mount of %v, which itself shares a mount", bvTest.Name, owner.Name)
+		}
+		if _, ok := bvTest.Builder.(MountSharer); !ok {
+			return nil, fmt.Errorf("test %v of type %v does not support shared_mount", bvTest.Name, bvTest.Type)
+		}
+		owners[bvTest.Name] = owner
+	}
+	return owners, nil
+}
+
 // TargetMulti allows building a vegeta targetter that chooses between various
 // operations randomly following a specified distribution.
 type TargetMulti struct {
@@ -88,8 +321,8 @@ type TargetMulti struct {
 }
 
 func (tm TargetMulti) choose(i int) *BenchmarkTarget {
-	if i > 99 || i < 0 {
-		log.Fatalf("i must be between 0 and 99")
+	if i >= tm.totalWeight() || i < 0 {
+		log.Fatalf("i must be between 0 and %d", tm.totalWeight()-1)
 	}
 
 	total := 0
@@ -104,6 +337,54 @@ func (tm TargetMulti) choose(i int) *BenchmarkTarget {
 	return nil
 }
 
+func (tm TargetMulti) totalWeight() int {
+	total := 0
+	for _, target := range tm.targets {
+		total += target.Weight
+	}
+	return total
+}
+
+// Subset returns a TargetMulti containing only the named targets. The
+// weights of the selected targets are kept, so requests are split between
+// them in the same proportions as in the full set.
+func (tm TargetMulti) Subset(names []string) (*TargetMulti, error) {
+	var subset TargetMulti
+	for _, name := range names {
+		found := false
+		for _, target := range tm.targets {
+			if target.Name == name {
+				subset.targets = append(subset.targets, target)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown test: %v", name)
+		}
+	}
+
+	if shared, _ := subset.partition(); len(shared.targets) > 0 && shared.totalWeight() <= 0 {
+		return nil, fmt.Errorf("tests %v have no weight", names)
+	}
+	return &subset, nil
+}
+
+// partition splits the targets into the weighted mix of shared targets and
+// the targets which are attacked independently
+func (tm TargetMulti) partition() (*TargetMulti, []BenchmarkTarget) {
+	var shared TargetMulti
+	var independent []BenchmarkTarget
+	for _, target := range tm.targets {
+		if target.Independent() {
+			independent = append(independent, target)
+		} else {
+			shared.targets = append(shared.targets, target)
+		}
+	}
+	return &shared, independent
+}
+
 func (tm TargetMulti) Cleanup(client *api.Client) error {
 	type CleanupMsg struct {
 		err        error
@@ -114,20 +395,30 @@ func (tm TargetMulti) Cleanup(client *api.Client) error {
 	errch := make(chan CleanupMsg)
 	var errCount int
 
+	var started int
 	for _, target := range tm.targets {
 		target := target
+		if target.SkipCleanup {
+			targetLogger.Debug("leaving mount in place", "target", target.Name)
+			continue
+		}
+		started++
 		wg.Add(1)
 		targetLogger.Debug("cleaning up", "target", target.Name)
 		go func() {
 			defer wg.Done()
+			testClient, err := target.client(client)
+			if err == nil {
+				err = target.Builder.Cleanup(testClient)
+			}
 			errch <- CleanupMsg{
-				err:        target.Builder.Cleanup(client),
+				err:        err,
 				targetName: target.Name,
 			}
 		}()
 	}
 
-	for i := 0; i < len(tm.targets); i++ {
+	for i := 0; i < started; i++ {
 		cleanupMsg := <-errch
 		if cleanupMsg.err != nil {
 			errCount++
@@ -140,11 +431,15 @@ func (tm TargetMulti) Cleanup(client *api.Client) error {
 }
 
 func (tm TargetMulti) Targeter(client *api.Client) (vegeta.Targeter, error) {
+	total := tm.totalWeight()
+	if total <= 0 {
+		return nil, vegeta.ErrNoTargets
+	}
 	return func(tgt *vegeta.Target) error {
 		if tgt == nil {
 			return vegeta.ErrNilTarget
 		}
-		rnd := int(rand.Int31n(100))
+		rnd := int(rand.Int31n(int32(total)))
 		t := tm.choose(rnd)
 		*tgt = t.Target(client)
 		return nil
@@ -168,7 +463,7 @@ func (tm TargetMulti) DebugInfo(client *api.Client) {
 		}
 		targetLogger.Debug(targetDebugInfo + fmt.Sprintf("Request: %v\n", req.URL.String()) + debugInfoFooter)
 
-		resp, err := client.CloneConfig().HttpClient.Do(req)
+		resp, err := attackClient(client, &AttackConfig{testTLS: tm.testTLS(), scenarios: tm.scenarios()}).Do(req)
 		if err != nil {
 			targetLogger.Error(fmt.Sprintf("Got err executing target request: %v", err))
 			os.Exit(1)
@@ -188,24 +483,61 @@ func (tm TargetMulti) DebugInfo(client *api.Client) {
 }
 
 func BuildTargets(client *api.Client, tests []*BenchmarkTarget, logger *hclog.Logger, config *TopLevelTargetConfig) (*TargetMulti, error) {
+	return buildTargets(client, tests, logger, config, nil)
+}
+
+// buildTargets sets up each test, calling setUp, when given, once each
+// test has been set up
+func buildTargets(client *api.Client, tests []*BenchmarkTarget, logger *hclog.Logger, config *TopLevelTargetConfig, setUp func(*BenchmarkTarget)) (*TargetMulti, error) {
 	var tm TargetMulti
 	var err error
 	targetLogger = *logger
 
 	// Check to make sure all weights add to 100
-	err = percentageValidate(tests)
+	if !config.IgnoreWeights {
+		err = percentageValidate(tests)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	owners, err := sharedMountOwners(tests)
 	if err != nil {
 		return nil, err
 	}
 
-	// Build tests
+	// Build tests, leaving those sharing another test's mount until the
+	// mount has been set up
 	for _, bvTest := range tests {
+		if bvTest.SharedMount != "" {
+			continue
+		}
 		targetLogger.Debug("setting up target", "target", hclog.Fmt("%v", bvTest.Name))
 		mountName := bvTest.Name
 		if bvTest.MountName != "" {
 			mountName = bvTest.MountName
 		}
-		bvTest.Builder, err = bvTest.Builder.Setup(client, mountName, config)
+		testClient, err := bvTest.client(client)
+		if err != nil {
+			return nil, err
+		}
+		callbacks := []api.RequestCallback{describeMounts(bvTest.Name)}
+
+		// Tests with fixed mount names which skip cleanup are set up again
+		// on the mounts earlier runs of them left in place
+		testConfig := config
+		if random := bvTest.UsesRandomMount(config.RandomMounts); random != config.RandomMounts {
+			c := *config
+			c.RandomMounts = random
+			testConfig = &c
+		}
+		if !testConfig.RandomMounts && bvTest.SkipCleanup {
+			reuse := newMountReuse(testClient, bvTest.Name)
+			callbacks = append(callbacks, reuse.request)
+			testClient = testClient.WithResponseCallbacks(reuse.response)
+		}
+		testClient = testClient.WithRequestCallbacks(callbacks...)
+		bvTest.Builder, err = bvTest.Builder.Setup(testClient, mountName, testConfig)
 		if err != nil {
 			// TODO:
 			// We should look to implement some mechanism to clean up the mount if we
@@ -214,6 +546,29 @@ func BuildTargets(client *api.Client, tests []*BenchmarkTarget, logger *hclog.Lo
 		}
 		bvTest.ConfigureTarget(client)
 		tm.targets = append(tm.targets, *bvTest)
+		if setUp != nil {
+			setUp(bvTest)
+		}
+	}
+
+	for _, bvTest := range tests {
+		if bvTest.SharedMount == "" {
+			continue
+		}
+		targetLogger.Debug("setting up target on shared mount", "target", hclog.Fmt("%v", bvTest.Name), "mount_of", bvTest.SharedMount)
+		testClient, err := bvTest.client(client)
+		if err != nil {
+			return nil, err
+		}
+		bvTest.Builder, err = bvTest.Builder.(MountSharer).SetupShared(testClient, owners[bvTest.Name].Builder, config)
+		if err != nil {
+			return nil, fmt.Errorf("error setting up %v on the mount of %v: %v", bvTest.Name, bvTest.SharedMount, err)
+		}
+		bvTest.ConfigureTarget(client)
+		tm.targets = append(tm.targets, *bvTest)
+		if setUp != nil {
+			setUp(bvTest)
+		}
 	}
 
 	// Put the biggest fractions first as an optimization
@@ -224,12 +579,20 @@ func BuildTargets(client *api.Client, tests []*BenchmarkTarget, logger *hclog.Lo
 	return &tm, nil
 }
 
+// percentageValidate checks the weights of the tests which share the
+// global rate add up to 100. Tests with their own rate or duration are
+// attacked independently and so are not weighted.
 func percentageValidate(tests []*BenchmarkTarget) error {
 	total := 0
+	shared := 0
 	for _, bvTest := range tests {
+		if bvTest.Independent() {
+			continue
+		}
+		shared++
 		total += bvTest.Weight
 	}
-	if total != 100 {
+	if shared > 0 && total != 100 {
 		return fmt.Errorf("test percentage total comes to %d, should be 100", total)
 	}
 	return nil
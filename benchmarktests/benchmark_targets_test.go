@@ -0,0 +1,184 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestPercentageValidate_IndependentTests(t *testing.T) {
+	rps := 100
+	tests := []*BenchmarkTarget{
+		{Name: "shared1", Weight: 60},
+		{Name: "shared2", Weight: 40},
+		{Name: "independent", RPS: &rps},
+	}
+	if err := percentageValidate(tests); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	tests[1].Weight = 30
+	if err := percentageValidate(tests); err == nil {
+		t.Fatal("expected error when shared weights don't add up to 100")
+	}
+
+	independentOnly := []*BenchmarkTarget{
+		{Name: "a", RPS: &rps},
+		{Name: "b", Duration: "10s"},
+	}
+	if err := percentageValidate(independentOnly); err != nil {
+		t.Fatalf("expected no error for independent tests, got: %v", err)
+	}
+}
+
+func TestTargetMulti_Partition(t *testing.T) {
+	rps := 10
+	tm := TargetMulti{targets: []BenchmarkTarget{
+		{Name: "shared", Weight: 100},
+		{Name: "independent", RPS: &rps, Duration: "5s"},
+	}}
+
+	shared, independent := tm.partition()
+	if len(shared.targets) != 1 || shared.targets[0].Name != "shared" {
+		t.Fatalf("expected only the shared target in the weighted mix, got: %v", shared.targets)
+	}
+	if len(independent) != 1 || independent[0].Name != "independent" {
+		t.Fatalf("expected one independent target, got: %v", independent)
+	}
+
+	config, err := independent[0].attackConfig(&AttackConfig{RPS: 1})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.RPS != 10 || config.Duration.String() != "5s" {
+		t.Fatalf("expected target rate and duration to be applied, got: %v", config)
+	}
+}
+
+func TestBenchmarkTarget_AttackConfigRequests(t *testing.T) {
+	target := BenchmarkTarget{Name: "issue", Requests: 1000000}
+	if !target.Independent() {
+		t.Fatal("expected a target with a request count to be attacked independently")
+	}
+
+	config, err := target.attackConfig(&AttackConfig{Duration: time.Minute})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Requests != 1000000 || config.Duration != 0 {
+		t.Fatalf("expected 1000000 requests without a time limit, got: %d requests, %v", config.Requests, config.Duration)
+	}
+
+	target.Duration = "10m"
+	config, err = target.attackConfig(&AttackConfig{Duration: time.Minute})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Duration != 10*time.Minute {
+		t.Fatalf("expected the target duration to still apply, got: %v", config.Duration)
+	}
+}
+
+func TestBenchmarkTarget_AttackConfigWorkers(t *testing.T) {
+	target := BenchmarkTarget{Name: "issue", Workers: 4}
+	if !target.Independent() {
+		t.Fatal("expected a target with its own workers to be attacked independently")
+	}
+
+	config, err := target.attackConfig(&AttackConfig{Workers: 10, Duration: time.Minute})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Workers != 4 || config.Duration != time.Minute {
+		t.Fatalf("expected 4 workers for the rest of the attack, got: %d workers, %v", config.Workers, config.Duration)
+	}
+}
+
+func TestBenchmarkTarget_AttackConfigStartOffset(t *testing.T) {
+	target := BenchmarkTarget{Name: "revoke", StartAfter: "2m"}
+	if !target.Independent() {
+		t.Fatal("expected a target with a start offset to be attacked independently")
+	}
+
+	config, err := target.attackConfig(&AttackConfig{Duration: 10 * time.Minute})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.StartOffset != 2*time.Minute || config.Duration != 8*time.Minute {
+		t.Fatalf("expected to start at 2m and stop with the rest of the attack, got: %v, %v", config.StartOffset, config.Duration)
+	}
+
+	target.Duration = "5m"
+	config, err = target.attackConfig(&AttackConfig{Duration: 10 * time.Minute})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if config.Duration != 5*time.Minute {
+		t.Fatalf("expected the target's own duration after its offset, got: %v", config.Duration)
+	}
+
+	target.Duration = ""
+	if _, err := target.attackConfig(&AttackConfig{Duration: time.Minute}); err == nil {
+		t.Fatal("expected an error for a start offset past the end of the attack")
+	}
+}
+
+func TestSharedMountOwners(t *testing.T) {
+	read := &BenchmarkTarget{Name: "read", Type: KVV2ReadTestType, Builder: &KVV2Test{action: "read"}}
+	write := &BenchmarkTarget{Name: "write", Type: KVV2WriteTestType, Builder: &KVV2Test{action: "write"}, SharedMount: "read"}
+
+	owners, err := sharedMountOwners([]*BenchmarkTarget{read, write})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if owners["write"] != read {
+		t.Fatalf("expected write to use the mount of read, got: %v", owners["write"])
+	}
+
+	write.SharedMount = "missing"
+	if _, err := sharedMountOwners([]*BenchmarkTarget{read, write}); err == nil {
+		t.Fatal("expected an error for an unknown test")
+	}
+
+	list := &BenchmarkTarget{Name: "list", Type: KVV2ListTestType, Builder: &KVV2Test{action: "list"}, SharedMount: "write"}
+	write.SharedMount = "read"
+	if _, err := sharedMountOwners([]*BenchmarkTarget{read, write, list}); err == nil {
+		t.Fatal("expected an error when sharing the mount of a test which itself shares a mount")
+	}
+
+	status := &BenchmarkTarget{Name: "status", Type: "sys_status", Builder: &StatusCheck{}, SharedMount: "read"}
+	if _, err := sharedMountOwners([]*BenchmarkTarget{read, status}); err == nil {
+		t.Fatal("expected an error for a test which can't share a mount")
+	}
+}
+
+func TestSetupShared_OwnerKeys(t *testing.T) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetLogger = hclog.NewNullLogger()
+	owner := &KVV2Test{pathPrefix: "/v1/kv", numKVs: 10}
+	sharer := &KVV2Test{action: "read", config: &KVV2SecretTestConfig{NumKVs: 1000}}
+	builder, err := sharer.SetupShared(client, owner, &TopLevelTargetConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Only the secrets the owner seeded are read, whatever numkvs the test
+	// sharing its mount sets
+	for i := 0; i < 1000; i++ {
+		url := builder.Target(client).URL
+		key, ok := strings.CutPrefix(url, client.Address()+"/v1/kv/data/secret-")
+		if n, err := strconv.Atoi(key); !ok || err != nil || n < 1 || n > 10 {
+			t.Fatalf("expected a key seeded by the owner, got: %v", url)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// DefaultRecoveryWindow is how long results must stay healthy after a
+	// chaos event for the target to count as recovered
+	DefaultRecoveryWindow = 5 * time.Second
+
+	// chaosActionTimeout bounds how long the action of a chaos event may
+	// take
+	chaosActionTimeout = 5 * time.Minute
+
+	// recoveryBaseline is how much of the attack before a chaos event the
+	// healthy error rate and latency are measured over
+	recoveryBaseline = time.Minute
+)
+
+// ChaosConfig runs an action, such as restarting or deleting the node
+// being benchmarked, at set points of a run, so the impact of failures on
+// latencies and errors, and the time taken to recover from them, can be
+// measured. Exactly one action is given.
+type ChaosConfig struct {
+	Name           string   `hcl:"name,label"`
+	At             string   `hcl:"at"`
+	Command        []string `hcl:"command,optional"`
+	DockerRestart  string   `hcl:"docker_restart,optional"`
+	DeletePod      string   `hcl:"kubernetes_delete_pod,optional"`
+	RecoveryWindow string   `hcl:"recovery_window,optional"`
+}
+
+// Validate checks that the chaos block has exactly one action and a valid
+// recovery window. The points it runs at are checked against the duration
+// of the run.
+func (c *ChaosConfig) Validate() error {
+	actions := 0
+	for _, set := range []bool{len(c.Command) > 0, c.DockerRestart != "", c.DeletePod != ""} {
+		if set {
+			actions++
+		}
+	}
+	if actions != 1 {
+		return fmt.Errorf("exactly one of command, docker_restart or kubernetes_delete_pod must be set")
+	}
+	if _, err := c.Window(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Window returns how long results must stay healthy after the event for
+// the target to count as recovered
+func (c *ChaosConfig) Window() (time.Duration, error) {
+	return parseRecoveryWindow(c.RecoveryWindow)
+}
+
+func parseRecoveryWindow(raw string) (time.Duration, error) {
+	if raw == "" {
+		return DefaultRecoveryWindow, nil
+	}
+	window, err := time.ParseDuration(raw)
+	if err != nil || window < time.Second {
+		return 0, fmt.Errorf("invalid recovery_window %q: must be a duration of at least 1s", raw)
+	}
+	return window, nil
+}
+
+// ChaosEvent is the action of a chaos block scheduled at a point of a run.
+// Built-in scenarios, such as failover, set up the part of the result they
+// measure when they run.
+type ChaosEvent struct {
+	Name   string
+	At     time.Duration
+	Action string
+	Window time.Duration
+	Run    func(ctx context.Context, result *ChaosEventResult) error
+}
+
+// ChaosEventResult records when a chaos event happened and how long the
+// target took to recover from it. Recovery is nil when the results didn't
+// return to healthy before the attack ended.
+type ChaosEventResult struct {
+	Name     string          `json:"name"`
+	Action   string          `json:"action"`
+	Time     time.Time       `json:"time"`
+	Took     time.Duration   `json:"took"`
+	Error    string          `json:"error,omitempty"`
+	Window   time.Duration   `json:"recovery_window"`
+	Recovery *time.Duration  `json:"recovery,omitempty"`
+	Failover *FailoverResult `json:"failover,omitempty"`
+	Snapshot *SnapshotResult `json:"snapshot,omitempty"`
+}
+
+// ChaosRunner runs chaos events at their points of a run, each in the
+// background so a slow action doesn't delay the events after it
+type ChaosRunner struct {
+	Events []*ChaosEvent
+	Logger hclog.Logger
+
+	wg      sync.WaitGroup
+	l       sync.Mutex
+	results []ChaosEventResult
+}
+
+// Start runs every event at its point after began. Events which haven't
+// been reached when stop is closed are skipped; Wait waits for the actions
+// already started.
+func (c *ChaosRunner) Start(began time.Time, stop <-chan struct{}) {
+	events := append([]*ChaosEvent(nil), c.Events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At < events[j].At })
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for _, event := range events {
+			select {
+			case <-time.After(time.Until(began.Add(event.At))):
+			case <-stop:
+				return
+			}
+			c.wg.Add(1)
+			go func(event *ChaosEvent) {
+				defer c.wg.Done()
+				c.run(event)
+			}(event)
+		}
+	}()
+}
+
+func (c *ChaosRunner) run(event *ChaosEvent) {
+	c.Logger.Info("running chaos event", "event", event.Name, "action", event.Action)
+	ctx, cancel := context.WithTimeout(context.Background(), chaosActionTimeout)
+	defer cancel()
+	result := ChaosEventResult{Name: event.Name, Action: event.Action, Time: time.Now(), Window: event.Window}
+	err := event.Run(ctx, &result)
+	result.Took = time.Since(result.Time)
+	if err != nil {
+		result.Error = err.Error()
+		c.Logger.Error("chaos event failed", "event", event.Name, "error", hclog.Fmt("%v", err))
+	}
+
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.results = append(c.results, result)
+}
+
+// Wait waits for the actions of the events already started to finish
+func (c *ChaosRunner) Wait() {
+	c.wg.Wait()
+}
+
+// Results returns the events which happened, in the order they happened
+func (c *ChaosRunner) Results() []ChaosEventResult {
+	c.l.Lock()
+	defer c.l.Unlock()
+	results := append([]ChaosEventResult(nil), c.results...)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Time.Before(results[j].Time) })
+	return results
+}
+
+// recoverySecond counts the results sent during one second of the attack
+type recoverySecond struct {
+	requests uint64
+	errors   uint64
+	latency  time.Duration
+
+	// firstError and lastError are when the first and last failed
+	// requests of the second were sent, and successes holds the latencies
+	// of the requests which succeeded
+	firstError time.Time
+	lastError  time.Time
+	successes  vegeta.LatencyMetrics
+}
+
+// trackRecovery enables counting the results of every second of the
+// attack, so the time taken to recover from chaos events can be found. It
+// must be called after startWarmup.
+func (r *Reporter) trackRecovery(enabled bool) {
+	if enabled {
+		r.recovery = []recoverySecond{}
+	}
+}
+
+func (r *Reporter) recordRecovery(result *vegeta.Result) {
+	i := int(result.Timestamp.Sub(r.began) / time.Second)
+	if i < 0 {
+		return
+	}
+	for len(r.recovery) <= i {
+		r.recovery = append(r.recovery, recoverySecond{})
+	}
+	s := &r.recovery[i]
+	s.requests++
+	s.latency += result.Latency
+	if result.Error == "" {
+		s.successes.Add(result.Latency)
+		return
+	}
+	s.errors++
+	if s.firstError.IsZero() || result.Timestamp.Before(s.firstError) {
+		s.firstError = result.Timestamp
+	}
+	if result.Timestamp.After(s.lastError) {
+		s.lastError = result.Timestamp
+	}
+}
+
+// SetChaosEvents records the chaos events which happened during the
+// attack of the report, annotating the points of the time series they
+// happened in. The time to recover from each is found from the results
+// when they were tracked during the attack.
+func (r *Reporter) SetChaosEvents(events []ChaosEventResult) {
+	end := r.began.Add(time.Duration(len(r.recovery)) * time.Second)
+	if m, ok := r.metrics["total"]; ok && m.End.After(end) {
+		end = m.End
+	}
+	for _, event := range events {
+		if event.Time.Before(r.began) || event.Time.After(end) {
+			continue
+		}
+		if r.recovery != nil {
+			event.Recovery = r.recoveryTime(event.Time, event.Window)
+			if event.Failover != nil {
+				event.Failover = r.failoverResult(event)
+			}
+			if event.Snapshot != nil {
+				event.Snapshot = r.snapshotResult(event)
+			}
+		}
+		r.chaosEvents = append(r.chaosEvents, event)
+
+		for name, points := range r.timeseries {
+			for i := range points {
+				if !event.Time.Before(points[i].Start) && event.Time.Before(points[i].Start.Add(r.seriesInterval)) {
+					r.timeseries[name][i].Events = append(r.timeseries[name][i].Events, event.Name)
+				}
+			}
+		}
+	}
+}
+
+// recoveryTime returns the time from a chaos event to the start of the
+// first window of healthy seconds after it. A second is healthy when its
+// error rate is at most a percentage point above, and its mean latency at
+// most twice, that of the minute before the event.
+func (r *Reporter) recoveryTime(at time.Time, window time.Duration) *time.Duration {
+	first := int(at.Sub(r.began) / time.Second)
+	baseline := r.baseline(first)
+	maxErrorRate := 0.01
+	var maxMean time.Duration
+	if baseline.requests > 0 {
+		maxErrorRate += float64(baseline.errors) / float64(baseline.requests)
+		maxMean = 2 * baseline.latency / time.Duration(baseline.requests)
+	}
+	healthy := func(s recoverySecond) bool {
+		if s.requests == 0 || float64(s.errors)/float64(s.requests) > maxErrorRate {
+			return false
+		}
+		return maxMean == 0 || s.latency/time.Duration(s.requests) <= maxMean
+	}
+
+	needed := int((window + time.Second - 1) / time.Second)
+	run := 0
+	for i := first; i < len(r.recovery); i++ {
+		if !healthy(r.recovery[i]) {
+			run = 0
+			continue
+		}
+		run++
+		if run == needed {
+			start := r.began.Add(time.Duration(i-needed+1) * time.Second)
+			recovery := max(start.Sub(at), 0)
+			return &recovery
+		}
+	}
+	return nil
+}
+
+// baseline sums the results of the minute of the attack before the given
+// second
+func (r *Reporter) baseline(second int) recoverySecond {
+	var baseline recoverySecond
+	for i := max(second-int(recoveryBaseline/time.Second), 0); i < second && i < len(r.recovery); i++ {
+		baseline.requests += r.recovery[i].requests
+		baseline.errors += r.recovery[i].errors
+		baseline.latency += r.recovery[i].latency
+	}
+	return baseline
+}
+
+// baselineP99 returns the median of the 99th percentile latency of the
+// successful requests of every second of the minute of the attack before
+// the given second, so a single slow second doesn't skew it. It is zero
+// when no requests succeeded.
+func (r *Reporter) baselineP99(second int) time.Duration {
+	var p99s []time.Duration
+	for i := max(second-int(recoveryBaseline/time.Second), 0); i < second && i < len(r.recovery); i++ {
+		if r.recovery[i].successes.Max > 0 {
+			p99s = append(p99s, r.recovery[i].successes.Quantile(0.99))
+		}
+	}
+	if len(p99s) == 0 {
+		return 0
+	}
+	sort.Slice(p99s, func(i, j int) bool { return p99s[i] < p99s[j] })
+	return p99s[len(p99s)/2]
+}
+
+// reportChaos writes a table of the chaos events of the attack, when it
+// has any, and the time taken to recover from each
+func (r *Reporter) reportChaos(w io.Writer) {
+	if len(r.chaosEvents) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "\nChaos events:\n")
+	fmt.Fprintf(tw, "event\tat\taction\trecovery\t\n")
+	for _, event := range r.chaosEvents {
+		recovery := "not recovered"
+		switch {
+		case event.Error != "":
+			recovery = "failed: " + event.Error
+		case event.Recovery != nil:
+			recovery = event.Recovery.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t+%s\t%s\t%s\t\n", event.Name, event.Time.Sub(r.began).Round(time.Second), event.Action, recovery)
+	}
+	tw.Flush()
+}
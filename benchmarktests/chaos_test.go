@@ -0,0 +1,133 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestChaosConfigValidate(t *testing.T) {
+	cases := []struct {
+		config ChaosConfig
+		err    string
+	}{
+		{ChaosConfig{Name: "kill", At: "1m", DockerRestart: "bao-0"}, ""},
+		{ChaosConfig{Name: "kill", At: "1m", Command: []string{"true"}, RecoveryWindow: "10s"}, ""},
+		{ChaosConfig{Name: "kill", At: "1m"}, "exactly one of"},
+		{ChaosConfig{Name: "kill", At: "1m", DockerRestart: "bao-0", DeletePod: "active"}, "exactly one of"},
+		{ChaosConfig{Name: "kill", At: "1m", DeletePod: "active", RecoveryWindow: "100ms"}, "invalid recovery_window"},
+	}
+	for _, tc := range cases {
+		err := tc.config.Validate()
+		if tc.err == "" && err != nil {
+			t.Errorf("%+v: unexpected error: %v", tc.config, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%+v: expected error containing %q, got %v", tc.config, tc.err, err)
+		}
+	}
+}
+
+func TestChaosRecovery(t *testing.T) {
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.startWarmup(began, 0)
+	rpt.trackTimeseries(10 * time.Second)
+	rpt.trackRecovery(true)
+
+	// The target fails for 10 seconds after the event 30 seconds in, then
+	// recovers, with slower requests for the first seconds after
+	for i := 0; i < 60; i++ {
+		for j := 0; j < 10; j++ {
+			result := &vegeta.Result{
+				Method:    "GET",
+				URL:       "N/A/v1/secret/foo",
+				Code:      200,
+				Timestamp: began.Add(time.Duration(i)*time.Second + time.Duration(j)*100*time.Millisecond),
+				Latency:   time.Millisecond,
+			}
+			switch {
+			case i >= 30 && i < 40:
+				result.Code, result.Error = 503, "503 Service Unavailable"
+			case i >= 40 && i < 42:
+				result.Latency = 5 * time.Millisecond
+			}
+			rpt.Add(result)
+		}
+	}
+	rpt.Close()
+
+	rpt.SetChaosEvents([]ChaosEventResult{
+		{Name: "early", Time: began.Add(-time.Minute), Window: DefaultRecoveryWindow},
+		{Name: "kill", Action: "docker restart bao-0", Time: began.Add(30500 * time.Millisecond), Window: DefaultRecoveryWindow},
+		{Name: "late", Time: began.Add(57 * time.Second), Window: DefaultRecoveryWindow},
+	})
+	if len(rpt.chaosEvents) != 2 {
+		t.Fatalf("expected the events during the attack, got %+v", rpt.chaosEvents)
+	}
+	kill := rpt.chaosEvents[0]
+	if kill.Recovery == nil || *kill.Recovery != 11500*time.Millisecond {
+		t.Fatalf("expected recovery 11.5s after the event, got %v", kill.Recovery)
+	}
+	if late := rpt.chaosEvents[1]; late.Recovery != nil {
+		t.Fatalf("expected no recovery within the attack, got %v", *late.Recovery)
+	}
+
+	var annotated []string
+	for _, p := range rpt.timeseries["total"] {
+		if len(p.Events) > 0 {
+			annotated = append(annotated, fmt.Sprintf("%v=%v", p.Start.Sub(began), p.Events))
+		}
+	}
+	if want := []string{"30s=[kill]", "50s=[late]"}; !reflect.DeepEqual(annotated, want) {
+		t.Fatalf("got annotated points %v, want %v", annotated, want)
+	}
+
+	var b bytes.Buffer
+	rpt.reportChaos(&b)
+	for _, want := range []string{"Chaos events:", "kill", "+31s", "docker restart bao-0", "11.5s", "not recovered"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected %q in chaos report:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestChaosRunner(t *testing.T) {
+	ran := make(chan string, 2)
+	runner := &ChaosRunner{
+		Events: []*ChaosEvent{
+			{Name: "later", At: time.Hour, Run: func(ctx context.Context, result *ChaosEventResult) error {
+				ran <- "later"
+				return nil
+			}},
+			{Name: "kill", At: 0, Action: "kill", Run: func(ctx context.Context, result *ChaosEventResult) error {
+				ran <- "kill"
+				return fmt.Errorf("no such container")
+			}},
+		},
+		Logger: hclog.NewNullLogger(),
+	}
+	stop := make(chan struct{})
+	runner.Start(time.Now(), stop)
+	if name := <-ran; name != "kill" {
+		t.Fatalf("expected kill to run first, got %v", name)
+	}
+	close(stop)
+	runner.Wait()
+
+	results := runner.Results()
+	if len(results) != 1 || results[0].Name != "kill" || results[0].Error != "no such container" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
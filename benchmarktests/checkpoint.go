@@ -0,0 +1,424 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// CheckpointVersion is the version of the layout of checkpoint files.
+	// Runs aren't resumed from checkpoints of another version.
+	CheckpointVersion = 1
+
+	// DefaultCheckpointInterval is how often the progress of a run is saved
+	DefaultCheckpointInterval = time.Minute
+)
+
+// Checkpoint is the progress of a run saved to disk, so a run which was
+// interrupted, such as by its pod being rescheduled during a soak test, can
+// be resumed instead of started over
+type Checkpoint struct {
+	Version int `json:"version"`
+
+	// ConfigHash identifies the configuration of the run, which must be
+	// the same for the run to be resumed
+	ConfigHash string    `json:"config_hash"`
+	RunID      string    `json:"run_id,omitempty"`
+	Started    time.Time `json:"started"`
+	Updated    time.Time `json:"updated"`
+
+	// Mounts are the secrets engines and auth methods the tests were set up
+	// on, and Namespaces those they were fanned out into. Tests are set up
+	// again when the run is resumed, so these are removed first.
+	Mounts     []string `json:"mounts,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Targets are the progress of the phases of the run against each
+	// target address
+	Targets map[string][]*PhaseCheckpoint `json:"targets,omitempty"`
+}
+
+// PhaseCheckpoint is the progress of a phase of a run against one target
+type PhaseCheckpoint struct {
+	Name string `json:"name,omitempty"`
+	Done bool   `json:"done"`
+
+	// Elapsed is how long the phase was attacked for
+	Elapsed time.Duration `json:"elapsed"`
+
+	// Report is the JSON report of the results of the phase so far
+	Report json.RawMessage `json:"report,omitempty"`
+}
+
+// LoadCheckpoint reads the checkpoint at path, returning nil when there is
+// none
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %v", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("error decoding checkpoint: %v", err)
+	}
+	return &cp, nil
+}
+
+// Resumable reports whether a run of the configuration with the hash can be
+// resumed from the checkpoint
+func (cp *Checkpoint) Resumable(configHash string) bool {
+	return cp != nil && cp.Version == CheckpointVersion && cp.ConfigHash == configHash
+}
+
+// Cleanup removes the mounts and namespaces the tests of the interrupted
+// run were set up on with the client
+func (cp *Checkpoint) Cleanup(client *api.Client) error {
+	var failed int
+	var lastErr error
+	for _, mount := range cp.Mounts {
+		if err := RemoveMount(client, mount); err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	for _, ns := range cp.Namespaces {
+		if err := RemoveNamespace(client, ns); err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d errors removing the mounts and namespaces of the checkpoint: %v", failed, lastErr)
+	}
+	return nil
+}
+
+// CheckpointFile saves the progress of a run to a file every interval while
+// its phases are attacked, and as each of them completes. The file is
+// replaced atomically, so it always holds a complete checkpoint.
+type CheckpointFile struct {
+	path     string
+	interval time.Duration
+
+	l     sync.Mutex
+	state *Checkpoint
+	err   error
+}
+
+// NewCheckpointFile returns a checkpoint file at path holding state, which
+// is either that of a resumed run or a new one
+func NewCheckpointFile(path string, interval time.Duration, state *Checkpoint) *CheckpointFile {
+	state.Version = CheckpointVersion
+	if state.Targets == nil {
+		state.Targets = make(map[string][]*PhaseCheckpoint)
+	}
+	return &CheckpointFile{path: path, interval: interval, state: state}
+}
+
+// SetTargets records the mounts the targets were set up on, or the
+// namespaces they were fanned out into, and saves the checkpoint
+func (c *CheckpointFile) SetTargets(tm *TargetMulti, namespaces []string) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.state.Namespaces = namespaces
+	c.state.Mounts = nil
+	if len(namespaces) == 0 {
+		c.state.Mounts = tm.mounts()
+	}
+	return c.save()
+}
+
+// Phase returns the progress of the phase at index of the run against the
+// target address, which the phase is resumed from and saved to
+func (c *CheckpointFile) Phase(addr string, index int, name string) (*PhaseProgress, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	phases := c.state.Targets[addr]
+	for len(phases) <= index {
+		phases = append(phases, &PhaseCheckpoint{})
+	}
+	c.state.Targets[addr] = phases
+	cp := phases[index]
+	if len(cp.Report) == 0 {
+		cp.Name = name
+	}
+	if cp.Name != name {
+		// The phases of the configuration changed, which should have
+		// changed its hash
+		return nil, fmt.Errorf("checkpoint has phase %q where %q was expected", cp.Name, name)
+	}
+
+	p := &PhaseProgress{file: c, addr: addr, index: index, name: name, elapsed: cp.Elapsed}
+	if len(cp.Report) > 0 {
+		rpts, err := FromReader(bytes.NewReader(cp.Report))
+		if err != nil {
+			return nil, fmt.Errorf("error loading report of phase %q: %v", name, err)
+		}
+		if len(rpts) != 1 {
+			return nil, fmt.Errorf("checkpoint has %d reports of phase %q", len(rpts), name)
+		}
+		p.report = rpts[0]
+	}
+	p.done = cp.Done && p.report != nil
+	return p, nil
+}
+
+// Err returns the first error saving the checkpoint, if any
+func (c *CheckpointFile) Err() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.err
+}
+
+// Remove deletes the checkpoint file once the run has completed, so the
+// next run starts over
+func (c *CheckpointFile) Remove() error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error removing checkpoint: %v", err)
+	}
+	return nil
+}
+
+// update replaces the progress of a phase and saves the checkpoint
+func (c *CheckpointFile) update(addr string, index int, cp *PhaseCheckpoint) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.state.Targets[addr][index] = cp
+	if err := c.save(); err != nil && c.err == nil {
+		c.err = err
+	}
+}
+
+// save writes the checkpoint to a temporary file next to its path and
+// renames it into place
+func (c *CheckpointFile) save() error {
+	c.state.Updated = time.Now()
+	data, err := json.Marshal(c.state)
+	if err != nil {
+		return fmt.Errorf("error encoding checkpoint: %v", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error writing checkpoint: %v", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing checkpoint: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing checkpoint: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("error writing checkpoint: %v", err)
+	}
+	return nil
+}
+
+// PhaseProgress is the progress of a phase of a run against one target.
+// An attack given it skips a phase which already completed, resumes one
+// which was interrupted from where it was, and saves its results as it
+// goes.
+type PhaseProgress struct {
+	file  *CheckpointFile
+	addr  string
+	index int
+	name  string
+
+	// elapsed and report are the time the phase was attacked for and its
+	// results before it was resumed
+	elapsed time.Duration
+	report  *Reporter
+	done    bool
+
+	started time.Time
+	saved   time.Time
+}
+
+// Elapsed returns how long the phase was attacked for before it was
+// resumed
+func (p *PhaseProgress) Elapsed() time.Duration {
+	if p == nil {
+		return 0
+	}
+	return p.elapsed
+}
+
+// sent returns the number of requests sent to the named tests before the
+// phase was resumed
+func (p *PhaseProgress) sent(names []string) uint64 {
+	if p.report == nil {
+		return 0
+	}
+	var sent uint64
+	for _, name := range names {
+		if m, ok := p.report.metrics[name]; ok {
+			sent += m.Requests
+		}
+		if m, ok := p.report.warmupMetrics[name]; ok {
+			sent += m.Requests
+		}
+	}
+	return sent
+}
+
+// resume shortens the attack of the named tests by the time the phase was
+// attacked for and the requests it sent before it was resumed, returning
+// false when nothing of it is left
+func (p *PhaseProgress) resume(config *AttackConfig, names []string) bool {
+	elapsed := p.Elapsed()
+	if elapsed <= 0 {
+		return true
+	}
+	if config.StartOffset >= elapsed {
+		config.StartOffset -= elapsed
+		return true
+	}
+	elapsed -= config.StartOffset
+	config.StartOffset = 0
+	config.Warmup = max(config.Warmup-elapsed, 0)
+	if config.Duration > 0 {
+		if config.Duration <= elapsed {
+			return false
+		}
+		config.Duration -= elapsed
+	}
+	if config.Requests > 0 {
+		sent := p.sent(names)
+		if sent >= config.Requests {
+			return false
+		}
+		config.Requests -= sent
+	}
+	return true
+}
+
+// start marks the time the phase starts being attacked
+func (p *PhaseProgress) start(now time.Time) {
+	p.started = now
+	p.saved = now
+}
+
+// update saves the results of the phase so far once the checkpoint
+// interval has passed since they were last saved
+func (p *PhaseProgress) update(rpt *Reporter) {
+	now := time.Now()
+	if p.file.interval <= 0 || now.Sub(p.saved) < p.file.interval {
+		return
+	}
+	p.saved = now
+	rpt.closeMetrics()
+	p.save(rpt, false)
+}
+
+// save saves the results of the phase joined with those from before it
+// was resumed, returning them. The metrics of rpt must be closed.
+func (p *PhaseProgress) save(rpt *Reporter, done bool) *Reporter {
+	if p.report != nil {
+		rpt = joinReports(p.report, rpt)
+	}
+	cp := &PhaseCheckpoint{
+		Name:    p.name,
+		Done:    done,
+		Elapsed: p.elapsed + time.Since(p.started),
+	}
+	var b bytes.Buffer
+	if err := rpt.ReportJSON(&b); err == nil {
+		cp.Report = bytes.TrimSpace(b.Bytes())
+	}
+	p.file.update(p.addr, p.index, cp)
+	return rpt
+}
+
+// names returns the names of the targets
+func (tm TargetMulti) names() []string {
+	names := make([]string, 0, len(tm.targets))
+	for _, target := range tm.targets {
+		names = append(names, target.Name)
+	}
+	return names
+}
+
+// mounts returns the paths of the secrets engines and auth methods the
+// targets were set up on. Targets of system paths don't have one, and
+// those of targets which skip cleanup are left for later runs.
+func (tm TargetMulti) mounts() []string {
+	var mounts []string
+	seen := make(map[string]bool)
+	for _, target := range tm.targets {
+		if target.SkipCleanup {
+			continue
+		}
+		p, ok := strings.CutPrefix(target.PathPrefix, "/v1/")
+		if !ok || p == "" || strings.HasPrefix(p, "sys/") {
+			continue
+		}
+		parts := strings.Split(p, "/")
+		n := 1
+		if parts[0] == "auth" && len(parts) > 1 {
+			n = 2
+		}
+		mount := strings.Join(parts[:n], "/")
+		if !seen[mount] {
+			seen[mount] = true
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts
+}
+
+// joinReports combines the reports of consecutive parts of the same attack,
+// such as one which was interrupted and the rest of it once resumed. Counts
+// are summed and histograms merged like the reports of a distributed run,
+// but rates are those over the combined duration of the parts.
+func joinReports(rpts ...*Reporter) *Reporter {
+	m := mergeGroup(rpts)
+	joinRates(m.metrics, rpts, func(r *Reporter) map[string]*vegeta.Metrics { return r.metrics })
+	joinRates(m.warmupMetrics, rpts, func(r *Reporter) map[string]*vegeta.Metrics { return r.warmupMetrics })
+	joinRates(m.burstMetrics, rpts, func(r *Reporter) map[string]*vegeta.Metrics { return r.burstMetrics })
+	joinRates(m.addrMetrics, rpts, func(r *Reporter) map[string]*vegeta.Metrics { return r.addrMetrics })
+	joinRates(m.nsMetrics, rpts, func(r *Reporter) map[string]*vegeta.Metrics { return r.nsMetrics })
+	for name := range m.opMetrics {
+		joinRates(m.opMetrics[name], rpts, func(r *Reporter) map[string]*vegeta.Metrics { return r.opMetrics[name] })
+	}
+	return m
+}
+
+// joinRates replaces the rates of metrics merged from consecutive parts of
+// an attack, which are summed, with those over their combined duration
+func joinRates(merged map[string]*vegeta.Metrics, rpts []*Reporter, set func(*Reporter) map[string]*vegeta.Metrics) {
+	for name, m := range merged {
+		var duration time.Duration
+		for _, rpt := range rpts {
+			if part, ok := set(rpt)[name]; ok && part.Requests > 0 {
+				duration += part.Duration
+			}
+		}
+		m.Duration = duration
+		m.Rate, m.Throughput = 0, 0
+		if secs := duration.Seconds(); secs > 0 {
+			m.Rate = float64(m.Requests) / secs
+			m.Throughput = m.Success * float64(m.Requests) / (duration + m.Wait).Seconds()
+		}
+	}
+}
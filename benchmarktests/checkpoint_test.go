@@ -0,0 +1,122 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestCheckpoint_Resume(t *testing.T) {
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := &TargetMulti{targets: []BenchmarkTarget{{
+		Name:       "health",
+		Method:     "GET",
+		PathPrefix: "/v1/sys/health",
+		Weight:     100,
+		Builder:    &StatusCheck{},
+		Target: func(client *api.Client) vegeta.Target {
+			return vegeta.Target{Method: "GET", URL: client.Address() + "/v1/sys/health"}
+		},
+	}}}
+	attack := func(cf *CheckpointFile, duration time.Duration) *Reporter {
+		t.Helper()
+		progress, err := cf.Phase(client.Address(), 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rpt, err := Attack(tm, client, &AttackConfig{
+			Mode:       OpenLoopAttackMode,
+			RPS:        100,
+			Workers:    1,
+			Duration:   duration,
+			Checkpoint: progress,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rpt
+	}
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	cf := NewCheckpointFile(path, time.Nanosecond, &Checkpoint{ConfigHash: "a", Started: time.Now()})
+	first := attack(cf, 200*time.Millisecond)
+	sent := first.metrics["total"].Requests
+
+	cp, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cp.Resumable("a") || cp.Resumable("b") {
+		t.Fatalf("expected the checkpoint to be resumable by its configuration alone")
+	}
+	phase := cp.Targets[client.Address()][0]
+	if !phase.Done || phase.Elapsed < 200*time.Millisecond {
+		t.Fatalf("expected the phase to be saved as done after 200ms, got %+v", phase)
+	}
+
+	// A completed phase isn't attacked again
+	rpt := attack(NewCheckpointFile(path, time.Nanosecond, cp), 200*time.Millisecond)
+	if requests.Load() != int64(sent) || rpt.metrics["total"].Requests != sent {
+		t.Fatalf("expected the completed phase to be skipped, got %d requests", requests.Load()-int64(sent))
+	}
+
+	// An interrupted phase is attacked for what is left of it
+	phase.Done = false
+	rpt = attack(NewCheckpointFile(path, time.Nanosecond, cp), 300*time.Millisecond)
+	resumed := uint64(requests.Load()) - sent
+	if resumed == 0 || resumed > sent {
+		t.Fatalf("expected about 100ms of the phase to be left after 200ms of 300ms, got %d requests after %d", resumed, sent)
+	}
+	total := rpt.metrics["total"]
+	if total.Requests != sent+resumed {
+		t.Fatalf("expected the results from before and after resuming to be joined, got %d requests", total.Requests)
+	}
+	if total.Rate < 50 || total.Rate > 150 {
+		t.Errorf("expected the joined rate to be about 100/s, got %v", total.Rate)
+	}
+	if cp, err := LoadCheckpoint(path); err != nil || !cp.Targets[client.Address()][0].Done {
+		t.Fatalf("expected the resumed phase to be saved as done, got %v", err)
+	}
+
+	if err := cf.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if cp, err := LoadCheckpoint(path); cp != nil || err != nil {
+		t.Fatalf("expected no checkpoint once removed, got %+v, %v", cp, err)
+	}
+}
+
+func TestTargetMulti_mounts(t *testing.T) {
+	tm := TargetMulti{targets: []BenchmarkTarget{
+		{Name: "login", PathPrefix: "/v1/auth/userpass/login"},
+		{Name: "read", PathPrefix: "/v1/kv"},
+		{Name: "write", PathPrefix: "/v1/kv"},
+		{Name: "health", PathPrefix: "/v1/sys/health"},
+		{Name: "sync", PathPrefix: "sys/sync"},
+	}}
+	if got, want := tm.mounts(), []string{"auth/userpass", "kv"}; !slices.Equal(got, want) {
+		t.Fatalf("expected mounts %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// ClusterComparison compares the results of a test between the clusters
+// of a comparison run. Stats holds the results against each cluster, in
+// the order of the clusters, and is nil for those the test didn't run
+// against.
+type ClusterComparison struct {
+	Phase string
+	Test  string
+	Stats []*DiffStats
+}
+
+// CompareClusters compares the results of every test between the reports
+// of several clusters, ordered by phase as they were run and then by test,
+// with the total of each phase first. As with diffs, several reports of a
+// cluster for the same phase are combined.
+func CompareClusters(clusterRpts [][]*Reporter) []*ClusterComparison {
+	stats := make([]map[diffKey]*DiffStats, len(clusterRpts))
+	keySets := make([][]diffKey, len(clusterRpts))
+	for i, rpts := range clusterRpts {
+		stats[i], keySets[i] = diffStats(rpts)
+	}
+
+	keys := sortDiffKeys(keySets...)
+	comparisons := make([]*ClusterComparison, 0, len(keys))
+	for _, key := range keys {
+		c := &ClusterComparison{Phase: key.phase, Test: key.test, Stats: make([]*DiffStats, len(clusterRpts))}
+		for i := range clusterRpts {
+			c.Stats[i] = stats[i][key]
+		}
+		comparisons = append(comparisons, c)
+	}
+	return comparisons
+}
+
+// WriteComparison writes a table of the throughput, 50th and 99th
+// percentile latency and error rate of every test against each of the
+// named clusters side by side, along with the cluster which did best when
+// they differ
+func WriteComparison(w io.Writer, names []string, comparisons []*ClusterComparison) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "op\tmetric\t")
+	for _, name := range names {
+		fmt.Fprintf(tw, "%s\t", name)
+	}
+	fmt.Fprintf(tw, "best\t\n")
+	for _, c := range comparisons {
+		label := c.Test
+		if c.Phase != "" {
+			label = c.Test + " (" + c.Phase + ")"
+		}
+		for _, metric := range diffMetrics {
+			fmt.Fprintf(tw, "%s\t%s\t", label, metric.name)
+			best, ran, tied := -1, 0, true
+			for i, s := range c.Stats {
+				if s == nil {
+					fmt.Fprintf(tw, "-\t")
+					continue
+				}
+				fmt.Fprintf(tw, "%s\t", metric.format(metric.value(s)))
+				ran++
+				if best == -1 {
+					best = i
+					continue
+				}
+				value, bestValue := metric.value(s), metric.value(c.Stats[best])
+				if value != bestValue {
+					tied = false
+				}
+				if (value > bestValue) == metric.higherIsBetter && value != bestValue {
+					best = i
+				}
+			}
+			if ran > 1 && !tied {
+				fmt.Fprintf(tw, "%s\t\n", names[best])
+			} else {
+				fmt.Fprintf(tw, "\t\n")
+			}
+		}
+	}
+	return tw.Flush()
+}
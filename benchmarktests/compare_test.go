@@ -0,0 +1,55 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompareClusters(t *testing.T) {
+	raft := []*Reporter{diffReporter("", 10*time.Millisecond, 0)}
+	postgres := []*Reporter{diffReporter("", 30*time.Millisecond, 5)}
+	// A cluster which only ran the warm phase
+	consul := []*Reporter{diffReporter("warm", 20*time.Millisecond, 0)}
+
+	comparisons := CompareClusters([][]*Reporter{raft, postgres, consul})
+	if len(comparisons) != 4 || comparisons[1].Test != "read" || comparisons[3].Phase != "warm" {
+		t.Fatalf("expected the main phase then warm, got: %+v", comparisons)
+	}
+	read := comparisons[1]
+	if read.Stats[0].P99 != 10*time.Millisecond || read.Stats[1].ErrorRate != 0.05 || read.Stats[2] != nil {
+		t.Fatalf("unexpected stats: %+v", read.Stats)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteComparison(&buf, []string{"raft", "postgres", "consul"}, comparisons); err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string]string)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 2 && fields[0] == "read" && fields[1] == "(warm)" {
+			fields = fields[1:]
+		}
+		if len(fields) > 2 {
+			rows[fields[0]+" "+fields[1]] = strings.Join(fields[2:], " ")
+		}
+	}
+	if got := rows["op metric"]; got != "raft postgres consul best" {
+		t.Fatalf("unexpected header: %v", got)
+	}
+	if got := rows["read p99"]; got != "10ms 30ms - raft" {
+		t.Fatalf("unexpected p99 row: %v", got)
+	}
+	if got := rows["read error"]; got != "rate 0.00% 5.00% - raft" {
+		t.Fatalf("unexpected error rate row: %v", got)
+	}
+	// Only one cluster ran the warm phase, so none is best
+	if got := rows["(warm) p99"]; got != "- - 20ms" {
+		t.Fatalf("unexpected warm p99 row: %v", got)
+	}
+}
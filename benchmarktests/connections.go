@@ -0,0 +1,140 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// connReusedHeader and tlsHandshakeHeader are set on responses to
+	// whether the request was sent on a connection which was reused, and to
+	// the nanoseconds the TLS handshake of a new connection took, so the
+	// connections of each test can be recorded
+	connReusedHeader   = "X-Benchmark-Conn-Reused"
+	tlsHandshakeHeader = "X-Benchmark-TLS-Handshake"
+)
+
+// ConnectionStats counts the requests of a test which were sent on a new
+// connection and on one reused from an earlier request, along with the
+// latency of the TLS handshakes of the new connections. Results dominated
+// by handshakes show up as many new connections with slow handshakes.
+type ConnectionStats struct {
+	New          uint64     `json:"new"`
+	Reused       uint64     `json:"reused"`
+	TLSHandshake *Histogram `json:"tls_handshake"`
+}
+
+// connTransport traces the connection each request is sent on
+type connTransport struct {
+	base http.RoundTripper
+}
+
+func (t *connTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var mu sync.Mutex
+	var gotConn, reused bool
+	var handshakeStart time.Time
+	var handshake time.Duration
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			gotConn, reused = true, info.Reused
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			handshakeStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !handshakeStart.IsZero() {
+				handshake = time.Since(handshakeStart)
+			}
+		},
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		return resp, err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if gotConn {
+		resp.Header.Set(connReusedHeader, strconv.FormatBool(reused))
+		// Connections dialed for the request may go to another one which
+		// got a connection first
+		if !reused && handshake > 0 {
+			resp.Header.Set(tlsHandshakeHeader, strconv.FormatInt(int64(handshake), 10))
+		}
+	}
+	return resp, nil
+}
+
+// recordConnection records the connection the request of a result of the
+// named test was sent on, when it is known
+func (r *Reporter) recordConnection(name string, result *vegeta.Result) {
+	reused, err := strconv.ParseBool(result.Headers.Get(connReusedHeader))
+	if err != nil {
+		return
+	}
+	if r.connections == nil {
+		r.connections = make(map[string]*ConnectionStats)
+	}
+	stats, ok := r.connections[name]
+	if !ok {
+		stats = &ConnectionStats{TLSHandshake: NewHistogram()}
+		r.connections[name] = stats
+	}
+	if reused {
+		stats.Reused++
+		return
+	}
+	stats.New++
+	if handshake, err := strconv.ParseInt(result.Headers.Get(tlsHandshakeHeader), 10, 64); err == nil {
+		stats.TLSHandshake.Record(time.Duration(handshake))
+	}
+}
+
+// reportConnectionsVerbose writes the number of requests of the named test
+// sent on new and reused connections, and the latency of the handshakes
+func (r *Reporter) reportConnectionsVerbose(w io.Writer, name string) {
+	stats, ok := r.connections[name]
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "Connections   [new, reused, handshakes, mean, 99]   %d, %d, %d, %s, %s\n",
+		stats.New, stats.Reused, stats.TLSHandshake.Count(), stats.TLSHandshake.Mean(), stats.TLSHandshake.Quantile(0.99))
+}
+
+// reportConnectionsTerse writes a table of the requests of every test sent
+// on new and reused connections and the latency of the handshakes, when
+// some test had TLS handshakes after its warmup
+func (r *Reporter) reportConnectionsTerse(w io.Writer, names []string) {
+	var handshakes bool
+	for name, stats := range r.connections {
+		handshakes = handshakes || (name != "total" && stats.TLSHandshake.Count() > 0)
+	}
+	if !handshakes {
+		return
+	}
+	fmt.Fprintf(w, "\nop\tnewConns\treusedConns\thandshakes\thandshakeMean\thandshake99th\n")
+	for _, name := range names {
+		stats, ok := r.connections[name]
+		if name == "total" || !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\t%s\n", name, stats.New, stats.Reused, stats.TLSHandshake.Count(),
+			stats.TLSHandshake.Mean(), stats.TLSHandshake.Quantile(0.99))
+	}
+}
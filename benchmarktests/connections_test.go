@@ -0,0 +1,78 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestConnectionStats(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	cfg.HttpClient.Transport.(*http.Transport).TLSClientConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, client)
+	httpClient := attackClient(client, &AttackConfig{})
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest("GET", srv.URL+"/v1/secret/data/foo", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		rpt.Add(&vegeta.Result{Method: "GET", URL: req.URL.String(), Code: uint16(resp.StatusCode), Timestamp: time.Now(), Latency: time.Millisecond, Headers: resp.Header})
+	}
+	rpt.Close()
+
+	stats := rpt.connections["read"]
+	if stats == nil || stats.New != 1 || stats.Reused != 2 || stats.TLSHandshake.Count() != 1 || stats.TLSHandshake.Mean() <= 0 {
+		t.Fatalf("expected one new connection with a handshake reused twice, got %+v", stats)
+	}
+
+	var b bytes.Buffer
+	if err := rpt.ReportTerse(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "reusedConns") {
+		t.Errorf("expected a connections table in the terse report:\n%s", b.String())
+	}
+	merged := MergeReports([]*Reporter{rpt, rpt})
+	if got := merged[0].connections["read"]; got.New != 2 || got.Reused != 4 || got.TLSHandshake.Count() != 2 {
+		t.Fatalf("expected the connections of both reports to be merged, got %+v", got)
+	}
+
+	// Without handshakes after the warmup the table is only shown in
+	// verbose reports
+	for _, name := range []string{"total", "read"} {
+		rpt.connections[name] = &ConnectionStats{Reused: 3, TLSHandshake: NewHistogram()}
+	}
+	b.Reset()
+	if err := rpt.ReportTerse(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "reusedConns") {
+		t.Errorf("expected no connections table in the terse report:\n%s", b.String())
+	}
+}
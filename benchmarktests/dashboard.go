@@ -0,0 +1,288 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+)
+
+const (
+	// RemoteWriteDashboard charts the bench_interval_* series pushed to
+	// remote_write_url
+	RemoteWriteDashboard = "remote_write"
+
+	// OTelDashboard charts the metrics exported to otlp_metrics_endpoint,
+	// as named once an OpenTelemetry collector writes them to Prometheus
+	OTelDashboard = "otel"
+)
+
+// DefaultDashboardRunLabel returns the label holding the run ID of each
+// series for the given metrics. The OTLP exporter sets the run ID as a
+// resource attribute, which collectors copy to a label of that name.
+func DefaultDashboardRunLabel(metrics string) string {
+	if metrics == OTelDashboard {
+		return "benchmark_run_id"
+	}
+	return "run_id"
+}
+
+// DashboardConfig controls the Grafana dashboard created by
+// GrafanaDashboard
+type DashboardConfig struct {
+	Title string
+
+	// Metrics is which exported metrics the dashboard charts, either
+	// remote_write or otel
+	Metrics string
+
+	// RunLabel is the label holding the run ID of each series, defaulting
+	// to DefaultDashboardRunLabel, and RunID the run selected when the
+	// dashboard is first opened. Every run is selected when RunID is empty.
+	RunLabel string
+	RunID    string
+}
+
+// Dashboard is the subset of the Grafana dashboard JSON model which is
+// needed to import a dashboard
+type Dashboard struct {
+	Title         string              `json:"title"`
+	UID           string              `json:"uid,omitempty"`
+	Tags          []string            `json:"tags"`
+	SchemaVersion int                 `json:"schemaVersion"`
+	Editable      bool                `json:"editable"`
+	Refresh       string              `json:"refresh"`
+	Time          DashboardTime       `json:"time"`
+	Templating    DashboardTemplating `json:"templating"`
+	Panels        []DashboardPanel    `json:"panels"`
+}
+
+type DashboardTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type DashboardTemplating struct {
+	List []DashboardVariable `json:"list"`
+}
+
+type DashboardVariable struct {
+	Name       string               `json:"name"`
+	Label      string               `json:"label,omitempty"`
+	Type       string               `json:"type"`
+	Query      interface{}          `json:"query"`
+	Datasource *DashboardDatasource `json:"datasource,omitempty"`
+	Current    *DashboardCurrent    `json:"current,omitempty"`
+	Refresh    int                  `json:"refresh,omitempty"`
+	IncludeAll bool                 `json:"includeAll,omitempty"`
+	AllValue   string               `json:"allValue,omitempty"`
+	Multi      bool                 `json:"multi,omitempty"`
+	Sort       int                  `json:"sort,omitempty"`
+}
+
+type DashboardCurrent struct {
+	Text  string `json:"text"`
+	Value string `json:"value"`
+}
+
+type DashboardDatasource struct {
+	Type string `json:"type"`
+	UID  string `json:"uid"`
+}
+
+type DashboardPanel struct {
+	ID          int                   `json:"id"`
+	Title       string                `json:"title"`
+	Type        string                `json:"type"`
+	Datasource  DashboardDatasource   `json:"datasource"`
+	GridPos     DashboardGridPos      `json:"gridPos"`
+	FieldConfig DashboardFieldConfig  `json:"fieldConfig"`
+	Targets     []DashboardPanelQuery `json:"targets"`
+}
+
+type DashboardGridPos struct {
+	H int `json:"h"`
+	W int `json:"w"`
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type DashboardFieldConfig struct {
+	Defaults DashboardFieldDefaults `json:"defaults"`
+}
+
+type DashboardFieldDefaults struct {
+	Unit string `json:"unit"`
+}
+
+type DashboardPanelQuery struct {
+	RefID        string `json:"refId"`
+	Expr         string `json:"expr"`
+	LegendFormat string `json:"legendFormat"`
+}
+
+// dashboardPanel is a chart before it is laid out on the dashboard
+type dashboardPanel struct {
+	title   string
+	unit    string
+	queries []DashboardPanelQuery
+}
+
+// GrafanaDashboard returns a dashboard charting the request rate,
+// throughput, errors, latency and response codes of every test, read from
+// the metrics the benchmark exports to Prometheus compatible backends. The
+// dashboard has variables to pick the Prometheus data source, the runs and
+// the tests shown.
+func GrafanaDashboard(config *DashboardConfig) (*Dashboard, error) {
+	runLabel := config.RunLabel
+	if runLabel == "" {
+		runLabel = DefaultDashboardRunLabel(config.Metrics)
+	}
+
+	var panels []dashboardPanel
+	var runMetric string
+	selector := fmt.Sprintf(`%s=~"$run", test=~"$test"`, runLabel)
+	switch config.Metrics {
+	case RemoteWriteDashboard, "":
+		runMetric = "bench_interval_requests"
+		panels = remoteWriteDashboardPanels(selector)
+	case OTelDashboard:
+		runMetric = "bench_requests_total"
+		panels = otelDashboardPanels(selector)
+	default:
+		return nil, fmt.Errorf("unknown dashboard metrics %q, must be remote_write or otel", config.Metrics)
+	}
+
+	title := config.Title
+	if title == "" {
+		title = "OpenBao Benchmark"
+	}
+
+	datasource := DashboardDatasource{Type: "prometheus", UID: "${datasource}"}
+	runCurrent := &DashboardCurrent{Text: "All", Value: "$__all"}
+	if config.RunID != "" {
+		runCurrent = &DashboardCurrent{Text: config.RunID, Value: config.RunID}
+	}
+
+	dashboard := &Dashboard{
+		Title:         title,
+		Tags:          []string{"openbao", "benchmark"},
+		SchemaVersion: 39,
+		Editable:      true,
+		Refresh:       "30s",
+		Time:          DashboardTime{From: "now-1h", To: "now"},
+		Templating: DashboardTemplating{List: []DashboardVariable{
+			{
+				Name:  "datasource",
+				Label: "Data source",
+				Type:  "datasource",
+				Query: "prometheus",
+			},
+			{
+				Name:       "run",
+				Label:      "Run",
+				Type:       "query",
+				Datasource: &datasource,
+				Query: map[string]string{
+					"query": fmt.Sprintf("label_values(%s, %s)", runMetric, runLabel),
+					"refId": "run",
+				},
+				Current:    runCurrent,
+				Refresh:    2,
+				IncludeAll: true,
+				AllValue:   ".*",
+				Multi:      true,
+				Sort:       1,
+			},
+			{
+				Name:       "test",
+				Label:      "Test",
+				Type:       "query",
+				Datasource: &datasource,
+				Query: map[string]string{
+					"query": fmt.Sprintf(`label_values(%s{%s=~"$run"}, test)`, runMetric, runLabel),
+					"refId": "test",
+				},
+				Current:    &DashboardCurrent{Text: "All", Value: "$__all"},
+				Refresh:    2,
+				IncludeAll: true,
+				AllValue:   ".*",
+				Multi:      true,
+				Sort:       1,
+			},
+		}},
+	}
+
+	// Lay the panels out two to a row
+	for i, panel := range panels {
+		dashboard.Panels = append(dashboard.Panels, DashboardPanel{
+			ID:          i + 1,
+			Title:       panel.title,
+			Type:        "timeseries",
+			Datasource:  datasource,
+			GridPos:     DashboardGridPos{H: 8, W: 12, X: (i % 2) * 12, Y: (i / 2) * 8},
+			FieldConfig: DashboardFieldConfig{Defaults: DashboardFieldDefaults{Unit: panel.unit}},
+			Targets:     panel.queries,
+		})
+	}
+	return dashboard, nil
+}
+
+func remoteWriteDashboardPanels(selector string) []dashboardPanel {
+	query := func(metric, extra, legend string) []DashboardPanelQuery {
+		return []DashboardPanelQuery{{
+			RefID:        "A",
+			Expr:         fmt.Sprintf("%s{%s%s}", metric, selector, extra),
+			LegendFormat: legend,
+		}}
+	}
+	return []dashboardPanel{
+		{"Request rate", "reqps", query("bench_interval_rate", "", "{{test}}")},
+		{"Throughput", "reqps", query("bench_interval_throughput", "", "{{test}}")},
+		{"Success ratio", "percentunit", query("bench_interval_success_ratio", "", "{{test}}")},
+		{"Mean latency", "s", query("bench_interval_latency_mean_seconds", "", "{{test}}")},
+		{"Latency percentiles", "s", query("bench_interval_latency_seconds", `, quantile=~"0.5|0.99"`, "{{test}} q{{quantile}}")},
+		{"Responses by status code", "short", query("bench_interval_responses", "", "{{test}} {{code}}")},
+	}
+}
+
+func otelDashboardPanels(selector string) []dashboardPanel {
+	rate := func(metric string) string {
+		return fmt.Sprintf("rate(%s{%s}[$__rate_interval])", metric, selector)
+	}
+	latency := func(q string) DashboardPanelQuery {
+		return DashboardPanelQuery{
+			RefID:        "p" + q,
+			Expr:         fmt.Sprintf("histogram_quantile(%s, sum by (test, le) (%s))", q, rate("bench_request_duration_seconds_bucket")),
+			LegendFormat: "{{test}} q" + q,
+		}
+	}
+	return []dashboardPanel{
+		{"Request rate", "reqps", []DashboardPanelQuery{{
+			RefID:        "A",
+			Expr:         fmt.Sprintf("sum by (test) (%s)", rate("bench_requests_total")),
+			LegendFormat: "{{test}}",
+		}}},
+		{"Error rate", "reqps", []DashboardPanelQuery{{
+			RefID:        "A",
+			Expr:         fmt.Sprintf("sum by (test) (%s)", rate("bench_errors_total")),
+			LegendFormat: "{{test}}",
+		}}},
+		{"Success ratio", "percentunit", []DashboardPanelQuery{{
+			RefID:        "A",
+			Expr:         fmt.Sprintf("1 - (sum by (test) (%s) or sum by (test) (%s) * 0) / sum by (test) (%s)", rate("bench_errors_total"), rate("bench_requests_total"), rate("bench_requests_total")),
+			LegendFormat: "{{test}}",
+		}}},
+		{"Mean latency", "s", []DashboardPanelQuery{{
+			RefID:        "A",
+			Expr:         fmt.Sprintf("sum by (test) (%s) / sum by (test) (%s)", rate("bench_request_duration_seconds_sum"), rate("bench_request_duration_seconds_count")),
+			LegendFormat: "{{test}}",
+		}}},
+		{"Latency percentiles", "s", []DashboardPanelQuery{latency("0.5"), latency("0.99")}},
+		{"Responses by status code", "reqps", []DashboardPanelQuery{{
+			RefID:        "A",
+			Expr:         fmt.Sprintf("sum by (test, code) (%s)", rate("bench_requests_total")),
+			LegendFormat: "{{test}} {{code}}",
+		}}},
+	}
+}
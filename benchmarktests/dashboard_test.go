@@ -0,0 +1,53 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGrafanaDashboard(t *testing.T) {
+	for _, tc := range []struct {
+		metrics  string
+		runLabel string
+		want     string
+	}{
+		{RemoteWriteDashboard, "", `bench_interval_rate{run_id=~"$run", test=~"$test"}`},
+		{OTelDashboard, "", `benchmark_run_id=~"$run"`},
+		{OTelDashboard, "job_run", `rate(bench_requests_total{job_run=~"$run", test=~"$test"}[$__rate_interval])`},
+	} {
+		dashboard, err := GrafanaDashboard(&DashboardConfig{Metrics: tc.metrics, RunLabel: tc.runLabel, RunID: "run-1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dashboard.Panels) == 0 {
+			t.Fatalf("expected %v dashboard to have panels", tc.metrics)
+		}
+		if got := dashboard.Templating.List[1].Current.Value; got != "run-1" {
+			t.Fatalf("expected run-1 to be selected, got: %v", got)
+		}
+
+		encoded, err := json.Marshal(dashboard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var exprs []string
+		for _, panel := range dashboard.Panels {
+			for _, query := range panel.Targets {
+				exprs = append(exprs, query.Expr)
+			}
+		}
+		if !strings.Contains(strings.Join(exprs, "\n"), tc.want) {
+			t.Fatalf("expected %v dashboard queries to contain %q, got: %s", tc.metrics, tc.want, encoded)
+		}
+	}
+}
+
+func TestGrafanaDashboard_UnknownMetrics(t *testing.T) {
+	if _, err := GrafanaDashboard(&DashboardConfig{Metrics: "statsd"}); err == nil {
+		t.Fatal("expected an error for unknown metrics")
+	}
+}
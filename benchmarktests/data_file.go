@@ -0,0 +1,150 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// CycleDataOrder gives requests the rows of a data file in turn,
+	// starting over after the last
+	CycleDataOrder = "cycle"
+
+	// RandomDataOrder gives every request a row of a data file picked at
+	// random
+	RandomDataOrder = "random"
+)
+
+// DataFileConfig is the data_file block accepted by tests whose payload
+// templates are filled in from the rows of a CSV or JSON file, such as an
+// inventory of real usernames or key paths
+type DataFileConfig struct {
+	Path  string `hcl:"path,optional"`
+	Order string `hcl:"order,optional"`
+}
+
+// files returns the path of the data file, or nil when the test has none
+func (c *DataFileConfig) files() []string {
+	if c == nil {
+		return nil
+	}
+	return []string{c.Path}
+}
+
+// dataRows are the rows of a data file, keyed by their column
+type dataRows struct {
+	path    string
+	columns map[string]bool
+	rows    []map[string]string
+	random  bool
+}
+
+// loadDataFile reads the rows of the data file of a test, or returns nil
+// when the test has none. Files ending in .json hold an array of objects,
+// and any other file is CSV with a header row naming the columns.
+func loadDataFile(config *DataFileConfig) (*dataRows, error) {
+	if config == nil {
+		return nil, nil
+	}
+	if config.Path == "" {
+		return nil, fmt.Errorf("data_file path is required")
+	}
+	d := &dataRows{path: config.Path, columns: make(map[string]bool)}
+	switch config.Order {
+	case CycleDataOrder, "":
+	case RandomDataOrder:
+		d.random = true
+	default:
+		return nil, fmt.Errorf("data_file order must be one of %v or %v", CycleDataOrder, RandomDataOrder)
+	}
+
+	f, err := os.Open(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening data file: %v", err)
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(config.Path), ".json") {
+		var objects []map[string]interface{}
+		if err := json.NewDecoder(f).Decode(&objects); err != nil {
+			return nil, fmt.Errorf("error decoding data file %v, which must be an array of objects: %v", config.Path, err)
+		}
+		for i, object := range objects {
+			row := make(map[string]string, len(object))
+			for column, value := range object {
+				switch v := value.(type) {
+				case string:
+					row[column] = v
+				case float64, bool:
+					row[column] = fmt.Sprint(v)
+				case nil:
+					row[column] = ""
+				default:
+					return nil, fmt.Errorf("column %v of row %d of data file %v must be a string, number or bool", column, i+1, config.Path)
+				}
+				d.columns[column] = true
+			}
+			d.rows = append(d.rows, row)
+		}
+	} else {
+		records, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("error reading data file %v: %v", config.Path, err)
+		}
+		if len(records) > 0 {
+			header := records[0]
+			for _, column := range header {
+				d.columns[column] = true
+			}
+			for _, record := range records[1:] {
+				row := make(map[string]string, len(header))
+				for i, column := range header {
+					row[column] = record[i]
+				}
+				d.rows = append(d.rows, row)
+			}
+		}
+	}
+
+	if len(d.rows) == 0 {
+		return nil, fmt.Errorf("data file %v has no rows", config.Path)
+	}
+	return d, nil
+}
+
+// row returns the row given to the request of the sequence number,
+// starting at 1
+func (d *dataRows) row(seq int64) map[string]string {
+	if d.random {
+		return d.rows[rand.Intn(len(d.rows))]
+	}
+	return d.rows[(seq-1)%int64(len(d.rows))]
+}
+
+// keys returns a selector over the rows, or whatever was set up from each
+// of them, following the order of the data file
+func (d *dataRows) keys() keySelector {
+	if d.random {
+		return uniformKeys(len(d.rows))
+	}
+	return &cycleKeys{n: int64(len(d.rows))}
+}
+
+// cycleKeys picks every key in turn
+type cycleKeys struct {
+	n    int64
+	last atomic.Int64
+}
+
+func (c *cycleKeys) next() int {
+	return int((c.last.Add(1) - 1) % c.n)
+}
@@ -0,0 +1,246 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestLoadDataFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	csvPath := write("users.csv", "username,team\nalice,red\nbob,blue\n")
+	jsonPath := write("users.json", `[{"username": "alice", "uid": 1001}, {"username": "bob", "admin": true}]`)
+
+	rows, err := loadDataFile(&DataFileConfig{Path: csvPath})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var order []string
+	for seq := int64(1); seq <= 3; seq++ {
+		order = append(order, rows.row(seq)["username"])
+	}
+	if !reflect.DeepEqual(order, []string{"alice", "bob", "alice"}) {
+		t.Fatalf("expected the rows in turn, got %v", order)
+	}
+	keys := rows.keys()
+	if first, second, third := keys.next(), keys.next(), keys.next(); first != 0 || second != 1 || third != 0 {
+		t.Fatalf("expected the keys in turn, got %d, %d, %d", first, second, third)
+	}
+
+	rows, err = loadDataFile(&DataFileConfig{Path: jsonPath, Order: RandomDataOrder})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !rows.random || !rows.columns["uid"] || !rows.columns["admin"] {
+		t.Fatalf("expected random rows with the columns of every row, got %+v", rows)
+	}
+	if rows.rows[0]["uid"] != "1001" || rows.rows[1]["admin"] != "true" || rows.rows[1]["uid"] != "" {
+		t.Fatalf("unexpected rows: %v", rows.rows)
+	}
+
+	if rows, err := loadDataFile(nil); rows != nil || err != nil {
+		t.Fatalf("expected no rows without a data file, got %v, %v", rows, err)
+	}
+
+	cases := []struct {
+		config *DataFileConfig
+		err    string
+	}{
+		{&DataFileConfig{}, "data_file path is required"},
+		{&DataFileConfig{Path: csvPath, Order: "shuffle"}, "order must be one of cycle or random"},
+		{&DataFileConfig{Path: filepath.Join(dir, "missing.csv")}, "error opening data file"},
+		{&DataFileConfig{Path: write("header.csv", "username\n")}, "has no rows"},
+		{&DataFileConfig{Path: write("ragged.csv", "username,team\nalice\n")}, "wrong number of fields"},
+		{&DataFileConfig{Path: write("object.json", `{"username": "alice"}`)}, "must be an array of objects"},
+		{&DataFileConfig{Path: write("nested.json", `[{"user": {"name": "alice"}}]`)}, "column user of row 1"},
+	}
+	for _, tc := range cases {
+		if _, err := loadDataFile(tc.config); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: expected error %q, got: %v", tc.config.Path, tc.err, err)
+		}
+	}
+
+	rows, _ = loadDataFile(&DataFileConfig{Path: csvPath})
+	if _, err := parsePayloadTemplate("{{field email}}", rows); err == nil || !strings.Contains(err.Error(), "has no column email") {
+		t.Fatalf("expected an unknown column to be rejected, got: %v", err)
+	}
+}
+
+func TestDataFile_Tests(t *testing.T) {
+	var lock sync.Mutex
+	var writes []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPut || req.Method == http.MethodPost {
+			lock.Lock()
+			writes = append(writes, req.URL.Path)
+			lock.Unlock()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetLogger = hclog.NewNullLogger()
+
+	dataFile := filepath.Join(t.TempDir(), "inventory.csv")
+	if err := os.WriteFile(dataFile, []byte("app,host\nbilling,billing.example.com\nsearch,search.example.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	parse := func(testType, config string) (BenchmarkBuilder, error) {
+		t.Helper()
+		file, diags := hclparse.NewParser().ParseHCL([]byte(fmt.Sprintf(config, dataFile)), "test.hcl")
+		if diags.HasErrors() {
+			t.Fatalf("err: %v", diags)
+		}
+		builder := TestList[testType]()
+		return builder, builder.ParseConfig(file.Body)
+	}
+
+	// The key of every row is seeded, and requests read them in turn
+	builder, err := parse(KVV1ReadTestType, `
+config {
+  key = "apps/{{field app}}"
+  data_file {
+    path = %q
+  }
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	test, err := builder.Setup(client, "kv", &TopLevelTargetConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if !reflect.DeepEqual(writes[1:], []string{"/v1/kv/apps/billing", "/v1/kv/apps/search"}) {
+		t.Fatalf("expected the key of every row to be seeded, got %v", writes)
+	}
+	for _, expected := range []string{"billing", "search", "billing"} {
+		if tgt := test.Target(client); tgt.URL != srv.URL+"/v1/kv/apps/"+expected {
+			t.Fatalf("expected a read of %v, got %v", expected, tgt.URL)
+		}
+	}
+
+	// Tests sharing the mount read the keys seeded from the template
+	sharer, err := parse(KVV1ReadTestType, `
+config {
+  numkvs = 1000
+  data_file {
+    path = %q
+  }
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	shared, err := sharer.(MountSharer).SetupShared(client, test, &TopLevelTargetConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if tgt := shared.Target(client); tgt.URL != srv.URL+"/v1/kv/apps/billing" && tgt.URL != srv.URL+"/v1/kv/apps/search" {
+			t.Fatalf("expected a read of a seeded key, got %v", tgt.URL)
+		}
+	}
+
+	// Certificates are issued for the row of every request, keeping the
+	// other options of the request
+	builder, err = parse(PKIIssueTestType, `
+config {
+  issue {
+    common_name = "{{field host}}"
+    ttl         = "1h"
+  }
+  data_file {
+    path = %q
+  }
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	pki := builder.(*PKIIssueTest)
+	issueData, err := structToMap(pki.config.IssueConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads, err := newBodyPayloads(issueData, pkiPayloadFields, pki.rows)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, expected := range []string{"billing.example.com", "search.example.com"} {
+		var body map[string]interface{}
+		if err := json.Unmarshal(payloads.next().body, &body); err != nil {
+			t.Fatal(err)
+		}
+		if body["common_name"] != expected || body["ttl"] != "1h" {
+			t.Fatalf("expected a certificate for %v, got %v", expected, body)
+		}
+	}
+	if payloads, err := newBodyPayloads(map[string]interface{}{"common_name": "example.com"}, pkiPayloadFields, nil); payloads != nil || err != nil {
+		t.Fatalf("expected no payloads for a fixed body, got %v, %v", payloads, err)
+	}
+
+	cases := []struct {
+		testType string
+		config   string
+		err      string
+	}{
+		{KVV2ReadTestType, `config {
+  key  = "apps/{{field app}}"
+  data = { source = %q }
+}`, "key requires a data_file"},
+		{UserpassTestType, `config {
+  data_file {
+    path = %q
+  }
+}`, "username must use a payload template"},
+		{UserpassTestType, `config {
+  username  = "{{field app}}"
+  num_users = 5
+  data_file {
+    path = %q
+  }
+}`, "num_users cannot be set with a data_file"},
+		{PKISignTestType, `config {
+  sign {
+    common_name = "{{field team}}"
+  }
+  data_file {
+    path = %q
+  }
+}`, "invalid sign: data file"},
+	}
+	for _, tc := range cases {
+		if _, err := parse(tc.testType, tc.config); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: expected error %q, got: %v", tc.testType, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultDiffThreshold is the percentage change beyond which a difference
+// between two runs is considered significant
+const DefaultDiffThreshold = 5.0
+
+// DiffStats are the results of a test which are compared between runs. When
+// a results file holds several reports for the same phase, such as one per
+// node of a cluster, their results are combined.
+type DiffStats struct {
+	Requests   uint64
+	Throughput float64
+	ErrorRate  float64
+	P50        time.Duration
+	P99        time.Duration
+}
+
+// TestDiff compares the results of a test in a base run with those of a
+// new run. Base or New is nil when the test only ran in one of them.
+type TestDiff struct {
+	Phase string
+	Test  string
+	Base  *DiffStats
+	New   *DiffStats
+}
+
+type diffKey struct {
+	phase string
+	test  string
+}
+
+// diffAccumulator combines the results of a test over several reports
+type diffAccumulator struct {
+	requests   uint64
+	failures   uint64
+	throughput float64
+	histogram  *Histogram
+	p50, p99   time.Duration
+}
+
+func (a *diffAccumulator) stats() *DiffStats {
+	s := &DiffStats{Requests: a.requests, Throughput: a.throughput, P50: a.p50, P99: a.p99}
+	if a.requests > 0 {
+		s.ErrorRate = float64(a.failures) / float64(a.requests)
+	}
+	if a.histogram != nil {
+		s.P50 = a.histogram.Quantile(0.5)
+		s.P99 = a.histogram.Quantile(0.99)
+	}
+	return s
+}
+
+// diffStats combines the main results of every test in rpts by phase and
+// test. Latency percentiles are read from the merged histograms; reports
+// written before histograms were kept fall back to the slowest percentile
+// of any report.
+func diffStats(rpts []*Reporter) (map[diffKey]*DiffStats, []diffKey) {
+	accs := make(map[diffKey]*diffAccumulator)
+	var keys []diffKey
+	for _, rpt := range rpts {
+		for name, m := range rpt.metrics {
+			key := diffKey{phase: rpt.phase, test: name}
+			acc, ok := accs[key]
+			if !ok {
+				acc = &diffAccumulator{histogram: NewHistogram()}
+				accs[key] = acc
+				keys = append(keys, key)
+			}
+			acc.requests += m.Requests
+			acc.failures += uint64(math.Round(float64(m.Requests) * (1 - m.Success)))
+			acc.throughput += m.Throughput
+			acc.p50 = max(acc.p50, m.Latencies.P50)
+			acc.p99 = max(acc.p99, m.Latencies.P99)
+			if h, ok := rpt.histograms[name]; ok && acc.histogram != nil {
+				acc.histogram.Merge(h)
+			} else {
+				acc.histogram = nil
+			}
+		}
+	}
+
+	stats := make(map[diffKey]*DiffStats, len(accs))
+	for key, acc := range accs {
+		stats[key] = acc.stats()
+	}
+	return stats, keys
+}
+
+// DiffReports compares the results of every test of a base run with those
+// of a new run, ordered by phase as they were run and then by test, with
+// the total of each phase first
+func DiffReports(baseRpts, newRpts []*Reporter) []*TestDiff {
+	baseStats, baseKeys := diffStats(baseRpts)
+	newStats, newKeys := diffStats(newRpts)
+
+	keys := sortDiffKeys(baseKeys, newKeys)
+	diffs := make([]*TestDiff, 0, len(keys))
+	for _, key := range keys {
+		diffs = append(diffs, &TestDiff{
+			Phase: key.phase,
+			Test:  key.test,
+			Base:  baseStats[key],
+			New:   newStats[key],
+		})
+	}
+	return diffs
+}
+
+// sortDiffKeys returns the distinct keys of several runs ordered by phase
+// as they were run and then by test, with the total of each phase first
+func sortDiffKeys(keySets ...[]diffKey) []diffKey {
+	phaseOrder := make(map[string]int)
+	seen := make(map[diffKey]bool)
+	var keys []diffKey
+	for _, key := range slices.Concat(keySets...) {
+		if _, ok := phaseOrder[key.phase]; !ok {
+			phaseOrder[key.phase] = len(phaseOrder)
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].phase != keys[j].phase {
+			return phaseOrder[keys[i].phase] < phaseOrder[keys[j].phase]
+		}
+		if keys[i].test == "total" || keys[j].test == "total" {
+			return keys[i].test == "total" && keys[j].test != "total"
+		}
+		return keys[i].test < keys[j].test
+	})
+	return keys
+}
+
+// diffMetric is a result compared between runs. higherIsBetter tells which
+// direction of change is an improvement, and latency whether the values
+// are durations.
+type diffMetric struct {
+	name           string
+	higherIsBetter bool
+	latency        bool
+	value          func(s *DiffStats) float64
+	format         func(v float64) string
+}
+
+var diffMetrics = []diffMetric{
+	{
+		name:           "throughput",
+		higherIsBetter: true,
+		value:          func(s *DiffStats) float64 { return s.Throughput },
+		format:         func(v float64) string { return fmt.Sprintf("%.2f/s", v) },
+	},
+	{
+		name:    "p50",
+		latency: true,
+		value:   func(s *DiffStats) float64 { return float64(s.P50) },
+		format:  func(v float64) string { return time.Duration(v).String() },
+	},
+	{
+		name:    "p99",
+		latency: true,
+		value:   func(s *DiffStats) float64 { return float64(s.P99) },
+		format:  func(v float64) string { return time.Duration(v).String() },
+	},
+	{
+		name:   "error rate",
+		value:  func(s *DiffStats) float64 { return s.ErrorRate },
+		format: func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) },
+	},
+}
+
+// WriteDiff writes a table of the change of the throughput, 50th and 99th
+// percentile latency and error rate of every test. Changes of more than
+// threshold percent are marked as better or worse.
+func WriteDiff(w io.Writer, diffs []*TestDiff, threshold float64) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "op\tmetric\tbase\tnew\tchange\t\n")
+	for _, d := range diffs {
+		label := d.Test
+		if d.Phase != "" {
+			label = d.Test + " (" + d.Phase + ")"
+		}
+		for _, metric := range diffMetrics {
+			baseValue, newValue := "-", "-"
+			if d.Base != nil {
+				baseValue = metric.format(metric.value(d.Base))
+			}
+			if d.New != nil {
+				newValue = metric.format(metric.value(d.New))
+			}
+
+			var change, verdict string
+			switch {
+			case d.Base == nil:
+				change = "added"
+			case d.New == nil:
+				change = "removed"
+			default:
+				change, verdict = diffChange(metric, metric.value(d.Base), metric.value(d.New), threshold)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", label, metric.name, baseValue, newValue, change, verdict)
+		}
+	}
+	return tw.Flush()
+}
+
+// diffChange returns the percentage change between the values of the base
+// and new runs, and whether it is a significant improvement or regression
+func diffChange(metric diffMetric, baseValue, newValue, threshold float64) (string, string) {
+	if baseValue == newValue {
+		return "0.00%", ""
+	}
+
+	change := math.Inf(1)
+	if baseValue != 0 {
+		change = (newValue - baseValue) / math.Abs(baseValue) * 100
+	}
+	formatted := fmt.Sprintf("%+.2f%%", change)
+	if math.IsInf(change, 1) {
+		formatted = "+inf%"
+	}
+
+	if math.Abs(change) <= threshold {
+		return formatted, ""
+	}
+	if (change > 0) == metric.higherIsBetter {
+		return formatted, "better"
+	}
+	return formatted, "worse"
+}
@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func diffReporter(phase string, latency time.Duration, failures int) *Reporter {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.phase = phase
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 100; i++ {
+		result := &vegeta.Result{
+			Method:    "GET",
+			URL:       "N/A/v1/secret/foo",
+			Code:      200,
+			Timestamp: began.Add(time.Duration(i) * 10 * time.Millisecond),
+			Latency:   latency,
+		}
+		if i < failures {
+			result.Code = 500
+			result.Error = "500 Internal Server Error"
+		}
+		rpt.Add(result)
+	}
+	rpt.Close()
+	return rpt
+}
+
+func TestDiffReports(t *testing.T) {
+	base := []*Reporter{diffReporter("", 10*time.Millisecond, 0)}
+	// Two nodes of the new run, which are combined
+	next := []*Reporter{diffReporter("", 20*time.Millisecond, 2), diffReporter("", 20*time.Millisecond, 2)}
+
+	diffs := DiffReports(base, next)
+	if len(diffs) != 2 || diffs[0].Test != "total" || diffs[1].Test != "read" {
+		t.Fatalf("expected total then read, got: %+v", diffs)
+	}
+	read := diffs[1]
+	if read.Base.Requests != 100 || read.New.Requests != 200 {
+		t.Fatalf("unexpected request counts: %d, %d", read.Base.Requests, read.New.Requests)
+	}
+	if read.New.ErrorRate != 0.02 {
+		t.Fatalf("expected an error rate of 2%%, got: %v", read.New.ErrorRate)
+	}
+	if read.Base.P99 != 10*time.Millisecond || read.New.P99 != 20*time.Millisecond {
+		t.Fatalf("unexpected p99 latencies: %v, %v", read.Base.P99, read.New.P99)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, diffs, DefaultDiffThreshold); err != nil {
+		t.Fatal(err)
+	}
+	rows := make(map[string]string)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 2 && fields[0] == "read" {
+			rows[fields[1]] = strings.Join(fields[2:], " ")
+		}
+	}
+	if got := rows["throughput"]; !strings.HasSuffix(got, "better") {
+		t.Fatalf("expected throughput to nearly double, got: %v", got)
+	}
+	if got := rows["p99"]; got != "10ms 20ms +100.00% worse" {
+		t.Fatalf("unexpected p99 row: %v", got)
+	}
+	if got := rows["error"]; got != "rate 0.00% 2.00% +inf% worse" {
+		t.Fatalf("unexpected error rate row: %v", got)
+	}
+}
+
+func TestDiffReports_Phases(t *testing.T) {
+	base := []*Reporter{diffReporter("ramp", time.Millisecond, 0), diffReporter("steady", time.Millisecond, 0)}
+	next := []*Reporter{diffReporter("steady", time.Millisecond, 0), diffReporter("soak", time.Millisecond, 0)}
+
+	var labels []string
+	for _, d := range DiffReports(base, next) {
+		if d.Test == "read" {
+			labels = append(labels, d.Phase)
+			if d.Phase == "ramp" && d.New != nil || d.Phase == "soak" && d.Base != nil {
+				t.Fatalf("expected phases missing from a run to have no results, got: %+v", d)
+			}
+		}
+	}
+	if strings.Join(labels, ",") != "ramp,steady,soak" {
+		t.Fatalf("unexpected phase order: %v", labels)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDiff(&buf, DiffReports(base, next), DefaultDiffThreshold); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "removed") || !strings.Contains(buf.String(), "added") {
+		t.Fatalf("expected added and removed phases, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// workerServiceName is the gRPC service served by workers of a distributed
+// run. Messages are encoded as JSON rather than protobuf, as results are
+// already JSON reports.
+const workerServiceName = "vault_benchmark.Worker"
+
+// MaxWorkerResultsSize bounds the size of the results a worker may return.
+// Results hold the full latency histograms of every test, so can be larger
+// than the default gRPC message limit.
+const MaxWorkerResultsSize = 256 << 20
+
+// MaxWorkerRequestSize bounds the size of the requests a worker accepts,
+// which hold the configuration and every file it reads, such as data files
+const MaxWorkerRequestSize = 256 << 20
+
+// workerTokenKey is the metadata calls to workers send their token in, as a
+// bearer token
+const workerTokenKey = "authorization"
+
+// WorkerRunRequest asks a worker to run its share of a benchmark
+type WorkerRunRequest struct {
+	// Files are the contents of the configuration file of the run and of
+	// the files it reads, keyed by their slash separated paths within the
+	// bundle. Dir is the directory of the bundle the run is started in, so
+	// relative paths resolve as they do on the coordinator, and Config and
+	// VarFiles are the slash separated paths of the configuration file and
+	// its var files relative to it.
+	Files    map[string][]byte `json:"files"`
+	Dir      string            `json:"dir"`
+	Config   string            `json:"config"`
+	VarFiles []string          `json:"var_files,omitempty"`
+
+	// Vars are the values of the variables of the configuration given to
+	// the coordinator
+	Vars map[string]string `json:"vars,omitempty"`
+
+	// Part is the share of the load of the run taken by the worker, from 1
+	// to Parts
+	Part  int `json:"part"`
+	Parts int `json:"parts"`
+
+	// StartAt is when every worker starts attacking
+	StartAt time.Time `json:"start_at"`
+}
+
+// WorkerRunResponse holds the results of a worker's share of a benchmark
+type WorkerRunResponse struct {
+	// Results are the reports of the run in the json report mode
+	Results []byte `json:"results"`
+
+	// ExitCode is the exit code of the run, which is non-zero when an SLO
+	// was missed or the error budget exceeded as well as on errors
+	ExitCode int `json:"exit_code"`
+}
+
+// WorkerServer runs benchmarks on behalf of a coordinator
+type WorkerServer interface {
+	Run(context.Context, *WorkerRunRequest) (*WorkerRunResponse, error)
+}
+
+// RegisterWorkerServer serves the worker service with srv
+func RegisterWorkerServer(s *grpc.Server, srv WorkerServer) {
+	s.RegisterService(&workerServiceDesc, srv)
+}
+
+// WorkerTokenInterceptor rejects calls to a worker which don't send its
+// token
+func WorkerTokenInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var got string
+		ok := false
+		if values := metadata.ValueFromIncomingContext(ctx, workerTokenKey); len(values) == 1 {
+			got, ok = strings.CutPrefix(values[0], "Bearer ")
+		}
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "missing or invalid token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// CallWorker asks the worker connected to by conn to run its share of a
+// benchmark, waiting for the run to finish. The token, when set, is sent
+// for the worker to authenticate the call with.
+func CallWorker(ctx context.Context, conn *grpc.ClientConn, token string, req *WorkerRunRequest) (*WorkerRunResponse, error) {
+	if token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, workerTokenKey, "Bearer "+token)
+	}
+	resp := &WorkerRunResponse{}
+	err := conn.Invoke(ctx, "/"+workerServiceName+"/Run", req, resp,
+		grpc.CallContentSubtype(jsonCodec{}.Name()), grpc.MaxCallRecvMsgSize(MaxWorkerResultsSize))
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+var workerServiceDesc = grpc.ServiceDesc{
+	ServiceName: workerServiceName,
+	HandlerType: (*WorkerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Run", Handler: workerRunHandler},
+	},
+	Metadata: "distributed.go",
+}
+
+func workerRunHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &WorkerRunRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WorkerServer).Run(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + workerServiceName + "/Run"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WorkerServer).Run(ctx, req.(*WorkerRunRequest))
+	}
+	return interceptor(ctx, req, info, handler)
+}
+
+// jsonCodec encodes the messages of the worker service as JSON. Servers
+// pick it from the content subtype of calls.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
@@ -0,0 +1,72 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+type testWorker struct {
+	got *WorkerRunRequest
+}
+
+func (w *testWorker) Run(_ context.Context, req *WorkerRunRequest) (*WorkerRunResponse, error) {
+	w.got = req
+	return &WorkerRunResponse{Results: []byte(`{"target_addr":"http://127.0.0.1:8200","metrics":{}}`), ExitCode: 1}, nil
+}
+
+func TestCallWorker(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(grpc.UnaryInterceptor(WorkerTokenInterceptor("secret")))
+	worker := &testWorker{}
+	RegisterWorkerServer(s, worker)
+	go s.Serve(l)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Calls without the token of the worker are rejected before it runs
+	// anything
+	for _, token := range []string{"", "wrong"} {
+		_, err := CallWorker(context.Background(), conn, token, &WorkerRunRequest{Config: "config.hcl"})
+		if status.Code(err) != codes.Unauthenticated || worker.got != nil {
+			t.Fatalf("expected call with token %q to be rejected, got: %v", token, err)
+		}
+	}
+
+	startAt := time.Now().Add(time.Minute).UTC()
+	resp, err := CallWorker(context.Background(), conn, "secret", &WorkerRunRequest{
+		Files:   map[string][]byte{"config.hcl": []byte(`rps = 10`)},
+		Dir:     ".",
+		Config:  "config.hcl",
+		Part:    2,
+		Parts:   3,
+		StartAt: startAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if worker.got.Part != 2 || worker.got.Parts != 3 || string(worker.got.Files["config.hcl"]) != "rps = 10" || !worker.got.StartAt.Equal(startAt) {
+		t.Fatalf("unexpected request: %+v", worker.got)
+	}
+	if resp.ExitCode != 1 || !strings.Contains(string(resp.Results), "target_addr") {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
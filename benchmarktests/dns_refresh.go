@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// dnsRefreshTransport resolves the host names requests are sent to itself,
+// spreading new connections across every address a name resolves to, and
+// resolves them again every interval, closing idle connections so they are
+// reopened to the addresses of the latest resolution. Benchmarks of DNS
+// load balanced or failover endpoints then behave like clients honouring
+// the records' TTLs instead of pinning the address resolved first.
+type dnsRefreshTransport struct {
+	base     *http.Transport
+	interval time.Duration
+	lookup   func(ctx context.Context, host string) ([]string, error)
+	dialer   *net.Dialer
+
+	mu        sync.Mutex
+	refreshed time.Time
+	resolved  map[string][]string
+	next      atomic.Uint64
+}
+
+// newDNSRefreshTransport returns a copy of base which resolves host names
+// again every interval
+func newDNSRefreshTransport(base *http.Transport, interval time.Duration) *dnsRefreshTransport {
+	t := &dnsRefreshTransport{
+		base:      base.Clone(),
+		interval:  interval,
+		lookup:    net.DefaultResolver.LookupHost,
+		dialer:    &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		refreshed: time.Now(),
+	}
+	t.base.DialContext = t.dial
+	return t
+}
+
+func (t *dnsRefreshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	expired := time.Since(t.refreshed) >= t.interval
+	if expired {
+		t.refreshed = time.Now()
+		t.resolved = nil
+	}
+	t.mu.Unlock()
+	if expired {
+		t.base.CloseIdleConnections()
+	}
+	return t.base.RoundTrip(req)
+}
+
+// dial connects to the next of the addresses the host of addr resolved to,
+// resolving it when it hasn't been since the last refresh
+func (t *dnsRefreshTransport) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) != nil {
+		return t.dialer.DialContext(ctx, network, addr)
+	}
+
+	t.mu.Lock()
+	ips, ok := t.resolved[host]
+	t.mu.Unlock()
+	if !ok {
+		if ips, err = t.lookup(ctx, host); err != nil {
+			return nil, err
+		}
+		t.mu.Lock()
+		if t.resolved == nil {
+			t.resolved = make(map[string][]string)
+		}
+		t.resolved[host] = ips
+		t.mu.Unlock()
+	}
+	if len(ips) == 0 {
+		return t.dialer.DialContext(ctx, network, addr)
+	}
+	ip := ips[(t.next.Add(1)-1)%uint64(len(ips))]
+	return t.dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+}
@@ -0,0 +1,54 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDNSRefreshTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	var lookups atomic.Int32
+	transport := newDNSRefreshTransport(http.DefaultTransport.(*http.Transport), 50*time.Millisecond)
+	transport.lookup = func(ctx context.Context, host string) ([]string, error) {
+		if host != "bao.example" {
+			t.Errorf("unexpected lookup of %v", host)
+		}
+		lookups.Add(1)
+		return []string{"127.0.0.1"}, nil
+	}
+	client := &http.Client{Transport: transport}
+	get := func() {
+		resp, err := client.Get("http://bao.example:" + port + "/v1/sys/health")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Fatalf("unexpected status %v", resp.Status)
+		}
+	}
+
+	get()
+	get()
+	if n := lookups.Load(); n != 1 {
+		t.Fatalf("expected the kept connection to be reused, got %d lookups", n)
+	}
+	time.Sleep(60 * time.Millisecond)
+	get()
+	if n := lookups.Load(); n != 2 {
+		t.Fatalf("expected the host to be resolved again after the interval, got %d lookups", n)
+	}
+}
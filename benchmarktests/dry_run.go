@@ -0,0 +1,330 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// DryRunSamples is the number of targets of each test a dry run shows
+	DryRunSamples = 3
+
+	// dryRunSetupRequests is the number of setup requests of each test a
+	// dry run shows, as tests may write thousands of secrets
+	dryRunSetupRequests = 10
+
+	// dryRunBodyLength is the length bodies shown by a dry run are cut to
+	dryRunBodyLength = 200
+
+	redacted = "<redacted>"
+)
+
+// dryRunData is the data of every response to a setup request of a dry run,
+// holding the fields tests read from the responses they set up with
+var dryRunData = map[string]interface{}{
+	"role_id":     "dry-run-role-id",
+	"secret_id":   "dry-run-secret-id",
+	"csr":         "dry-run-csr",
+	"certificate": "dry-run-certificate",
+	"issuing_ca":  "dry-run-issuing-ca",
+	"signature":   "vault:v1:dry-run-signature",
+	"ciphertext":  "vault:v1:dry-run-ciphertext",
+}
+
+// DryRunRequest is a request a test would send
+type DryRunRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// DryRunMount is a secrets engine or auth method a test would enable
+type DryRunMount struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+// DryRunTest is what a test would do during a run: the mounts and other
+// requests of its setup, and samples of the requests of the attack
+type DryRunTest struct {
+	Name          string          `json:"name"`
+	Type          string          `json:"type"`
+	Weight        int             `json:"weight,omitempty"`
+	Mounts        []DryRunMount   `json:"mounts,omitempty"`
+	Setup         []DryRunRequest `json:"setup,omitempty"`
+	SetupRequests int             `json:"setup_requests"`
+	Targets       []DryRunRequest `json:"targets"`
+}
+
+// DryRun sets the tests up against a stand-in server running in this
+// process, which records each request and answers it as a server would,
+// so what the tests would do to the target at addr is known without
+// sending it any requests. Secrets in the bodies and headers of requests
+// are redacted.
+func DryRun(addr string, tests []*BenchmarkTarget, logger *hclog.Logger, config *TopLevelTargetConfig) ([]*DryRunTest, error) {
+	recorder := &dryRunRecorder{addr: addr}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+	recorder.standIn = srv.URL
+
+	setupConfig := api.DefaultConfig()
+	setupConfig.Address = srv.URL
+	setupClient, err := api.NewClient(setupConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating dry run client: %v", err)
+	}
+	setupClient.SetToken("dry-run")
+
+	targetConfig := api.DefaultConfig()
+	targetConfig.Address = addr
+	targetClient, err := api.NewClient(targetConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating dry run client: %v", err)
+	}
+	targetClient.SetToken("dry-run")
+
+	plans := make(map[string]*DryRunTest, len(tests))
+	_, err = buildTargets(setupClient, tests, logger, config, func(bvTest *BenchmarkTarget) {
+		plan := &DryRunTest{
+			Name:   bvTest.Name,
+			Type:   bvTest.Type,
+			Weight: bvTest.Weight,
+		}
+		requests, mounts := recorder.take()
+		plan.SetupRequests = len(requests)
+		plan.Mounts = mounts
+		if len(requests) > dryRunSetupRequests {
+			requests = requests[:dryRunSetupRequests]
+		}
+		plan.Setup = requests
+		plans[bvTest.Name] = plan
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*DryRunTest, 0, len(tests))
+	for _, bvTest := range tests {
+		plan := plans[bvTest.Name]
+		for i := 0; i < DryRunSamples; i++ {
+			plan.Targets = append(plan.Targets, dryRunTarget(bvTest.Target(targetClient)))
+		}
+		result = append(result, plan)
+	}
+	return result, nil
+}
+
+// WriteDryRun writes what each test would do during a run
+func WriteDryRun(w io.Writer, plans []*DryRunTest) error {
+	var b strings.Builder
+	for i, plan := range plans {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "Test %v (%v)", plan.Name, plan.Type)
+		if plan.Weight > 0 {
+			fmt.Fprintf(&b, ", weight %d%%", plan.Weight)
+		}
+		b.WriteString("\n")
+		if len(plan.Mounts) > 0 {
+			b.WriteString("  Mounts:\n")
+			for _, mount := range plan.Mounts {
+				fmt.Fprintf(&b, "    %v %v (%v)\n", mount.Kind, mount.Path, mount.Type)
+			}
+		}
+		fmt.Fprintf(&b, "  Setup: %d request(s)\n", plan.SetupRequests)
+		for _, req := range plan.Setup {
+			writeDryRunRequest(&b, req)
+		}
+		if more := plan.SetupRequests - len(plan.Setup); more > 0 {
+			fmt.Fprintf(&b, "    ... and %d more\n", more)
+		}
+		b.WriteString("  Targets:\n")
+		for _, req := range plan.Targets {
+			writeDryRunRequest(&b, req)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeDryRunRequest(b *strings.Builder, req DryRunRequest) {
+	fmt.Fprintf(b, "    %v %v", req.Method, req.URL)
+	if req.Body != "" {
+		fmt.Fprintf(b, " %v", req.Body)
+	}
+	b.WriteString("\n")
+}
+
+// dryRunRecorder stands in for a server during a dry run, recording the
+// requests sent to it. Tests which configure the server with its own
+// address are shown doing so with the address of the target.
+type dryRunRecorder struct {
+	addr    string
+	standIn string
+
+	l        sync.Mutex
+	requests []DryRunRequest
+	mounts   []DryRunMount
+}
+
+func (d *dryRunRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	body = bytes.ReplaceAll(body, []byte(d.standIn), []byte(d.addr))
+	d.l.Lock()
+	d.requests = append(d.requests, DryRunRequest{
+		Method: r.Method,
+		URL:    r.URL.RequestURI(),
+		Body:   redactBody(body),
+	})
+	if mount, ok := dryRunMount(r, body); ok {
+		d.mounts = append(d.mounts, mount)
+	}
+	d.l.Unlock()
+
+	// Listing returns no keys, and reads of the mounts of the server
+	// return none, while everything else succeeds with the data tests
+	// read from responses
+	data := dryRunData
+	switch {
+	case r.Method == "LIST" || r.URL.Query().Get("list") == "true":
+		data = map[string]interface{}{"keys": []string{}, "key_info": map[string]interface{}{}}
+	case strings.HasPrefix(r.URL.Path, "/v1/sys/"):
+		data = map[string]interface{}{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"request_id": "dry-run",
+		"data":       data,
+	})
+}
+
+// take returns the requests and mounts recorded since it was last called
+func (d *dryRunRecorder) take() ([]DryRunRequest, []DryRunMount) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	requests, mounts := d.requests, d.mounts
+	d.requests, d.mounts = nil, nil
+	return requests, mounts
+}
+
+// dryRunMount returns the mount a setup request enables, if any
+func dryRunMount(r *http.Request, body []byte) (DryRunMount, bool) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		return DryRunMount{}, false
+	}
+	path := r.URL.Path
+	var mount DryRunMount
+	switch {
+	case strings.HasSuffix(path, "/tune"):
+		return DryRunMount{}, false
+	case strings.HasPrefix(path, "/v1/sys/mounts/"):
+		mount = DryRunMount{Kind: "secret", Path: strings.TrimPrefix(path, "/v1/sys/mounts/")}
+	case strings.HasPrefix(path, "/v1/sys/auth/"):
+		mount = DryRunMount{Kind: "auth", Path: strings.TrimPrefix(path, "/v1/sys/auth/")}
+	default:
+		return DryRunMount{}, false
+	}
+	var options struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(body, &options)
+	mount.Type = options.Type
+	return mount, true
+}
+
+// dryRunTarget describes an attack target, redacting its secrets and
+// leaving out the headers used within the benchmark
+func dryRunTarget(tgt vegeta.Target) DryRunRequest {
+	header := tgt.Header.Clone()
+	header.Del(testTLSHeader)
+	header.Del(requestPolicyHeader)
+	header.Del(scenarioHeader)
+	for name := range header {
+		if sensitiveName(name) || strings.EqualFold(name, "Authorization") {
+			header[name] = []string{redacted}
+		}
+	}
+	if len(header) == 0 {
+		header = nil
+	}
+	return DryRunRequest{
+		Method: tgt.Method,
+		URL:    tgt.URL,
+		Header: header,
+		Body:   redactBody(tgt.Body),
+	}
+}
+
+// redactBody returns a body to show, with the values of secret fields of
+// JSON bodies redacted and long bodies cut short
+func redactBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(body))
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(redactValue(v)); err != nil {
+		return fmt.Sprintf("<%d bytes>", len(body))
+	}
+	shown := strings.TrimSpace(buf.String())
+	if len(shown) > dryRunBodyLength {
+		return shown[:dryRunBodyLength] + "..."
+	}
+	return shown
+}
+
+func redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if sensitiveName(key) {
+				if _, ok := value.(string); ok {
+					v[key] = redacted
+					continue
+				}
+			}
+			v[key] = redactValue(value)
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// sensitiveName reports whether a field or header holds a secret, such as
+// a password or token, going by its name
+func sensitiveName(name string) bool {
+	name = strings.ToLower(strings.ReplaceAll(name, "-", "_"))
+	for _, s := range []string{"password", "bindpass", "secret", "secret_id", "token", "jwt", "credentials", "key"} {
+		if name == s || strings.HasSuffix(name, "_"+s) {
+			return true
+		}
+	}
+	return false
+}
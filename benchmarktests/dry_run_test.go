@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+func TestDryRun(t *testing.T) {
+	parseConfig := func(builder BenchmarkBuilder, config string) BenchmarkBuilder {
+		t.Helper()
+		body := hcl.EmptyBody()
+		if config != "" {
+			file, diags := hclparse.NewParser().ParseHCL([]byte(config), "test.hcl")
+			if diags.HasErrors() {
+				t.Fatalf("err: %v", diags)
+			}
+			body = file.Body
+		}
+		if err := builder.ParseConfig(body); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return builder
+	}
+	tests := []*BenchmarkTarget{
+		{Name: "login", Type: "approle_auth", Weight: 50, Builder: parseConfig(TestList["approle_auth"](), "")},
+		{Name: "rabbit", Type: "rabbitmq_secret", Weight: 50, Builder: parseConfig(TestList["rabbitmq_secret"](), `
+config {
+  connection {
+    connection_uri = "http://rabbit:15672"
+    username       = "admin"
+    password       = "hunter2"
+  }
+}
+`)},
+	}
+
+	logger := hclog.NewNullLogger()
+	plans, err := DryRun("https://vault.example.com:8200", tests, &logger, &TopLevelTargetConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(plans) != 2 || plans[0].Name != "login" || plans[1].Name != "rabbit" {
+		t.Fatalf("expected a plan for each test in order, got: %+v", plans)
+	}
+
+	login := plans[0]
+	if len(login.Mounts) != 1 || login.Mounts[0].Kind != "auth" || login.Mounts[0].Path != "login" || login.Mounts[0].Type != "approle" {
+		t.Fatalf("expected the approle auth mount, got: %+v", login.Mounts)
+	}
+	if login.SetupRequests != len(login.Setup) || len(login.Targets) != DryRunSamples {
+		t.Fatalf("expected every setup request and %d targets, got: %+v", DryRunSamples, login)
+	}
+	target := login.Targets[0]
+	if target.URL != "https://vault.example.com:8200/v1/auth/login/login" {
+		t.Fatalf("expected the target to be sent to the target address, got: %v", target.URL)
+	}
+	if !strings.Contains(target.Body, `"secret_id":"<redacted>"`) {
+		t.Fatalf("expected secret_id to be redacted, got: %v", target.Body)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDryRun(&buf, plans); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if strings.Contains(buf.String(), "hunter2") {
+		t.Fatalf("expected password to be redacted:\n%s", buf.String())
+	}
+	for _, want := range []string{"Test rabbit (rabbitmq_secret), weight 50%", "secret rabbit (rabbitmq)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestSensitiveName(t *testing.T) {
+	for name, sensitive := range map[string]bool{
+		"password":      true,
+		"bindpass":      true,
+		"client_secret": true,
+		"secret_id":     true,
+		"X-Vault-Token": true,
+		"private_key":   true,
+		"token_ttl":     false,
+		"role_id":       false,
+		"username":      false,
+	} {
+		if sensitiveName(name) != sensitive {
+			t.Errorf("expected sensitiveName(%q) to be %v", name, sensitive)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// DefaultErrorBudgetWindow is the sliding window errors are counted over
+	// when an error budget doesn't set its own
+	DefaultErrorBudgetWindow = 30 * time.Second
+
+	// minErrorBudgetSamples is the number of requests which must have been
+	// seen in the window before the error percentage is considered, so a
+	// single early failure can't abort the attack
+	minErrorBudgetSamples = 10
+)
+
+// ErrErrorBudgetExceeded is returned by Attack when it was aborted early
+// because too many requests failed
+var ErrErrorBudgetExceeded = errors.New("error budget exceeded")
+
+// ErrorBudgetConfig sets how many requests may fail within a sliding
+// window before the attack is aborted. Either limit may be used on its own.
+type ErrorBudgetConfig struct {
+	MaxErrors       int     `hcl:"max_errors,optional"`
+	MaxErrorPercent float64 `hcl:"max_error_percent,optional"`
+	Window          string  `hcl:"window,optional"`
+}
+
+func (c *ErrorBudgetConfig) Validate() error {
+	switch {
+	case c.MaxErrors < 0:
+		return fmt.Errorf("max_errors must not be negative")
+	case c.MaxErrorPercent < 0 || c.MaxErrorPercent > 100:
+		return fmt.Errorf("max_error_percent must be between 0 and 100")
+	case c.MaxErrors == 0 && c.MaxErrorPercent == 0:
+		return fmt.Errorf("one of max_errors or max_error_percent must be set")
+	}
+	if c.Window != "" {
+		window, err := time.ParseDuration(c.Window)
+		if err != nil {
+			return fmt.Errorf("invalid window: %v", err)
+		}
+		if window < time.Second {
+			return fmt.Errorf("window must be at least 1s")
+		}
+	}
+	return nil
+}
+
+func (c *ErrorBudgetConfig) window() time.Duration {
+	if c.Window == "" {
+		return DefaultErrorBudgetWindow
+	}
+	// The window is checked by Validate when the config is parsed
+	window, _ := time.ParseDuration(c.Window)
+	return window
+}
+
+// errorBudget counts requests and errors in one second buckets covering the
+// budget's window, so the totals only ever reflect recent results. Results
+// arrive roughly in the order they were sent; those arriving after the
+// window they were sent in has passed are dropped.
+type errorBudget struct {
+	config  *ErrorBudgetConfig
+	window  time.Duration
+	buckets []errorBucket
+
+	// latest is the second of the latest result, which ends the window
+	latest int64
+}
+
+type errorBucket struct {
+	second   int64
+	requests int
+	errors   int
+}
+
+func newErrorBudget(config *ErrorBudgetConfig) *errorBudget {
+	window := config.window()
+	return &errorBudget{
+		config:  config,
+		window:  window,
+		buckets: make([]errorBucket, int(window/time.Second)),
+	}
+}
+
+// add records the result and returns an error describing the breach if the
+// budget has been exceeded
+func (b *errorBudget) add(result *vegeta.Result) error {
+	second := result.Timestamp.Unix()
+	if second > b.latest {
+		b.latest = second
+	}
+	oldest := b.latest - int64(len(b.buckets))
+	if second <= oldest {
+		return nil
+	}
+	bucket := &b.buckets[second%int64(len(b.buckets))]
+	if bucket.second < second {
+		*bucket = errorBucket{second: second}
+	}
+	bucket.requests++
+	if result.Error != "" {
+		bucket.errors++
+	}
+
+	var requests, failed int
+	for _, bucket := range b.buckets {
+		if bucket.second > oldest {
+			requests += bucket.requests
+			failed += bucket.errors
+		}
+	}
+
+	window := b.window
+	if b.config.MaxErrors > 0 && failed > b.config.MaxErrors {
+		return fmt.Errorf("%d errors in the last %v, more than the %d allowed", failed, window, b.config.MaxErrors)
+	}
+	if b.config.MaxErrorPercent > 0 && requests >= minErrorBudgetSamples {
+		percent := float64(failed) / float64(requests) * 100
+		if percent > b.config.MaxErrorPercent {
+			return fmt.Errorf("%.2f%% of requests failed in the last %v, more than the %.2f%% allowed", percent, window, b.config.MaxErrorPercent)
+		}
+	}
+	return nil
+}
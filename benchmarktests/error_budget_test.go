@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestErrorBudget_MaxErrorsSlidingWindow(t *testing.T) {
+	budget := newErrorBudget(&ErrorBudgetConfig{MaxErrors: 2, Window: "10s"})
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	failed := func(offset time.Duration) *vegeta.Result {
+		return &vegeta.Result{Timestamp: start.Add(offset), Error: "500 Internal Server Error"}
+	}
+
+	// Errors spread further apart than the window never exceed the budget
+	for _, offset := range []time.Duration{0, 6 * time.Second, 12 * time.Second, 18 * time.Second} {
+		if err := budget.add(failed(offset)); err != nil {
+			t.Fatalf("expected no error at %v, got: %v", offset, err)
+		}
+	}
+
+	// Errors arriving after their window has passed are dropped, rather
+	// than clearing the counts of the bucket they would have been in
+	if err := budget.add(failed(8 * time.Second)); err != nil {
+		t.Fatalf("expected a late error to be dropped, got: %v", err)
+	}
+	if err := budget.add(failed(19 * time.Second)); err == nil {
+		t.Fatal("expected the budget to be exceeded by a third error within the window")
+	}
+}
+
+func TestErrorBudget_MaxErrorPercent(t *testing.T) {
+	budget := newErrorBudget(&ErrorBudgetConfig{MaxErrorPercent: 20})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// The first failure alone must not trip the percentage
+	if err := budget.add(&vegeta.Result{Timestamp: now, Error: "timeout"}); err != nil {
+		t.Fatalf("expected no error before enough samples, got: %v", err)
+	}
+	for i := 0; i < 9; i++ {
+		if err := budget.add(&vegeta.Result{Timestamp: now}); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+	}
+	if err := budget.add(&vegeta.Result{Timestamp: now, Error: "timeout"}); err != nil {
+		t.Fatalf("expected 2 of 11 requests failing to be within budget, got: %v", err)
+	}
+	if err := budget.add(&vegeta.Result{Timestamp: now, Error: "timeout"}); err == nil {
+		t.Fatal("expected 3 of 12 requests failing to exceed the budget")
+	}
+}
+
+func TestReporter_ErrorBudgetAborts(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "read", Method: "GET", PathPrefix: "/v1/secret", ErrorBudget: &ErrorBudgetConfig{MaxErrors: 1}},
+	}}
+	rpt := newReporter(tm, nil)
+
+	aborted := 0
+	rpt.trackErrorBudgets(nil, func() { aborted++ })
+	for i := 0; i < 3; i++ {
+		rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Timestamp: time.Now(), Error: "500 Internal Server Error"})
+	}
+
+	if aborted != 1 {
+		t.Fatalf("expected the attack to be aborted once, got: %d", aborted)
+	}
+	if !errors.Is(rpt.budgetErr, ErrErrorBudgetExceeded) {
+		t.Fatalf("expected error budget exceeded, got: %v", rpt.budgetErr)
+	}
+}
+
+func TestErrorBudgetConfig_Validate(t *testing.T) {
+	for _, c := range []ErrorBudgetConfig{
+		{},
+		{MaxErrors: -1},
+		{MaxErrorPercent: 101},
+		{MaxErrors: 10, Window: "500ms"},
+		{MaxErrors: 10, Window: "soon"},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Fatalf("expected error validating %+v", c)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// DefaultErrorSamples is the number of response bodies kept for each
+	// error group
+	DefaultErrorSamples = 3
+
+	// maxErrorGroups caps the number of error groups kept per test, so
+	// errors which defeat the normalization can't grow the report without
+	// bound. Once reached, further errors are counted in an overflow group.
+	maxErrorGroups = 50
+
+	// maxErrorSampleBytes truncates the sampled response bodies
+	maxErrorSampleBytes = 4096
+
+	errorGroupOverflow = "(other errors)"
+)
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	hexPattern    = regexp.MustCompile(`\b[0-9a-fA-F]{16,}\b`)
+	numberPattern = regexp.MustCompile(`[0-9]+`)
+)
+
+// ErrorGroup counts the failed requests of a test which had the same
+// response status code and normalized error message, along with samples of
+// their response bodies
+type ErrorGroup struct {
+	Code    uint16   `json:"code"`
+	Error   string   `json:"error"`
+	Count   uint64   `json:"count"`
+	Samples []string `json:"samples,omitempty"`
+}
+
+// normalizeError returns the message a failed result is grouped by. The
+// errors returned by OpenBao in the response body are preferred over the
+// status line. IDs and numbers are replaced with placeholders so that
+// otherwise identical errors about different requests share a group.
+func normalizeError(result *vegeta.Result) string {
+	msg := strings.TrimPrefix(result.Error, fmt.Sprintf("%d ", result.Code))
+
+	var body struct {
+		Errors []string `json:"errors"`
+	}
+	if err := json.Unmarshal(result.Body, &body); err == nil && len(body.Errors) > 0 {
+		msg = strings.Join(body.Errors, "; ")
+	}
+
+	msg = uuidPattern.ReplaceAllString(msg, "<uuid>")
+	msg = hexPattern.ReplaceAllString(msg, "<hex>")
+	msg = numberPattern.ReplaceAllString(msg, "<n>")
+	return strings.Join(strings.Fields(msg), " ")
+}
+
+// recordError adds a failed result of the named test to its error group
+func (r *Reporter) recordError(name string, result *vegeta.Result, msg string) {
+	groups := r.errorGroups[name]
+	var group *ErrorGroup
+	for _, g := range groups {
+		if g.Code == result.Code && g.Error == msg {
+			group = g
+			break
+		}
+	}
+	if group == nil && len(groups) >= maxErrorGroups {
+		msg = errorGroupOverflow
+		for _, g := range groups {
+			if g.Error == msg {
+				group = g
+				break
+			}
+		}
+	}
+	if group == nil {
+		group = &ErrorGroup{Code: result.Code, Error: msg}
+		r.errorGroups[name] = append(groups, group)
+	}
+
+	group.Count++
+	if len(group.Samples) < r.errorSamples && len(result.Body) > 0 {
+		sample := result.Body
+		if len(sample) > maxErrorSampleBytes {
+			sample = sample[:maxErrorSampleBytes]
+		}
+		group.Samples = append(group.Samples, string(sample))
+	}
+}
+
+// sortedErrorGroups returns the error groups of the named test, the most
+// frequent first
+func (r *Reporter) sortedErrorGroups(name string) []*ErrorGroup {
+	groups := append([]*ErrorGroup(nil), r.errorGroups[name]...)
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Count > groups[j].Count })
+	return groups
+}
+
+// reportErrorsVerbose writes the error groups of the named test
+func (r *Reporter) reportErrorsVerbose(w io.Writer, name string) {
+	groups := r.sortedErrorGroups(name)
+	if len(groups) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Error groups [count, code, error]\n")
+	for _, g := range groups {
+		fmt.Fprintf(w, "  %d, %d, %s\n", g.Count, g.Code, g.Error)
+	}
+}
+
+// reportErrorsTerse writes a table of the error groups of every test, when
+// any request failed
+func (r *Reporter) reportErrorsTerse(w io.Writer, names []string) {
+	failed := false
+	for _, name := range names {
+		if name != "total" && len(r.errorGroups[name]) > 0 {
+			failed = true
+			break
+		}
+	}
+	if !failed {
+		return
+	}
+
+	fmt.Fprintf(w, "\nop\tcode\tcount\terror\n")
+	for _, name := range names {
+		if name == "total" {
+			continue
+		}
+		for _, g := range r.sortedErrorGroups(name) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", name, g.Code, g.Count, g.Error)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestNormalizeError(t *testing.T) {
+	cases := []struct {
+		name   string
+		result vegeta.Result
+		want   string
+	}{
+		{
+			name:   "status line",
+			result: vegeta.Result{Code: 503, Error: "503 Service Unavailable"},
+			want:   "Service Unavailable",
+		},
+		{
+			name: "openbao errors",
+			result: vegeta.Result{
+				Code:  400,
+				Error: "400 Bad Request",
+				Body:  []byte(`{"errors":["1 error occurred:\n\t* lease 5f3c0a1e-7d2b-4c9e-8a6f-0123456789ab not found\n\n"]}`),
+			},
+			want: "<n> error occurred: * lease <uuid> not found",
+		},
+		{
+			name:   "connection error",
+			result: vegeta.Result{Error: `Post "http://127.0.0.1:8200/v1/secret/data/abc123": dial tcp 127.0.0.1:8200: connect: connection refused`},
+			want:   `Post "http://<n>.<n>.<n>.<n>:<n>/v<n>/secret/data/abc<n>": dial tcp <n>.<n>.<n>.<n>:<n>: connect: connection refused`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := normalizeError(&tc.result); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestReporter_ErrorGroups(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.errorSamples = 2
+	for i := 0; i < 5; i++ {
+		rpt.Add(&vegeta.Result{
+			Method: "GET", URL: "N/A/v1/secret/foo", Code: 403, Timestamp: time.Now(), Error: "403 Forbidden",
+			Body: []byte(`{"errors":["permission denied"]}`),
+		})
+	}
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 500, Timestamp: time.Now(), Error: "500 Internal Server Error"})
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 200, Timestamp: time.Now()})
+	rpt.Close()
+
+	groups := rpt.sortedErrorGroups("read")
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 error groups, got: %v", len(groups))
+	}
+	if g := groups[0]; g.Code != 403 || g.Error != "permission denied" || g.Count != 5 || len(g.Samples) != 2 {
+		t.Fatalf("unexpected error group: %+v", g)
+	}
+	if g := groups[1]; g.Code != 500 || g.Error != "Internal Server Error" || g.Count != 1 || len(g.Samples) != 0 {
+		t.Fatalf("unexpected error group: %+v", g)
+	}
+
+	var terse bytes.Buffer
+	if err := rpt.ReportTerse(&terse); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(terse.String(), "permission denied") {
+		t.Fatalf("expected the error groups in the terse report, got:\n%s", terse.String())
+	}
+
+	var encoded bytes.Buffer
+	if err := rpt.ReportJSON(&encoded); err != nil {
+		t.Fatal(err)
+	}
+	rpts, err := FromReader(&encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rpts[0].errorGroups["read"][0].Samples[0]; got != `{"errors":["permission denied"]}` {
+		t.Fatalf("expected the sampled body to round trip, got: %v", got)
+	}
+}
+
+func TestReporter_ErrorGroupsOverflow(t *testing.T) {
+	rpt := newReporter(&TargetMulti{}, nil)
+	for i := 0; i < maxErrorGroups+10; i++ {
+		rpt.Add(&vegeta.Result{Code: 400, Timestamp: time.Now(), Error: "400 Bad Request", Body: []byte(`{"errors":["bad field ` + strings.Repeat("x", i) + `"]}`)})
+	}
+
+	groups := rpt.errorGroups["total"]
+	if len(groups) != maxErrorGroups+1 {
+		t.Fatalf("expected %d error groups, got: %v", maxErrorGroups+1, len(groups))
+	}
+	if g := groups[maxErrorGroups]; g.Error != errorGroupOverflow || g.Count != 10 {
+		t.Fatalf("unexpected overflow group: %+v", g)
+	}
+}
@@ -0,0 +1,446 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// DefaultExpectSampleRate is the share of the responses of a test checked
+// against its expect rules when it doesn't set its own
+const DefaultExpectSampleRate = 0.1
+
+var validationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "bench_attack_validation_failures",
+}, []string{"attack", "rule"})
+
+func init() {
+	prometheus.MustRegister(validationFailures)
+}
+
+// ExpectConfig is the rules a sample of the responses of a test must
+// follow, so a test which is fast because it is answered with errors or
+// empty data is caught. Responses which break them are counted apart from
+// the errors of the test.
+type ExpectConfig struct {
+	StatusCodes []int               `hcl:"status_codes,optional"`
+	SampleRate  *float64            `hcl:"sample_rate,optional"`
+	JSON        []*JSONExpectConfig `hcl:"json,block"`
+}
+
+// JSONExpectConfig is a rule on the value at a path of JSON responses,
+// such as data.keys[0]. Only the checks which are set are made.
+type JSONExpectConfig struct {
+	Path      string  `hcl:"path,label"`
+	Exists    *bool   `hcl:"exists,optional"`
+	Equals    *string `hcl:"equals,optional"`
+	MinLength int     `hcl:"min_length,optional"`
+}
+
+func (c *ExpectConfig) Validate() error {
+	_, err := c.rules()
+	return err
+}
+
+// sampleRate returns the share of responses checked against the rules
+func (c *ExpectConfig) sampleRate() float64 {
+	if c.SampleRate == nil {
+		return DefaultExpectSampleRate
+	}
+	return *c.SampleRate
+}
+
+// expectRule is a single check of a response, described by its rule
+type expectRule struct {
+	description string
+	json        bool
+	check       func(code uint16, body interface{}, found bool) bool
+}
+
+// rules returns the checks of the config, failing when it has none or
+// when one is invalid
+func (c *ExpectConfig) rules() ([]expectRule, error) {
+	if rate := c.sampleRate(); rate <= 0 || rate > 1 {
+		return nil, fmt.Errorf("sample_rate must be greater than 0 and at most 1")
+	}
+
+	var rules []expectRule
+	if len(c.StatusCodes) > 0 {
+		codes := make(map[uint16]bool, len(c.StatusCodes))
+		for _, code := range c.StatusCodes {
+			if code < 100 || code > 599 {
+				return nil, fmt.Errorf("invalid status code %d", code)
+			}
+			codes[uint16(code)] = true
+		}
+		rules = append(rules, expectRule{
+			description: fmt.Sprintf("status code in %v", c.StatusCodes),
+			check: func(code uint16, _ interface{}, _ bool) bool {
+				return codes[code]
+			},
+		})
+	}
+
+	for _, j := range c.JSON {
+		path, err := parseJSONPath(j.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json path %q: %v", j.Path, err)
+		}
+		if j.MinLength < 0 {
+			return nil, fmt.Errorf("min_length of %v must not be negative", j.Path)
+		}
+		if j.Exists == nil && j.Equals == nil && j.MinLength == 0 {
+			return nil, fmt.Errorf("one of exists, equals or min_length must be set for %v", j.Path)
+		}
+		if j.Exists != nil && !*j.Exists && (j.Equals != nil || j.MinLength > 0) {
+			return nil, fmt.Errorf("exists = false cannot be set with equals or min_length for %v", j.Path)
+		}
+
+		if j.Exists != nil {
+			exists := *j.Exists
+			description := j.Path + " exists"
+			if !exists {
+				description = j.Path + " does not exist"
+			}
+			rules = append(rules, expectRule{
+				description: description,
+				json:        true,
+				check: func(_ uint16, body interface{}, found bool) bool {
+					_, ok := path.lookup(body, found)
+					return ok == exists
+				},
+			})
+		}
+		if j.Equals != nil {
+			want := *j.Equals
+			rules = append(rules, expectRule{
+				description: fmt.Sprintf("%v == %q", j.Path, want),
+				json:        true,
+				check: func(_ uint16, body interface{}, found bool) bool {
+					value, ok := path.lookup(body, found)
+					if !ok {
+						return false
+					}
+					s, ok := jsonScalar(value)
+					return ok && s == want
+				},
+			})
+		}
+		if j.MinLength > 0 {
+			minLength := j.MinLength
+			rules = append(rules, expectRule{
+				description: fmt.Sprintf("%v length >= %d", j.Path, minLength),
+				json:        true,
+				check: func(_ uint16, body interface{}, found bool) bool {
+					value, ok := path.lookup(body, found)
+					if !ok {
+						return false
+					}
+					n, ok := jsonLength(value)
+					return ok && n >= minLength
+				},
+			})
+		}
+	}
+
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("one of status_codes or json must be set")
+	}
+	return rules, nil
+}
+
+// jsonPathStep is either the key of an object or the index of an array
+type jsonPathStep struct {
+	key   string
+	index int
+}
+
+type jsonPath []jsonPathStep
+
+// parseJSONPath parses a path of keys separated by dots with array
+// indexes in brackets, such as data.keys[0], optionally starting with $
+func parseJSONPath(s string) (jsonPath, error) {
+	rest := strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+	var path jsonPath
+	for rest != "" {
+		if strings.HasPrefix(rest, "[") {
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [")
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("array index must be a number, got %q", rest[1:end])
+			}
+			path = append(path, jsonPathStep{index: index})
+			rest = strings.TrimPrefix(rest[end+1:], ".")
+			continue
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("empty key")
+		}
+		path = append(path, jsonPathStep{key: rest[:end], index: -1})
+		rest = rest[end:]
+		if strings.HasPrefix(rest, ".") {
+			rest = rest[1:]
+			if rest == "" {
+				return nil, fmt.Errorf("empty key")
+			}
+		}
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("path is empty")
+	}
+	return path, nil
+}
+
+// lookup returns the value at the path of a decoded JSON body, which isn't
+// found when the response had no body
+func (p jsonPath) lookup(body interface{}, found bool) (interface{}, bool) {
+	if !found {
+		return nil, false
+	}
+	value := body
+	for _, step := range p {
+		if step.index >= 0 {
+			array, ok := value.([]interface{})
+			if !ok || step.index >= len(array) {
+				return nil, false
+			}
+			value = array[step.index]
+			continue
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[step.key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// jsonScalar returns a string, number, bool or null as it is compared
+// with equals
+func jsonScalar(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case nil:
+		return "null", true
+	default:
+		return "", false
+	}
+}
+
+// jsonLength returns the number of characters of a string, or of elements
+// of an array or object
+func jsonLength(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case string:
+		return utf8.RuneCountInString(v), true
+	case []interface{}:
+		return len(v), true
+	case map[string]interface{}:
+		return len(v), true
+	default:
+		return 0, false
+	}
+}
+
+// expectation is the compiled rules of a test and how many of its
+// responses are checked against them
+type expectation struct {
+	rate  float64
+	rules []expectRule
+	json  bool
+}
+
+// expectations returns the rules of the targets which have them, by name
+func (tm TargetMulti) expectations() map[string]*expectation {
+	var expectations map[string]*expectation
+	for _, target := range tm.targets {
+		if target.Expect == nil {
+			continue
+		}
+		// Rules are validated when the config is loaded
+		rules, err := target.Expect.rules()
+		if err != nil {
+			continue
+		}
+		e := &expectation{rate: target.Expect.sampleRate(), rules: rules}
+		for _, rule := range rules {
+			e.json = e.json || rule.json
+		}
+		if expectations == nil {
+			expectations = make(map[string]*expectation)
+		}
+		expectations[target.Name] = e
+	}
+	return expectations
+}
+
+// failures returns the descriptions of the rules a response breaks
+func (e *expectation) failures(result *vegeta.Result) []string {
+	var body interface{}
+	found := false
+	notJSON := false
+	if e.json && len(bytes.TrimSpace(result.Body)) > 0 {
+		d := json.NewDecoder(bytes.NewReader(result.Body))
+		d.UseNumber()
+		if err := d.Decode(&body); err != nil {
+			notJSON = true
+		}
+		found = !notJSON
+	}
+
+	var failed []string
+	for _, rule := range e.rules {
+		if (rule.json && notJSON) || !rule.check(result.Code, body, found) {
+			failed = append(failed, rule.description)
+		}
+	}
+	return failed
+}
+
+// ValidationStats counts the responses of a test checked against its
+// expect rules, those which broke any of them, and how often each rule was
+// broken
+type ValidationStats struct {
+	Sampled  uint64            `json:"sampled"`
+	Failed   uint64            `json:"failed"`
+	Failures map[string]uint64 `json:"failures,omitempty"`
+}
+
+func (s *ValidationStats) merge(o *ValidationStats) {
+	s.Sampled += o.Sampled
+	s.Failed += o.Failed
+	for rule, n := range o.Failures {
+		if s.Failures == nil {
+			s.Failures = make(map[string]uint64)
+		}
+		s.Failures[rule] += n
+	}
+}
+
+// recordValidation checks a sample of the responses of the named test
+// against its expect rules. Requests which failed without a response
+// aren't checked, as they are already counted as errors.
+func (r *Reporter) recordValidation(name string, result *vegeta.Result) {
+	e, ok := r.expectations[name]
+	if !ok || result.Code == 0 || rand.Float64() >= e.rate {
+		return
+	}
+	failed := e.failures(result)
+	if r.validation == nil {
+		r.validation = make(map[string]*ValidationStats)
+	}
+	for _, key := range []string{"total", name} {
+		stats, ok := r.validation[key]
+		if !ok {
+			stats = &ValidationStats{}
+			r.validation[key] = stats
+		}
+		stats.Sampled++
+		if len(failed) == 0 {
+			continue
+		}
+		stats.Failed++
+		if stats.Failures == nil {
+			stats.Failures = make(map[string]uint64)
+		}
+		for _, rule := range failed {
+			stats.Failures[rule]++
+		}
+	}
+	for _, rule := range failed {
+		validationFailures.WithLabelValues(name, rule).Inc()
+	}
+}
+
+// sortedFailures returns the rules broken by the responses of a test, the
+// most often broken first
+func (s *ValidationStats) sortedFailures() []string {
+	rules := make([]string, 0, len(s.Failures))
+	for rule := range s.Failures {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if s.Failures[rules[i]] != s.Failures[rules[j]] {
+			return s.Failures[rules[i]] > s.Failures[rules[j]]
+		}
+		return rules[i] < rules[j]
+	})
+	return rules
+}
+
+// reportValidationVerbose writes the number of checked and invalid
+// responses of the named test, and the rules they broke
+func (r *Reporter) reportValidationVerbose(w io.Writer, name string) {
+	stats, ok := r.validation[name]
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "Validation    [sampled, failed]                 %d, %d\n", stats.Sampled, stats.Failed)
+	for _, rule := range stats.sortedFailures() {
+		fmt.Fprintf(w, "  %d  %s\n", stats.Failures[rule], rule)
+	}
+}
+
+// reportValidationTerse writes a table of the checked and invalid
+// responses of every test with expect rules, and of the rules they broke
+func (r *Reporter) reportValidationTerse(w io.Writer, names []string) {
+	if len(r.validation) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nop\tsampled\tfailed\tfailureRatio\n")
+	for _, name := range names {
+		stats, ok := r.validation[name]
+		if name == "total" || !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f%%\n", name, stats.Sampled, stats.Failed, 100*float64(stats.Failed)/float64(stats.Sampled))
+	}
+
+	failed := false
+	for _, name := range names {
+		if stats, ok := r.validation[name]; ok && name != "total" && stats.Failed > 0 {
+			failed = true
+			break
+		}
+	}
+	if !failed {
+		return
+	}
+	fmt.Fprintf(w, "\nop\tfailures\trule\n")
+	for _, name := range names {
+		stats, ok := r.validation[name]
+		if name == "total" || !ok {
+			continue
+		}
+		for _, rule := range stats.sortedFailures() {
+			fmt.Fprintf(w, "%s\t%d\t%s\n", name, stats.Failures[rule], rule)
+		}
+	}
+}
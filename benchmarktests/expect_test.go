@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestExpectConfig_Validate(t *testing.T) {
+	yes, no := true, false
+	equals := "x"
+	rate := 0.0
+	cases := []struct {
+		config *ExpectConfig
+		err    string
+	}{
+		{&ExpectConfig{}, "one of status_codes or json must be set"},
+		{&ExpectConfig{StatusCodes: []int{200}, SampleRate: &rate}, "sample_rate must be greater than 0"},
+		{&ExpectConfig{StatusCodes: []int{42}}, "invalid status code 42"},
+		{&ExpectConfig{JSON: []*JSONExpectConfig{{Path: "data.keys[x]", Exists: &yes}}}, "array index must be a number"},
+		{&ExpectConfig{JSON: []*JSONExpectConfig{{Path: "data..keys", Exists: &yes}}}, "empty key"},
+		{&ExpectConfig{JSON: []*JSONExpectConfig{{Path: "$", Exists: &yes}}}, "path is empty"},
+		{&ExpectConfig{JSON: []*JSONExpectConfig{{Path: "data"}}}, "one of exists, equals or min_length must be set"},
+		{&ExpectConfig{JSON: []*JSONExpectConfig{{Path: "data", Exists: &no, Equals: &equals}}}, "exists = false cannot be set"},
+	}
+	for _, tc := range cases {
+		if err := tc.config.Validate(); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("expected error %q, got: %v", tc.err, err)
+		}
+	}
+}
+
+func TestReporter_Validation(t *testing.T) {
+	yes, no := true, false
+	zero := "0"
+	rate := 1.0
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "reads", Method: "GET", PathPrefix: "/v1/kv", Expect: &ExpectConfig{
+			StatusCodes: []int{200},
+			SampleRate:  &rate,
+			JSON: []*JSONExpectConfig{
+				{Path: "$.data.keys[0]", Exists: &yes},
+				{Path: "data.keys", MinLength: 2},
+				{Path: "data.version", Equals: &zero},
+				{Path: "warnings", Exists: &no},
+			},
+		}},
+		{Name: "logins", Method: "POST", PathPrefix: "/v1/auth"},
+	}}
+	rpt := newReporter(tm, nil)
+	began := time.Now()
+	rpt.startWarmup(began, 0)
+
+	add := func(url string, code uint16, body string) {
+		rpt.Add(&vegeta.Result{Method: tm.targets[0].Method, URL: "N/A" + url, Code: code, Body: []byte(body), Timestamp: began, Latency: time.Millisecond})
+	}
+	add("/v1/kv/secret-1", 200, `{"data": {"keys": ["a", "b"], "version": 0}}`)
+	add("/v1/kv/secret-1", 200, `{"data": {"keys": ["a"], "version": 0}, "warnings": ["slow"]}`)
+	add("/v1/kv/secret-1", 500, `<html>`)
+	add("/v1/kv/secret-1", 204, ``)
+	// Requests which failed without a response aren't checked
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/kv/secret-1", Error: "connection refused", Timestamp: began})
+	rpt.Add(&vegeta.Result{Method: "POST", URL: "N/A/v1/auth/login", Code: 200, Timestamp: began})
+	rpt.Close()
+
+	stats := rpt.validation["reads"]
+	if stats == nil || stats.Sampled != 4 || stats.Failed != 3 {
+		t.Fatalf("expected 3 of 4 responses to fail, got %+v", stats)
+	}
+	expected := map[string]uint64{
+		"status code in [200]":    2,
+		"$.data.keys[0] exists":   2,
+		"data.keys length >= 2":   3,
+		`data.version == "0"`:     2,
+		"warnings does not exist": 2,
+	}
+	for rule, n := range expected {
+		if stats.Failures[rule] != n {
+			t.Errorf("expected %q to fail %d times, got %d", rule, n, stats.Failures[rule])
+		}
+	}
+	if _, ok := rpt.validation["logins"]; ok {
+		t.Error("expected the test without rules not to be checked")
+	}
+	if rpt.metrics["reads"].Success != 0.6 {
+		t.Errorf("expected invalid responses not to change the success ratio, got %v", rpt.metrics["reads"].Success)
+	}
+
+	// Validation is kept in JSON reports and added up when merged
+	var out bytes.Buffer
+	if err := rpt.ReportJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var report JSONReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Validation["total"].Failed != 3 {
+		t.Fatalf("expected the validation in the JSON report, got %+v", report.Validation)
+	}
+	rpts, err := FromReader(bytes.NewReader(bytes.Repeat(out.Bytes(), 2)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := mergeGroup(rpts)
+	if merged.validation["reads"].Sampled != 8 || merged.validation["reads"].Failures["data.keys length >= 2"] != 6 {
+		t.Fatalf("expected the validation to be added up, got %+v", merged.validation["reads"])
+	}
+
+	var terse bytes.Buffer
+	if err := rpt.ReportTerse(&terse); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(terse.String(), "failureRatio") || !strings.Contains(terse.String(), "data.keys length >= 2") {
+		t.Fatalf("expected the validation in the terse report, got:\n%v", terse.String())
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// ExportFormatVegeta writes targets in the JSON format of vegeta, one
+	// per line, for vegeta attack -format=json
+	ExportFormatVegeta = "vegeta"
+
+	// ExportFormatK6 writes a k6 script sending the targets
+	ExportFormatK6 = "k6"
+
+	// ExportDefaultRequests is how many targets are exported by default
+	ExportDefaultRequests = 1000
+)
+
+// ExportConfig is how the targets of tests are exported
+type ExportConfig struct {
+	Format   string
+	Requests int
+
+	// Duration, RPS, Workers and Mode are the options of the attack a k6
+	// script runs with
+	Duration time.Duration
+	RPS      int
+	Workers  int
+	Mode     string
+}
+
+// exportTarget is a target of a k6 script
+type exportTarget struct {
+	Test   string            `json:"test"`
+	Method string            `json:"method"`
+	URL    string            `json:"url"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   string            `json:"body,omitempty"`
+}
+
+// ExportTargets writes the targets the tests would be attacked with, picked
+// by their weights as an attack would, so other load generators can send
+// the requests of tests set up by this tool. Tests attacked on their own
+// and scenarios, whose requests depend on earlier responses, can't be
+// exported.
+func ExportTargets(w io.Writer, tm *TargetMulti, client *api.Client, config *ExportConfig) error {
+	for _, target := range tm.targets {
+		switch {
+		case target.Independent():
+			return fmt.Errorf("test %v sets its own rps, duration, requests, workers or start_after, which can't be exported", target.Name)
+		case target.Type == ScenarioTestType:
+			return fmt.Errorf("test %v is a scenario, whose requests can't be exported", target.Name)
+		}
+	}
+	requests := config.Requests
+	if requests == 0 {
+		requests = ExportDefaultRequests
+	}
+	if requests < 0 {
+		return fmt.Errorf("number of requests to export must be positive, got %d", requests)
+	}
+
+	total := tm.totalWeight()
+	if total <= 0 {
+		return vegeta.ErrNoTargets
+	}
+	targets := make([]exportTarget, requests)
+	for i := range targets {
+		target := tm.choose(int(rand.Int31n(int32(total))))
+		tgt := target.Target(client)
+		targets[i] = exportTarget{
+			Test:   target.Name,
+			Method: tgt.Method,
+			URL:    tgt.URL,
+			Body:   string(tgt.Body),
+		}
+		// Headers used within the benchmark mean nothing to other tools
+		header := tgt.Header.Clone()
+		header.Del(testTLSHeader)
+		header.Del(requestPolicyHeader)
+		header.Del(scenarioHeader)
+		if len(header) > 0 {
+			targets[i].Header = make(map[string]string, len(header))
+			for name := range header {
+				targets[i].Header[name] = header.Get(name)
+			}
+		}
+	}
+
+	switch config.Format {
+	case ExportFormatVegeta, "":
+		return writeVegetaTargets(w, targets)
+	case ExportFormatK6:
+		return writeK6Script(w, targets, config)
+	default:
+		return fmt.Errorf("unknown export format %q, must be %v or %v", config.Format, ExportFormatVegeta, ExportFormatK6)
+	}
+}
+
+// writeVegetaTargets writes the targets in the JSON format of vegeta
+func writeVegetaTargets(w io.Writer, targets []exportTarget) error {
+	bw := bufio.NewWriter(w)
+	enc := vegeta.NewJSONTargetEncoder(bw)
+	for _, target := range targets {
+		tgt := vegeta.Target{
+			Method: target.Method,
+			URL:    target.URL,
+			Body:   []byte(target.Body),
+		}
+		if len(target.Header) > 0 {
+			tgt.Header = make(map[string][]string, len(target.Header))
+			for name, value := range target.Header {
+				tgt.Header[name] = []string{value}
+			}
+		}
+		if err := enc.Encode(&tgt); err != nil {
+			return fmt.Errorf("error encoding target: %v", err)
+		}
+	}
+	return bw.Flush()
+}
+
+// writeK6Script writes a k6 script sending the targets in turn, at the rate
+// and for the duration of the attack. The requests of each test are tagged
+// with its name. Bodies are base64 encoded, as they may not be text.
+func writeK6Script(w io.Writer, targets []exportTarget, config *ExportConfig) error {
+	for i := range targets {
+		targets[i].Body = base64.StdEncoding.EncodeToString([]byte(targets[i].Body))
+	}
+	encoded, err := json.MarshalIndent(targets, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding targets: %v", err)
+	}
+
+	workers := max(config.Workers, 1)
+	duration := config.Duration
+	if duration <= 0 {
+		duration = 10 * time.Second
+	}
+	var scenario string
+	if config.Mode == ClosedLoopAttackMode || config.RPS <= 0 {
+		scenario = fmt.Sprintf(`      executor: 'constant-vus',
+      vus: %d,
+      duration: '%v',`, workers, duration)
+	} else {
+		scenario = fmt.Sprintf(`      executor: 'constant-arrival-rate',
+      rate: %d,
+      timeUnit: '1s',
+      duration: '%v',
+      preAllocatedVUs: %d,`, config.RPS, duration, workers)
+	}
+
+	var b strings.Builder
+	b.WriteString(`// Written by vault-benchmark run -export_format=k6. The targets were picked
+// by the weights of the tests, and are sent in turn.
+import http from 'k6/http';
+import exec from 'k6/execution';
+import encoding from 'k6/encoding';
+import { check } from 'k6';
+
+export const options = {
+  scenarios: {
+    benchmark: {
+`)
+	b.WriteString(scenario)
+	b.WriteString(`
+    },
+  },
+};
+
+const targets = `)
+	b.Write(encoded)
+	b.WriteString(`;
+
+export default function () {
+  const target = targets[exec.scenario.iterationInTest % targets.length];
+  const body = target.body ? encoding.b64decode(target.body, 'std', 's') : null;
+  const res = http.request(target.method, target.url, body, {
+    headers: target.header,
+    tags: { name: target.test },
+  });
+  check(res, { 'status is 2xx': (r) => r.status >= 200 && r.status < 300 });
+}
+`)
+	_, err = io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestExportTargets(t *testing.T) {
+	cfg := api.DefaultConfig()
+	cfg.Address = "http://127.0.0.1:8200"
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+
+	build := func(name string, weight int, config string) *BenchmarkTarget {
+		file, diags := hclparse.NewParser().ParseHCL([]byte(config), "test.hcl")
+		if diags.HasErrors() {
+			t.Fatalf("err: %v", diags)
+		}
+		builder := TestList[RawTestType]()
+		if err := builder.ParseConfig(file.Body); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return &BenchmarkTarget{Name: name, Type: RawTestType, Weight: weight, Builder: builder}
+	}
+	logger := hclog.NewNullLogger()
+	tm, err := BuildTargets(client, []*BenchmarkTarget{
+		build("read", 75, "config {\n  path = \"secret/data/app-{{seq}}\"\n}\n"),
+		build("write", 25, "config {\n  method = \"POST\"\n  path = \"secret/data/app\"\n  body = \"{\\\"data\\\": {\\\"n\\\": \\\"{{seq}}\\\"}}\"\n}\n"),
+	}, &logger, &TopLevelTargetConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := ExportTargets(&out, tm, client, &ExportConfig{Format: ExportFormatVegeta, Requests: 200}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	targets, err := vegeta.ReadAllTargets(vegeta.NewJSONTargeter(&out, nil, nil))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(targets) != 200 {
+		t.Fatalf("expected 200 targets, got %d", len(targets))
+	}
+	reads := 0
+	for _, tgt := range targets {
+		if tgt.Header.Get("X-Vault-Token") != "root" {
+			t.Fatalf("expected the token to be sent, got %v", tgt.Header)
+		}
+		switch tgt.Method {
+		case "GET":
+			reads++
+			if !strings.HasPrefix(tgt.URL, "http://127.0.0.1:8200/v1/secret/data/app-") {
+				t.Fatalf("unexpected read: %v", tgt.URL)
+			}
+		case "POST":
+			if !strings.HasPrefix(string(tgt.Body), `{"data": {"n": "`) {
+				t.Fatalf("unexpected write: %s", tgt.Body)
+			}
+		default:
+			t.Fatalf("unexpected target: %+v", tgt)
+		}
+	}
+	// The targets are picked by the weights of the tests
+	if reads < 100 || reads > 190 {
+		t.Fatalf("expected about 150 reads, got %d", reads)
+	}
+
+	out.Reset()
+	if err := ExportTargets(&out, tm, client, &ExportConfig{Format: ExportFormatK6, Requests: 10, Duration: time.Minute, RPS: 50, Workers: 5}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, expected := range []string{"executor: 'constant-arrival-rate'", "rate: 50,", "duration: '1m0s'", "preAllocatedVUs: 5,", `"test": "read"`, "tags: { name: target.test }"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("expected script to contain %q, got:\n%v", expected, out.String())
+		}
+	}
+
+	tm.targets[0].Requests = 10
+	if err := ExportTargets(&out, tm, client, &ExportConfig{}); err == nil || !strings.Contains(err.Error(), "can't be exported") {
+		t.Fatalf("expected tests attacked on their own to be rejected, got: %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// DefaultFailoverAt is when the active node is stepped down when no
+	// point is given, halfway through the run
+	DefaultFailoverAt = "50%"
+
+	// FailoverEvent is the name of the chaos event of the failover scenario
+	FailoverEvent = "failover"
+
+	// failoverP99Tolerance is how far above its baseline the 99th
+	// percentile latency may be once it has returned to it
+	failoverP99Tolerance = 1.5
+)
+
+// FailoverConfig runs the built-in failover scenario, stepping down the
+// active node of the cluster with sys/step-down during the attack and
+// measuring how long requests failed for and how long latencies took to
+// return to normal after it.
+type FailoverConfig struct {
+	At             string `hcl:"at,optional"`
+	RecoveryWindow string `hcl:"recovery_window,optional"`
+}
+
+// Validate checks the recovery window of the failover and defaults the
+// point it runs at. The points are checked against the duration of the run.
+func (f *FailoverConfig) Validate() error {
+	if f.At == "" {
+		f.At = DefaultFailoverAt
+	}
+	_, err := f.Window()
+	return err
+}
+
+// Window returns how long results must stay healthy after the step-down
+// for the cluster to count as recovered
+func (f *FailoverConfig) Window() (time.Duration, error) {
+	return parseRecoveryWindow(f.RecoveryWindow)
+}
+
+// FailoverResult measures the impact of a failover. The error window is
+// the time from the first to the last request which failed before the
+// cluster recovered, and P99Recovery the time from the step-down until the
+// 99th percentile latency of successful requests returned to its baseline
+// for the recovery window, nil when it didn't before the attack ended.
+type FailoverResult struct {
+	ErrorWindow time.Duration  `json:"error_window"`
+	Failed      uint64         `json:"failed"`
+	BaselineP99 time.Duration  `json:"baseline_p99"`
+	P99Recovery *time.Duration `json:"p99_recovery,omitempty"`
+}
+
+// failoverResult measures the failover of a step-down from the results of
+// every second of the attack. Failed requests are counted from the second
+// of the step-down until the cluster recovered, or the attack ended.
+func (r *Reporter) failoverResult(event ChaosEventResult) *FailoverResult {
+	first := int(event.Time.Sub(r.began) / time.Second)
+	last := len(r.recovery)
+	if event.Recovery != nil {
+		last = int(event.Time.Add(*event.Recovery).Sub(r.began) / time.Second)
+	}
+
+	result := &FailoverResult{}
+	var firstError, lastError time.Time
+	for i := max(first, 0); i < last && i < len(r.recovery); i++ {
+		s := r.recovery[i]
+		result.Failed += s.errors
+		if s.errors == 0 {
+			continue
+		}
+		if firstError.IsZero() {
+			firstError = s.firstError
+		}
+		lastError = s.lastError
+	}
+	if !firstError.IsZero() {
+		result.ErrorWindow = lastError.Sub(firstError)
+	}
+
+	result.BaselineP99 = r.baselineP99(first)
+	if result.BaselineP99 == 0 {
+		return result
+	}
+
+	limit := time.Duration(float64(result.BaselineP99) * failoverP99Tolerance)
+	needed := int((event.Window + time.Second - 1) / time.Second)
+	run := 0
+	for i := max(first, 0); i < len(r.recovery); i++ {
+		s := r.recovery[i]
+		if s.successes.Max == 0 || s.successes.Quantile(0.99) > limit {
+			run = 0
+			continue
+		}
+		run++
+		if run == needed {
+			start := r.began.Add(time.Duration(i-needed+1) * time.Second)
+			recovery := max(start.Sub(event.Time), 0)
+			result.P99Recovery = &recovery
+			break
+		}
+	}
+	return result
+}
+
+// reportFailover writes the measurements of the failovers of the attack,
+// when it has any
+func (r *Reporter) reportFailover(w io.Writer) {
+	for _, event := range r.chaosEvents {
+		if event.Failover == nil {
+			continue
+		}
+		f := event.Failover
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "\nFailover: step-down at +%s\n", event.Time.Sub(r.began).Round(time.Second))
+		if event.Error != "" {
+			fmt.Fprintf(tw, "step-down failed:\t%s\n", event.Error)
+			tw.Flush()
+			continue
+		}
+		fmt.Fprintf(tw, "error window:\t%s\t\n", f.ErrorWindow.Round(time.Millisecond))
+		fmt.Fprintf(tw, "failed requests:\t%d\t\n", f.Failed)
+		recovered, p99 := "not recovered", "not returned"
+		if event.Recovery != nil {
+			recovered = event.Recovery.Round(time.Millisecond).String()
+		}
+		if f.P99Recovery != nil {
+			p99 = f.P99Recovery.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "recovered after:\t%s\t\n", recovered)
+		fmt.Fprintf(tw, "99th%% back to baseline after:\t%s\t(baseline %s)\n", p99, f.BaselineP99.Round(time.Microsecond))
+		tw.Flush()
+	}
+}
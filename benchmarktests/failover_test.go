@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestFailoverResult(t *testing.T) {
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	stepDown := began.Add(30500 * time.Millisecond)
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.startWarmup(began, 0)
+	rpt.trackRecovery(true)
+
+	// Requests fail from the step-down until 34 seconds in, are slow until
+	// 37 seconds in and are still above the baseline 99th percentile until
+	// 40 seconds in
+	for i := 0; i < 60; i++ {
+		for j := 0; j < 10; j++ {
+			result := &vegeta.Result{
+				Method:    "GET",
+				URL:       "N/A/v1/secret/foo",
+				Code:      200,
+				Timestamp: began.Add(time.Duration(i)*time.Second + time.Duration(j)*100*time.Millisecond),
+				Latency:   time.Millisecond,
+			}
+			switch {
+			case !result.Timestamp.Before(stepDown) && i < 34:
+				result.Code, result.Error = 500, "500 Internal Server Error"
+			case i >= 34 && i < 37:
+				result.Latency = 10 * time.Millisecond
+			case i >= 37 && i < 40:
+				result.Latency = 1800 * time.Microsecond
+			}
+			rpt.Add(result)
+		}
+	}
+	rpt.Close()
+
+	rpt.SetChaosEvents([]ChaosEventResult{{
+		Name:     FailoverEvent,
+		Action:   "step-down",
+		Time:     stepDown,
+		Window:   DefaultRecoveryWindow,
+		Failover: &FailoverResult{},
+	}})
+	event := rpt.chaosEvents[0]
+	if event.Recovery == nil || *event.Recovery != 6500*time.Millisecond {
+		t.Fatalf("expected recovery 6.5s after the step-down, got %v", event.Recovery)
+	}
+	f := event.Failover
+	if f.Failed != 35 || f.ErrorWindow != 3400*time.Millisecond {
+		t.Fatalf("expected 35 requests to fail over 3.4s, got %+v", f)
+	}
+	if f.BaselineP99 != time.Millisecond || f.P99Recovery == nil || *f.P99Recovery != 9500*time.Millisecond {
+		t.Fatalf("expected the 99th percentile back to 1ms 9.5s after the step-down, got %+v", f)
+	}
+
+	var b bytes.Buffer
+	rpt.reportFailover(&b)
+	for _, want := range []string{"Failover: step-down at +31s", "error window:", "3.4s", "failed requests:", "35", "recovered after:", "6.5s", "9.5s", "(baseline 1ms)"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected %q in failover report:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	// histogramLowest is the smallest latency the histogram tells apart
+	// from zero
+	histogramLowest = time.Microsecond
+
+	// histogramHighest is the largest latency the histogram records;
+	// anything slower is recorded as this value
+	histogramHighest = time.Hour
+
+	// histogramSignificantFigures is the number of significant decimal
+	// digits every recorded latency keeps
+	histogramSignificantFigures = 3
+)
+
+// Histogram records latencies with a fixed relative precision, following
+// the layout of HdrHistogram: values are kept in buckets whose width
+// doubles from one bucket to the next, each split into enough linear sub
+// buckets to keep histogramSignificantFigures digits. Every recorded value
+// is counted in the bucket it falls in, so, unlike with the digest used by
+// vegeta, any percentile can be read back to within that precision, about
+// 0.1% of the value, and the whole distribution can be exported. Only the
+// min and max are kept exactly.
+type Histogram struct {
+	unitMagnitude               uint
+	subBucketHalfCountMagnitude uint
+	subBucketCount              int
+	subBucketHalfCount          int
+	subBucketMask               int64
+
+	counts []int64
+	total  int64
+	sum    float64
+	min    int64
+	max    int64
+}
+
+// HistogramBucket is the number of recorded latencies which were no
+// greater than Value and greater than the Value of the previous bucket
+type HistogramBucket struct {
+	Value time.Duration `json:"value"`
+	Count int64         `json:"count"`
+}
+
+// NewHistogram returns an empty latency histogram
+func NewHistogram() *Histogram {
+	largestSingleUnit := 2 * int64(math.Pow10(histogramSignificantFigures))
+	subBucketCountMagnitude := uint(math.Ceil(math.Log2(float64(largestSingleUnit))))
+	subBucketHalfCountMagnitude := max(subBucketCountMagnitude, 1) - 1
+	unitMagnitude := uint(math.Floor(math.Log2(float64(histogramLowest))))
+	subBucketCount := 1 << (subBucketHalfCountMagnitude + 1)
+
+	// Find the number of buckets needed to cover the highest value
+	bucketCount := 1
+	for smallestUntrackable := int64(subBucketCount) << unitMagnitude; smallestUntrackable <= int64(histogramHighest); smallestUntrackable <<= 1 {
+		bucketCount++
+	}
+
+	return &Histogram{
+		unitMagnitude:               unitMagnitude,
+		subBucketHalfCountMagnitude: subBucketHalfCountMagnitude,
+		subBucketCount:              subBucketCount,
+		subBucketHalfCount:          subBucketCount / 2,
+		subBucketMask:               int64(subBucketCount-1) << unitMagnitude,
+		counts:                      make([]int64, (bucketCount+1)*(subBucketCount/2)),
+		min:                         math.MaxInt64,
+	}
+}
+
+// Record adds a single latency to the histogram
+func (h *Histogram) Record(latency time.Duration) {
+	h.recordValues(int64(latency), 1)
+}
+
+func (h *Histogram) recordValues(value, count int64) {
+	value = min(max(value, 0), int64(histogramHighest))
+	h.counts[h.countsIndex(value)] += count
+	h.total += count
+	h.sum += float64(value) * float64(count)
+	h.min = min(h.min, value)
+	h.max = max(h.max, value)
+}
+
+// Count returns the number of recorded latencies
+func (h *Histogram) Count() int64 {
+	return h.total
+}
+
+// Min returns the smallest recorded latency
+func (h *Histogram) Min() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.min)
+}
+
+// Max returns the largest recorded latency
+func (h *Histogram) Max() time.Duration {
+	return time.Duration(h.max)
+}
+
+// Mean returns the average of the recorded latencies
+func (h *Histogram) Mean() time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	return time.Duration(h.sum / float64(h.total))
+}
+
+// Quantile returns the latency at or below which the given fraction,
+// between 0 and 1, of the recorded latencies fall. Like HdrHistogram, it is
+// the value of the recorded latency whose rank is the fraction of the
+// count rounded up.
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	if q >= 1 {
+		return h.Max()
+	}
+
+	// The rank is rounded up less the error of the multiplication, so
+	// 0.07 of 100 latencies is the 7th rather than the 8th
+	target := max(int64(math.Ceil(q*float64(h.total)-1e-9)), 1)
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= target {
+			value := h.highestEquivalentValue(h.valueFromIndex(i))
+			return time.Duration(min(value, h.max))
+		}
+	}
+	return h.Max()
+}
+
+// Merge adds all latencies recorded in other to the histogram
+func (h *Histogram) Merge(other *Histogram) {
+	for i, count := range other.counts {
+		if count > 0 {
+			h.counts[i] += count
+		}
+	}
+	h.total += other.total
+	h.sum += other.sum
+	h.min = min(h.min, other.min)
+	h.max = max(h.max, other.max)
+}
+
+// Buckets returns the non-empty buckets of the histogram, ordered by value
+func (h *Histogram) Buckets() []HistogramBucket {
+	var buckets []HistogramBucket
+	for i, count := range h.counts {
+		if count == 0 {
+			continue
+		}
+		value := h.highestEquivalentValue(h.valueFromIndex(i))
+		buckets = append(buckets, HistogramBucket{Value: time.Duration(value), Count: count})
+	}
+	return buckets
+}
+
+// jsonHistogram is the exported form of a Histogram
+type jsonHistogram struct {
+	Count   int64             `json:"count"`
+	Min     time.Duration     `json:"min"`
+	Max     time.Duration     `json:"max"`
+	Mean    time.Duration     `json:"mean"`
+	Buckets []HistogramBucket `json:"buckets"`
+}
+
+func (h *Histogram) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonHistogram{
+		Count:   h.total,
+		Min:     h.Min(),
+		Max:     h.Max(),
+		Mean:    h.Mean(),
+		Buckets: h.Buckets(),
+	})
+}
+
+// UnmarshalJSON rebuilds a histogram from its exported buckets. The exact
+// minimum, maximum and mean are restored from the export.
+func (h *Histogram) UnmarshalJSON(data []byte) error {
+	var exported jsonHistogram
+	if err := json.Unmarshal(data, &exported); err != nil {
+		return fmt.Errorf("error decoding histogram: %v", err)
+	}
+
+	*h = *NewHistogram()
+	for _, bucket := range exported.Buckets {
+		if bucket.Count < 0 {
+			return fmt.Errorf("histogram bucket %v has a negative count", bucket.Value)
+		}
+		h.recordValues(int64(bucket.Value), bucket.Count)
+	}
+	if h.total > 0 {
+		h.min = int64(exported.Min)
+		h.max = int64(exported.Max)
+		h.sum = float64(exported.Mean) * float64(h.total)
+	}
+	return nil
+}
+
+func (h *Histogram) bucketIndex(value int64) int {
+	pow2Ceiling := 64 - bits.LeadingZeros64(uint64(value|h.subBucketMask))
+	return pow2Ceiling - int(h.unitMagnitude) - int(h.subBucketHalfCountMagnitude+1)
+}
+
+func (h *Histogram) subBucketIndex(value int64, bucketIdx int) int {
+	return int(value >> (uint(bucketIdx) + h.unitMagnitude))
+}
+
+func (h *Histogram) countsIndex(value int64) int {
+	bucketIdx := h.bucketIndex(value)
+	subBucketIdx := h.subBucketIndex(value, bucketIdx)
+	bucketBaseIdx := (bucketIdx + 1) << h.subBucketHalfCountMagnitude
+	return bucketBaseIdx + subBucketIdx - h.subBucketHalfCount
+}
+
+// valueFromIndex returns the lowest value recorded in the given slot of
+// the counts array
+func (h *Histogram) valueFromIndex(i int) int64 {
+	bucketIdx := (i >> h.subBucketHalfCountMagnitude) - 1
+	subBucketIdx := (i & (h.subBucketHalfCount - 1)) + h.subBucketHalfCount
+	if bucketIdx < 0 {
+		subBucketIdx -= h.subBucketHalfCount
+		bucketIdx = 0
+	}
+	return int64(subBucketIdx) << (uint(bucketIdx) + h.unitMagnitude)
+}
+
+// highestEquivalentValue returns the largest value which is recorded in
+// the same slot as value
+func (h *Histogram) highestEquivalentValue(value int64) int64 {
+	bucketIdx := h.bucketIndex(value)
+	subBucketIdx := h.subBucketIndex(value, bucketIdx)
+	lowest := int64(subBucketIdx) << (uint(bucketIdx) + h.unitMagnitude)
+
+	sizeMagnitude := uint(bucketIdx) + h.unitMagnitude
+	if subBucketIdx >= h.subBucketCount {
+		sizeMagnitude++
+	}
+	return lowest + (int64(1) << sizeMagnitude) - 1
+}
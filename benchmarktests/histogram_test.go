@@ -0,0 +1,144 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// within reports whether got is within the histogram's precision of want
+func within(got, want time.Duration) bool {
+	return math.Abs(float64(got-want)) <= float64(want)/1000+float64(histogramLowest)
+}
+
+func TestHistogram_Quantile(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 10000; i++ {
+		h.Record(time.Duration(i) * 100 * time.Microsecond)
+	}
+
+	if h.Count() != 10000 {
+		t.Fatalf("expected 10000 values, got: %d", h.Count())
+	}
+	if h.Min() != 100*time.Microsecond || h.Max() != time.Second {
+		t.Fatalf("expected exact min and max, got: %v and %v", h.Min(), h.Max())
+	}
+	for q, want := range map[float64]time.Duration{
+		0.5:    500 * time.Millisecond,
+		0.99:   990 * time.Millisecond,
+		0.9999: 999900 * time.Microsecond,
+		1:      time.Second,
+	} {
+		if got := h.Quantile(q); !within(got, want) {
+			t.Fatalf("expected quantile %v to be about %v, got: %v", q, want, got)
+		}
+	}
+	if got := h.Mean(); !within(got, 500050*time.Microsecond) {
+		t.Fatalf("expected a mean of about 500.05ms, got: %v", got)
+	}
+
+	// The rank of a quantile is rounded up
+	small := NewHistogram()
+	for i := 1; i <= 100; i++ {
+		small.Record(time.Duration(i) * time.Millisecond)
+	}
+	for q, want := range map[float64]time.Duration{
+		0.004: time.Millisecond,
+		0.07:  7 * time.Millisecond,
+		0.071: 8 * time.Millisecond,
+		0.994: 100 * time.Millisecond,
+	} {
+		if got := small.Quantile(q); !within(got, want) {
+			t.Fatalf("expected quantile %v to be about %v, got: %v", q, want, got)
+		}
+	}
+
+	// Latencies beyond the range of the histogram are clamped
+	h.Record(2 * histogramHighest)
+	if h.Max() != histogramHighest {
+		t.Fatalf("expected max to be clamped to %v, got: %v", histogramHighest, h.Max())
+	}
+}
+
+func TestHistogram_JSONRoundTrip(t *testing.T) {
+	h := NewHistogram()
+	for _, latency := range []time.Duration{time.Millisecond, time.Millisecond, 3 * time.Millisecond, 2 * time.Second} {
+		h.Record(latency)
+	}
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	var decoded Histogram
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	if len(decoded.Buckets()) != 3 {
+		t.Fatalf("expected 3 buckets, got: %v", decoded.Buckets())
+	}
+	if decoded.Count() != h.Count() || decoded.Min() != h.Min() || decoded.Max() != h.Max() {
+		t.Fatalf("expected histogram to be unchanged after round trip, got: %s", data)
+	}
+	if got := decoded.Quantile(0.5); got != h.Quantile(0.5) {
+		t.Fatalf("expected median %v after round trip, got: %v", h.Quantile(0.5), got)
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	a, b := NewHistogram(), NewHistogram()
+	a.Record(time.Millisecond)
+	b.Record(time.Second)
+	a.Merge(b)
+
+	if a.Count() != 2 || a.Min() != time.Millisecond || a.Max() != time.Second {
+		t.Fatalf("expected merged histogram to hold both values, got: %v", a.Buckets())
+	}
+}
+
+func TestSendSchedule_Delay(t *testing.T) {
+	schedule := newSendSchedule(vegeta.Rate{Freq: 10, Per: time.Second})
+
+	// Hit 0 is due 100ms in, hit 1 200ms in. The attack has fallen behind
+	// by the time it paces hit 1.
+	schedule.Pace(0, 0)
+	began := schedule.began
+	if wait, _ := schedule.Pace(time.Second, 1); wait != 0 {
+		t.Fatalf("expected a late hit to be sent immediately, got wait: %v", wait)
+	}
+
+	onTime := &vegeta.Result{Seq: 0, Timestamp: began.Add(100 * time.Millisecond)}
+	if got := schedule.delay(onTime); got != 0 {
+		t.Fatalf("expected no delay for a hit sent on time, got: %v", got)
+	}
+	late := &vegeta.Result{Seq: 1, Timestamp: began.Add(time.Second)}
+	if got := schedule.delay(late); got != 800*time.Millisecond {
+		t.Fatalf("expected a delay of 800ms, got: %v", got)
+	}
+	if len(schedule.intended) != 0 {
+		t.Fatalf("expected consumed hits to be forgotten, got: %v", schedule.intended)
+	}
+}
+
+func TestReporter_CorrectedLatencies(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.corrected = true
+
+	rpt.addDelayed(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Timestamp: time.Now(), Latency: 10 * time.Millisecond}, 90*time.Millisecond)
+	rpt.Close()
+
+	if got := rpt.metrics["read"].Latencies.Max; got != 10*time.Millisecond {
+		t.Fatalf("expected the raw latency in the metrics, got: %v", got)
+	}
+	if got := rpt.histograms["read"].Max(); got != 100*time.Millisecond {
+		t.Fatalf("expected the corrected latency in the histogram, got: %v", got)
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	// The sqlite driver is pure Go, so binaries built without cgo can
+	// record history
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// DefaultHistoryLimit is the number of runs shown by the history command
+	DefaultHistoryLimit = 20
+
+	// historyBarWidth is the width of the bar drawn for the largest value
+	// of a trend
+	historyBarWidth = 40
+)
+
+// historySchema creates the tables of the history database. Times are kept
+// as unix nanoseconds and latencies as nanoseconds.
+const historySchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id      TEXT NOT NULL,
+	started     INTEGER NOT NULL,
+	duration    INTEGER NOT NULL,
+	labels      TEXT NOT NULL,
+	version     TEXT NOT NULL,
+	fingerprint TEXT NOT NULL,
+	passed      INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS results (
+	run        INTEGER NOT NULL REFERENCES runs (id),
+	phase      TEXT NOT NULL,
+	test       TEXT NOT NULL,
+	requests   INTEGER NOT NULL,
+	throughput REAL NOT NULL,
+	error_rate REAL NOT NULL,
+	p50        INTEGER NOT NULL,
+	p99        INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS results_test ON results (test, phase);
+CREATE TABLE IF NOT EXISTS intervals (
+	run        INTEGER NOT NULL REFERENCES runs (id),
+	target     TEXT NOT NULL,
+	phase      TEXT NOT NULL,
+	test       TEXT NOT NULL,
+	start      INTEGER NOT NULL,
+	requests   INTEGER NOT NULL,
+	throughput REAL NOT NULL,
+	error_rate REAL NOT NULL,
+	mean       INTEGER NOT NULL,
+	p50        INTEGER NOT NULL,
+	p90        INTEGER NOT NULL,
+	p95        INTEGER NOT NULL,
+	p99        INTEGER NOT NULL,
+	max        INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS intervals_run ON intervals (run, test);
+`
+
+// HistoryDB is a SQLite database keeping the results of every run, so the
+// performance of a test can be followed over time
+type HistoryDB struct {
+	db *sql.DB
+}
+
+// HistoryRun describes a run kept in the history database. Tests is only
+// set when listing runs.
+type HistoryRun struct {
+	ID          int64
+	RunID       string
+	Started     time.Time
+	Duration    time.Duration
+	Labels      map[string]string
+	Version     string
+	Fingerprint string
+	Passed      bool
+	Tests       int
+}
+
+// HistoryPoint is the results of a test in one run
+type HistoryPoint struct {
+	Run   *HistoryRun
+	Stats *DiffStats
+}
+
+// OpenHistoryDB opens the history database at path, creating it if it
+// doesn't exist
+func OpenHistoryDB(path string) (*HistoryDB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening history database: %v", err)
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating history database: %v", err)
+	}
+	return &HistoryDB{db: db}, nil
+}
+
+func (h *HistoryDB) Close() error {
+	return h.db.Close()
+}
+
+// Record stores a run along with the results of every test in rpts,
+// combined by phase and test as by DiffReports, and the time series of
+// each report. The ID of the stored run is returned.
+func (h *HistoryDB) Record(run *HistoryRun, rpts []*Reporter) (int64, error) {
+	labels, err := json.Marshal(run.Labels)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding labels: %v", err)
+	}
+
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error recording run: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO runs (run_id, started, duration, labels, version, fingerprint, passed) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		run.RunID, run.Started.UnixNano(), int64(run.Duration), string(labels), run.Version, run.Fingerprint, run.Passed)
+	if err != nil {
+		return 0, fmt.Errorf("error recording run: %v", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error recording run: %v", err)
+	}
+
+	stats, keys := diffStats(rpts)
+	for _, key := range keys {
+		s := stats[key]
+		if _, err := tx.Exec(`INSERT INTO results (run, phase, test, requests, throughput, error_rate, p50, p99) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, key.phase, key.test, int64(s.Requests), s.Throughput, s.ErrorRate, int64(s.P50), int64(s.P99)); err != nil {
+			return 0, fmt.Errorf("error recording results of %v: %v", key.test, err)
+		}
+	}
+
+	for _, rpt := range rpts {
+		for test, points := range rpt.timeseries {
+			for _, p := range points {
+				if _, err := tx.Exec(`INSERT INTO intervals (run, target, phase, test, start, requests, throughput, error_rate, mean, p50, p90, p95, p99, max) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+					id, rpt.clientAddr, rpt.phase, test, p.Start.UnixNano(), int64(p.Requests), p.Throughput, p.ErrorRate,
+					int64(p.Mean), int64(p.P50), int64(p.P90), int64(p.P95), int64(p.P99), int64(p.Max)); err != nil {
+					return 0, fmt.Errorf("error recording time series of %v: %v", test, err)
+				}
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("error recording run: %v", err)
+	}
+	return id, nil
+}
+
+// Runs returns the most recent runs, at most limit of them, oldest first
+func (h *HistoryDB) Runs(limit int) ([]*HistoryRun, error) {
+	rows, err := h.db.Query(`SELECT id, run_id, started, duration, labels, version, fingerprint, passed,
+		(SELECT COUNT(DISTINCT test) FROM results WHERE results.run = runs.id AND test != 'total')
+		FROM runs ORDER BY started DESC, id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing runs: %v", err)
+	}
+	defer rows.Close()
+
+	var runs []*HistoryRun
+	for rows.Next() {
+		run := &HistoryRun{}
+		var started, duration int64
+		var labels string
+		if err := rows.Scan(&run.ID, &run.RunID, &started, &duration, &labels, &run.Version, &run.Fingerprint, &run.Passed, &run.Tests); err != nil {
+			return nil, fmt.Errorf("error listing runs: %v", err)
+		}
+		if err := run.decode(started, duration, labels); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing runs: %v", err)
+	}
+	slices.Reverse(runs)
+	return runs, nil
+}
+
+// Trend returns the results of a test in the most recent runs which ran
+// it, at most limit of them, oldest first
+func (h *HistoryDB) Trend(test, phase string, limit int) ([]*HistoryPoint, error) {
+	rows, err := h.db.Query(`SELECT runs.id, run_id, started, duration, labels, version, fingerprint, passed,
+		requests, throughput, error_rate, p50, p99
+		FROM results JOIN runs ON results.run = runs.id
+		WHERE test = ? AND phase = ? ORDER BY started DESC, runs.id DESC LIMIT ?`, test, phase, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error reading results of %v: %v", test, err)
+	}
+	defer rows.Close()
+
+	var points []*HistoryPoint
+	for rows.Next() {
+		run, stats := &HistoryRun{}, &DiffStats{}
+		var started, duration, requests, p50, p99 int64
+		var labels string
+		if err := rows.Scan(&run.ID, &run.RunID, &started, &duration, &labels, &run.Version, &run.Fingerprint, &run.Passed,
+			&requests, &stats.Throughput, &stats.ErrorRate, &p50, &p99); err != nil {
+			return nil, fmt.Errorf("error reading results of %v: %v", test, err)
+		}
+		if err := run.decode(started, duration, labels); err != nil {
+			return nil, err
+		}
+		stats.Requests = uint64(requests)
+		stats.P50 = time.Duration(p50)
+		stats.P99 = time.Duration(p99)
+		points = append(points, &HistoryPoint{Run: run, Stats: stats})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading results of %v: %v", test, err)
+	}
+	slices.Reverse(points)
+	return points, nil
+}
+
+func (run *HistoryRun) decode(started, duration int64, labels string) error {
+	run.Started = time.Unix(0, started)
+	run.Duration = time.Duration(duration)
+	if err := json.Unmarshal([]byte(labels), &run.Labels); err != nil {
+		return fmt.Errorf("error decoding labels of run %d: %v", run.ID, err)
+	}
+	return nil
+}
+
+// WriteHistoryRuns writes a table of the runs
+func WriteHistoryRuns(w io.Writer, runs []*HistoryRun) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "run\tstarted\tduration\ttests\tversion\tresult\tlabels\n")
+	for _, run := range runs {
+		fmt.Fprintf(tw, "%d\t%s\t%v\t%d\t%s\t%s\t%s\n", run.ID, run.Started.Format(time.RFC3339), run.Duration.Round(time.Second),
+			run.Tests, historyValue(run.Version), historyResult(run.Passed), historyValue(formatLabels(run.Labels)))
+	}
+	return tw.Flush()
+}
+
+// HistoryMetrics returns the names of the metrics a trend can be plotted
+// for
+func HistoryMetrics() []string {
+	names := make([]string, 0, len(diffMetrics))
+	for _, metric := range diffMetrics {
+		names = append(names, historyMetricName(metric))
+	}
+	return names
+}
+
+func historyMetricName(metric diffMetric) string {
+	return strings.ReplaceAll(metric.name, " ", "_")
+}
+
+// WriteTrend writes a table of the results of a test in each run, with a bar
+// plotting the named metric
+func WriteTrend(w io.Writer, points []*HistoryPoint, metricName string) error {
+	var plotted *diffMetric
+	for i := range diffMetrics {
+		if historyMetricName(diffMetrics[i]) == metricName {
+			plotted = &diffMetrics[i]
+		}
+	}
+	if plotted == nil {
+		return fmt.Errorf("unknown metric %q, must be one of %v", metricName, strings.Join(HistoryMetrics(), ", "))
+	}
+
+	var peak float64
+	for _, p := range points {
+		peak = math.Max(peak, plotted.value(p.Stats))
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "run\tstarted\tversion\trequests\tthroughput\tp50\tp99\terror rate\t%s\n", metricName)
+	for _, p := range points {
+		var bar string
+		if peak > 0 {
+			bar = strings.Repeat("█", int(math.Round(plotted.value(p.Stats)/peak*historyBarWidth)))
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%.2f/s\t%v\t%v\t%.2f%%\t%s\n", p.Run.ID, p.Run.Started.Format(time.RFC3339),
+			historyValue(p.Run.Version), p.Stats.Requests, p.Stats.Throughput, p.Stats.P50, p.Stats.P99, p.Stats.ErrorRate*100, bar)
+	}
+	return tw.Flush()
+}
+
+func historyValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func historyResult(passed bool) string {
+	if passed {
+		return "pass"
+	}
+	return "FAIL"
+}
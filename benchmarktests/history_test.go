@@ -0,0 +1,109 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// historyReport returns a report of a read test whose requests took latency
+func historyReport(began time.Time, latency time.Duration) *Reporter {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.startWarmup(began, 0)
+	rpt.trackTimeseries(10 * time.Second)
+	for i := 0; i < 20; i++ {
+		rpt.Add(&vegeta.Result{
+			Method:    "GET",
+			URL:       "N/A/v1/secret/foo",
+			Code:      200,
+			Timestamp: began.Add(time.Duration(i) * time.Second),
+			Latency:   latency,
+		})
+	}
+	rpt.Close()
+	return rpt
+}
+
+func TestHistoryDB(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bench.db")
+	history, err := OpenHistoryDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, latency := range []time.Duration{time.Millisecond, 4 * time.Millisecond} {
+		started := began.Add(time.Duration(i) * time.Hour)
+		run := &HistoryRun{
+			RunID:    "run",
+			Started:  started,
+			Duration: 20 * time.Second,
+			Labels:   map[string]string{"env": "ci"},
+			Version:  "2.0.1",
+			Passed:   i == 0,
+		}
+		if _, err := history.Record(run, []*Reporter{historyReport(started, latency)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history.Close()
+
+	// Runs are kept once the database is closed
+	history, err = OpenHistoryDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer history.Close()
+
+	runs, err := history.Runs(DefaultHistoryLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || !runs[0].Started.Equal(began) || runs[0].Tests != 1 || runs[0].Labels["env"] != "ci" || !runs[0].Passed || runs[1].Passed {
+		t.Fatalf("unexpected runs: %+v", runs)
+	}
+
+	points, err := history.Trend("read", "", DefaultHistoryLimit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 || points[0].Stats.Requests != 20 || points[1].Stats.P99 != 4*time.Millisecond {
+		t.Fatalf("unexpected trend: %+v", points)
+	}
+	if latest, err := history.Trend("read", "", 1); err != nil || len(latest) != 1 || latest[0].Run.ID != runs[1].ID {
+		t.Fatalf("expected only the latest run, got: %+v, %v", latest, err)
+	}
+
+	var intervals int
+	if err := history.db.QueryRow(`SELECT COUNT(*) FROM intervals WHERE test = 'read'`).Scan(&intervals); err != nil {
+		t.Fatal(err)
+	}
+	if intervals != 4 {
+		t.Fatalf("expected 2 intervals of each run, got: %v", intervals)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTrend(&buf, points, "p99"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and a line per run, got:\n%v", buf.String())
+	}
+	// The bar of the slowest run is the longest, and a quarter as long for
+	// the run four times as fast
+	if !strings.HasSuffix(lines[2], strings.Repeat("█", historyBarWidth)) || !strings.HasSuffix(lines[1], " "+strings.Repeat("█", historyBarWidth/4)) {
+		t.Fatalf("unexpected bars:\n%v", buf.String())
+	}
+	if err := WriteTrend(&buf, points, "p42"); err == nil {
+		t.Fatalf("expected an unknown metric to be an error")
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// Points of a run hooks are run at
+	BeforeRunHook  = "before_run"
+	AfterRunHook   = "after_run"
+	BeforeTestHook = "before_test"
+	AfterTestHook  = "after_test"
+
+	// What a failed hook does to the run: fail it, before the attack
+	// starts for hooks run before it, or carry on as if it had succeeded
+	HookFailureFail     = "fail"
+	HookFailureContinue = "continue"
+
+	// DefaultHookTimeout is how long a hook may run when it doesn't set
+	// its own timeout
+	DefaultHookTimeout = 5 * time.Minute
+
+	// hookOutputLimit is how much of the output of a failed hook is kept
+	// in its error
+	hookOutputLimit = 4096
+)
+
+// HookConfig is a command run before or after the attack of a run or of a
+// test, such as to flush caches, rotate logs on the target or start a
+// profiler. The command is run directly rather than by a shell, with the
+// metadata of the run in its environment.
+type HookConfig struct {
+	Command   []string `hcl:"command"`
+	Timeout   string   `hcl:"timeout,optional"`
+	OnFailure string   `hcl:"on_failure,optional"`
+}
+
+// Validate checks the command, timeout and on_failure of the hook
+func (c *HookConfig) Validate() error {
+	if len(c.Command) == 0 || c.Command[0] == "" {
+		return fmt.Errorf("command must be set")
+	}
+	if c.Timeout != "" {
+		timeout, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %v", err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be positive")
+		}
+	}
+	switch c.OnFailure {
+	case "", HookFailureFail, HookFailureContinue:
+	default:
+		return fmt.Errorf("on_failure must be one of %v or %v", HookFailureFail, HookFailureContinue)
+	}
+	return nil
+}
+
+func (c *HookConfig) timeout() time.Duration {
+	if c.Timeout == "" {
+		return DefaultHookTimeout
+	}
+	// The timeout is checked by Validate when the config is parsed
+	timeout, _ := time.ParseDuration(c.Timeout)
+	return timeout
+}
+
+// HookRun is the metadata of the run hooks are told in their environment.
+// Duration is the planned duration of the run, or of the attack of a test,
+// before it and how long it took after, and Passed is only set after the
+// run. Test and TestType are only set for the hooks of a test, whose
+// Targets are the target its attack is on.
+type HookRun struct {
+	Hook     string
+	RunID    string
+	Targets  []string
+	Duration string
+	Passed   *bool
+	Test     string
+	TestType string
+}
+
+// Environ returns the variables hooks are run with on top of the
+// environment of the benchmark
+func (r *HookRun) Environ() []string {
+	env := []string{
+		"VAULT_BENCHMARK_HOOK=" + r.Hook,
+		"VAULT_BENCHMARK_RUN_ID=" + r.RunID,
+		"VAULT_BENCHMARK_TARGETS=" + strings.Join(r.Targets, ","),
+		"VAULT_BENCHMARK_DURATION=" + r.Duration,
+	}
+	if r.Passed != nil {
+		env = append(env, "VAULT_BENCHMARK_PASSED="+strconv.FormatBool(*r.Passed))
+	}
+	if r.Test != "" {
+		env = append(env,
+			"VAULT_BENCHMARK_TEST_NAME="+r.Test,
+			"VAULT_BENCHMARK_TEST_TYPE="+r.TestType,
+		)
+	}
+	return env
+}
+
+// RunHooks runs the hooks in order, returning the errors of those which
+// failed and fail the run. Hooks run before the attack stop at the first
+// such failure, as the attack won't start, while those run after it all
+// run. Hooks which continue on failure only have their errors logged.
+func RunHooks(hooks []*HookConfig, run *HookRun, logger hclog.Logger) error {
+	var errs []error
+	for _, hook := range hooks {
+		command := strings.Join(hook.Command, " ")
+		start := time.Now()
+		err := runHook(hook, run)
+		switch {
+		case err == nil:
+			logger.Info("ran hook", "hook", run.Hook, "test", run.Test, "command", command, "duration", time.Since(start).Round(time.Millisecond).String())
+		case hook.OnFailure == HookFailureContinue:
+			logger.Warn("hook failed, continuing", "hook", run.Hook, "test", run.Test, "command", command, "error", hclog.Fmt("%v", err))
+		default:
+			errs = append(errs, fmt.Errorf("%v hook %q failed: %v", run.Hook, command, err))
+		}
+		if len(errs) > 0 && (run.Hook == BeforeRunHook || run.Hook == BeforeTestHook) {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// TestHooks runs the before and after hooks of the tests of attacks: the
+// before hooks of each test right before it starts sending requests, after
+// any start offset, and its after hooks once it has stopped. A test is
+// attacked once for each target and phase, so its hooks run around each of
+// these attacks. It is safe to use for several attacks at once.
+type TestHooks struct {
+	// RunID names the run to the hooks
+	RunID  string
+	Logger hclog.Logger
+
+	l    sync.Mutex
+	errs []error
+}
+
+// before runs the before hooks of the targets in order, stopping at the
+// first which fails the run. duration is how long the targets are planned
+// to be attacked for, or zero when they send a number of requests.
+func (h *TestHooks) before(targets []BenchmarkTarget, addr string, duration time.Duration) error {
+	if h == nil {
+		return nil
+	}
+	for _, target := range targets {
+		run := h.run(BeforeTestHook, target, addr)
+		if duration > 0 {
+			run.Duration = duration.String()
+		}
+		if err := RunHooks(target.Before, run, h.Logger); err != nil {
+			h.fail(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// after runs the after hooks of the targets in order, all of them even when
+// one fails. elapsed is how long the targets were attacked for.
+func (h *TestHooks) after(targets []BenchmarkTarget, addr string, elapsed time.Duration) {
+	if h == nil {
+		return
+	}
+	for _, target := range targets {
+		run := h.run(AfterTestHook, target, addr)
+		run.Duration = elapsed.Round(time.Millisecond).String()
+		if err := RunHooks(target.After, run, h.Logger); err != nil {
+			h.fail(err)
+		}
+	}
+}
+
+func (h *TestHooks) run(hook string, target BenchmarkTarget, addr string) *HookRun {
+	return &HookRun{
+		Hook:     hook,
+		RunID:    h.RunID,
+		Targets:  []string{addr},
+		Test:     target.Name,
+		TestType: target.Type,
+	}
+}
+
+func (h *TestHooks) fail(err error) {
+	h.l.Lock()
+	defer h.l.Unlock()
+	h.errs = append(h.errs, err)
+}
+
+// Err returns the errors of the hooks which failed the run
+func (h *TestHooks) Err() error {
+	if h == nil {
+		return nil
+	}
+	h.l.Lock()
+	defer h.l.Unlock()
+	return errors.Join(h.errs...)
+}
+
+func runHook(hook *HookConfig, run *HookRun) error {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.timeout())
+	defer cancel()
+	cmd := exec.CommandContext(ctx, hook.Command[0], hook.Command[1:]...)
+	cmd.Env = append(os.Environ(), run.Environ()...)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		err = fmt.Errorf("timed out after %v", hook.timeout())
+	}
+	out = bytes.TrimSpace(out)
+	if len(out) > hookOutputLimit {
+		out = out[len(out)-hookOutputLimit:]
+	}
+	if len(out) > 0 {
+		return fmt.Errorf("%v: %s", err, out)
+	}
+	return err
+}
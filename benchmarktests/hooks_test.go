@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestRunHooks(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	logger := hclog.NewNullLogger()
+	record := func(line string) *HookConfig {
+		return &HookConfig{Command: []string{"/bin/sh", "-c", "echo \"" + line + "\" >> " + out}}
+	}
+
+	passed := true
+	run := &HookRun{Hook: AfterTestHook, RunID: "run-1", Targets: []string{"http://a:8200", "http://b:8200"}, Duration: "1m0s", Passed: &passed, Test: "kvv2_read_test", TestType: "kvv2_read"}
+	if err := RunHooks([]*HookConfig{record("$VAULT_BENCHMARK_HOOK $VAULT_BENCHMARK_RUN_ID $VAULT_BENCHMARK_TARGETS $VAULT_BENCHMARK_DURATION $VAULT_BENCHMARK_PASSED $VAULT_BENCHMARK_TEST_NAME $VAULT_BENCHMARK_TEST_TYPE")}, run, logger); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "after_test run-1 http://a:8200,http://b:8200 1m0s true kvv2_read_test kvv2_read\n"; string(got) != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	// Hooks before the attack stop at the first failure, while those which
+	// continue on failure don't count as failed
+	os.Remove(out)
+	fail := &HookConfig{Command: []string{"/bin/sh", "-c", "echo flush failed >&2; exit 3"}}
+	ignored := &HookConfig{Command: []string{"/bin/sh", "-c", "exit 1"}, OnFailure: HookFailureContinue}
+	err = RunHooks([]*HookConfig{ignored, record("first"), fail, record("second")}, &HookRun{Hook: BeforeRunHook}, logger)
+	if err == nil || !strings.Contains(err.Error(), "exit status 3: flush failed") {
+		t.Fatalf("expected the hook to fail with its output, got: %v", err)
+	}
+	if got, _ := os.ReadFile(out); string(got) != "first\n" {
+		t.Fatalf("expected hooks after the failure not to run, got %q", got)
+	}
+
+	// Hooks after the attack all run
+	os.Remove(out)
+	err = RunHooks([]*HookConfig{fail, record("first"), fail, record("second")}, &HookRun{Hook: AfterRunHook}, logger)
+	if err == nil || strings.Count(err.Error(), "after_run hook") != 2 {
+		t.Fatalf("expected both failed hooks to be reported, got: %v", err)
+	}
+	if got, _ := os.ReadFile(out); string(got) != "first\nsecond\n" {
+		t.Fatalf("expected all hooks to run, got %q", got)
+	}
+
+	err = RunHooks([]*HookConfig{{Command: []string{"sleep", "5"}, Timeout: "50ms"}}, &HookRun{Hook: BeforeTestHook}, logger)
+	if err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+		t.Fatalf("expected the hook to time out, got: %v", err)
+	}
+}
+
+func TestTestHooks_Staggered(t *testing.T) {
+	// The time of the first and last request of each test
+	var l sync.Mutex
+	first, last := make(map[string]time.Time), make(map[string]time.Time)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		now, name := time.Now(), strings.TrimPrefix(req.URL.Path, "/v1/")
+		l.Lock()
+		if _, ok := first[name]; !ok {
+			first[name] = now
+		}
+		last[name] = now
+		l.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	record := &HookConfig{Command: []string{"/bin/sh", "-c", "echo \"$(date +%s%N) $VAULT_BENCHMARK_HOOK $VAULT_BENCHMARK_TEST_NAME $VAULT_BENCHMARK_TARGETS $VAULT_BENCHMARK_DURATION\" >> " + out}}
+	test := func(name string) BenchmarkTarget {
+		return BenchmarkTarget{
+			Name:   name,
+			Weight: 100,
+			Before: []*HookConfig{record},
+			After:  []*HookConfig{record},
+			Target: func(client *api.Client) vegeta.Target {
+				return vegeta.Target{Method: "GET", URL: client.Address() + "/v1/" + name}
+			},
+		}
+	}
+	// The second test starts once the first has been attacked for a while,
+	// and ends before it
+	second := test("second")
+	second.StartAfter, second.Duration = "300ms", "200ms"
+	tm := &TargetMulti{targets: []BenchmarkTarget{test("first"), second}}
+	hooks := &TestHooks{RunID: "run-1", Logger: hclog.NewNullLogger()}
+	started := time.Now()
+	if _, err := Attack(tm, client, &AttackConfig{Duration: 600 * time.Millisecond, RPS: 50, Workers: 2, TestHooks: hooks}); err != nil {
+		t.Fatal(err)
+	}
+	if err := hooks.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	ran := make(map[string]time.Time)
+	for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 5 || fields[3] != srv.URL {
+			t.Fatalf("unexpected hook environment: %q", line)
+		}
+		nanos, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hook := fields[1] + " " + fields[2]
+		order = append(order, hook)
+		ran[hook] = time.Unix(0, nanos)
+		if hook == "before_test second" && fields[4] != "200ms" {
+			t.Fatalf("expected the before hook to be told the planned duration of the test, got: %q", line)
+		}
+	}
+	expected := []string{"before_test first", "before_test second", "after_test second", "after_test first"}
+	if strings.Join(order, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected hooks to run in order %v, got %v", expected, order)
+	}
+	for _, name := range []string{"first", "second"} {
+		if first[name].IsZero() {
+			t.Fatalf("expected requests for %v", name)
+		}
+		if first[name].Before(ran["before_test "+name]) || last[name].After(ran["after_test "+name]) {
+			t.Errorf("expected the requests of %v from %v to %v to be sent between its hooks at %v and %v", name, first[name], last[name], ran["before_test "+name], ran["after_test "+name])
+		}
+	}
+	if ran["before_test second"].Sub(started) < 300*time.Millisecond {
+		t.Errorf("expected the before hooks of the second test to run after its start offset")
+	}
+}
+
+func TestHookConfig_Validate(t *testing.T) {
+	cases := map[string]*HookConfig{
+		"command must be set":       {},
+		"invalid timeout":           {Command: []string{"true"}, Timeout: "soon"},
+		"timeout must be positive":  {Command: []string{"true"}, Timeout: "0s"},
+		"on_failure must be one of": {Command: []string{"true"}, OnFailure: "retry"},
+	}
+	for expected, config := range cases {
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got: %v", expected, err)
+		}
+	}
+	if err := (&HookConfig{Command: []string{"true"}, Timeout: "1m", OnFailure: HookFailureContinue}).Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"sync/atomic"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// inFlightLimiter tracks the requests of an open loop attack which have been
+// sent but not yet answered. The cap itself is enforced by the maximum
+// number of attack workers; the limiter counts how many requests were due
+// while the cap was reached and so had their submission delayed.
+type inFlightLimiter struct {
+	max      int64
+	inFlight atomic.Int64
+
+	// throttled is approximate: it is sampled by the pacer when each hit
+	// falls due, so requests answered between the check and the hit being
+	// sent may be counted, and a hit is only counted once however long it
+	// waits
+	throttled atomic.Uint64
+}
+
+// targeter wraps tr to count each request as in flight once a worker starts
+// sending it
+func (l *inFlightLimiter) targeter(tr vegeta.Targeter) vegeta.Targeter {
+	return func(tgt *vegeta.Target) error {
+		l.inFlight.Add(1)
+		return tr(tgt)
+	}
+}
+
+// done marks a request as answered
+func (l *inFlightLimiter) done() {
+	l.inFlight.Add(-1)
+}
+
+func (l *inFlightLimiter) pacer(p vegeta.Pacer) vegeta.Pacer {
+	return &inFlightPacer{Pacer: p, limiter: l}
+}
+
+// inFlightPacer paces hits with the wrapped Pacer, recording each hit which
+// is due while every allowed request is still in flight
+type inFlightPacer struct {
+	vegeta.Pacer
+	limiter *inFlightLimiter
+
+	// The pacer is only called from the attacker's pacing goroutine, so
+	// this does not need to be atomic
+	lastThrottled uint64
+}
+
+func (p *inFlightPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	wait, stop := p.Pacer.Pace(elapsed, hits)
+	if !stop && wait <= 0 && p.lastThrottled != hits+1 && p.limiter.inFlight.Load() >= p.limiter.max {
+		p.limiter.throttled.Add(1)
+		p.lastThrottled = hits + 1
+	}
+	return wait, stop
+}
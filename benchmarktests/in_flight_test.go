@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestInFlightLimiter_CountsThrottledHits(t *testing.T) {
+	limiter := &inFlightLimiter{max: 2}
+	targeter := limiter.targeter(func(*vegeta.Target) error { return nil })
+	pacer := limiter.pacer(vegeta.Rate{Freq: 10, Per: time.Second})
+
+	// Two requests in flight, with the third already due
+	targeter(&vegeta.Target{})
+	targeter(&vegeta.Target{})
+	for i := 0; i < 3; i++ {
+		// The attacker may ask again for the same hit; it is only counted once
+		pacer.Pace(time.Second, 2)
+	}
+	if got := limiter.throttled.Load(); got != 1 {
+		t.Fatalf("expected 1 throttled hit, got: %d", got)
+	}
+
+	// Hits which aren't due yet aren't throttled
+	pacer.Pace(0, 3)
+	if got := limiter.throttled.Load(); got != 1 {
+		t.Fatalf("expected hits not yet due to not be throttled, got: %d", got)
+	}
+
+	// Once a request completes there is room for the next
+	limiter.done()
+	pacer.Pace(time.Second, 4)
+	if got := limiter.throttled.Load(); got != 1 {
+		t.Fatalf("expected no throttling below the cap, got: %d", got)
+	}
+}
+
+func TestOpenLoopAttack_MaxInFlightAboveWorkers(t *testing.T) {
+	var inFlight, peak atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	// A cap above the workers starts more workers, up to the cap, while
+	// every worker is busy
+	config := &AttackConfig{Workers: 2, MaxInFlight: 4, Duration: 300 * time.Millisecond}
+	limiter := &inFlightLimiter{max: int64(config.MaxInFlight)}
+	targeter := func(tgt *vegeta.Target) error {
+		tgt.Method = http.MethodGet
+		tgt.URL = srv.URL
+		return nil
+	}
+	results := openLoopAttack(nil, targeter, vegeta.Rate{Freq: 200, Per: time.Second}, limiter, config, nil)
+	for range results {
+	}
+	if got := peak.Load(); got <= 2 || got > 4 {
+		t.Fatalf("expected more than 2 and at most 4 requests in flight, got: %d", got)
+	}
+	if limiter.throttled.Load() == 0 {
+		t.Fatalf("expected requests due while the cap was reached to be throttled")
+	}
+}
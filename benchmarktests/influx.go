@@ -0,0 +1,227 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InfluxWriter writes interval reports in InfluxDB line protocol, either to
+// a file or to the write API of an InfluxDB server. Every test of each
+// interval is written as a bench_interval point, with its response status
+// codes as bench_interval_responses points, tagged with the run ID, target,
+// test, phase and the labels of the run.
+//
+// Like RemoteWriter, points sent to a server are pushed from a separate
+// goroutine so a slow server can't hold up the attack; Close waits for the
+// queued points to be sent.
+type InfluxWriter struct {
+	runID  string
+	labels map[string]string
+
+	// w is set when writing to a file
+	w io.Writer
+
+	// url, token and client are set when writing to a server
+	url    string
+	token  string
+	client *http.Client
+	queue  chan []byte
+	done   chan struct{}
+
+	l   sync.Mutex
+	err error
+}
+
+// NewInfluxWriter returns a writer which appends the points of each
+// interval to w as soon as it completes
+func NewInfluxWriter(w io.Writer, runID string, labels map[string]string) *InfluxWriter {
+	return &InfluxWriter{runID: runID, labels: labels, w: w}
+}
+
+// NewInfluxHTTPWriter returns a writer which posts the points of each
+// interval to url, the full write URL of the server such as
+// http://localhost:8086/api/v2/write?org=bench&bucket=bench. The token,
+// when given, is sent in the Authorization header.
+func NewInfluxHTTPWriter(url, token, runID string, labels map[string]string) *InfluxWriter {
+	iw := &InfluxWriter{
+		runID:  runID,
+		labels: labels,
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: 30 * time.Second},
+		queue:  make(chan []byte, remoteWriteQueueSize),
+		done:   make(chan struct{}),
+	}
+	go iw.run()
+	return iw
+}
+
+// Write converts the report to line protocol and writes it out, or queues
+// it to be pushed to the server. Only the first error is kept; it is
+// returned by Err.
+func (iw *InfluxWriter) Write(report *IntervalReport) error {
+	lines := influxLines(report, iw.runID, iw.labels)
+
+	if iw.queue != nil {
+		select {
+		case iw.queue <- lines:
+			return nil
+		default:
+			err := fmt.Errorf("influx write queue is full, dropping interval ending %v", report.End)
+			iw.setErr(err)
+			return err
+		}
+	}
+
+	iw.l.Lock()
+	defer iw.l.Unlock()
+	_, err := iw.w.Write(lines)
+	if err == nil {
+		if f, ok := iw.w.(interface{ Sync() error }); ok {
+			err = f.Sync()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("error writing influx points: %v", err)
+		if iw.err == nil {
+			iw.err = err
+		}
+	}
+	return err
+}
+
+// Close sends any queued points and stops the writer
+func (iw *InfluxWriter) Close() {
+	if iw.queue != nil {
+		close(iw.queue)
+		<-iw.done
+	}
+}
+
+// Err returns the first error encountered while writing interval reports
+func (iw *InfluxWriter) Err() error {
+	iw.l.Lock()
+	defer iw.l.Unlock()
+	return iw.err
+}
+
+func (iw *InfluxWriter) setErr(err error) {
+	iw.l.Lock()
+	defer iw.l.Unlock()
+	if iw.err == nil {
+		iw.err = err
+	}
+}
+
+func (iw *InfluxWriter) run() {
+	defer close(iw.done)
+	for lines := range iw.queue {
+		if err := iw.push(lines); err != nil {
+			iw.setErr(err)
+		}
+	}
+}
+
+func (iw *InfluxWriter) push(lines []byte) error {
+	req, err := http.NewRequest(http.MethodPost, iw.url, bytes.NewReader(lines))
+	if err != nil {
+		return fmt.Errorf("error creating influx write request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if iw.token != "" {
+		req.Header.Set("Authorization", "Token "+iw.token)
+	}
+
+	resp, err := iw.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error pushing influx points: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("error pushing influx points: %v: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// influxLines converts the metrics of every test in the report into line
+// protocol, timestamped at the end of the interval
+func influxLines(report *IntervalReport, runID string, labels map[string]string) []byte {
+	names := make([]string, 0, len(report.Metrics))
+	for name := range report.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	timestamp := strconv.FormatInt(report.End.UnixNano(), 10)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		m := report.Metrics[name]
+
+		tagList := [][2]string{
+			{"phase", report.Phase},
+			{"run_id", runID},
+			{"target", report.TargetAddr},
+			{"test", name},
+		}
+		for label, value := range labels {
+			tagList = append(tagList, [2]string{label, value})
+		}
+		// Influx writes points fastest with their tags sorted by key
+		sort.Slice(tagList, func(i, j int) bool { return tagList[i][0] < tagList[j][0] })
+
+		var tags strings.Builder
+		for _, tag := range tagList {
+			// Line protocol doesn't allow empty tag values
+			if tag[1] != "" {
+				tags.WriteString("," + tag[0] + "=" + influxEscape(tag[1]))
+			}
+		}
+
+		fields := []string{
+			"requests=" + strconv.FormatUint(m.Requests, 10) + "i",
+			"rate=" + influxFloat(m.Rate),
+			"throughput=" + influxFloat(m.Throughput),
+			"success_ratio=" + influxFloat(m.Success),
+			"latency_mean_seconds=" + influxFloat(m.Latencies.Mean.Seconds()),
+			"latency_p50_seconds=" + influxFloat(m.Latencies.P50.Seconds()),
+			"latency_p90_seconds=" + influxFloat(m.Latencies.P90.Seconds()),
+			"latency_p95_seconds=" + influxFloat(m.Latencies.P95.Seconds()),
+			"latency_p99_seconds=" + influxFloat(m.Latencies.P99.Seconds()),
+			"latency_max_seconds=" + influxFloat(m.Latencies.Max.Seconds()),
+		}
+		fmt.Fprintf(&buf, "bench_interval%s %s %s\n", tags.String(), strings.Join(fields, ","), timestamp)
+
+		codes := make([]string, 0, len(m.StatusCodes))
+		for code := range m.StatusCodes {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			fmt.Fprintf(&buf, "bench_interval_responses%s,code=%s count=%di %s\n", tags.String(), influxEscape(code), m.StatusCodes[code], timestamp)
+		}
+	}
+	return buf.Bytes()
+}
+
+// influxEscaper escapes the characters which are special in tag values
+var influxEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+func influxEscape(s string) string {
+	return influxEscaper.Replace(s)
+}
+
+func influxFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
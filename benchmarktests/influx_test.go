@@ -0,0 +1,108 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func testInfluxReport() *IntervalReport {
+	end := time.Unix(1700000000, 0)
+	return &IntervalReport{
+		TargetAddr: "http://127.0.0.1:8200",
+		Start:      end.Add(-time.Minute),
+		End:        end,
+		Metrics: map[string]*vegeta.Metrics{
+			"kv read": {
+				Requests:    10,
+				Rate:        2.5,
+				Success:     0.9,
+				StatusCodes: map[string]int{"200": 9, "500": 1},
+			},
+		},
+	}
+}
+
+func TestInfluxLines(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(influxLines(testInfluxReport(), "run-1", map[string]string{"env": "ci"}))), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a point for the test and each status code, got: %q", lines)
+	}
+
+	if !strings.HasPrefix(lines[0], `bench_interval,env=ci,run_id=run-1,target=http://127.0.0.1:8200,test=kv\ read requests=10i,rate=2.5,`) {
+		t.Fatalf("unexpected point: %v", lines[0])
+	}
+	if !strings.Contains(lines[0], ",success_ratio=0.9,") {
+		t.Fatalf("expected the success ratio in: %v", lines[0])
+	}
+	if !strings.HasSuffix(lines[0], " 1700000000000000000") {
+		t.Fatalf("expected the point to be timestamped at the end of the interval: %v", lines[0])
+	}
+	if lines[2] != `bench_interval_responses,env=ci,run_id=run-1,target=http://127.0.0.1:8200,test=kv\ read,code=500 count=1i 1700000000000000000` {
+		t.Fatalf("unexpected point: %v", lines[2])
+	}
+}
+
+func TestInfluxWriter_File(t *testing.T) {
+	var buf bytes.Buffer
+	iw := NewInfluxWriter(&buf, "run-1", nil)
+	if err := iw.Write(testInfluxReport()); err != nil {
+		t.Fatal(err)
+	}
+	iw.Close()
+
+	if got := strings.Count(buf.String(), "\n"); got != 3 {
+		t.Fatalf("expected 3 points to be written, got: %v", got)
+	}
+}
+
+func TestInfluxWriter_HTTP(t *testing.T) {
+	var auth string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth = req.Header.Get("Authorization")
+		body, _ = io.ReadAll(req.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	iw := NewInfluxHTTPWriter(srv.URL+"/api/v2/write?org=bench&bucket=bench", "secret", "run-1", nil)
+	if err := iw.Write(testInfluxReport()); err != nil {
+		t.Fatal(err)
+	}
+	iw.Close()
+	if err := iw.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if auth != "Token secret" {
+		t.Fatalf("unexpected authorization header: %q", auth)
+	}
+	if !bytes.Equal(body, influxLines(testInfluxReport(), "run-1", nil)) {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
+func TestInfluxWriter_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "bucket not found", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	iw := NewInfluxHTTPWriter(srv.URL, "", "run-1", nil)
+	_ = iw.Write(testInfluxReport())
+	iw.Close()
+
+	if err := iw.Err(); err == nil || !strings.Contains(err.Error(), "bucket not found") {
+		t.Fatalf("expected the server's error, got: %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// IntervalReport summarizes the results which completed during one interval
+// of a long running attack
+type IntervalReport struct {
+	TargetAddr string                     `json:"target_addr"`
+	Phase      string                     `json:"phase,omitempty"`
+	Start      time.Time                  `json:"start"`
+	End        time.Time                  `json:"end"`
+	Metrics    map[string]*vegeta.Metrics `json:"metrics"`
+}
+
+// IntervalOutput is a destination for interval reports. Outputs may be
+// shared between concurrent attacks, so must be safe for concurrent use.
+type IntervalOutput interface {
+	Write(report *IntervalReport) error
+}
+
+// MultiIntervalOutput writes every interval report to each of its outputs
+type MultiIntervalOutput []IntervalOutput
+
+func (m MultiIntervalOutput) Write(report *IntervalReport) error {
+	var firstErr error
+	for _, out := range m {
+		if err := out.Write(report); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// IntervalWriter writes interval reports as newline delimited JSON as soon
+// as each interval completes, so the results of a soak test survive even if
+// the run never finishes. It is safe to share between concurrent attacks.
+type IntervalWriter struct {
+	l   sync.Mutex
+	w   io.Writer
+	enc *json.Encoder
+	err error
+}
+
+func NewIntervalWriter(w io.Writer) *IntervalWriter {
+	return &IntervalWriter{w: w, enc: json.NewEncoder(w)}
+}
+
+// Write encodes the report and, when writing to a file, syncs it to disk.
+// Only the first error is kept; it is returned by Err.
+func (iw *IntervalWriter) Write(report *IntervalReport) error {
+	iw.l.Lock()
+	defer iw.l.Unlock()
+
+	err := iw.enc.Encode(report)
+	if err == nil {
+		if f, ok := iw.w.(interface{ Sync() error }); ok {
+			err = f.Sync()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("error writing interval report: %v", err)
+		if iw.err == nil {
+			iw.err = err
+		}
+	}
+	return err
+}
+
+// Err returns the first error encountered while writing interval reports
+func (iw *IntervalWriter) Err() error {
+	iw.l.Lock()
+	defer iw.l.Unlock()
+	return iw.err
+}
+
+// intervalRecorder groups results into fixed intervals by the time they
+// completed, writing out each interval once a later result is seen
+type intervalRecorder struct {
+	out      IntervalOutput
+	interval time.Duration
+	start    time.Time
+	names    []string
+	metrics  map[string]*vegeta.Metrics
+}
+
+func newIntervalRecorder(out IntervalOutput, interval time.Duration, began time.Time, names []string) *intervalRecorder {
+	ir := &intervalRecorder{
+		out:      out,
+		interval: interval,
+		start:    began,
+		names:    names,
+	}
+	ir.reset()
+	return ir
+}
+
+func (ir *intervalRecorder) reset() {
+	ir.metrics = make(map[string]*vegeta.Metrics, len(ir.names))
+	for _, name := range ir.names {
+		ir.metrics[name] = &vegeta.Metrics{}
+	}
+}
+
+func (ir *intervalRecorder) add(rpt *Reporter, name string, result *vegeta.Result) {
+	completed := result.Timestamp.Add(result.Latency)
+	if !completed.Before(ir.start.Add(ir.interval)) {
+		ir.flush(rpt)
+		ir.start = ir.start.Add(completed.Sub(ir.start).Truncate(ir.interval))
+	}
+
+	ir.metrics["total"].Add(result)
+	if name != "total" {
+		ir.metrics[name].Add(result)
+	}
+}
+
+// flush writes out the current interval, if any results were recorded
+// during it, and starts a new one
+func (ir *intervalRecorder) flush(rpt *Reporter) {
+	if ir.metrics["total"].Requests == 0 {
+		return
+	}
+
+	for _, m := range ir.metrics {
+		m.Close()
+	}
+	ir.out.Write(&IntervalReport{
+		TargetAddr: rpt.clientAddr,
+		Phase:      rpt.phase,
+		Start:      ir.start,
+		End:        ir.start.Add(ir.interval),
+		Metrics:    ir.metrics,
+	})
+	ir.reset()
+}
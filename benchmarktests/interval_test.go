@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestReporter_Intervals(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "read", Method: "GET", PathPrefix: "/v1/secret/data"},
+	}}
+	rpt := newReporter(tm, nil)
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rpt.startWarmup(began, 0)
+
+	var buf bytes.Buffer
+	rpt.trackIntervals(NewIntervalWriter(&buf), time.Minute)
+
+	// Two results in the first minute, none in the second and one in the
+	// third
+	for _, offset := range []time.Duration{10 * time.Second, 50 * time.Second, 150 * time.Second} {
+		rpt.Add(&vegeta.Result{
+			Method:    "GET",
+			URL:       "N/A/v1/secret/data/foo",
+			Code:      200,
+			Timestamp: began.Add(offset),
+			Latency:   time.Millisecond,
+		})
+	}
+	rpt.Close()
+
+	var reports []IntervalReport
+	d := json.NewDecoder(&buf)
+	for d.More() {
+		var report IntervalReport
+		if err := d.Decode(&report); err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		reports = append(reports, report)
+	}
+
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 interval reports, got: %d", len(reports))
+	}
+	if !reports[0].Start.Equal(began) || !reports[1].Start.Equal(began.Add(2*time.Minute)) {
+		t.Fatalf("unexpected interval starts: %v, %v", reports[0].Start, reports[1].Start)
+	}
+	if got := reports[0].Metrics["read"].Requests; got != 2 {
+		t.Fatalf("expected 2 requests in the first interval, got: %d", got)
+	}
+	if got := reports[1].Metrics["total"].Requests; got != 1 {
+		t.Fatalf("expected 1 request in the last interval, got: %d", got)
+	}
+	if got := rpt.metrics["total"].Requests; got != 3 {
+		t.Fatalf("expected the final report to contain all 3 requests, got: %d", got)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// junitSuiteName is the name of the suite holding checks of the whole run,
+// and of the report itself
+const junitSuiteName = "vault-benchmark"
+
+// JUnitReport collects the outcome of a run as JUnit test cases, so CI
+// systems can show which tests and objectives passed. Every test, per
+// phase, is a suite with a case for its results and one for each of its
+// SLO objectives and its regression check against a baseline.
+type JUnitReport struct {
+	suites []*junitSuite
+}
+
+type junitSuites struct {
+	XMLName  xml.Name      `xml:"testsuites"`
+	Name     string        `xml:"name,attr"`
+	Tests    int           `xml:"tests,attr"`
+	Failures int           `xml:"failures,attr"`
+	Suites   []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Cases    []*junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Output    string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// NewJUnitReport returns a report with a passing case for the results of
+// every test in rpts, combined by phase as by DiffReports
+func NewJUnitReport(rpts []*Reporter) *JUnitReport {
+	j := &JUnitReport{}
+	for _, d := range DiffReports(nil, rpts) {
+		s := d.New
+		j.add(junitLabel(d.Phase, d.Test), "results", "", fmt.Sprintf(
+			"requests %d, throughput %.2f/s, p50 %v, p99 %v, error rate %.2f%%",
+			s.Requests, s.Throughput, s.P50, s.P99, s.ErrorRate*100))
+	}
+	return j
+}
+
+// AddSLOResults adds a case for every checked SLO objective
+func (j *JUnitReport) AddSLOResults(results []*SLOResult) {
+	for _, result := range results {
+		var failure string
+		if !result.Passed {
+			failure = fmt.Sprintf("slo %v missed: %v", result.Objective, result.Actual)
+		}
+		j.add(junitLabel(result.Phase, result.Test), "slo "+result.Objective, failure, "actual "+result.Actual)
+	}
+}
+
+// AddRegressions adds a case for every test compared against a baseline,
+// failing with the regressions of the test
+func (j *JUnitReport) AddRegressions(diffs []*TestDiff, regressions []*Regression) {
+	for _, d := range diffs {
+		if d.Base == nil || d.New == nil {
+			continue
+		}
+		var failures []string
+		for _, regression := range regressions {
+			if regression.Phase == d.Phase && regression.Test == d.Test {
+				failures = append(failures, regression.String())
+			}
+		}
+		j.add(junitLabel(d.Phase, d.Test), "no regression from baseline", strings.Join(failures, "\n"), "")
+	}
+}
+
+// AddErrorBudget adds a case for the error budget of the run
+func (j *JUnitReport) AddErrorBudget(exceeded bool) {
+	var failure string
+	if exceeded {
+		failure = "error budget exceeded"
+	}
+	j.add(junitSuiteName, "error budget", failure, "")
+}
+
+func (j *JUnitReport) add(suiteName, name, failure, output string) {
+	var suite *junitSuite
+	for _, s := range j.suites {
+		if s.Name == suiteName {
+			suite = s
+			break
+		}
+	}
+	if suite == nil {
+		suite = &junitSuite{Name: suiteName}
+		j.suites = append(j.suites, suite)
+	}
+
+	c := &junitCase{ClassName: suiteName, Name: name, Output: output}
+	if failure != "" {
+		// The message is shown on one line, with the details below it
+		message, _, _ := strings.Cut(failure, "\n")
+		c.Failure = &junitFailure{Message: message, Text: failure}
+		suite.Failures++
+	}
+	suite.Tests++
+	suite.Cases = append(suite.Cases, c)
+}
+
+// Write writes the report as JUnit XML
+func (j *JUnitReport) Write(w io.Writer) error {
+	report := &junitSuites{Name: junitSuiteName, Suites: j.suites}
+	for _, suite := range j.suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitLabel(phase, test string) string {
+	if phase == "" {
+		return test
+	}
+	return test + " (" + phase + ")"
+}
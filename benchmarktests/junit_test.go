@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestJUnitReport(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 200, Timestamp: time.Now(), Latency: time.Millisecond})
+	rpt.Close()
+
+	junit := NewJUnitReport([]*Reporter{rpt})
+	junit.AddSLOResults([]*SLOResult{
+		{Test: "read", Objective: "p99 <= 50ms", Actual: "1ms", Passed: true},
+		{Test: "read", Objective: "throughput >= 100.00/s", Actual: "1.00/s"},
+	})
+	diffs := []*TestDiff{{Test: "read", Base: &DiffStats{}, New: &DiffStats{}}, {Test: "write", New: &DiffStats{}}}
+	junit.AddRegressions(diffs, []*Regression{{Test: "read", Metric: "p99", Base: "1ms", New: "2ms", Change: 100, Limit: 10, Unit: "%"}})
+	junit.AddErrorBudget(false)
+
+	var buf bytes.Buffer
+	if err := junit.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Fatalf("expected an XML header, got:\n%v", buf.String())
+	}
+
+	var report junitSuites
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Tests != 6 || report.Failures != 2 {
+		t.Fatalf("expected 6 tests with 2 failures, got %d with %d", report.Tests, report.Failures)
+	}
+
+	var got []string
+	for _, suite := range report.Suites {
+		for _, c := range suite.Cases {
+			outcome := "pass"
+			if c.Failure != nil {
+				outcome = "fail: " + c.Failure.Message
+			}
+			got = append(got, suite.Name+" / "+c.Name+" "+outcome)
+		}
+	}
+	want := []string{
+		"total / results pass",
+		"read / results pass",
+		"read / slo p99 <= 50ms pass",
+		"read / slo throughput >= 100.00/s fail: slo throughput >= 100.00/s missed: 1.00/s",
+		"read / no regression from baseline fail: read p99 regressed from 1ms to 2ms by 100.00%, more than the limit of 10.00%",
+		"vault-benchmark / error budget pass",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// UniformKeyDistribution picks every key with the same probability
+	UniformKeyDistribution = "uniform"
+
+	// ZipfianKeyDistribution picks the key of rank k with a probability
+	// proportional to 1/k^skew, so a few keys receive most requests
+	ZipfianKeyDistribution = "zipfian"
+
+	// HotSetKeyDistribution sends a fixed share of requests to a small set
+	// of hot keys and spreads the rest evenly over the remaining keys
+	HotSetKeyDistribution = "hot_set"
+)
+
+// KeyDistributionConfig is the key_distribution block accepted by tests
+// which pick one of several keys for every request
+type KeyDistributionConfig struct {
+	Type       string  `hcl:"type,optional"`
+	Skew       float64 `hcl:"skew,optional"`
+	HotKeys    int     `hcl:"hot_keys,optional"`
+	HotPercent float64 `hcl:"hot_percent,optional"`
+}
+
+// keySelector picks the index, between 0 and n-1, of the key the next
+// request is for. It must be safe for concurrent use.
+type keySelector interface {
+	next() int
+}
+
+// newKeySelector returns a selector over n keys following the configured
+// distribution. A nil config selects keys uniformly.
+func newKeySelector(config *KeyDistributionConfig, n int) (keySelector, error) {
+	if config == nil {
+		return uniformKeys(n), nil
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("key distribution requires at least one key")
+	}
+
+	switch config.Type {
+	case UniformKeyDistribution, "":
+		return uniformKeys(n), nil
+	case ZipfianKeyDistribution:
+		skew := config.Skew
+		if skew == 0 {
+			skew = 0.99
+		}
+		if skew < 0 {
+			return nil, fmt.Errorf("zipfian skew must not be negative")
+		}
+		return newZipfianKeys(n, skew), nil
+	case HotSetKeyDistribution:
+		switch {
+		case config.HotKeys <= 0 || config.HotKeys > n:
+			return nil, fmt.Errorf("hot_keys must be between 1 and the number of keys (%d)", n)
+		case config.HotPercent < 0 || config.HotPercent > 100:
+			return nil, fmt.Errorf("hot_percent must be between 0 and 100")
+		}
+		percent := config.HotPercent
+		if percent == 0 {
+			percent = 90
+		}
+		return &hotSetKeys{n: n, hot: config.HotKeys, hotRatio: percent / 100}, nil
+	default:
+		return nil, fmt.Errorf("unknown key distribution: %v", config.Type)
+	}
+}
+
+type uniformKeys int
+
+func (u uniformKeys) next() int {
+	return rand.Intn(int(u))
+}
+
+// zipfianKeys samples ranks from a precomputed cumulative distribution, so
+// any skew can be used and no state is shared between callers
+type zipfianKeys struct {
+	cdf []float64
+}
+
+func newZipfianKeys(n int, skew float64) *zipfianKeys {
+	cdf := make([]float64, n)
+	var total float64
+	for i := 0; i < n; i++ {
+		total += 1 / math.Pow(float64(i+1), skew)
+		cdf[i] = total
+	}
+	for i := range cdf {
+		cdf[i] /= total
+	}
+	return &zipfianKeys{cdf: cdf}
+}
+
+func (z *zipfianKeys) next() int {
+	i := sort.SearchFloat64s(z.cdf, rand.Float64())
+	if i >= len(z.cdf) {
+		i = len(z.cdf) - 1
+	}
+	return i
+}
+
+type hotSetKeys struct {
+	n        int
+	hot      int
+	hotRatio float64
+}
+
+func (h *hotSetKeys) next() int {
+	if h.hot == h.n || rand.Float64() < h.hotRatio {
+		return rand.Intn(h.hot)
+	}
+	return h.hot + rand.Intn(h.n-h.hot)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import "testing"
+
+func TestKeySelector_Distributions(t *testing.T) {
+	const (
+		numKeys = 100
+		samples = 100000
+	)
+
+	sample := func(config *KeyDistributionConfig) []int {
+		selector, err := newKeySelector(config, numKeys)
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+		counts := make([]int, numKeys)
+		for i := 0; i < samples; i++ {
+			key := selector.next()
+			if key < 0 || key >= numKeys {
+				t.Fatalf("key %d out of range", key)
+			}
+			counts[key]++
+		}
+		return counts
+	}
+
+	uniform := sample(nil)
+	if uniform[0] > samples/numKeys*2 {
+		t.Fatalf("expected uniform keys to be picked evenly, first key picked %d times", uniform[0])
+	}
+
+	// With a skew of 1 the hottest key receives about 19% of requests
+	// across 100 keys
+	zipfian := sample(&KeyDistributionConfig{Type: ZipfianKeyDistribution, Skew: 1})
+	if zipfian[0] < samples/6 || zipfian[0] < zipfian[numKeys-1]*50 {
+		t.Fatalf("expected the first key to be far hotter than the last: %d vs %d", zipfian[0], zipfian[numKeys-1])
+	}
+
+	hotSet := sample(&KeyDistributionConfig{Type: HotSetKeyDistribution, HotKeys: 10, HotPercent: 80})
+	hot := 0
+	for _, count := range hotSet[:10] {
+		hot += count
+	}
+	if hot < samples*75/100 || hot > samples*85/100 {
+		t.Fatalf("expected about 80%% of requests to go to the hot keys, got %d of %d", hot, samples)
+	}
+
+	for _, config := range []*KeyDistributionConfig{
+		{Type: "pareto"},
+		{Type: HotSetKeyDistribution},
+		{Type: HotSetKeyDistribution, HotKeys: 10, HotPercent: 120},
+		{Type: ZipfianKeyDistribution, Skew: -1},
+	} {
+		if _, err := newKeySelector(config, numKeys); err == nil {
+			t.Fatalf("expected error for %+v", config)
+		}
+	}
+}
+
+func TestTransitKeyNames(t *testing.T) {
+	if names := transitKeyNames("test", 1); len(names) != 1 || names[0] != "test" {
+		t.Fatalf("expected the configured name for a single key, got: %v", names)
+	}
+	if names := transitKeyNames("test", 3); len(names) != 3 || names[2] != "test-3" {
+		t.Fatalf("expected numbered key names, got: %v", names)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// labelNameRe matches label names which are valid in every output labels
+// are written to
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels are the names of labels outputs already add to their
+// series, which run labels may not replace
+var reservedLabels = []string{"code", "phase", "quantile", "run_id", "target", "test"}
+
+// ValidateLabels checks the labels of a run can be added to every output
+func ValidateLabels(labels map[string]string) error {
+	for _, name := range sortedLabelNames(labels) {
+		switch {
+		case !labelNameRe.MatchString(name):
+			return fmt.Errorf("invalid label name %q: must only contain letters, digits and underscores, and not start with a digit", name)
+		case strings.HasPrefix(name, "__"):
+			return fmt.Errorf("invalid label name %q: names starting with __ are reserved", name)
+		}
+		for _, reserved := range reservedLabels {
+			if name == reserved {
+				return fmt.Errorf("invalid label name %q: already used by the outputs of every run", name)
+			}
+		}
+	}
+	return nil
+}
+
+func sortedLabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatLabels returns the labels as sorted, comma separated name=value
+// pairs
+func formatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for _, name := range sortedLabelNames(labels) {
+		pairs = append(pairs, name+"="+labels[name])
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// SetLabels records the labels of the run the results belong to
+func (r *Reporter) SetLabels(labels map[string]string) {
+	r.labels = labels
+}
+
+// LabeledGatherer returns a gatherer which adds the labels of the run to
+// every metric gathered from g. Labels a metric already has are kept.
+func LabeledGatherer(g prometheus.Gatherer, labels map[string]string) prometheus.Gatherer {
+	names := sortedLabelNames(labels)
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, err := g.Gather()
+		for _, mf := range mfs {
+			for _, m := range mf.Metric {
+				has := make(map[string]bool, len(m.Label))
+				for _, lp := range m.Label {
+					has[lp.GetName()] = true
+				}
+				for _, name := range names {
+					if !has[name] {
+						m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(name), Value: proto.String(labels[name])})
+					}
+				}
+				sort.Slice(m.Label, func(i, j int) bool { return m.Label[i].GetName() < m.Label[j].GetName() })
+			}
+		}
+		return mfs, err
+	})
+}
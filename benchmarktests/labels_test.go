@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestValidateLabels(t *testing.T) {
+	if err := ValidateLabels(map[string]string{"env": "ci", "git_sha": "abc123", "_instance": "m5.large"}); err != nil {
+		t.Fatalf("expected labels to be valid, got: %v", err)
+	}
+	for _, name := range []string{"", "1st", "instance-type", "git.sha", "__name__", "run_id", "test"} {
+		if err := ValidateLabels(map[string]string{name: "value"}); err == nil {
+			t.Fatalf("expected label %q to be invalid", name)
+		}
+	}
+}
+
+func TestLabeledGatherer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests"}, []string{"test", "env"})
+	reg.MustRegister(requests)
+	requests.WithLabelValues("read", "own").Inc()
+
+	mfs, err := LabeledGatherer(reg, map[string]string{"env": "ci", "git_sha": "abc123"}).Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mfs) != 1 || len(mfs[0].Metric) != 1 {
+		t.Fatalf("expected a single metric, got: %v", mfs)
+	}
+
+	var labels []string
+	for _, lp := range mfs[0].Metric[0].Label {
+		labels = append(labels, lp.GetName()+"="+lp.GetValue())
+	}
+	// Labels the metric already has are kept
+	if got := strings.Join(labels, ","); got != "env=own,git_sha=abc123,test=read" {
+		t.Fatalf("unexpected labels: %v", got)
+	}
+}
+
+func TestReporter_Labels(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "read", Method: "GET", PathPrefix: "/v1/secret/data"},
+	}}
+	rpt := newReporter(tm, nil)
+	rpt.SetLabels(map[string]string{"git_sha": "abc123", "env": "ci"})
+	rpt.Close()
+
+	var buf bytes.Buffer
+	if err := rpt.ReportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"labels":{"env":"ci","git_sha":"abc123"}`) {
+		t.Fatalf("expected labels in JSON report, got: %v", buf.String())
+	}
+	rpts, err := FromReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpts[0].labels["env"] != "ci" || rpts[0].labels["git_sha"] != "abc123" {
+		t.Fatalf("expected labels to be read back, got: %v", rpts[0].labels)
+	}
+
+	buf.Reset()
+	if err := rpt.ReportTerse(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Labels: env=ci, git_sha=abc123\n") {
+		t.Fatalf("expected labels line in report, got:\n%v", buf.String())
+	}
+}
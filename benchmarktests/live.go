@@ -0,0 +1,232 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// liveHistory is the number of seconds kept for each test, shown as
+	// its sparkline
+	liveHistory = 40
+
+	// liveRateWindow and liveLatencyWindow are the number of most recent
+	// complete seconds the rate and latency percentiles are computed over
+	liveRateWindow    = 5
+	liveLatencyWindow = 10
+
+	// liveSamples caps the number of latencies kept per test and second;
+	// past it latencies are sampled
+	liveSamples = 1000
+)
+
+// sparkBlocks draw a sparkline from the lowest to highest value
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// LiveView keeps rolling statistics of the results of every test while
+// attacks run, and periodically redraws them on a terminal so long runs
+// don't have to wait until the end to see how they are doing. It only draws
+// the statistics and doesn't read keyboard input. It is safe to share
+// between concurrent attacks; the results of every target are combined.
+type LiveView struct {
+	mu    sync.Mutex
+	began time.Time
+	phase string
+	tests map[string]*liveTest
+}
+
+type liveTest struct {
+	requests uint64
+	errors   uint64
+	seconds  [liveHistory]liveSecond
+}
+
+// liveSecond holds the results which completed during one second
+type liveSecond struct {
+	second    int64
+	requests  uint64
+	errors    uint64
+	latencies []time.Duration
+}
+
+func NewLiveView() *LiveView {
+	return &LiveView{began: time.Now(), tests: make(map[string]*liveTest)}
+}
+
+// add records a result of the named test, and of the total
+func (lv *LiveView) add(phase, name string, result *vegeta.Result) {
+	lv.mu.Lock()
+	defer lv.mu.Unlock()
+
+	lv.phase = phase
+	second := result.Timestamp.Add(result.Latency).Unix()
+	lv.test("total").add(second, result)
+	if name != "total" {
+		lv.test(name).add(second, result)
+	}
+}
+
+func (lv *LiveView) test(name string) *liveTest {
+	t, ok := lv.tests[name]
+	if !ok {
+		t = &liveTest{}
+		lv.tests[name] = t
+	}
+	return t
+}
+
+func (t *liveTest) add(second int64, result *vegeta.Result) {
+	t.requests++
+	failed := result.Error != ""
+	if failed {
+		t.errors++
+	}
+
+	s := &t.seconds[second%liveHistory]
+	if s.second != second {
+		if s.second > second {
+			// Too old to be shown any more
+			return
+		}
+		*s = liveSecond{second: second, latencies: s.latencies[:0]}
+	}
+	s.requests++
+	if failed {
+		s.errors++
+	}
+	if len(s.latencies) < liveSamples {
+		s.latencies = append(s.latencies, result.Latency)
+	} else if i := rand.Int63n(int64(s.requests)); i < liveSamples {
+		s.latencies[i] = result.Latency
+	}
+}
+
+// window returns the seconds from count seconds before end up to, but not
+// including, end. Seconds without any results are zero.
+func (t *liveTest) window(end int64, count int) []liveSecond {
+	seconds := make([]liveSecond, count)
+	for i := range seconds {
+		second := end - int64(count-i)
+		if s := t.seconds[second%liveHistory]; s.second == second {
+			seconds[i] = s
+		}
+	}
+	return seconds
+}
+
+// Run redraws the view on w every refresh until stop is closed, drawing it
+// one last time before returning
+func (lv *LiveView) Run(w io.Writer, refresh time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			lv.Render(w, time.Now())
+		case <-stop:
+			lv.Render(w, time.Now())
+			return
+		}
+	}
+}
+
+// Render clears the terminal and draws the current statistics of every
+// test: the total requests and errors, the rate and latency percentiles of
+// the last few seconds, and a sparkline of the requests per second
+func (lv *LiveView) Render(w io.Writer, now time.Time) error {
+	lv.mu.Lock()
+	defer lv.mu.Unlock()
+
+	var buf bytes.Buffer
+	// Move to the top left and clear the screen
+	buf.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&buf, "vault-benchmark  elapsed %v", now.Sub(lv.began).Truncate(time.Second))
+	if lv.phase != "" {
+		fmt.Fprintf(&buf, "  phase %v", lv.phase)
+	}
+	buf.WriteString("\n\n")
+
+	names := make([]string, 0, len(lv.tests))
+	for name := range lv.tests {
+		if name != "total" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := lv.tests["total"]; ok {
+		names = append([]string{"total"}, names...)
+	}
+
+	tw := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Test\tRequests\tErrors\tRate\tP50\tP95\tP99\tRequests/s, last %vs\n", liveHistory)
+	for _, name := range names {
+		t := lv.tests[name]
+		current := now.Unix()
+
+		var recent uint64
+		for _, s := range t.window(current, liveRateWindow) {
+			recent += s.requests
+		}
+
+		var latencies []time.Duration
+		for _, s := range t.window(current, liveLatencyWindow) {
+			latencies = append(latencies, s.latencies...)
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f/s\t%s\t%s\t%s\t%s\n",
+			name, t.requests, t.errors,
+			float64(recent)/liveRateWindow,
+			livePercentile(latencies, 0.5),
+			livePercentile(latencies, 0.95),
+			livePercentile(latencies, 0.99),
+			sparkline(t.window(current, liveHistory)))
+	}
+	tw.Flush()
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// livePercentile returns the latency at fraction q of the sorted latencies
+func livePercentile(sorted []time.Duration, q float64) string {
+	if len(sorted) == 0 {
+		return "-"
+	}
+	i := min(int(q*float64(len(sorted))), len(sorted)-1)
+	return sorted[i].Round(10 * time.Microsecond).String()
+}
+
+// sparkline draws the requests of each second, marking seconds where any
+// request failed with a !
+func sparkline(seconds []liveSecond) string {
+	var peak uint64
+	for _, s := range seconds {
+		peak = max(peak, s.requests)
+	}
+
+	var b strings.Builder
+	for _, s := range seconds {
+		switch {
+		case s.errors > 0:
+			b.WriteRune('!')
+		case s.requests == 0:
+			b.WriteRune(' ')
+		default:
+			b.WriteRune(sparkBlocks[int(s.requests*uint64(len(sparkBlocks)-1)/peak)])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,68 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestLiveView_Render(t *testing.T) {
+	lv := NewLiveView()
+	now := time.Unix(1700000100, 0)
+
+	// Ten requests a second for the last five seconds, the oldest of which
+	// had a failure
+	for i := 1; i <= 5; i++ {
+		for j := 0; j < 10; j++ {
+			res := &vegeta.Result{Timestamp: now.Add(-time.Duration(i) * time.Second), Latency: time.Duration(j+1) * time.Millisecond}
+			if i == 5 && j == 0 {
+				res.Error = "500 Internal Server Error"
+			}
+			lv.add("", "read", res)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := lv.Render(&buf, now); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	lines := strings.Split(out, "\n")
+	var total, read string
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "total "):
+			total = line
+		case strings.HasPrefix(line, "read "):
+			read = line
+		}
+	}
+	if total == "" || read == "" {
+		t.Fatalf("expected rows for total and read, got:\n%s", out)
+	}
+
+	fields := strings.Fields(read)
+	if fields[1] != "50" || fields[2] != "1" || fields[3] != "10.0/s" {
+		t.Fatalf("unexpected requests, errors or rate: %v", read)
+	}
+	if fields[4] != "6ms" || fields[6] != "10ms" {
+		t.Fatalf("unexpected latency percentiles: %v", read)
+	}
+	if !strings.HasSuffix(read, "!████") {
+		t.Fatalf("expected a sparkline ending with the failed second and four full seconds: %q", read)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	got := sparkline([]liveSecond{{requests: 0}, {requests: 1}, {requests: 8}, {requests: 4, errors: 1}})
+	if got != " ▁█!" {
+		t.Fatalf("unexpected sparkline: %q", got)
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// RoundRobinBalance sends requests to each address in turn
+	RoundRobinBalance = "round_robin"
+
+	// RandomBalance sends each request to an address chosen at random
+	RandomBalance = "random"
+
+	// StickyBalance pins each closed loop worker to one address, as a
+	// client keeping its connection to one node would
+	StickyBalance = "sticky"
+
+	// WeightedBalance sends requests to addresses at random in proportion
+	// to their weights
+	WeightedBalance = "weighted"
+
+	// StandbyReadsBalance sends reads to the standby nodes, or a read-only
+	// address, and everything else to the active node, as clients making use
+	// of performance standbys would
+	StandbyReadsBalance = "standby_reads"
+)
+
+// LoadBalanceConfig spreads the requests of a single attack across several
+// addresses of the same cluster, such as its nodes or several load balancer
+// endpoints, rather than attacking each address on its own
+type LoadBalanceConfig struct {
+	Strategy string
+	Addrs    []string
+
+	// Weights are the weights of Addrs for the weighted strategy, in the
+	// same order
+	Weights []int
+
+	// Active and ReadAddrs are the addresses, out of Addrs, writes and reads
+	// are sent to by the standby reads strategy
+	Active    string
+	ReadAddrs []string
+
+	// Nodes, when set, are the nodes of the cluster as they are discovered
+	// during the attack, which requests are balanced across in place of
+	// Addrs, the nodes discovered before it
+	Nodes *NodeSet
+}
+
+// Validate checks the strategy and weights can be used to balance an
+// attack of the given mode
+func (c *LoadBalanceConfig) Validate(mode string) error {
+	if len(c.Addrs) == 0 {
+		return fmt.Errorf("no addresses to balance between")
+	}
+	switch c.Strategy {
+	case RoundRobinBalance, RandomBalance:
+	case StickyBalance:
+		if mode != ClosedLoopAttackMode {
+			return fmt.Errorf("sticky load balancing requires the closed attack mode")
+		}
+	case WeightedBalance:
+		if len(c.Weights) != len(c.Addrs) {
+			return fmt.Errorf("expected %d weights, got %d", len(c.Addrs), len(c.Weights))
+		}
+		total := 0
+		for i, weight := range c.Weights {
+			if weight < 0 {
+				return fmt.Errorf("weight of %v must not be negative", c.Addrs[i])
+			}
+			total += weight
+		}
+		if total == 0 {
+			return fmt.Errorf("at least one address must have a weight")
+		}
+	case StandbyReadsBalance:
+		if !slices.Contains(c.Addrs, c.Active) {
+			return fmt.Errorf("no active node to send writes to")
+		}
+		if len(c.ReadAddrs) == 0 {
+			return fmt.Errorf("no standby nodes to send reads to")
+		}
+		for _, addr := range c.ReadAddrs {
+			if !slices.Contains(c.Addrs, addr) {
+				return fmt.Errorf("read address %v is not one of the balanced addresses", addr)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown load balancing strategy: %v", c.Strategy)
+	}
+	if c.Nodes != nil && (c.Strategy == WeightedBalance || c.Strategy == StandbyReadsBalance) {
+		return fmt.Errorf("refreshing discovered nodes cannot be combined with the %v strategy", c.Strategy)
+	}
+	return nil
+}
+
+// balancer rewrites the targets of an attack, which are built against a
+// single client, to send them to the balanced addresses
+type balancer struct {
+	config *LoadBalanceConfig
+	from   string
+	total  int
+	next   atomic.Uint64
+}
+
+func newBalancer(config *LoadBalanceConfig, from string, mode string) (*balancer, error) {
+	if err := config.Validate(mode); err != nil {
+		return nil, err
+	}
+	b := &balancer{config: config, from: from}
+	for _, weight := range config.Weights {
+		b.total += weight
+	}
+	return b, nil
+}
+
+// pick chooses the address of the next request of the given worker
+func (b *balancer) pick(method string, worker int) string {
+	addrs := b.config.Addrs
+	if b.config.Nodes != nil {
+		addrs = b.config.Nodes.Addrs()
+	}
+	switch b.config.Strategy {
+	case StandbyReadsBalance:
+		if method != "GET" && method != "LIST" {
+			return b.config.Active
+		}
+		addrs = b.config.ReadAddrs
+	case StickyBalance:
+		return addrs[worker%len(addrs)]
+	case RandomBalance:
+		return addrs[rand.Intn(len(addrs))]
+	case WeightedBalance:
+		i := rand.Intn(b.total)
+		for j, weight := range b.config.Weights {
+			if i < weight {
+				return addrs[j]
+			}
+			i -= weight
+		}
+	}
+	return addrs[(b.next.Add(1)-1)%uint64(len(addrs))]
+}
+
+// targeter returns a targeter sending the targets of tr to the addresses
+// picked for worker
+func (b *balancer) targeter(tr vegeta.Targeter, worker int) vegeta.Targeter {
+	return func(tgt *vegeta.Target) error {
+		if err := tr(tgt); err != nil {
+			return err
+		}
+		tgt.URL = b.pick(tgt.Method, worker) + strings.TrimPrefix(tgt.URL, b.from)
+		return nil
+	}
+}
+
+// balancedAddr returns the address of the target, joining the addresses of
+// a balanced attack so its results are told apart from those of attacks
+// against any one of them
+func balancedAddr(config *LoadBalanceConfig) string {
+	return strings.Join(config.Addrs, ",")
+}
+
+// splitAddr splits a URL into the address, out of addrs, it was sent to and
+// its path. URLs sent to none of them are returned as a path.
+func splitAddr(url string, addrs []string) (string, string) {
+	for _, addr := range addrs {
+		if rest, ok := strings.CutPrefix(url, addr); ok && (rest == "" || rest[0] == '/' || rest[0] == '?') {
+			return addr, rest
+		}
+	}
+	return "", url
+}
+
+// splitURL splits the URL of a result into the address it was sent to and
+// its path
+func (r *Reporter) splitURL(url string) (string, string) {
+	if r.nodes != nil {
+		return splitAddr(url, r.nodes.Known())
+	}
+	if len(r.addrs) == 0 {
+		return splitAddr(url, []string{r.clientAddr})
+	}
+	return splitAddr(url, r.addrs)
+}
+
+// node names the node which served a result: the address it was sent to,
+// followed by the value of the node header when the response had one. It
+// is empty when results are not broken down by node.
+func (r *Reporter) node(addr string, result *vegeta.Result) string {
+	if addr == "" || (len(r.addrs) < 2 && r.nodes == nil && r.nodeHeader == "") {
+		return ""
+	}
+	if r.nodeHeader != "" {
+		if hint := result.Headers.Get(r.nodeHeader); hint != "" {
+			return addr + " (" + hint + ")"
+		}
+	}
+	return addr
+}
+
+// recordAddr adds a result to the metrics of the node which served it, when
+// the attack was balanced between several addresses or responses name the
+// node
+func (r *Reporter) recordAddr(addr string, result *vegeta.Result, latency time.Duration) {
+	addr = r.node(addr, result)
+	if addr == "" {
+		return
+	}
+	if r.addrMetrics == nil {
+		r.addrMetrics = make(map[string]*vegeta.Metrics, len(r.addrs))
+		r.addrHistograms = make(map[string]*Histogram, len(r.addrs))
+	}
+	m, ok := r.addrMetrics[addr]
+	if !ok {
+		m = &vegeta.Metrics{}
+		r.addrMetrics[addr] = m
+		r.addrHistograms[addr] = NewHistogram()
+	}
+	m.Add(result)
+	r.addrHistograms[addr].Record(latency)
+}
+
+func (r *Reporter) sortedAddrs() []string {
+	addrs := make([]string, 0, len(r.addrMetrics))
+	for addr := range r.addrMetrics {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// reportAddrsTerse writes a table of the results of all tests by the
+// address they were sent to
+func (r *Reporter) reportAddrsTerse(w io.Writer) {
+	if len(r.addrMetrics) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\naddress\tcount\trate\tthroughput\tinMB/s\toutMB/s\tmean\t")
+	for _, p := range r.reportedPercentiles() {
+		if p == 100 {
+			fmt.Fprintf(w, "max\t")
+		} else {
+			fmt.Fprintf(w, "%s%%\t", percentileLabel(p))
+		}
+	}
+	fmt.Fprintf(w, "successRatio\n")
+	for _, addr := range r.sortedAddrs() {
+		r.terseRow(w, addr, r.addrMetrics[addr], r.addrHistograms[addr])
+	}
+	if outliers := r.outlyingAddrs(); len(outliers) > 0 {
+		fmt.Fprintf(w, "\nOutlying nodes: %s\n", strings.Join(outliers, ", "))
+	}
+}
+
+// reportAddrsVerbose writes the results of all tests by the address they
+// were sent to
+func (r *Reporter) reportAddrsVerbose(w io.Writer) error {
+	for _, addr := range r.sortedAddrs() {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "address "+addr)
+		if err := vegeta.NewTextReporter(r.addrMetrics[addr]).Report(w); err != nil {
+			return fmt.Errorf("report error: %v", err)
+		}
+	}
+	if outliers := r.outlyingAddrs(); len(outliers) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Outlying nodes: %s\n", strings.Join(outliers, ", "))
+	}
+	return nil
+}
+
+// outlyingAddrs returns the nodes which look unhealthy next to the others:
+// those whose 99th percentile latency is more than twice the median of all
+// nodes, or whose success ratio is more than 5 points below the median
+func (r *Reporter) outlyingAddrs() []string {
+	addrs := r.sortedAddrs()
+	if len(addrs) < 2 {
+		return nil
+	}
+	p99s := make([]time.Duration, 0, len(addrs))
+	successes := make([]float64, 0, len(addrs))
+	for _, addr := range addrs {
+		p99s = append(p99s, r.addrHistograms[addr].Quantile(0.99))
+		successes = append(successes, r.addrMetrics[addr].Success)
+	}
+	slices.Sort(p99s)
+	slices.Sort(successes)
+	medianP99 := p99s[(len(p99s)-1)/2]
+	medianSuccess := successes[len(successes)/2]
+
+	var outliers []string
+	for _, addr := range addrs {
+		p99 := r.addrHistograms[addr].Quantile(0.99)
+		success := r.addrMetrics[addr].Success
+		switch {
+		case success < medianSuccess-0.05:
+			outliers = append(outliers, fmt.Sprintf("%s (%.2f%% success)", addr, success*100))
+		case p99 > 2*medianP99:
+			outliers = append(outliers, fmt.Sprintf("%s (99th %s)", addr, p99))
+		}
+	}
+	return outliers
+}
@@ -0,0 +1,181 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestBalancer_Strategies(t *testing.T) {
+	addrs := []string{"http://a:8200", "http://b:8200", "http://c:8200"}
+	count := func(strategy string, weights []int, worker int) map[string]int {
+		b, err := newBalancer(&LoadBalanceConfig{Strategy: strategy, Addrs: addrs, Weights: weights}, "http://a:8200", ClosedLoopAttackMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := b.targeter(func(tgt *vegeta.Target) error {
+			tgt.URL = "http://a:8200/v1/kv/data/secret"
+			return nil
+		}, worker)
+		counts := make(map[string]int)
+		for i := 0; i < 3000; i++ {
+			var tgt vegeta.Target
+			if err := tr(&tgt); err != nil {
+				t.Fatal(err)
+			}
+			addr, path := splitAddr(tgt.URL, addrs)
+			if path != "/v1/kv/data/secret" {
+				t.Fatalf("expected the path to be kept, got %v", tgt.URL)
+			}
+			counts[addr]++
+		}
+		return counts
+	}
+
+	if counts := count(RoundRobinBalance, nil, 0); counts["http://a:8200"] != 1000 || counts["http://b:8200"] != 1000 || counts["http://c:8200"] != 1000 {
+		t.Errorf("expected round robin to spread requests evenly, got %v", counts)
+	}
+	if counts := count(StickyBalance, nil, 4); counts["http://b:8200"] != 3000 {
+		t.Errorf("expected worker 4 to stick to the second address, got %v", counts)
+	}
+	if counts := count(WeightedBalance, []int{1, 0, 9}, 0); counts["http://b:8200"] != 0 || counts["http://c:8200"] < 2500 {
+		t.Errorf("expected requests in proportion to the weights, got %v", counts)
+	}
+	if counts := count(RandomBalance, nil, 0); len(counts) != 3 {
+		t.Errorf("expected random requests to reach every address, got %v", counts)
+	}
+}
+
+func TestBalancer_StandbyReads(t *testing.T) {
+	config := &LoadBalanceConfig{
+		Strategy:  StandbyReadsBalance,
+		Addrs:     []string{"http://a:8200", "http://b:8200", "http://c:8200"},
+		Active:    "http://a:8200",
+		ReadAddrs: []string{"http://b:8200", "http://c:8200"},
+	}
+	b, err := newBalancer(config, "http://a:8200", OpenLoopAttackMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, method := range []string{"GET", "POST", "LIST", "PUT", "GET", "DELETE"} {
+		tr := b.targeter(func(tgt *vegeta.Target) error {
+			tgt.Method = method
+			tgt.URL = "http://a:8200/v1/kv/data/secret"
+			return nil
+		}, 0)
+		var tgt vegeta.Target
+		if err := tr(&tgt); err != nil {
+			t.Fatal(err)
+		}
+		want := "http://a:8200"
+		switch i {
+		case 0, 4:
+			want = "http://b:8200"
+		case 2:
+			want = "http://c:8200"
+		}
+		if addr, _ := splitAddr(tgt.URL, config.Addrs); addr != want {
+			t.Errorf("expected %v request %d to be sent to %v, got %v", method, i, want, addr)
+		}
+	}
+}
+
+func TestLoadBalanceConfig_Validate(t *testing.T) {
+	addrs := []string{"http://a:8200", "http://b:8200"}
+	cases := []struct {
+		config *LoadBalanceConfig
+		mode   string
+		err    string
+	}{
+		{&LoadBalanceConfig{Strategy: RoundRobinBalance, Addrs: addrs}, OpenLoopAttackMode, ""},
+		{&LoadBalanceConfig{Strategy: "least_conn", Addrs: addrs}, OpenLoopAttackMode, "unknown load balancing strategy"},
+		{&LoadBalanceConfig{Strategy: StickyBalance, Addrs: addrs}, OpenLoopAttackMode, "requires the closed attack mode"},
+		{&LoadBalanceConfig{Strategy: WeightedBalance, Addrs: addrs, Weights: []int{1}}, OpenLoopAttackMode, "expected 2 weights"},
+		{&LoadBalanceConfig{Strategy: WeightedBalance, Addrs: addrs, Weights: []int{0, 0}}, OpenLoopAttackMode, "at least one address"},
+		{&LoadBalanceConfig{Strategy: StandbyReadsBalance, Addrs: addrs, ReadAddrs: addrs[1:]}, OpenLoopAttackMode, "no active node"},
+		{&LoadBalanceConfig{Strategy: StandbyReadsBalance, Addrs: addrs, Active: addrs[0]}, OpenLoopAttackMode, "no standby nodes"},
+		{&LoadBalanceConfig{Strategy: StandbyReadsBalance, Addrs: addrs, Active: addrs[0], ReadAddrs: []string{"http://c:8200"}}, OpenLoopAttackMode, "not one of the balanced addresses"},
+	}
+	for _, tc := range cases {
+		err := tc.config.Validate(tc.mode)
+		if tc.err == "" && err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.config.Strategy, err)
+		}
+		if tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)) {
+			t.Errorf("%v: expected error %q, got %v", tc.config.Strategy, tc.err, err)
+		}
+	}
+}
+
+func TestReporter_Addresses(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/kv"}}}
+	rpt := newReporter(tm, nil)
+	rpt.clientAddr = "http://a:8200,http://b:8200"
+	rpt.addrs = []string{"http://a:8200", "http://b:8200"}
+	began := time.Now()
+	for i := 0; i < 4; i++ {
+		addr := rpt.addrs[i%2]
+		rpt.Add(&vegeta.Result{Method: "GET", URL: addr + "/v1/kv/data/secret", Code: 200, Timestamp: began, Latency: time.Millisecond})
+	}
+	rpt.Close()
+
+	if got := rpt.metrics["read"].Requests; got != 4 {
+		t.Fatalf("expected requests to every address to match the test, got %d", got)
+	}
+	if a, b := rpt.addrMetrics["http://a:8200"], rpt.addrMetrics["http://b:8200"]; a.Requests != 2 || b.Requests != 2 {
+		t.Fatalf("unexpected address metrics: %+v, %+v", a, b)
+	}
+
+	var terse bytes.Buffer
+	if err := rpt.ReportTerse(&terse); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(terse.String(), "address") || !strings.Contains(terse.String(), "http://b:8200  2") {
+		t.Errorf("expected a table of addresses in terse report:\n%s", terse.String())
+	}
+}
+
+func TestReporter_Nodes(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/kv"}}}
+	rpt := newReporter(tm, nil)
+	rpt.clientAddr = "http://lb:8200"
+	rpt.nodeHeader = "X-Node"
+	began := time.Now()
+	for i := 0; i < 30; i++ {
+		// Behind the load balancer the third node is slow and failing
+		node := []string{"node-1", "node-2", "node-3"}[i%3]
+		result := &vegeta.Result{Method: "GET", URL: "http://lb:8200/v1/kv/data/secret", Code: 200, Timestamp: began, Latency: time.Millisecond, Headers: http.Header{"X-Node": []string{node}}}
+		if node == "node-3" {
+			result.Latency = 50 * time.Millisecond
+			if i%2 == 0 {
+				result.Code = 500
+				result.Error = "500 Internal Server Error"
+			}
+		}
+		rpt.Add(result)
+	}
+	rpt.Close()
+
+	if m := rpt.addrMetrics["http://lb:8200 (node-2)"]; m == nil || m.Requests != 10 {
+		t.Fatalf("expected results to be broken down by node, got %v", rpt.sortedAddrs())
+	}
+	outliers := rpt.outlyingAddrs()
+	if len(outliers) != 1 || !strings.HasPrefix(outliers[0], "http://lb:8200 (node-3) (50.00% success)") {
+		t.Fatalf("expected the failing node to be an outlier, got %v", outliers)
+	}
+
+	var verbose bytes.Buffer
+	if err := rpt.ReportVerbose(&verbose); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(verbose.String(), "address http://lb:8200 (node-1)") || !strings.Contains(verbose.String(), "Outlying nodes: http://lb:8200 (node-3)") {
+		t.Errorf("expected the results of each node in verbose report:\n%s", verbose.String())
+	}
+}
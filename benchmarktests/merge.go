@@ -0,0 +1,364 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"slices"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// MergeReports combines the reports of load generators which attacked the
+// same targets at the same time, such as the workers of a distributed run,
+// into one report for every target and phase, in the order they first
+// appear. Counts, rates and throughputs are summed and the histograms are
+// merged, so latency percentiles are exact. Results without histograms,
+// such as those of the warmup and burst windows, fall back to the slowest
+// percentile of any report.
+func MergeReports(rpts []*Reporter) []*Reporter {
+	type mergeKey struct{ addr, phase string }
+	groups := make(map[mergeKey][]*Reporter)
+	var keys []mergeKey
+	for _, rpt := range rpts {
+		key := mergeKey{rpt.clientAddr, rpt.phase}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], rpt)
+	}
+
+	merged := make([]*Reporter, 0, len(keys))
+	for _, key := range keys {
+		merged = append(merged, mergeGroup(groups[key]))
+	}
+	return merged
+}
+
+// mergeGroup merges reports of the same target and phase
+func mergeGroup(rpts []*Reporter) *Reporter {
+	m := newReporter(&TargetMulti{}, nil)
+	first := rpts[0]
+	m.clientAddr = first.clientAddr
+	m.phase = first.phase
+	m.server = first.server
+	m.labels = first.labels
+	m.transport = first.transport
+	m.tokenRenewal = first.tokenRenewal
+	m.tokenPool = first.tokenPool
+	m.tokenType = first.tokenType
+	m.corrected = first.corrected
+	m.seriesInterval = first.seriesInterval
+	m.resources = first.resources
+	m.percentiles = first.percentiles
+
+	m.histograms = mergeHistograms(rpts, func(r *Reporter) map[string]*Histogram { return r.histograms })
+	m.metrics = mergeMetricSets(rpts, m.histograms, m.corrected, func(r *Reporter) map[string]*vegeta.Metrics { return r.metrics })
+	m.warmupMetrics = mergeMetricSets(rpts, nil, false, func(r *Reporter) map[string]*vegeta.Metrics { return r.warmupMetrics })
+	m.burstMetrics = mergeMetricSets(rpts, nil, false, func(r *Reporter) map[string]*vegeta.Metrics { return r.burstMetrics })
+	m.addrHistograms = mergeHistograms(rpts, func(r *Reporter) map[string]*Histogram { return r.addrHistograms })
+	m.addrMetrics = mergeMetricSets(rpts, m.addrHistograms, m.corrected, func(r *Reporter) map[string]*vegeta.Metrics { return r.addrMetrics })
+	m.nsHistograms = mergeHistograms(rpts, func(r *Reporter) map[string]*Histogram { return r.nsHistograms })
+	m.nsMetrics = mergeMetricSets(rpts, m.nsHistograms, m.corrected, func(r *Reporter) map[string]*vegeta.Metrics { return r.nsMetrics })
+
+	for _, rpt := range rpts {
+		// Chaos events are only run by one of the reports of a sharded run
+		if m.chaosEvents == nil {
+			m.chaosEvents = rpt.chaosEvents
+		}
+		m.throttled += rpt.throttled
+		for name, codes := range rpt.codeHistograms {
+			for code, h := range codes {
+				if _, ok := m.codeHistograms[name]; !ok {
+					m.codeHistograms[name] = make(map[uint16]*Histogram)
+				}
+				if _, ok := m.codeHistograms[name][code]; !ok {
+					m.codeHistograms[name][code] = NewHistogram()
+				}
+				m.codeHistograms[name][code].Merge(h)
+			}
+		}
+		for name, outcomes := range rpt.cacheHistograms {
+			for outcome, h := range outcomes {
+				if m.cacheHistograms == nil {
+					m.cacheHistograms = make(map[string]map[string]*Histogram)
+				}
+				if _, ok := m.cacheHistograms[name]; !ok {
+					m.cacheHistograms[name] = make(map[string]*Histogram)
+				}
+				if _, ok := m.cacheHistograms[name][outcome]; !ok {
+					m.cacheHistograms[name][outcome] = NewHistogram()
+				}
+				m.cacheHistograms[name][outcome].Merge(h)
+			}
+		}
+		for name, stats := range rpt.redirects {
+			if m.redirects == nil {
+				m.redirects = make(map[string]*RedirectStats)
+			}
+			if _, ok := m.redirects[name]; !ok {
+				m.redirects[name] = &RedirectStats{Latency: NewHistogram()}
+			}
+			m.redirects[name].Redirects += stats.Redirects
+			m.redirects[name].Latency.Merge(stats.Latency)
+		}
+		for name, stats := range rpt.connections {
+			if m.connections == nil {
+				m.connections = make(map[string]*ConnectionStats)
+			}
+			if _, ok := m.connections[name]; !ok {
+				m.connections[name] = &ConnectionStats{TLSHandshake: NewHistogram()}
+			}
+			m.connections[name].New += stats.New
+			m.connections[name].Reused += stats.Reused
+			m.connections[name].TLSHandshake.Merge(stats.TLSHandshake)
+		}
+		for name, stats := range rpt.retries {
+			if m.retries == nil {
+				m.retries = make(map[string]*RetryStats)
+			}
+			if _, ok := m.retries[name]; !ok {
+				m.retries[name] = &RetryStats{}
+			}
+			m.retries[name].Retried += stats.Retried
+			m.retries[name].Retries += stats.Retries
+			m.retries[name].Recovered += stats.Recovered
+		}
+		for name, stats := range rpt.validation {
+			if m.validation == nil {
+				m.validation = make(map[string]*ValidationStats)
+			}
+			if _, ok := m.validation[name]; !ok {
+				m.validation[name] = &ValidationStats{}
+			}
+			m.validation[name].merge(stats)
+		}
+		for name, groups := range rpt.errorGroups {
+			m.errorGroups[name] = mergeErrorGroups(m.errorGroups[name], groups)
+		}
+		for name, ops := range rpt.opHistograms {
+			for op, h := range ops {
+				if m.opHistograms == nil {
+					m.opHistograms = make(map[string]map[string]*Histogram)
+				}
+				if _, ok := m.opHistograms[name]; !ok {
+					m.opHistograms[name] = make(map[string]*Histogram)
+				}
+				if _, ok := m.opHistograms[name][op]; !ok {
+					m.opHistograms[name][op] = NewHistogram()
+				}
+				m.opHistograms[name][op].Merge(h)
+			}
+		}
+	}
+
+	for _, rpt := range rpts {
+		for name := range rpt.opMetrics {
+			if m.opMetrics == nil {
+				m.opMetrics = make(map[string]map[string]*vegeta.Metrics)
+			}
+			if _, ok := m.opMetrics[name]; ok {
+				continue
+			}
+			m.opMetrics[name] = mergeMetricSets(rpts, m.opHistograms[name], m.corrected, func(r *Reporter) map[string]*vegeta.Metrics { return r.opMetrics[name] })
+		}
+	}
+
+	if m.seriesInterval > 0 {
+		m.timeseries = mergeTimeseries(rpts, m.seriesInterval)
+	}
+	return m
+}
+
+func mergeHistograms(rpts []*Reporter, set func(*Reporter) map[string]*Histogram) map[string]*Histogram {
+	merged := make(map[string]*Histogram)
+	for _, rpt := range rpts {
+		for name, h := range set(rpt) {
+			if _, ok := merged[name]; !ok {
+				merged[name] = NewHistogram()
+			}
+			merged[name].Merge(h)
+		}
+	}
+	return merged
+}
+
+// mergeMetricSets merges the metrics of every test in one set of metrics of
+// the reports, such as their main or warmup metrics. Latency percentiles
+// are read from the histogram of a test when given, unless it holds
+// latencies corrected for coordinated omission.
+func mergeMetricSets(rpts []*Reporter, histograms map[string]*Histogram, corrected bool, set func(*Reporter) map[string]*vegeta.Metrics) map[string]*vegeta.Metrics {
+	byName := make(map[string][]*vegeta.Metrics)
+	for _, rpt := range rpts {
+		for name, m := range set(rpt) {
+			byName[name] = append(byName[name], m)
+		}
+	}
+	if len(byName) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]*vegeta.Metrics, len(byName))
+	for name, ms := range byName {
+		var h *Histogram
+		if !corrected {
+			h = histograms[name]
+		}
+		merged[name] = mergeMetrics(ms, h)
+	}
+	return merged
+}
+
+func mergeMetrics(ms []*vegeta.Metrics, h *Histogram) *vegeta.Metrics {
+	out := &vegeta.Metrics{StatusCodes: make(map[string]int)}
+	var successes float64
+	errors := make(map[string]bool)
+	for _, m := range ms {
+		for code, n := range m.StatusCodes {
+			out.StatusCodes[code] += n
+		}
+		for _, err := range m.Errors {
+			if !errors[err] {
+				errors[err] = true
+				out.Errors = append(out.Errors, err)
+			}
+		}
+		if m.Requests == 0 {
+			continue
+		}
+
+		if out.Requests == 0 || m.Earliest.Before(out.Earliest) {
+			out.Earliest = m.Earliest
+		}
+		if m.Latest.After(out.Latest) {
+			out.Latest = m.Latest
+		}
+		if m.End.After(out.End) {
+			out.End = m.End
+		}
+		if out.Requests == 0 || m.Latencies.Min < out.Latencies.Min {
+			out.Latencies.Min = m.Latencies.Min
+		}
+		out.Requests += m.Requests
+		out.Rate += m.Rate
+		out.Throughput += m.Throughput
+		successes += m.Success * float64(m.Requests)
+		out.Wait = max(out.Wait, m.Wait)
+		out.BytesIn.Total += m.BytesIn.Total
+		out.BytesOut.Total += m.BytesOut.Total
+		out.Latencies.Total += m.Latencies.Total
+		out.Latencies.Max = max(out.Latencies.Max, m.Latencies.Max)
+		out.Latencies.P50 = max(out.Latencies.P50, m.Latencies.P50)
+		out.Latencies.P90 = max(out.Latencies.P90, m.Latencies.P90)
+		out.Latencies.P95 = max(out.Latencies.P95, m.Latencies.P95)
+		out.Latencies.P99 = max(out.Latencies.P99, m.Latencies.P99)
+	}
+	if out.Requests == 0 {
+		return out
+	}
+
+	out.Duration = out.Latest.Sub(out.Earliest)
+	out.Success = successes / float64(out.Requests)
+	out.Latencies.Mean = out.Latencies.Total / time.Duration(out.Requests)
+	out.BytesIn.Mean = float64(out.BytesIn.Total) / float64(out.Requests)
+	out.BytesOut.Mean = float64(out.BytesOut.Total) / float64(out.Requests)
+	if h != nil && h.Count() > 0 {
+		out.Latencies.P50 = h.Quantile(0.5)
+		out.Latencies.P90 = h.Quantile(0.9)
+		out.Latencies.P95 = h.Quantile(0.95)
+		out.Latencies.P99 = h.Quantile(0.99)
+	}
+	return out
+}
+
+// mergeErrorGroups adds the counts and samples of groups to the matching
+// groups of merged, keeping no more samples than any one report did
+func mergeErrorGroups(merged, groups []*ErrorGroup) []*ErrorGroup {
+	for _, g := range groups {
+		var match *ErrorGroup
+		for _, existing := range merged {
+			if existing.Code == g.Code && existing.Error == g.Error {
+				match = existing
+				break
+			}
+		}
+		if match == nil {
+			match = &ErrorGroup{Code: g.Code, Error: g.Error}
+			merged = append(merged, match)
+		}
+		match.Count += g.Count
+		if len(match.Samples) < len(g.Samples) {
+			match.Samples = append(match.Samples, g.Samples[:len(g.Samples)-len(match.Samples)]...)
+		}
+	}
+	return merged
+}
+
+// mergeTimeseries merges the points of the reports' time series which
+// cover the same interval. The reports are expected to have started
+// together, so points are matched by their interval since the earliest
+// start of any report. Latency percentiles are the slowest of any point.
+func mergeTimeseries(rpts []*Reporter, interval time.Duration) map[string][]TimeseriesPoint {
+	var base time.Time
+	for _, rpt := range rpts {
+		for _, points := range rpt.timeseries {
+			if len(points) > 0 && (base.IsZero() || points[0].Start.Before(base)) {
+				base = points[0].Start
+			}
+		}
+	}
+
+	merged := make(map[string][]TimeseriesPoint)
+	for name := range rpts[0].timeseries {
+		byIndex := make(map[int64]*TimeseriesPoint)
+		var indexes []int64
+		for _, rpt := range rpts {
+			for _, p := range rpt.timeseries[name] {
+				i := int64((p.Start.Sub(base) + interval/2) / interval)
+				mp, ok := byIndex[i]
+				if !ok {
+					mp = &TimeseriesPoint{Start: p.Start}
+					byIndex[i] = mp
+					indexes = append(indexes, i)
+				}
+				requests := mp.Requests + p.Requests
+				if requests > 0 {
+					mp.ErrorRate = (mp.ErrorRate*float64(mp.Requests) + p.ErrorRate*float64(p.Requests)) / float64(requests)
+					mp.Mean = time.Duration((float64(mp.Mean)*float64(mp.Requests) + float64(p.Mean)*float64(p.Requests)) / float64(requests))
+				}
+				if p.Start.Before(mp.Start) {
+					mp.Start = p.Start
+				}
+				mp.Requests = requests
+				mp.Throughput += p.Throughput
+				mp.P50 = max(mp.P50, p.P50)
+				mp.P90 = max(mp.P90, p.P90)
+				mp.P95 = max(mp.P95, p.P95)
+				mp.P99 = max(mp.P99, p.P99)
+				mp.Max = max(mp.Max, p.Max)
+				for _, event := range p.Events {
+					if !slices.Contains(mp.Events, event) {
+						mp.Events = append(mp.Events, event)
+					}
+				}
+			}
+		}
+
+		points := make([]TimeseriesPoint, 0, len(indexes))
+		for _, i := range indexes {
+			points = append(points, *byIndex[i])
+		}
+		sortTimeseries(points)
+		merged[name] = points
+	}
+	return merged
+}
+
+func sortTimeseries(points []TimeseriesPoint) {
+	for i := 1; i < len(points); i++ {
+		for j := i; j > 0 && points[j].Start.Before(points[j-1].Start); j-- {
+			points[j], points[j-1] = points[j-1], points[j]
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestMergeReports(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "read", Method: "GET", PathPrefix: "/v1/kv"},
+	}}
+	began := time.Now()
+
+	// Two workers attack together, one seeing far slower responses
+	worker := func(latency time.Duration, requests int, failed bool) *Reporter {
+		rpt := newReporter(tm, nil)
+		rpt.startWarmup(began, 0)
+		rpt.trackTimeseries(time.Second)
+		for i := 0; i < requests; i++ {
+			result := &vegeta.Result{Method: "GET", URL: "N/A/v1/kv/data/secret", Code: 200, Timestamp: began.Add(time.Duration(i) * time.Millisecond), Latency: latency}
+			if failed && i == 0 {
+				result.Code = 500
+				result.Error = "500 Internal Server Error"
+			}
+			rpt.Add(result)
+		}
+		rpt.Close()
+		return rpt
+	}
+	fast := worker(time.Millisecond, 90, false)
+	slow := worker(100*time.Millisecond, 10, true)
+
+	merged := MergeReports([]*Reporter{fast, slow})
+	if len(merged) != 1 {
+		t.Fatalf("expected the reports of one target to be merged into one, got %d", len(merged))
+	}
+	m := merged[0].metrics["read"]
+	if m.Requests != 100 || m.StatusCodes["200"] != 99 || m.StatusCodes["500"] != 1 {
+		t.Fatalf("unexpected merged counts: %d requests, status codes %v", m.Requests, m.StatusCodes)
+	}
+	if m.Success != 0.99 {
+		t.Fatalf("expected a success ratio of 0.99, got %v", m.Success)
+	}
+	// 10% of requests were slow, so the 95th percentile is slow and the
+	// 50th fast, unlike either worker on its own
+	if m.Latencies.P50 > 2*time.Millisecond || m.Latencies.P95 < 90*time.Millisecond {
+		t.Fatalf("expected percentiles of the merged histogram, got p50 %v and p95 %v", m.Latencies.P50, m.Latencies.P95)
+	}
+	if m.Latencies.Max != 100*time.Millisecond || m.Latencies.Min != time.Millisecond {
+		t.Fatalf("unexpected latency range %v to %v", m.Latencies.Min, m.Latencies.Max)
+	}
+	if got := merged[0].histograms["read"].Count(); got != 100 {
+		t.Fatalf("expected the histograms to be merged, got %d values", got)
+	}
+	if groups := merged[0].errorGroups["read"]; len(groups) != 1 || groups[0].Count != 1 {
+		t.Fatalf("unexpected error groups: %+v", groups)
+	}
+	if points := merged[0].timeseries["read"]; len(points) != 1 || points[0].Requests != 100 {
+		t.Fatalf("expected the points of the time series to be merged, got %+v", points)
+	}
+}
+
+func TestMergeReports_Targets(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/kv"}}}
+	report := func(addr, phase string) *Reporter {
+		rpt := newReporter(tm, nil)
+		rpt.clientAddr = addr
+		rpt.phase = phase
+		rpt.Add(&vegeta.Result{Method: "GET", URL: addr + "/v1/kv/data/secret", Code: 200, Timestamp: time.Now(), Latency: time.Millisecond})
+		rpt.Close()
+		return rpt
+	}
+
+	merged := MergeReports([]*Reporter{
+		report("http://a:8200", "warm"),
+		report("http://a:8200", "peak"),
+		report("http://b:8200", "warm"),
+		report("http://a:8200", "warm"),
+		report("http://a:8200", "peak"),
+		report("http://b:8200", "warm"),
+	})
+	if len(merged) != 3 {
+		t.Fatalf("expected a report for each target and phase, got %d", len(merged))
+	}
+	for i, want := range []struct{ addr, phase string }{{"http://a:8200", "warm"}, {"http://a:8200", "peak"}, {"http://b:8200", "warm"}} {
+		if merged[i].clientAddr != want.addr || merged[i].phase != want.phase {
+			t.Fatalf("expected report %d to be %v, got %v (%v)", i, want, merged[i].clientAddr, merged[i].phase)
+		}
+		if got := merged[i].metrics["read"].Requests; got != 2 {
+			t.Fatalf("expected 2 requests in report %d, got %d", i, got)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+)
+
+// mountDescriptionPrefix starts the description of the secrets engines and
+// auth methods tests enable, which is followed by the name of the test, so
+// those of runs which weren't cleaned up can be found
+const mountDescriptionPrefix = "vault-benchmark test "
+
+// DiscoveredMount is a secrets engine or auth method enabled by a test of
+// an earlier run
+type DiscoveredMount struct {
+	// Path is the path of the mount, starting with auth/ for auth methods
+	Path string
+	Type string
+	Test string
+}
+
+// describeMounts returns a request callback describing the secrets engines
+// and auth methods enabled by the test of the name, unless the test gives
+// them a description of its own
+func describeMounts(name string) api.RequestCallback {
+	return func(r *api.Request) {
+		if !enablesMount(r.Method, r.URL.Path) {
+			return
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(r.BodyBytes, &body); err != nil || body == nil {
+			return
+		}
+		if description, _ := body["description"].(string); description != "" {
+			return
+		}
+		body["description"] = mountDescriptionPrefix + name
+		_ = r.SetJSONBody(body)
+	}
+}
+
+// mountReuse answers requests of a test enabling a secrets engine or auth
+// method at a path which is already in use as though the mount was enabled,
+// when the mount is one the test left in place in an earlier run: of the
+// type requested, and described as a mount of the test. Tests with fixed
+// mount names which skip cleanup are so set up again on their own mounts,
+// while any other mount at the path still fails the setup, rather than
+// being written to and later removed by the benchmark.
+type mountReuse struct {
+	client *api.Client
+	test   string
+
+	l     sync.Mutex
+	types map[string]string
+}
+
+func newMountReuse(client *api.Client, test string) *mountReuse {
+	return &mountReuse{client: client, test: test, types: make(map[string]string)}
+}
+
+// request is a request callback recording the type of the mounts the test
+// enables, which responses don't tell
+func (m *mountReuse) request(r *api.Request) {
+	if !enablesMount(r.Method, r.URL.Path) {
+		return
+	}
+	var body struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(r.BodyBytes, &body); err != nil {
+		return
+	}
+	m.l.Lock()
+	m.types[r.URL.Path] = body.Type
+	m.l.Unlock()
+}
+
+// response is a response callback answering the requests enabling mounts
+// the test can be set up on again
+func (m *mountReuse) response(resp *api.Response) {
+	req := resp.Request
+	if resp.StatusCode != http.StatusBadRequest || req == nil || !enablesMount(req.Method, req.URL.Path) {
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil || !strings.Contains(string(body), "path is already in use") {
+		return
+	}
+	m.l.Lock()
+	mountType := m.types[req.URL.Path]
+	m.l.Unlock()
+
+	mount := strings.TrimPrefix(req.URL.Path, "/v1/sys/")
+	existing, err := m.existing(mount)
+	if err != nil {
+		targetLogger.Warn("error looking up existing mount", "path", mount, "error", hclog.Fmt("%v", err))
+		return
+	}
+	if existing == nil || mountType == "" || existing.Type != mountType || existing.Description != mountDescriptionPrefix+m.test {
+		return
+	}
+	targetLogger.Debug("reusing existing mount", "path", mount)
+	resp.StatusCode = http.StatusNoContent
+	resp.Body = http.NoBody
+}
+
+// existing returns the secrets engine or auth method at the path, given as
+// mounts/<path> or auth/<path>
+func (m *mountReuse) existing(mount string) (*api.MountOutput, error) {
+	var mounts map[string]*api.MountOutput
+	var err error
+	if p, ok := strings.CutPrefix(mount, "auth/"); ok {
+		mount = p
+		mounts, err = m.client.Sys().ListAuth()
+	} else {
+		mount = strings.TrimPrefix(mount, "mounts/")
+		mounts, err = m.client.Sys().ListMounts()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mounts[strings.Trim(mount, "/")+"/"], nil
+}
+
+// enablesMount reports whether a request enables a secrets engine or auth
+// method
+func enablesMount(method, p string) bool {
+	if method != http.MethodPost && method != http.MethodPut {
+		return false
+	}
+	if !strings.HasPrefix(p, "/v1/sys/mounts/") && !strings.HasPrefix(p, "/v1/sys/auth/") {
+		return false
+	}
+	return !strings.HasSuffix(p, "/tune")
+}
+
+// DiscoverMounts finds the secrets engines and auth methods enabled by the
+// tests of earlier runs in the namespace of the client, going by their
+// descriptions
+func DiscoverMounts(client *api.Client) ([]*DiscoveredMount, error) {
+	secrets, err := client.Sys().ListMounts()
+	if err != nil {
+		return nil, fmt.Errorf("error listing secrets engines: %v", err)
+	}
+	auths, err := client.Sys().ListAuth()
+	if err != nil {
+		return nil, fmt.Errorf("error listing auth methods: %v", err)
+	}
+
+	var mounts []*DiscoveredMount
+	add := func(prefix string, outputs map[string]*api.MountOutput) {
+		for p, output := range outputs {
+			test, ok := strings.CutPrefix(output.Description, mountDescriptionPrefix)
+			if !ok {
+				continue
+			}
+			mounts = append(mounts, &DiscoveredMount{
+				Path: prefix + strings.TrimSuffix(p, "/"),
+				Type: output.Type,
+				Test: test,
+			})
+		}
+	}
+	add("", secrets)
+	add("auth/", auths)
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].Path < mounts[j].Path })
+	return mounts, nil
+}
+
+// DiscoverNamespaces finds the namespaces tests of earlier runs were fanned
+// out into, which are children of the namespace of the client
+func DiscoverNamespaces(client *api.Client) ([]string, error) {
+	secret, err := client.Logical().List("sys/namespaces")
+	if err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %v", err)
+	}
+	if secret == nil {
+		return nil, nil
+	}
+	keys, _ := secret.Data["keys"].([]interface{})
+	var namespaces []string
+	for _, key := range keys {
+		name, _ := key.(string)
+		name = strings.TrimSuffix(name, "/")
+		if strings.HasPrefix(name, namespacePrefix) {
+			namespaces = append(namespaces, name)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// RemoveMount disables the secrets engine or auth method at the path, which
+// starts with auth/ for auth methods
+func RemoveMount(client *api.Client, mount string) error {
+	if method, ok := strings.CutPrefix(mount, "auth/"); ok {
+		return client.Sys().DisableAuth(method)
+	}
+	return client.Sys().Unmount(mount)
+}
+
+// RemoveNamespace deletes the namespace, which is a child of the namespace
+// of the client
+func RemoveNamespace(client *api.Client, ns string) error {
+	_, err := client.Logical().Delete("sys/namespaces/" + path.Base(ns))
+	return err
+}
@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestDiscoverMounts(t *testing.T) {
+	var enabled map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var data map[string]interface{}
+		switch {
+		case req.Method == http.MethodPost:
+			body, _ := io.ReadAll(req.Body)
+			_ = json.Unmarshal(body, &enabled)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case req.URL.Path == "/v1/sys/mounts":
+			data = map[string]interface{}{
+				"kv-1/":   map[string]interface{}{"type": "kv", "description": mountDescriptionPrefix + "reads"},
+				"secret/": map[string]interface{}{"type": "kv", "description": "key/value secret storage"},
+			}
+		case req.URL.Path == "/v1/sys/auth":
+			data = map[string]interface{}{
+				"approle-1/": map[string]interface{}{"type": "approle", "description": mountDescriptionPrefix + "logins"},
+				"token/":     map[string]interface{}{"type": "token", "description": "token based credentials"},
+			}
+		case req.URL.Path == "/v1/sys/namespaces":
+			data = map[string]interface{}{"keys": []string{namespacePrefix + "1/", "team-a/"}}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+
+	// Mounts enabled by tests are described by the name of the test
+	err = client.WithRequestCallbacks(describeMounts("logins")).Sys().EnableAuthWithOptions("approle-1", &api.EnableAuthOptions{Type: "approle"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if enabled["description"] != mountDescriptionPrefix+"logins" || enabled["type"] != "approle" {
+		t.Fatalf("expected the mount to be described by its test, got: %v", enabled)
+	}
+
+	mounts, err := DiscoverMounts(client)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(mounts) != 2 {
+		t.Fatalf("expected the two mounts of tests, got: %+v", mounts)
+	}
+	if mounts[0].Path != "auth/approle-1" || mounts[0].Type != "approle" || mounts[0].Test != "logins" {
+		t.Errorf("unexpected auth mount: %+v", mounts[0])
+	}
+	if mounts[1].Path != "kv-1" || mounts[1].Type != "kv" || mounts[1].Test != "reads" {
+		t.Errorf("unexpected secrets mount: %+v", mounts[1])
+	}
+
+	namespaces, err := DiscoverNamespaces(client)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(namespaces) != 1 || namespaces[0] != namespacePrefix+"1" {
+		t.Fatalf("expected the fanned out namespace, got: %v", namespaces)
+	}
+}
+
+func TestReuseMounts(t *testing.T) {
+	var deletes atomic.Int64
+	var existing atomic.Value
+	existing.Store(map[string]interface{}{"type": "userpass", "description": mountDescriptionPrefix + "logins"})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodPost && req.URL.Path == "/v1/sys/auth/logins":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"errors":["path is already in use at logins/"]}`)
+		case req.Method == http.MethodGet && req.URL.Path == "/v1/sys/auth":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"logins/": existing.Load()}})
+		case req.Method == http.MethodDelete:
+			deletes.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+
+	newTests := func(randomMount, skipCleanup bool) []*BenchmarkTarget {
+		builder := TestList["userpass_auth"]()
+		if err := builder.ParseConfig(hcl.EmptyBody()); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return []*BenchmarkTarget{{Name: "logins", Type: "userpass_auth", Weight: 100, Builder: builder, RandomMount: &randomMount, SkipCleanup: skipCleanup}}
+	}
+
+	// The test is set up again on the mount of the fixed name an earlier
+	// run of it left in place, and leaves it in place again
+	logger := hclog.NewNullLogger()
+	tm, err := BuildTargets(client, newTests(false, true), &logger, &TopLevelTargetConfig{RandomMounts: true})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if tm.targets[0].PathPrefix != "/v1/auth/logins" {
+		t.Fatalf("expected the test to be set up on its fixed mount, got: %v", tm.targets[0].PathPrefix)
+	}
+	if err := tm.Cleanup(client); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if deletes.Load() != 0 {
+		t.Fatalf("expected the mount to be left in place, got %d deletes", deletes.Load())
+	}
+	if len(tm.mounts()) != 0 {
+		t.Fatalf("expected no mounts to remove on resume, got: %v", tm.mounts())
+	}
+
+	// Only tests which skip cleanup reuse mounts
+	if _, err := BuildTargets(client, newTests(false, false), &logger, &TopLevelTargetConfig{}); err == nil {
+		t.Fatal("expected a test which doesn't skip cleanup to fail on a mount in use")
+	}
+
+	// Mounts of another type or which the test didn't enable aren't reused
+	for _, mount := range []map[string]interface{}{
+		{"type": "approle", "description": mountDescriptionPrefix + "logins"},
+		{"type": "userpass", "description": "team logins"},
+		{"type": "userpass", "description": mountDescriptionPrefix + "other"},
+	} {
+		existing.Store(mount)
+		if _, err := BuildTargets(client, newTests(false, true), &logger, &TopLevelTargetConfig{}); err == nil {
+			t.Fatalf("expected mount %v not to be reused", mount)
+		}
+	}
+
+	// Random mounts aren't reused
+	tm, err = BuildTargets(client, newTests(true, true), &logger, &TopLevelTargetConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if tm.targets[0].PathPrefix == "/v1/auth/logins" {
+		t.Fatal("expected the test to be set up on a random mount")
+	}
+}
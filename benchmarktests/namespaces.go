@@ -0,0 +1,241 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// namespacePrefix is the prefix of the names of the namespaces tests are
+	// fanned out into
+	namespacePrefix = "benchmark-ns-"
+
+	// namespaceHeader is set on responses to the namespace the request was
+	// sent to, so the results of each namespace can be recorded
+	namespaceHeader = "X-Benchmark-Namespace"
+)
+
+// NamespaceFanOut replicates the setup of the tests into several child
+// namespaces of the client's namespace and spreads the requests of each
+// test across them, to measure how a server scales with many tenants
+type NamespaceFanOut struct {
+	// namespaces are the full paths of the namespaces
+	namespaces []string
+
+	// random is whether the namespaces have random names, in which case
+	// the tests in them don't need random mounts
+	random bool
+
+	clients []*api.Client
+	targets []*TargetMulti
+}
+
+// CreateNamespaces creates count namespaces under the client's namespace,
+// with names of their own to the run when random is set
+func CreateNamespaces(client *api.Client, count int, random bool) (*NamespaceFanOut, error) {
+	f := &NamespaceFanOut{random: random}
+	prefix := namespacePrefix
+	if random {
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		prefix += id + "-"
+	}
+	for i := 1; i <= count; i++ {
+		name := fmt.Sprintf("%s%d", prefix, i)
+		if _, err := client.Logical().Write("sys/namespaces/"+name, nil); err != nil {
+			return nil, fmt.Errorf("error creating namespace %v: %v", name, err)
+		}
+		nsClient, err := client.CloneWithHeaders()
+		if err != nil {
+			return nil, err
+		}
+		nsClient.SetToken(client.Token())
+		nsClient.SetNamespace(path.Join(client.Namespace(), name))
+		f.namespaces = append(f.namespaces, nsClient.Namespace())
+		f.clients = append(f.clients, nsClient)
+	}
+	return f, nil
+}
+
+// Names returns the paths of the namespaces, or none without a fan-out
+func (f *NamespaceFanOut) Names() []string {
+	if f == nil {
+		return nil
+	}
+	return f.namespaces
+}
+
+// BuildTargets sets the tests up in every namespace and returns targets
+// sending the requests of each test to the namespaces in turn
+func (f *NamespaceFanOut) BuildTargets(tests []*BenchmarkTarget, logger *hclog.Logger, config *TopLevelTargetConfig) (*TargetMulti, error) {
+	targetLogger = *logger
+	// The requests of each test are told apart by the path of its mount,
+	// which must be the same in every namespace, so the random_mount of
+	// each test is ignored
+	if f.random {
+		fixed := *config
+		fixed.RandomMounts = false
+		config = &fixed
+	}
+	for i, client := range f.clients {
+		// Each namespace gets its own copy of the tests, which are set up
+		// in place
+		copies := make([]*BenchmarkTarget, len(tests))
+		for j, test := range tests {
+			test := *test
+			test.RandomMount = nil
+			copies[j] = &test
+		}
+		targetLogger.Debug("setting up targets in namespace", "namespace", f.namespaces[i])
+		tm, err := BuildTargets(client, copies, logger, config)
+		if err != nil {
+			return nil, fmt.Errorf("error setting up namespace %v: %v", f.namespaces[i], err)
+		}
+		f.targets = append(f.targets, tm)
+	}
+	return fanOutTargets(f.targets), nil
+}
+
+// fanOutTargets returns the targets of the first set, each sending its
+// requests with the target of the same name of every set in turn
+func fanOutTargets(sets []*TargetMulti) *TargetMulti {
+	var tm TargetMulti
+	for _, target := range sets[0].targets {
+		var fns []func(*api.Client) vegeta.Target
+		for _, set := range sets {
+			for _, t := range set.targets {
+				if t.Name == target.Name {
+					fns = append(fns, t.Target)
+				}
+			}
+		}
+		next := new(atomic.Uint64)
+		target.Target = func(client *api.Client) vegeta.Target {
+			return fns[(next.Add(1)-1)%uint64(len(fns))](client)
+		}
+		tm.targets = append(tm.targets, target)
+	}
+	return &tm
+}
+
+// Cleanup cleans the tests up in every namespace with the client, which is
+// in the parent namespace, and deletes the namespaces
+func (f *NamespaceFanOut) Cleanup(client *api.Client) error {
+	var failed int
+	var lastErr error
+	for i, ns := range f.namespaces {
+		if i < len(f.targets) {
+			nsClient, err := client.CloneWithHeaders()
+			if err != nil {
+				return err
+			}
+			nsClient.SetToken(client.Token())
+			nsClient.SetNamespace(ns)
+			if err := f.targets[i].Cleanup(nsClient); err != nil {
+				failed++
+				lastErr = err
+			}
+		}
+		if _, err := client.Logical().Delete("sys/namespaces/" + path.Base(ns)); err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d errors cleaning up namespaces: %v", failed, lastErr)
+	}
+	return nil
+}
+
+// namespaceTransport names the namespace of each request on its response
+type namespaceTransport struct {
+	base http.RoundTripper
+}
+
+func (t *namespaceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		if ns := req.Header.Get("X-Vault-Namespace"); ns != "" {
+			resp.Header.Set(namespaceHeader, ns)
+		}
+	}
+	return resp, err
+}
+
+// recordNamespace adds a result to the metrics of the namespace its request
+// was sent to, when the tests were fanned out into namespaces
+func (r *Reporter) recordNamespace(result *vegeta.Result, latency time.Duration) {
+	ns := result.Headers.Get(namespaceHeader)
+	if ns == "" {
+		return
+	}
+	if r.nsMetrics == nil {
+		r.nsMetrics = make(map[string]*vegeta.Metrics)
+		r.nsHistograms = make(map[string]*Histogram)
+	}
+	m, ok := r.nsMetrics[ns]
+	if !ok {
+		m = &vegeta.Metrics{}
+		r.nsMetrics[ns] = m
+		r.nsHistograms[ns] = NewHistogram()
+	}
+	m.Add(result)
+	r.nsHistograms[ns].Record(latency)
+}
+
+func (r *Reporter) sortedNamespaces() []string {
+	namespaces := make([]string, 0, len(r.nsMetrics))
+	for ns := range r.nsMetrics {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// reportNamespacesTerse writes a table of the results of all tests by the
+// namespace they were sent to
+func (r *Reporter) reportNamespacesTerse(w io.Writer) {
+	if len(r.nsMetrics) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nnamespace\tcount\trate\tthroughput\tinMB/s\toutMB/s\tmean\t")
+	for _, p := range r.reportedPercentiles() {
+		if p == 100 {
+			fmt.Fprintf(w, "max\t")
+		} else {
+			fmt.Fprintf(w, "%s%%\t", percentileLabel(p))
+		}
+	}
+	fmt.Fprintf(w, "successRatio\n")
+	for _, ns := range r.sortedNamespaces() {
+		r.terseRow(w, ns, r.nsMetrics[ns], r.nsHistograms[ns])
+	}
+}
+
+// reportNamespacesVerbose writes the results of all tests by the namespace
+// they were sent to
+func (r *Reporter) reportNamespacesVerbose(w io.Writer) error {
+	for _, ns := range r.sortedNamespaces() {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "namespace "+ns)
+		if err := vegeta.NewTextReporter(r.nsMetrics[ns]).Report(w); err != nil {
+			return fmt.Errorf("report error: %v", err)
+		}
+	}
+	return nil
+}
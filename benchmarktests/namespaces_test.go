@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestNamespaceFanOut(t *testing.T) {
+	var mu sync.Mutex
+	var created, deleted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(req.URL.Path, "/v1/sys/namespaces/") && req.Method == http.MethodDelete:
+			deleted = append(deleted, req.Header.Get("X-Vault-Namespace")+"/"+strings.TrimPrefix(req.URL.Path, "/v1/sys/namespaces/"))
+		case strings.HasPrefix(req.URL.Path, "/v1/sys/namespaces/"):
+			created = append(created, req.Header.Get("X-Vault-Namespace")+"/"+strings.TrimPrefix(req.URL.Path, "/v1/sys/namespaces/"))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+	client.SetNamespace("team")
+
+	fanOut, err := CreateNamespaces(client, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(fanOut.Names(), ",") != "team/benchmark-ns-1,team/benchmark-ns-2" {
+		t.Fatalf("expected two namespaces under team, got %v", fanOut.Names())
+	}
+	if strings.Join(created, ",") != "team/benchmark-ns-1,team/benchmark-ns-2" {
+		t.Fatalf("expected the namespaces to be created in team, got %v", created)
+	}
+
+	// Tests set up in each namespace send their requests there
+	for _, ns := range fanOut.Names() {
+		ns := ns
+		fanOut.targets = append(fanOut.targets, &TargetMulti{targets: []BenchmarkTarget{{
+			Name:       "read",
+			Method:     "GET",
+			PathPrefix: "/v1/secret",
+			Weight:     100,
+			Builder:    &StatusCheck{},
+			Target: func(client *api.Client) vegeta.Target {
+				return vegeta.Target{
+					Method: "GET",
+					URL:    client.Address() + "/v1/secret/data/foo",
+					Header: http.Header{"X-Vault-Namespace": []string{ns}},
+				}
+			},
+		}}})
+	}
+	tm := fanOutTargets(fanOut.targets)
+
+	rpt := newReporter(tm, client)
+	httpClient := attackClient(client, &AttackConfig{Namespaces: fanOut.Names()})
+	for i := 0; i < 4; i++ {
+		tgt := tm.targets[0].Target(client)
+		req, err := tgt.Request()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		rpt.Add(&vegeta.Result{Method: "GET", URL: tgt.URL, Code: uint16(resp.StatusCode), Timestamp: time.Now(), Latency: time.Millisecond, Headers: resp.Header})
+	}
+	rpt.Close()
+	for _, ns := range fanOut.Names() {
+		if m := rpt.nsMetrics[ns]; m == nil || m.Requests != 2 {
+			t.Fatalf("expected the requests to be spread evenly across the namespaces, got %+v", rpt.nsMetrics)
+		}
+	}
+	if rpt.metrics["read"].Requests != 4 {
+		t.Fatalf("expected the results of the test to be aggregated across namespaces, got %d", rpt.metrics["read"].Requests)
+	}
+
+	var b bytes.Buffer
+	if err := rpt.ReportTerse(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "team/benchmark-ns-2") {
+		t.Errorf("expected a namespace table in the terse report:\n%s", b.String())
+	}
+
+	targetLogger = hclog.NewNullLogger()
+	if err := fanOut.Cleanup(client); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(deleted, ",") != "team/benchmark-ns-1,team/benchmark-ns-2" {
+		t.Fatalf("expected the namespaces to be deleted from team, got %v", deleted)
+	}
+}
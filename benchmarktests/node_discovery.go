@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"slices"
+	"sync"
+)
+
+// NodeSet holds the addresses of the nodes of a cluster as they are
+// discovered during a run. A balanced attack sends requests to the current
+// nodes, while results are told apart by every node it has known, as
+// requests to nodes which left may still complete.
+type NodeSet struct {
+	mu    sync.RWMutex
+	addrs []string
+	known []string
+}
+
+// NewNodeSet returns a set of the initially discovered nodes
+func NewNodeSet(addrs []string) *NodeSet {
+	return &NodeSet{addrs: slices.Clone(addrs), known: slices.Clone(addrs)}
+}
+
+// Addrs returns the addresses of the current nodes
+func (s *NodeSet) Addrs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.addrs
+}
+
+// Known returns the addresses of every node discovered so far
+func (s *NodeSet) Known() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.known
+}
+
+// Set replaces the current nodes with newly discovered ones, returning the
+// nodes which joined and left. An empty discovery is ignored, so requests
+// keep going to the last nodes known.
+func (s *NodeSet) Set(addrs []string) (joined, left []string) {
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, addr := range addrs {
+		if !slices.Contains(s.addrs, addr) {
+			joined = append(joined, addr)
+		}
+		if !slices.Contains(s.known, addr) {
+			s.known = append(s.known, addr)
+		}
+	}
+	for _, addr := range s.addrs {
+		if !slices.Contains(addrs, addr) {
+			left = append(left, addr)
+		}
+	}
+	// The current nodes are replaced rather than updated in place, as
+	// requests being balanced may hold the previous slice
+	s.addrs = slices.Clone(addrs)
+	return joined, left
+}
@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestNodeSet(t *testing.T) {
+	nodes := NewNodeSet([]string{"http://a:8200", "http://b:8200"})
+	joined, left := nodes.Set([]string{"http://b:8200", "http://c:8200"})
+	if !reflect.DeepEqual(joined, []string{"http://c:8200"}) || !reflect.DeepEqual(left, []string{"http://a:8200"}) {
+		t.Fatalf("expected c to join and a to leave, got %v and %v", joined, left)
+	}
+	if joined, left := nodes.Set(nil); joined != nil || left != nil || len(nodes.Addrs()) != 2 {
+		t.Fatalf("expected an empty discovery to be ignored, got %v", nodes.Addrs())
+	}
+	if want := []string{"http://a:8200", "http://b:8200", "http://c:8200"}; !reflect.DeepEqual(nodes.Known(), want) {
+		t.Fatalf("got known nodes %v, want %v", nodes.Known(), want)
+	}
+}
+
+func TestBalancer_DiscoveredNodes(t *testing.T) {
+	nodes := NewNodeSet([]string{"http://a:8200"})
+	config := &LoadBalanceConfig{Strategy: RoundRobinBalance, Addrs: []string{"http://a:8200"}, Nodes: nodes}
+	b, err := newBalancer(config, "http://a:8200", OpenLoopAttackMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := b.targeter(func(tgt *vegeta.Target) error {
+		tgt.URL = "http://a:8200/v1/kv/data/secret"
+		return nil
+	}, 0)
+
+	send := func() string {
+		var tgt vegeta.Target
+		if err := tr(&tgt); err != nil {
+			t.Fatal(err)
+		}
+		return tgt.URL
+	}
+	if url := send(); url != "http://a:8200/v1/kv/data/secret" {
+		t.Fatalf("expected the only node to be sent to, got %v", url)
+	}
+	nodes.Set([]string{"http://b:8200"})
+	url := send()
+	if url != "http://b:8200/v1/kv/data/secret" {
+		t.Fatalf("expected requests to follow the nodes, got %v", url)
+	}
+
+	// Results of nodes which joined are broken down by node
+	rpt := newReporter(&TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/kv"}}}, nil)
+	rpt.addrs = config.Addrs
+	rpt.nodes = nodes
+	rpt.Add(&vegeta.Result{Method: "GET", URL: url, Code: 200, Timestamp: time.Now(), Latency: time.Millisecond})
+	if m := rpt.addrMetrics["http://b:8200"]; m == nil || m.Requests != 1 {
+		t.Fatalf("expected the result of the new node to be recorded, got %+v", rpt.addrMetrics)
+	}
+
+	config.Strategy = WeightedBalance
+	config.Weights = []int{1}
+	if err := config.Validate(OpenLoopAttackMode); err == nil || !strings.Contains(err.Error(), "cannot be combined with the weighted strategy") {
+		t.Fatalf("expected refreshing nodes to be rejected with weights, got %v", err)
+	}
+}
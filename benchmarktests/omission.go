@@ -0,0 +1,65 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// sendSchedule wraps the pacer of an open loop attack to remember when
+// each hit was meant to be sent. When the attacker falls behind, e.g.
+// because every worker is busy waiting on a slow response, hits are sent
+// late and their latency alone hides the time they spent waiting to be
+// sent: the coordinated omission problem. Measuring from the intended send
+// time instead corrects for it.
+//
+// The intended time of a hit is read from the wrapped pacer by asking it
+// how long to wait for that hit from the start of the attack. All pacers
+// used by Attack answer this with the hit's position in their schedule,
+// however far behind the attack is.
+type sendSchedule struct {
+	vegeta.Pacer
+
+	mu       sync.Mutex
+	began    time.Time
+	intended map[uint64]time.Time
+}
+
+func newSendSchedule(p vegeta.Pacer) *sendSchedule {
+	return &sendSchedule{Pacer: p, intended: make(map[uint64]time.Time)}
+}
+
+func (s *sendSchedule) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	offset, stop := s.Pacer.Pace(0, hits)
+	if stop {
+		return 0, true
+	}
+
+	s.mu.Lock()
+	if s.began.IsZero() {
+		s.began = time.Now().Add(-elapsed)
+	}
+	s.intended[hits] = s.began.Add(offset)
+	s.mu.Unlock()
+
+	return s.Pacer.Pace(elapsed, hits)
+}
+
+// delay returns how long after its intended send time the result was sent.
+// Results are numbered in the order they were paced, so a result's
+// sequence number identifies its hit.
+func (s *sendSchedule) delay(res *vegeta.Result) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intended, ok := s.intended[res.Seq]
+	if !ok {
+		return 0
+	}
+	delete(s.intended, res.Seq)
+	return max(res.Timestamp.Sub(intended), 0)
+}
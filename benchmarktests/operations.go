@@ -0,0 +1,75 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"sort"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// OperationNamer is implemented by tests which send several kinds of
+// request in one attack, such as a mix of reads and writes, so each kind
+// is reported on its own as well as in the blended results of the test
+type OperationNamer interface {
+	// Operation names the operation performed by a request to path, the
+	// URL path without the server address, or returns "" if the request
+	// wasn't sent by the test. Requests of any method named by the test
+	// are matched to it.
+	Operation(method, path string) string
+}
+
+// operation returns the name of the operation of a result matched to a
+// target, or "" if the target's test doesn't name its operations
+func (r *Reporter) operation(target *BenchmarkTarget, result *vegeta.Result) string {
+	namer, ok := target.Builder.(OperationNamer)
+	if !ok {
+		return ""
+	}
+	_, path := r.splitURL(result.URL)
+	return namer.Operation(result.Method, path)
+}
+
+// recordOperation adds a result of the named test to the metrics of its
+// operation
+func (r *Reporter) recordOperation(name, op string, result *vegeta.Result, latency time.Duration) {
+	if r.opMetrics == nil {
+		r.opMetrics = make(map[string]map[string]*vegeta.Metrics)
+		r.opHistograms = make(map[string]map[string]*Histogram)
+	}
+	if _, ok := r.opMetrics[name]; !ok {
+		r.opMetrics[name] = make(map[string]*vegeta.Metrics)
+		r.opHistograms[name] = make(map[string]*Histogram)
+	}
+	m, ok := r.opMetrics[name][op]
+	if !ok {
+		m = &vegeta.Metrics{}
+		r.opMetrics[name][op] = m
+		r.opHistograms[name][op] = NewHistogram()
+	}
+	m.Add(result)
+	r.opHistograms[name][op].Record(latency)
+}
+
+// sortedOperations returns the operations seen by the named test, in
+// order. Tests which only performed one operation have nothing to break
+// down, so none are returned.
+func (r *Reporter) sortedOperations(name string) []string {
+	if len(r.opMetrics[name]) < 2 {
+		return nil
+	}
+	ops := make([]string, 0, len(r.opMetrics[name]))
+	for op := range r.opMetrics[name] {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// operationLabel is the label of the results of an operation of a test in
+// reports
+func operationLabel(name, op string) string {
+	return name + "/" + op
+}
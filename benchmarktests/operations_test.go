@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestReporter_Operations(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "mixed", Method: "GET", PathPrefix: "/v1/kv", Builder: &KVV2Test{action: "mixed", pathPrefix: "/v1/kv"}},
+		{Name: "other", Method: "POST", PathPrefix: "/v1/other"},
+	}}
+	rpt := newReporter(tm, nil)
+	began := time.Now()
+	for i := 0; i < 9; i++ {
+		rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/kv/data/secret-1", Code: 200, Timestamp: began, Latency: time.Millisecond})
+	}
+	rpt.Add(&vegeta.Result{Method: "POST", URL: "N/A/v1/kv/data/secret-1", Code: 500, Timestamp: began, Latency: 50 * time.Millisecond, Error: "500 Internal Server Error"})
+	rpt.Add(&vegeta.Result{Method: "POST", URL: "N/A/v1/other", Code: 200, Timestamp: began, Latency: time.Millisecond})
+	rpt.Close()
+
+	if got := rpt.metrics["mixed"].Requests; got != 10 {
+		t.Fatalf("expected the writes of the mixed test to match it, got %d requests", got)
+	}
+	if got := rpt.metrics["other"].Requests; got != 1 {
+		t.Fatalf("expected 1 request of the other test, got %d", got)
+	}
+	if ops := rpt.sortedOperations("mixed"); len(ops) != 2 || ops[0] != "read" || ops[1] != "write" {
+		t.Fatalf("unexpected operations: %v", ops)
+	}
+	if read, write := rpt.opMetrics["mixed"]["read"], rpt.opMetrics["mixed"]["write"]; read.Requests != 9 || read.Success != 1 || write.Requests != 1 || write.Success != 0 {
+		t.Fatalf("unexpected operation metrics: read %+v, write %+v", read, write)
+	}
+	if ops := rpt.sortedOperations("other"); len(ops) != 0 {
+		t.Fatalf("expected no operations of a test which doesn't name them, got %v", ops)
+	}
+
+	var terse bytes.Buffer
+	if err := rpt.ReportTerse(&terse); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"mixed/read", "mixed/write"} {
+		if !strings.Contains(terse.String(), want) {
+			t.Errorf("expected %q in terse report:\n%s", want, terse.String())
+		}
+	}
+
+	// The breakdown survives a round trip through the JSON report
+	var j bytes.Buffer
+	if err := rpt.ReportJSON(&j); err != nil {
+		t.Fatal(err)
+	}
+	rpts, err := FromReader(&j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rpts[0].opMetrics["mixed"]["write"].Requests; got != 1 {
+		t.Fatalf("expected 1 write after decoding, got %d", got)
+	}
+}
+
+func TestKVV2Test_Operation(t *testing.T) {
+	mixed := &KVV2Test{action: "mixed", pathPrefix: "/v1/kv"}
+	cases := []struct {
+		method, path, want string
+	}{
+		{"GET", "/v1/kv/data/secret-1", "read"},
+		{"POST", "/v1/kv/data/secret-1", "write"},
+		{"LIST", "/v1/kv/metadata", ""},
+		{"GET", "/v1/kv2/data/secret-1", ""},
+	}
+	for _, tc := range cases {
+		if got := mixed.Operation(tc.method, tc.path); got != tc.want {
+			t.Errorf("Operation(%v, %v): expected %q, got %q", tc.method, tc.path, tc.want, got)
+		}
+	}
+
+	read := &KVV2Test{action: "read", pathPrefix: "/v1/kv"}
+	if got := read.Operation("GET", "/v1/kv/data/secret-1"); got != "" {
+		t.Errorf("expected the read test not to name operations, got %q", got)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http"
+
+	// defaultOTLPExportInterval is how often metrics are exported when no
+	// report interval is configured
+	defaultOTLPExportInterval = 10 * time.Second
+)
+
+// The instruments are created from the global meter provider, which
+// discards measurements until StartOTelMetrics installs an exporting one.
+var (
+	otelMeter = otel.Meter("github.com/openbao/benchmark-openbao")
+
+	otelRequests, _ = otelMeter.Int64Counter("bench.requests",
+		metric.WithDescription("Number of requests sent by the benchmark."),
+		metric.WithUnit("{request}"))
+	otelErrors, _ = otelMeter.Int64Counter("bench.errors",
+		metric.WithDescription("Number of requests which failed."),
+		metric.WithUnit("{request}"))
+	otelDuration, _ = otelMeter.Float64Histogram("bench.request.duration",
+		metric.WithDescription("Latency of requests sent by the benchmark."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10))
+)
+
+// StartOTelMetrics exports the request, error and latency series of every
+// test over OTLP to the given endpoint URL, using either the grpc or http
+// protocol. The run ID and labels are added to the exported resource so
+// results of different runs can be told apart. The returned function flushes any
+// remaining metrics and stops the exporter.
+func StartOTelMetrics(ctx context.Context, endpoint, protocol, runID string, labels map[string]string, interval time.Duration) (func(context.Context) error, error) {
+	var exporter sdkmetric.Exporter
+	var err error
+	switch protocol {
+	case OTLPProtocolGRPC, "":
+		exporter, err = otlpmetricgrpc.New(ctx, otlpmetricgrpc.WithEndpointURL(endpoint))
+	case OTLPProtocolHTTP:
+		exporter, err = otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpointURL(endpoint))
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q, must be grpc or http", protocol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP metric exporter: %v", err)
+	}
+
+	if interval <= 0 {
+		interval = defaultOTLPExportInterval
+	}
+
+	res, err := otelResource(runID, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, sdkmetric.WithInterval(interval))),
+	)
+	otel.SetMeterProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// otelResource describes the benchmark run in exported metrics and spans.
+// Each label of the run is added as a benchmark.<name> attribute.
+func otelResource(runID string, labels map[string]string) (*resource.Resource, error) {
+	attrs := []attribute.KeyValue{
+		attribute.String("service.name", "benchmark-openbao"),
+		attribute.String("benchmark.run_id", runID),
+	}
+	for _, name := range sortedLabelNames(labels) {
+		attrs = append(attrs, attribute.String("benchmark."+name, labels[name]))
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attrs...))
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP resource: %v", err)
+	}
+	return res, nil
+}
+
+// recordOTelResult records a single result of the named test
+func (r *Reporter) recordOTelResult(name string, result *vegeta.Result) {
+	ctx := context.Background()
+	attrs := []attribute.KeyValue{
+		attribute.String("target", r.clientAddr),
+		attribute.String("test", name),
+	}
+	if r.phase != "" {
+		attrs = append(attrs, attribute.String("phase", r.phase))
+	}
+
+	otelDuration.Record(ctx, result.Latency.Seconds(), metric.WithAttributes(attrs...))
+	if result.Error != "" {
+		otelErrors.Add(ctx, 1, metric.WithAttributes(attrs...))
+	}
+	attrs = append(attrs, attribute.String("code", strconv.Itoa(int(result.Code))))
+	otelRequests.Add(ctx, 1, metric.WithAttributes(attrs...))
+}
@@ -0,0 +1,70 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestStartOTelMetrics_UnknownProtocol(t *testing.T) {
+	_, err := StartOTelMetrics(context.Background(), "http://localhost:4317", "udp", "run", nil, 0)
+	if err == nil || !strings.Contains(err.Error(), "unknown OTLP protocol") {
+		t.Fatalf("expected an unknown protocol error, got: %v", err)
+	}
+}
+
+func TestStartOTelMetrics_HTTP(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		paths = append(paths, req.URL.Path)
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer srv.Close()
+
+	shutdown, err := StartOTelMetrics(context.Background(), srv.URL+"/v1/metrics", OTLPProtocolHTTP, "otel-run", map[string]string{"env": "otel-env"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 200, Timestamp: time.Now(), Latency: 10 * time.Millisecond})
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 500, Timestamp: time.Now(), Latency: 20 * time.Millisecond, Error: "500 Internal Server Error"})
+	rpt.Close()
+
+	// Shutting down flushes the recorded metrics
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) == 0 {
+		t.Fatal("expected metrics to be exported")
+	}
+	if paths[0] != "/v1/metrics" {
+		t.Fatalf("expected metrics to be exported to /v1/metrics, got: %v", paths[0])
+	}
+	exported := strings.Join(bodies, "")
+	for _, want := range []string{"bench.requests", "bench.errors", "bench.request.duration", "otel-run", "benchmark.env", "otel-env", "read"} {
+		if !strings.Contains(exported, want) {
+			t.Fatalf("expected %q in the exported metrics", want)
+		}
+	}
+}
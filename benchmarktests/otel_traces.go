@@ -0,0 +1,125 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestTracer creates a span for every request sent by an attack. It is
+// nil, and requests aren't traced, until StartOTelTracing is called.
+var requestTracer trace.Tracer
+
+// StartOTelTracing traces a sampled share of the requests sent by every
+// attack, exporting the spans over OTLP to the given endpoint URL using
+// either the grpc or http protocol. The trace context of each request is
+// sent to OpenBao in the traceparent header, so its server side spans can
+// be correlated with the benchmark's. The returned function flushes any
+// remaining spans and stops the exporter.
+func StartOTelTracing(ctx context.Context, endpoint, protocol, runID string, labels map[string]string, sampleRatio float64) (func(context.Context) error, error) {
+	if sampleRatio <= 0 || sampleRatio > 1 {
+		return nil, fmt.Errorf("trace sample ratio must be greater than 0 and at most 1")
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch protocol {
+	case OTLPProtocolGRPC, "":
+		exporter, err = otlptracegrpc.New(ctx, otlptracegrpc.WithEndpointURL(endpoint))
+	case OTLPProtocolHTTP:
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	default:
+		return nil, fmt.Errorf("unknown OTLP protocol %q, must be grpc or http", protocol)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP trace exporter: %v", err)
+	}
+
+	res, err := otelResource(runID, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.TraceIDRatioBased(sampleRatio)),
+		sdktrace.WithBatcher(exporter),
+	)
+	requestTracer = provider.Tracer("github.com/openbao/benchmark-openbao")
+	return provider.Shutdown, nil
+}
+
+// tracedClient returns a copy of the client whose requests are traced, or
+// the client itself when tracing isn't enabled
+func tracedClient(client *http.Client) *http.Client {
+	if requestTracer == nil || client == nil {
+		return client
+	}
+
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	traced := *client
+	traced.Transport = &tracingTransport{base: base, tracer: requestTracer}
+	return &traced
+}
+
+// tracingTransport starts a client span for each request and injects its
+// context into the request headers. The span ends once the response body
+// has been read and closed, so its duration matches the request latency.
+type tracingTransport struct {
+	base   http.RoundTripper
+	tracer trace.Tracer
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := t.tracer.Start(req.Context(), req.Method+" "+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", req.Method),
+			attribute.String("url.path", req.URL.Path),
+			attribute.String("server.address", req.URL.Host),
+		))
+
+	req = req.Clone(ctx)
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.End()
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+	}
+	resp.Body = &tracedBody{ReadCloser: resp.Body, span: span}
+	return resp, nil
+}
+
+type tracedBody struct {
+	io.ReadCloser
+	span trace.Span
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.span.End()
+	return err
+}
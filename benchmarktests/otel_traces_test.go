@@ -0,0 +1,77 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestStartOTelTracing_InvalidRatio(t *testing.T) {
+	for _, ratio := range []float64{0, -0.5, 1.5} {
+		if _, err := StartOTelTracing(context.Background(), "http://localhost:4317", OTLPProtocolGRPC, "run", nil, ratio); err == nil {
+			t.Fatalf("expected an error for sample ratio %v", ratio)
+		}
+	}
+}
+
+func TestTracingTransport(t *testing.T) {
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceparent = req.Header.Get("traceparent")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte("permission denied"))
+	}))
+	defer srv.Close()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	client := &http.Client{Transport: &tracingTransport{base: http.DefaultTransport, tracer: provider.Tracer("test")}}
+
+	resp, err := client.Get(srv.URL + "/v1/secret/data/foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exporter.GetSpans()) != 0 {
+		t.Fatal("expected the span to stay open until the body is closed")
+	}
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected a single span, got: %v", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /v1/secret/data/foo" {
+		t.Fatalf("unexpected span name: %v", span.Name)
+	}
+	if span.Status.Code != codes.Error {
+		t.Fatalf("expected an error status for a 403, got: %v", span.Status.Code)
+	}
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, attr := range span.Attributes {
+		attrs[attr.Key] = attr.Value
+	}
+	if got := attrs["url.path"].AsString(); got != "/v1/secret/data/foo" {
+		t.Fatalf("unexpected url.path: %v", got)
+	}
+	if got := attrs["http.response.status_code"].AsInt64(); got != http.StatusForbidden {
+		t.Fatalf("unexpected http.response.status_code: %v", got)
+	}
+
+	if !strings.Contains(traceparent, span.SpanContext.TraceID().String()) {
+		t.Fatalf("expected the trace context to be sent to the server, got traceparent: %q", traceparent)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// ConstantArrival spaces requests evenly at the configured rate
+	ConstantArrival = "constant"
+
+	// PoissonArrival draws the time between requests from an exponential
+	// distribution whose mean matches the configured rate
+	PoissonArrival = "poisson"
+)
+
+// newPacer returns the vegeta.Pacer matching the arrival process requested
+// in the attack configuration
+func newPacer(config *AttackConfig) (vegeta.Pacer, error) {
+	switch config.Arrival {
+	case ConstantArrival, "":
+		return vegeta.Rate{Freq: config.RPS, Per: time.Second}, nil
+	case PoissonArrival:
+		if config.RPS <= 0 {
+			return nil, fmt.Errorf("poisson arrival requires a rps greater than 0")
+		}
+		return NewPoissonPacer(config.RPS, time.Second, time.Now().UnixNano()), nil
+	default:
+		return nil, fmt.Errorf("unknown arrival process: %v", config.Arrival)
+	}
+}
+
+// PoissonPacer paces hits so that inter-arrival times are exponentially
+// distributed around the configured mean rate. Unlike the constant pacer
+// this produces the bursts and lulls of many independent clients, which is
+// closer to what a server sees from real traffic.
+type PoissonPacer struct {
+	Freq int           // Mean number of hits per ...
+	Per  time.Duration // Time unit, usually 1s
+
+	rnd       *rand.Rand
+	scheduled uint64
+	next      time.Duration
+}
+
+var _ vegeta.Pacer = (*PoissonPacer)(nil)
+
+// NewPoissonPacer creates a PoissonPacer with its own random source. The
+// pacer is only ever called from the attacker's pacing goroutine so the
+// source does not need to be safe for concurrent use.
+func NewPoissonPacer(freq int, per time.Duration, seed int64) *PoissonPacer {
+	return &PoissonPacer{
+		Freq: freq,
+		Per:  per,
+		rnd:  rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (p *PoissonPacer) String() string {
+	return fmt.Sprintf("Poisson{%d hits/%s}", p.Freq, p.Per)
+}
+
+// Pace returns the time to wait until the arrival of the next hit. Arrival
+// times are drawn lazily, one per hit, so the schedule only ever advances.
+func (p *PoissonPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	switch {
+	case p.Per == 0 || p.Freq == 0:
+		return 0, false
+	case p.Per < 0 || p.Freq < 0:
+		return 0, true
+	}
+
+	mean := float64(p.Per) / float64(p.Freq)
+	for p.scheduled <= hits {
+		p.next += time.Duration(p.rnd.ExpFloat64() * mean)
+		p.scheduled++
+	}
+
+	return p.next - elapsed, false
+}
+
+// Rate returns the mean hit rate per second of the pacer
+func (p *PoissonPacer) Rate(elapsed time.Duration) float64 {
+	if p.Per <= 0 {
+		return 0
+	}
+	return float64(p.Freq) / p.Per.Seconds()
+}
+
+// requestLimitPacer stops an attack once Max hits have been sent, pacing
+// them with the wrapped Pacer until then
+type requestLimitPacer struct {
+	vegeta.Pacer
+	Max uint64
+}
+
+func (p *requestLimitPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	if hits >= p.Max {
+		return 0, true
+	}
+	return p.Pacer.Pace(elapsed, hits)
+}
+
+// BurstConfig describes periodic spikes of extra load layered on top of the
+// base rate of an attack. Every Interval, starting one Interval into the
+// attack, an extra RPS requests per second are sent for Duration.
+type BurstConfig struct {
+	RPS      int
+	Duration time.Duration
+	Interval time.Duration
+}
+
+func (b *BurstConfig) validate() error {
+	switch {
+	case b.RPS <= 0:
+		return fmt.Errorf("burst rps must be greater than 0")
+	case b.Duration <= 0 || b.Interval <= 0:
+		return fmt.Errorf("burst duration and interval must be greater than 0")
+	case b.Duration >= b.Interval:
+		return fmt.Errorf("burst duration must be shorter than the burst interval")
+	}
+	return nil
+}
+
+// inBurst reports whether the given point in the attack falls within one
+// of the burst windows
+func (b *BurstConfig) inBurst(elapsed time.Duration) bool {
+	return elapsed >= b.Interval && elapsed%b.Interval < b.Duration
+}
+
+// BurstPacer only sends hits during the burst windows described by its
+// BurstConfig, spacing them evenly at the burst rate within each window.
+// It is run alongside the base pacer to produce the spikes.
+type BurstPacer struct {
+	Burst BurstConfig
+}
+
+var _ vegeta.Pacer = (*BurstPacer)(nil)
+
+func (p *BurstPacer) String() string {
+	return fmt.Sprintf("Burst{%d hits/1s for %s every %s}", p.Burst.RPS, p.Burst.Duration, p.Burst.Interval)
+}
+
+// Pace returns the time until the scheduled send of the next hit, which is
+// found by counting whole bursts already sent and then spacing the hit
+// within its burst.
+func (p *BurstPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	if p.Burst.RPS <= 0 || p.Burst.Duration <= 0 {
+		return 0, true
+	}
+
+	perBurst := uint64(float64(p.Burst.RPS) * p.Burst.Duration.Seconds())
+	if perBurst == 0 {
+		perBurst = 1
+	}
+	spacing := time.Second / time.Duration(p.Burst.RPS)
+
+	burst := hits / perBurst
+	next := time.Duration(burst+1)*p.Burst.Interval + time.Duration(hits%perBurst)*spacing
+	return next - elapsed, false
+}
+
+// Rate returns the instantaneous burst rate at the given point in the
+// attack, which is zero outside of the burst windows
+func (p *BurstPacer) Rate(elapsed time.Duration) float64 {
+	if p.Burst.inBurst(elapsed) {
+		return float64(p.Burst.RPS)
+	}
+	return 0
+}
@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestPoissonPacer_MeanRate(t *testing.T) {
+	p := NewPoissonPacer(100, time.Second, 1)
+
+	// Drive the pacer as the attacker would, jumping straight to each
+	// scheduled arrival, and check the mean rate over many hits.
+	const hits = 20000
+	var elapsed time.Duration
+	for i := uint64(0); i < hits; i++ {
+		wait, stop := p.Pace(elapsed, i)
+		if stop {
+			t.Fatalf("expected pacer not to stop at hit %d", i)
+		}
+		if wait > 0 {
+			elapsed += wait
+		}
+	}
+
+	rate := float64(hits) / elapsed.Seconds()
+	if math.Abs(rate-100) > 5 {
+		t.Fatalf("expected a mean rate close to 100, got: %f", rate)
+	}
+}
+
+func TestPoissonPacer_ScheduleStable(t *testing.T) {
+	p := NewPoissonPacer(10, time.Second, 1)
+
+	first, _ := p.Pace(0, 0)
+	again, _ := p.Pace(0, 0)
+	if first != again {
+		t.Fatalf("expected repeated calls for the same hit to agree, got: %v and %v", first, again)
+	}
+}
+
+func TestBurstPacer_Schedule(t *testing.T) {
+	p := &BurstPacer{Burst: BurstConfig{RPS: 10, Duration: time.Second, Interval: time.Minute}}
+
+	// The first burst starts one interval in
+	wait, stop := p.Pace(0, 0)
+	if stop || wait != time.Minute {
+		t.Fatalf("expected first hit after one interval, got: %v (stop %v)", wait, stop)
+	}
+
+	// Hits within a burst are spaced at the burst rate
+	wait, _ = p.Pace(time.Minute, 1)
+	if wait != 100*time.Millisecond {
+		t.Fatalf("expected second hit 100ms into the burst, got: %v", wait)
+	}
+
+	// Once a burst's worth of hits are sent, wait for the next window
+	wait, _ = p.Pace(time.Minute+time.Second, 10)
+	if wait != time.Minute-time.Second {
+		t.Fatalf("expected to wait for the next burst, got: %v", wait)
+	}
+
+	if p.Rate(30*time.Second) != 0 || p.Rate(time.Minute+500*time.Millisecond) != 10 {
+		t.Fatal("expected rate to be non-zero only within burst windows")
+	}
+}
+
+func TestRequestLimitPacer(t *testing.T) {
+	p := &requestLimitPacer{Pacer: vegeta.Rate{Freq: 0, Per: time.Second}, Max: 3}
+	for hits := uint64(0); hits < 3; hits++ {
+		if _, stop := p.Pace(0, hits); stop {
+			t.Fatalf("expected pacer not to stop after %d hits", hits)
+		}
+	}
+	if _, stop := p.Pace(0, 3); !stop {
+		t.Fatal("expected pacer to stop once the request limit was reached")
+	}
+}
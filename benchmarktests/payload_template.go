@@ -0,0 +1,338 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/go-uuid"
+)
+
+const (
+	// payloadBatchSize is how many payloads are rendered at once, ahead of
+	// the requests they are sent with, and payloadBatchBytes the size at
+	// which a batch of large payloads is cut short
+	payloadBatchSize  = 256
+	payloadBatchBytes = 1 << 20
+
+	// maxPayloadLength is the longest random string a payload template may
+	// ask for
+	maxPayloadLength = 1 << 20
+
+	alphaChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	lowerChars = "abcdefghijklmnopqrstuvwxyz"
+)
+
+// payloadTemplate is a value of a test config rendered anew for every
+// request, in which functions written between {{ and }} are replaced by
+// generated data:
+//
+//	{{rand_alpha N}}  N random letters
+//	{{uuid}}          a random UUID
+//	{{seq}}           the number of the request, starting at 1
+//	{{rand_email}}    a random email address at example.com
+//	{{field NAME}}    the column NAME of the row of the data file
+//	{{var NAME}}      the variable NAME captured by an earlier step of a
+//	                  scenario
+type payloadTemplate struct {
+	parts []payloadPart
+
+	// static is set when the template has no functions, so renders to the
+	// same value every time
+	static bool
+}
+
+// payloadPart is either literal text or a function of the request the
+// template is rendered for
+type payloadPart struct {
+	text string
+	fn   func(r *payloadRender) string
+}
+
+// payloadRender is the request a payload is rendered for
+type payloadRender struct {
+	seq int64
+
+	// row is the row of the data file of the test given to the request,
+	// if the test has one
+	row map[string]string
+
+	// vars are the variables captured by the steps of a scenario before
+	// the request
+	vars map[string]string
+}
+
+// parsePayloadTemplate parses a payload template, failing on unknown
+// functions, unclosed braces and fields which aren't columns of the rows,
+// which may be nil when the test has no data file
+func parsePayloadTemplate(s string, rows *dataRows) (*payloadTemplate, error) {
+	return parseVarsTemplate(s, rows, nil)
+}
+
+// parseVarsTemplate parses a payload template which may also use the
+// variables named, failing on any others
+func parseVarsTemplate(s string, rows *dataRows, vars map[string]bool) (*payloadTemplate, error) {
+	t := &payloadTemplate{static: true}
+	for {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed {{ in %q", s)
+		}
+		if start > 0 {
+			t.parts = append(t.parts, payloadPart{text: s[:start]})
+		}
+		fn, err := payloadFunc(strings.Fields(s[start+2:start+end]), rows, vars)
+		if err != nil {
+			return nil, err
+		}
+		t.parts = append(t.parts, payloadPart{fn: fn})
+		t.static = false
+		s = s[start+end+2:]
+	}
+	if s != "" {
+		t.parts = append(t.parts, payloadPart{text: s})
+	}
+	return t, nil
+}
+
+// payloadFunc returns the function called with its arguments in a payload
+// template
+func payloadFunc(call []string, rows *dataRows, vars map[string]bool) (func(r *payloadRender) string, error) {
+	if len(call) == 0 {
+		return nil, fmt.Errorf("empty {{}} in payload template")
+	}
+	name, args := call[0], call[1:]
+	wantArgs := 0
+	if name == "rand_alpha" || name == "field" || name == "var" {
+		wantArgs = 1
+	}
+	if len(args) != wantArgs {
+		return nil, fmt.Errorf("%v takes %d arguments, got %d", name, wantArgs, len(args))
+	}
+
+	switch name {
+	case "rand_alpha":
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > maxPayloadLength {
+			return nil, fmt.Errorf("length of rand_alpha must be between 1 and %d, got %v", maxPayloadLength, args[0])
+		}
+		return func(*payloadRender) string { return randString(alphaChars, n) }, nil
+	case "uuid":
+		return func(*payloadRender) string {
+			id, err := uuid.GenerateUUID()
+			if err != nil {
+				panic(fmt.Sprintf("can't create UUID: %v", err))
+			}
+			return id
+		}, nil
+	case "seq":
+		return func(r *payloadRender) string { return strconv.FormatInt(r.seq, 10) }, nil
+	case "rand_email":
+		return func(*payloadRender) string { return randString(lowerChars, 12) + "@example.com" }, nil
+	case "field":
+		column := args[0]
+		if rows == nil {
+			return nil, fmt.Errorf("field %v requires a data_file", column)
+		}
+		if !rows.columns[column] {
+			return nil, fmt.Errorf("data file %v has no column %v", rows.path, column)
+		}
+		return func(r *payloadRender) string { return r.row[column] }, nil
+	case "var":
+		variable := args[0]
+		if vars == nil {
+			return nil, fmt.Errorf("var %v may only be used in the steps of a scenario", variable)
+		}
+		if !vars[variable] {
+			return nil, fmt.Errorf("variable %v isn't captured by an earlier step", variable)
+		}
+		return func(r *payloadRender) string { return r.vars[variable] }, nil
+	default:
+		return nil, fmt.Errorf("unknown payload template function: %v", name)
+	}
+}
+
+// render returns the value of the template for the request
+func (t *payloadTemplate) render(r *payloadRender) string {
+	var b strings.Builder
+	for _, part := range t.parts {
+		if part.fn != nil {
+			b.WriteString(part.fn(r))
+		} else {
+			b.WriteString(part.text)
+		}
+	}
+	return b.String()
+}
+
+func randString(chars string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = chars[rand.Intn(len(chars))]
+	}
+	return string(b)
+}
+
+// payload is what is rendered for a request: the key it is sent to, for
+// tests which choose one, and its body
+type payload struct {
+	key  string
+	body []byte
+}
+
+// payloadGenerator hands out the payloads of requests, rendering them in
+// batches so generating them doesn't slow down sending requests. Batches
+// are kept to payloadBatchBytes, so large payloads don't hold up the
+// requests waiting on the batch being rendered for long. It is safe for
+// concurrent use.
+type payloadGenerator struct {
+	rows   *dataRows
+	render func(r *payloadRender) payload
+
+	lock  sync.Mutex
+	seq   int64
+	batch []payload
+}
+
+// newPayloadGenerator returns a generator rendering payloads for requests
+// given the rows of the data file in turn, or without rows when rows is nil
+func newPayloadGenerator(rows *dataRows, render func(r *payloadRender) payload) *payloadGenerator {
+	return &payloadGenerator{rows: rows, render: render}
+}
+
+// next returns the payload of the next request
+func (g *payloadGenerator) next() payload {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	if len(g.batch) == 0 {
+		size := 0
+		for len(g.batch) < payloadBatchSize && size < payloadBatchBytes {
+			g.seq++
+			r := &payloadRender{seq: g.seq}
+			if g.rows != nil {
+				r.row = g.rows.row(g.seq)
+			}
+			p := g.render(r)
+			size += len(p.key) + len(p.body)
+			g.batch = append(g.batch, p)
+		}
+	}
+	p := g.batch[0]
+	g.batch = g.batch[1:]
+	return p
+}
+
+// newDataPayloads returns a generator of the bodies of requests writing the
+// data, whose values are payload templates, as {"data": {...}}, sent to
+// the key rendered from the key template when it isn't empty. It returns
+// nil when there is neither data nor a key.
+func newDataPayloads(key string, data map[string]string, rows *dataRows) (*payloadGenerator, error) {
+	if key == "" && len(data) == 0 {
+		return nil, nil
+	}
+	var keyTemplate *payloadTemplate
+	if key != "" {
+		t, err := parsePayloadTemplate(key, rows)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key: %v", err)
+		}
+		keyTemplate = t
+	}
+	templates := make(map[string]*payloadTemplate, len(data))
+	for name, value := range data {
+		t, err := parsePayloadTemplate(value, rows)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data %v: %v", name, err)
+		}
+		templates[name] = t
+	}
+
+	return newPayloadGenerator(rows, func(r *payloadRender) payload {
+		var p payload
+		if keyTemplate != nil {
+			p.key = keyTemplate.render(r)
+		}
+		if len(templates) > 0 {
+			values := make(map[string]string, len(templates))
+			for name, t := range templates {
+				values[name] = t.render(r)
+			}
+			body, err := json.Marshal(map[string]interface{}{"data": values})
+			if err != nil {
+				panic(fmt.Sprintf("can't encode payload: %v", err))
+			}
+			p.body = body
+		}
+		return p
+	}), nil
+}
+
+// newBodyPayloads returns a generator of JSON bodies of the data, in which
+// the string values of the fields named are payload templates. It returns
+// nil when none of them use a function, so the body is always the same.
+func newBodyPayloads(data map[string]interface{}, fields []string, rows *dataRows) (*payloadGenerator, error) {
+	templates := make(map[string]*payloadTemplate)
+	for _, name := range fields {
+		value, ok := data[name].(string)
+		if !ok {
+			continue
+		}
+		t, err := parsePayloadTemplate(value, rows)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v: %v", name, err)
+		}
+		if !t.static {
+			templates[name] = t
+		}
+	}
+	if len(templates) == 0 {
+		return nil, nil
+	}
+
+	return newPayloadGenerator(rows, func(r *payloadRender) payload {
+		values := make(map[string]interface{}, len(data))
+		for name, value := range data {
+			values[name] = value
+		}
+		for name, t := range templates {
+			values[name] = t.render(r)
+		}
+		body, err := json.Marshal(values)
+		if err != nil {
+			panic(fmt.Sprintf("can't encode payload: %v", err))
+		}
+		return payload{body: body}
+	}), nil
+}
+
+// kvSeedKeys returns the keys KV tests seed when they are set up: the key
+// template rendered for every row of the data file, or else numKVs keys
+// named secret-1 onwards
+func kvSeedKeys(key string, rows *dataRows, numKVs int) ([]string, error) {
+	if key == "" {
+		keys := make([]string, numKVs)
+		for i := range keys {
+			keys[i] = "secret-" + strconv.Itoa(i+1)
+		}
+		return keys, nil
+	}
+	t, err := parsePayloadTemplate(key, rows)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %v", err)
+	}
+	keys := make([]string, len(rows.rows))
+	for i, row := range rows.rows {
+		keys[i] = t.render(&payloadRender{seq: int64(i + 1), row: row})
+	}
+	return keys, nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestPayloadTemplate(t *testing.T) {
+	tmpl, err := parsePayloadTemplate("user-{{seq}}-{{ rand_alpha 8 }}/{{uuid}}/{{rand_email}}", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if tmpl.static {
+		t.Fatal("expected the template to have functions")
+	}
+	re := regexp.MustCompile(`^user-7-[a-zA-Z]{8}/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}/[a-z]{12}@example\.com$`)
+	if value := tmpl.render(&payloadRender{seq: 7}); !re.MatchString(value) {
+		t.Fatalf("unexpected render: %v", value)
+	}
+
+	tmpl, err = parsePayloadTemplate("benchmark-user", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if value := tmpl.render(&payloadRender{seq: 1}); !tmpl.static || value != "benchmark-user" {
+		t.Fatalf("expected the plain value, got %v", value)
+	}
+
+	cases := map[string]string{
+		"{{username}}":      "unknown payload template function: username",
+		"{{rand_alpha}}":    "rand_alpha takes 1 arguments, got 0",
+		"{{uuid 4}}":        "uuid takes 0 arguments, got 1",
+		"{{rand_alpha -1}}": "length of rand_alpha must be between",
+		"{{ }}":             "empty {{}}",
+		"user-{{seq":        "unclosed {{",
+		"{{field name}}":    "field name requires a data_file",
+	}
+	for value, expected := range cases {
+		if _, err := parsePayloadTemplate(value, nil); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%v: expected error %q, got: %v", value, expected, err)
+		}
+	}
+}
+
+func TestPayloadGenerator(t *testing.T) {
+	g, err := newDataPayloads("", map[string]string{"id": "{{seq}}", "kind": "user"}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Payloads are handed out once each across concurrent requests
+	var lock sync.Mutex
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < payloadBatchSize; j++ {
+				var body struct {
+					Data map[string]string `json:"data"`
+				}
+				if err := json.Unmarshal(g.next().body, &body); err != nil {
+					t.Error(err)
+					return
+				}
+				if body.Data["kind"] != "user" {
+					t.Errorf("unexpected payload: %v", body.Data)
+				}
+				lock.Lock()
+				seen[body.Data["id"]] = true
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 4*payloadBatchSize {
+		t.Fatalf("expected %d distinct payloads, got %d", 4*payloadBatchSize, len(seen))
+	}
+
+	// Large payloads are rendered in smaller batches
+	g, err = newDataPayloads("", map[string]string{"blob": "{{rand_alpha 262144}}"}, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	g.next()
+	if len(g.batch) != 3 {
+		t.Fatalf("expected a batch of 4 payloads of 256KiB, got %d left after the first", len(g.batch))
+	}
+
+	if g, err := newDataPayloads("", nil, nil); g != nil || err != nil {
+		t.Fatalf("expected no generator without data, got %v, %v", g, err)
+	}
+	if _, err := newDataPayloads("", map[string]string{"id": "{{nope}}"}, nil); err == nil || !strings.Contains(err.Error(), "invalid data id") {
+		t.Fatalf("expected the data to be rejected, got: %v", err)
+	}
+}
+
+func TestUserpassAuth_NumUsers(t *testing.T) {
+	var lock sync.Mutex
+	var users []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if name, ok := strings.CutPrefix(req.URL.Path, "/v1/auth/logins/users/"); ok {
+			var body map[string]interface{}
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			if _, ok := body["num_users"]; ok || body["username"] != name {
+				t.Errorf("unexpected user config: %v", body)
+			}
+			lock.Lock()
+			users = append(users, name)
+			lock.Unlock()
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parse := func(config string) (BenchmarkBuilder, error) {
+		file, diags := hclparse.NewParser().ParseHCL([]byte(config), "test.hcl")
+		if diags.HasErrors() {
+			t.Fatalf("err: %v", diags)
+		}
+		builder := TestList[UserpassTestType]()
+		return builder, builder.ParseConfig(file.Body)
+	}
+
+	builder, err := parse(`
+config {
+  username  = "user-{{seq}}"
+  num_users = 3
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	targetLogger = hclog.NewNullLogger()
+	test, err := builder.Setup(client, "logins", &TopLevelTargetConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if strings.Join(users, ",") != "user-1,user-2,user-3" {
+		t.Fatalf("expected three users, got %v", users)
+	}
+	for i := 0; i < 10; i++ {
+		tgt := test.Target(client)
+		if !regexp.MustCompile(`/v1/auth/logins/login/user-[123]$`).MatchString(tgt.URL) {
+			t.Fatalf("expected a login as one of the users, got %v", tgt.URL)
+		}
+	}
+
+	if _, err := parse("config {\n  num_users = 3\n}\n"); err == nil || !strings.Contains(err.Error(), "username must use a payload template") {
+		t.Fatalf("expected a fixed username to be rejected, got: %v", err)
+	}
+}
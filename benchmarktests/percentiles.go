@@ -0,0 +1,97 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// defaultPercentiles are the latency percentiles reported when none are
+// configured
+var defaultPercentiles = []float64{95, 99}
+
+// ParsePercentiles parses a comma separated list of latency percentiles,
+// such as "p50,p99.9,max". Each percentile may optionally be prefixed with
+// p, and max is the same as p100.
+func ParsePercentiles(raw string) ([]float64, error) {
+	var percentiles []float64
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.ToLower(strings.TrimSpace(field))
+		if field == "" {
+			continue
+		}
+		if field == "max" {
+			percentiles = append(percentiles, 100)
+			continue
+		}
+
+		p, err := strconv.ParseFloat(strings.TrimPrefix(field, "p"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid percentile %q", field)
+		}
+		if p <= 0 || p > 100 {
+			return nil, fmt.Errorf("percentile %q must be greater than 0 and at most 100", field)
+		}
+		percentiles = append(percentiles, p)
+	}
+	if len(percentiles) == 0 {
+		return nil, fmt.Errorf("no percentiles given")
+	}
+	return percentiles, nil
+}
+
+func percentileLabel(p float64) string {
+	if p == 100 {
+		return "max"
+	}
+	return strconv.FormatFloat(p, 'f', -1, 64) + "th"
+}
+
+// vegetaPercentile returns the latency at one of the percentiles vegeta
+// keeps in its metrics
+func vegetaPercentile(m *vegeta.Metrics, p float64) (time.Duration, bool) {
+	switch p {
+	case 50:
+		return m.Latencies.P50, true
+	case 90:
+		return m.Latencies.P90, true
+	case 95:
+		return m.Latencies.P95, true
+	case 99:
+		return m.Latencies.P99, true
+	case 100:
+		return m.Latencies.Max, true
+	default:
+		return 0, false
+	}
+}
+
+// SetPercentiles changes the latency percentiles shown in the terse and
+// verbose reports from the default 95th and 99th
+func (r *Reporter) SetPercentiles(percentiles []float64) {
+	r.percentiles = percentiles
+}
+
+func (r *Reporter) reportedPercentiles() []float64 {
+	if len(r.percentiles) == 0 {
+		return defaultPercentiles
+	}
+	return r.percentiles
+}
+
+// latencyPercentile returns the latency at percentile p, reading it from
+// the histogram when the latencies were corrected or percentiles other
+// than the defaults were asked for. Without a histogram only the
+// percentiles kept by vegeta are available.
+func (r *Reporter) latencyPercentile(m *vegeta.Metrics, h *Histogram, p float64) (time.Duration, bool) {
+	if h != nil && (r.corrected || len(r.percentiles) > 0) {
+		return h.Quantile(p / 100), true
+	}
+	return vegetaPercentile(m, p)
+}
@@ -0,0 +1,61 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestParsePercentiles(t *testing.T) {
+	got, err := ParsePercentiles("p50, 90,p99.9 ,P99.99,max")
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if want := []float64{50, 90, 99.9, 99.99, 100}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got: %v", want, got)
+	}
+
+	for _, raw := range []string{"", "p0", "p101", "fast", ","} {
+		if _, err := ParsePercentiles(raw); err == nil {
+			t.Fatalf("expected an error parsing %q", raw)
+		}
+	}
+}
+
+func TestReportTerse_Percentiles(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	now := time.Now()
+	for i := 1; i <= 1000; i++ {
+		rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 200, Timestamp: now, Latency: time.Duration(i) * time.Millisecond})
+	}
+	rpt.Close()
+
+	var buf bytes.Buffer
+	if err := rpt.ReportTerse(&buf); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !strings.Contains(buf.String(), "95th%") || !strings.Contains(buf.String(), "99th%") {
+		t.Fatalf("expected the default percentiles, got:\n%s", buf.String())
+	}
+
+	rpt.SetPercentiles([]float64{99.9, 100})
+	buf.Reset()
+	if err := rpt.ReportTerse(&buf); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "99.9th%") || !strings.Contains(out, "max") || strings.Contains(out, "95th%") {
+		t.Fatalf("expected the configured percentiles, got:\n%s", out)
+	}
+	if !strings.Contains(out, "999.") || !strings.Contains(out, "1s") {
+		t.Fatalf("expected the 99.9th percentile and max latencies, got:\n%s", out)
+	}
+}
@@ -0,0 +1,509 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+const (
+	// PluginProtocolVersion is the version of the protocol plugins speak,
+	// which they must answer the handshake with
+	PluginProtocolVersion = 1
+
+	// PluginCookieKey and PluginCookieValue are set in the environment of
+	// plugins, so they can tell they were started by vault-benchmark
+	// rather than run by hand
+	PluginCookieKey   = "VAULT_BENCHMARK_PLUGIN_COOKIE"
+	PluginCookieValue = "c9b3c0f1-benchmark-plugin"
+
+	// pluginStartTimeout is how long a plugin may take to answer the
+	// handshake
+	pluginStartTimeout = 10 * time.Second
+
+	// pluginStopTimeout is how long a plugin may take to exit once its
+	// input is closed, before it is killed
+	pluginStopTimeout = 5 * time.Second
+)
+
+// PluginConfig is a plugin binary providing test types of its own, which
+// are registered alongside the built-in ones when the config is loaded
+type PluginConfig struct {
+	Name    string            `hcl:"name,label"`
+	Command []string          `hcl:"command"`
+	Env     map[string]string `hcl:"env,optional"`
+}
+
+func (c *PluginConfig) Validate() error {
+	if len(c.Command) == 0 {
+		return fmt.Errorf("command is required")
+	}
+	return nil
+}
+
+// pluginMessage is a line of the protocol spoken with plugins over their
+// standard input and output: a call when it has a method, or else the
+// result or error of the call of the same id
+type pluginMessage struct {
+	ID     uint64          `json:"id"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// pluginHandshake is the first line a plugin writes once started
+type pluginHandshake struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	Tests           []string `json:"tests"`
+}
+
+// pluginTarget is a request of the attack on a test of a plugin, sent to
+// an API path such as kv/data/secret-1
+type pluginTarget struct {
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+}
+
+// pluginRequest is a request a plugin makes through the benchmark's client
+// while it sets up or cleans up a test, and pluginResponse its outcome
+type pluginRequest struct {
+	Operation string          `json:"operation"`
+	Path      string          `json:"path"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+type pluginResponse struct {
+	Secret *api.Secret `json:"secret"`
+}
+
+// pluginClient is a running plugin. Calls are made one at a time.
+type pluginClient struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Scanner
+	tests  []string
+
+	lock   sync.Mutex
+	nextID uint64
+	err    error
+}
+
+var (
+	pluginsLock sync.Mutex
+	plugins     = make(map[string]*pluginClient)
+)
+
+// RegisterPlugins starts the plugins which aren't running yet and adds
+// the test types they provide to TestList
+func RegisterPlugins(configs []*PluginConfig) error {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+	for _, config := range configs {
+		if _, ok := plugins[config.Name]; ok {
+			continue
+		}
+		p, err := startPlugin(config)
+		if err != nil {
+			return fmt.Errorf("error starting plugin %v: %v", config.Name, err)
+		}
+		for _, testType := range p.tests {
+			if _, ok := TestList[testType]; ok {
+				p.stop()
+				return fmt.Errorf("plugin %v provides test type %v, which is already registered", config.Name, testType)
+			}
+		}
+		for _, testType := range p.tests {
+			testType := testType
+			TestList[testType] = func() BenchmarkBuilder { return &PluginTest{plugin: p, testType: testType} }
+		}
+		plugins[config.Name] = p
+	}
+	return nil
+}
+
+// StopPlugins stops every running plugin, removing their test types from
+// TestList
+func StopPlugins() {
+	pluginsLock.Lock()
+	defer pluginsLock.Unlock()
+	for name, p := range plugins {
+		for _, testType := range p.tests {
+			delete(TestList, testType)
+		}
+		p.stop()
+		delete(plugins, name)
+	}
+}
+
+func startPlugin(config *PluginConfig) (*pluginClient, error) {
+	cmd := exec.Command(config.Command[0], config.Command[1:]...)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("%v=%v", PluginCookieKey, PluginCookieValue))
+	for k, v := range config.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &pluginClient{name: config.Name, cmd: cmd, stdin: stdin, stdout: bufio.NewScanner(stdout)}
+	p.stdout.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	var handshake pluginHandshake
+	done := make(chan error, 1)
+	go func() {
+		line, err := p.readLine()
+		if err == nil {
+			err = json.Unmarshal(line, &handshake)
+		}
+		done <- err
+	}()
+	select {
+	case err = <-done:
+	case <-time.After(pluginStartTimeout):
+		err = fmt.Errorf("no handshake within %v", pluginStartTimeout)
+	}
+	switch {
+	case err != nil:
+		err = fmt.Errorf("invalid handshake: %v", err)
+	case handshake.ProtocolVersion != PluginProtocolVersion:
+		err = fmt.Errorf("plugin speaks protocol version %d, expected %d", handshake.ProtocolVersion, PluginProtocolVersion)
+	case len(handshake.Tests) == 0:
+		err = fmt.Errorf("plugin provides no test types")
+	}
+	if err != nil {
+		p.stop()
+		return nil, err
+	}
+	p.tests = handshake.Tests
+	sort.Strings(p.tests)
+	return p, nil
+}
+
+// readLine returns the next line the plugin writes
+func (p *pluginClient) readLine() ([]byte, error) {
+	if !p.stdout.Scan() {
+		if err := p.stdout.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("plugin exited")
+	}
+	return p.stdout.Bytes(), nil
+}
+
+// stop closes the input of the plugin, which it should exit on, and kills
+// it if it doesn't
+func (p *pluginClient) stop() {
+	p.stdin.Close()
+	done := make(chan struct{})
+	go func() {
+		_ = p.cmd.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(pluginStopTimeout):
+		_ = p.cmd.Process.Kill()
+		<-done
+	}
+}
+
+// call calls a method of the plugin, decoding its result into result.
+// Requests the plugin makes while handling the call are sent with client,
+// or refused when it is nil. Once the plugin breaks the protocol every
+// later call fails.
+func (p *pluginClient) call(client *api.Client, method string, params, result interface{}) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+
+	p.nextID++
+	id := p.nextID
+	if err := p.write(pluginMessage{ID: id, Method: method, Params: mustMarshal(params)}); err != nil {
+		p.err = fmt.Errorf("plugin %v failed: %v", p.name, err)
+		return p.err
+	}
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			p.err = fmt.Errorf("plugin %v failed: %v", p.name, err)
+			return p.err
+		}
+		var msg pluginMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			p.err = fmt.Errorf("plugin %v wrote an invalid message: %v", p.name, err)
+			return p.err
+		}
+
+		if msg.Method != "" {
+			reply := pluginMessage{ID: msg.ID}
+			response, err := p.handle(client, msg)
+			if err != nil {
+				reply.Error = err.Error()
+			} else {
+				reply.Result = mustMarshal(response)
+			}
+			if err := p.write(reply); err != nil {
+				p.err = fmt.Errorf("plugin %v failed: %v", p.name, err)
+				return p.err
+			}
+			continue
+		}
+
+		if msg.ID != id {
+			p.err = fmt.Errorf("plugin %v answered call %d, expected %d", p.name, msg.ID, id)
+			return p.err
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("%v", msg.Error)
+		}
+		if result == nil || len(msg.Result) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(msg.Result, result); err != nil {
+			return fmt.Errorf("invalid result of %v: %v", method, err)
+		}
+		return nil
+	}
+}
+
+func (p *pluginClient) write(msg pluginMessage) error {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = p.stdin.Write(append(line, '\n'))
+	return err
+}
+
+// handle handles a call the plugin makes while handling one of ours
+func (p *pluginClient) handle(client *api.Client, msg pluginMessage) (interface{}, error) {
+	if msg.Method != "request" {
+		return nil, fmt.Errorf("unknown method: %v", msg.Method)
+	}
+	if client == nil {
+		return nil, fmt.Errorf("requests can only be made during setup and cleanup")
+	}
+	var req pluginRequest
+	if err := json.Unmarshal(msg.Params, &req); err != nil {
+		return nil, fmt.Errorf("invalid request: %v", err)
+	}
+
+	var secret *api.Secret
+	var err error
+	switch req.Operation {
+	case "read":
+		secret, err = client.Logical().Read(req.Path)
+	case "list":
+		secret, err = client.Logical().List(req.Path)
+	case "write":
+		data := []byte(req.Data)
+		if len(data) == 0 {
+			data = []byte("{}")
+		}
+		secret, err = client.Logical().WriteBytes(req.Path, data)
+	case "delete":
+		secret, err = client.Logical().Delete(req.Path)
+	default:
+		return nil, fmt.Errorf("operation must be one of read, list, write or delete")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pluginResponse{Secret: secret}, nil
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(fmt.Sprintf("can't encode plugin message: %v", err))
+	}
+	return b
+}
+
+// PluginTest is a test of a type provided by a plugin. Its config is sent
+// to the plugin as a JSON object, and requests are asked of it in batches.
+type PluginTest struct {
+	plugin   *pluginClient
+	testType string
+	config   map[string]json.RawMessage
+
+	// id names the test to the plugin once it is set up
+	id         string
+	method     string
+	pathPrefix string
+
+	lock  sync.Mutex
+	batch []pluginTarget
+}
+
+// pluginTestIDs counts the tests of plugins set up, to give each an id
+var pluginTestIDs struct {
+	sync.Mutex
+	next int
+}
+
+func (p *PluginTest) ParseConfig(body hcl.Body) error {
+	testConfig := &struct {
+		Config *struct {
+			Remain hcl.Body `hcl:",remain"`
+		} `hcl:"config,block"`
+	}{}
+	diags := gohcl.DecodeBody(body, nil, testConfig)
+	if diags.HasErrors() {
+		return fmt.Errorf("error decoding to struct: %v", diags)
+	}
+
+	p.config = make(map[string]json.RawMessage)
+	if testConfig.Config != nil {
+		attrs, diags := testConfig.Config.Remain.JustAttributes()
+		if diags.HasErrors() {
+			return fmt.Errorf("the config of plugin tests may only set attributes: %v", diags)
+		}
+		for name, attr := range attrs {
+			value, diags := attr.Expr.Value(nil)
+			if diags.HasErrors() {
+				return fmt.Errorf("error evaluating %v: %v", name, diags)
+			}
+			encoded, err := ctyjson.Marshal(value, value.Type())
+			if err != nil {
+				return fmt.Errorf("error encoding %v: %v", name, err)
+			}
+			p.config[name] = encoded
+		}
+	}
+
+	return p.plugin.call(nil, "parse_config", map[string]interface{}{
+		"type":   p.testType,
+		"config": p.config,
+	}, nil)
+}
+
+func (p *PluginTest) Setup(client *api.Client, mountName string, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
+	pluginTestIDs.Lock()
+	pluginTestIDs.next++
+	id := fmt.Sprintf("%v-%d", p.testType, pluginTestIDs.next)
+	pluginTestIDs.Unlock()
+
+	var result struct {
+		Method     string `json:"method"`
+		PathPrefix string `json:"path_prefix"`
+	}
+	err := p.plugin.call(client, "setup", map[string]interface{}{
+		"type":      p.testType,
+		"id":        id,
+		"config":    p.config,
+		"mount":     mountName,
+		"namespace": client.Namespace(),
+		"duration":  topLevelConfig.Duration.String(),
+	}, &result)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up plugin test: %v", err)
+	}
+	if result.Method == "" || result.PathPrefix == "" {
+		return nil, fmt.Errorf("plugin setup must return the method and path_prefix of the test")
+	}
+
+	return &PluginTest{
+		plugin:     p.plugin,
+		testType:   p.testType,
+		config:     p.config,
+		id:         id,
+		method:     strings.ToUpper(result.Method),
+		pathPrefix: "/v1/" + strings.TrimPrefix(result.PathPrefix, "/"),
+	}, nil
+}
+
+func (p *PluginTest) Target(client *api.Client) vegeta.Target {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if len(p.batch) == 0 {
+		var result struct {
+			Targets []pluginTarget `json:"targets"`
+		}
+		err := p.plugin.call(nil, "targets", map[string]interface{}{
+			"id":    p.id,
+			"count": payloadBatchSize,
+		}, &result)
+		if err == nil && len(result.Targets) == 0 {
+			err = fmt.Errorf("no targets returned")
+		}
+		if err != nil {
+			// There is no way to fail a single target, so send the request
+			// to a path the results of which show the failure
+			targetLogger.Error("error getting targets of plugin test", "type", p.testType, "error", err)
+			return vegeta.Target{Method: p.method, URL: client.Address() + p.pathPrefix, Header: generateHeader(client)}
+		}
+		p.batch = result.Targets
+	}
+	t := p.batch[0]
+	p.batch = p.batch[1:]
+
+	header := generateHeader(client)
+	for k, v := range t.Header {
+		header.Set(k, v)
+	}
+	method := strings.ToUpper(t.Method)
+	if method == "" {
+		method = p.method
+	}
+	target := vegeta.Target{
+		Method: method,
+		URL:    client.Address() + "/v1/" + strings.TrimPrefix(t.Path, "/"),
+		Header: header,
+	}
+	if len(t.Body) > 0 {
+		target.Body = t.Body
+	}
+	return target
+}
+
+func (p *PluginTest) Cleanup(client *api.Client) error {
+	if err := p.plugin.call(client, "cleanup", map[string]interface{}{"id": p.id}, nil); err != nil {
+		return fmt.Errorf("error cleaning up plugin test: %v", err)
+	}
+	return nil
+}
+
+func (p *PluginTest) GetTargetInfo() TargetInfo {
+	return TargetInfo{
+		method:     p.method,
+		pathPrefix: p.pathPrefix,
+	}
+}
+
+func (p *PluginTest) Flags(fs *flag.FlagSet) {}
@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/openbao/openbao/api/v2"
+)
+
+// TestPluginHelperProcess is the plugin started by the tests of plugins.
+// It provides the test type named by BENCHMARK_PLUGIN_TEST, which reads
+// the numkvs secrets of a KV mount it enables.
+func TestPluginHelperProcess(t *testing.T) {
+	testType := os.Getenv("BENCHMARK_PLUGIN_TEST")
+	if testType == "" {
+		return
+	}
+	defer os.Exit(0)
+	if os.Getenv(PluginCookieKey) != PluginCookieValue {
+		fmt.Fprintln(os.Stderr, "this is a vault-benchmark plugin")
+		os.Exit(1)
+	}
+
+	out := json.NewEncoder(os.Stdout)
+	in := bufio.NewScanner(os.Stdin)
+	_ = out.Encode(pluginHandshake{ProtocolVersion: PluginProtocolVersion, Tests: []string{testType}})
+
+	mounts := make(map[string]string)
+	request := func(id uint64, operation, path string) error {
+		_ = out.Encode(pluginMessage{ID: id, Method: "request", Params: mustMarshal(pluginRequest{Operation: operation, Path: path, Data: json.RawMessage(`{"type": "kv"}`)})})
+		in.Scan()
+		var reply pluginMessage
+		_ = json.Unmarshal(in.Bytes(), &reply)
+		if reply.Error != "" {
+			return fmt.Errorf("%v", reply.Error)
+		}
+		return nil
+	}
+	for in.Scan() {
+		var msg pluginMessage
+		_ = json.Unmarshal(in.Bytes(), &msg)
+		var params struct {
+			ID     string                     `json:"id"`
+			Mount  string                     `json:"mount"`
+			Count  int                        `json:"count"`
+			Config map[string]json.RawMessage `json:"config"`
+		}
+		_ = json.Unmarshal(msg.Params, &params)
+
+		reply := pluginMessage{ID: msg.ID}
+		switch msg.Method {
+		case "parse_config":
+			if _, ok := params.Config["numkvs"]; !ok {
+				reply.Error = "numkvs is required"
+			}
+		case "setup":
+			if err := request(1000+msg.ID, "write", "sys/mounts/"+params.Mount); err != nil {
+				reply.Error = err.Error()
+				break
+			}
+			mounts[params.ID] = params.Mount
+			reply.Result = mustMarshal(map[string]string{"method": "GET", "path_prefix": params.Mount})
+		case "targets":
+			targets := make([]pluginTarget, params.Count)
+			for i := range targets {
+				targets[i] = pluginTarget{Path: fmt.Sprintf("%v/secret-%d", mounts[params.ID], i%3+1), Header: map[string]string{"X-Plugin": "kv"}}
+			}
+			reply.Result = mustMarshal(map[string]interface{}{"targets": targets})
+		case "cleanup":
+			if err := request(1000+msg.ID, "delete", "sys/mounts/"+mounts[params.ID]); err != nil {
+				reply.Error = err.Error()
+			}
+		default:
+			reply.Error = "unknown method " + msg.Method
+		}
+		_ = out.Encode(reply)
+	}
+}
+
+func pluginConfig(name, testType string) *PluginConfig {
+	return &PluginConfig{
+		Name:    name,
+		Command: []string{os.Args[0], "-test.run=^TestPluginHelperProcess$"},
+		Env:     map[string]string{"BENCHMARK_PLUGIN_TEST": testType},
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	var lock sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		requests = append(requests, req.Method+" "+req.URL.Path)
+		lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetLogger = hclog.NewNullLogger()
+
+	defer StopPlugins()
+	if err := RegisterPlugins([]*PluginConfig{pluginConfig("kv", "plugin_kv_read")}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	newBuilder, ok := TestList["plugin_kv_read"]
+	if !ok {
+		t.Fatal("expected the test type of the plugin to be registered")
+	}
+	parse := func(config string) (BenchmarkBuilder, error) {
+		file, diags := hclparse.NewParser().ParseHCL([]byte(config), "test.hcl")
+		if diags.HasErrors() {
+			t.Fatalf("err: %v", diags)
+		}
+		builder := newBuilder()
+		return builder, builder.ParseConfig(file.Body)
+	}
+
+	if _, err := parse("config {\n  size = 3\n}\n"); err == nil || !strings.Contains(err.Error(), "numkvs is required") {
+		t.Fatalf("expected the plugin to reject the config, got: %v", err)
+	}
+	if _, err := parse("config {\n  nested {\n  }\n}\n"); err == nil || !strings.Contains(err.Error(), "may only set attributes") {
+		t.Fatalf("expected blocks to be rejected, got: %v", err)
+	}
+	builder, err := parse("config {\n  numkvs = 3\n}\n")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The plugin sets up its mount through the benchmark's client, and
+	// hands out the requests of the attack
+	test, err := builder.Setup(client, "plugin-kv", &TopLevelTargetConfig{Duration: time.Second})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info := test.GetTargetInfo(); info.method != "GET" || info.pathPrefix != "/v1/plugin-kv" {
+		t.Fatalf("unexpected target info: %+v", info)
+	}
+	for i := 1; i <= 4; i++ {
+		tgt := test.Target(client)
+		if tgt.Method != "GET" || tgt.URL != fmt.Sprintf("%v/v1/plugin-kv/secret-%d", srv.URL, (i-1)%3+1) || tgt.Header.Get("X-Plugin") != "kv" {
+			t.Fatalf("unexpected target: %+v", tgt)
+		}
+	}
+	if err := test.Cleanup(client); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if strings.Join(requests, ",") != "PUT /v1/sys/mounts/plugin-kv,DELETE /v1/sys/mounts/plugin-kv" {
+		t.Fatalf("expected the mount to be enabled and disabled, got %v", requests)
+	}
+
+	// Plugins can't replace the built-in tests
+	if err := RegisterPlugins([]*PluginConfig{pluginConfig("kv2", KVV2ReadTestType)}); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("expected the test type to conflict, got: %v", err)
+	}
+	if err := RegisterPlugins([]*PluginConfig{{Name: "missing", Command: []string{"/nonexistent/plugin"}}}); err == nil {
+		t.Fatal("expected a missing plugin to fail to start")
+	}
+
+	StopPlugins()
+	if _, ok := TestList["plugin_kv_read"]; ok {
+		t.Fatal("expected the test type to be removed with its plugin")
+	}
+}
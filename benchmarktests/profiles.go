@@ -0,0 +1,163 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+)
+
+const (
+	// DefaultProfileAt is when profiles are captured when no points are
+	// given, halfway through the run
+	DefaultProfileAt = "50%"
+
+	// DefaultProfileDuration is how long CPU profiles are recorded for
+	DefaultProfileDuration = 10 * time.Second
+)
+
+// ParseProfilePoints parses comma separated points of a run, each either a
+// duration from the start of the run or a percentage of its duration, such
+// as 30s,50%,90%. The points are returned in order.
+func ParseProfilePoints(raw string, duration time.Duration) ([]time.Duration, error) {
+	var points []time.Duration
+	for _, field := range strings.Split(raw, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		var point time.Duration
+		if percent, ok := strings.CutSuffix(field, "%"); ok {
+			p, err := strconv.ParseFloat(percent, 64)
+			if err != nil || p < 0 || p > 100 {
+				return nil, fmt.Errorf("invalid point %q: percentages must be between 0 and 100", field)
+			}
+			point = time.Duration(float64(duration) * p / 100)
+		} else {
+			d, err := time.ParseDuration(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid point %q: %v", field, err)
+			}
+			if d < 0 || d > duration {
+				return nil, fmt.Errorf("invalid point %q: must be within the %v run", field, duration)
+			}
+			point = d
+		}
+		points = append(points, point.Round(time.Second))
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("no points given")
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+	return points, nil
+}
+
+// ProfileCapture fetches CPU and heap profiles from the pprof endpoints of
+// servers at set points of a run, so regressions come with the profiles
+// needed to debug them. Profiles are written to a directory, one file per
+// server, point and kind, which can be read with go tool pprof.
+type ProfileCapture struct {
+	Dir         string
+	Points      []time.Duration
+	CPUDuration time.Duration
+	Logger      hclog.Logger
+
+	wg sync.WaitGroup
+}
+
+// Start captures profiles of every client at each point after began. Points
+// which haven't been reached when stop is closed are skipped; Wait waits for
+// the captures already started.
+func (p *ProfileCapture) Start(clients []*api.Client, began time.Time, stop <-chan struct{}) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for _, point := range p.Points {
+			select {
+			case <-time.After(time.Until(began.Add(point))):
+			case <-stop:
+				return
+			}
+			for _, client := range clients {
+				p.wg.Add(1)
+				go func(client *api.Client, point time.Duration) {
+					defer p.wg.Done()
+					p.capture(client, point)
+				}(client, point)
+			}
+		}
+	}()
+}
+
+// Wait waits for the profiles being captured to be written
+func (p *ProfileCapture) Wait() {
+	p.wg.Wait()
+}
+
+func (p *ProfileCapture) capture(client *api.Client, point time.Duration) {
+	seconds := strconv.Itoa(max(int(p.CPUDuration.Seconds()), 1))
+	for _, profile := range []struct {
+		kind  string
+		path  string
+		query map[string][]string
+	}{
+		// The heap profile is taken first, as the CPU profile blocks for
+		// its duration
+		{"heap", "sys/pprof/heap", nil},
+		{"cpu", "sys/pprof/profile", map[string][]string{"seconds": {seconds}}},
+	} {
+		path := filepath.Join(p.Dir, profileFileName(client.Address(), point, profile.kind))
+		if err := fetchProfile(client, profile.path, profile.query, path); err != nil {
+			p.Logger.Error("error capturing profile", "target", client.Address(), "kind", profile.kind, "at", point.String(), "error", hclog.Fmt("%v", err))
+			continue
+		}
+		p.Logger.Info("captured profile", "target", client.Address(), "kind", profile.kind, "at", point.String(), "path", path)
+	}
+}
+
+// fetchProfile writes the profile read from a pprof endpoint to path
+func fetchProfile(client *api.Client, endpoint string, query map[string][]string, path string) error {
+	resp, err := client.Logical().ReadRawWithDataWithContext(context.Background(), endpoint, query)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("error reading %v: %v", endpoint, err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating profile file: %v", err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing profile file: %v", err)
+	}
+	return f.Close()
+}
+
+// profileFileNameRe matches the characters of an address which aren't
+// safe in file names
+var profileFileNameRe = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+// profileFileName names the file of a profile of a server, such as
+// 127.0.0.1-8200-30s-cpu.pprof
+func profileFileName(addr string, point time.Duration, kind string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(addr, "https://"), "http://")
+	host = strings.Trim(profileFileNameRe.ReplaceAllString(host, "-"), "-")
+	return fmt.Sprintf("%s-%s-%s.pprof", host, point, kind)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestParseProfilePoints(t *testing.T) {
+	points, err := ParseProfilePoints("90%, 30s,50%", 2*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Duration{30 * time.Second, time.Minute, 108 * time.Second}
+	if !reflect.DeepEqual(points, want) {
+		t.Fatalf("got points %v, want %v", points, want)
+	}
+
+	for _, raw := range []string{"", "150%", "-1%", "3m", "soon"} {
+		if _, err := ParseProfilePoints(raw, 2*time.Minute); err == nil {
+			t.Errorf("expected error parsing %q", raw)
+		}
+	}
+}
+
+func TestProfileFileName(t *testing.T) {
+	name := profileFileName("https://127.0.0.1:8200", 30*time.Second, "cpu")
+	if name != "127.0.0.1-8200-30s-cpu.pprof" {
+		t.Fatalf("unexpected file name %q", name)
+	}
+}
+
+func TestProfileCapture(t *testing.T) {
+	var seconds string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/v1/sys/pprof/heap":
+			w.Write([]byte("heap profile"))
+		case "/v1/sys/pprof/profile":
+			seconds = req.URL.Query().Get("seconds")
+			w.Write([]byte("cpu profile"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	config := api.DefaultConfig()
+	config.Address = srv.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	p := &ProfileCapture{
+		Dir:         dir,
+		Points:      []time.Duration{0, time.Hour},
+		CPUDuration: 5 * time.Second,
+		Logger:      hclog.NewNullLogger(),
+	}
+	stop := make(chan struct{})
+	p.Start([]*api.Client{client}, time.Now(), stop)
+
+	// The first point is captured right away, and the second skipped once
+	// the run stops
+	host := strings.TrimPrefix(srv.URL, "http://")
+	host = strings.ReplaceAll(host, ":", "-")
+	cpuPath := filepath.Join(dir, host+"-0s-cpu.pprof")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := os.Stat(cpuPath); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("profile %v was not written", cpuPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	p.Wait()
+
+	for kind, want := range map[string]string{"heap": "heap profile", "cpu": "cpu profile"} {
+		got, err := os.ReadFile(filepath.Join(dir, host+"-0s-"+kind+".pprof"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("got %v profile %q, want %q", kind, got, want)
+		}
+	}
+	if seconds != "5" {
+		t.Errorf("got cpu profile seconds %q, want 5", seconds)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("expected 2 profiles, got %d", len(entries))
+	}
+}
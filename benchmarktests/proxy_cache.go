@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// CacheHeader is the response header OpenBao Proxy and Agent set to HIT
+	// when a response was served from their cache and MISS when the
+	// request was forwarded to the server
+	CacheHeader = "X-Cache"
+
+	cacheHit  = "hit"
+	cacheMiss = "miss"
+)
+
+// recordCache adds a latency of the named test to the histogram of cache
+// hits or misses, when the response says whether it was served from a
+// cache
+func (r *Reporter) recordCache(name string, result *vegeta.Result, latency time.Duration) {
+	var outcome string
+	switch result.Headers.Get(CacheHeader) {
+	case "HIT":
+		outcome = cacheHit
+	case "MISS":
+		outcome = cacheMiss
+	default:
+		return
+	}
+	if r.cacheHistograms == nil {
+		r.cacheHistograms = make(map[string]map[string]*Histogram)
+	}
+	outcomes, ok := r.cacheHistograms[name]
+	if !ok {
+		outcomes = map[string]*Histogram{cacheHit: NewHistogram(), cacheMiss: NewHistogram()}
+		r.cacheHistograms[name] = outcomes
+	}
+	outcomes[outcome].Record(latency)
+}
+
+// cacheStats summarizes the cache hits and misses of the named test. The
+// speedup is how many times faster hits were than misses on average, or
+// zero when either is missing.
+func (r *Reporter) cacheStats(name string) (hits, misses *Histogram, ratio, speedup float64, ok bool) {
+	outcomes, ok := r.cacheHistograms[name]
+	if !ok {
+		return nil, nil, 0, 0, false
+	}
+	hits, misses = outcomes[cacheHit], outcomes[cacheMiss]
+	if hits == nil {
+		hits = NewHistogram()
+	}
+	if misses == nil {
+		misses = NewHistogram()
+	}
+	if total := hits.Count() + misses.Count(); total > 0 {
+		ratio = float64(hits.Count()) / float64(total)
+	}
+	if hits.Count() > 0 && misses.Count() > 0 && hits.Mean() > 0 {
+		speedup = float64(misses.Mean()) / float64(hits.Mean())
+	}
+	return hits, misses, ratio, speedup, true
+}
+
+// reportCacheVerbose writes the cache hit ratio of the named test along
+// with the latencies of hits and misses
+func (r *Reporter) reportCacheVerbose(w io.Writer, name string) {
+	hits, misses, ratio, speedup, ok := r.cacheStats(name)
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "Cache         [ratio, hits, misses, speedup]    %.2f%%, %d, %d, %.2fx\n", ratio*100, hits.Count(), misses.Count(), speedup)
+	fmt.Fprintf(w, "Cache latencies [hit mean, 99, miss mean, 99]   %s, %s, %s, %s\n", hits.Mean(), hits.Quantile(0.99), misses.Mean(), misses.Quantile(0.99))
+}
+
+// reportCacheTerse writes a table of the cache hit ratio of every test and
+// the latencies of hits and misses, when responses said whether they were
+// served from a cache
+func (r *Reporter) reportCacheTerse(w io.Writer, names []string) {
+	if len(r.cacheHistograms) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nop\thitRatio\thits\tmisses\thitMean\thit99th\tmissMean\tmiss99th\tspeedup\n")
+	for _, name := range names {
+		hits, misses, ratio, speedup, ok := r.cacheStats(name)
+		if name == "total" || !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%.2f%%\t%d\t%d\t%s\t%s\t%s\t%s\t%.2fx\n", name, ratio*100, hits.Count(), misses.Count(),
+			hits.Mean(), hits.Quantile(0.99), misses.Mean(), misses.Quantile(0.99), speedup)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestReporter_Cache(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "read", Method: "GET", PathPrefix: "/v1/kv/data"},
+		{Name: "write", Method: "POST", PathPrefix: "/v1/kv/data"},
+	}}
+	rpt := newReporter(tm, nil)
+	began := time.Now()
+	for i := 0; i < 8; i++ {
+		result := &vegeta.Result{Method: "GET", URL: "N/A/v1/kv/data/secret", Code: 200, Timestamp: began, Latency: time.Millisecond, Headers: http.Header{}}
+		if i%4 == 0 {
+			result.Latency = 4 * time.Millisecond
+			result.Headers.Set(CacheHeader, "MISS")
+		} else {
+			result.Headers.Set(CacheHeader, "HIT")
+		}
+		rpt.Add(result)
+	}
+	// Writes aren't cached, so have no cache header
+	rpt.Add(&vegeta.Result{Method: "POST", URL: "N/A/v1/kv/data/secret", Code: 200, Timestamp: began, Latency: time.Millisecond})
+	rpt.Close()
+
+	hits, misses, ratio, speedup, ok := rpt.cacheStats("read")
+	if !ok || hits.Count() != 6 || misses.Count() != 2 {
+		t.Fatalf("expected 6 hits and 2 misses, got %v", rpt.cacheHistograms["read"])
+	}
+	if ratio != 0.75 || speedup < 3.9 || speedup > 4.1 {
+		t.Fatalf("expected a hit ratio of 0.75 and a speedup of 4x, got %v and %v", ratio, speedup)
+	}
+	if _, _, _, _, ok := rpt.cacheStats("write"); ok {
+		t.Fatal("expected no cache results for writes")
+	}
+
+	var buf bytes.Buffer
+	if err := rpt.ReportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rpts, err := FromReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var terse bytes.Buffer
+	if err := rpts[0].ReportTerse(&terse); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(terse.String(), "hitRatio") || !strings.Contains(terse.String(), "75.00%") {
+		t.Errorf("expected the cache hit ratio in terse report:\n%s", terse.String())
+	}
+}
+
+func TestProxyAddr(t *testing.T) {
+	cfg := api.DefaultConfig()
+	cfg.Address = "unix:///run/openbao/proxy.sock"
+	proxy, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if proxy.Address() != "http://localhost" {
+		t.Fatalf("expected requests to a socket to be sent to localhost, got %v", proxy.Address())
+	}
+	if got := configuredAddr(proxy); got != "unix:///run/openbao/proxy.sock" {
+		t.Fatalf("expected the socket to be reported, got %v", got)
+	}
+}
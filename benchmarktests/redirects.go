@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// maxRedirects is how many redirects of a request are followed before
+	// the last one is returned as its response
+	maxRedirects = 10
+
+	// redirectsHeader and redirectLatencyHeader are set on the responses
+	// of requests which were redirected, to the number of redirects
+	// followed and the nanoseconds spent before the last of them, so the
+	// redirects of each test can be recorded
+	redirectsHeader       = "X-Benchmark-Redirects"
+	redirectLatencyHeader = "X-Benchmark-Redirect-Latency"
+)
+
+// RedirectStats counts the requests of a test which were redirected, such
+// as by a standby node to the active node, and the latency the redirects
+// added to them
+type RedirectStats struct {
+	Redirects uint64     `json:"redirects"`
+	Latency   *Histogram `json:"latency"`
+}
+
+// redirectTransport follows the temporary and permanent redirects standby
+// nodes answer requests meant for the active node with, resending requests
+// along with their token and body, which the API client otherwise returns
+// as responses
+type redirectTransport struct {
+	base http.RoundTripper
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	redirects := 0
+	var added time.Duration
+	for err == nil && redirects < maxRedirects {
+		if resp.StatusCode != http.StatusTemporaryRedirect && resp.StatusCode != http.StatusPermanentRedirect {
+			break
+		}
+		next, ok := redirectRequest(req, resp)
+		if !ok {
+			break
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		redirects++
+		added = time.Since(start)
+		resp, err = t.base.RoundTrip(next)
+	}
+	if err == nil && redirects > 0 {
+		resp.Header.Set(redirectsHeader, strconv.Itoa(redirects))
+		resp.Header.Set(redirectLatencyHeader, strconv.FormatInt(int64(added), 10))
+	}
+	return resp, err
+}
+
+// redirectRequest returns the request to send to the location a response
+// redirected req to, or false when it can't be resent
+func redirectRequest(req *http.Request, resp *http.Response) (*http.Request, bool) {
+	location, err := resp.Location()
+	if err != nil {
+		return nil, false
+	}
+	next := req.Clone(req.Context())
+	next.URL = location
+	next.Host = ""
+	if req.GetBody != nil {
+		if next.Body, err = req.GetBody(); err != nil {
+			return nil, false
+		}
+	} else if req.Body != nil && req.Body != http.NoBody {
+		return nil, false
+	}
+	return next, true
+}
+
+// recordRedirect records the redirects the request of a result of the
+// named test was sent through, when it was redirected
+func (r *Reporter) recordRedirect(name string, result *vegeta.Result) {
+	redirects, err := strconv.ParseUint(result.Headers.Get(redirectsHeader), 10, 64)
+	if err != nil || redirects == 0 {
+		return
+	}
+	added, _ := strconv.ParseInt(result.Headers.Get(redirectLatencyHeader), 10, 64)
+	if r.redirects == nil {
+		r.redirects = make(map[string]*RedirectStats)
+	}
+	stats, ok := r.redirects[name]
+	if !ok {
+		stats = &RedirectStats{Latency: NewHistogram()}
+		r.redirects[name] = stats
+	}
+	stats.Redirects += redirects
+	stats.Latency.Record(time.Duration(added))
+}
+
+// reportRedirectsVerbose writes the number of redirected requests of the
+// named test along with the latency the redirects added to them
+func (r *Reporter) reportRedirectsVerbose(w io.Writer, name string) {
+	stats, ok := r.redirects[name]
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "Redirects     [requests, redirects, mean, 99]   %d, %d, %s, %s\n",
+		stats.Latency.Count(), stats.Redirects, stats.Latency.Mean(), stats.Latency.Quantile(0.99))
+}
+
+// reportRedirectsTerse writes a table of the redirected requests of every
+// test and the latency the redirects added to them, when any were
+// redirected
+func (r *Reporter) reportRedirectsTerse(w io.Writer, names []string) {
+	if len(r.redirects) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nop\tredirected\tredirects\taddedMean\tadded99th\n")
+	for _, name := range names {
+		stats, ok := r.redirects[name]
+		if name == "total" || !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", name, stats.Latency.Count(), stats.Redirects, stats.Latency.Mean(), stats.Latency.Quantile(0.99))
+	}
+}
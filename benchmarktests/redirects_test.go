@@ -0,0 +1,89 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestFollowRedirects(t *testing.T) {
+	active := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if req.Header.Get("X-Vault-Token") != "root" || string(body) != `{"data":{}}` {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer active.Close()
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		http.Redirect(w, req, active.URL+req.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer standby.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = standby.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tgt := vegeta.Target{
+		Method: "POST",
+		URL:    standby.URL + "/v1/secret/data/foo",
+		Body:   []byte(`{"data":{}}`),
+		Header: http.Header{"X-Vault-Token": []string{"root"}},
+	}
+	send := func(follow bool) *http.Response {
+		req, err := tgt.Request()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := attackClient(client, &AttackConfig{FollowRedirects: follow}).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := send(false); resp.StatusCode != http.StatusTemporaryRedirect {
+		t.Fatalf("expected the redirect to be returned without following, got %v", resp.Status)
+	}
+	resp := send(true)
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get(redirectsHeader) != "1" {
+		t.Fatalf("expected the redirect to be followed with the token and body, got %v %v", resp.Status, resp.Header)
+	}
+
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "write", Method: "POST", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, client)
+	rpt.Add(&vegeta.Result{Method: "POST", URL: tgt.URL, Code: 204, Timestamp: time.Now(), Latency: 10 * time.Millisecond, Headers: resp.Header})
+	rpt.Add(&vegeta.Result{Method: "POST", URL: tgt.URL, Code: 204, Timestamp: time.Now(), Latency: time.Millisecond})
+	rpt.Close()
+	stats := rpt.redirects["write"]
+	if stats == nil || stats.Redirects != 1 || stats.Latency.Count() != 1 || stats.Latency.Mean() < 5*time.Millisecond {
+		t.Fatalf("expected one redirect adding at least 5ms, got %+v", stats)
+	}
+
+	var b bytes.Buffer
+	if err := rpt.ReportTerse(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "redirected") {
+		t.Errorf("expected a redirects table in the terse report:\n%s", b.String())
+	}
+	merged := MergeReports([]*Reporter{rpt, rpt})
+	if got := merged[0].redirects["write"]; got.Redirects != 2 || got.Latency.Count() != 2 {
+		t.Fatalf("expected the redirects of both reports to be merged, got %+v", got)
+	}
+}
@@ -0,0 +1,482 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+)
+
+// generatedDefault is the default shown for options whose default is
+// generated each time a config is parsed, such as a random password
+const generatedDefault = "(generated)"
+
+// TestReference describes the config block a test type accepts
+type TestReference struct {
+	Type    string             `json:"type"`
+	Fields  []*FieldReference  `json:"fields"`
+	EnvVars []*EnvVarReference `json:"env_vars,omitempty"`
+}
+
+// FieldReference describes an attribute or block of the config block of a
+// test. Names of options within nested blocks are joined with dots.
+type FieldReference struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required"`
+	Default  string `json:"default,omitempty"`
+	EnvVar   string `json:"env_var,omitempty"`
+}
+
+// EnvVarReference describes an environment variable a test reads an option
+// from when it is not set in the config
+type EnvVarReference struct {
+	Name     string `json:"name"`
+	Field    string `json:"field,omitempty"`
+	Required bool   `json:"required"`
+}
+
+// TestReferences describes the config blocks of the given test types, or of
+// every test type when none are given. Fields, their types and whether
+// they are required come from the HCL tags of the config of each test, and
+// defaults from parsing an empty config. Environment variables are found by
+// parsing with each of them set in turn, so this must not be called while
+// tests are being parsed or run.
+func TestReferences(testTypes ...string) ([]*TestReference, error) {
+	if len(testTypes) == 0 {
+		for testType := range TestList {
+			testTypes = append(testTypes, testType)
+		}
+	}
+	sort.Strings(testTypes)
+
+	refs := make([]*TestReference, 0, len(testTypes))
+	for _, testType := range testTypes {
+		ref, err := testReference(testType)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+func testReference(testType string) (*TestReference, error) {
+	newBuilder, ok := TestList[testType]
+	if !ok {
+		return nil, fmt.Errorf("invalid test type: %v", testType)
+	}
+
+	var envVars []string
+	if reader, ok := newBuilder().(EnvVarReader); ok {
+		envVars = reader.EnvVars()
+	}
+
+	ref := &TestReference{Type: testType}
+	config, _ := parseEmptyConfig(testType, envVars, nil)
+	if !config.IsValid() {
+		// Tests without a config block have no options
+		return ref, nil
+	}
+	ref.Fields = referenceFields(config.Type(), "")
+
+	// Options whose defaults differ between two parses are generated
+	defaults := referenceValues(config, "")
+	again, _ := parseEmptyConfig(testType, envVars, nil)
+	regenerated := referenceValues(again, "")
+	for _, field := range ref.Fields {
+		field.Default = defaults[field.Name]
+		if field.Default != regenerated[field.Name] {
+			field.Default = generatedDefault
+		}
+	}
+
+	// Each environment variable is set to its own name to find the option
+	// it sets, and unset while the others are set to find whether it is
+	// required, which it is when the error of parsing changes
+	allSet := make(map[string]string, len(envVars))
+	for _, name := range envVars {
+		allSet[name] = name
+	}
+	_, allSetErr := parseEmptyConfig(testType, envVars, allSet)
+	for _, name := range envVars {
+		envRef := &EnvVarReference{Name: name}
+		config, _ := parseEmptyConfig(testType, envVars, map[string]string{name: name})
+		values := referenceValues(config, "")
+		for _, field := range ref.Fields {
+			if values[field.Name] == fmt.Sprintf("%q", name) {
+				field.EnvVar = name
+				envRef.Field = field.Name
+				break
+			}
+		}
+
+		others := make(map[string]string, len(envVars))
+		for other := range allSet {
+			if other != name {
+				others[other] = other
+			}
+		}
+		_, err := parseEmptyConfig(testType, envVars, others)
+		envRef.Required = err != nil && (allSetErr == nil || err.Error() != allSetErr.Error())
+		ref.EnvVars = append(ref.EnvVars, envRef)
+	}
+	return ref, nil
+}
+
+// parseEmptyConfig parses an empty config for a test with only the given
+// environment variables of names set, so neither credentials of the
+// environment nor options set elsewhere show up in its defaults. It returns
+// the config the test parsed, which tests set even when parsing fails.
+func parseEmptyConfig(testType string, names []string, env map[string]string) (reflect.Value, error) {
+	saved := make(map[string]*string, len(names))
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			saved[name] = &value
+		} else {
+			saved[name] = nil
+		}
+		if value, ok := env[name]; ok {
+			os.Setenv(name, value)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+	defer func() {
+		for name, value := range saved {
+			if value != nil {
+				os.Setenv(name, *value)
+			} else {
+				os.Unsetenv(name)
+			}
+		}
+	}()
+
+	builder := TestList[testType]()
+	err := builder.ParseConfig(hcl.EmptyBody())
+	return reflect.ValueOf(builder).Elem().FieldByName("config"), err
+}
+
+// referenceFields lists the attributes and blocks of a config type from
+// its HCL tags
+func referenceFields(t reflect.Type, prefix string) []*FieldReference {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []*FieldReference
+	for i := 0; i < t.NumField(); i++ {
+		name, kind, ok := hclTag(t.Field(i))
+		if !ok || kind == "label" || kind == "remain" {
+			continue
+		}
+		fieldType := t.Field(i).Type
+		field := &FieldReference{Name: prefix + name}
+		switch kind {
+		case "block":
+			field.Type = "block"
+			if fieldType.Kind() == reflect.Slice {
+				field.Type = "list of blocks"
+			}
+			field.Required = fieldType.Kind() == reflect.Struct
+			fields = append(fields, field)
+			fields = append(fields, referenceFields(elemType(fieldType), field.Name+".")...)
+		default:
+			field.Type = hclTypeName(fieldType)
+			field.Required = kind != "optional"
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// referenceValues returns the values of the non-zero attributes of a
+// config by their name, formatted as they would be written in HCL
+func referenceValues(v reflect.Value, prefix string) map[string]string {
+	values := make(map[string]string)
+	for v.IsValid() && v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return values
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, kind, ok := hclTag(t.Field(i))
+		if !ok || kind == "label" || kind == "remain" {
+			continue
+		}
+		field := v.Field(i)
+		if kind == "block" {
+			if field.Kind() == reflect.Slice {
+				continue
+			}
+			for k, value := range referenceValues(field, prefix+name+".") {
+				values[k] = value
+			}
+			continue
+		}
+		if field.IsZero() {
+			continue
+		}
+		values[prefix+name] = hclValue(field)
+	}
+	return values
+}
+
+func hclTag(field reflect.StructField) (name, kind string, ok bool) {
+	tag, ok := field.Tag.Lookup("hcl")
+	if !ok {
+		return "", "", false
+	}
+	name, kind, _ = strings.Cut(tag, ",")
+	return name, kind, true
+}
+
+func elemType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t
+}
+
+// hclTypeName returns the HCL type of values of a Go type
+func hclTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return hclTypeName(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "list(" + hclTypeName(t.Elem()) + ")"
+	case reflect.Map:
+		return "map(" + hclTypeName(t.Elem()) + ")"
+	default:
+		return "any"
+	}
+}
+
+// hclValue formats a value as it would be written in HCL. Values of
+// unexported fields cannot be turned back into interfaces, so they are
+// formatted by kind.
+func hclValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return "null"
+		}
+		return hclValue(v.Elem())
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	case reflect.Slice:
+		elems := make([]string, v.Len())
+		for i := range elems {
+			elems[i] = hclValue(v.Index(i))
+		}
+		return "[" + strings.Join(elems, ", ") + "]"
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		elems := make([]string, len(keys))
+		for i, key := range keys {
+			elems[i] = fmt.Sprintf("%v = %v", key.String(), hclValue(v.MapIndex(key)))
+		}
+		return "{ " + strings.Join(elems, ", ") + " }"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// WriteTestReferenceMarkdown writes the references of tests as Markdown,
+// with a table of the options of the config block of each test
+func WriteTestReferenceMarkdown(w io.Writer, refs []*TestReference) error {
+	var b strings.Builder
+	for i, ref := range refs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "## %v\n\n", ref.Type)
+		if len(ref.Fields) == 0 {
+			b.WriteString("This test has no config block.\n")
+			continue
+		}
+		b.WriteString("Options of the `config` block of the test.\n\n")
+		b.WriteString("| Option | Type | Required | Default | Environment Variable |\n")
+		b.WriteString("| --- | --- | --- | --- | --- |\n")
+		for _, field := range ref.Fields {
+			required := "no"
+			if field.Required {
+				required = "yes"
+			}
+			fmt.Fprintf(&b, "| `%v` | %v | %v | %v | %v |\n", field.Name, field.Type, required,
+				markdownCode(field.Default), markdownCode(field.EnvVar))
+		}
+		var required []string
+		for _, envVar := range ref.EnvVars {
+			if envVar.Required {
+				required = append(required, "`"+envVar.Name+"`")
+			}
+		}
+		if len(required) > 0 {
+			fmt.Fprintf(&b, "\nRequired unless set in the config: %v.\n", strings.Join(required, ", "))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteTestReferenceJSON writes the references of tests as indented JSON
+func WriteTestReferenceJSON(w io.Writer, refs []*TestReference) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(refs)
+}
+
+func markdownCode(s string) string {
+	if s == "" || s == generatedDefault {
+		return s
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+// WriteTestExample writes an example test block for each reference, with
+// the options of its config block commented. Options with defaults are set
+// to them, and required options to empty values which must be filled in.
+// Other options, and blocks without any options set, are commented out.
+// The weight of the tests is split evenly between them.
+func WriteTestExample(w io.Writer, refs []*TestReference) error {
+	var b strings.Builder
+	for i, ref := range refs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		weight := 100 / len(refs)
+		if i == 0 {
+			weight += 100 % len(refs)
+		}
+		fmt.Fprintf(&b, "test %q %q {\n", ref.Type, ref.Type+"_test")
+		fmt.Fprintf(&b, "    weight = %d\n", weight)
+		if len(ref.Fields) == 0 {
+			b.WriteString("    # This test has no config block.\n")
+		} else {
+			b.WriteString("    config {\n")
+			envRequired := make(map[string]bool, len(ref.EnvVars))
+			for _, envVar := range ref.EnvVars {
+				envRequired[envVar.Name] = envVar.Required
+			}
+			writeExampleFields(&b, ref.Fields, envRequired, "", 2, false)
+			b.WriteString("    }\n")
+		}
+		b.WriteString("}\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeExampleFields writes the options of the block of the prefix, which
+// are all commented out when the block is
+func writeExampleFields(b *strings.Builder, fields []*FieldReference, envRequired map[string]bool, prefix string, depth int, commented bool) {
+	indent := strings.Repeat("    ", depth)
+	for _, field := range fields {
+		name, ok := strings.CutPrefix(field.Name, prefix)
+		if !ok || strings.Contains(name, ".") {
+			continue
+		}
+
+		required := "optional"
+		if field.Required {
+			required = "required"
+		}
+		comment := field.Type + ", " + required
+		switch {
+		case field.EnvVar != "" && envRequired[field.EnvVar]:
+			comment += ", read from " + field.EnvVar + " when unset, which must then be set"
+		case field.EnvVar != "":
+			comment += ", read from " + field.EnvVar + " when unset"
+		case field.Default == generatedDefault:
+			comment += ", generated when unset"
+		}
+
+		line := indent
+		active := !commented && exampleFieldSet(fields, field)
+		if !active {
+			line += "# "
+		}
+		fmt.Fprintf(b, "%v# %v\n", indent, comment)
+		switch field.Type {
+		case "block", "list of blocks":
+			fmt.Fprintf(b, "%v%v {\n", line, name)
+			writeExampleFields(b, fields, envRequired, field.Name+".", depth+1, !active)
+			fmt.Fprintf(b, "%v}\n", line)
+		default:
+			value := field.Default
+			if value == "" || value == generatedDefault {
+				value = examplePlaceholder(field.Type)
+			}
+			fmt.Fprintf(b, "%v%v = %v\n", line, name, value)
+		}
+	}
+}
+
+// exampleFieldSet reports whether an option is set in example configs,
+// which attributes are when they have a default or must be set in the
+// config, and blocks are when they are required or any of their options
+// are set. Lists of blocks are never set, as they have no defaults.
+func exampleFieldSet(fields []*FieldReference, field *FieldReference) bool {
+	switch field.Type {
+	case "list of blocks":
+		return false
+	case "block":
+		if field.Required {
+			return true
+		}
+		for _, child := range fields {
+			name, ok := strings.CutPrefix(child.Name, field.Name+".")
+			if ok && !strings.Contains(name, ".") && exampleFieldSet(fields, child) {
+				return true
+			}
+		}
+		return false
+	default:
+		if field.Default != "" && field.Default != generatedDefault {
+			return true
+		}
+		return field.Required && field.EnvVar == ""
+	}
+}
+
+// examplePlaceholder returns the empty value of an HCL type
+func examplePlaceholder(hclType string) string {
+	switch {
+	case hclType == "string":
+		return `""`
+	case hclType == "number":
+		return "0"
+	case hclType == "bool":
+		return "false"
+	case strings.HasPrefix(hclType, "list("):
+		return "[]"
+	case strings.HasPrefix(hclType, "map("):
+		return "{}"
+	default:
+		return "null"
+	}
+}
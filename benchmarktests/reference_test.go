@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+func TestTestReferences(t *testing.T) {
+	t.Setenv(PostgreSQLUsernameEnvVar, "secret-user")
+
+	refs, err := TestReferences(PostgreSQLSecretTestType, UserpassTestType, "ha_status")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(refs) != 3 || refs[0].Type != "ha_status" || refs[1].Type != PostgreSQLSecretTestType {
+		t.Fatalf("expected references sorted by type, got: %+v", refs)
+	}
+	if len(refs[0].Fields) != 0 {
+		t.Fatalf("expected ha_status to have no options, got: %+v", refs[0].Fields)
+	}
+	if os.Getenv(PostgreSQLUsernameEnvVar) != "secret-user" {
+		t.Fatal("expected environment variables to be restored")
+	}
+
+	fields := make(map[string]*FieldReference)
+	for _, field := range refs[1].Fields {
+		fields[field.Name] = field
+	}
+	if f := fields["db_connection"]; f == nil || f.Type != "block" || f.Required {
+		t.Fatalf("expected optional db_connection block, got: %+v", f)
+	}
+	if f := fields["db_connection.connection_url"]; f == nil || f.Type != "string" || !f.Required {
+		t.Fatalf("expected required connection_url, got: %+v", f)
+	}
+	if f := fields["db_connection.allowed_roles"]; f == nil || f.Type != "list(string)" || f.Default != `["benchmark-role"]` {
+		t.Fatalf("expected allowed_roles default, got: %+v", f)
+	}
+	if f := fields["db_connection.username"]; f == nil || f.EnvVar != PostgreSQLUsernameEnvVar || f.Default != "" {
+		t.Fatalf("expected username read from the environment without its value, got: %+v", f)
+	}
+	if len(refs[1].EnvVars) != 2 || !refs[1].EnvVars[0].Required || refs[1].EnvVars[0].Field != "db_connection.username" {
+		t.Fatalf("expected required environment variables, got: %+v", refs[1].EnvVars)
+	}
+
+	for _, field := range refs[2].Fields {
+		if field.Name == "password" && field.Default != generatedDefault {
+			t.Fatalf("expected generated password default, got: %q", field.Default)
+		}
+	}
+
+	if _, err := TestReferences("nope"); err == nil {
+		t.Fatal("expected error for unknown test type")
+	}
+}
+
+func TestTestReferences_OptionalEnvVar(t *testing.T) {
+	refs, err := TestReferences(AzureAuthTestType)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	required := make(map[string]bool)
+	for _, envVar := range refs[0].EnvVars {
+		required[envVar.Name] = envVar.Required
+	}
+	if !required[AzureAuthJWT] || required[AzureAuthClientID] || required[AzureAuthClientSecret] {
+		t.Fatalf("expected only the JWT to be required, got: %+v", required)
+	}
+}
+
+func TestWriteTestReferenceMarkdown(t *testing.T) {
+	refs, err := TestReferences(PostgreSQLSecretTestType)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteTestReferenceMarkdown(&buf, refs); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, want := range []string{
+		"## postgresql_secret",
+		"| `db_connection.connection_url` | string | yes |  |  |",
+		"| `db_connection.username` | string | no |  | `VAULT_BENCHMARK_POSTGRES_USERNAME` |",
+		"Required unless set in the config: `VAULT_BENCHMARK_POSTGRES_USERNAME`, `VAULT_BENCHMARK_POSTGRES_PASSWORD`.",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestWriteTestExample(t *testing.T) {
+	refs, err := TestReferences()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteTestExample(&buf, refs); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	file, diags := hclparse.NewParser().ParseHCL(buf.Bytes(), "example.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("err: %v", diags)
+	}
+	content, diags := file.Body.Content(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "test", LabelNames: []string{"type", "name"}}},
+	})
+	if diags.HasErrors() {
+		t.Fatalf("err: %v", diags)
+	}
+	if len(content.Blocks) != len(TestList) {
+		t.Fatalf("expected a test block for each test type, got %d", len(content.Blocks))
+	}
+
+	// Examples of tests without required options parse as they are
+	for _, block := range content.Blocks {
+		if block.Labels[0] != KVV2ReadTestType && block.Labels[0] != UserpassTestType {
+			continue
+		}
+		_, body, diags := block.Body.PartialContent(&hcl.BodySchema{
+			Attributes: []hcl.AttributeSchema{{Name: "weight", Required: true}},
+		})
+		if diags.HasErrors() {
+			t.Fatalf("err: %v", diags)
+		}
+		if err := TestList[block.Labels[0]]().ParseConfig(body); err != nil {
+			t.Fatalf("error parsing example of %v: %v", block.Labels[0], err)
+		}
+	}
+
+	refs, err = TestReferences(PostgreSQLSecretTestType)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	buf.Reset()
+	if err := WriteTestExample(&buf, refs); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	for _, want := range []string{
+		`test "postgresql_secret" "postgresql_secret_test" {`,
+		"    weight = 100\n",
+		"        db_connection {\n",
+		`            allowed_roles = ["benchmark-role"]`,
+		"            # string, required\n            connection_url = \"\"\n",
+		"            # username = \"\"\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
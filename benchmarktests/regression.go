@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// DefaultMaxP99Increase and DefaultMaxThroughputDrop are the limits, in
+	// percent, checked against a baseline when no regression block is given
+	DefaultMaxP99Increase    = 10.0
+	DefaultMaxThroughputDrop = 5.0
+)
+
+// RegressionConfig sets how much worse than a baseline run the results of
+// a test may be. Only the limits which are set are checked. Latency and
+// throughput limits are a percentage of the baseline value, while the
+// error rate limit is in percentage points.
+type RegressionConfig struct {
+	MaxP50Increase       *float64 `hcl:"max_p50_increase,optional"`
+	MaxP99Increase       *float64 `hcl:"max_p99_increase,optional"`
+	MaxThroughputDrop    *float64 `hcl:"max_throughput_drop,optional"`
+	MaxErrorRateIncrease *float64 `hcl:"max_error_rate_increase,optional"`
+}
+
+// DefaultRegressionConfig returns the limits checked against a baseline
+// when the config has no regression block
+func DefaultRegressionConfig() *RegressionConfig {
+	p99, throughput := DefaultMaxP99Increase, DefaultMaxThroughputDrop
+	return &RegressionConfig{MaxP99Increase: &p99, MaxThroughputDrop: &throughput}
+}
+
+func (c *RegressionConfig) Validate() error {
+	limits := []struct {
+		name  string
+		limit *float64
+	}{
+		{"max_p50_increase", c.MaxP50Increase},
+		{"max_p99_increase", c.MaxP99Increase},
+		{"max_throughput_drop", c.MaxThroughputDrop},
+		{"max_error_rate_increase", c.MaxErrorRateIncrease},
+	}
+	set := false
+	for _, l := range limits {
+		if l.limit == nil {
+			continue
+		}
+		if *l.limit < 0 {
+			return fmt.Errorf("%v must not be negative", l.name)
+		}
+		set = true
+	}
+	if !set {
+		return fmt.Errorf("one of max_p50_increase, max_p99_increase, max_throughput_drop or max_error_rate_increase must be set")
+	}
+	return nil
+}
+
+// Regression is a result of a test which is worse than the baseline by
+// more than its limit allows. Change is how much worse it is, in Unit.
+type Regression struct {
+	Phase  string
+	Test   string
+	Metric string
+	Base   string
+	New    string
+	Change float64
+	Limit  float64
+	Unit   string
+}
+
+func (r *Regression) String() string {
+	test := r.Test
+	if r.Phase != "" {
+		test = r.Test + " (" + r.Phase + ")"
+	}
+	return fmt.Sprintf("%v %v regressed from %v to %v by %.2f%v, more than the limit of %.2f%v",
+		test, r.Metric, r.Base, r.New, r.Change, r.Unit, r.Limit, r.Unit)
+}
+
+// CheckRegressions returns the results of every test which regressed from
+// the baseline by more than allowed. Tests use their own limits when set in
+// tests, and the global limits otherwise. Tests which only ran in one of the
+// runs are not checked.
+func CheckRegressions(diffs []*TestDiff, global *RegressionConfig, tests map[string]*RegressionConfig) []*Regression {
+	var regressions []*Regression
+	for _, d := range diffs {
+		if d.Base == nil || d.New == nil {
+			continue
+		}
+		config := global
+		if c, ok := tests[d.Test]; ok && c != nil {
+			config = c
+		}
+
+		check := func(metric string, limit *float64, change float64, unit, baseValue, newValue string) {
+			if limit == nil || change <= *limit {
+				return
+			}
+			regressions = append(regressions, &Regression{
+				Phase:  d.Phase,
+				Test:   d.Test,
+				Metric: metric,
+				Base:   baseValue,
+				New:    newValue,
+				Change: change,
+				Limit:  *limit,
+				Unit:   unit,
+			})
+		}
+		check("p50", config.MaxP50Increase, percentChange(float64(d.Base.P50), float64(d.New.P50)), "%",
+			d.Base.P50.String(), d.New.P50.String())
+		check("p99", config.MaxP99Increase, percentChange(float64(d.Base.P99), float64(d.New.P99)), "%",
+			d.Base.P99.String(), d.New.P99.String())
+		check("throughput", config.MaxThroughputDrop, -percentChange(d.Base.Throughput, d.New.Throughput), "%",
+			fmt.Sprintf("%.2f/s", d.Base.Throughput), fmt.Sprintf("%.2f/s", d.New.Throughput))
+		check("error rate", config.MaxErrorRateIncrease, (d.New.ErrorRate-d.Base.ErrorRate)*100, "pp",
+			fmt.Sprintf("%.2f%%", d.Base.ErrorRate*100), fmt.Sprintf("%.2f%%", d.New.ErrorRate*100))
+	}
+	return regressions
+}
+
+// percentChange returns the change between the values of the base and new
+// runs as a percentage of the base value. Any increase from zero is an
+// infinite increase.
+func percentChange(baseValue, newValue float64) float64 {
+	switch {
+	case baseValue == newValue:
+		return 0
+	case baseValue == 0:
+		return math.Inf(1)
+	}
+	return (newValue - baseValue) / baseValue * 100
+}
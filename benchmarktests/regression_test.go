@@ -0,0 +1,59 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestCheckRegressions(t *testing.T) {
+	stats := func(throughput float64, p99 time.Duration, errorRate float64) *DiffStats {
+		return &DiffStats{Requests: 1000, Throughput: throughput, P50: time.Millisecond, P99: p99, ErrorRate: errorRate}
+	}
+	diffs := []*TestDiff{
+		{Test: "total", Base: stats(300, 10*time.Millisecond, 0), New: stats(290, 10*time.Millisecond, 0.01)},
+		{Test: "read", Base: stats(200, 10*time.Millisecond, 0), New: stats(198, 12*time.Millisecond, 0)},
+		{Test: "write", Base: stats(100, 10*time.Millisecond, 0), New: stats(92, 10*time.Millisecond, 0.01)},
+		{Test: "list", New: stats(100, time.Second, 1)},
+	}
+
+	// Writes have their own limits, which don't check the latency
+	drop, errorRate := 5.0, 0.5
+	tests := map[string]*RegressionConfig{
+		"write": {MaxThroughputDrop: &drop, MaxErrorRateIncrease: &errorRate},
+	}
+	regressions := CheckRegressions(diffs, DefaultRegressionConfig(), tests)
+
+	var got []string
+	for _, r := range regressions {
+		got = append(got, r.Test+" "+r.Metric)
+	}
+	want := []string{"read p99", "write throughput", "write error rate"}
+	if len(got) != len(want) {
+		t.Fatalf("expected regressions %v, got: %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected regressions %v, got: %v", want, got)
+		}
+	}
+	if r := regressions[1]; math.Abs(r.Change-8) > 1e-9 || r.Limit != 5 {
+		t.Fatalf("unexpected throughput regression: %+v", r)
+	}
+	if s := regressions[0].String(); s != "read p99 regressed from 10ms to 12ms by 20.00%, more than the limit of 10.00%" {
+		t.Fatalf("unexpected description: %v", s)
+	}
+}
+
+func TestRegressionConfig_Validate(t *testing.T) {
+	if err := (&RegressionConfig{}).Validate(); err == nil {
+		t.Fatal("expected a regression block without limits to be rejected")
+	}
+	zero := 0.0
+	if err := (&RegressionConfig{MaxErrorRateIncrease: &zero}).Validate(); err != nil {
+		t.Fatalf("expected a zero limit to be allowed, got: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteQueueSize is the number of interval reports which may be
+// waiting to be pushed before further reports are dropped
+const remoteWriteQueueSize = 64
+
+// RemoteWriter pushes interval reports to a Prometheus remote-write
+// endpoint, such as Mimir, Thanos or VictoriaMetrics, so results are kept
+// alongside other long term metrics. Every series is labeled with the run
+// ID, test name and the labels of the run.
+//
+// Reports are pushed from a separate goroutine so a slow endpoint can't
+// hold up the attack; Close waits for the queued reports to be sent.
+type RemoteWriter struct {
+	url    string
+	runID  string
+	labels map[string]string
+	client *http.Client
+
+	queue chan *IntervalReport
+	done  chan struct{}
+
+	l   sync.Mutex
+	err error
+}
+
+func NewRemoteWriter(url, runID string, labels map[string]string) *RemoteWriter {
+	rw := &RemoteWriter{
+		url:    url,
+		runID:  runID,
+		labels: labels,
+		client: &http.Client{Timeout: 30 * time.Second},
+		queue:  make(chan *IntervalReport, remoteWriteQueueSize),
+		done:   make(chan struct{}),
+	}
+	go rw.run()
+	return rw
+}
+
+// Write queues the report to be pushed. Reports are dropped, and an error
+// returned, if the endpoint can't keep up.
+func (rw *RemoteWriter) Write(report *IntervalReport) error {
+	select {
+	case rw.queue <- report:
+		return nil
+	default:
+		err := fmt.Errorf("remote write queue is full, dropping interval ending %v", report.End)
+		rw.setErr(err)
+		return err
+	}
+}
+
+// Close sends any queued reports and stops the writer
+func (rw *RemoteWriter) Close() {
+	close(rw.queue)
+	<-rw.done
+}
+
+// Err returns the first error encountered while pushing interval reports
+func (rw *RemoteWriter) Err() error {
+	rw.l.Lock()
+	defer rw.l.Unlock()
+	return rw.err
+}
+
+func (rw *RemoteWriter) setErr(err error) {
+	rw.l.Lock()
+	defer rw.l.Unlock()
+	if rw.err == nil {
+		rw.err = err
+	}
+}
+
+func (rw *RemoteWriter) run() {
+	defer close(rw.done)
+	for report := range rw.queue {
+		if err := rw.push(report); err != nil {
+			rw.setErr(err)
+		}
+	}
+}
+
+func (rw *RemoteWriter) push(report *IntervalReport) error {
+	body := snappy.Encode(nil, encodeWriteRequest(intervalSeries(report, rw.runID, rw.labels)))
+
+	req, err := http.NewRequest(http.MethodPost, rw.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating remote write request: %v", err)
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error pushing interval metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("error pushing interval metrics: %v: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// remoteSeries is a single sample of a labeled series
+type remoteSeries struct {
+	labels    map[string]string
+	value     float64
+	timestamp time.Time
+}
+
+// intervalSeries converts the metrics of every test in the report into
+// samples, timestamped at the end of the interval
+func intervalSeries(report *IntervalReport, runID string, runLabels map[string]string) []remoteSeries {
+	names := make([]string, 0, len(report.Metrics))
+	for name := range report.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var series []remoteSeries
+	for _, name := range names {
+		m := report.Metrics[name]
+		add := func(metric string, value float64, extra ...string) {
+			labels := make(map[string]string, len(runLabels)+6)
+			for label, value := range runLabels {
+				labels[label] = value
+			}
+			labels["__name__"] = metric
+			labels["run_id"] = runID
+			labels["target"] = report.TargetAddr
+			labels["test"] = name
+			if report.Phase != "" {
+				labels["phase"] = report.Phase
+			}
+			for i := 0; i+1 < len(extra); i += 2 {
+				labels[extra[i]] = extra[i+1]
+			}
+			series = append(series, remoteSeries{labels: labels, value: value, timestamp: report.End})
+		}
+
+		add("bench_interval_requests", float64(m.Requests))
+		add("bench_interval_rate", m.Rate)
+		add("bench_interval_throughput", m.Throughput)
+		add("bench_interval_success_ratio", m.Success)
+		add("bench_interval_latency_mean_seconds", m.Latencies.Mean.Seconds())
+		for _, q := range []struct {
+			label   string
+			latency time.Duration
+		}{
+			{"0.5", m.Latencies.P50},
+			{"0.9", m.Latencies.P90},
+			{"0.95", m.Latencies.P95},
+			{"0.99", m.Latencies.P99},
+			{"1", m.Latencies.Max},
+		} {
+			add("bench_interval_latency_seconds", q.latency.Seconds(), "quantile", q.label)
+		}
+		for code, count := range m.StatusCodes {
+			add("bench_interval_responses", float64(count), "code", code)
+		}
+	}
+	return series
+}
+
+// encodeWriteRequest encodes the series as a remote-write WriteRequest
+// protobuf message:
+//
+//	message WriteRequest { repeated TimeSeries timeseries = 1; }
+//	message TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	message Label { string name = 1; string value = 2; }
+//	message Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []remoteSeries) []byte {
+	var req []byte
+	for _, s := range series {
+		names := make([]string, 0, len(s.labels))
+		for name := range s.labels {
+			names = append(names, name)
+		}
+		// Remote write requires the labels of a series to be sorted
+		sort.Strings(names)
+
+		var ts []byte
+		for _, name := range names {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, s.labels[name])
+
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp.UnixMilli()))
+
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
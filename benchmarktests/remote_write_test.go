@@ -0,0 +1,140 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeWriteRequest decodes the labels and value of each series in a
+// remote-write request
+func decodeWriteRequest(t *testing.T, data []byte) []remoteSeries {
+	t.Helper()
+
+	fields := func(b []byte, fn func(num protowire.Number, v []byte, u uint64)) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			if n < 0 {
+				t.Fatalf("invalid tag: %v", protowire.ParseError(n))
+			}
+			b = b[n:]
+			switch typ {
+			case protowire.BytesType:
+				v, n := protowire.ConsumeBytes(b)
+				fn(num, v, 0)
+				b = b[n:]
+			case protowire.Fixed64Type:
+				u, n := protowire.ConsumeFixed64(b)
+				fn(num, nil, u)
+				b = b[n:]
+			case protowire.VarintType:
+				u, n := protowire.ConsumeVarint(b)
+				fn(num, nil, u)
+				b = b[n:]
+			default:
+				t.Fatalf("unexpected wire type %v", typ)
+			}
+		}
+	}
+
+	var series []remoteSeries
+	fields(data, func(_ protowire.Number, ts []byte, _ uint64) {
+		s := remoteSeries{labels: make(map[string]string)}
+		fields(ts, func(num protowire.Number, v []byte, _ uint64) {
+			switch num {
+			case 1:
+				var name, value string
+				fields(v, func(num protowire.Number, v []byte, _ uint64) {
+					if num == 1 {
+						name = string(v)
+					} else {
+						value = string(v)
+					}
+				})
+				s.labels[name] = value
+			case 2:
+				fields(v, func(num protowire.Number, _ []byte, u uint64) {
+					if num == 1 {
+						s.value = math.Float64frombits(u)
+					} else {
+						s.timestamp = time.UnixMilli(int64(u))
+					}
+				})
+			}
+		})
+		series = append(series, s)
+	})
+	return series
+}
+
+func TestRemoteWriter(t *testing.T) {
+	requests := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "snappy" {
+			t.Errorf("expected a snappy encoded body, got: %v", r.Header.Get("Content-Encoding"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		decoded, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Errorf("expected no error decoding body, got: %v", err)
+		}
+		requests <- decoded
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	end := time.UnixMilli(1700000000000)
+	m := &vegeta.Metrics{}
+	m.Add(&vegeta.Result{Code: 200, Timestamp: end.Add(-time.Second), Latency: time.Millisecond})
+	m.Close()
+
+	rw := NewRemoteWriter(srv.URL, "run-1", map[string]string{"env": "ci"})
+	rw.Write(&IntervalReport{TargetAddr: "http://bao:8200", End: end, Metrics: map[string]*vegeta.Metrics{"read": m}})
+	rw.Close()
+	if err := rw.Err(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	var found bool
+	for _, s := range decodeWriteRequest(t, <-requests) {
+		if s.labels["run_id"] != "run-1" || s.labels["test"] != "read" || s.labels["target"] != "http://bao:8200" || s.labels["env"] != "ci" {
+			t.Fatalf("expected run, test, target and run labels, got: %v", s.labels)
+		}
+		if !s.timestamp.Equal(end) {
+			t.Fatalf("expected samples at the end of the interval, got: %v", s.timestamp)
+		}
+		if s.labels["__name__"] == "bench_interval_responses" {
+			found = true
+			if s.labels["code"] != "200" || s.value != 1 {
+				t.Fatalf("expected a single 200 response, got: %v = %v", s.labels, s.value)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected a bench_interval_responses series")
+	}
+}
+
+func TestRemoteWriter_Error(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	rw := NewRemoteWriter(srv.URL, "run-1", nil)
+	rw.Write(&IntervalReport{End: time.Now(), Metrics: map[string]*vegeta.Metrics{"total": {}}})
+	rw.Close()
+	if err := rw.Err(); err == nil {
+		t.Fatalf("expected an error from a rejected push")
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// ReplayEvent is a single request in a trace: which test to send a request
+// for, and when to send it relative to the start of the replay
+type ReplayEvent struct {
+	Offset time.Duration
+	Test   string
+}
+
+// replayRecord is a line of an NDJSON trace file. The offset may either be
+// a duration string such as "1.5s" or a number of seconds.
+type replayRecord struct {
+	Offset json.RawMessage `json:"offset"`
+	Test   string          `json:"test"`
+}
+
+// LoadReplayFile reads the trace at path. Files ending in .csv are read as
+// CSV with an offset and test name per row; anything else is read as
+// newline delimited JSON. The events are returned sorted by offset.
+func LoadReplayFile(path string) ([]ReplayEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening replay file: %v", err)
+	}
+	defer f.Close()
+
+	var events []ReplayEvent
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		events, err = ParseReplayCSV(f)
+	} else {
+		events, err = ParseReplayNDJSON(f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("replay file %v contains no events", path)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Offset < events[j].Offset
+	})
+	return events, nil
+}
+
+// ParseReplayCSV reads a trace with one "offset,test" row per request. A
+// first row which doesn't start with a valid offset is treated as a header.
+func ParseReplayCSV(r io.Reader) ([]ReplayEvent, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 2
+	cr.TrimLeadingSpace = true
+
+	var events []ReplayEvent
+	for line := 1; ; line++ {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading replay CSV: %v", err)
+		}
+
+		offset, err := parseReplayOffset(record[0])
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("invalid offset on line %d: %v", line, err)
+		}
+		events = append(events, ReplayEvent{Offset: offset, Test: record[1]})
+	}
+	return events, nil
+}
+
+// ParseReplayNDJSON reads a trace with one JSON object per line, each with
+// an offset and test field
+func ParseReplayNDJSON(r io.Reader) ([]ReplayEvent, error) {
+	var events []ReplayEvent
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record replayRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("error decoding replay JSON on line %d: %v", line, err)
+		}
+
+		var raw string
+		if err := json.Unmarshal(record.Offset, &raw); err != nil {
+			raw = string(record.Offset)
+		}
+		offset, err := parseReplayOffset(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid offset on line %d: %v", line, err)
+		}
+		events = append(events, ReplayEvent{Offset: offset, Test: record.Test})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading replay file: %v", err)
+	}
+	return events, nil
+}
+
+// parseReplayOffset accepts either a duration string or a number of seconds
+func parseReplayOffset(raw string) (time.Duration, error) {
+	var offset time.Duration
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+		offset = time.Duration(seconds * float64(time.Second))
+	} else {
+		offset, err = time.ParseDuration(raw)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("offset must not be negative")
+	}
+	return offset, nil
+}
+
+// replayPacer sends one hit at the offset of each event, stopping after the
+// last one
+type replayPacer struct {
+	events []ReplayEvent
+}
+
+func (p *replayPacer) Pace(elapsed time.Duration, hits uint64) (time.Duration, bool) {
+	if hits >= uint64(len(p.events)) {
+		return 0, true
+	}
+	return p.events[hits].Offset - elapsed, false
+}
+
+// Rate returns the rate of the trace around elapsed, averaged over the
+// second before it
+func (p *replayPacer) Rate(elapsed time.Duration) float64 {
+	from := sort.Search(len(p.events), func(i int) bool {
+		return p.events[i].Offset > elapsed-time.Second
+	})
+	to := sort.Search(len(p.events), func(i int) bool {
+		return p.events[i].Offset > elapsed
+	})
+	return float64(to - from)
+}
+
+// replayTargeter returns the targets of the events in order. Targets are
+// handed out in the order workers ask for them, which follows the order
+// hits were paced.
+func replayTargeter(tm *TargetMulti, client *api.Client, events []ReplayEvent) (vegeta.Targeter, error) {
+	byName := make(map[string]*BenchmarkTarget, len(tm.targets))
+	for i := range tm.targets {
+		byName[tm.targets[i].Name] = &tm.targets[i]
+	}
+
+	targets := make([]*BenchmarkTarget, len(events))
+	for i, event := range events {
+		target, ok := byName[event.Test]
+		if !ok {
+			return nil, fmt.Errorf("replay event %d references unknown test: %v", i, event.Test)
+		}
+		targets[i] = target
+	}
+
+	var next atomic.Uint64
+	return func(tgt *vegeta.Target) error {
+		if tgt == nil {
+			return vegeta.ErrNilTarget
+		}
+		i := next.Add(1) - 1
+		if i >= uint64(len(targets)) {
+			return vegeta.ErrNoTargets
+		}
+		*tgt = targets[i].Target(client)
+		return nil
+	}, nil
+}
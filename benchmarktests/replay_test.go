@@ -0,0 +1,81 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestParseReplay(t *testing.T) {
+	expected := []ReplayEvent{
+		{Offset: 0, Test: "read"},
+		{Offset: 1500 * time.Millisecond, Test: "write"},
+		{Offset: 2 * time.Second, Test: "read"},
+	}
+
+	csvEvents, err := ParseReplayCSV(strings.NewReader("offset,test\n0,read\n1.5,write\n2s,read\n"))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(csvEvents, expected) {
+		t.Fatalf("unexpected CSV events: %v", csvEvents)
+	}
+
+	ndjson := `{"offset": 0, "test": "read"}
+{"offset": "1.5s", "test": "write"}
+
+{"offset": 2, "test": "read"}
+`
+	jsonEvents, err := ParseReplayNDJSON(strings.NewReader(ndjson))
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(jsonEvents, expected) {
+		t.Fatalf("unexpected NDJSON events: %v", jsonEvents)
+	}
+
+	if _, err := ParseReplayCSV(strings.NewReader("0,read\nsoon,write\n")); err == nil {
+		t.Fatal("expected an error for an invalid offset")
+	}
+}
+
+func TestReplay_PacerAndTargeter(t *testing.T) {
+	events := []ReplayEvent{
+		{Offset: 0, Test: "read"},
+		{Offset: time.Second, Test: "write"},
+	}
+
+	p := &replayPacer{events: events}
+	if wait, stop := p.Pace(200*time.Millisecond, 1); stop || wait != 800*time.Millisecond {
+		t.Fatalf("expected to wait 800ms for the second event, got: %v, %v", wait, stop)
+	}
+	if _, stop := p.Pace(time.Second, 2); !stop {
+		t.Fatal("expected the pacer to stop after the last event")
+	}
+
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "read", Target: func(*api.Client) vegeta.Target { return vegeta.Target{Method: "GET"} }},
+		{Name: "write", Target: func(*api.Client) vegeta.Target { return vegeta.Target{Method: "POST"} }},
+	}}
+	tr, err := replayTargeter(tm, nil, events)
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	for _, method := range []string{"GET", "POST"} {
+		var tgt vegeta.Target
+		if err := tr(&tgt); err != nil || tgt.Method != method {
+			t.Fatalf("expected a %v target, got: %v, %v", method, tgt.Method, err)
+		}
+	}
+
+	if _, err := replayTargeter(tm, nil, []ReplayEvent{{Test: "list"}}); err == nil {
+		t.Fatal("expected an error for an unknown test")
+	}
+}
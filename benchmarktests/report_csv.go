@@ -0,0 +1,143 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// ReportCSV writes the reports as CSV with one row per test, and per
+// warmup or burst section of a test, for loading into a spreadsheet. A
+// single header row is written for all reports, so the reports must share
+// the same percentiles. Latencies are in milliseconds.
+func ReportCSV(w io.Writer, rpts []*Reporter) error {
+	cw := csv.NewWriter(w)
+
+	var percentiles []float64
+	if len(rpts) > 0 {
+		percentiles = rpts[0].reportedPercentiles()
+	}
+
+	header := []string{"target_addr", "phase", "test", "section", "requests", "rate", "throughput", "success_ratio", "latency_mean_ms", "latency_min_ms"}
+	for _, p := range percentiles {
+		header = append(header, "latency_"+csvPercentileName(p)+"_ms")
+	}
+	header = append(header, "bytes_in", "bytes_out", "mb_in_per_sec", "mb_out_per_sec", "status_codes", "errors")
+	if err := cw.Write(header); err != nil {
+		return fmt.Errorf("error writing CSV report: %v", err)
+	}
+
+	for _, rpt := range rpts {
+		for _, row := range rpt.csvRows(percentiles) {
+			if err := cw.Write(row); err != nil {
+				return fmt.Errorf("error writing CSV report: %v", err)
+			}
+		}
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("error writing CSV report: %v", err)
+	}
+	return nil
+}
+
+func (r *Reporter) csvRows(percentiles []float64) [][]string {
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "total" {
+			return true
+		}
+		if names[j] == "total" {
+			return false
+		}
+		return names[i] < names[j]
+	})
+
+	var rows [][]string
+	for _, name := range names {
+		rows = append(rows, r.csvRow(name, "main", r.metrics[name], r.histograms[name], percentiles))
+		for _, op := range r.sortedOperations(name) {
+			rows = append(rows, r.csvRow(operationLabel(name, op), "main", r.opMetrics[name][op], r.opHistograms[name][op], percentiles))
+		}
+	}
+	for _, extra := range r.extraSections() {
+		for _, name := range names {
+			m, ok := extra.metrics[name]
+			if ok && m.Requests > 0 {
+				rows = append(rows, r.csvRow(name, extra.label, m, nil, percentiles))
+			}
+		}
+	}
+	return rows
+}
+
+func (r *Reporter) csvRow(name, section string, m *vegeta.Metrics, h *Histogram, percentiles []float64) []string {
+	mean := m.Latencies.Mean
+	if h != nil && r.corrected {
+		mean = h.Mean()
+	}
+
+	row := []string{
+		r.clientAddr,
+		r.phase,
+		name,
+		section,
+		strconv.FormatUint(m.Requests, 10),
+		strconv.FormatFloat(m.Rate, 'f', 3, 64),
+		strconv.FormatFloat(m.Throughput, 'f', 3, 64),
+		strconv.FormatFloat(m.Success, 'f', 4, 64),
+		csvMilliseconds(mean),
+		csvMilliseconds(m.Latencies.Min),
+	}
+	for _, p := range percentiles {
+		if latency, ok := r.latencyPercentile(m, h, p); ok {
+			row = append(row, csvMilliseconds(latency))
+		} else {
+			row = append(row, "")
+		}
+	}
+
+	codes := make([]string, 0, len(m.StatusCodes))
+	for code := range m.StatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for i, code := range codes {
+		codes[i] = fmt.Sprintf("%s=%d", code, m.StatusCodes[code])
+	}
+
+	in, out := bandwidth(m)
+	return append(row,
+		strconv.FormatUint(m.BytesIn.Total, 10),
+		strconv.FormatUint(m.BytesOut.Total, 10),
+		strconv.FormatFloat(in, 'f', 3, 64),
+		strconv.FormatFloat(out, 'f', 3, 64),
+		strings.Join(codes, " "),
+		strconv.FormatUint(uint64(math.Round(float64(m.Requests)*(1-m.Success))), 10),
+	)
+}
+
+func csvPercentileName(p float64) string {
+	if p == 100 {
+		return "max"
+	}
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+func csvMilliseconds(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
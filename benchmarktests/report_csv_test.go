@@ -0,0 +1,61 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestReportCSV(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.phase = "steady"
+	now := time.Now()
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 200, Timestamp: now, Latency: 2 * time.Millisecond, BytesIn: 10})
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 500, Timestamp: now, Latency: 4 * time.Millisecond, Error: "500 Internal Server Error"})
+	rpt.Close()
+	rpt.SetPercentiles([]float64{50, 100})
+
+	var buf bytes.Buffer
+	if err := ReportCSV(&buf, []*Reporter{rpt}); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("expected valid CSV, got: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected a header and 2 rows, got: %v", records)
+	}
+
+	row := make(map[string]string)
+	for i, column := range records[0] {
+		row[column] = records[2][i]
+	}
+	want := map[string]string{
+		"phase":           "steady",
+		"test":            "read",
+		"section":         "main",
+		"requests":        "2",
+		"success_ratio":   "0.5000",
+		"latency_max_ms":  "4.000",
+		"bytes_in":        "10",
+		"status_codes":    "200=1 500=1",
+		"errors":          "1",
+		"latency_mean_ms": "3.000",
+	}
+	for column, value := range want {
+		if row[column] != value {
+			t.Fatalf("expected %v to be %q, got: %q", column, value, row[column])
+		}
+	}
+	if records[1][2] != "total" {
+		t.Fatalf("expected the total row first, got: %v", records[1])
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// markdownPercentiles are the latency percentiles shown in markdown reports
+// when none are configured
+var markdownPercentiles = []float64{50, 95, 99}
+
+// ReportMarkdown writes a compact markdown summary of the reports, a table
+// of the throughput, latencies and errors of every test, for pasting into
+// pull requests or chat. As with ReportCSV, a single header is written for
+// all reports, so the reports must share the same percentiles. When
+// baseline reports are given, a second table shows the change of each test
+// from the baseline as in WriteDiff.
+func ReportMarkdown(w io.Writer, rpts, baseline []*Reporter) error {
+	percentiles := markdownPercentiles
+	if len(rpts) > 0 && len(rpts[0].percentiles) > 0 {
+		percentiles = rpts[0].percentiles
+	}
+	targets := make(map[string]bool)
+	var phases bool
+	for _, rpt := range rpts {
+		targets[rpt.clientAddr] = true
+		phases = phases || rpt.phase != ""
+	}
+
+	// Target and phase columns are only shown when they tell rows apart
+	var b strings.Builder
+	var header []string
+	if len(targets) > 1 {
+		header = append(header, "target")
+	}
+	if phases {
+		header = append(header, "phase")
+	}
+	header = append(header, "test", "ops/s")
+	for _, p := range percentiles {
+		header = append(header, markdownPercentileLabel(p))
+	}
+	header = append(header, "errors")
+	markdownRow(&b, header)
+	markdownDivider(&b, len(header))
+
+	for _, rpt := range rpts {
+		names := make([]string, 0, len(rpt.metrics))
+		for name := range rpt.metrics {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if names[i] == "total" || names[j] == "total" {
+				return names[i] == "total" && names[j] != "total"
+			}
+			return names[i] < names[j]
+		})
+
+		for _, name := range names {
+			m, h := rpt.metrics[name], rpt.histograms[name]
+			var row []string
+			if len(targets) > 1 {
+				row = append(row, rpt.clientAddr)
+			}
+			if phases {
+				row = append(row, rpt.phase)
+			}
+			row = append(row, name, fmt.Sprintf("%.2f", m.Throughput))
+			for _, p := range percentiles {
+				latency, ok := rpt.latencyPercentile(m, h, p)
+				if !ok {
+					row = append(row, "-")
+					continue
+				}
+				row = append(row, markdownLatency(latency))
+			}
+			failures := uint64(math.Round(float64(m.Requests) * (1 - m.Success)))
+			if failures == 0 {
+				row = append(row, "0")
+			} else {
+				row = append(row, fmt.Sprintf("%d (%.2f%%)", failures, (1-m.Success)*100))
+			}
+			markdownRow(&b, row)
+		}
+	}
+
+	if len(baseline) > 0 {
+		b.WriteString("\n**Change from baseline**\n\n")
+		header := []string{"test"}
+		for _, metric := range diffMetrics {
+			header = append(header, metric.name)
+		}
+		markdownRow(&b, header)
+		markdownDivider(&b, len(header))
+
+		for _, d := range DiffReports(baseline, rpts) {
+			row := []string{d.Test}
+			if d.Phase != "" {
+				row[0] = d.Test + " (" + d.Phase + ")"
+			}
+			for _, metric := range diffMetrics {
+				switch {
+				case d.Base == nil:
+					row = append(row, "added")
+				case d.New == nil:
+					row = append(row, "removed")
+				default:
+					row = append(row, markdownChange(metric, d.Base, d.New))
+				}
+			}
+			markdownRow(&b, row)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// markdownChange formats a metric of the baseline and new runs with their
+// change, in bold when the change is significantly worse
+func markdownChange(metric diffMetric, baseStats, newStats *DiffStats) string {
+	baseValue, newValue := metric.value(baseStats), metric.value(newStats)
+	change, verdict := diffChange(metric, baseValue, newValue, DefaultDiffThreshold)
+	if verdict == "worse" {
+		change = "**" + change + "**"
+	}
+	format := metric.format
+	if metric.latency {
+		format = func(v float64) string { return markdownLatency(time.Duration(v)) }
+	}
+	return fmt.Sprintf("%s → %s (%s)", format(baseValue), format(newValue), change)
+}
+
+func markdownPercentileLabel(p float64) string {
+	if p == 100 {
+		return "max"
+	}
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// markdownLatency rounds latencies so tables stay compact
+func markdownLatency(d time.Duration) string {
+	return d.Round(10 * time.Microsecond).String()
+}
+
+// markdownEscaper escapes the characters which would break a table cell
+var markdownEscaper = strings.NewReplacer("|", `\|`, "\n", " ")
+
+func markdownRow(b *strings.Builder, cells []string) {
+	for i, cell := range cells {
+		cells[i] = markdownEscaper.Replace(cell)
+	}
+	b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+}
+
+func markdownDivider(b *strings.Builder, columns int) {
+	b.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+}
@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// markdownReport returns a report of a read test with the given latency,
+// of which one in ten requests fail when failing is set
+func markdownReport(latency time.Duration, failing bool) *Reporter {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		result := &vegeta.Result{
+			Method:    "GET",
+			URL:       "N/A/v1/secret/foo",
+			Code:      200,
+			Timestamp: began.Add(time.Duration(i) * time.Second),
+			Latency:   latency,
+		}
+		if failing && i == 9 {
+			result.Code = 500
+			result.Error = "500 Internal Server Error"
+		}
+		rpt.Add(result)
+	}
+	rpt.Close()
+	return rpt
+}
+
+func TestReportMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := ReportMarkdown(&buf, []*Reporter{markdownReport(2*time.Millisecond, true)}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"| test | ops/s | p50 | p95 | p99 | errors |",
+		"| --- | --- | --- | --- | --- | --- |",
+		"| total | 1.00 | 2ms | 2ms | 2ms | 1 (10.00%) |",
+		"| read | 1.00 | 2ms | 2ms | 2ms | 1 (10.00%) |",
+	}
+	if got := strings.TrimSpace(buf.String()); got != strings.Join(want, "\n") {
+		t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), got)
+	}
+}
+
+func TestReportMarkdown_Percentiles(t *testing.T) {
+	rpt := markdownReport(2*time.Millisecond, false)
+	rpt.SetPercentiles([]float64{90, 99.9, 100})
+	var buf bytes.Buffer
+	if err := ReportMarkdown(&buf, []*Reporter{rpt}, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"| test | ops/s | p90 | p99.9 | max | errors |",
+		"| --- | --- | --- | --- | --- | --- |",
+		"| total | 1.11 | 2ms | 2ms | 2ms | 0 |",
+		"| read | 1.11 | 2ms | 2ms | 2ms | 0 |",
+	}
+	if got := strings.TrimSpace(buf.String()); got != strings.Join(want, "\n") {
+		t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), got)
+	}
+}
+
+func TestReportMarkdown_Baseline(t *testing.T) {
+	var buf bytes.Buffer
+	baseline := []*Reporter{markdownReport(time.Millisecond, false)}
+	if err := ReportMarkdown(&buf, []*Reporter{markdownReport(2*time.Millisecond, false)}, baseline); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"**Change from baseline**",
+		"| test | throughput | p50 | p99 | error rate |",
+		"| 1ms → 2ms (**+100.00%**) | 1ms → 2ms (**+100.00%**) | 0.00% → 0.00% (0.00%) |",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("expected %q in:\n%v", want, buf.String())
+		}
+	}
+}
@@ -9,7 +9,9 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"text/tabwriter"
+	"time"
 
 	"github.com/openbao/openbao/api/v2"
 	"github.com/prometheus/client_golang/prometheus"
@@ -33,12 +35,179 @@ func init() {
 type Reporter struct {
 	tm         *TargetMulti
 	clientAddr string
+	phase      string
 	metrics    map[string]*vegeta.Metrics
+
+	// addrs are the addresses requests were sent to when the attack was
+	// balanced between several, in which case addrMetrics and
+	// addrHistograms break the main metrics down by the node which served
+	// them. nodeHeader is the response header naming that node, if any.
+	// nodes, when set, are the nodes discovered during the attack.
+	addrs          []string
+	nodes          *NodeSet
+	nodeHeader     string
+	addrMetrics    map[string]*vegeta.Metrics
+	addrHistograms map[string]*Histogram
+
+	// nsMetrics and nsHistograms break the main metrics down by the
+	// namespace requests were sent to, when tests were fanned out into
+	// several
+	nsMetrics    map[string]*vegeta.Metrics
+	nsHistograms map[string]*Histogram
+
+	// server describes the server the results were measured against
+	server *ServerInfo
+
+	// labels are the labels of the run, such as its environment
+	labels map[string]string
+
+	// transport is the settings of the HTTP transport requests were sent
+	// with
+	transport *TransportConfig
+
+	// tokenRenewal counts the renewals of the benchmark's token
+	tokenRenewal *TokenRenewalStats
+
+	// tokenPool is the number of tokens requests were spread across, and
+	// tokenType their type
+	tokenPool int
+	tokenType string
+
+	// Results sent during a target's warmup period are recorded separately
+	// so they do not skew the main metrics. The warmup of a target starting
+	// after the attack counts from when it started, in nanoseconds since the
+	// epoch in testStarts
+	began         time.Time
+	warmups       map[string]time.Duration
+	warmupMetrics map[string]*vegeta.Metrics
+	testStarts    map[string]*atomic.Int64
+
+	// Results sent during a burst window are additionally recorded on
+	// their own so the impact of the bursts can be seen
+	burst        *BurstConfig
+	burstMetrics map[string]*vegeta.Metrics
+
+	// intervals periodically writes out summaries of the results while the
+	// attack is running
+	intervals *intervalRecorder
+
+	// series records the results of every seriesInterval into timeseries,
+	// to be included in the JSON report
+	series         *intervalRecorder
+	seriesInterval time.Duration
+	timeseries     map[string][]TimeseriesPoint
+
+	// resources is the resource usage of the target during the time series
+	resources *ResourceUsage
+
+	// recovery counts the results of every second of the attack when
+	// chaos events are run, from which chaosEvents are given the time the
+	// target took to recover from them
+	recovery    []recoverySecond
+	chaosEvents []ChaosEventResult
+
+	// search is the throughput search the report is the capacity of
+	search *SearchReport
+
+	// live is shown every result as it arrives
+	live *LiveView
+
+	// resultLog is written every individual result
+	resultLog *ResultLog
+
+	// budgets holds the error budgets of the whole attack ("total") and of
+	// individual targets. Once one is exceeded abort is called and the
+	// reason is kept in budgetErr.
+	budgets   map[string]*errorBudget
+	abort     func()
+	budgetErr error
+
+	// throttled counts, approximately, the requests whose submission was
+	// delayed because max_in_flight requests were already outstanding
+	throttled uint64
+
+	// histograms keeps the full latency distribution of the main metrics.
+	// When corrected is set, latencies are measured from the time each
+	// request was scheduled to be sent rather than when it was sent.
+	histograms map[string]*Histogram
+	corrected  bool
+
+	// codeHistograms breaks the latencies of histograms down by response
+	// status code, so fast failures don't hide slow successes
+	codeHistograms map[string]map[uint16]*Histogram
+
+	// opMetrics and opHistograms break the main metrics of tests which
+	// name their operations down by operation, so each kind of request of
+	// a mixed test is reported on its own
+	opMetrics    map[string]map[string]*vegeta.Metrics
+	opHistograms map[string]map[string]*Histogram
+
+	// cacheHistograms breaks the latencies of each test down by whether
+	// the response was a cache hit or miss of the proxy it was sent through
+	cacheHistograms map[string]map[string]*Histogram
+
+	// redirects counts the requests of each test which were redirected to
+	// another node, and the latency the redirects added
+	redirects map[string]*RedirectStats
+
+	// connections counts the requests of each test sent on new and reused
+	// connections, and the latency of the TLS handshakes
+	connections map[string]*ConnectionStats
+
+	// retries counts the requests of each test which were retried, and
+	// how many of them succeeded
+	retries map[string]*RetryStats
+
+	// expectations are the expect rules of the tests which have them, and
+	// validation counts the responses of each test checked against them
+	expectations map[string]*expectation
+	validation   map[string]*ValidationStats
+
+	// errorGroups counts the failed requests of each test by status code
+	// and error message, keeping up to errorSamples response bodies of each
+	errorGroups  map[string][]*ErrorGroup
+	errorSamples int
+
+	// percentiles are the latency percentiles shown in the terse and
+	// verbose reports, the defaults being used when empty
+	percentiles []float64
 }
 
 type JSONReport struct {
-	TargetAddr string                     `json:"target_addr"`
-	Metrics    map[string]*vegeta.Metrics `json:"metrics"`
+	SchemaVersion int                        `json:"schema_version"`
+	TargetAddr    string                     `json:"target_addr"`
+	Server        *ServerInfo                `json:"server,omitempty"`
+	Labels        map[string]string          `json:"labels,omitempty"`
+	Transport     *TransportConfig           `json:"transport,omitempty"`
+	TokenRenewal  *TokenRenewalStats         `json:"token_renewal,omitempty"`
+	TokenPool     int                        `json:"token_pool,omitempty"`
+	TokenType     string                     `json:"token_type,omitempty"`
+	Phase         string                     `json:"phase,omitempty"`
+	Metrics       map[string]*vegeta.Metrics `json:"metrics"`
+	WarmupMetrics map[string]*vegeta.Metrics `json:"warmup_metrics,omitempty"`
+	BurstMetrics  map[string]*vegeta.Metrics `json:"burst_metrics,omitempty"`
+	Throttled     uint64                     `json:"throttled,omitempty"`
+	Histograms    map[string]*Histogram      `json:"histograms,omitempty"`
+	Corrected     bool                       `json:"coordinated_omission_corrected,omitempty"`
+
+	StatusCodeHistograms map[string]map[uint16]*Histogram      `json:"status_code_histograms,omitempty"`
+	ErrorGroups          map[string][]*ErrorGroup              `json:"error_groups,omitempty"`
+	OperationMetrics     map[string]map[string]*vegeta.Metrics `json:"operation_metrics,omitempty"`
+	OperationHistograms  map[string]map[string]*Histogram      `json:"operation_histograms,omitempty"`
+	AddressMetrics       map[string]*vegeta.Metrics            `json:"address_metrics,omitempty"`
+	AddressHistograms    map[string]*Histogram                 `json:"address_histograms,omitempty"`
+	NamespaceMetrics     map[string]*vegeta.Metrics            `json:"namespace_metrics,omitempty"`
+	NamespaceHistograms  map[string]*Histogram                 `json:"namespace_histograms,omitempty"`
+	CacheHistograms      map[string]map[string]*Histogram      `json:"cache_histograms,omitempty"`
+	Redirects            map[string]*RedirectStats             `json:"redirects,omitempty"`
+	Connections          map[string]*ConnectionStats           `json:"connections,omitempty"`
+	Retries              map[string]*RetryStats                `json:"retries,omitempty"`
+	Validation           map[string]*ValidationStats           `json:"validation,omitempty"`
+	TimeseriesInterval   time.Duration                         `json:"timeseries_interval,omitempty"`
+	Timeseries           map[string][]TimeseriesPoint          `json:"timeseries,omitempty"`
+	Resources            *ResourceUsage                        `json:"resources,omitempty"`
+	ChaosEvents          []ChaosEventResult                    `json:"chaos_events,omitempty"`
+	Search               *SearchReport                         `json:"search,omitempty"`
 }
 
 func FromReader(r io.Reader) ([]*Reporter, error) {
@@ -49,9 +218,42 @@ func FromReader(r io.Reader) ([]*Reporter, error) {
 		if err := d.Decode(&unmarshaled); err != nil {
 			return nil, fmt.Errorf("could not decode report JSON (index %d): %w", len(reporters), err)
 		}
+		if unmarshaled.SchemaVersion > ResultSchemaVersion {
+			return nil, fmt.Errorf("report (index %d) has schema version %d, newer than the supported version %d", len(reporters), unmarshaled.SchemaVersion, ResultSchemaVersion)
+		}
 		rpt := newReporter(&TargetMulti{}, nil)
 		rpt.clientAddr = unmarshaled.TargetAddr
+		rpt.server = unmarshaled.Server
+		rpt.labels = unmarshaled.Labels
+		rpt.transport = unmarshaled.Transport
+		rpt.tokenRenewal = unmarshaled.TokenRenewal
+		rpt.tokenPool = unmarshaled.TokenPool
+		rpt.tokenType = unmarshaled.TokenType
+		rpt.phase = unmarshaled.Phase
 		rpt.metrics = unmarshaled.Metrics
+		rpt.warmupMetrics = unmarshaled.WarmupMetrics
+		rpt.burstMetrics = unmarshaled.BurstMetrics
+		rpt.throttled = unmarshaled.Throttled
+		rpt.histograms = unmarshaled.Histograms
+		rpt.corrected = unmarshaled.Corrected
+		rpt.codeHistograms = unmarshaled.StatusCodeHistograms
+		rpt.errorGroups = unmarshaled.ErrorGroups
+		rpt.opMetrics = unmarshaled.OperationMetrics
+		rpt.opHistograms = unmarshaled.OperationHistograms
+		rpt.addrMetrics = unmarshaled.AddressMetrics
+		rpt.addrHistograms = unmarshaled.AddressHistograms
+		rpt.nsMetrics = unmarshaled.NamespaceMetrics
+		rpt.nsHistograms = unmarshaled.NamespaceHistograms
+		rpt.cacheHistograms = unmarshaled.CacheHistograms
+		rpt.redirects = unmarshaled.Redirects
+		rpt.connections = unmarshaled.Connections
+		rpt.retries = unmarshaled.Retries
+		rpt.validation = unmarshaled.Validation
+		rpt.timeseries = unmarshaled.Timeseries
+		rpt.seriesInterval = unmarshaled.TimeseriesInterval
+		rpt.resources = unmarshaled.Resources
+		rpt.chaosEvents = unmarshaled.ChaosEvents
+		rpt.search = unmarshaled.Search
 		reporters = append(reporters, rpt)
 	}
 	return reporters, nil
@@ -63,40 +265,319 @@ func newReporter(tm *TargetMulti, client *api.Client) *Reporter {
 		clientAddress = client.Address()
 	}
 	r := &Reporter{tm: tm, clientAddr: clientAddress}
+	if client != nil && isUnixSocket(client) {
+		// Name the socket, while telling results apart by the address
+		// requests to it are sent to
+		r.clientAddr = configuredAddr(client)
+		r.addrs = []string{clientAddress}
+	}
 	r.metrics = make(map[string]*vegeta.Metrics, len(tm.targets)+1)
 	r.metrics["total"] = &vegeta.Metrics{}
+	r.histograms = make(map[string]*Histogram, len(tm.targets)+1)
+	r.histograms["total"] = NewHistogram()
+	r.codeHistograms = make(map[string]map[uint16]*Histogram, len(tm.targets)+1)
+	r.errorGroups = make(map[string][]*ErrorGroup)
+	r.expectations = tm.expectations()
 	for _, t := range tm.targets {
 		r.metrics[t.Name] = &vegeta.Metrics{}
+		r.histograms[t.Name] = NewHistogram()
 	}
 	return r
 }
 
-func (r *Reporter) Add(result *vegeta.Result) {
-	r.metrics["total"].Add(result)
-	for _, target := range r.tm.targets {
-		if result.Method == target.Method && strings.HasPrefix(result.URL, r.clientAddr+target.PathPrefix) {
-			r.metrics[target.Name].Add(result)
-			attackResult.WithLabelValues(target.Name).Observe(result.Latency.Seconds())
-			if result.Error != "" {
-				attackErrors.WithLabelValues(target.Name, result.Error).Inc()
+// startWarmup marks the start of the attack and configures the warmup
+// period of every target. A target's own warmup takes precedence over the
+// global one. Results are only split out when some target has a warmup.
+func (r *Reporter) startWarmup(began time.Time, global time.Duration) {
+	r.began = began
+	r.warmups = make(map[string]time.Duration, len(r.tm.targets)+1)
+	r.warmups["total"] = global
+	r.testStarts = make(map[string]*atomic.Int64, len(r.tm.targets))
+	for _, t := range r.tm.targets {
+		warmup, _ := t.WarmupDuration()
+		if warmup == 0 {
+			warmup = global
+		}
+		r.warmups[t.Name] = warmup
+		r.testStarts[t.Name] = &atomic.Int64{}
+	}
+
+	for _, warmup := range r.warmups {
+		if warmup > 0 {
+			r.warmupMetrics = make(map[string]*vegeta.Metrics, len(r.metrics))
+			for name := range r.metrics {
+				r.warmupMetrics[name] = &vegeta.Metrics{}
 			}
+			return
+		}
+	}
+}
+
+// trackBursts enables recording the results sent during burst windows
+// separately. It must be called after startWarmup.
+func (r *Reporter) trackBursts(burst *BurstConfig) {
+	if burst == nil {
+		return
+	}
+	r.burst = burst
+	r.burstMetrics = make(map[string]*vegeta.Metrics, len(r.metrics))
+	for name := range r.metrics {
+		r.burstMetrics[name] = &vegeta.Metrics{}
+	}
+}
+
+// trackIntervals enables writing a summary of the results to out every
+// interval. It must be called after startWarmup.
+func (r *Reporter) trackIntervals(out IntervalOutput, interval time.Duration) {
+	if out == nil || interval <= 0 {
+		return
+	}
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	r.intervals = newIntervalRecorder(out, interval, r.began, names)
+}
+
+// trackErrorBudgets enables aborting the attack by calling abort once the
+// global budget or the budget of any target is exceeded
+func (r *Reporter) trackErrorBudgets(global *ErrorBudgetConfig, abort func()) {
+	r.budgets = make(map[string]*errorBudget)
+	if global != nil {
+		r.budgets["total"] = newErrorBudget(global)
+	}
+	for _, t := range r.tm.targets {
+		if t.ErrorBudget != nil {
+			r.budgets[t.Name] = newErrorBudget(t.ErrorBudget)
+		}
+	}
+	r.abort = abort
+}
+
+// checkErrorBudgets records the result against the global budget and that
+// of its target, aborting the attack the first time either is exceeded
+func (r *Reporter) checkErrorBudgets(name string, result *vegeta.Result) {
+	if r.budgetErr != nil {
+		return
+	}
+	for _, key := range []string{"total", name} {
+		budget, ok := r.budgets[key]
+		if !ok {
+			continue
+		}
+		if err := budget.add(result); err != nil {
+			if key == "total" {
+				r.budgetErr = fmt.Errorf("%w: %v", ErrErrorBudgetExceeded, err)
+			} else {
+				r.budgetErr = fmt.Errorf("%w for %v: %v", ErrErrorBudgetExceeded, key, err)
+			}
+			r.abort()
+			return
+		}
+		if key == name {
+			// The result was for an unmatched target, don't count it twice
 			break
 		}
 	}
+}
+
+func (r *Reporter) match(result *vegeta.Result) *BenchmarkTarget {
+	_, path := r.splitURL(result.URL)
+	for i, target := range r.tm.targets {
+		if !strings.HasPrefix(path, target.PathPrefix) {
+			continue
+		}
+		if result.Method == target.Method || r.operation(&r.tm.targets[i], result) != "" {
+			return &r.tm.targets[i]
+		}
+	}
+	return nil
+}
+
+func (r *Reporter) inWarmup(name string, result *vegeta.Result) bool {
+	if r.warmupMetrics == nil {
+		return false
+	}
+	began := r.began
+	if start, ok := r.testStarts[name]; ok && start.Load() != 0 {
+		began = time.Unix(0, start.Load())
+	}
+	return result.Timestamp.Before(began.Add(r.warmups[name]))
+}
+
+// startTests records that the tests started being attacked after the start
+// of the attack, so their warmup is counted from then. It may be called
+// while results are being added.
+func (r *Reporter) startTests(tests []BenchmarkTarget, started time.Time) {
+	for _, t := range tests {
+		if start, ok := r.testStarts[t.Name]; ok {
+			start.Store(started.UnixNano())
+		}
+	}
+}
+
+// Metrics returns the main metrics of every test, and of all of them as
+// "total"
+func (r *Reporter) Metrics() map[string]*vegeta.Metrics {
+	return r.metrics
+}
+
+func (r *Reporter) Add(result *vegeta.Result) {
+	r.addDelayed(result, 0)
+}
+
+// addDelayed adds a result which was sent delay after it was scheduled to
+// be. The delay is only counted in the latency histograms.
+func (r *Reporter) addDelayed(result *vegeta.Result, delay time.Duration) {
 	// TODO what if we didn't find any match?
+	target := r.match(result)
+	name := "total"
+	if target != nil {
+		name = target.Name
+	}
+
+	if r.intervals != nil {
+		r.intervals.add(r, name, result)
+	}
+	if r.series != nil {
+		r.series.add(r, name, result)
+	}
+	if r.live != nil {
+		r.live.add(r.phase, name, result)
+	}
+	if r.resultLog != nil {
+		r.resultLog.add(r, name, result)
+	}
+	if r.recovery != nil {
+		r.recordRecovery(result)
+	}
+	if len(r.budgets) > 0 {
+		r.checkErrorBudgets(name, result)
+	}
+
+	metrics := r.metrics
+	if r.inWarmup(name, result) {
+		metrics = r.warmupMetrics
+	} else if r.histograms != nil {
+		r.histograms["total"].Record(result.Latency + delay)
+		r.recordCode("total", result.Code, result.Latency+delay)
+		r.recordCache("total", result, result.Latency+delay)
+		r.recordRedirect("total", result)
+		r.recordConnection("total", result)
+		r.recordRetry("total", result)
+		addr, _ := r.splitURL(result.URL)
+		r.recordAddr(addr, result, result.Latency+delay)
+		r.recordNamespace(result, result.Latency+delay)
+		if target != nil {
+			r.histograms[target.Name].Record(result.Latency + delay)
+			r.recordCode(target.Name, result.Code, result.Latency+delay)
+			r.recordCache(target.Name, result, result.Latency+delay)
+			r.recordRedirect(target.Name, result)
+			r.recordConnection(target.Name, result)
+			r.recordRetry(target.Name, result)
+			r.recordValidation(target.Name, result)
+			if op := r.operation(target, result); op != "" {
+				r.recordOperation(target.Name, op, result, result.Latency+delay)
+			}
+		}
+	}
+	if result.Error != "" && !r.inWarmup(name, result) {
+		msg := normalizeError(result)
+		r.recordError("total", result, msg)
+		if target != nil {
+			r.recordError(target.Name, result, msg)
+		}
+	}
+
+	metrics["total"].Add(result)
+	if r.burst != nil && r.burst.inBurst(result.Timestamp.Sub(r.began)) {
+		r.burstMetrics["total"].Add(result)
+		if target != nil {
+			r.burstMetrics[target.Name].Add(result)
+		}
+	}
+	if target != nil {
+		metrics[target.Name].Add(result)
+		attackResult.WithLabelValues(target.Name).Observe(result.Latency.Seconds())
+		if result.Error != "" {
+			attackErrors.WithLabelValues(target.Name, result.Error).Inc()
+		}
+		r.recordOTelResult(target.Name, result)
+	}
 }
 
 func (r *Reporter) Close() {
+	if r.intervals != nil {
+		r.intervals.flush(r)
+	}
+	if r.series != nil {
+		r.series.flush(r)
+	}
+	r.closeMetrics()
+}
+
+// closeMetrics computes the summaries of the metrics from the results added
+// so far, which may be done again after adding more
+func (r *Reporter) closeMetrics() {
 	for name := range r.metrics {
 		r.metrics[name].Close()
 	}
+	for name := range r.warmupMetrics {
+		r.warmupMetrics[name].Close()
+	}
+	for name := range r.burstMetrics {
+		r.burstMetrics[name].Close()
+	}
+	for name := range r.opMetrics {
+		for op := range r.opMetrics[name] {
+			r.opMetrics[name][op].Close()
+		}
+	}
+	for addr := range r.addrMetrics {
+		r.addrMetrics[addr].Close()
+	}
+	for ns := range r.nsMetrics {
+		r.nsMetrics[ns].Close()
+	}
 }
 
 func (r *Reporter) ReportJSON(w io.Writer) error {
 	j := json.NewEncoder(w)
 	return j.Encode(&JSONReport{
-		TargetAddr: r.clientAddr,
-		Metrics:    r.metrics,
+		SchemaVersion: ResultSchemaVersion,
+		TargetAddr:    r.clientAddr,
+		Server:        r.server,
+		Labels:        r.labels,
+		Transport:     r.transport,
+		TokenRenewal:  r.tokenRenewal,
+		TokenPool:     r.tokenPool,
+		TokenType:     r.tokenType,
+		Phase:         r.phase,
+		Metrics:       r.metrics,
+		WarmupMetrics: r.warmupMetrics,
+		BurstMetrics:  r.burstMetrics,
+		Throttled:     r.throttled,
+		Histograms:    r.histograms,
+		Corrected:     r.corrected,
+
+		StatusCodeHistograms: r.codeHistograms,
+		ErrorGroups:          r.errorGroups,
+		OperationMetrics:     r.opMetrics,
+		OperationHistograms:  r.opHistograms,
+		AddressMetrics:       r.addrMetrics,
+		AddressHistograms:    r.addrHistograms,
+		NamespaceMetrics:     r.nsMetrics,
+		NamespaceHistograms:  r.nsHistograms,
+		CacheHistograms:      r.cacheHistograms,
+		Redirects:            r.redirects,
+		Connections:          r.connections,
+		Retries:              r.retries,
+		Validation:           r.validation,
+		TimeseriesInterval:   r.seriesInterval,
+		Timeseries:           r.timeseries,
+		Resources:            r.resources,
+		ChaosEvents:          r.chaosEvents,
+		Search:               r.search,
 	})
 }
 
@@ -111,21 +592,138 @@ func (r *Reporter) ReportVerbose(w io.Writer) error {
 		}
 		return sections[i] < sections[j]
 	})
+	if r.server != nil {
+		r.server.report(w)
+	}
+	if len(r.labels) > 0 {
+		fmt.Fprintf(w, "Labels: %v\n", formatLabels(r.labels))
+	}
+	if r.transport != nil {
+		r.transport.report(w)
+	}
+	if r.tokenRenewal != nil {
+		r.tokenRenewal.report(w)
+	}
+	if r.tokenPool > 0 {
+		fmt.Fprintf(w, "Token pool: %d %s tokens\n", r.tokenPool, r.tokenType)
+	}
+	if r.phase != "" {
+		fmt.Fprintf(w, "Phase: %v\n", r.phase)
+	}
+	if r.throttled > 0 {
+		fmt.Fprintf(w, "Throttled: %d requests delayed by max_in_flight\n", r.throttled)
+	}
 	for _, name := range sections {
 		fmt.Fprintln(w)
 		fmt.Fprintln(w, name)
 		if err := vegeta.NewTextReporter(r.metrics[name]).Report(w); err != nil {
 			return fmt.Errorf("report error: %v", err)
 		}
+		in, out := bandwidth(r.metrics[name])
+		fmt.Fprintf(w, "Bandwidth     [in, out]                         %.3f MB/s, %.3f MB/s\n", in, out)
+		if h, ok := r.histograms[name]; ok && r.corrected && h.Count() > 0 {
+			fmt.Fprintf(w, "Corrected latencies [min, mean, 50, 90, 95, 99, 99.9, max]  %s, %s, %s, %s, %s, %s, %s, %s\n",
+				h.Min(), h.Mean(), h.Quantile(0.5), h.Quantile(0.9), h.Quantile(0.95), h.Quantile(0.99), h.Quantile(0.999), h.Max())
+		}
+		if h, ok := r.histograms[name]; ok && len(r.percentiles) > 0 && h.Count() > 0 {
+			labels := make([]string, len(r.percentiles))
+			values := make([]string, len(r.percentiles))
+			for i, p := range r.percentiles {
+				labels[i] = percentileLabel(p)
+				values[i] = h.Quantile(p / 100).String()
+			}
+			fmt.Fprintf(w, "Percentiles [%s]  %s\n", strings.Join(labels, ", "), strings.Join(values, ", "))
+		}
+		r.reportCodesVerbose(w, name)
+		r.reportCacheVerbose(w, name)
+		r.reportRedirectsVerbose(w, name)
+		r.reportConnectionsVerbose(w, name)
+		r.reportRetriesVerbose(w, name)
+		r.reportValidationVerbose(w, name)
+		r.reportErrorsVerbose(w, name)
+		for _, op := range r.sortedOperations(name) {
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, operationLabel(name, op))
+			if err := vegeta.NewTextReporter(r.opMetrics[name][op]).Report(w); err != nil {
+				return fmt.Errorf("report error: %v", err)
+			}
+		}
 	}
+	for _, extra := range r.extraSections() {
+		for _, name := range sections {
+			m, ok := extra.metrics[name]
+			if !ok || m.Requests == 0 {
+				continue
+			}
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, name+" ("+extra.label+")")
+			if err := vegeta.NewTextReporter(m).Report(w); err != nil {
+				return fmt.Errorf("report error: %v", err)
+			}
+		}
+	}
+	if err := r.reportAddrsVerbose(w); err != nil {
+		return err
+	}
+	if err := r.reportNamespacesVerbose(w); err != nil {
+		return err
+	}
+	r.reportResources(w)
+	r.reportChaos(w)
+	r.reportFailover(w)
+	r.reportSnapshots(w)
 	return nil
 }
 
+// reportSection is a set of metrics reported in addition to the main ones,
+// such as those recorded during the warmup or burst windows
+type reportSection struct {
+	label   string
+	metrics map[string]*vegeta.Metrics
+}
+
+func (r *Reporter) extraSections() []reportSection {
+	return []reportSection{
+		{label: "warmup", metrics: r.warmupMetrics},
+		{label: "burst", metrics: r.burstMetrics},
+	}
+}
+
 func (r *Reporter) ReportTerse(w io.Writer) error {
 	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.StripEscape)
 	fmt.Fprintf(tw, "Target: %v\n", r.clientAddr)
-	fmt.Fprintf(tw, "op\tcount\trate\tthroughput\tmean\t95th%%\t99th%%\tsuccessRatio\n")
-	const fmtstr = "%s\t%d\t%f\t%f\t%s\t%s\t%s\t%.2f%%\n"
+	if len(r.labels) > 0 {
+		fmt.Fprintf(tw, "Labels: %v\n", formatLabels(r.labels))
+	}
+	// The server and the transport's defaults are only shown in verbose
+	// reports
+	if r.transport != nil && r.transport.configured {
+		r.transport.report(tw)
+	}
+	if r.tokenRenewal != nil {
+		r.tokenRenewal.report(tw)
+	}
+	if r.tokenPool > 0 {
+		fmt.Fprintf(tw, "Token pool: %d %s tokens\n", r.tokenPool, r.tokenType)
+	}
+	if r.phase != "" {
+		fmt.Fprintf(tw, "Phase: %v\n", r.phase)
+	}
+	if r.throttled > 0 {
+		fmt.Fprintf(tw, "Throttled: %d requests delayed by max_in_flight\n", r.throttled)
+	}
+	if r.corrected {
+		fmt.Fprintf(tw, "Latencies corrected for coordinated omission\n")
+	}
+	fmt.Fprintf(tw, "op\tcount\trate\tthroughput\tinMB/s\toutMB/s\tmean\t")
+	for _, p := range r.reportedPercentiles() {
+		if p == 100 {
+			fmt.Fprintf(tw, "max\t")
+		} else {
+			fmt.Fprintf(tw, "%s%%\t", percentileLabel(p))
+		}
+	}
+	fmt.Fprintf(tw, "successRatio\n")
 
 	metricNames := make([]string, 0)
 	for name := range r.metrics {
@@ -137,11 +735,53 @@ func (r *Reporter) ReportTerse(w io.Writer) error {
 	})
 
 	for _, name := range metricNames {
-		m := r.metrics[name]
 		if name != "total" {
-			fmt.Fprintf(tw, fmtstr, name, m.Requests, m.Rate, m.Throughput, m.Latencies.Mean, m.Latencies.P95, m.Latencies.P99, m.Success*100)
+			r.terseRow(tw, name, r.metrics[name], r.histograms[name])
+			for _, op := range r.sortedOperations(name) {
+				r.terseRow(tw, operationLabel(name, op), r.opMetrics[name][op], r.opHistograms[name][op])
+			}
 		}
 	}
+	for _, extra := range r.extraSections() {
+		for _, name := range metricNames {
+			m, ok := extra.metrics[name]
+			if name != "total" && ok && m.Requests > 0 {
+				r.terseRow(tw, name+" ("+extra.label+")", m, nil)
+			}
+		}
+	}
+	r.reportCodesTerse(tw, metricNames)
+	r.reportErrorsTerse(tw, metricNames)
+	r.reportCacheTerse(tw, metricNames)
+	r.reportRedirectsTerse(tw, metricNames)
+	r.reportConnectionsTerse(tw, metricNames)
+	r.reportRetriesTerse(tw, metricNames)
+	r.reportValidationTerse(tw, metricNames)
+	r.reportAddrsTerse(tw)
+	r.reportNamespacesTerse(tw)
 	tw.Flush()
+	r.reportResources(w)
+	r.reportChaos(w)
+	r.reportFailover(w)
+	r.reportSnapshots(w)
 	return nil
 }
+
+// terseRow writes the line of the terse report for one set of metrics. The
+// histogram, when given, holds the same latencies as the metrics.
+func (r *Reporter) terseRow(w io.Writer, label string, m *vegeta.Metrics, h *Histogram) {
+	mean := m.Latencies.Mean
+	if h != nil && r.corrected {
+		mean = h.Mean()
+	}
+	in, out := bandwidth(m)
+	fmt.Fprintf(w, "%s\t%d\t%f\t%f\t%.3f\t%.3f\t%s\t", label, m.Requests, m.Rate, m.Throughput, in, out, mean)
+	for _, p := range r.reportedPercentiles() {
+		if latency, ok := r.latencyPercentile(m, h, p); ok {
+			fmt.Fprintf(w, "%s\t", latency)
+		} else {
+			fmt.Fprintf(w, "-\t")
+		}
+	}
+	fmt.Fprintf(w, "%.2f%%\n", m.Success*100)
+}
@@ -0,0 +1,327 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// requestPolicyHeader names the test whose timeout and retries a
+	// request is sent with. It is set on the targets of tests with their
+	// own and removed before the request is sent.
+	requestPolicyHeader = "X-Benchmark-Request-Policy"
+
+	// retriesHeader is set on the responses of requests which were
+	// retried, to the number of retries, so the retries of each test can
+	// be recorded
+	retriesHeader = "X-Benchmark-Retries"
+
+	// DefaultRetryBackoff and DefaultRetryMaxBackoff are the waits before
+	// the first retry of a request and the longest wait between retries,
+	// when a test doesn't set its own
+	DefaultRetryBackoff    = 100 * time.Millisecond
+	DefaultRetryMaxBackoff = 2 * time.Second
+)
+
+// retriedErrorPattern matches the note on the errors of requests which
+// failed without a response after being retried
+var retriedErrorPattern = regexp.MustCompile(`\(retried (\d+) times\)$`)
+
+// RequestPolicy is the timeout and retries of the requests of a single
+// test, in place of the timeout of the client all other requests are sent
+// with, which otherwise either counts transient failures as errors or
+// hides them behind the API client's own retries
+type RequestPolicy struct {
+	// Timeout is how long each attempt of a request may take, including
+	// reading the response, or zero to keep the client's
+	Timeout time.Duration
+
+	// Retries is how many times a request which failed without a response,
+	// or with a 412, 429 or 5xx other than 501, is sent again
+	Retries int
+
+	// Backoff is the wait before the first retry, doubling with each
+	// retry after it up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// RequestPolicy returns the timeout and retries of the test, or nil when
+// it keeps the client's
+func (bt *BenchmarkTarget) RequestPolicy() (*RequestPolicy, error) {
+	if bt.Timeout == "" && bt.Retries == 0 && bt.RetryBackoff == "" && bt.RetryMaxBackoff == "" {
+		return nil, nil
+	}
+	policy := &RequestPolicy{
+		Retries:    bt.Retries,
+		Backoff:    DefaultRetryBackoff,
+		MaxBackoff: DefaultRetryMaxBackoff,
+	}
+	var err error
+	if bt.Timeout != "" {
+		if policy.Timeout, err = time.ParseDuration(bt.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid timeout: %v", err)
+		}
+		if policy.Timeout <= 0 {
+			return nil, fmt.Errorf("timeout must be positive")
+		}
+	}
+	if bt.Retries < 0 {
+		return nil, fmt.Errorf("retries must not be negative")
+	}
+	if bt.Retries == 0 && (bt.RetryBackoff != "" || bt.RetryMaxBackoff != "") {
+		return nil, fmt.Errorf("retry_backoff and retry_max_backoff require retries")
+	}
+	if bt.RetryBackoff != "" {
+		if policy.Backoff, err = time.ParseDuration(bt.RetryBackoff); err != nil {
+			return nil, fmt.Errorf("invalid retry_backoff: %v", err)
+		}
+	}
+	if bt.RetryMaxBackoff != "" {
+		if policy.MaxBackoff, err = time.ParseDuration(bt.RetryMaxBackoff); err != nil {
+			return nil, fmt.Errorf("invalid retry_max_backoff: %v", err)
+		}
+	} else if policy.Backoff > policy.MaxBackoff {
+		policy.MaxBackoff = policy.Backoff
+	}
+	if policy.Backoff < 0 || policy.MaxBackoff < policy.Backoff {
+		return nil, fmt.Errorf("retry_max_backoff must be at least retry_backoff, which must not be negative")
+	}
+	return policy, nil
+}
+
+// backoff returns the wait before the given retry, counting from one
+func (p *RequestPolicy) backoff(retry int) time.Duration {
+	wait := p.Backoff
+	for i := 1; i < retry && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, p.MaxBackoff)
+}
+
+// send sends a single attempt of the request with base, within the
+// timeout of the policy
+func (p *RequestPolicy) send(base http.RoundTripper, req *http.Request) (*http.Response, error) {
+	if p.Timeout <= 0 {
+		return base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), p.Timeout)
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The timeout covers reading the response
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases the context of a request once its response is read
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// policyTarget returns the targets of the test named by the test's
+// timeout and retries, so they are sent with them
+func policyTarget(name string, target func(*api.Client) vegeta.Target) func(*api.Client) vegeta.Target {
+	return func(client *api.Client) vegeta.Target {
+		tgt := target(client)
+		tgt.Header = tgt.Header.Clone()
+		if tgt.Header == nil {
+			tgt.Header = make(http.Header)
+		}
+		tgt.Header.Set(requestPolicyHeader, name)
+		return tgt
+	}
+}
+
+// requestPolicies returns the timeout and retries of the targets which
+// have their own, by name
+func (tm TargetMulti) requestPolicies() map[string]*RequestPolicy {
+	var policies map[string]*RequestPolicy
+	for _, target := range tm.targets {
+		// Policies are validated when the config is loaded
+		policy, err := target.RequestPolicy()
+		if err != nil || policy == nil {
+			continue
+		}
+		if policies == nil {
+			policies = make(map[string]*RequestPolicy)
+		}
+		policies[target.Name] = policy
+	}
+	return policies
+}
+
+// requestPolicyTransport sends the requests of tests with their own
+// timeout and retries with them, and all others within the timeout of the
+// client they were made with
+type requestPolicyTransport struct {
+	base     http.RoundTripper
+	defaults *RequestPolicy
+	tests    map[string]*RequestPolicy
+}
+
+// newRequestPolicyTransport returns a transport applying the timeout and
+// retries of tests on top of base, with timeout applying to the attempts
+// of all requests which don't have their own
+func newRequestPolicyTransport(base http.RoundTripper, timeout time.Duration, tests map[string]*RequestPolicy) *requestPolicyTransport {
+	t := &requestPolicyTransport{
+		base:     base,
+		defaults: &RequestPolicy{Timeout: timeout},
+		tests:    make(map[string]*RequestPolicy, len(tests)),
+	}
+	for name, policy := range tests {
+		policy := *policy
+		if policy.Timeout == 0 {
+			policy.Timeout = timeout
+		}
+		t.tests[name] = &policy
+	}
+	return t
+}
+
+func (t *requestPolicyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := t.defaults
+	if name := req.Header.Get(requestPolicyHeader); name != "" {
+		if p, ok := t.tests[name]; ok {
+			policy = p
+		}
+		req = req.Clone(req.Context())
+		req.Header.Del(requestPolicyHeader)
+	}
+
+	resp, err := policy.send(t.base, req)
+	retries := 0
+	for retries < policy.Retries {
+		if retry, _ := api.DefaultRetryPolicy(req.Context(), resp, err); !retry {
+			break
+		}
+		next, ok := retryRequest(req)
+		if !ok {
+			break
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		retries++
+		timer := time.NewTimer(policy.backoff(retries))
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		resp, err = policy.send(t.base, next)
+	}
+	if retries > 0 {
+		if err != nil {
+			return nil, fmt.Errorf("%v (retried %d times)", err, retries)
+		}
+		resp.Header.Set(retriesHeader, strconv.Itoa(retries))
+	}
+	return resp, err
+}
+
+// retryRequest returns a copy of req to send again, or false when its body
+// can't be resent
+func retryRequest(req *http.Request) (*http.Request, bool) {
+	next := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		next.Body = body
+	} else if req.Body != nil && req.Body != http.NoBody {
+		return nil, false
+	}
+	return next, true
+}
+
+// RetryStats counts the requests of a test which were retried, the retries
+// they took and how many of them eventually succeeded
+type RetryStats struct {
+	Retried   uint64 `json:"retried"`
+	Retries   uint64 `json:"retries"`
+	Recovered uint64 `json:"recovered"`
+}
+
+// resultRetries returns how many times the request of a result was retried
+func resultRetries(result *vegeta.Result) uint64 {
+	if retries, err := strconv.ParseUint(result.Headers.Get(retriesHeader), 10, 64); err == nil {
+		return retries
+	}
+	if m := retriedErrorPattern.FindStringSubmatch(result.Error); m != nil {
+		retries, _ := strconv.ParseUint(m[1], 10, 64)
+		return retries
+	}
+	return 0
+}
+
+// recordRetry records the retries of the request of a result of the named
+// test, when it was retried
+func (r *Reporter) recordRetry(name string, result *vegeta.Result) {
+	retries := resultRetries(result)
+	if retries == 0 {
+		return
+	}
+	if r.retries == nil {
+		r.retries = make(map[string]*RetryStats)
+	}
+	stats, ok := r.retries[name]
+	if !ok {
+		stats = &RetryStats{}
+		r.retries[name] = stats
+	}
+	stats.Retried++
+	stats.Retries += retries
+	if result.Error == "" {
+		stats.Recovered++
+	}
+}
+
+// reportRetriesVerbose writes the number of retried requests of the named
+// test, their retries and how many of them succeeded
+func (r *Reporter) reportRetriesVerbose(w io.Writer, name string) {
+	stats, ok := r.retries[name]
+	if !ok {
+		return
+	}
+	fmt.Fprintf(w, "Retries       [requests, retries, recovered]   %d, %d, %d\n", stats.Retried, stats.Retries, stats.Recovered)
+}
+
+// reportRetriesTerse writes a table of the retried requests of every test,
+// their retries and how many of them succeeded, when any were retried
+func (r *Reporter) reportRetriesTerse(w io.Writer, names []string) {
+	if len(r.retries) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nop\tretried\tretries\trecovered\n")
+	for _, name := range names {
+		stats, ok := r.retries[name]
+		if name == "total" || !ok {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", name, stats.Retried, stats.Retries, stats.Recovered)
+	}
+}
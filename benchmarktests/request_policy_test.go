@@ -0,0 +1,141 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestBenchmarkTarget_RequestPolicy(t *testing.T) {
+	if policy, err := (&BenchmarkTarget{}).RequestPolicy(); policy != nil || err != nil {
+		t.Fatalf("expected no policy without settings, got %+v, %v", policy, err)
+	}
+	policy, err := (&BenchmarkTarget{Timeout: "1s", Retries: 3}).RequestPolicy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if policy.Timeout != time.Second || policy.Backoff != DefaultRetryBackoff || policy.MaxBackoff != DefaultRetryMaxBackoff {
+		t.Fatalf("expected the default backoff, got %+v", policy)
+	}
+	if got := policy.backoff(1); got != DefaultRetryBackoff {
+		t.Errorf("expected the first retry to wait %v, got %v", DefaultRetryBackoff, got)
+	}
+	if got := policy.backoff(3); got != 4*DefaultRetryBackoff {
+		t.Errorf("expected the backoff to double, got %v", got)
+	}
+	if got := policy.backoff(10); got != DefaultRetryMaxBackoff {
+		t.Errorf("expected the backoff to be capped at %v, got %v", DefaultRetryMaxBackoff, got)
+	}
+
+	for _, bt := range []*BenchmarkTarget{
+		{Timeout: "soon"},
+		{Timeout: "-1s"},
+		{Retries: -1},
+		{RetryBackoff: "1s"},
+		{Retries: 1, RetryBackoff: "1s", RetryMaxBackoff: "500ms"},
+	} {
+		if _, err := bt.RequestPolicy(); err == nil {
+			t.Errorf("expected %+v to be rejected", bt)
+		}
+	}
+}
+
+func TestRequestPolicyTransport(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		switch {
+		case req.Header.Get(requestPolicyHeader) != "":
+			w.WriteHeader(http.StatusBadRequest)
+		case req.URL.Path == "/v1/slow":
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent)
+		case calls.Add(1) <= 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case string(body) != `{"data":{}}`:
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "write", Method: "POST", PathPrefix: "/v1/secret", Retries: 3, RetryBackoff: "1ms"},
+		{Name: "slow", Method: "GET", PathPrefix: "/v1/slow", Timeout: "50ms", Retries: 1, RetryBackoff: "1ms"},
+	}}
+	httpClient := attackClient(client, &AttackConfig{testPolicies: tm.requestPolicies()})
+
+	write := policyTarget("write", func(*api.Client) vegeta.Target {
+		return vegeta.Target{Method: "POST", URL: srv.URL + "/v1/secret/data/foo", Body: []byte(`{"data":{}}`)}
+	})(client)
+	req, err := write.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get(retriesHeader) != "2" {
+		t.Fatalf("expected the request to succeed with its body after 2 retries, got %v %v", resp.Status, resp.Header)
+	}
+
+	slow := policyTarget("slow", func(*api.Client) vegeta.Target {
+		return vegeta.Target{Method: "GET", URL: srv.URL + "/v1/slow"}
+	})(client)
+	req, err = slow.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = httpClient.Do(req)
+	if err == nil || !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Fatalf("expected the request to time out, got %v", err)
+	}
+	failed := &vegeta.Result{Method: "GET", URL: slow.URL, Timestamp: time.Now(), Latency: 100 * time.Millisecond, Error: err.Error()}
+	if got := resultRetries(failed); got != 1 {
+		t.Fatalf("expected the failed request to have been retried once, got %d from %q", got, err)
+	}
+
+	rpt := newReporter(tm, client)
+	rpt.Add(&vegeta.Result{Method: "POST", URL: write.URL, Code: 204, Timestamp: time.Now(), Latency: 10 * time.Millisecond, Headers: resp.Header})
+	rpt.Add(&vegeta.Result{Method: "POST", URL: write.URL, Code: 204, Timestamp: time.Now(), Latency: time.Millisecond})
+	rpt.Add(failed)
+	rpt.Close()
+	if stats := rpt.retries["write"]; stats == nil || *stats != (RetryStats{Retried: 1, Retries: 2, Recovered: 1}) {
+		t.Fatalf("expected one recovered request retried twice, got %+v", stats)
+	}
+	if stats := rpt.retries["slow"]; stats == nil || *stats != (RetryStats{Retried: 1, Retries: 1}) {
+		t.Fatalf("expected one failed request retried once, got %+v", stats)
+	}
+
+	var b bytes.Buffer
+	if err := rpt.ReportTerse(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "recovered") {
+		t.Errorf("expected a retries table in the terse report:\n%s", b.String())
+	}
+	merged := MergeReports([]*Reporter{rpt, rpt})
+	if got := merged[0].retries["write"]; got.Retried != 2 || got.Retries != 4 || got.Recovered != 2 {
+		t.Fatalf("expected the retries of both reports to be merged, got %+v", got)
+	}
+}
@@ -0,0 +1,352 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// resourceScrapeTimeout bounds each scrape, so a stuck exporter doesn't
+// hold up the samples after it
+const resourceScrapeTimeout = 5 * time.Second
+
+// ResourceUsage is the resource usage of the target sampled every interval
+// of a run from a Prometheus metrics endpoint, such as node_exporter,
+// cAdvisor or the target's own runtime metrics
+type ResourceUsage struct {
+	URL      string           `json:"url"`
+	Interval time.Duration    `json:"interval"`
+	Samples  []ResourceSample `json:"samples"`
+}
+
+// ResourceSample is the resource usage during the interval ending at End.
+// Usage which the endpoint doesn't expose is left zero.
+type ResourceSample struct {
+	End time.Time `json:"end"`
+
+	// CPUPercent is the CPU time used per second of the interval, so a
+	// process busy on two cores uses 200%
+	CPUPercent float64 `json:"cpu_percent,omitempty"`
+
+	// RSSBytes is the resident memory at the end of the interval
+	RSSBytes uint64 `json:"rss_bytes,omitempty"`
+
+	// GCPause is the mean garbage collection pause during the interval
+	GCPause time.Duration `json:"gc_pause,omitempty"`
+}
+
+// resourceScrape is the usage read by a scrape. The CPU and garbage
+// collection counters are cumulative, the usage of an interval being the
+// change between scrapes.
+type resourceScrape struct {
+	time       time.Time
+	cpuSeconds float64
+	gcSeconds  float64
+	gcCount    uint64
+	rssBytes   uint64
+}
+
+// ResourceMonitor scrapes a Prometheus metrics endpoint every interval of
+// a run, recording the CPU, memory and garbage collection of the target.
+// Process metrics, as exported by the target itself, are preferred over
+// container metrics, which are preferred over those of the whole node.
+type ResourceMonitor struct {
+	url      string
+	interval time.Duration
+	client   *http.Client
+	logger   hclog.Logger
+
+	l       sync.Mutex
+	samples []ResourceSample
+}
+
+func NewResourceMonitor(url string, interval time.Duration, logger hclog.Logger) *ResourceMonitor {
+	return &ResourceMonitor{
+		url:      url,
+		interval: interval,
+		client:   &http.Client{Timeout: resourceScrapeTimeout},
+		logger:   logger,
+	}
+}
+
+// Run scrapes the endpoint at the start of the run and then every interval
+// until stop is closed, recording a sample for every interval. Failed
+// scrapes are logged, and the following interval is measured from the last
+// successful scrape.
+func (m *ResourceMonitor) Run(stop <-chan struct{}) {
+	prev, err := m.scrape()
+	if err != nil {
+		m.logger.Warn("error scraping resource metrics", "url", m.url, "error", hclog.Fmt("%v", err))
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		cur, err := m.scrape()
+		if err != nil {
+			m.logger.Warn("error scraping resource metrics", "url", m.url, "error", hclog.Fmt("%v", err))
+			continue
+		}
+		if prev != nil {
+			m.l.Lock()
+			m.samples = append(m.samples, resourceSample(prev, cur))
+			m.l.Unlock()
+		}
+		prev = cur
+	}
+}
+
+// Usage returns the samples recorded so far
+func (m *ResourceMonitor) Usage() *ResourceUsage {
+	m.l.Lock()
+	defer m.l.Unlock()
+	return &ResourceUsage{
+		URL:      m.url,
+		Interval: m.interval,
+		Samples:  append([]ResourceSample(nil), m.samples...),
+	}
+}
+
+func (m *ResourceMonitor) scrape() (*resourceScrape, error) {
+	resp, err := m.client.Get(m.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing metrics: %v", err)
+	}
+	s := parseResourceMetrics(families)
+	s.time = time.Now()
+	return s, nil
+}
+
+func resourceSample(prev, cur *resourceScrape) ResourceSample {
+	sample := ResourceSample{End: cur.time, RSSBytes: cur.rssBytes}
+	if elapsed := cur.time.Sub(prev.time).Seconds(); elapsed > 0 && cur.cpuSeconds >= prev.cpuSeconds {
+		sample.CPUPercent = (cur.cpuSeconds - prev.cpuSeconds) / elapsed * 100
+	}
+	if cur.gcCount > prev.gcCount && cur.gcSeconds >= prev.gcSeconds {
+		pause := (cur.gcSeconds - prev.gcSeconds) / float64(cur.gcCount-prev.gcCount)
+		sample.GCPause = time.Duration(pause * float64(time.Second))
+	}
+	return sample
+}
+
+// parseResourceMetrics reads the CPU and memory used from the process,
+// container or node metrics, whichever are found first, and the garbage
+// collection pauses of the Go runtime
+func parseResourceMetrics(families map[string]*dto.MetricFamily) *resourceScrape {
+	s := &resourceScrape{}
+
+	// Containers are told apart from the cgroups holding them by their name
+	container := func(labels map[string]string) bool {
+		return labels["name"] != "" || labels["container"] != ""
+	}
+	nodeBusy := func(labels map[string]string) bool {
+		return labels["mode"] != "idle" && labels["mode"] != "iowait"
+	}
+	if v, ok := sumMetric(families, "process_cpu_seconds_total", nil); ok {
+		s.cpuSeconds = v
+	} else if v, ok := sumMetric(families, "container_cpu_usage_seconds_total", container); ok {
+		s.cpuSeconds = v
+	} else if v, ok := sumMetric(families, "node_cpu_seconds_total", nodeBusy); ok {
+		s.cpuSeconds = v
+	}
+
+	if v, ok := sumMetric(families, "process_resident_memory_bytes", nil); ok {
+		s.rssBytes = uint64(v)
+	} else if v, ok := sumMetric(families, "container_memory_rss", container); ok {
+		s.rssBytes = uint64(v)
+	} else if total, ok := sumMetric(families, "node_memory_MemTotal_bytes", nil); ok {
+		if available, ok := sumMetric(families, "node_memory_MemAvailable_bytes", nil); ok && total > available {
+			s.rssBytes = uint64(total - available)
+		}
+	}
+
+	if f, ok := families["go_gc_duration_seconds"]; ok {
+		for _, metric := range f.GetMetric() {
+			if summary := metric.GetSummary(); summary != nil {
+				s.gcSeconds += summary.GetSampleSum()
+				s.gcCount += summary.GetSampleCount()
+			}
+		}
+	}
+	return s
+}
+
+// sumMetric sums the values of the series of a counter or gauge whose
+// labels match
+func sumMetric(families map[string]*dto.MetricFamily, name string, match func(map[string]string) bool) (float64, bool) {
+	f, ok := families[name]
+	if !ok {
+		return 0, false
+	}
+	var sum float64
+	var found bool
+	for _, metric := range f.GetMetric() {
+		if match != nil {
+			labels := make(map[string]string, len(metric.GetLabel()))
+			for _, pair := range metric.GetLabel() {
+				labels[pair.GetName()] = pair.GetValue()
+			}
+			if !match(labels) {
+				continue
+			}
+		}
+		switch {
+		case metric.GetCounter() != nil:
+			sum += metric.GetCounter().GetValue()
+		case metric.GetGauge() != nil:
+			sum += metric.GetGauge().GetValue()
+		case metric.GetUntyped() != nil:
+			sum += metric.GetUntyped().GetValue()
+		default:
+			continue
+		}
+		found = true
+	}
+	return sum, found
+}
+
+// resourceMetric is a kind of resource usage shown in reports
+type resourceMetric struct {
+	name   string
+	value  func(s ResourceSample) float64
+	format func(v float64) string
+}
+
+var resourceMetrics = []resourceMetric{
+	{"cpu", func(s ResourceSample) float64 { return s.CPUPercent }, func(v float64) string { return fmt.Sprintf("%.1f%%", v) }},
+	{"rss", func(s ResourceSample) float64 { return float64(s.RSSBytes) }, func(v float64) string { return fmt.Sprintf("%.1fMB", v/1e6) }},
+	{"gc pause", func(s ResourceSample) float64 { return float64(s.GCPause) }, func(v float64) string { return time.Duration(v).String() }},
+}
+
+// SetResources records the resource usage of the target during the run.
+// Only the samples falling within the time series of the report are kept,
+// paired with the point of the series they overlap, so reports of
+// different phases each hold the usage of their own phase.
+func (r *Reporter) SetResources(usage *ResourceUsage) {
+	if usage == nil {
+		return
+	}
+	r.resources = &ResourceUsage{URL: usage.URL, Interval: usage.Interval}
+	for _, sample := range usage.Samples {
+		if _, ok := r.resourcePoint(sample, usage.Interval); ok {
+			r.resources.Samples = append(r.resources.Samples, sample)
+		}
+	}
+}
+
+// resourcePoint returns the point of the total time series which the
+// middle of a sample's interval falls in
+func (r *Reporter) resourcePoint(sample ResourceSample, interval time.Duration) (TimeseriesPoint, bool) {
+	middle := sample.End.Add(-interval / 2)
+	for _, p := range r.timeseries["total"] {
+		if !middle.Before(p.Start) && middle.Before(p.Start.Add(r.seriesInterval)) {
+			return p, true
+		}
+	}
+	return TimeseriesPoint{}, false
+}
+
+// reportResources writes a table of the minimum, mean and maximum of each
+// kind of resource usage, and its correlation with the 99th percentile
+// latency and the throughput of the run
+func (r *Reporter) reportResources(w io.Writer) {
+	if r.resources == nil || len(r.resources.Samples) == 0 {
+		return
+	}
+
+	points := make([]TimeseriesPoint, len(r.resources.Samples))
+	for i, sample := range r.resources.Samples {
+		points[i], _ = r.resourcePoint(sample, r.resources.Interval)
+	}
+	latencies := make([]float64, len(points))
+	throughputs := make([]float64, len(points))
+	for i, p := range points {
+		latencies[i] = float64(p.P99)
+		throughputs[i] = p.Throughput
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "\nResources: %v, %d samples\n", r.resources.URL, len(r.resources.Samples))
+	fmt.Fprintf(tw, "resource\tmin\tmean\tmax\tcorr. 99%%\tcorr. throughput\t\n")
+	for _, metric := range resourceMetrics {
+		values := make([]float64, len(r.resources.Samples))
+		var exposed bool
+		for i, sample := range r.resources.Samples {
+			values[i] = metric.value(sample)
+			exposed = exposed || values[i] != 0
+		}
+		if !exposed {
+			continue
+		}
+
+		minimum, maximum, sum := math.Inf(1), math.Inf(-1), 0.0
+		for _, v := range values {
+			minimum, maximum, sum = math.Min(minimum, v), math.Max(maximum, v), sum+v
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t\n", metric.name, metric.format(minimum), metric.format(sum/float64(len(values))),
+			metric.format(maximum), formatCorrelation(values, latencies), formatCorrelation(values, throughputs))
+	}
+	tw.Flush()
+}
+
+// formatCorrelation formats the Pearson correlation of two series, or -
+// when there are too few samples or either series is constant
+func formatCorrelation(x, y []float64) string {
+	c, ok := correlation(x, y)
+	if !ok {
+		return "-"
+	}
+	return fmt.Sprintf("%+.2f", c)
+}
+
+func correlation(x, y []float64) (float64, bool) {
+	if len(x) != len(y) || len(x) < 3 {
+		return 0, false
+	}
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(len(x))
+	meanY /= float64(len(y))
+
+	var cov, varX, varY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0, false
+	}
+	return cov / math.Sqrt(varX*varY), true
+}
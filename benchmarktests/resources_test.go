@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/common/expfmt"
+)
+
+func parseTestResourceMetrics(t *testing.T, text string) *resourceScrape {
+	t.Helper()
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parseResourceMetrics(families)
+}
+
+func TestParseResourceMetrics(t *testing.T) {
+	process := parseTestResourceMetrics(t, `# TYPE process_cpu_seconds_total counter
+process_cpu_seconds_total 12.5
+# TYPE process_resident_memory_bytes gauge
+process_resident_memory_bytes 1e+08
+# TYPE node_cpu_seconds_total counter
+node_cpu_seconds_total{cpu="0",mode="user"} 100
+# TYPE go_gc_duration_seconds summary
+go_gc_duration_seconds{quantile="0.5"} 0.0001
+go_gc_duration_seconds_sum 0.5
+go_gc_duration_seconds_count 1000
+`)
+	if process.cpuSeconds != 12.5 || process.rssBytes != 1e8 || process.gcSeconds != 0.5 || process.gcCount != 1000 {
+		t.Fatalf("unexpected process usage: %+v", process)
+	}
+
+	node := parseTestResourceMetrics(t, `# TYPE node_cpu_seconds_total counter
+node_cpu_seconds_total{cpu="0",mode="idle"} 1000
+node_cpu_seconds_total{cpu="0",mode="iowait"} 10
+node_cpu_seconds_total{cpu="0",mode="system"} 20
+node_cpu_seconds_total{cpu="1",mode="user"} 30
+# TYPE node_memory_MemTotal_bytes gauge
+node_memory_MemTotal_bytes 8e+09
+# TYPE node_memory_MemAvailable_bytes gauge
+node_memory_MemAvailable_bytes 6e+09
+`)
+	if node.cpuSeconds != 50 || node.rssBytes != 2e9 {
+		t.Fatalf("unexpected node usage: %+v", node)
+	}
+
+	container := parseTestResourceMetrics(t, `# TYPE container_cpu_usage_seconds_total counter
+container_cpu_usage_seconds_total{id="/"} 500
+container_cpu_usage_seconds_total{id="/docker/abc",name="openbao"} 40
+# TYPE container_memory_rss gauge
+container_memory_rss{id="/"} 4e+09
+container_memory_rss{id="/docker/abc",name="openbao"} 3e+08
+`)
+	if container.cpuSeconds != 40 || container.rssBytes != 3e8 {
+		t.Fatalf("unexpected container usage: %+v", container)
+	}
+}
+
+func TestResourceSample(t *testing.T) {
+	start := time.Now()
+	prev := &resourceScrape{time: start, cpuSeconds: 10, gcSeconds: 1, gcCount: 100}
+	cur := &resourceScrape{time: start.Add(10 * time.Second), cpuSeconds: 25, gcSeconds: 1.01, gcCount: 110, rssBytes: 42}
+	sample := resourceSample(prev, cur)
+	if math.Abs(sample.CPUPercent-150) > 1e-9 || sample.RSSBytes != 42 || sample.GCPause != time.Millisecond {
+		t.Fatalf("unexpected sample: %+v", sample)
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	if c, ok := correlation([]float64{1, 2, 3, 4}, []float64{2, 4, 6, 8}); !ok || math.Abs(c-1) > 1e-9 {
+		t.Fatalf("expected correlation 1, got %v (%v)", c, ok)
+	}
+	if c, ok := correlation([]float64{1, 2, 3, 4}, []float64{8, 6, 4, 2}); !ok || math.Abs(c+1) > 1e-9 {
+		t.Fatalf("expected correlation -1, got %v (%v)", c, ok)
+	}
+	if _, ok := correlation([]float64{1, 1, 1}, []float64{1, 2, 3}); ok {
+		t.Fatal("expected no correlation of a constant series")
+	}
+	if _, ok := correlation([]float64{1, 2}, []float64{1, 2}); ok {
+		t.Fatal("expected no correlation of two samples")
+	}
+}
+
+func TestReportResources(t *testing.T) {
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rpt := newReporter(&TargetMulti{}, nil)
+	rpt.seriesInterval = 10 * time.Second
+	rpt.timeseries = map[string][]TimeseriesPoint{"total": nil}
+	usage := &ResourceUsage{URL: "http://node:9100/metrics", Interval: 10 * time.Second}
+	for i := 0; i < 4; i++ {
+		start := began.Add(time.Duration(i) * 10 * time.Second)
+		rpt.timeseries["total"] = append(rpt.timeseries["total"], TimeseriesPoint{
+			Start:      start,
+			Throughput: 100,
+			P99:        time.Duration(i+1) * time.Millisecond,
+		})
+		usage.Samples = append(usage.Samples, ResourceSample{
+			End:        start.Add(10*time.Second + 100*time.Millisecond),
+			CPUPercent: float64(20 * (i + 1)),
+			RSSBytes:   1e8,
+		})
+	}
+	// Samples outside the time series belong to another phase
+	usage.Samples = append(usage.Samples, ResourceSample{End: began.Add(time.Minute), CPUPercent: 500})
+
+	rpt.SetResources(usage)
+	if len(rpt.resources.Samples) != 4 {
+		t.Fatalf("expected 4 samples in the report, got %d", len(rpt.resources.Samples))
+	}
+
+	var b bytes.Buffer
+	rpt.reportResources(&b)
+	out := b.String()
+	for _, want := range []string{"Resources: http://node:9100/metrics, 4 samples", "cpu", "20.0%", "80.0%", "+1.00", "100.0MB"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in report:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "gc pause") {
+		t.Errorf("expected usage not exposed to be left out:\n%s", out)
+	}
+}
+
+func TestResourceMonitor(t *testing.T) {
+	var scrapes atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := scrapes.Add(1)
+		fmt.Fprintf(w, "# TYPE process_cpu_seconds_total counter\nprocess_cpu_seconds_total %d\n", n)
+		fmt.Fprintf(w, "# TYPE process_resident_memory_bytes gauge\nprocess_resident_memory_bytes %d\n", n*1000)
+	}))
+	defer srv.Close()
+
+	m := NewResourceMonitor(srv.URL, 20*time.Millisecond, hclog.NewNullLogger())
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Run(stop)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.Usage().Samples) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected resource samples to be recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	<-done
+
+	usage := m.Usage()
+	if usage.URL != srv.URL || usage.Interval != 20*time.Millisecond {
+		t.Fatalf("unexpected usage: %+v", usage)
+	}
+	for _, sample := range usage.Samples {
+		if sample.CPUPercent <= 0 || sample.RSSBytes == 0 {
+			t.Fatalf("unexpected sample: %+v", sample)
+		}
+	}
+}
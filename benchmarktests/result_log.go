@@ -0,0 +1,169 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// ResultLogEntry is a single request as written to the result log
+type ResultLogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Target    string    `json:"target_addr"`
+	Node      string    `json:"node,omitempty"`
+	Phase     string    `json:"phase,omitempty"`
+	Test      string    `json:"test"`
+	Method    string    `json:"method"`
+	URL       string    `json:"url"`
+	Code      uint16    `json:"code"`
+	LatencyMS float64   `json:"latency_ms"`
+	BytesIn   uint64    `json:"bytes_in"`
+	BytesOut  uint64    `json:"bytes_out"`
+	Error     string    `json:"error,omitempty"`
+	Retries   uint64    `json:"retries,omitempty"`
+}
+
+// ResultLog streams every result as newline delimited JSON, for analysis
+// of individual requests after the run. When writing to a file, the file
+// is rotated once it grows past a maximum size: the current file is
+// renamed with a .1 suffix, older files are shifted up by one and the
+// oldest is removed. It is safe to share between concurrent attacks.
+type ResultLog struct {
+	l sync.Mutex
+
+	path     string
+	maxBytes int64
+	maxFiles int
+
+	f       *os.File
+	w       *bufio.Writer
+	written int64
+	err     error
+}
+
+// NewResultLog opens a result log writing to path, or to stdout when path
+// is "-". A maxBytes of zero never rotates the file; otherwise at most
+// maxFiles rotated files are kept.
+func NewResultLog(path string, maxBytes int64, maxFiles int) (*ResultLog, error) {
+	rl := &ResultLog{path: path, maxBytes: maxBytes, maxFiles: maxFiles}
+	if path == "-" {
+		rl.w = bufio.NewWriter(os.Stdout)
+		return rl, nil
+	}
+	if err := rl.open(); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+func (rl *ResultLog) open() error {
+	f, err := os.Create(rl.path)
+	if err != nil {
+		return fmt.Errorf("error creating result log: %v", err)
+	}
+	rl.f = f
+	rl.w = bufio.NewWriter(f)
+	rl.written = 0
+	return nil
+}
+
+// add writes a result of the named test. Only the first error is kept; it
+// is returned by Err.
+func (rl *ResultLog) add(rpt *Reporter, name string, result *vegeta.Result) {
+	addr, _ := rpt.splitURL(result.URL)
+	line, err := json.Marshal(ResultLogEntry{
+		Timestamp: result.Timestamp,
+		Target:    rpt.clientAddr,
+		Node:      rpt.node(addr, result),
+		Phase:     rpt.phase,
+		Test:      name,
+		Method:    result.Method,
+		URL:       result.URL,
+		Code:      result.Code,
+		LatencyMS: float64(result.Latency) / float64(time.Millisecond),
+		BytesIn:   result.BytesIn,
+		BytesOut:  result.BytesOut,
+		Error:     result.Error,
+		Retries:   resultRetries(result),
+	})
+	line = append(line, '\n')
+
+	rl.l.Lock()
+	defer rl.l.Unlock()
+	if err == nil && rl.f != nil && rl.maxBytes > 0 && rl.written > 0 && rl.written+int64(len(line)) > rl.maxBytes {
+		err = rl.rotate()
+	}
+	if err == nil {
+		var n int
+		n, err = rl.w.Write(line)
+		rl.written += int64(n)
+	}
+	if err != nil && rl.err == nil {
+		rl.err = fmt.Errorf("error writing result log: %v", err)
+	}
+}
+
+// rotate closes the current file, shifts it and the previously rotated
+// files up by one and starts a new file
+func (rl *ResultLog) rotate() error {
+	if err := rl.closeFile(); err != nil {
+		return err
+	}
+
+	for i := rl.maxFiles; i > 0; i-- {
+		from := rl.path
+		if i > 1 {
+			from = fmt.Sprintf("%s.%d", rl.path, i-1)
+		}
+		to := fmt.Sprintf("%s.%d", rl.path, i)
+		if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if rl.maxFiles <= 0 {
+		if err := os.Remove(rl.path); err != nil {
+			return err
+		}
+	}
+	return rl.open()
+}
+
+func (rl *ResultLog) closeFile() error {
+	if err := rl.w.Flush(); err != nil {
+		return err
+	}
+	return rl.f.Close()
+}
+
+// Close flushes any buffered results and closes the file
+func (rl *ResultLog) Close() error {
+	rl.l.Lock()
+	defer rl.l.Unlock()
+
+	var err error
+	if rl.f != nil {
+		err = rl.closeFile()
+		rl.f = nil
+	} else {
+		err = rl.w.Flush()
+	}
+	if err != nil && rl.err == nil {
+		rl.err = fmt.Errorf("error writing result log: %v", err)
+	}
+	return err
+}
+
+// Err returns the first error encountered while writing the result log
+func (rl *ResultLog) Err() error {
+	rl.l.Lock()
+	defer rl.l.Unlock()
+	return rl.err
+}
@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func readResultLog(t *testing.T, path string) []ResultLogEntry {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var entries []ResultLogEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry ResultLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid result log line %q: %v", scanner.Text(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestResultLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.ndjson")
+	rl, err := NewResultLog(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.resultLog = rl
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 200, Timestamp: time.Now(), Latency: 1500 * time.Microsecond, BytesIn: 42})
+	rpt.Add(&vegeta.Result{Method: "POST", URL: "N/A/v1/other", Code: 500, Timestamp: time.Now(), Error: "500 Internal Server Error"})
+	rpt.Close()
+	if err := rl.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := readResultLog(t, path)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 results to be logged, got: %v", len(entries))
+	}
+	if entries[0].Test != "read" || entries[0].Code != 200 || entries[0].LatencyMS != 1.5 || entries[0].BytesIn != 42 {
+		t.Fatalf("unexpected entry: %+v", entries[0])
+	}
+	if entries[1].Test != "total" || entries[1].Error != "500 Internal Server Error" {
+		t.Fatalf("unexpected entry: %+v", entries[1])
+	}
+}
+
+func TestResultLog_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.ndjson")
+	// Small enough that every result starts a new file
+	rl, err := NewResultLog(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rpt := newReporter(&TargetMulti{}, nil)
+	for i := 0; i < 4; i++ {
+		rl.add(rpt, "total", &vegeta.Result{Code: uint16(200 + i), Timestamp: time.Now()})
+	}
+	if err := rl.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rl.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	for suffix, code := range map[string]uint16{"": 203, ".1": 202, ".2": 201} {
+		entries := readResultLog(t, path+suffix)
+		if len(entries) != 1 || entries[0].Code != code {
+			t.Fatalf("expected %v to hold the result with code %v, got: %+v", path+suffix, code, entries)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected only 2 rotated files to be kept, got: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// ResultSchemaVersion is the version of the layout of JSON reports. It is
+// only increased when a change would break existing readers, such as a
+// field being removed or changing type; new fields are added without a new
+// version. Reports written before versioning have no schema_version and
+// share the layout of version 1.
+const ResultSchemaVersion = 1
+
+// resultFieldDocs describes the fields of a JSON report in its schema
+var resultFieldDocs = map[string]string{
+	"schema_version":                 "Version of the layout of the report, see ResultSchemaVersion.",
+	"target_addr":                    "Address of the server the results were measured against.",
+	"server":                         "Version and configuration of the server.",
+	"labels":                         "Labels of the run, such as its environment.",
+	"transport":                      "Settings of the HTTP transport requests were sent with.",
+	"token_renewal":                  "Renewals of the benchmark's token and logins for a new one during the run.",
+	"token_pool":                     "Number of tokens the requests were spread across, when using a token pool.",
+	"token_type":                     "Type of the tokens of the pool, service or batch.",
+	"phase":                          "Phase of the run the results belong to, when running phases.",
+	"metrics":                        "Results of every test, and of all of them under total.",
+	"warmup_metrics":                 "Results sent during the warmup of each test, excluded from metrics.",
+	"burst_metrics":                  "Results sent during burst windows, also included in metrics.",
+	"throttled":                      "Requests whose sending was delayed by max_in_flight.",
+	"histograms":                     "Full latency distribution of every test.",
+	"coordinated_omission_corrected": "Whether latencies are measured from when each request was scheduled to be sent.",
+	"status_code_histograms":         "Latency distribution of every test by response status code.",
+	"error_groups":                   "Failed requests of every test grouped by status code and error message.",
+	"operation_metrics":              "Results of every test which sends several kinds of request, by operation.",
+	"operation_histograms":           "Latency distribution of every test which sends several kinds of request, by operation.",
+	"address_metrics":                "Results of all tests by the node which served them, when balanced between several addresses or a node_header is set.",
+	"address_histograms":             "Latency distribution of all tests by the node which served them, when balanced between several addresses or a node_header is set.",
+	"namespace_metrics":              "Results of all tests by the namespace they were sent to, when fanned out into namespaces.",
+	"namespace_histograms":           "Latency distribution of all tests by the namespace they were sent to, when fanned out into namespaces.",
+	"cache_histograms":               "Latency distribution of every test by whether responses were a cache hit or miss of the proxy they were sent through.",
+	"redirects":                      "Redirects of every test to another node which were followed, with the distribution of the latency they added.",
+	"connections":                    "Requests of every test sent on new and reused connections, with the distribution of the latency of TLS handshakes.",
+	"retries":                        "Requests of every test which were retried after failing, with the number of retries and the requests which then succeeded.",
+	"validation":                     "Responses of every test with expect rules which were checked against them, those which broke any rule and how often each rule was broken.",
+	"timeseries_interval":            "Length of each point of the time series in nanoseconds.",
+	"timeseries":                     "Results of every test in each interval of the run.",
+	"resources":                      "Resource usage of the server sampled during the run.",
+	"chaos_events":                   "Chaos events run during the results and the time the server took to recover from each.",
+	"search":                         "Capacity found by a throughput search and every step it took, when the results are those of the attack at the capacity.",
+}
+
+// schemaOverrides are the schemas of types which have their own JSON
+// encoding, or whose encoding needs explaining
+var schemaOverrides = map[reflect.Type]map[string]interface{}{
+	reflect.TypeOf(time.Time{}): {"type": "string", "format": "date-time"},
+	reflect.TypeOf(time.Duration(0)): {
+		"type":        "integer",
+		"description": "Duration in nanoseconds.",
+	},
+	reflect.TypeOf(Histogram{}): {"$ref": "#/$defs/Histogram"},
+	reflect.TypeOf(vegeta.Histogram{}): {
+		"type":                 "object",
+		"description":          "Count of requests by the lower bound of their latency bucket in nanoseconds.",
+		"additionalProperties": map[string]interface{}{"type": "integer"},
+	},
+}
+
+// ResultSchema returns the JSON Schema of the reports written in json
+// report mode. Results files hold one report per line, for each target
+// and phase of the run.
+func ResultSchema() map[string]interface{} {
+	b := &schemaBuilder{defs: map[string]interface{}{}}
+	b.defs["Histogram"] = b.object(reflect.TypeOf(jsonHistogram{}))
+
+	root := b.object(reflect.TypeOf(JSONReport{}))
+	properties := root["properties"].(map[string]interface{})
+	for name, doc := range resultFieldDocs {
+		if property, ok := properties[name].(map[string]interface{}); ok {
+			property["description"] = doc
+		}
+	}
+	root["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	root["title"] = "vault-benchmark result"
+	root["description"] = fmt.Sprintf("Report of one target and phase of a run, version %d.", ResultSchemaVersion)
+	root["$defs"] = b.defs
+	return root
+}
+
+// WriteResultSchema writes the JSON Schema of reports, indented so that
+// changes to it are easy to review
+func WriteResultSchema(w io.Writer) error {
+	out, err := json.MarshalIndent(ResultSchema(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding schema: %v", err)
+	}
+	_, err = w.Write(append(out, '\n'))
+	return err
+}
+
+// schemaBuilder derives JSON Schemas from the types of reports. Structs
+// are added to defs once and referenced wherever they are used.
+type schemaBuilder struct {
+	defs map[string]interface{}
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	if s, ok := schemaOverrides[t]; ok {
+		return copySchema(s)
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := b.defs[name]; !ok {
+			// Reserve the name first, for types which refer to themselves
+			b.defs[name] = nil
+			b.defs[name] = b.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/$defs/" + name}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": b.schema(t.Elem())}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": b.schema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// object returns the schema of a struct from its exported fields and their
+// json tags. Fields which are omitted when empty aren't required, and
+// pointers which aren't may be null.
+func (b *schemaBuilder) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		omitempty := strings.Contains(options, "omitempty")
+
+		property := b.schema(field.Type)
+		if field.Type.Kind() == reflect.Pointer && !omitempty {
+			property = map[string]interface{}{"anyOf": []interface{}{property, map[string]interface{}{"type": "null"}}}
+		}
+		properties[name] = property
+		if !omitempty {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+func copySchema(s map[string]interface{}) map[string]interface{} {
+	c := make(map[string]interface{}, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,182 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// TestResultSchema_Stable checks the schema against the one kept in
+// testdata, so changes to the layout of results are seen in review. After
+// a deliberate change, regenerate it with:
+//
+//	go run . schema > benchmarktests/testdata/result_schema.json
+func TestResultSchema_Stable(t *testing.T) {
+	want, err := os.ReadFile("testdata/result_schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	if err := WriteResultSchema(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != string(want) {
+		t.Fatal("result schema changed, regenerate testdata/result_schema.json if the change is intended and backward compatible")
+	}
+}
+
+func TestResultSchema_MatchesReport(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "mixed", Method: "GET", PathPrefix: "/v1/kv", Builder: &KVV2Test{action: "mixed", pathPrefix: "/v1/kv"}},
+	}}
+	rpt := newReporter(tm, nil)
+	began := time.Now()
+	rpt.startWarmup(began, 0)
+	rpt.trackTimeseries(time.Second)
+	rpt.SetServerInfo(&ServerInfo{Version: "2.0.1", Fingerprint: "abc"})
+	rpt.SetLabels(map[string]string{"env": "ci"})
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/kv/data/secret-1", Code: 200, Timestamp: began, Latency: time.Millisecond})
+	rpt.Add(&vegeta.Result{Method: "POST", URL: "N/A/v1/kv/data/secret-1", Code: 500, Timestamp: began, Latency: time.Millisecond, Error: "500 Internal Server Error"})
+	rpt.Close()
+
+	var out bytes.Buffer
+	if err := rpt.ReportJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var report interface{}
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+
+	schema := ResultSchema()
+	// Round trip the schema so it holds the same types as the report
+	encoded, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSchema(decoded, decoded, report, "report"); err != nil {
+		t.Fatal(err)
+	}
+	if version := report.(map[string]interface{})["schema_version"]; version != float64(ResultSchemaVersion) {
+		t.Fatalf("expected schema version %v, got %v", ResultSchemaVersion, version)
+	}
+}
+
+func TestFromReader_SchemaVersion(t *testing.T) {
+	// Reports written before versioning are read as the first version
+	rpts, err := FromReader(strings.NewReader(`{"target_addr":"http://127.0.0.1:8200","metrics":{}}`))
+	if err != nil || len(rpts) != 1 {
+		t.Fatalf("expected an unversioned report to be read, got %v reports: %v", len(rpts), err)
+	}
+
+	_, err = FromReader(strings.NewReader(`{"schema_version":99,"target_addr":"http://127.0.0.1:8200","metrics":{}}`))
+	if err == nil || !strings.Contains(err.Error(), "schema version 99") {
+		t.Fatalf("expected an error reading a newer schema version, got %v", err)
+	}
+}
+
+// checkSchema checks a decoded JSON value against the parts of JSON Schema
+// used by ResultSchema
+func checkSchema(root, schema map[string]interface{}, value interface{}, path string) error {
+	if ref, ok := schema["$ref"].(string); ok {
+		def := root["$defs"].(map[string]interface{})[strings.TrimPrefix(ref, "#/$defs/")]
+		if def == nil {
+			return &schemaError{path, "unknown reference " + ref}
+		}
+		return checkSchema(root, def.(map[string]interface{}), value, path)
+	}
+	if anyOf, ok := schema["anyOf"].([]interface{}); ok {
+		for _, option := range anyOf {
+			if checkSchema(root, option.(map[string]interface{}), value, path) == nil {
+				return nil
+			}
+		}
+		return &schemaError{path, "matches none of anyOf"}
+	}
+
+	if types, ok := schema["type"]; ok {
+		var allowed []interface{}
+		if list, ok := types.([]interface{}); ok {
+			allowed = list
+		} else {
+			allowed = []interface{}{types}
+		}
+		var matched bool
+		for _, typ := range allowed {
+			matched = matched || schemaTypeMatches(typ.(string), value)
+		}
+		if !matched {
+			return &schemaError{path, "has the wrong type"}
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				return &schemaError{path, "is missing " + name.(string)}
+			}
+		}
+		for name, field := range v {
+			var fieldSchema interface{}
+			if properties != nil {
+				fieldSchema = properties[name]
+			} else {
+				fieldSchema = schema["additionalProperties"]
+			}
+			if fieldSchema == nil {
+				return &schemaError{path, "has unknown field " + name}
+			}
+			if err := checkSchema(root, fieldSchema.(map[string]interface{}), field, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := checkSchema(root, schema["items"].(map[string]interface{}), item, path+"[]"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func schemaTypeMatches(typ string, value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return typ == "null"
+	case bool:
+		return typ == "boolean"
+	case string:
+		return typ == "string"
+	case float64:
+		return typ == "number" || (typ == "integer" && v == float64(int64(v)))
+	case []interface{}:
+		return typ == "array"
+	case map[string]interface{}:
+		return typ == "object"
+	}
+	return false
+}
+
+type schemaError struct {
+	path, problem string
+}
+
+func (e *schemaError) Error() string {
+	return e.path + " " + e.problem
+}
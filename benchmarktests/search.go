@@ -0,0 +1,145 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+)
+
+// SearchConfig controls the search for the highest rate at which the
+// target still meets a latency objective
+type SearchConfig struct {
+	MaxP99          time.Duration
+	MinSuccessRatio float64
+	MinRPS          int
+	MaxRPS          int
+	StepDuration    time.Duration
+	Tolerance       int
+}
+
+// SearchStep records the outcome of attacking the target at one rate
+type SearchStep struct {
+	RPS     int           `json:"rps"`
+	P99     time.Duration `json:"p99"`
+	Success float64       `json:"success"`
+	Passed  bool          `json:"passed"`
+}
+
+// SearchResult is the outcome of a throughput search. Capacity is the
+// highest rate which met the objective, or zero if even the lowest rate
+// failed. Report holds the results of the attack at that rate.
+type SearchResult struct {
+	Capacity int
+	Steps    []SearchStep
+	Report   *Reporter
+}
+
+// SearchReport is the outcome of a throughput search included in the JSON
+// report of the attack at the discovered capacity
+type SearchReport struct {
+	Capacity int          `json:"capacity"`
+	Steps    []SearchStep `json:"steps"`
+}
+
+func (s *SearchConfig) passed(rpt *Reporter) (SearchStep, bool) {
+	total := rpt.metrics["total"]
+	step := SearchStep{
+		P99:     total.Latencies.P99,
+		Success: total.Success,
+	}
+	step.Passed = total.Requests > 0 && step.P99 <= s.MaxP99 && step.Success >= s.MinSuccessRatio
+	return step, step.Passed
+}
+
+// Search looks for the maximum sustainable rate by attacking the target for
+// a short interval at a time, bisecting between the highest rate known to
+// meet the objective and the lowest rate known to miss it until the two
+// are within the configured tolerance.
+func Search(tm *TargetMulti, client *api.Client, attackConfig *AttackConfig, search *SearchConfig, logger hclog.Logger) (*SearchResult, error) {
+	if search.MinRPS <= 0 || search.MaxRPS < search.MinRPS {
+		return nil, fmt.Errorf("search rates must satisfy 0 < min_rps <= max_rps")
+	}
+	if attackConfig.Mode == ClosedLoopAttackMode {
+		return nil, fmt.Errorf("throughput search requires the open attack mode")
+	}
+
+	tolerance := search.Tolerance
+	if tolerance <= 0 {
+		tolerance = 1
+	}
+
+	result := &SearchResult{}
+	// The report at the capacity carries every step of the search, however
+	// the search ends
+	defer func() {
+		if result.Report != nil {
+			result.Report.search = &SearchReport{Capacity: result.Capacity, Steps: result.Steps}
+		}
+	}()
+	try := func(rps int) (bool, error) {
+		stepConfig := *attackConfig
+		stepConfig.RPS = rps
+		stepConfig.Duration = search.StepDuration
+
+		logger.Info("searching", "rps", rps, "duration", search.StepDuration.String())
+		rpt, err := Attack(tm, client, &stepConfig)
+		if err != nil {
+			return false, err
+		}
+
+		step, ok := search.passed(rpt)
+		step.RPS = rps
+		result.Steps = append(result.Steps, step)
+		logger.Info("search step complete", "rps", rps, "p99", step.P99.String(), "success", step.Success, "passed", ok)
+		if ok {
+			result.Capacity = rps
+			result.Report = rpt
+		}
+		return ok, nil
+	}
+
+	// Check the bounds first: there is nothing to search if the highest
+	// rate passes or the lowest one fails
+	ok, err := try(search.MaxRPS)
+	if err != nil || ok {
+		return result, err
+	}
+	ok, err = try(search.MinRPS)
+	if err != nil || !ok {
+		return result, err
+	}
+
+	low, high := search.MinRPS, search.MaxRPS
+	for high-low > tolerance {
+		mid := low + (high-low)/2
+		ok, err := try(mid)
+		if err != nil {
+			return result, err
+		}
+		if ok {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+
+	return result, nil
+}
+
+// ReportSteps writes a summary of every step taken during the search
+func (r *SearchResult) ReportSteps(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.StripEscape)
+	fmt.Fprintf(tw, "Capacity: %d rps\n", r.Capacity)
+	fmt.Fprintf(tw, "rps\t99th%%\tsuccessRatio\tpassed\n")
+	for _, step := range r.Steps {
+		fmt.Fprintf(tw, "%d\t%s\t%.2f%%\t%t\n", step.RPS, step.P99, step.Success*100, step.Passed)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,84 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestSearchConfig_Passed(t *testing.T) {
+	search := &SearchConfig{MaxP99: 50 * time.Millisecond, MinSuccessRatio: 0.99}
+
+	rpt := newReporter(&TargetMulti{}, nil)
+	rpt.metrics["total"] = &vegeta.Metrics{Requests: 10, Success: 1}
+	rpt.metrics["total"].Latencies.P99 = 10 * time.Millisecond
+	if _, ok := search.passed(rpt); !ok {
+		t.Fatal("expected step to pass")
+	}
+
+	rpt.metrics["total"].Latencies.P99 = 60 * time.Millisecond
+	if _, ok := search.passed(rpt); ok {
+		t.Fatal("expected step to fail on latency")
+	}
+
+	rpt.metrics["total"].Latencies.P99 = 10 * time.Millisecond
+	rpt.metrics["total"].Success = 0.9
+	if _, ok := search.passed(rpt); ok {
+		t.Fatal("expected step to fail on success ratio")
+	}
+}
+
+func TestSearch_ReportsSteps(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := &TargetMulti{targets: []BenchmarkTarget{{
+		Name:       "health",
+		Method:     "GET",
+		PathPrefix: "/v1/sys/health",
+		Weight:     100,
+		Builder:    &StatusCheck{},
+		Target: func(client *api.Client) vegeta.Target {
+			return vegeta.Target{Method: "GET", URL: client.Address() + "/v1/sys/health"}
+		},
+	}}}
+	search := &SearchConfig{MaxP99: time.Second, MinSuccessRatio: 0.99, MinRPS: 10, MaxRPS: 50, StepDuration: 200 * time.Millisecond}
+	result, err := Search(tm, client, &AttackConfig{Mode: OpenLoopAttackMode, Workers: 1}, search, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Capacity != 50 || len(result.Steps) != 1 || result.Report == nil {
+		t.Fatalf("expected the highest rate to pass, got: %+v", result)
+	}
+
+	// The steps are included in the JSON report of the attack at the capacity
+	var out bytes.Buffer
+	if err := result.Report.ReportJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	reports, err := FromReader(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := reports[0].search
+	if got == nil || got.Capacity != 50 || len(got.Steps) != 1 || got.Steps[0].RPS != 50 || !got.Steps[0].Passed {
+		t.Fatalf("unexpected search in report: %+v", got)
+	}
+}
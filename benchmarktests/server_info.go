@@ -0,0 +1,139 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/openbao/openbao/api/v2"
+)
+
+// ServerInfo describes the server a report's results were measured
+// against, so results files remain self-describing long after the run
+type ServerInfo struct {
+	Version     string `json:"version,omitempty"`
+	BuildDate   string `json:"build_date,omitempty"`
+	ClusterName string `json:"cluster_name,omitempty"`
+	ClusterID   string `json:"cluster_id,omitempty"`
+	StorageType string `json:"storage_type,omitempty"`
+	SealType    string `json:"seal_type,omitempty"`
+	Standby     bool   `json:"standby,omitempty"`
+
+	// Host details are only available when the token may read
+	// sys/host-info
+	Hostname    string `json:"hostname,omitempty"`
+	OS          string `json:"os,omitempty"`
+	CPUs        int    `json:"cpus,omitempty"`
+	MemoryBytes uint64 `json:"memory_bytes,omitempty"`
+
+	// Fingerprint identifies the version and configuration of the server,
+	// and is the same for runs against identically configured servers
+	Fingerprint string `json:"fingerprint"`
+}
+
+// CollectServerInfo queries sys/health, sys/seal-status and, if permitted,
+// sys/host-info of the server the client is configured for
+func CollectServerInfo(client *api.Client) (*ServerInfo, error) {
+	health, err := client.Sys().Health()
+	if err != nil {
+		return nil, fmt.Errorf("error reading health: %v", err)
+	}
+	info := &ServerInfo{
+		Version:     health.Version,
+		ClusterName: health.ClusterName,
+		ClusterID:   health.ClusterID,
+		Standby:     health.Standby,
+	}
+
+	sealStatus, err := client.Sys().SealStatus()
+	if err != nil {
+		return nil, fmt.Errorf("error reading seal status: %v", err)
+	}
+	info.BuildDate = sealStatus.BuildDate
+	info.StorageType = sealStatus.StorageType
+	info.SealType = sealStatus.Type
+
+	// Reading the host info requires sudo, so is skipped when the token
+	// isn't allowed to
+	if hostInfo, err := client.Logical().Read("sys/host-info"); err == nil && hostInfo != nil {
+		info.setHostInfo(hostInfo.Data)
+	}
+
+	info.Fingerprint = info.fingerprint()
+	return info, nil
+}
+
+func (info *ServerInfo) setHostInfo(data map[string]interface{}) {
+	if host, ok := data["host"].(map[string]interface{}); ok {
+		info.Hostname, _ = host["hostname"].(string)
+		platform, _ := host["platform"].(string)
+		platformVersion, _ := host["platformVersion"].(string)
+		hostOS, _ := host["os"].(string)
+		info.OS = strings.Join(strings.Fields(strings.Join([]string{hostOS, platform, platformVersion}, " ")), " ")
+	}
+	if cpus, ok := data["cpu"].([]interface{}); ok {
+		for _, cpu := range cpus {
+			if cpu, ok := cpu.(map[string]interface{}); ok {
+				cores, _ := hostInfoNumber(cpu["cores"])
+				info.CPUs += int(cores)
+			}
+		}
+	}
+	if memory, ok := data["memory"].(map[string]interface{}); ok {
+		info.MemoryBytes, _ = hostInfoNumber(memory["total"])
+	}
+}
+
+// hostInfoNumber returns a number from the host info, which is decoded
+// either as a json.Number or a float64
+func hostInfoNumber(value interface{}) (uint64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		n, err := strconv.ParseUint(v.String(), 10, 64)
+		return n, err == nil
+	case float64:
+		return uint64(v), true
+	}
+	return 0, false
+}
+
+// fingerprint hashes the version and configuration of the server. The
+// cluster's name and ID and the hostname are left out, so that a
+// redeployed server with the same configuration has the same fingerprint.
+func (info *ServerInfo) fingerprint() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "version=%s\nstorage=%s\nseal=%s\nos=%s\ncpus=%d\nmemory=%d\n",
+		info.Version, info.StorageType, info.SealType, info.OS, info.CPUs, info.MemoryBytes)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// report writes a one line summary of the server
+func (info *ServerInfo) report(w io.Writer) {
+	fields := []string{"version " + info.Version}
+	if info.StorageType != "" {
+		fields = append(fields, "storage "+info.StorageType)
+	}
+	if info.SealType != "" {
+		fields = append(fields, "seal "+info.SealType)
+	}
+	if info.ClusterName != "" {
+		fields = append(fields, "cluster "+info.ClusterName)
+	}
+	if info.CPUs > 0 {
+		fields = append(fields, fmt.Sprintf("%d cpus", info.CPUs))
+	}
+	fields = append(fields, "fingerprint "+info.Fingerprint)
+	fmt.Fprintf(w, "Server: %s\n", strings.Join(fields, ", "))
+}
+
+// SetServerInfo records the server the results were measured against
+func (r *Reporter) SetServerInfo(info *ServerInfo) {
+	r.server = info
+}
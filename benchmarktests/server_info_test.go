@@ -0,0 +1,116 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openbao/openbao/api/v2"
+)
+
+func newServerInfoServer(t *testing.T, hostname string, allowHostInfo bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/v1/sys/health":
+			w.Write([]byte(`{"initialized":true,"sealed":false,"standby":false,"version":"2.0.1","cluster_name":"bench","cluster_id":"1234"}`))
+		case "/v1/sys/seal-status":
+			w.Write([]byte(`{"type":"shamir","initialized":true,"sealed":false,"t":1,"n":1,"version":"2.0.1","build_date":"2024-09-01T00:00:00Z","storage_type":"raft"}`))
+		case "/v1/sys/host-info":
+			if !allowHostInfo {
+				w.WriteHeader(http.StatusForbidden)
+				w.Write([]byte(`{"errors":["permission denied"]}`))
+				return
+			}
+			w.Write([]byte(`{"data":{"host":{"hostname":"` + hostname + `","os":"linux","platform":"debian","platformVersion":"12"},"cpu":[{"cores":4},{"cores":4}],"memory":{"total":8589934592}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func collectTestServerInfo(t *testing.T, srv *httptest.Server) *ServerInfo {
+	t.Helper()
+	config := api.DefaultConfig()
+	config.Address = srv.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := CollectServerInfo(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info
+}
+
+func TestCollectServerInfo(t *testing.T) {
+	info := collectTestServerInfo(t, newServerInfoServer(t, "node-1", true))
+	if info.Version != "2.0.1" || info.ClusterName != "bench" || info.StorageType != "raft" || info.SealType != "shamir" {
+		t.Fatalf("unexpected server info: %+v", info)
+	}
+	if info.Hostname != "node-1" || info.OS != "linux debian 12" || info.CPUs != 8 || info.MemoryBytes != 8589934592 {
+		t.Fatalf("unexpected host info: %+v", info)
+	}
+
+	// Identically configured servers share a fingerprint
+	other := collectTestServerInfo(t, newServerInfoServer(t, "node-2", true))
+	if other.Fingerprint != info.Fingerprint {
+		t.Fatalf("expected fingerprint to ignore the hostname, got %v and %v", info.Fingerprint, other.Fingerprint)
+	}
+
+	// Host info is skipped when the token isn't allowed to read it
+	denied := collectTestServerInfo(t, newServerInfoServer(t, "node-1", false))
+	if denied.Version != "2.0.1" || denied.Hostname != "" || denied.CPUs != 0 {
+		t.Fatalf("unexpected server info without host info: %+v", denied)
+	}
+	if denied.Fingerprint == info.Fingerprint {
+		t.Fatalf("expected fingerprint to change with the host info")
+	}
+}
+
+func TestReporter_ServerInfo(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "read", Method: "GET", PathPrefix: "/v1/secret/data"},
+	}}
+	rpt := newReporter(tm, nil)
+	rpt.SetServerInfo(collectTestServerInfo(t, newServerInfoServer(t, "node-1", true)))
+	rpt.Close()
+
+	var buf bytes.Buffer
+	if err := rpt.ReportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rpts, err := FromReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpts[0].server == nil || rpts[0].server.Fingerprint != rpt.server.Fingerprint || rpts[0].server.StorageType != "raft" {
+		t.Fatalf("expected server info to be read back, got: %+v", rpts[0].server)
+	}
+
+	buf.Reset()
+	if err := rpt.ReportVerbose(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Server: version 2.0.1, storage raft, seal shamir, cluster bench, 8 cpus, fingerprint "+rpt.server.Fingerprint) {
+		t.Fatalf("expected server line in report, got:\n%v", buf.String())
+	}
+
+	// Terse reports leave the server out
+	buf.Reset()
+	if err := rpt.ReportTerse(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "Server:") {
+		t.Fatalf("expected no server line in terse report, got:\n%v", buf.String())
+	}
+}
@@ -0,0 +1,433 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
+	"github.com/openbao/openbao/api/v2"
+)
+
+// SetupStateVersion is the version of the layout of setup state files.
+// Tests aren't attacked from the state of another version.
+const SetupStateVersion = 1
+
+// SetupState is the setup of the tests of a run saved to disk by a setup
+// only run, so expensive setup, such as seeding millions of secrets, is
+// reused by any number of attack only runs. The responses of the server to
+// the setup requests of each test are kept, so attack only runs set the
+// tests up again against a stand-in replaying them, which leaves each test
+// as it was without sending the server a request.
+type SetupState struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+
+	// Addr is the address of the server the tests were set up on
+	Addr string `json:"addr"`
+
+	// Tests are the setup of each test, in the order they were set up
+	Tests []*TestSetupState `json:"tests"`
+}
+
+// TestSetupState is the setup of a test
+type TestSetupState struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+
+	// MountName is the name the test was set up with, which is generated
+	// with random mounts. Tests sharing the mount of another have none.
+	MountName string `json:"mount_name,omitempty"`
+
+	// Mounts are the secrets engines and auth methods the test enabled
+	Mounts []string `json:"mounts,omitempty"`
+
+	// Digest is of the setup requests the test sent, which it must send
+	// the same way again for its setup to be replayed
+	Digest string `json:"digest"`
+
+	// Responses are the responses to the setup requests, in order
+	Responses []*SetupResponse `json:"responses,omitempty"`
+}
+
+// SetupResponse is the response of the server to a setup request
+type SetupResponse struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	Body   string `json:"body,omitempty"`
+}
+
+// SetupOnly sets the tests up on the server of the client and returns their
+// state. Requests are sent through a proxy running in this process, which
+// records the responses of the server. With random mounts the names of the
+// mounts are generated here instead of by each test, so they are known to
+// the state. The tests are set up in place and are not cleaned up.
+func SetupOnly(client *api.Client, tests []*BenchmarkTarget, logger *hclog.Logger, config *TopLevelTargetConfig) (*SetupState, error) {
+	state := &SetupState{
+		Version: SetupStateVersion,
+		Created: time.Now(),
+		Addr:    client.Address(),
+	}
+
+	fixed := *config
+	fixed.RandomMounts = false
+	for _, bvTest := range tests {
+		random := bvTest.UsesRandomMount(config.RandomMounts)
+		bvTest.RandomMount = new(bool)
+		if !random || bvTest.SharedMount != "" {
+			continue
+		}
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		bvTest.MountName = id
+	}
+
+	recorder := &setupRecorder{
+		addr:      client.Address(),
+		transport: client.CloneConfig().HttpClient.Transport,
+		digest:    sha256.New(),
+	}
+	srv := httptest.NewServer(recorder)
+	defer srv.Close()
+	recorder.standIn = srv.URL
+
+	setupClient, err := standInClient(srv.URL, client)
+	if err != nil {
+		return nil, err
+	}
+	_, err = buildTargets(setupClient, tests, logger, &fixed, func(bvTest *BenchmarkTarget) {
+		test := recorder.take()
+		test.Name = bvTest.Name
+		test.Type = bvTest.Type
+		if bvTest.SharedMount == "" {
+			test.MountName = bvTest.Name
+			if bvTest.MountName != "" {
+				test.MountName = bvTest.MountName
+			}
+		}
+		state.Tests = append(state.Tests, test)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// LoadSetupState reads the setup state at path
+func LoadSetupState(path string) (*SetupState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading setup state: %v", err)
+	}
+	var state SetupState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error decoding setup state: %v", err)
+	}
+	if state.Version != SetupStateVersion {
+		return nil, fmt.Errorf("setup state is of version %d, expected %d", state.Version, SetupStateVersion)
+	}
+	return &state, nil
+}
+
+// Save writes the setup state to path. The responses of the server may
+// hold secrets, such as the secret IDs of roles, so only the owner may read
+// the file.
+func (s *SetupState) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("error encoding setup state: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("error writing setup state: %v", err)
+	}
+	return nil
+}
+
+// Mounts returns the secrets engines and auth methods the tests enabled
+func (s *SetupState) Mounts() []string {
+	var mounts []string
+	for _, test := range s.Tests {
+		mounts = append(mounts, test.Mounts...)
+	}
+	return mounts
+}
+
+// BuildTargets sets the tests up again against a stand-in replaying the
+// responses of the server to their setup, returning targets for the mounts
+// which were set up by the setup only run. The tests must be those which
+// were set up, and must send the same setup requests again, which tests
+// generating values during their setup, such as keys or passwords, don't.
+func (s *SetupState) BuildTargets(client *api.Client, tests []*BenchmarkTarget, logger *hclog.Logger, config *TopLevelTargetConfig) (*TargetMulti, error) {
+	order := setupOrder(tests)
+	if len(order) != len(s.Tests) {
+		return nil, fmt.Errorf("config has %d tests where %d were set up", len(order), len(s.Tests))
+	}
+	for i, bvTest := range order {
+		test := s.Tests[i]
+		if bvTest.Name != test.Name || bvTest.Type != test.Type {
+			return nil, fmt.Errorf("config has test %v (%v) where %v (%v) was set up", bvTest.Name, bvTest.Type, test.Name, test.Type)
+		}
+		if test.MountName != "" {
+			bvTest.MountName = test.MountName
+		}
+	}
+
+	fixed := *config
+	fixed.RandomMounts = false
+	for _, bvTest := range tests {
+		bvTest.RandomMount = new(bool)
+	}
+
+	replayer := &setupReplayer{addr: s.Addr}
+	srv := httptest.NewServer(replayer)
+	defer srv.Close()
+	replayer.standIn = srv.URL
+
+	setupClient, err := standInClient(srv.URL, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var replayErr error
+	next := 0
+	if len(s.Tests) > 0 {
+		replayer.start(s.Tests[0])
+	}
+	tm, err := buildTargets(setupClient, tests, logger, &fixed, func(bvTest *BenchmarkTarget) {
+		if err := replayer.finish(); err != nil && replayErr == nil {
+			replayErr = err
+		}
+		next++
+		if next < len(s.Tests) {
+			replayer.start(s.Tests[next])
+		}
+	})
+	if err != nil {
+		// The test most likely failed because of requests which weren't
+		// recorded
+		if mismatch := replayer.mismatch(); mismatch != nil {
+			return nil, mismatch
+		}
+		return nil, err
+	}
+	if replayErr != nil {
+		return nil, replayErr
+	}
+	return tm, nil
+}
+
+// setupOrder returns the tests in the order they are set up, which is
+// those sharing the mount of another test last
+func setupOrder(tests []*BenchmarkTarget) []*BenchmarkTarget {
+	order := make([]*BenchmarkTarget, 0, len(tests))
+	for _, bvTest := range tests {
+		if bvTest.SharedMount == "" {
+			order = append(order, bvTest)
+		}
+	}
+	for _, bvTest := range tests {
+		if bvTest.SharedMount != "" {
+			order = append(order, bvTest)
+		}
+	}
+	return order
+}
+
+// standInClient returns a client sending the requests of client to a
+// stand-in for its server at addr
+func standInClient(addr string, client *api.Client) (*api.Client, error) {
+	cfg := api.DefaultConfig()
+	cfg.Address = addr
+	standIn, err := api.NewClient(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating setup client: %v", err)
+	}
+	standIn.SetHeaders(client.Headers())
+	standIn.SetToken(client.Token())
+	return standIn, nil
+}
+
+// writeSetupDigest adds a setup request to the digest of the requests of a
+// test
+func writeSetupDigest(h hash.Hash, r *http.Request, body []byte) {
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.RequestURI()))
+	h.Write([]byte{0})
+	h.Write(body)
+	h.Write([]byte{0})
+}
+
+// setupRecorder proxies setup requests to the server, recording its
+// responses. Tests which configure the server with its own address do so
+// with the address of the server rather than that of the proxy.
+type setupRecorder struct {
+	addr      string
+	standIn   string
+	transport http.RoundTripper
+
+	l         sync.Mutex
+	digest    hash.Hash
+	requests  int
+	responses []*SetupResponse
+	mounts    []string
+}
+
+func (s *setupRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	body = bytes.ReplaceAll(body, []byte(s.standIn), []byte(s.addr))
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, s.addr+r.URL.RequestURI(), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	req.Header = r.Header.Clone()
+	resp, err := s.transport.RoundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	s.l.Lock()
+	writeSetupDigest(s.digest, r, body)
+	s.responses = append(s.responses, &SetupResponse{
+		Method: r.Method,
+		Path:   r.URL.RequestURI(),
+		Status: resp.StatusCode,
+		Body:   string(respBody),
+	})
+	if mount, ok := dryRunMount(r, body); ok && resp.StatusCode < 300 {
+		if mount.Kind == "auth" {
+			mount.Path = "auth/" + mount.Path
+		}
+		s.mounts = append(s.mounts, mount.Path)
+	}
+	s.l.Unlock()
+
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(respBody)
+}
+
+// take returns the setup of the test set up since it was last called
+func (s *setupRecorder) take() *TestSetupState {
+	s.l.Lock()
+	defer s.l.Unlock()
+	test := &TestSetupState{
+		Mounts:    s.mounts,
+		Digest:    hex.EncodeToString(s.digest.Sum(nil)),
+		Responses: s.responses,
+	}
+	s.digest.Reset()
+	s.responses, s.mounts = nil, nil
+	return test
+}
+
+// setupReplayer stands in for the server a test was set up on, answering
+// its setup requests with the responses recorded when it was. The first
+// request which wasn't recorded fails the setup of the test.
+type setupReplayer struct {
+	addr    string
+	standIn string
+
+	l      sync.Mutex
+	test   *TestSetupState
+	next   int
+	digest hash.Hash
+	err    error
+}
+
+// start replays the setup of a test
+func (s *setupReplayer) start(test *TestSetupState) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.test = test
+	s.next = 0
+	s.digest = sha256.New()
+	s.err = nil
+}
+
+// finish checks the test sent every setup request it was recorded sending
+func (s *setupReplayer) finish() error {
+	s.l.Lock()
+	defer s.l.Unlock()
+	switch {
+	case s.err != nil:
+		return s.err
+	case s.next != len(s.test.Responses):
+		return fmt.Errorf("test %v sent %d setup requests where %d were recorded", s.test.Name, s.next, len(s.test.Responses))
+	case hex.EncodeToString(s.digest.Sum(nil)) != s.test.Digest:
+		return fmt.Errorf("test %v sent different setup requests than were recorded, so may generate values during its setup and can't be attacked only", s.test.Name)
+	}
+	return nil
+}
+
+// mismatch returns the first setup request of the test which wasn't
+// recorded, if any
+func (s *setupReplayer) mismatch() error {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.err
+}
+
+func (s *setupReplayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	body = bytes.ReplaceAll(body, []byte(s.standIn), []byte(s.addr))
+
+	s.l.Lock()
+	var resp *SetupResponse
+	if s.next < len(s.test.Responses) {
+		resp = s.test.Responses[s.next]
+	}
+	if resp == nil || resp.Method != r.Method || resp.Path != r.URL.RequestURI() {
+		if s.err == nil {
+			expected := "none"
+			if resp != nil {
+				expected = resp.Method + " " + resp.Path
+			}
+			s.err = fmt.Errorf("setup request %d of test %v was %v %v where %v was recorded", s.next+1, s.test.Name, r.Method, r.URL.RequestURI(), expected)
+		}
+		s.l.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"errors": []string{"setup request was not recorded"},
+		})
+		return
+	}
+	writeSetupDigest(s.digest, r, body)
+	s.next++
+	s.l.Unlock()
+
+	if resp.Body != "" && strings.HasPrefix(strings.TrimSpace(resp.Body), "{") {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(resp.Status)
+	_, _ = io.WriteString(w, resp.Body)
+}
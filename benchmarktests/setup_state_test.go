@@ -0,0 +1,137 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestSetupState_AttackOnly(t *testing.T) {
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		var data map[string]interface{}
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/role-id"):
+			data = map[string]interface{}{"role_id": "role-1"}
+		case strings.HasSuffix(req.URL.Path, "/secret-id"):
+			data = map[string]interface{}{"secret_id": "secret-1"}
+		default:
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+
+	newTests := func(testType string) []*BenchmarkTarget {
+		t.Helper()
+		builder := TestList[testType]()
+		if err := builder.ParseConfig(hcl.EmptyBody()); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return []*BenchmarkTarget{{Name: "login", Type: testType, Weight: 100, Builder: builder}}
+	}
+
+	logger := hclog.NewNullLogger()
+	state, err := SetupOnly(client, newTests("approle_auth"), &logger, &TopLevelTargetConfig{RandomMounts: true})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(state.Tests) != 1 {
+		t.Fatalf("expected the setup of one test, got: %+v", state.Tests)
+	}
+	test := state.Tests[0]
+	if test.MountName == "" || test.MountName == "login" {
+		t.Fatalf("expected a random mount name, got: %q", test.MountName)
+	}
+	if len(test.Mounts) != 1 || test.Mounts[0] != "auth/"+test.MountName {
+		t.Fatalf("expected the approle auth mount, got: %v", test.Mounts)
+	}
+	if int64(len(test.Responses)) != requests.Load() {
+		t.Fatalf("expected %d responses, got %d", requests.Load(), len(test.Responses))
+	}
+
+	path := filepath.Join(t.TempDir(), "setup.json")
+	if err := state.Save(path); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	loaded, err := LoadSetupState(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The tests are set up again without sending the server a request
+	sent := requests.Load()
+	tm, err := loaded.BuildTargets(client, newTests("approle_auth"), &logger, &TopLevelTargetConfig{RandomMounts: true})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if requests.Load() != sent {
+		t.Fatalf("expected no requests to the server, got %d", requests.Load()-sent)
+	}
+	tgt := tm.targets[0].Target(client)
+	if tgt.URL != srv.URL+"/v1/auth/"+test.MountName+"/login" {
+		t.Fatalf("expected the target to be sent to the mount which was set up, got: %v", tgt.URL)
+	}
+	if !strings.Contains(string(tgt.Body), `"secret_id": "secret-1"`) {
+		t.Fatalf("expected the recorded secret_id, got: %s", tgt.Body)
+	}
+
+	if _, err := loaded.BuildTargets(client, newTests("userpass_auth"), &logger, &TopLevelTargetConfig{}); err == nil {
+		t.Fatal("expected an error for a config of other tests")
+	}
+}
+
+func TestSetupState_GeneratedValues(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Userpass tests generate a password when none is configured, which
+	// differs when they are set up again
+	newTests := func() []*BenchmarkTarget {
+		builder := TestList["userpass_auth"]()
+		if err := builder.ParseConfig(hcl.EmptyBody()); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		return []*BenchmarkTarget{{Name: "login", Type: "userpass_auth", Weight: 100, Builder: builder}}
+	}
+	logger := hclog.NewNullLogger()
+	state, err := SetupOnly(client, newTests(), &logger, &TopLevelTargetConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	_, err = state.BuildTargets(client, newTests(), &logger, &TopLevelTargetConfig{})
+	if err == nil || !strings.Contains(err.Error(), "different setup requests") {
+		t.Fatalf("expected the setup of the test to differ, got: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// SLOConfig declares objectives the results of a test must meet. Only the
+// objectives which are set are checked. They are checked against the
+// results of the whole run and, with PerInterval, additionally against each
+// point of the time series.
+type SLOConfig struct {
+	MaxP99        string   `hcl:"max_p99,optional"`
+	MinThroughput float64  `hcl:"min_throughput,optional"`
+	MaxErrorRate  *float64 `hcl:"max_error_rate,optional"`
+	PerInterval   bool     `hcl:"per_interval,optional"`
+}
+
+func (c *SLOConfig) Validate() error {
+	if c.MaxP99 != "" {
+		maxP99, err := time.ParseDuration(c.MaxP99)
+		if err != nil {
+			return fmt.Errorf("invalid max_p99: %v", err)
+		}
+		if maxP99 <= 0 {
+			return fmt.Errorf("max_p99 must be positive")
+		}
+	}
+	switch {
+	case c.MinThroughput < 0:
+		return fmt.Errorf("min_throughput must not be negative")
+	case c.MaxErrorRate != nil && (*c.MaxErrorRate < 0 || *c.MaxErrorRate > 100):
+		return fmt.Errorf("max_error_rate must be between 0 and 100")
+	case c.MaxP99 == "" && c.MinThroughput == 0 && c.MaxErrorRate == nil:
+		return fmt.Errorf("one of max_p99, min_throughput or max_error_rate must be set")
+	}
+	return nil
+}
+
+func (c *SLOConfig) maxP99() time.Duration {
+	// The latency is checked by Validate when the config is parsed
+	maxP99, _ := time.ParseDuration(c.MaxP99)
+	return maxP99
+}
+
+// SLOResult is the outcome of checking one objective of a test
+type SLOResult struct {
+	Phase     string
+	Test      string
+	Objective string
+	Actual    string
+	Passed    bool
+}
+
+// sloObjective is a single objective of an SLO block, checked against the
+// results of the run or of one interval
+type sloObjective struct {
+	description string
+	check       func(s *DiffStats) (actual string, passed bool)
+}
+
+func (c *SLOConfig) objectives() []sloObjective {
+	var objectives []sloObjective
+	if c.MaxP99 != "" {
+		maxP99 := c.maxP99()
+		objectives = append(objectives, sloObjective{
+			description: fmt.Sprintf("p99 <= %v", maxP99),
+			check: func(s *DiffStats) (string, bool) {
+				return s.P99.String(), s.P99 <= maxP99
+			},
+		})
+	}
+	if c.MinThroughput > 0 {
+		objectives = append(objectives, sloObjective{
+			description: fmt.Sprintf("throughput >= %.2f/s", c.MinThroughput),
+			check: func(s *DiffStats) (string, bool) {
+				return fmt.Sprintf("%.2f/s", s.Throughput), s.Throughput >= c.MinThroughput
+			},
+		})
+	}
+	if c.MaxErrorRate != nil {
+		maxErrorRate := *c.MaxErrorRate
+		objectives = append(objectives, sloObjective{
+			description: fmt.Sprintf("error rate <= %.2f%%", maxErrorRate),
+			check: func(s *DiffStats) (string, bool) {
+				return fmt.Sprintf("%.2f%%", s.ErrorRate*100), s.ErrorRate*100 <= maxErrorRate
+			},
+		})
+	}
+	return objectives
+}
+
+// CheckSLOs checks the objectives of every test against its results in
+// each phase, combined over all reports of the phase. Per interval
+// objectives are checked against every point of the time series of each
+// report, and only pass when every interval does.
+func CheckSLOs(rpts []*Reporter, slos map[string][]*SLOConfig) []*SLOResult {
+	stats, keys := diffStats(rpts)
+
+	var results []*SLOResult
+	for _, key := range keys {
+		for _, slo := range slos[key.test] {
+			for _, objective := range slo.objectives() {
+				actual, passed := objective.check(stats[key])
+				results = append(results, &SLOResult{
+					Phase:     key.phase,
+					Test:      key.test,
+					Objective: objective.description,
+					Actual:    actual,
+					Passed:    passed,
+				})
+				if !slo.PerInterval {
+					continue
+				}
+
+				var intervals, failed int
+				for _, rpt := range rpts {
+					if rpt.phase != key.phase {
+						continue
+					}
+					for _, point := range rpt.timeseries[key.test] {
+						intervals++
+						if _, ok := objective.check(pointStats(point)); !ok {
+							failed++
+						}
+					}
+				}
+				results = append(results, &SLOResult{
+					Phase:     key.phase,
+					Test:      key.test,
+					Objective: objective.description + " per interval",
+					Actual:    fmt.Sprintf("%d of %d intervals failed", failed, intervals),
+					Passed:    failed == 0,
+				})
+			}
+		}
+	}
+	return results
+}
+
+// pointStats returns the results of one interval of the time series
+func pointStats(point TimeseriesPoint) *DiffStats {
+	return &DiffStats{
+		Requests:   point.Requests,
+		Throughput: point.Throughput,
+		ErrorRate:  point.ErrorRate,
+		P50:        point.P50,
+		P99:        point.P99,
+	}
+}
+
+// WriteSLOResults writes a table of the outcome of every objective
+func WriteSLOResults(w io.Writer, results []*SLOResult) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "op\tslo\tactual\tresult\n")
+	for _, result := range results {
+		label := result.Test
+		if result.Phase != "" {
+			label = result.Test + " (" + result.Phase + ")"
+		}
+		outcome := "pass"
+		if !result.Passed {
+			outcome = "FAIL"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", label, result.Objective, result.Actual, outcome)
+	}
+	return tw.Flush()
+}
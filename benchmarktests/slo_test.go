@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestCheckSLOs(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rpt.startWarmup(began, 0)
+	rpt.trackTimeseries(10 * time.Second)
+
+	// Fast in the first interval, and failing slowly in the second
+	for i := 0; i < 20; i++ {
+		result := &vegeta.Result{
+			Method:    "GET",
+			URL:       "N/A/v1/secret/foo",
+			Code:      200,
+			Timestamp: began.Add(time.Duration(i) * time.Second),
+			Latency:   time.Millisecond,
+		}
+		if i == 17 || i == 18 {
+			result.Code = 500
+			result.Error = "500 Internal Server Error"
+			result.Latency = time.Second
+		}
+		rpt.Add(result)
+	}
+	rpt.Close()
+
+	maxErrorRate := 10.0
+	slos := map[string][]*SLOConfig{
+		"read": {
+			{MaxP99: "50ms"},
+			{MaxErrorRate: &maxErrorRate, PerInterval: true},
+		},
+	}
+	results := CheckSLOs([]*Reporter{rpt}, slos)
+
+	var got []string
+	for _, result := range results {
+		got = append(got, result.Objective+" "+result.Actual+" "+map[bool]string{true: "pass", false: "fail"}[result.Passed])
+	}
+	want := []string{
+		"p99 <= 50ms 1s fail",
+		"error rate <= 10.00% 10.00% pass",
+		"error rate <= 10.00% per interval 1 of 2 intervals failed fail",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("expected:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSLOResults(&buf, results); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "FAIL") != 2 {
+		t.Fatalf("expected 2 failed slos, got:\n%s", buf.String())
+	}
+}
+
+func TestSLOConfig_Validate(t *testing.T) {
+	negative := -1.0
+	cases := map[string]*SLOConfig{
+		"one of max_p99":           {},
+		"invalid max_p99":          {MaxP99: "fast"},
+		"min_throughput must not":  {MinThroughput: -1},
+		"max_error_rate must be":   {MaxErrorRate: &negative},
+		"max_p99 must be positive": {MaxP99: "0s"},
+	}
+	for want, slo := range cases {
+		if err := slo.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error containing %q, got: %v", want, err)
+		}
+	}
+	if err := (&SLOConfig{MinThroughput: 100}).Validate(); err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// DefaultSnapshotAt is when the snapshot is taken when no point is
+	// given, halfway through the run
+	DefaultSnapshotAt = "50%"
+
+	// SnapshotEvent is the name of the chaos event of the snapshot
+	// scenario
+	SnapshotEvent = "raft_snapshot"
+)
+
+// SnapshotConfig runs the built-in snapshot scenario, saving a snapshot of
+// the Raft storage of the cluster with sys/storage/raft/snapshot during the
+// attack and measuring how long it took and how latencies degraded while
+// it was taken. The snapshot may be restored on a scratch cluster, to
+// measure how long restoring it takes.
+type SnapshotConfig struct {
+	At           string `hcl:"at,optional"`
+	RestoreAddr  string `hcl:"restore_addr,optional"`
+	RestoreToken string `hcl:"restore_token,optional"`
+}
+
+// Validate checks the scratch cluster to restore the snapshot on and
+// defaults the point the snapshot is taken at. The points are checked
+// against the duration of the run.
+func (s *SnapshotConfig) Validate() error {
+	if s.At == "" {
+		s.At = DefaultSnapshotAt
+	}
+	if s.RestoreAddr == "" {
+		if s.RestoreToken != "" {
+			return fmt.Errorf("restore_token requires restore_addr")
+		}
+		return nil
+	}
+	if u, err := url.Parse(s.RestoreAddr); err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid restore_addr %q", s.RestoreAddr)
+	}
+	return nil
+}
+
+// SnapshotResult measures the impact of taking a snapshot. Latencies are
+// compared between the minute before the snapshot and the seconds it was
+// taken in, the window P99 being the slowest 99th percentile of successful
+// requests of any of those seconds.
+type SnapshotResult struct {
+	Took        time.Duration  `json:"took"`
+	Size        int64          `json:"size"`
+	RestoreTook *time.Duration `json:"restore_took,omitempty"`
+
+	BaselineMean    time.Duration `json:"baseline_mean"`
+	BaselineP99     time.Duration `json:"baseline_p99"`
+	WindowRequests  uint64        `json:"window_requests"`
+	WindowMean      time.Duration `json:"window_mean"`
+	WindowP99       time.Duration `json:"window_p99"`
+	WindowErrorRate float64       `json:"window_error_rate"`
+}
+
+// snapshotResult measures the latencies of the seconds a snapshot was
+// taken in against those of the minute before it
+func (r *Reporter) snapshotResult(event ChaosEventResult) *SnapshotResult {
+	result := *event.Snapshot
+	first := int(event.Time.Sub(r.began) / time.Second)
+	last := int(event.Time.Add(result.Took).Sub(r.began) / time.Second)
+
+	baseline := r.baseline(first)
+	if baseline.requests > 0 {
+		result.BaselineMean = baseline.latency / time.Duration(baseline.requests)
+	}
+	result.BaselineP99 = r.baselineP99(first)
+
+	var window recoverySecond
+	for i := max(first, 0); i <= last && i < len(r.recovery); i++ {
+		s := r.recovery[i]
+		window.requests += s.requests
+		window.errors += s.errors
+		window.latency += s.latency
+		if s.successes.Max > 0 {
+			result.WindowP99 = max(result.WindowP99, s.successes.Quantile(0.99))
+		}
+	}
+	result.WindowRequests = window.requests
+	if window.requests > 0 {
+		result.WindowMean = window.latency / time.Duration(window.requests)
+		result.WindowErrorRate = float64(window.errors) / float64(window.requests)
+	}
+	return &result
+}
+
+// reportSnapshots writes the measurements of the snapshots taken during
+// the attack, when it has any
+func (r *Reporter) reportSnapshots(w io.Writer) {
+	for _, event := range r.chaosEvents {
+		if event.Snapshot == nil {
+			continue
+		}
+		s := event.Snapshot
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		fmt.Fprintf(tw, "\nRaft snapshot: taken at +%s\n", event.Time.Sub(r.began).Round(time.Second))
+		if event.Error != "" {
+			fmt.Fprintf(tw, "snapshot failed:\t%s\n", event.Error)
+		}
+		if s.Took > 0 {
+			fmt.Fprintf(tw, "took:\t%s\t(%.1fMB)\n", s.Took.Round(time.Millisecond), float64(s.Size)/1e6)
+		}
+		if s.RestoreTook != nil {
+			fmt.Fprintf(tw, "restore took:\t%s\t\n", s.RestoreTook.Round(time.Millisecond))
+		}
+		if s.WindowRequests > 0 {
+			fmt.Fprintf(tw, "mean:\t%s\t(baseline %s)\n", s.WindowMean.Round(time.Microsecond), s.BaselineMean.Round(time.Microsecond))
+			fmt.Fprintf(tw, "99th%%:\t%s\t(baseline %s)\n", s.WindowP99.Round(time.Microsecond), s.BaselineP99.Round(time.Microsecond))
+			fmt.Fprintf(tw, "error rate:\t%.2f%%\t\n", 100*s.WindowErrorRate)
+		}
+		tw.Flush()
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestSnapshotConfigValidate(t *testing.T) {
+	s := &SnapshotConfig{}
+	if err := s.Validate(); err != nil || s.At != DefaultSnapshotAt {
+		t.Fatalf("expected the snapshot to default to halfway, got %q: %v", s.At, err)
+	}
+	if err := (&SnapshotConfig{RestoreToken: "root"}).Validate(); err == nil || !strings.Contains(err.Error(), "restore_token requires restore_addr") {
+		t.Fatalf("expected a restore token without an address to be rejected, got %v", err)
+	}
+	if err := (&SnapshotConfig{RestoreAddr: "scratch:8200"}).Validate(); err == nil || !strings.Contains(err.Error(), "invalid restore_addr") {
+		t.Fatalf("expected an address without a scheme to be rejected, got %v", err)
+	}
+}
+
+func TestSnapshotResult(t *testing.T) {
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.startWarmup(began, 0)
+	rpt.trackRecovery(true)
+
+	// Requests slow down, and some fail, during the two seconds the
+	// snapshot takes 20 seconds in
+	for i := 0; i < 40; i++ {
+		for j := 0; j < 10; j++ {
+			result := &vegeta.Result{
+				Method:    "GET",
+				URL:       "N/A/v1/secret/foo",
+				Code:      200,
+				Timestamp: began.Add(time.Duration(i)*time.Second + time.Duration(j)*100*time.Millisecond),
+				Latency:   time.Millisecond,
+			}
+			if i == 20 || i == 21 {
+				result.Latency = 4 * time.Millisecond
+				if j == 0 {
+					result.Code, result.Error = 503, "503 Service Unavailable"
+				}
+			}
+			rpt.Add(result)
+		}
+	}
+	rpt.Close()
+
+	restoreTook := 3 * time.Second
+	rpt.SetChaosEvents([]ChaosEventResult{{
+		Name:     SnapshotEvent,
+		Action:   "raft snapshot",
+		Time:     began.Add(20 * time.Second),
+		Window:   DefaultRecoveryWindow,
+		Snapshot: &SnapshotResult{Took: 1500 * time.Millisecond, Size: 25e6, RestoreTook: &restoreTook},
+	}})
+	s := rpt.chaosEvents[0].Snapshot
+	if s.WindowRequests != 20 || s.WindowErrorRate != 0.1 {
+		t.Fatalf("expected 20 requests with 10%% errors during the snapshot, got %+v", s)
+	}
+	if s.BaselineMean != time.Millisecond || s.BaselineP99 != time.Millisecond || s.WindowMean != 4*time.Millisecond || s.WindowP99 != 4*time.Millisecond {
+		t.Fatalf("expected latencies to degrade from 1ms to 4ms, got %+v", s)
+	}
+
+	var b bytes.Buffer
+	rpt.reportSnapshots(&b)
+	for _, want := range []string{"Raft snapshot: taken at +20s", "1.5s", "(25.0MB)", "restore took:", "3s", "(baseline 1ms)", "10.00%"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected %q in snapshot report:\n%s", want, b.String())
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recordCode adds a latency of the named test to the histogram of its
+// response status code
+func (r *Reporter) recordCode(name string, code uint16, latency time.Duration) {
+	codes, ok := r.codeHistograms[name]
+	if !ok {
+		codes = make(map[uint16]*Histogram)
+		r.codeHistograms[name] = codes
+	}
+	h, ok := codes[code]
+	if !ok {
+		h = NewHistogram()
+		codes[code] = h
+	}
+	h.Record(latency)
+}
+
+// sortedCodes returns the status codes seen by the named test, in order
+func (r *Reporter) sortedCodes(name string) []uint16 {
+	codes := make([]uint16, 0, len(r.codeHistograms[name]))
+	for code := range r.codeHistograms[name] {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// reportCodesVerbose writes the count and latencies of each status code of
+// the named test
+func (r *Reporter) reportCodesVerbose(w io.Writer, name string) {
+	codes := r.sortedCodes(name)
+	if len(codes) == 0 {
+		return
+	}
+
+	percentiles := r.reportedPercentiles()
+	labels := make([]string, len(percentiles))
+	for i, p := range percentiles {
+		labels[i] = percentileLabel(p)
+	}
+	fmt.Fprintf(w, "Status code latencies [count, mean, %s]\n", strings.Join(labels, ", "))
+	for _, code := range codes {
+		h := r.codeHistograms[name][code]
+		values := make([]string, len(percentiles))
+		for i, p := range percentiles {
+			values[i] = h.Quantile(p / 100).String()
+		}
+		fmt.Fprintf(w, "  %d  %d, %s, %s\n", code, h.Count(), h.Mean(), strings.Join(values, ", "))
+	}
+}
+
+// reportCodesTerse writes a table of the count and latencies of each
+// status code of every test. It is only written when some test saw more
+// than one status code, as otherwise it repeats the main table.
+func (r *Reporter) reportCodesTerse(w io.Writer, names []string) {
+	mixed := false
+	for _, name := range names {
+		if name != "total" && len(r.codeHistograms[name]) > 1 {
+			mixed = true
+			break
+		}
+	}
+	if !mixed {
+		return
+	}
+
+	fmt.Fprintf(w, "\nop\tcode\tcount\tmean\t")
+	for _, p := range r.reportedPercentiles() {
+		if p == 100 {
+			fmt.Fprintf(w, "max\t")
+		} else {
+			fmt.Fprintf(w, "%s%%\t", percentileLabel(p))
+		}
+	}
+	fmt.Fprintf(w, "\n")
+
+	for _, name := range names {
+		if name == "total" {
+			continue
+		}
+		for _, code := range r.sortedCodes(name) {
+			h := r.codeHistograms[name][code]
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t", name, code, h.Count(), h.Mean())
+			for _, p := range r.reportedPercentiles() {
+				fmt.Fprintf(w, "%s\t", h.Quantile(p/100))
+			}
+			fmt.Fprintf(w, "\n")
+		}
+	}
+}
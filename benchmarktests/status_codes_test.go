@@ -0,0 +1,88 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func statusCodeReporter() *Reporter {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	for i := 0; i < 9; i++ {
+		rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 200, Timestamp: time.Now(), Latency: 100 * time.Millisecond})
+	}
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 500, Timestamp: time.Now(), Latency: time.Millisecond, Error: "500 Internal Server Error"})
+	rpt.Close()
+	return rpt
+}
+
+func TestReporter_StatusCodeLatencies(t *testing.T) {
+	rpt := statusCodeReporter()
+
+	if got := rpt.codeHistograms["read"][200].Count(); got != 9 {
+		t.Fatalf("expected 9 requests with a 200, got: %v", got)
+	}
+	if got := rpt.codeHistograms["read"][500].Max(); got != time.Millisecond {
+		t.Fatalf("expected the 500 to be kept apart from the slow 200s, got: %v", got)
+	}
+
+	var terse bytes.Buffer
+	if err := rpt.ReportTerse(&terse); err != nil {
+		t.Fatal(err)
+	}
+	var rows []string
+	for _, table := range strings.Split(terse.String(), "\n\n") {
+		lines := strings.Split(strings.TrimSpace(table), "\n")
+		if !strings.HasPrefix(strings.Join(strings.Fields(lines[0]), " "), "op code count mean") {
+			continue
+		}
+		for _, line := range lines[1:] {
+			rows = append(rows, strings.Join(strings.Fields(line)[:4], " "))
+		}
+	}
+	if strings.Join(rows, "\n") != "read 200 9 100ms\nread 500 1 1ms" {
+		t.Fatalf("unexpected status code rows in:\n%s", terse.String())
+	}
+
+	var verbose bytes.Buffer
+	if err := rpt.ReportVerbose(&verbose); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(verbose.String(), "Status code latencies [count, mean, 95th, 99th]") || !strings.Contains(verbose.String(), "  500  1, 1ms, ") {
+		t.Fatalf("expected status code latencies in the verbose report, got:\n%s", verbose.String())
+	}
+
+	var encoded bytes.Buffer
+	if err := rpt.ReportJSON(&encoded); err != nil {
+		t.Fatal(err)
+	}
+	rpts, err := FromReader(&encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rpts[0].codeHistograms["read"][500].Count(); got != 1 {
+		t.Fatalf("expected the status code histograms to round trip, got: %v", got)
+	}
+}
+
+func TestReporter_StatusCodeLatenciesSingleCode(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	rpt.Add(&vegeta.Result{Method: "GET", URL: "N/A/v1/secret/foo", Code: 200, Timestamp: time.Now(), Latency: time.Millisecond})
+	rpt.Close()
+
+	var terse bytes.Buffer
+	if err := rpt.ReportTerse(&terse); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(terse.String(), "code") {
+		t.Fatalf("expected no status code table when every response had the same code, got:\n%s", terse.String())
+	}
+}
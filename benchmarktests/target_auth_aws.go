@@ -121,6 +121,10 @@ func (a *AWSAuth) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (a *AWSAuth) EnvVars() []string {
+	return []string{AWSAuthAccessKey, AWSAuthSecretKey}
+}
+
 func (a *AWSAuth) Target(client *api.Client) vegeta.Target {
 	jsonData, _ := json.Marshal(a.loginData)
 	return vegeta.Target{
@@ -116,6 +116,10 @@ func (a *AzureAuth) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (a *AzureAuth) EnvVars() []string {
+	return []string{AzureAuthClientID, AzureAuthClientSecret, AzureAuthJWT}
+}
+
 func (a *AzureAuth) Target(client *api.Client) vegeta.Target {
 	jsonData, _ := json.Marshal(a.loginData)
 	return vegeta.Target{
@@ -91,6 +91,10 @@ func (g *GitHubAuth) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (g *GitHubAuth) EnvVars() []string {
+	return []string{GitHubAuthTestUserToken}
+}
+
 func (g *GitHubAuth) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: "POST",
@@ -126,6 +126,14 @@ func (l *LDAPAuth) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (l *LDAPAuth) EnvVars() []string {
+	return []string{
+		LDAPAuthBindPassEnvVar,
+		LDAPAuthTestUserNameEnvVar,
+		LDAPAuthTestUserPasswordEnvVar,
+	}
+}
+
 func (l *LDAPAuth) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: "POST",
@@ -32,8 +32,10 @@ func init() {
 
 type UserpassAuth struct {
 	pathPrefix string
-	user       string
+	users      []string
+	keys       keySelector
 	password   string
+	rows       *dataRows
 	header     http.Header
 	config     *UserpassAuthConfig
 	logger     hclog.Logger
@@ -51,6 +53,11 @@ type UserpassAuthConfig struct {
 	TokenNumUses         int      `hcl:"token_num_uses,optional"`
 	TokenPeriod          string   `hcl:"token_period,optional"`
 	TokenType            string   `hcl:"token_type,optional"`
+	NumUsers             int      `hcl:"num_users,optional"`
+
+	// DataFile, when set, creates a user for every row, rendering the
+	// username template with the row
+	DataFile *DataFileConfig `hcl:"data_file,block"`
 }
 
 // ParseConfig parses the passed in hcl.Body into Configuration structs for use during
@@ -63,6 +70,7 @@ func (u *UserpassAuth) ParseConfig(body hcl.Body) error {
 		Config: &UserpassAuthConfig{
 			Username: "benchmark-user",
 			Password: password.MustGenerate(64, 10, 0, false, true),
+			NumUsers: 1,
 		},
 	}
 
@@ -70,14 +78,31 @@ func (u *UserpassAuth) ParseConfig(body hcl.Body) error {
 	if diags.HasErrors() {
 		return fmt.Errorf("error decoding to struct: %v", diags)
 	}
+	rows, err := loadDataFile(testConfig.Config.DataFile)
+	if err != nil {
+		return err
+	}
+	username, err := parsePayloadTemplate(testConfig.Config.Username, rows)
+	if err != nil {
+		return fmt.Errorf("invalid username: %v", err)
+	}
+	switch {
+	case testConfig.Config.NumUsers < 1:
+		return fmt.Errorf("num_users must be at least 1")
+	case testConfig.Config.NumUsers > 1 && rows != nil:
+		return fmt.Errorf("num_users cannot be set with a data_file, which creates a user for every row")
+	case (testConfig.Config.NumUsers > 1 || rows != nil) && username.static:
+		return fmt.Errorf("username must use a payload template, such as {{seq}}, to create more than one user")
+	}
 	u.config = testConfig.Config
+	u.rows = rows
 	return nil
 }
 
 func (u *UserpassAuth) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: UserpassAuthTestMethod,
-		URL:    client.Address() + u.pathPrefix + "/login/" + u.user,
+		URL:    client.Address() + u.pathPrefix + "/login/" + u.users[u.keys.next()],
 		Header: u.header,
 		Body:   []byte(fmt.Sprintf(`{"password": "%s"}`, u.password)),
 	}
@@ -99,6 +124,10 @@ func (u *UserpassAuth) GetTargetInfo() TargetInfo {
 	}
 }
 
+func (u *UserpassAuth) Files() []string {
+	return u.config.DataFile.files()
+}
+
 func (u *UserpassAuth) Setup(client *api.Client, mountName string, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
 	var err error
 	authPath := mountName
@@ -128,18 +157,41 @@ func (u *UserpassAuth) Setup(client *api.Client, mountName string, topLevelConfi
 	if err != nil {
 		return nil, fmt.Errorf("error parsing user config from struct: %v", err)
 	}
+	delete(userData, "num_users")
+	delete(userData, "data_file")
 
-	setupLogger.Trace(writingLogMessage("user config"))
-	userPath := filepath.Join("auth", authPath, "users", u.config.Username)
-	_, err = client.Logical().Write(userPath, userData)
+	// Every user is created from the username template, so each has a
+	// name of its own when it uses a function such as {{seq}}, or a field
+	// of its row of the data file
+	username, err := parsePayloadTemplate(u.config.Username, u.rows)
 	if err != nil {
-		return nil, fmt.Errorf("error creating userpass user %q: %v", u.config.Username, err)
+		return nil, fmt.Errorf("invalid username: %v", err)
+	}
+	numUsers, keys := u.config.NumUsers, keySelector(uniformKeys(u.config.NumUsers))
+	if u.rows != nil {
+		numUsers, keys = len(u.rows.rows), u.rows.keys()
+	}
+	setupLogger.Trace(writingLogMessage("user config"))
+	users := make([]string, numUsers)
+	for i := range users {
+		r := &payloadRender{seq: int64(i + 1)}
+		if u.rows != nil {
+			r.row = u.rows.rows[i]
+		}
+		users[i] = username.render(r)
+		userData["username"] = users[i]
+		userPath := filepath.Join("auth", authPath, "users", users[i])
+		_, err = client.Logical().Write(userPath, userData)
+		if err != nil {
+			return nil, fmt.Errorf("error creating userpass user %q: %v", users[i], err)
+		}
 	}
 
 	return &UserpassAuth{
 		header:     generateHeader(client),
 		pathPrefix: "/v1/" + filepath.Join("auth", authPath),
-		user:       u.config.Username,
+		users:      users,
+		keys:       keys,
 		password:   u.config.Password,
 		logger:     u.logger,
 	}, nil
@@ -0,0 +1,246 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	RawTestType      = "raw"
+	RawDefaultMethod = "GET"
+
+	// rawMountVariable is replaced by the mount name of the test in the
+	// paths and bodies of raw requests
+	rawMountVariable = "{{mount}}"
+)
+
+func init() {
+	// "Register" this test to the main test registry
+	TestList[RawTestType] = func() BenchmarkBuilder { return &RawTest{} }
+}
+
+// RawTest sends requests described entirely by its config, for endpoints
+// no other test covers
+type RawTest struct {
+	config *RawTestConfig
+	rows   *dataRows
+
+	mount      string
+	method     string
+	pathPrefix string
+	path       string
+	body       []byte
+	header     http.Header
+	payloads   *payloadGenerator
+}
+
+type RawTestConfig struct {
+	Method   string              `hcl:"method,optional"`
+	Path     string              `hcl:"path"`
+	Headers  map[string]string   `hcl:"headers,optional"`
+	Body     string              `hcl:"body,optional"`
+	DataFile *DataFileConfig     `hcl:"data_file,block"`
+	Setup    []*RawRequestConfig `hcl:"setup,block"`
+	Cleanup  []*RawRequestConfig `hcl:"cleanup,block"`
+}
+
+// RawRequestConfig is a request a raw test sends when it is set up or
+// cleaned up
+type RawRequestConfig struct {
+	Method  string            `hcl:"method,optional"`
+	Path    string            `hcl:"path"`
+	Headers map[string]string `hcl:"headers,optional"`
+	Body    string            `hcl:"body,optional"`
+}
+
+func (r *RawTest) ParseConfig(body hcl.Body) error {
+	testConfig := &struct {
+		Config *RawTestConfig `hcl:"config,block"`
+	}{
+		Config: &RawTestConfig{
+			Method: RawDefaultMethod,
+		},
+	}
+
+	diags := gohcl.DecodeBody(body, nil, testConfig)
+	if diags.HasErrors() {
+		return fmt.Errorf("error decoding to struct: %v", diags)
+	}
+	r.config = testConfig.Config
+	if r.config.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+
+	rows, err := loadDataFile(r.config.DataFile)
+	if err != nil {
+		return err
+	}
+	r.rows = rows
+	// The templates are checked with a placeholder mount, as the name of
+	// the mount isn't known until the test is set up
+	if _, err := r.newPayloads("raw"); err != nil {
+		return err
+	}
+	for _, requests := range [][]*RawRequestConfig{r.config.Setup, r.config.Cleanup} {
+		for _, req := range requests {
+			if _, err := rawTemplate(req.Path, "raw", nil); err != nil {
+				return fmt.Errorf("invalid path of request %v %v: %v", req.Method, req.Path, err)
+			}
+			if _, err := rawTemplate(req.Body, "raw", nil); err != nil {
+				return fmt.Errorf("invalid body of request %v %v: %v", req.Method, req.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// newPayloads returns a generator of the paths and bodies of the requests
+// of the test against the mount, or nil when they are always the same
+func (r *RawTest) newPayloads(mountName string) (*payloadGenerator, error) {
+	pathTemplate, err := rawTemplate(r.config.Path, mountName, r.rows)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %v", err)
+	}
+	bodyTemplate, err := rawTemplate(r.config.Body, mountName, r.rows)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body: %v", err)
+	}
+	if pathTemplate.static && bodyTemplate.static {
+		return nil, nil
+	}
+	return newPayloadGenerator(r.rows, func(rr *payloadRender) payload {
+		return payload{key: pathTemplate.render(rr), body: []byte(bodyTemplate.render(rr))}
+	}), nil
+}
+
+func (r *RawTest) Files() []string {
+	return r.config.DataFile.files()
+}
+
+func (r *RawTest) Setup(client *api.Client, mountName string, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
+	for _, req := range r.config.Setup {
+		if err := sendRawRequest(client, req, mountName); err != nil {
+			return nil, fmt.Errorf("error setting up raw test: %v", err)
+		}
+	}
+
+	payloads, err := r.newPayloads(mountName)
+	if err != nil {
+		return nil, err
+	}
+	path := strings.ReplaceAll(r.config.Path, rawMountVariable, mountName)
+	// Results are matched to the test by the text of the path before its
+	// first template function
+	prefix := path
+	if i := strings.Index(prefix, "{{"); i >= 0 {
+		prefix = prefix[:i]
+	}
+	header := generateHeader(client)
+	for k, v := range r.config.Headers {
+		header.Set(k, v)
+	}
+
+	return &RawTest{
+		config:     r.config,
+		rows:       r.rows,
+		mount:      mountName,
+		method:     strings.ToUpper(r.config.Method),
+		pathPrefix: "/v1/" + strings.TrimPrefix(prefix, "/"),
+		path:       "/v1/" + strings.TrimPrefix(path, "/"),
+		body:       []byte(strings.ReplaceAll(r.config.Body, rawMountVariable, mountName)),
+		header:     header,
+		payloads:   payloads,
+	}, nil
+}
+
+func (r *RawTest) Target(client *api.Client) vegeta.Target {
+	target := vegeta.Target{
+		Method: r.method,
+		URL:    client.Address() + r.path,
+		Header: r.header,
+	}
+	body := r.body
+	if r.payloads != nil {
+		p := r.payloads.next()
+		target.URL = client.Address() + "/v1/" + strings.TrimPrefix(p.key, "/")
+		body = p.body
+	}
+	if len(body) > 0 {
+		target.Body = body
+	}
+	return target
+}
+
+func (r *RawTest) Cleanup(client *api.Client) error {
+	for _, req := range r.config.Cleanup {
+		if err := sendRawRequest(client, req, r.mount); err != nil {
+			return fmt.Errorf("error cleaning up raw test: %v", err)
+		}
+	}
+	return nil
+}
+
+func (r *RawTest) GetTargetInfo() TargetInfo {
+	return TargetInfo{
+		method:     r.method,
+		pathPrefix: r.pathPrefix,
+	}
+}
+
+func (r *RawTest) Flags(fs *flag.FlagSet) {}
+
+// rawTemplate parses a payload template of a raw test after replacing
+// {{mount}} with the mount name of the test
+func rawTemplate(s, mountName string, rows *dataRows) (*payloadTemplate, error) {
+	return parsePayloadTemplate(strings.ReplaceAll(s, rawMountVariable, mountName), rows)
+}
+
+// sendRawRequest sends a setup or cleanup request of a raw test, whose
+// path and body are rendered once, failing unless it succeeds
+func sendRawRequest(client *api.Client, req *RawRequestConfig, mountName string) error {
+	render := func(s string) string {
+		// The templates were checked when the config was parsed
+		t, _ := rawTemplate(s, mountName, nil)
+		return t.render(&payloadRender{seq: 1})
+	}
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = RawDefaultMethod
+	}
+	path := render(req.Path)
+	var body io.Reader
+	if req.Body != "" {
+		body = strings.NewReader(render(req.Body))
+	}
+
+	httpReq, err := http.NewRequest(method, client.Address()+"/v1/"+strings.TrimPrefix(path, "/"), body)
+	if err != nil {
+		return fmt.Errorf("error creating request %v %v: %v", method, path, err)
+	}
+	httpReq.Header = generateHeader(client)
+	for k, v := range req.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	resp, err := client.CloneConfig().HttpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("error sending request %v %v: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("request %v %v failed with status %d: %s", method, path, resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestRawTest(t *testing.T) {
+	var lock sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		lock.Lock()
+		requests = append(requests, req.Method+" "+req.URL.Path+" "+req.Header.Get("X-Vault-Token")+" "+string(body))
+		lock.Unlock()
+		if strings.HasSuffix(req.URL.Path, "/fail") {
+			http.Error(w, `{"errors": ["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+
+	parse := func(config string) (BenchmarkBuilder, error) {
+		file, diags := hclparse.NewParser().ParseHCL([]byte(config), "test.hcl")
+		if diags.HasErrors() {
+			t.Fatalf("err: %v", diags)
+		}
+		builder := TestList[RawTestType]()
+		return builder, builder.ParseConfig(file.Body)
+	}
+
+	builder, err := parse(`
+config {
+  method  = "post"
+  path    = "{{mount}}/encode/role-{{seq}}"
+  headers = { "X-Raw" = "yes" }
+  body    = "{\"value\": \"{{rand_alpha 8}}\", \"mount\": \"{{mount}}\"}"
+
+  setup {
+    method = "POST"
+    path   = "sys/mounts/{{mount}}"
+    body   = "{\"type\": \"transform\"}"
+  }
+  cleanup {
+    method = "DELETE"
+    path   = "sys/mounts/{{mount}}"
+  }
+}
+`)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	test, err := builder.Setup(client, "raw-transform", &TopLevelTargetConfig{Duration: time.Second})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info := test.GetTargetInfo(); info.method != "POST" || info.pathPrefix != "/v1/raw-transform/encode/role-" {
+		t.Fatalf("unexpected target info: %+v", info)
+	}
+	for i := 1; i <= 2; i++ {
+		tgt := test.Target(client)
+		if tgt.Method != "POST" || tgt.URL != srv.URL+"/v1/raw-transform/encode/role-"+string(rune('0'+i)) || tgt.Header.Get("X-Raw") != "yes" || tgt.Header.Get("X-Vault-Token") != "root" {
+			t.Fatalf("unexpected target: %+v", tgt)
+		}
+		if !strings.HasSuffix(string(tgt.Body), `", "mount": "raw-transform"}`) || len(tgt.Body) != len(`{"value": "12345678", "mount": "raw-transform"}`) {
+			t.Fatalf("unexpected body: %s", tgt.Body)
+		}
+	}
+	if err := test.Cleanup(client); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := "POST /v1/sys/mounts/raw-transform root {\"type\": \"transform\"}\nDELETE /v1/sys/mounts/raw-transform root "
+	if strings.Join(requests, "\n") != expected {
+		t.Fatalf("unexpected requests:\n%v", strings.Join(requests, "\n"))
+	}
+
+	// Static requests are sent as they are
+	builder, err = parse("config {\n  path = \"sys/health\"\n}\n")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	test, err = builder.Setup(client, "raw-health", &TopLevelTargetConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if tgt := test.Target(client); tgt.Method != "GET" || tgt.URL != srv.URL+"/v1/sys/health" || tgt.Body != nil {
+		t.Fatalf("unexpected target: %+v", tgt)
+	}
+
+	// Failed setup requests fail the test
+	builder, err = parse("config {\n  path = \"sys/health\"\n  setup {\n    path = \"sys/fail\"\n  }\n}\n")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := builder.Setup(client, "raw-health", &TopLevelTargetConfig{}); err == nil || !strings.Contains(err.Error(), "failed with status 403: {\"errors\": [\"permission denied\"]}") {
+		t.Fatalf("expected the setup request to fail, got: %v", err)
+	}
+
+	for config, expected := range map[string]string{
+		"config {\n  path = \"{{nope}}\"\n}\n":                                  "invalid path: unknown payload template function: nope",
+		"config {\n  path = \"x\"\n  body = \"{{seq\"\n}\n":                     "invalid body: unclosed {{",
+		"config {\n  path = \"x\"\n  cleanup {\n    path = \"{{x}}\"\n  }\n}\n": "invalid path of request",
+		"config {\n  path = \"{{field user}}\"\n}\n":                            "field user requires a data_file",
+	} {
+		if _, err := parse(config); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got: %v", expected, err)
+		}
+	}
+}
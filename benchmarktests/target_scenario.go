@@ -0,0 +1,441 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	ScenarioTestType = "scenario"
+
+	// ScenarioTestMethod is the method of scenario tests in reports. Their
+	// results are matched to them by the methods and paths of their steps.
+	ScenarioTestMethod = "SCENARIO"
+
+	// scenarioHeader names the scenario, virtual user and step a request
+	// was sent for, so its response can be handed back to the user. It is
+	// removed before the request is sent.
+	scenarioHeader = "X-Benchmark-Scenario"
+)
+
+func init() {
+	// "Register" this test to the main test registry
+	TestList[ScenarioTestType] = func() BenchmarkBuilder { return &ScenarioTest{} }
+}
+
+// ScenarioTest sends an ordered list of requests for each of its virtual
+// users, where values captured from the response to one request may be
+// used in the requests after it. Each user starts over from the first step
+// once it has run them all, or once one of them fails.
+type ScenarioTest struct {
+	config *ScenarioTestConfig
+	rows   *dataRows
+	steps  []*scenarioStep
+
+	id     int64
+	mount  string
+	header http.Header
+
+	lock      sync.Mutex
+	ready     []*scenarioUser
+	waiting   map[int64]*scenarioUser
+	users     int64
+	iteration int64
+}
+
+type ScenarioTestConfig struct {
+	Steps    []*ScenarioStepConfig `hcl:"step,block"`
+	DataFile *DataFileConfig       `hcl:"data_file,block"`
+	Setup    []*RawRequestConfig   `hcl:"setup,block"`
+	Cleanup  []*RawRequestConfig   `hcl:"cleanup,block"`
+}
+
+// ScenarioStepConfig is a request of a scenario, and the variables
+// captured from its response by their JSON paths
+type ScenarioStepConfig struct {
+	Name    string            `hcl:"name,label"`
+	Method  string            `hcl:"method,optional"`
+	Path    string            `hcl:"path"`
+	Headers map[string]string `hcl:"headers,optional"`
+	Body    string            `hcl:"body,optional"`
+	Capture map[string]string `hcl:"capture,optional"`
+}
+
+// scenarioStep is a step of a scenario with its templates parsed
+type scenarioStep struct {
+	name       string
+	method     string
+	path       *payloadTemplate
+	pathPrefix string
+	body       *payloadTemplate
+	headers    map[string]*payloadTemplate
+	capture    map[string]jsonPath
+}
+
+// scenarioUser is a virtual user of a scenario: the step it sends next,
+// and the row and variables of its current run through the steps
+type scenarioUser struct {
+	id   int64
+	step int
+	seq  int64
+	row  map[string]string
+	vars map[string]string
+}
+
+// scenarioIDs counts the scenarios set up, to give each an id
+var scenarioIDs struct {
+	sync.Mutex
+	next int64
+}
+
+func (s *ScenarioTest) ParseConfig(body hcl.Body) error {
+	testConfig := &struct {
+		Config *ScenarioTestConfig `hcl:"config,block"`
+	}{
+		Config: &ScenarioTestConfig{},
+	}
+
+	diags := gohcl.DecodeBody(body, nil, testConfig)
+	if diags.HasErrors() {
+		return fmt.Errorf("error decoding to struct: %v", diags)
+	}
+	s.config = testConfig.Config
+	if len(s.config.Steps) == 0 {
+		return fmt.Errorf("at least one step is required")
+	}
+
+	rows, err := loadDataFile(s.config.DataFile)
+	if err != nil {
+		return err
+	}
+	s.rows = rows
+	// The templates are checked with a placeholder mount, as the name of
+	// the mount isn't known until the test is set up
+	if _, err := s.parseSteps("scenario"); err != nil {
+		return err
+	}
+	for _, requests := range [][]*RawRequestConfig{s.config.Setup, s.config.Cleanup} {
+		for _, req := range requests {
+			if _, err := rawTemplate(req.Path, "scenario", nil); err != nil {
+				return fmt.Errorf("invalid path of request %v %v: %v", req.Method, req.Path, err)
+			}
+			if _, err := rawTemplate(req.Body, "scenario", nil); err != nil {
+				return fmt.Errorf("invalid body of request %v %v: %v", req.Method, req.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseSteps parses the templates of the steps against the mount, which
+// may only use the variables captured by the steps before them
+func (s *ScenarioTest) parseSteps(mountName string) ([]*scenarioStep, error) {
+	vars := make(map[string]bool)
+	names := make(map[string]bool)
+	steps := make([]*scenarioStep, len(s.config.Steps))
+	for i, config := range s.config.Steps {
+		if names[config.Name] {
+			return nil, fmt.Errorf("step %v is defined more than once", config.Name)
+		}
+		names[config.Name] = true
+
+		step := &scenarioStep{
+			name:    config.Name,
+			method:  strings.ToUpper(config.Method),
+			headers: make(map[string]*payloadTemplate, len(config.Headers)),
+			capture: make(map[string]jsonPath, len(config.Capture)),
+		}
+		if step.method == "" {
+			step.method = RawDefaultMethod
+		}
+		var err error
+		path := strings.ReplaceAll(config.Path, rawMountVariable, mountName)
+		if step.path, err = parseVarsTemplate(path, s.rows, vars); err != nil {
+			return nil, fmt.Errorf("invalid path of step %v: %v", config.Name, err)
+		}
+		// Results are matched to the step by the text of its path before
+		// its first template function
+		if i := strings.Index(path, "{{"); i >= 0 {
+			path = path[:i]
+		}
+		step.pathPrefix = "/v1/" + strings.TrimPrefix(path, "/")
+		if step.body, err = parseVarsTemplate(strings.ReplaceAll(config.Body, rawMountVariable, mountName), s.rows, vars); err != nil {
+			return nil, fmt.Errorf("invalid body of step %v: %v", config.Name, err)
+		}
+		for name, value := range config.Headers {
+			if step.headers[name], err = parseVarsTemplate(value, s.rows, vars); err != nil {
+				return nil, fmt.Errorf("invalid header %v of step %v: %v", name, config.Name, err)
+			}
+		}
+		for name, path := range config.Capture {
+			if step.capture[name], err = parseJSONPath(path); err != nil {
+				return nil, fmt.Errorf("invalid capture %v of step %v: %v", name, config.Name, err)
+			}
+		}
+		// Variables are captured once the step has been sent, so only the
+		// steps after it may use them
+		for name := range config.Capture {
+			vars[name] = true
+		}
+		steps[i] = step
+	}
+	return steps, nil
+}
+
+func (s *ScenarioTest) Files() []string {
+	return s.config.DataFile.files()
+}
+
+func (s *ScenarioTest) Setup(client *api.Client, mountName string, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
+	for _, req := range s.config.Setup {
+		if err := sendRawRequest(client, req, mountName); err != nil {
+			return nil, fmt.Errorf("error setting up scenario test: %v", err)
+		}
+	}
+	steps, err := s.parseSteps(mountName)
+	if err != nil {
+		return nil, err
+	}
+
+	scenarioIDs.Lock()
+	scenarioIDs.next++
+	id := scenarioIDs.next
+	scenarioIDs.Unlock()
+
+	return &ScenarioTest{
+		config:  s.config,
+		rows:    s.rows,
+		steps:   steps,
+		id:      id,
+		mount:   mountName,
+		header:  generateHeader(client),
+		waiting: make(map[int64]*scenarioUser),
+	}, nil
+}
+
+// Target returns the next step of a virtual user which is ready to send
+// it, starting a new user when all of them are waiting on a response
+func (s *ScenarioTest) Target(client *api.Client) vegeta.Target {
+	s.lock.Lock()
+	var u *scenarioUser
+	if n := len(s.ready); n > 0 {
+		u = s.ready[n-1]
+		s.ready = s.ready[:n-1]
+	} else {
+		s.users++
+		u = &scenarioUser{id: s.users}
+		s.restart(u)
+	}
+	s.waiting[u.id] = u
+	s.lock.Unlock()
+
+	// Only the user's own goroutine touches it until its response arrives
+	step := s.steps[u.step]
+	r := &payloadRender{seq: u.seq, row: u.row, vars: u.vars}
+	header := s.header.Clone()
+	for name, t := range step.headers {
+		header.Set(name, t.render(r))
+	}
+	header.Set(scenarioHeader, fmt.Sprintf("%d/%d/%d", s.id, u.id, u.step))
+	target := vegeta.Target{
+		Method: step.method,
+		URL:    client.Address() + "/v1/" + strings.TrimPrefix(step.path.render(r), "/"),
+		Header: header,
+	}
+	if body := step.body.render(r); body != "" {
+		target.Body = []byte(body)
+	}
+	return target
+}
+
+// restart starts a new run through the steps for the user, with the next
+// row of the data file. The lock must be held.
+func (s *ScenarioTest) restart(u *scenarioUser) {
+	s.iteration++
+	u.step = 0
+	u.seq = s.iteration
+	u.vars = make(map[string]string)
+	u.row = nil
+	if s.rows != nil {
+		u.row = s.rows.row(s.iteration)
+	}
+}
+
+// response hands the response to a step back to the user which sent it,
+// capturing its variables and moving the user on to the next step, or
+// back to the first when the step failed or was the last
+func (s *ScenarioTest) response(userID int64, stepIndex, code int, body []byte, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	u, ok := s.waiting[userID]
+	if !ok || u.step != stepIndex {
+		return
+	}
+	delete(s.waiting, userID)
+	defer func() { s.ready = append(s.ready, u) }()
+
+	step := s.steps[stepIndex]
+	if err == nil && code >= 400 {
+		err = fmt.Errorf("status %d", code)
+	}
+	if err == nil && len(step.capture) > 0 {
+		err = step.captureVars(body, u.vars)
+	}
+	if err != nil {
+		if targetLogger != nil {
+			targetLogger.Debug("scenario step failed, starting over", "step", step.name, "user", userID, "error", err)
+		}
+		s.restart(u)
+		return
+	}
+	u.step++
+	if u.step == len(s.steps) {
+		s.restart(u)
+	}
+}
+
+// captureVars sets the variables the step captures from the JSON body of
+// its response, failing when any of them isn't found. Strings, numbers
+// and bools are captured as they are, and other values as JSON.
+func (step *scenarioStep) captureVars(body []byte, vars map[string]string) error {
+	var decoded interface{}
+	d := json.NewDecoder(bytes.NewReader(body))
+	d.UseNumber()
+	if err := d.Decode(&decoded); err != nil {
+		return fmt.Errorf("response isn't JSON: %v", err)
+	}
+	names := make([]string, 0, len(step.capture))
+	for name := range step.capture {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, found := step.capture[name].lookup(decoded, true)
+		if !found || value == nil {
+			return fmt.Errorf("capture %v not found in response", name)
+		}
+		if scalar, ok := jsonScalar(value); ok {
+			vars[name] = scalar
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("error encoding capture %v: %v", name, err)
+		}
+		vars[name] = string(encoded)
+	}
+	return nil
+}
+
+func (s *ScenarioTest) Cleanup(client *api.Client) error {
+	for _, req := range s.config.Cleanup {
+		if err := sendRawRequest(client, req, s.mount); err != nil {
+			return fmt.Errorf("error cleaning up scenario test: %v", err)
+		}
+	}
+	return nil
+}
+
+// Operation names the step of a request by its method and path, preferring
+// the step whose path prefix is longest when several match
+func (s *ScenarioTest) Operation(method, path string) string {
+	op, longest := "", -1
+	for _, step := range s.steps {
+		if method == step.method && strings.HasPrefix(path, step.pathPrefix) && len(step.pathPrefix) > longest {
+			op, longest = step.name, len(step.pathPrefix)
+		}
+	}
+	return op
+}
+
+func (s *ScenarioTest) GetTargetInfo() TargetInfo {
+	// The prefix shared by the paths of all steps, which the results of
+	// every step start with
+	prefix := ""
+	for i, step := range s.steps {
+		if i == 0 {
+			prefix = step.pathPrefix
+			continue
+		}
+		for !strings.HasPrefix(step.pathPrefix, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return TargetInfo{
+		method:     ScenarioTestMethod,
+		pathPrefix: prefix,
+	}
+}
+
+func (s *ScenarioTest) Flags(fs *flag.FlagSet) {}
+
+// scenarios returns the scenario tests among the targets, by id
+func (tm TargetMulti) scenarios() map[int64]*ScenarioTest {
+	var scenarios map[int64]*ScenarioTest
+	for _, target := range tm.targets {
+		s, ok := target.Builder.(*ScenarioTest)
+		if !ok {
+			continue
+		}
+		if scenarios == nil {
+			scenarios = make(map[int64]*ScenarioTest)
+		}
+		scenarios[s.id] = s
+	}
+	return scenarios
+}
+
+// scenarioTransport hands the responses to the steps of scenarios back to
+// the virtual users which sent them
+type scenarioTransport struct {
+	base      http.RoundTripper
+	scenarios map[int64]*ScenarioTest
+}
+
+func (t *scenarioTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	value := req.Header.Get(scenarioHeader)
+	if value == "" {
+		return t.base.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Del(scenarioHeader)
+
+	var ids [3]int64
+	parts := strings.Split(value, "/")
+	for i := 0; i < len(ids) && i < len(parts); i++ {
+		ids[i], _ = strconv.ParseInt(parts[i], 10, 64)
+	}
+	s, ok := t.scenarios[ids[0]]
+	if !ok || len(parts) != len(ids) {
+		return t.base.RoundTrip(req)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		s.response(ids[1], int(ids[2]), 0, nil, err)
+		return nil, err
+	}
+	// The body is read here to capture from it, and handed on to be read
+	// again by the attack
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	s.response(ids[1], int(ids[2]), resp.StatusCode, body, err)
+	return resp, nil
+}
@@ -0,0 +1,183 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/openbao/openbao/api/v2"
+)
+
+const testScenario = `
+config {
+  setup {
+    method = "POST"
+    path   = "sys/auth/{{mount}}"
+    body   = "{\"type\": \"approle\"}"
+  }
+  cleanup {
+    method = "DELETE"
+    path   = "sys/auth/{{mount}}"
+  }
+
+  step "role_id" {
+    path    = "auth/{{mount}}/role/bench/role-id"
+    capture = { role_id = "$.data.role_id" }
+  }
+  step "secret_id" {
+    method  = "POST"
+    path    = "auth/{{mount}}/role/bench/secret-id"
+    capture = { secret_id = "data.secret_id" }
+  }
+  step "login" {
+    method  = "POST"
+    path    = "auth/{{mount}}/login"
+    body    = "{\"role_id\": \"{{var role_id}}\", \"secret_id\": \"{{var secret_id}}\"}"
+    capture = { token = "auth.client_token", policies = "auth.policies" }
+  }
+  step "read" {
+    path    = "secret/data/app-{{seq}}"
+    headers = { "X-Vault-Token" = "{{var token}}", "X-Policies" = "{{var policies}}" }
+  }
+}
+`
+
+func TestScenarioTest(t *testing.T) {
+	var lock sync.Mutex
+	secretIDs := 0
+	logins := make(map[string]bool)
+	var errors []string
+	fail := func(format string, args ...interface{}) {
+		lock.Lock()
+		errors = append(errors, fmt.Sprintf(format, args...))
+		lock.Unlock()
+	}
+	var reads, failedLogins int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(scenarioHeader) != "" {
+			fail("the scenario header was sent")
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case strings.HasPrefix(req.URL.Path, "/v1/sys/auth/"):
+			w.WriteHeader(http.StatusNoContent)
+		case strings.HasSuffix(req.URL.Path, "/role-id"):
+			_, _ = w.Write([]byte(`{"data": {"role_id": "role-1"}}`))
+		case strings.HasSuffix(req.URL.Path, "/secret-id"):
+			secretIDs++
+			fmt.Fprintf(w, `{"data": {"secret_id": "secret-%d"}}`, secretIDs)
+		case strings.HasSuffix(req.URL.Path, "/login"):
+			var body map[string]string
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			// Logins with every tenth secret ID fail, which starts the user over
+			if body["role_id"] != "role-1" || !strings.HasPrefix(body["secret_id"], "secret-") || strings.HasSuffix(body["secret_id"], "0") {
+				failedLogins++
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			token := "token-" + body["secret_id"]
+			logins[token] = true
+			fmt.Fprintf(w, `{"auth": {"client_token": %q, "policies": ["default"]}}`, token)
+		case strings.HasPrefix(req.URL.Path, "/v1/secret/data/app-"):
+			if !logins[req.Header.Get("X-Vault-Token")] || req.Header.Get("X-Policies") != `["default"]` {
+				errors = append(errors, fmt.Sprintf("read with unexpected headers: %v", req.Header))
+			}
+			reads++
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			errors = append(errors, "unexpected request "+req.Method+" "+req.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+
+	file, diags := hclparse.NewParser().ParseHCL([]byte(testScenario), "test.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("err: %v", diags)
+	}
+	builder := TestList[ScenarioTestType]()
+	if err := builder.ParseConfig(file.Body); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	logger := hclog.NewNullLogger()
+	tests := []*BenchmarkTarget{{Name: "approle_flow", Type: ScenarioTestType, Weight: 100, Builder: builder}}
+	tm, err := BuildTargets(client, tests, &logger, &TopLevelTargetConfig{})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if tm.targets[0].Method != ScenarioTestMethod || tm.targets[0].PathPrefix != "/v1/" {
+		t.Fatalf("unexpected target: %+v", tm.targets[0])
+	}
+
+	rpt, err := Attack(tm, client, &AttackConfig{Duration: 500 * time.Millisecond, RPS: 200, Workers: 4})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if err := tm.Cleanup(client); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(errors) > 0 {
+		t.Fatalf("unexpected requests:\n%v", strings.Join(errors, "\n"))
+	}
+	if reads == 0 || failedLogins == 0 {
+		t.Fatalf("expected users to get through the steps, and to start over after failed logins, got %d reads and %d failed logins", reads, failedLogins)
+	}
+
+	// Every step is reported on its own
+	ops := rpt.opMetrics["approle_flow"]
+	for _, step := range []string{"role_id", "secret_id", "login", "read"} {
+		if ops[step] == nil || ops[step].Requests == 0 {
+			t.Fatalf("expected results of step %v, got %v", step, ops)
+		}
+	}
+	if ops["read"].Requests != uint64(reads) || ops["login"].Success == 1 || ops["role_id"].Success != 1 {
+		t.Fatalf("unexpected results of steps: %+v %+v", ops["read"], ops["login"])
+	}
+	if rpt.metrics["approle_flow"].Requests != rpt.metrics["total"].Requests {
+		t.Fatal("expected every result to be matched to the scenario")
+	}
+}
+
+func TestScenarioTest_ParseConfig(t *testing.T) {
+	cases := map[string]string{
+		"config {\n}\n": "at least one step is required",
+		"config {\n  step \"a\" {\n    path = \"x/{{var token}}\"\n  }\n}\n":                                                                  "variable token isn't captured by an earlier step",
+		"config {\n  step \"a\" {\n    path = \"x\"\n    body = \"{{var token}}\"\n    capture = { token = \"auth.client_token\" }\n  }\n}\n": "variable token isn't captured",
+		"config {\n  step \"a\" {\n    path = \"x\"\n  }\n  step \"a\" {\n    path = \"y\"\n  }\n}\n":                                         "step a is defined more than once",
+		"config {\n  step \"a\" {\n    path = \"x\"\n    capture = { token = \"auth..client_token\" }\n  }\n}\n":                              "invalid capture token of step a: empty key",
+		"config {\n  step \"a\" {\n    path = \"x\"\n    headers = { \"X-Token\" = \"{{nope}}\" }\n  }\n}\n":                                  "invalid header X-Token of step a",
+	}
+	for config, expected := range cases {
+		file, diags := hclparse.NewParser().ParseHCL([]byte(config), "test.hcl")
+		if diags.HasErrors() {
+			t.Fatalf("err: %v", diags)
+		}
+		if err := TestList[ScenarioTestType]().ParseConfig(file.Body); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got: %v", expected, err)
+		}
+	}
+
+	// Variables may only be used by the steps of scenarios
+	if _, err := parsePayloadTemplate("{{var token}}", nil); err == nil || !strings.Contains(err.Error(), "may only be used in the steps of a scenario") {
+		t.Errorf("expected var to be rejected outside scenarios, got: %v", err)
+	}
+}
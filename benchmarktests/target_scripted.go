@@ -0,0 +1,467 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+const (
+	ScriptedTestType = "scripted"
+
+	// scriptTargetSteps is the number of steps each call of the target
+	// function of a script may take, so a script can't stall the attack
+	scriptTargetSteps = 100000
+)
+
+func init() {
+	// "Register" this test to the main test registry
+	TestList[ScriptedTestType] = func() BenchmarkBuilder { return &ScriptedTest{} }
+}
+
+// ScriptedTest is a test whose setup, requests and cleanup are defined by
+// the functions of a Starlark script
+type ScriptedTest struct {
+	config  *ScriptedTestConfig
+	globals starlark.StringDict
+	params  starlark.Value
+
+	mount      string
+	method     string
+	pathPrefix string
+	state      starlark.Value
+	requests   atomic.Int64
+}
+
+type ScriptedTestConfig struct {
+	Script string    `hcl:"script"`
+	Params cty.Value `hcl:"params,optional"`
+}
+
+func (s *ScriptedTest) ParseConfig(body hcl.Body) error {
+	testConfig := &struct {
+		Config *ScriptedTestConfig `hcl:"config,block"`
+	}{
+		Config: &ScriptedTestConfig{},
+	}
+
+	diags := gohcl.DecodeBody(body, nil, testConfig)
+	if diags.HasErrors() {
+		return fmt.Errorf("error decoding to struct: %v", diags)
+	}
+	s.config = testConfig.Config
+	if s.config.Script == "" {
+		return fmt.Errorf("script is required")
+	}
+
+	s.params = starlark.None
+	if !s.config.Params.IsNull() {
+		encoded, err := ctyjson.Marshal(s.config.Params, s.config.Params.Type())
+		if err != nil {
+			return fmt.Errorf("error encoding params: %v", err)
+		}
+		var params interface{}
+		if err := json.Unmarshal(encoded, &params); err != nil {
+			return fmt.Errorf("error decoding params: %v", err)
+		}
+		s.params = toStarlark(params)
+		s.params.Freeze()
+	}
+
+	src, err := os.ReadFile(s.config.Script)
+	if err != nil {
+		return fmt.Errorf("error reading script: %v", err)
+	}
+	s.globals, err = starlark.ExecFile(scriptThread("load", nil), s.config.Script, src, scriptBuiltins)
+	if err != nil {
+		return fmt.Errorf("error loading script %v: %v", s.config.Script, scriptError(err))
+	}
+	s.globals.Freeze()
+	for _, name := range []string{"setup", "target", "cleanup"} {
+		fn, ok := s.globals[name]
+		if !ok {
+			if name == "cleanup" {
+				continue
+			}
+			return fmt.Errorf("script %v must define a %v function", s.config.Script, name)
+		}
+		if _, ok := fn.(starlark.Callable); !ok {
+			return fmt.Errorf("%v of script %v must be a function", name, s.config.Script)
+		}
+	}
+	return nil
+}
+
+func (s *ScriptedTest) Files() []string {
+	return []string{s.config.Script}
+}
+
+func (s *ScriptedTest) Setup(client *api.Client, mountName string, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
+	ctx := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"mount":     starlark.String(mountName),
+		"params":    s.params,
+		"namespace": starlark.String(client.Namespace()),
+		"duration":  starlark.String(topLevelConfig.Duration.String()),
+	})
+	result, err := starlark.Call(scriptThread("setup", client), s.globals["setup"], starlark.Tuple{ctx}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up scripted test: %v", scriptError(err))
+	}
+	dict, ok := result.(*starlark.Dict)
+	if !ok {
+		return nil, fmt.Errorf("setup must return a dict, not %v", result.Type())
+	}
+	method, _ := scriptString(dict, "method")
+	pathPrefix, _ := scriptString(dict, "path_prefix")
+	if method == "" || pathPrefix == "" {
+		return nil, fmt.Errorf("setup must return the method and path_prefix of the test")
+	}
+	state, found, err := dict.Get(starlark.String("state"))
+	if err != nil || !found {
+		state = starlark.None
+	}
+	state.Freeze()
+
+	return &ScriptedTest{
+		config:     s.config,
+		globals:    s.globals,
+		params:     s.params,
+		mount:      mountName,
+		method:     strings.ToUpper(method),
+		pathPrefix: "/v1/" + strings.TrimPrefix(pathPrefix, "/"),
+		state:      state,
+	}, nil
+}
+
+func (s *ScriptedTest) Target(client *api.Client) vegeta.Target {
+	ctx := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"mount":  starlark.String(s.mount),
+		"params": s.params,
+		"state":  s.state,
+		"n":      starlark.MakeInt64(s.requests.Add(1)),
+	})
+	thread := scriptThread("target", nil)
+	thread.SetMaxExecutionSteps(scriptTargetSteps)
+	result, err := starlark.Call(thread, s.globals["target"], starlark.Tuple{ctx}, nil)
+	if err == nil {
+		var target vegeta.Target
+		if target, err = s.scriptTarget(client, result); err == nil {
+			return target
+		}
+	}
+	// There is no way to fail a single target, so send the request to a
+	// path the results of which show the failure
+	targetLogger.Error("error getting target of scripted test", "script", s.config.Script, "error", scriptError(err))
+	return vegeta.Target{Method: s.method, URL: client.Address() + s.pathPrefix, Header: generateHeader(client)}
+}
+
+// scriptTarget builds the request described by the dict a call of the
+// target function of a script returned
+func (s *ScriptedTest) scriptTarget(client *api.Client, result starlark.Value) (vegeta.Target, error) {
+	dict, ok := result.(*starlark.Dict)
+	if !ok {
+		return vegeta.Target{}, fmt.Errorf("target must return a dict, not %v", result.Type())
+	}
+	path, err := scriptString(dict, "path")
+	if err != nil {
+		return vegeta.Target{}, err
+	}
+	if path == "" {
+		return vegeta.Target{}, fmt.Errorf("target must return the path of the request")
+	}
+	method, err := scriptString(dict, "method")
+	if err != nil {
+		return vegeta.Target{}, err
+	}
+	if method == "" {
+		method = s.method
+	}
+	target := vegeta.Target{
+		Method: strings.ToUpper(method),
+		URL:    client.Address() + "/v1/" + strings.TrimPrefix(path, "/"),
+		Header: generateHeader(client),
+	}
+
+	if headers, found, _ := dict.Get(starlark.String("headers")); found {
+		headers, ok := headers.(*starlark.Dict)
+		if !ok {
+			return vegeta.Target{}, fmt.Errorf("headers must be a dict")
+		}
+		for _, item := range headers.Items() {
+			name, ok1 := starlark.AsString(item[0])
+			value, ok2 := starlark.AsString(item[1])
+			if !ok1 || !ok2 {
+				return vegeta.Target{}, fmt.Errorf("headers must map strings to strings")
+			}
+			target.Header.Set(name, value)
+		}
+	}
+	if body, found, _ := dict.Get(starlark.String("body")); found && body != starlark.None {
+		if str, ok := starlark.AsString(body); ok {
+			target.Body = []byte(str)
+		} else {
+			value, err := fromStarlark(body)
+			if err != nil {
+				return vegeta.Target{}, fmt.Errorf("error encoding body: %v", err)
+			}
+			if target.Body, err = json.Marshal(value); err != nil {
+				return vegeta.Target{}, fmt.Errorf("error encoding body: %v", err)
+			}
+		}
+	}
+	return target, nil
+}
+
+func (s *ScriptedTest) Cleanup(client *api.Client) error {
+	cleanup, ok := s.globals["cleanup"]
+	if !ok {
+		return nil
+	}
+	ctx := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"mount":  starlark.String(s.mount),
+		"params": s.params,
+		"state":  s.state,
+	})
+	if _, err := starlark.Call(scriptThread("cleanup", client), cleanup, starlark.Tuple{ctx}, nil); err != nil {
+		return fmt.Errorf("error cleaning up scripted test: %v", scriptError(err))
+	}
+	return nil
+}
+
+func (s *ScriptedTest) GetTargetInfo() TargetInfo {
+	return TargetInfo{
+		method:     s.method,
+		pathPrefix: s.pathPrefix,
+	}
+}
+
+func (s *ScriptedTest) Flags(fs *flag.FlagSet) {}
+
+// scriptBuiltins are the names predeclared in scripts, besides the
+// built-ins of the language
+var scriptBuiltins = starlark.StringDict{
+	"json": starlarkjson.Module,
+	"vault": &starlarkstruct.Module{
+		Name: "vault",
+		Members: starlark.StringDict{
+			"read":   starlark.NewBuiltin("vault.read", scriptVaultRequest),
+			"list":   starlark.NewBuiltin("vault.list", scriptVaultRequest),
+			"write":  starlark.NewBuiltin("vault.write", scriptVaultRequest),
+			"delete": starlark.NewBuiltin("vault.delete", scriptVaultRequest),
+		},
+	},
+	"rand_int": starlark.NewBuiltin("rand_int", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var n int
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &n); err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("%v: n must be greater than 0", fn.Name())
+		}
+		return starlark.MakeInt(rand.Intn(n)), nil
+	}),
+	"uuid": starlark.NewBuiltin("uuid", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+			return nil, err
+		}
+		id, err := uuid.GenerateUUID()
+		if err != nil {
+			return nil, err
+		}
+		return starlark.String(id), nil
+	}),
+}
+
+// scriptThread returns a thread to call the functions of a script with.
+// Only threads of setup and cleanup are given a client, which the vault
+// functions send their requests with. Scripts can't load other files.
+func scriptThread(name string, client *api.Client) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(thread *starlark.Thread, msg string) {
+			if targetLogger != nil {
+				targetLogger.Debug("script", "function", thread.Name, "msg", msg)
+			}
+		},
+	}
+	if client != nil {
+		thread.SetLocal("client", client)
+	}
+	return thread
+}
+
+// scriptVaultRequest sends the request of a vault function of a script,
+// returning the data of the response, or None when there is none
+func scriptVaultRequest(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	client, ok := thread.Local("client").(*api.Client)
+	if !ok {
+		return nil, fmt.Errorf("%v may only be called from setup and cleanup", fn.Name())
+	}
+	var path string
+	var data *starlark.Dict
+	if fn.Name() == "vault.write" {
+		if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "path", &path, "data?", &data); err != nil {
+			return nil, err
+		}
+	} else if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "path", &path); err != nil {
+		return nil, err
+	}
+
+	var secret *api.Secret
+	var err error
+	switch fn.Name() {
+	case "vault.read":
+		secret, err = client.Logical().Read(path)
+	case "vault.list":
+		secret, err = client.Logical().List(path)
+	case "vault.delete":
+		secret, err = client.Logical().Delete(path)
+	case "vault.write":
+		body := map[string]interface{}{}
+		if data != nil {
+			value, err := fromStarlark(data)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", fn.Name(), err)
+			}
+			body = value.(map[string]interface{})
+		}
+		secret, err = client.Logical().Write(path, body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v %v: %v", fn.Name(), path, err)
+	}
+	if secret == nil || secret.Data == nil {
+		return starlark.None, nil
+	}
+	// Round trip the data through JSON so its numbers are plain
+	encoded, err := json.Marshal(secret.Data)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(encoded, &value); err != nil {
+		return nil, err
+	}
+	return toStarlark(value), nil
+}
+
+// scriptString returns the string value of a key of a dict returned by a
+// script, or "" when it isn't set
+func scriptString(dict *starlark.Dict, key string) (string, error) {
+	value, found, _ := dict.Get(starlark.String(key))
+	if !found || value == starlark.None {
+		return "", nil
+	}
+	str, ok := starlark.AsString(value)
+	if !ok {
+		return "", fmt.Errorf("%v must be a string, not %v", key, value.Type())
+	}
+	return str, nil
+}
+
+// scriptError includes the backtrace of errors raised by scripts
+func scriptError(err error) error {
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		return fmt.Errorf("%v", evalErr.Backtrace())
+	}
+	return err
+}
+
+// toStarlark converts a value decoded from JSON to a Starlark value
+func toStarlark(value interface{}) starlark.Value {
+	switch v := value.(type) {
+	case bool:
+		return starlark.Bool(v)
+	case float64:
+		if v == float64(int64(v)) {
+			return starlark.MakeInt64(int64(v))
+		}
+		return starlark.Float(v)
+	case string:
+		return starlark.String(v)
+	case []interface{}:
+		elems := make([]starlark.Value, len(v))
+		for i, elem := range v {
+			elems[i] = toStarlark(elem)
+		}
+		return starlark.NewList(elems)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			_ = dict.SetKey(starlark.String(key), toStarlark(v[key]))
+		}
+		return dict
+	default:
+		return starlark.None
+	}
+}
+
+// fromStarlark converts a Starlark value to one which can be encoded as
+// JSON
+func fromStarlark(value starlark.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		if i, ok := v.Int64(); ok {
+			return i, nil
+		}
+		return nil, fmt.Errorf("int %v is too large", v)
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Indexable:
+		elems := make([]interface{}, v.Len())
+		for i := range elems {
+			elem, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return elems, nil
+	case *starlark.Dict:
+		m := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("keys of dicts must be strings, not %v", item[0].Type())
+			}
+			elem, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[key] = elem
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("cannot encode %v", value.Type())
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/openbao/openbao/api/v2"
+)
+
+const testScript = `
+def setup(ctx):
+    vault.write("sys/mounts/" + ctx.mount, {"type": "kv", "options": {"version": "2"}})
+    for i in range(ctx.params["numkvs"]):
+        vault.write(ctx.mount + "/data/secret-%d" % i, {"data": {"foo": "bar"}})
+    keys = vault.list(ctx.mount + "/metadata")["keys"]
+    return {"method": "GET", "path_prefix": ctx.mount, "state": {"keys": keys}}
+
+def target(ctx):
+    keys = ctx.state["keys"]
+    key = keys[(ctx.n - 1) % len(keys)]
+    if ctx.n % 2 == 0:
+        return {"method": "POST", "path": ctx.mount + "/data/" + key, "body": {"data": {"n": ctx.n}}}
+    return {"path": ctx.mount + "/data/" + key, "headers": {"X-Scripted": "yes"}}
+
+def cleanup(ctx):
+    vault.delete("sys/mounts/" + ctx.mount)
+`
+
+func TestScriptedTest(t *testing.T) {
+	var lock sync.Mutex
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		lock.Lock()
+		requests = append(requests, req.Method+" "+req.URL.Path+" "+strings.TrimSpace(string(body)))
+		lock.Unlock()
+		if req.URL.Query().Get("list") == "true" {
+			_, _ = w.Write([]byte(`{"data": {"keys": ["secret-0", "secret-1"]}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetLogger = hclog.NewNullLogger()
+
+	dir := t.TempDir()
+	writeScript := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	parse := func(config string) (BenchmarkBuilder, error) {
+		file, diags := hclparse.NewParser().ParseHCL([]byte(config), "test.hcl")
+		if diags.HasErrors() {
+			t.Fatalf("err: %v", diags)
+		}
+		builder := TestList[ScriptedTestType]()
+		return builder, builder.ParseConfig(file.Body)
+	}
+
+	script := writeScript("kv.star", testScript)
+	builder, err := parse("config {\n  script = \"" + script + "\"\n  params = { numkvs = 2 }\n}\n")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	test, err := builder.Setup(client, "scripted-kv", &TopLevelTargetConfig{Duration: time.Second})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if info := test.GetTargetInfo(); info.method != "GET" || info.pathPrefix != "/v1/scripted-kv" {
+		t.Fatalf("unexpected target info: %+v", info)
+	}
+
+	tgt := test.Target(client)
+	if tgt.Method != "GET" || tgt.URL != srv.URL+"/v1/scripted-kv/data/secret-0" || tgt.Header.Get("X-Scripted") != "yes" || tgt.Body != nil {
+		t.Fatalf("unexpected target: %+v", tgt)
+	}
+	tgt = test.Target(client)
+	var body map[string]map[string]int
+	if err := json.Unmarshal(tgt.Body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if tgt.Method != "POST" || tgt.URL != srv.URL+"/v1/scripted-kv/data/secret-1" || body["data"]["n"] != 2 {
+		t.Fatalf("unexpected target: %+v %s", tgt, tgt.Body)
+	}
+
+	if err := test.Cleanup(client); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	expected := []string{
+		`PUT /v1/sys/mounts/scripted-kv {"options":{"version":"2"},"type":"kv"}`,
+		`PUT /v1/scripted-kv/data/secret-0 {"data":{"foo":"bar"}}`,
+		`PUT /v1/scripted-kv/data/secret-1 {"data":{"foo":"bar"}}`,
+		`GET /v1/scripted-kv/metadata `,
+		`DELETE /v1/sys/mounts/scripted-kv `,
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected requests:\n%v", strings.Join(requests, "\n"))
+	}
+
+	// Scripts can't stall the attack or reach OpenBao from target, and
+	// failed targets are sent to the path prefix of the test
+	for name, src := range map[string]string{
+		"loop.star":  "def setup(ctx):\n    return {\"method\": \"GET\", \"path_prefix\": ctx.mount}\n\ndef target(ctx):\n    for i in range(1000000):\n        pass\n",
+		"vault.star": "def setup(ctx):\n    return {\"method\": \"GET\", \"path_prefix\": ctx.mount}\n\ndef target(ctx):\n    return {\"path\": vault.read(\"secret/foo\")}\n",
+	} {
+		builder, err := parse("config {\n  script = \"" + writeScript(name, src) + "\"\n}\n")
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		test, err := builder.Setup(client, "scripted-fail", &TopLevelTargetConfig{Duration: time.Second})
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if tgt := test.Target(client); tgt.URL != srv.URL+"/v1/scripted-fail" {
+			t.Errorf("%v: expected the target to fail, got %+v", name, tgt)
+		}
+	}
+
+	for src, expected := range map[string]string{
+		"def target(ctx):\n    pass\n":                                     "must define a setup function",
+		"setup = 1\ndef target(ctx):\n    pass\n":                          "setup of script",
+		"load(\"other.star\", \"x\")\n":                                    "load not implemented",
+		"def setup(ctx):\n    pass\n\ndef target(ctx):\n    pass\n\nx = y": "undefined: y",
+	} {
+		if _, err := parse("config {\n  script = \"" + writeScript("invalid.star", src) + "\"\n}\n"); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got: %v", expected, err)
+		}
+	}
+	if _, err := parse("config {\n  script = \"" + filepath.Join(dir, "missing.star") + "\"\n}\n"); err == nil {
+		t.Error("expected a missing script to be rejected")
+	}
+	builder, err = parse("config {\n  script = \"" + writeScript("nosetup.star", "def setup(ctx):\n    return {}\n\ndef target(ctx):\n    pass\n") + "\"\n}\n")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if _, err := builder.Setup(client, "scripted-fail", &TopLevelTargetConfig{}); err == nil || !strings.Contains(err.Error(), "method and path_prefix") {
+		t.Errorf("expected setup without a method to fail, got: %v", err)
+	}
+}
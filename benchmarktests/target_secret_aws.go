@@ -103,6 +103,10 @@ func (a *AWSTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (a *AWSTest) EnvVars() []string {
+	return []string{AWSSecretAccessKey, AWSSecretSecretKey}
+}
+
 func (a *AWSTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: AWSSecretTestMethod,
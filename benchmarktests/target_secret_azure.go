@@ -102,6 +102,16 @@ func (a *AzureTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (a *AzureTest) EnvVars() []string {
+	return []string{
+		AzureSecretSubscriptionID,
+		AzureSecretTenantID,
+		AzureSecretClientID,
+		AzureSecretClientSecret,
+		AzureSecretEnvironment,
+	}
+}
+
 func (a *AzureTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: "GET",
@@ -129,6 +129,10 @@ func (c *CassandraSecret) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (c *CassandraSecret) EnvVars() []string {
+	return []string{CassandraDBUsernameEnvVar, CassandraDBPasswordEnvVar}
+}
+
 func (c *CassandraSecret) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: CassandraSecretTestMethod,
@@ -100,6 +100,10 @@ func (c *ConsulTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (c *ConsulTest) EnvVars() []string {
+	return []string{ConsulTokenEnvVar}
+}
+
 func (c *ConsulTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: "GET",
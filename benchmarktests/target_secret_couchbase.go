@@ -112,6 +112,10 @@ func (c *CouchbaseSecretTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (c *CouchbaseSecretTest) EnvVars() []string {
+	return []string{CouchbaseUsernameEnvVar, CouchbasePasswordEnvVar}
+}
+
 func (c *CouchbaseSecretTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: CouchbaseSecretTestMethod,
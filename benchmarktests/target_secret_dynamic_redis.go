@@ -93,6 +93,10 @@ func (r *RedisDynamicSecret) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (r *RedisDynamicSecret) EnvVars() []string {
+	return []string{RedisDynamicSecretDBUsernameEnvVar, RedisDynamicSecretDBPasswordEnvVar}
+}
+
 func (r *RedisDynamicSecret) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: RedisDynamicSecretTestMethod,
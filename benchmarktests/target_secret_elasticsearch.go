@@ -115,6 +115,10 @@ func (e *ElasticSearchTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (e *ElasticSearchTest) EnvVars() []string {
+	return []string{ElasticSearchUsernameEnvVar, ElasticSearchPasswordEnvVar}
+}
+
 func (e *ElasticSearchTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: ElasticSearchSecretTestMethod,
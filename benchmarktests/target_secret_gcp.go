@@ -92,6 +92,10 @@ func (g *GCPTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (g *GCPTest) EnvVars() []string {
+	return []string{GCPSecretCredentials, GCPSecretBindings}
+}
+
 func (g *GCPTest) Target(client *api.Client) vegeta.Target {
 	var url string
 
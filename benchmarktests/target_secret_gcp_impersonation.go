@@ -78,6 +78,10 @@ func (g *GCPImpersonationTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (g *GCPImpersonationTest) EnvVars() []string {
+	return []string{GCPSecretCredentials, GCPImpersonationSecretServiceAccountEmail}
+}
+
 func (g *GCPImpersonationTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: "GET",
@@ -7,7 +7,6 @@ import (
 	"flag"
 	"fmt"
 	"log"
-	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
@@ -50,11 +49,25 @@ type KVV1Test struct {
 	numKVs     int
 	kvSize     int
 	logger     hclog.Logger
+	keys       keySelector
+	payloads   *payloadGenerator
+	rows       *dataRows
+
+	// shared is set when the test runs against the mount of another test
+	shared bool
+
+	// seeded are the keys of the secrets seeded from the key template,
+	// which tests sharing the mount pick from instead of the numkvs keys
+	seeded []string
 }
 
 type KVV1SecretTestConfig struct {
-	KVSize int `hcl:"kvsize,optional"`
-	NumKVs int `hcl:"numkvs,optional"`
+	KVSize          int                    `hcl:"kvsize,optional"`
+	NumKVs          int                    `hcl:"numkvs,optional"`
+	KeyDistribution *KeyDistributionConfig `hcl:"key_distribution,block"`
+	Data            map[string]string      `hcl:"data,optional"`
+	Key             string                 `hcl:"key,optional"`
+	DataFile        *DataFileConfig        `hcl:"data_file,block"`
 }
 
 func (k *KVV1Test) ParseConfig(body hcl.Body) error {
@@ -71,15 +84,26 @@ func (k *KVV1Test) ParseConfig(body hcl.Body) error {
 	if diags.HasErrors() {
 		return fmt.Errorf("error decoding to struct: %v", diags)
 	}
+	rows, err := loadDataFile(testConfig.Config.DataFile)
+	if err != nil {
+		return err
+	}
+	if testConfig.Config.Key != "" && rows == nil {
+		return fmt.Errorf("key requires a data_file, whose rows the keys seeded are rendered for")
+	}
+	if _, err := newDataPayloads(testConfig.Config.Key, testConfig.Config.Data, rows); err != nil {
+		return err
+	}
 	k.config = testConfig.Config
+	k.rows = rows
 	return nil
 }
 
 func (k *KVV1Test) read(client *api.Client) vegeta.Target {
-	secnum := int(1 + rand.Int31n(int32(k.numKVs)))
+	p := k.payload()
 	return vegeta.Target{
 		Method: KVV1ReadTestMethod,
-		URL:    client.Address() + k.pathPrefix + "/secret-" + strconv.Itoa(secnum),
+		URL:    client.Address() + k.pathPrefix + "/" + p.key,
 		Header: k.header,
 	}
 }
@@ -93,16 +117,34 @@ func (k *KVV1Test) list(client *api.Client) vegeta.Target {
 }
 
 func (k *KVV1Test) write(client *api.Client) vegeta.Target {
-	secnum := int(1 + rand.Int31n(int32(k.numKVs)))
-	value := strings.Repeat("a", k.kvSize)
+	p := k.payload()
 	return vegeta.Target{
 		Method: KVV1WriteTestMethod,
-		URL:    client.Address() + k.pathPrefix + "/secret-" + strconv.Itoa(secnum),
-		Body:   []byte(`{"data": {"foo": "` + value + `"}}`),
+		URL:    client.Address() + k.pathPrefix + "/" + p.key,
+		Body:   p.body,
 		Header: k.header,
 	}
 }
 
+// payload returns the key and body of the next request, those rendered
+// from the config for the request or else one of the keys seeded in the
+// mount and a value of kvsize bytes
+func (k *KVV1Test) payload() payload {
+	var p payload
+	if k.payloads != nil {
+		p = k.payloads.next()
+	}
+	if p.key == "" && k.seeded != nil {
+		p.key = k.seeded[k.keys.next()]
+	} else if p.key == "" {
+		p.key = "secret-" + strconv.Itoa(1+k.keys.next())
+	}
+	if p.body == nil && k.action != "read" {
+		p.body = []byte(`{"data": {"foo": "` + strings.Repeat("a", k.kvSize) + `"}}`)
+	}
+	return p
+}
+
 func (k *KVV1Test) Target(client *api.Client) vegeta.Target {
 	switch k.action {
 	case "write":
@@ -131,6 +173,10 @@ func (k *KVV1Test) GetTargetInfo() TargetInfo {
 }
 
 func (k *KVV1Test) Cleanup(client *api.Client) error {
+	if k.shared {
+		// The mount is removed by the test which created it
+		return nil
+	}
 	k.logger.Trace(cleanupLogMessage(k.pathPrefix))
 	_, err := client.Logical().Delete(strings.Replace(k.pathPrefix, "/v1/", "/sys/mounts/", 1))
 	if err != nil {
@@ -139,11 +185,24 @@ func (k *KVV1Test) Cleanup(client *api.Client) error {
 	return nil
 }
 
+func (k *KVV1Test) Files() []string {
+	return k.config.DataFile.files()
+}
+
 func (k *KVV1Test) Setup(client *api.Client, mountName string, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
 	var err error
 	mountPath := mountName
 	k.logger = targetLogger.Named("kvv1")
 
+	keys, err := newKeySelector(k.config.KeyDistribution, k.config.NumKVs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key_distribution: %v", err)
+	}
+	payloads, err := newDataPayloads(k.config.Key, k.config.Data, k.rows)
+	if err != nil {
+		return nil, err
+	}
+
 	if topLevelConfig.RandomMounts {
 		mountPath, err = uuid.GenerateUUID()
 		if err != nil {
@@ -168,12 +227,20 @@ func (k *KVV1Test) Setup(client *api.Client, mountName string, topLevelConfig *T
 	}
 
 	setupLogger.Trace("seeding secrets")
-	for i := 1; i <= k.config.NumKVs; i++ {
-		_, err = client.Logical().Write(mountPath+"/secret-"+strconv.Itoa(i), secval)
+	seedKeys, err := kvSeedKeys(k.config.Key, k.rows, k.config.NumKVs)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range seedKeys {
+		_, err = client.Logical().Write(mountPath+"/"+key, secval)
 		if err != nil {
 			return nil, fmt.Errorf("error writing kvv1 secret: %v", err)
 		}
 	}
+	var seeded []string
+	if k.config.Key != "" {
+		seeded = seedKeys
+	}
 
 	headers := http.Header{"X-Vault-Token": []string{client.Token()}, "X-Vault-Namespace": []string{client.Headers().Get("X-Vault-Namespace")}}
 	return &KVV1Test{
@@ -183,6 +250,47 @@ func (k *KVV1Test) Setup(client *api.Client, mountName string, topLevelConfig *T
 		numKVs:     k.config.NumKVs,
 		kvSize:     k.config.KVSize,
 		logger:     k.logger,
+		keys:       keys,
+		payloads:   payloads,
+		seeded:     seeded,
+	}, nil
+}
+
+// SetupShared configures the test to run against the mount, and the secrets
+// seeded in it, of another KVv1 test
+func (k *KVV1Test) SetupShared(client *api.Client, owner BenchmarkBuilder, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
+	ownerTest, ok := owner.(*KVV1Test)
+	if !ok {
+		return nil, fmt.Errorf("can only share the mount of another KVv1 test")
+	}
+
+	// Only the secrets the owner seeded exist, so keys are picked from
+	// those whatever numkvs this test sets
+	numSeeded := ownerTest.numKVs
+	if ownerTest.seeded != nil {
+		numSeeded = len(ownerTest.seeded)
+	}
+	keys, err := newKeySelector(k.config.KeyDistribution, numSeeded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key_distribution: %v", err)
+	}
+	payloads, err := newDataPayloads(k.config.Key, k.config.Data, k.rows)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := http.Header{"X-Vault-Token": []string{client.Token()}, "X-Vault-Namespace": []string{client.Headers().Get("X-Vault-Namespace")}}
+	return &KVV1Test{
+		pathPrefix: ownerTest.pathPrefix,
+		action:     k.action,
+		header:     headers,
+		numKVs:     ownerTest.numKVs,
+		kvSize:     k.config.KVSize,
+		logger:     targetLogger.Named("kvv1"),
+		keys:       keys,
+		payloads:   payloads,
+		shared:     true,
+		seeded:     ownerTest.seeded,
 	}, nil
 }
 
@@ -25,6 +25,7 @@ const (
 	KVV2ReadTestType    = "kvv2_read"
 	KVV2ListTestType    = "kvv2_list"
 	KVV2WriteTestType   = "kvv2_write"
+	KVV2MixedTestType   = "kvv2_mixed"
 	KVV2ReadTestMethod  = "GET"
 	KVV2ListTestMethod  = "LIST"
 	KVV2WriteTestMethod = "POST"
@@ -42,6 +43,9 @@ func init() {
 	TestList[KVV2ListTestType] = func() BenchmarkBuilder {
 		return &KVV2Test{action: "list"}
 	}
+	TestList[KVV2MixedTestType] = func() BenchmarkBuilder {
+		return &KVV2Test{action: "mixed"}
+	}
 }
 
 type KVV2Test struct {
@@ -52,13 +56,29 @@ type KVV2Test struct {
 	numKVs     int
 	kvSize     int
 	detailed   bool
+	readRatio  float64
 	logger     hclog.Logger
+	keys       keySelector
+	payloads   *payloadGenerator
+	rows       *dataRows
+
+	// shared is set when the test runs against the mount of another test
+	shared bool
+
+	// seeded are the keys of the secrets seeded from the key template,
+	// which tests sharing the mount pick from instead of the numkvs keys
+	seeded []string
 }
 
 type KVV2SecretTestConfig struct {
-	KVSize   int  `hcl:"kvsize,optional"`
-	NumKVs   int  `hcl:"numkvs,optional"`
-	Detailed bool `hcl:"detailed,optional"`
+	KVSize          int                    `hcl:"kvsize,optional"`
+	NumKVs          int                    `hcl:"numkvs,optional"`
+	Detailed        bool                   `hcl:"detailed,optional"`
+	ReadPercent     float64                `hcl:"read_percent,optional"`
+	KeyDistribution *KeyDistributionConfig `hcl:"key_distribution,block"`
+	Data            map[string]string      `hcl:"data,optional"`
+	Key             string                 `hcl:"key,optional"`
+	DataFile        *DataFileConfig        `hcl:"data_file,block"`
 }
 
 func (k *KVV2Test) ParseConfig(body hcl.Body) error {
@@ -66,9 +86,10 @@ func (k *KVV2Test) ParseConfig(body hcl.Body) error {
 		Config *KVV2SecretTestConfig `hcl:"config,block"`
 	}{
 		Config: &KVV2SecretTestConfig{
-			KVSize:   1,
-			NumKVs:   1000,
-			Detailed: false,
+			KVSize:      1,
+			NumKVs:      1000,
+			Detailed:    false,
+			ReadPercent: 90,
 		},
 	}
 
@@ -76,15 +97,29 @@ func (k *KVV2Test) ParseConfig(body hcl.Body) error {
 	if diags.HasErrors() {
 		return fmt.Errorf("error decoding to struct: %v", diags)
 	}
+	if testConfig.Config.ReadPercent < 0 || testConfig.Config.ReadPercent > 100 {
+		return fmt.Errorf("read_percent must be between 0 and 100")
+	}
+	rows, err := loadDataFile(testConfig.Config.DataFile)
+	if err != nil {
+		return err
+	}
+	if testConfig.Config.Key != "" && rows == nil {
+		return fmt.Errorf("key requires a data_file, whose rows the keys seeded are rendered for")
+	}
+	if _, err := newDataPayloads(testConfig.Config.Key, testConfig.Config.Data, rows); err != nil {
+		return err
+	}
 	k.config = testConfig.Config
+	k.rows = rows
 	return nil
 }
 
 func (k *KVV2Test) read(client *api.Client) vegeta.Target {
-	secnum := int(1 + rand.Int31n(int32(k.numKVs)))
+	p := k.payload()
 	return vegeta.Target{
 		Method: "GET",
-		URL:    client.Address() + k.pathPrefix + "/data/secret-" + strconv.Itoa(secnum),
+		URL:    client.Address() + k.pathPrefix + "/data/" + p.key,
 		Header: k.header,
 	}
 }
@@ -103,27 +138,65 @@ func (k *KVV2Test) list(client *api.Client) vegeta.Target {
 }
 
 func (k *KVV2Test) write(client *api.Client) vegeta.Target {
-	secnum := int(1 + rand.Int31n(int32(k.numKVs)))
-	value := strings.Repeat("a", k.kvSize)
+	p := k.payload()
 	return vegeta.Target{
 		Method: "POST",
-		URL:    client.Address() + k.pathPrefix + "/data/secret-" + strconv.Itoa(secnum),
+		URL:    client.Address() + k.pathPrefix + "/data/" + p.key,
 		Header: k.header,
-		Body:   []byte(`{"data": {"foo": "` + value + `"}}`),
+		Body:   p.body,
 	}
 }
 
+// payload returns the key and body of the next request, those rendered
+// from the config for the request or else one of the keys seeded in the
+// mount and a value of kvsize bytes
+func (k *KVV2Test) payload() payload {
+	var p payload
+	if k.payloads != nil {
+		p = k.payloads.next()
+	}
+	if p.key == "" && k.seeded != nil {
+		p.key = k.seeded[k.keys.next()]
+	} else if p.key == "" {
+		p.key = "secret-" + strconv.Itoa(1+k.keys.next())
+	}
+	if p.body == nil && k.action != "read" {
+		p.body = []byte(`{"data": {"foo": "` + strings.Repeat("a", k.kvSize) + `"}}`)
+	}
+	return p
+}
+
 func (k *KVV2Test) Target(client *api.Client) vegeta.Target {
 	switch k.action {
 	case "write":
 		return k.write(client)
 	case "list":
 		return k.list(client)
+	case "mixed":
+		if rand.Float64() < k.readRatio {
+			return k.read(client)
+		}
+		return k.write(client)
 	default:
 		return k.read(client)
 	}
 }
 
+// Operation names the reads and writes of the mixed test, so they are
+// reported separately
+func (k *KVV2Test) Operation(method, path string) string {
+	if k.action != "mixed" || !strings.HasPrefix(path, k.pathPrefix+"/data/") {
+		return ""
+	}
+	switch method {
+	case KVV2ReadTestMethod:
+		return "read"
+	case KVV2WriteTestMethod:
+		return "write"
+	}
+	return ""
+}
+
 func (k *KVV2Test) GetTargetInfo() TargetInfo {
 	var method string
 	switch k.action {
@@ -141,6 +214,10 @@ func (k *KVV2Test) GetTargetInfo() TargetInfo {
 }
 
 func (k *KVV2Test) Cleanup(client *api.Client) error {
+	if k.shared {
+		// The mount is removed by the test which created it
+		return nil
+	}
 	k.logger.Trace(cleanupLogMessage(k.pathPrefix))
 	_, err := client.Logical().Delete(strings.Replace(k.pathPrefix, "/v1/", "/sys/mounts/", 1))
 	if err != nil {
@@ -149,6 +226,10 @@ func (k *KVV2Test) Cleanup(client *api.Client) error {
 	return nil
 }
 
+func (k *KVV2Test) Files() []string {
+	return k.config.DataFile.files()
+}
+
 func (k *KVV2Test) Setup(client *api.Client, mountName string, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
 	var err error
 	mountPath := mountName
@@ -157,10 +238,21 @@ func (k *KVV2Test) Setup(client *api.Client, mountName string, topLevelConfig *T
 		k.logger = targetLogger.Named(KVV2WriteTestType)
 	case "list":
 		k.logger = targetLogger.Named(KVV2ListTestType)
+	case "mixed":
+		k.logger = targetLogger.Named(KVV2MixedTestType)
 	default:
 		k.logger = targetLogger.Named(KVV2ReadTestType)
 	}
 
+	keys, err := newKeySelector(k.config.KeyDistribution, k.config.NumKVs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key_distribution: %v", err)
+	}
+	payloads, err := newDataPayloads(k.config.Key, k.config.Data, k.rows)
+	if err != nil {
+		return nil, err
+	}
+
 	if topLevelConfig.RandomMounts {
 		mountPath, err = uuid.GenerateUUID()
 		if err != nil {
@@ -203,12 +295,20 @@ func (k *KVV2Test) Setup(client *api.Client, mountName string, topLevelConfig *T
 	}
 
 	setupLogger.Trace("seeding secrets")
-	for i := 1; i <= k.config.NumKVs; i++ {
-		_, err = client.Logical().Write(mountPath+"/data/secret-"+strconv.Itoa(i), secval)
+	seedKeys, err := kvSeedKeys(k.config.Key, k.rows, k.config.NumKVs)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range seedKeys {
+		_, err = client.Logical().Write(mountPath+"/data/"+key, secval)
 		if err != nil {
 			return nil, fmt.Errorf("error writing kv secret: %v", err)
 		}
 	}
+	var seeded []string
+	if k.config.Key != "" {
+		seeded = seedKeys
+	}
 
 	return &KVV2Test{
 		pathPrefix: "/v1/" + mountPath,
@@ -216,8 +316,51 @@ func (k *KVV2Test) Setup(client *api.Client, mountName string, topLevelConfig *T
 		numKVs:     k.config.NumKVs,
 		kvSize:     k.config.KVSize,
 		detailed:   k.config.Detailed,
+		readRatio:  k.config.ReadPercent / 100,
 		logger:     k.logger,
+		keys:       keys,
+		payloads:   payloads,
+		action:     k.action,
+		seeded:     seeded,
+	}, nil
+}
+
+// SetupShared configures the test to run against the mount, and the secrets
+// seeded in it, of another KVv2 test
+func (k *KVV2Test) SetupShared(client *api.Client, owner BenchmarkBuilder, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
+	ownerTest, ok := owner.(*KVV2Test)
+	if !ok {
+		return nil, fmt.Errorf("can only share the mount of another KVv2 test")
+	}
+
+	// Only the secrets the owner seeded exist, so keys are picked from
+	// those whatever numkvs this test sets
+	numSeeded := ownerTest.numKVs
+	if ownerTest.seeded != nil {
+		numSeeded = len(ownerTest.seeded)
+	}
+	keys, err := newKeySelector(k.config.KeyDistribution, numSeeded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key_distribution: %v", err)
+	}
+	payloads, err := newDataPayloads(k.config.Key, k.config.Data, k.rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KVV2Test{
+		pathPrefix: ownerTest.pathPrefix,
+		header:     http.Header{"X-Vault-Token": []string{client.Token()}, "X-Vault-Namespace": []string{client.Headers().Get("X-Vault-Namespace")}},
+		numKVs:     ownerTest.numKVs,
+		kvSize:     k.config.KVSize,
+		detailed:   k.config.Detailed,
+		readRatio:  k.config.ReadPercent / 100,
+		logger:     targetLogger.Named("kvv2_" + k.action),
 		action:     k.action,
+		keys:       keys,
+		payloads:   payloads,
+		shared:     true,
+		seeded:     ownerTest.seeded,
 	}, nil
 }
 
@@ -101,6 +101,10 @@ func (r *LDAPDynamicSecretTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (r *LDAPDynamicSecretTest) EnvVars() []string {
+	return []string{LDAPAuthBindPassEnvVar}
+}
+
 func (r *LDAPDynamicSecretTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: LDAPDynamicSecretTestMethod,
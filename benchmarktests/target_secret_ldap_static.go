@@ -96,6 +96,10 @@ func (r *LDAPStaticSecretTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (r *LDAPStaticSecretTest) EnvVars() []string {
+	return []string{LDAPAuthBindPassEnvVar}
+}
+
 func (r *LDAPStaticSecretTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: LDAPStaticSecretTestMethod,
@@ -108,6 +108,10 @@ func (m *MongoDBTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (m *MongoDBTest) EnvVars() []string {
+	return []string{MongoDBUsernameEnvVar, MongoDBPasswordEnvVar}
+}
+
 func (m *MongoDBTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: "GET",
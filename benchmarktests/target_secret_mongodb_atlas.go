@@ -104,6 +104,10 @@ func (m *MongoDBAtlasTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (m *MongoDBAtlasTest) EnvVars() []string {
+	return []string{MongoDBAtlasPublicKey, MongoDBAtlasPrivateKey}
+}
+
 func (m *MongoDBAtlasTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: "GET",
@@ -118,6 +118,10 @@ func (m *MSSQLSecret) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (m *MSSQLSecret) EnvVars() []string {
+	return []string{MSSQLUsernameEnvVar, MSSQLPasswordEnvVar}
+}
+
 func (m *MSSQLSecret) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: MSSQLSecretTestMethod,
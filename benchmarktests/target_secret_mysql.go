@@ -122,6 +122,10 @@ func (m *MySQLSecret) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (m *MySQLSecret) EnvVars() []string {
+	return []string{MySQLUsernameEnvVar, MySQLPasswordEnvVar}
+}
+
 func (m *MySQLSecret) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: MySQLSecretTestMethod,
@@ -87,6 +87,10 @@ func (c *NomadTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (c *NomadTest) EnvVars() []string {
+	return []string{NomadTokenEnvVar}
+}
+
 func (c *NomadTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: "GET",
@@ -30,6 +30,10 @@ func init() {
 	TestList[PKIIssueTestType] = func() BenchmarkBuilder { return &PKIIssueTest{} }
 }
 
+// pkiPayloadFields are the options of the certificates PKI tests issue and
+// sign which are payload templates, rendered for every request
+var pkiPayloadFields = []string{"common_name", "alt_names"}
+
 type PKIIssueTest struct {
 	pathPrefix string
 	cn         string
@@ -37,6 +41,8 @@ type PKIIssueTest struct {
 	rootpath   string
 	config     *PKISecretIssueTestConfig
 	body       []byte
+	payloads   *payloadGenerator
+	rows       *dataRows
 	header     http.Header
 	logger     hclog.Logger
 }
@@ -48,6 +54,7 @@ type PKISecretIssueTestConfig struct {
 	IntermediateCAConfig  *PKIIssueIntCAConfig  `hcl:"intermediate_ca,block"`
 	RoleConfig            *PKIIssueRoleConfig   `hcl:"role,block"`
 	IssueConfig           *PKIIssueCertConfig   `hcl:"issue,block"`
+	DataFile              *DataFileConfig       `hcl:"data_file,block"`
 }
 
 // PKIIssueCertConfig is the configuration
@@ -257,15 +264,29 @@ func (p *PKIIssueTest) ParseConfig(body hcl.Body) error {
 	if diags.HasErrors() {
 		return fmt.Errorf("error decoding to struct: %v", diags)
 	}
+	rows, err := loadDataFile(testConfig.Config.DataFile)
+	if err != nil {
+		return err
+	}
+	for _, value := range []string{testConfig.Config.IssueConfig.CommonName, testConfig.Config.IssueConfig.AltNames} {
+		if _, err := parsePayloadTemplate(value, rows); err != nil {
+			return fmt.Errorf("invalid issue: %v", err)
+		}
+	}
 	p.config = testConfig.Config
+	p.rows = rows
 	return nil
 }
 
 func (p *PKIIssueTest) Target(client *api.Client) vegeta.Target {
+	body := p.body
+	if p.payloads != nil {
+		body = p.payloads.next().body
+	}
 	return vegeta.Target{
 		Method: PKIIssueTestMethod,
 		URL:    client.Address() + p.pathPrefix,
-		Body:   p.body,
+		Body:   body,
 		Header: p.header,
 	}
 }
@@ -294,6 +315,10 @@ func (p *PKIIssueTest) Cleanup(client *api.Client) error {
 	return nil
 }
 
+func (p *PKIIssueTest) Files() []string {
+	return p.config.DataFile.files()
+}
+
 func (p *PKIIssueTest) Setup(client *api.Client, mountName string, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
 	var err error
 	secretPath := mountName
@@ -326,6 +351,11 @@ func (p *PKIIssueTest) Setup(client *api.Client, mountName string, topLevelConfi
 		return nil, fmt.Errorf("error parsing issue config from struct: %v", err)
 	}
 
+	payloads, err := newBodyPayloads(issueData, pkiPayloadFields, p.rows)
+	if err != nil {
+		return nil, err
+	}
+
 	issueDataString, err := json.Marshal(issueData)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling issue config data: %v", err)
@@ -336,6 +366,7 @@ func (p *PKIIssueTest) Setup(client *api.Client, mountName string, topLevelConfi
 		cn:         p.config.IssueConfig.CommonName,
 		header:     generateHeader(client),
 		body:       []byte(issueDataString),
+		payloads:   payloads,
 		rootpath:   p.rootpath,
 		intpath:    p.intpath,
 		logger:     p.logger,
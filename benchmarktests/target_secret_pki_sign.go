@@ -42,6 +42,8 @@ type PKISignTest struct {
 	rootpath   string
 	config     *pkiSecretIssueTestConfig
 	body       []byte
+	payloads   *payloadGenerator
+	rows       *dataRows
 	header     http.Header
 	logger     hclog.Logger
 }
@@ -53,6 +55,7 @@ type pkiSecretIssueTestConfig struct {
 	IntermediateCAConfig  *pkiSignIntCAConfig  `hcl:"intermediate_ca,block"`
 	RoleConfig            *pkiSignRoleConfig   `hcl:"role,block"`
 	SignConfig            *pkiSignCSRConfig    `hcl:"sign,block"`
+	DataFile              *DataFileConfig      `hcl:"data_file,block"`
 }
 
 // PKISignCertConfig is the configuration
@@ -261,15 +264,29 @@ func (p *PKISignTest) ParseConfig(body hcl.Body) error {
 	if diags.HasErrors() {
 		return fmt.Errorf("error decoding to struct: %v", diags)
 	}
+	rows, err := loadDataFile(testConfig.Config.DataFile)
+	if err != nil {
+		return err
+	}
+	for _, value := range []string{testConfig.Config.SignConfig.CommonName, testConfig.Config.SignConfig.AltNames} {
+		if _, err := parsePayloadTemplate(value, rows); err != nil {
+			return fmt.Errorf("invalid sign: %v", err)
+		}
+	}
 	p.config = testConfig.Config
+	p.rows = rows
 	return nil
 }
 
 func (p *PKISignTest) Target(client *api.Client) vegeta.Target {
+	body := p.body
+	if p.payloads != nil {
+		body = p.payloads.next().body
+	}
 	return vegeta.Target{
 		Method: PKISignTestMethod,
 		URL:    client.Address() + p.pathPrefix,
-		Body:   p.body,
+		Body:   body,
 		Header: p.header,
 	}
 }
@@ -298,6 +315,10 @@ func (p *PKISignTest) Cleanup(client *api.Client) error {
 	return nil
 }
 
+func (p *PKISignTest) Files() []string {
+	return p.config.DataFile.files()
+}
+
 func (p *PKISignTest) Setup(client *api.Client, mountName string, topLevelConfig *TopLevelTargetConfig) (BenchmarkBuilder, error) {
 	var err error
 	secretPath := mountName
@@ -373,6 +394,11 @@ func (p *PKISignTest) Setup(client *api.Client, mountName string, topLevelConfig
 		return nil, fmt.Errorf("error parsing signing config from struct: %v", err)
 	}
 
+	payloads, err := newBodyPayloads(signingData, pkiPayloadFields, p.rows)
+	if err != nil {
+		return nil, err
+	}
+
 	signingDataString, err := json.Marshal(signingData)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling signing config data: %v", err)
@@ -383,6 +409,7 @@ func (p *PKISignTest) Setup(client *api.Client, mountName string, topLevelConfig
 		cn:         p.config.SignConfig.CommonName,
 		header:     generateHeader(client),
 		body:       []byte(signingDataString),
+		payloads:   payloads,
 		rootpath:   p.rootpath,
 		intpath:    p.intpath,
 		logger:     p.logger,
@@ -120,6 +120,10 @@ func (s *PostgreSQLSecret) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (s *PostgreSQLSecret) EnvVars() []string {
+	return []string{PostgreSQLUsernameEnvVar, PostgreSQLPasswordEnvVar}
+}
+
 func (s *PostgreSQLSecret) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: PostgreSQLSecretTestMethod,
@@ -95,6 +95,10 @@ func (r *RabbitMQTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (r *RabbitMQTest) EnvVars() []string {
+	return []string{RabbitMQUsernameEnvVar, RabbitMQPasswordEnvVar}
+}
+
 func (r *RabbitMQTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: RabbitMQSecretTestMethod,
@@ -113,6 +113,10 @@ func (r *RedisStaticSecret) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (r *RedisStaticSecret) EnvVars() []string {
+	return []string{RedisStaticSecretUsernameEnvVar, RedisStaticSecretPasswordEnvVar}
+}
+
 func (r *RedisStaticSecret) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: RedisStaticSecretTestMethod,
@@ -130,6 +130,10 @@ func (t *TransformTokenizationTest) ParseConfig(body hcl.Body) error {
 	return nil
 }
 
+func (t *TransformTokenizationTest) EnvVars() []string {
+	return []string{TransformStoreUsernameEnvVar, TransformStorePasswordEnvVar}
+}
+
 func (t *TransformTokenizationTest) Target(client *api.Client) vegeta.Target {
 	return vegeta.Target{
 		Method: TransformTokenizationTestMethod,
@@ -44,16 +44,24 @@ type TransitTest struct {
 	header     http.Header
 	config     *TransitTestConfig
 	logger     hclog.Logger
+
+	// When the test uses more than one key, each request picks one of
+	// paths, and the matching entry of bodies if the body differs per key
+	paths  []string
+	bodies [][]byte
+	keys   keySelector
 }
 
 type TransitTestConfig struct {
-	PayloadLen           int                   `hcl:"payload_len,optional"`
-	ContextLen           int                   `hcl:"context_len,optional"`
-	TransitConfigKeys    *TransitConfigKeys    `hcl:"keys,block"`
-	TransitConfigSign    *TransitConfigSign    `hcl:"sign,block"`
-	TransitConfigVerify  *TransitConfigVerify  `hcl:"verify,block"`
-	TransitConfigEncrypt *TransitConfigEncrypt `hcl:"encrypt,block"`
-	TransitConfigDecrypt *TransitConfigDecrypt `hcl:"decrypt,block"`
+	PayloadLen           int                    `hcl:"payload_len,optional"`
+	ContextLen           int                    `hcl:"context_len,optional"`
+	NumKeys              int                    `hcl:"num_keys,optional"`
+	KeyDistribution      *KeyDistributionConfig `hcl:"key_distribution,block"`
+	TransitConfigKeys    *TransitConfigKeys     `hcl:"keys,block"`
+	TransitConfigSign    *TransitConfigSign     `hcl:"sign,block"`
+	TransitConfigVerify  *TransitConfigVerify   `hcl:"verify,block"`
+	TransitConfigEncrypt *TransitConfigEncrypt  `hcl:"encrypt,block"`
+	TransitConfigDecrypt *TransitConfigDecrypt  `hcl:"decrypt,block"`
 }
 
 // /transit/keys/:name
@@ -172,14 +180,42 @@ func (t *TransitTest) ParseConfig(body hcl.Body) error {
 }
 
 func (t *TransitTest) Target(client *api.Client) vegeta.Target {
+	if len(t.paths) == 0 {
+		return vegeta.Target{
+			Method: TransitSecretTestMethod,
+			URL:    client.Address() + t.pathPrefix,
+			Body:   t.body,
+			Header: t.header,
+		}
+	}
+
+	i := t.keys.next()
+	body := t.body
+	if len(t.bodies) > 0 {
+		body = t.bodies[i]
+	}
 	return vegeta.Target{
 		Method: TransitSecretTestMethod,
-		URL:    client.Address() + t.pathPrefix,
-		Body:   t.body,
+		URL:    client.Address() + t.paths[i],
+		Body:   body,
 		Header: t.header,
 	}
 }
 
+// transitKeyNames returns the names of the keys used by the test. With a
+// single key the configured name is used as is, otherwise it is suffixed
+// with the number of each key.
+func transitKeyNames(name string, numKeys int) []string {
+	if numKeys <= 1 {
+		return []string{name}
+	}
+	names := make([]string, numKeys)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s-%d", name, i+1)
+	}
+	return names
+}
+
 func (t *TransitTest) Cleanup(client *api.Client) error {
 	parts := strings.Split(t.pathPrefix, "/")
 	t.logger.Trace(cleanupLogMessage(parts[2]))
@@ -229,6 +265,16 @@ func (t *TransitTest) Setup(client *api.Client, mountName string, topLevelConfig
 	}
 
 	setupLogger := t.logger.Named(secretPath)
+
+	numKeys := t.config.NumKeys
+	if numKeys < 1 {
+		numKeys = 1
+	}
+	keys, err := newKeySelector(t.config.KeyDistribution, numKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key_distribution: %v", err)
+	}
+
 	// Generate Keys for testing
 	setupLogger.Trace(parsingConfigLogMessage("transit key"))
 	keysConfigData, err := structToMap(t.config.TransitConfigKeys)
@@ -236,10 +282,12 @@ func (t *TransitTest) Setup(client *api.Client, mountName string, topLevelConfig
 		return nil, fmt.Errorf("error parsing transit key config from struct: %v", err)
 	}
 
-	setupLogger.Trace(writingLogMessage("key config"), "name", t.config.TransitConfigKeys.Name)
-	_, err = client.Logical().Write(filepath.Join(secretPath, "keys", t.config.TransitConfigKeys.Name), keysConfigData)
-	if err != nil {
-		return nil, fmt.Errorf("error writing transit key config: %v", err)
+	for _, keyName := range transitKeyNames(t.config.TransitConfigKeys.Name, numKeys) {
+		setupLogger.Trace(writingLogMessage("key config"), "name", keyName)
+		_, err = client.Logical().Write(filepath.Join(secretPath, "keys", keyName), keysConfigData)
+		if err != nil {
+			return nil, fmt.Errorf("error writing transit key config: %v", err)
+		}
 	}
 
 	// Generate our payload and context
@@ -256,6 +304,11 @@ func (t *TransitTest) Setup(client *api.Client, mountName string, topLevelConfig
 	}
 	base64Context := base64.StdEncoding.EncodeToString(rawContext)
 
+	test := &TransitTest{
+		header: generateHeader(client),
+		logger: t.logger,
+	}
+
 	// Now dispatch the operation.
 	switch t.action {
 	case "sign":
@@ -271,12 +324,8 @@ func (t *TransitTest) Setup(client *api.Client, mountName string, topLevelConfig
 			return nil, fmt.Errorf("error marshaling signing config data: %v", err)
 		}
 
-		return &TransitTest{
-			pathPrefix: "/v1/" + secretPath,
-			header:     generateHeader(client),
-			body:       []byte(signingDataString),
-			logger:     t.logger,
-		}, nil
+		test.pathPrefix = "/v1/" + secretPath
+		test.body = []byte(signingDataString)
 
 	case "verify":
 		setupLogger.Trace(parsingConfigLogMessage("transit verify"))
@@ -285,36 +334,36 @@ func (t *TransitTest) Setup(client *api.Client, mountName string, topLevelConfig
 			return nil, fmt.Errorf("error parsing transit verify config from struct: %v", err)
 		}
 		verifyPath := filepath.Join(secretPath, "verify", t.config.TransitConfigVerify.Name)
-
-		// Sign the payload first
-		setupLogger.Trace("signing payload")
-		resp, err := client.Logical().Write(filepath.Join(secretPath, "sign", t.config.TransitConfigVerify.Name), signData)
-		if err != nil {
-			return nil, fmt.Errorf("error signing payload: %v", err)
+		test.pathPrefix = "/v1/" + verifyPath
+
+		// Each key signs the payload differently, so every key needs its
+		// own request body
+		for _, keyName := range transitKeyNames(t.config.TransitConfigVerify.Name, numKeys) {
+			// Sign the payload first
+			setupLogger.Trace("signing payload", "name", keyName)
+			resp, err := client.Logical().Write(filepath.Join(secretPath, "sign", keyName), signData)
+			if err != nil {
+				return nil, fmt.Errorf("error signing payload: %v", err)
+			}
+
+			if resp == nil || len(resp.Data["signature"].(string)) == 0 {
+				return nil, fmt.Errorf("unable to sign payload: no response or invalid signature: %v", resp)
+			}
+			t.config.TransitConfigVerify.Signature = resp.Data["signature"].(string)
+
+			setupLogger.Trace(parsingConfigLogMessage("transit verify"))
+			verifyData, err := structToMap(t.config.TransitConfigVerify)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing transit verify config from struct: %v", err)
+			}
+
+			verifyDataString, err := json.Marshal(verifyData)
+			if err != nil {
+				return nil, fmt.Errorf("error marshaling transit verify data: %v", err)
+			}
+			test.bodies = append(test.bodies, []byte(verifyDataString))
 		}
-
-		if resp == nil || len(resp.Data["signature"].(string)) == 0 {
-			return nil, fmt.Errorf("unable to sign payload: no response or invalid signature: %v", resp)
-		}
-		t.config.TransitConfigVerify.Signature = resp.Data["signature"].(string)
-
-		setupLogger.Trace(parsingConfigLogMessage("transit verify"))
-		verifyData, err := structToMap(t.config.TransitConfigVerify)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing transit verify config from struct: %v", err)
-		}
-
-		verifyDataString, err := json.Marshal(verifyData)
-		if err != nil {
-			return nil, fmt.Errorf("error marshaling transit verify data: %v", err)
-		}
-
-		return &TransitTest{
-			pathPrefix: "/v1/" + verifyPath,
-			header:     generateHeader(client),
-			body:       []byte(verifyDataString),
-			logger:     t.logger,
-		}, nil
+		test.body = test.bodies[0]
 
 	case "encrypt":
 		if t.config.TransitConfigKeys.Derived {
@@ -334,12 +383,8 @@ func (t *TransitTest) Setup(client *api.Client, mountName string, topLevelConfig
 		}
 
 		encryptPath := filepath.Join(secretPath, "encrypt", t.config.TransitConfigEncrypt.Name)
-		return &TransitTest{
-			pathPrefix: "/v1/" + encryptPath,
-			header:     generateHeader(client),
-			body:       []byte(encryptDataString),
-			logger:     t.logger,
-		}, nil
+		test.pathPrefix = "/v1/" + encryptPath
+		test.body = []byte(encryptDataString)
 
 	case "decrypt":
 		// Encrypt test payload
@@ -352,43 +397,52 @@ func (t *TransitTest) Setup(client *api.Client, mountName string, topLevelConfig
 			testEncryptData["context"] = base64Context
 		}
 
-		setupLogger.Trace("encrypting payload")
-		resp, err := client.Logical().Write(filepath.Join(secretPath, "encrypt", t.config.TransitConfigDecrypt.Name), testEncryptData)
-		if err != nil {
-			return nil, fmt.Errorf("error encrypting payload: %v", err)
-		}
-
-		if resp == nil || resp.Data["ciphertext"] == nil || len(resp.Data["ciphertext"].(string)) == 0 {
-			return nil, fmt.Errorf("unable to encrypt payload: no response or invalid ciphertext: %v", resp)
-		}
-
-		t.config.TransitConfigDecrypt.Ciphertext = resp.Data["ciphertext"].(string)
-
 		// Prepare for decryption
 		decryptPath := filepath.Join(secretPath, "decrypt", t.config.TransitConfigDecrypt.Name)
-
-		setupLogger.Trace(parsingConfigLogMessage("transit decrypt"))
-		decryptData, err := structToMap(t.config.TransitConfigDecrypt)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing transit decrypt config: %v", err)
+		test.pathPrefix = "/v1/" + decryptPath
+
+		// Each key produces its own ciphertext, so every key needs its own
+		// request body
+		for _, keyName := range transitKeyNames(t.config.TransitConfigDecrypt.Name, numKeys) {
+			setupLogger.Trace("encrypting payload", "name", keyName)
+			resp, err := client.Logical().Write(filepath.Join(secretPath, "encrypt", keyName), testEncryptData)
+			if err != nil {
+				return nil, fmt.Errorf("error encrypting payload: %v", err)
+			}
+
+			if resp == nil || resp.Data["ciphertext"] == nil || len(resp.Data["ciphertext"].(string)) == 0 {
+				return nil, fmt.Errorf("unable to encrypt payload: no response or invalid ciphertext: %v", resp)
+			}
+
+			t.config.TransitConfigDecrypt.Ciphertext = resp.Data["ciphertext"].(string)
+
+			setupLogger.Trace(parsingConfigLogMessage("transit decrypt"))
+			decryptData, err := structToMap(t.config.TransitConfigDecrypt)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing transit decrypt config: %v", err)
+			}
+
+			decryptDataString, err := json.Marshal(decryptData)
+			if err != nil {
+				return nil, fmt.Errorf("error marshaling transit decrypt data: %v", err)
+			}
+			test.bodies = append(test.bodies, []byte(decryptDataString))
 		}
-
-		decryptDataString, err := json.Marshal(decryptData)
-		if err != nil {
-			return nil, fmt.Errorf("error marshaling transit decrypt data: %v", err)
-		}
-
-		// Now decrypt it
-		return &TransitTest{
-			pathPrefix: "/v1/" + decryptPath,
-			header:     generateHeader(client),
-			body:       []byte(decryptDataString),
-			logger:     t.logger,
-		}, nil
+		test.body = test.bodies[0]
 
 	default:
 		return nil, fmt.Errorf("unknown or unsupported transit operation: %v", t.action)
 	}
+
+	// Requests are spread over the keys by suffixing the path prefix, which
+	// ends in the configured key name, with the number of each key
+	if numKeys > 1 {
+		test.keys = keys
+		for _, keyName := range transitKeyNames(filepath.Base(test.pathPrefix), numKeys) {
+			test.paths = append(test.paths, filepath.Join(filepath.Dir(test.pathPrefix), keyName))
+		}
+	}
+	return test, nil
 }
 
 func (t *TransitTest) Flags(fs *flag.FlagSet) {}
@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// ValidateHeaders checks the headers of a test can be sent. The token of
+// requests is set with its own options, and headers starting with X-Benchmark-
+// are used internally and removed before requests are sent.
+func ValidateHeaders(headers map[string]string) error {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		canonical := http.CanonicalHeaderKey(name)
+		switch {
+		case name == "" || strings.ContainsAny(name, " \t\r\n:"):
+			return fmt.Errorf("invalid header name %q", name)
+		case strings.ContainsAny(headers[name], "\r\n"):
+			return fmt.Errorf("value of header %v must not contain line breaks", name)
+		case canonical == "X-Vault-Token":
+			return fmt.Errorf("header %v cannot be set, as the token of requests is set with the token or token_source of the test", name)
+		case strings.HasPrefix(canonical, "X-Benchmark-"):
+			return fmt.Errorf("header %v cannot be set, as X-Benchmark- headers are used internally", name)
+		}
+	}
+	return nil
+}
+
+// headersClient returns a copy of client whose requests are sent with the
+// headers of the test, or client itself when the test has none
+func (bt *BenchmarkTarget) headersClient(client *api.Client) (*api.Client, error) {
+	if len(bt.Headers) == 0 {
+		return client, nil
+	}
+	hClient, err := client.CloneWithHeaders()
+	if err != nil {
+		return nil, err
+	}
+	hClient.SetToken(client.Token())
+	headers := hClient.Headers()
+	for name, value := range bt.Headers {
+		headers.Set(name, value)
+	}
+	hClient.SetHeaders(headers)
+	return hClient, nil
+}
+
+// headersTarget returns the targets of a test with its headers set,
+// replacing those the test sets itself
+func headersTarget(headers map[string]string, target func(*api.Client) vegeta.Target) func(*api.Client) vegeta.Target {
+	return func(client *api.Client) vegeta.Target {
+		tgt := target(client)
+		tgt.Header = tgt.Header.Clone()
+		if tgt.Header == nil {
+			tgt.Header = make(http.Header)
+		}
+		for name, value := range headers {
+			tgt.Header.Set(name, value)
+		}
+		return tgt
+	}
+}
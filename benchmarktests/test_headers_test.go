@@ -0,0 +1,83 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestTestHeaders(t *testing.T) {
+	var lock sync.Mutex
+	var requests []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		lock.Lock()
+		requests = append(requests, req.Header.Clone())
+		lock.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+	client.SetNamespace("admin")
+
+	builder := TestList["userpass_auth"]()
+	if err := builder.ParseConfig(hcl.EmptyBody()); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	headers := map[string]string{"X-Trace-Id": "run-1", "X-Vault-Namespace": "admin/team-a"}
+	tests := []*BenchmarkTarget{{Name: "logins", Type: "userpass_auth", Weight: 100, Builder: builder, Headers: headers}}
+	logger := hclog.NewNullLogger()
+	tm, err := BuildTargets(client, tests, &logger, &TopLevelTargetConfig{RandomMounts: true})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(requests) == 0 {
+		t.Fatal("expected the test to be set up")
+	}
+	for _, h := range requests {
+		if h.Get("X-Trace-Id") != "run-1" || h.Get("X-Vault-Token") != "root" {
+			t.Fatalf("expected setup requests with the headers of the test and the token, got %v", h)
+		}
+		if ns := h.Values("X-Vault-Namespace"); len(ns) != 1 || ns[0] != "admin/team-a" {
+			t.Fatalf("expected the namespace of the client to be replaced, got %v", ns)
+		}
+	}
+	if client.Headers().Get("X-Trace-Id") != "" {
+		t.Fatal("expected the headers of the client to be left alone")
+	}
+
+	tgt := tm.targets[0].Target(client)
+	if tgt.Header.Get("X-Trace-Id") != "run-1" || tgt.Header.Get("X-Vault-Namespace") != "admin/team-a" {
+		t.Fatalf("expected attack requests with the headers of the test, got %v", tgt.Header)
+	}
+
+	cases := []struct {
+		headers map[string]string
+		err     string
+	}{
+		{map[string]string{"X Trace": "1"}, "invalid header name"},
+		{map[string]string{"X-Trace-Id": "1\r\nX-Other: 2"}, "line breaks"},
+		{map[string]string{"x-vault-token": "other"}, "token of requests"},
+		{map[string]string{"X-Benchmark-TLS-Test": "logins"}, "X-Benchmark- headers"},
+	}
+	for _, tc := range cases {
+		if err := ValidateHeaders(tc.headers); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("expected error containing %q, got %v", tc.err, err)
+		}
+	}
+}
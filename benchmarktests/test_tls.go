@@ -0,0 +1,164 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+// testTLSHeader names the test whose TLS settings a request is sent with.
+// It is set on the targets of tests with a tls block and removed before the
+// request is sent.
+const testTLSHeader = "X-Benchmark-TLS-Test"
+
+// TLSConfig configures the TLS client of the requests of a single test,
+// independently of the VAULT_CACERT, VAULT_CLIENT_CERT, VAULT_CLIENT_KEY,
+// VAULT_TLS_SERVER_NAME and VAULT_SKIP_VERIFY environment variables, such
+// as to present a client certificate to cert auth or to verify a listener
+// certificate issued for a single node. Settings which aren't given are
+// kept from the environment, except for tls_skip_verify.
+type TLSConfig struct {
+	CACert     string `hcl:"ca_cert,optional"`
+	ClientCert string `hcl:"client_cert,optional"`
+	ClientKey  string `hcl:"client_key,optional"`
+	ServerName string `hcl:"tls_server_name,optional"`
+	SkipVerify bool   `hcl:"tls_skip_verify,optional"`
+}
+
+// Validate checks the certificates and key can be loaded
+func (c *TLSConfig) Validate() error {
+	if (c.ClientCert == "") != (c.ClientKey == "") {
+		return fmt.Errorf("client_cert and client_key must be given together")
+	}
+	_, err := c.apply(nil)
+	return err
+}
+
+// apply returns a copy of base with the settings applied
+func (c *TLSConfig) apply(base *tls.Config) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+	}
+	if c.CACert != "" {
+		pem, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ca_cert: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in ca_cert %v", c.CACert)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.ClientCert != "" {
+		keyPair, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client_cert and client_key: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{keyPair}
+		cfg.GetClientCertificate = nil
+	}
+	if c.ServerName != "" {
+		cfg.ServerName = c.ServerName
+	}
+	cfg.InsecureSkipVerify = c.SkipVerify
+	return cfg, nil
+}
+
+// transport returns a copy of base sending requests with the settings
+// applied
+func (c *TLSConfig) transport(base *http.Transport) (*http.Transport, error) {
+	tlsConfig, err := c.apply(base.TLSClientConfig)
+	if err != nil {
+		return nil, err
+	}
+	t := base.Clone()
+	t.TLSClientConfig = tlsConfig
+	return t, nil
+}
+
+// client returns a copy of client whose requests are sent with the TLS
+// settings and headers of the test, or client itself when the test has
+// neither
+func (bt *BenchmarkTarget) client(client *api.Client) (*api.Client, error) {
+	if bt.TLS == nil {
+		return bt.headersClient(client)
+	}
+	cfg := client.CloneConfig()
+	base, ok := cfg.HttpClient.Transport.(*http.Transport)
+	if !ok {
+		return nil, fmt.Errorf("tls block of test %v cannot be applied to the transport of the client", bt.Name)
+	}
+	transport, err := bt.TLS.transport(base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls block of test %v: %v", bt.Name, err)
+	}
+	cfg.HttpClient.Transport = transport
+	tClient, err := api.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tClient.SetToken(client.Token())
+	tClient.SetNamespace(client.Namespace())
+	return bt.headersClient(tClient)
+}
+
+// tlsTarget returns the targets of the test named by the test's TLS
+// settings, so they are sent with them
+func tlsTarget(name string, target func(*api.Client) vegeta.Target) func(*api.Client) vegeta.Target {
+	return func(client *api.Client) vegeta.Target {
+		tgt := target(client)
+		tgt.Header = tgt.Header.Clone()
+		if tgt.Header == nil {
+			tgt.Header = make(http.Header)
+		}
+		tgt.Header.Set(testTLSHeader, name)
+		return tgt
+	}
+}
+
+// testTLS returns the TLS settings of the targets which have their own,
+// by name
+func (tm TargetMulti) testTLS() map[string]*TLSConfig {
+	var configs map[string]*TLSConfig
+	for _, target := range tm.targets {
+		if target.TLS == nil {
+			continue
+		}
+		if configs == nil {
+			configs = make(map[string]*TLSConfig)
+		}
+		configs[target.Name] = target.TLS
+	}
+	return configs
+}
+
+// testTLSTransport sends the requests of tests with their own TLS settings
+// with the transport of the test, and all others with base
+type testTLSTransport struct {
+	base  http.RoundTripper
+	tests map[string]http.RoundTripper
+}
+
+func (t *testTLSTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	name := req.Header.Get(testTLSHeader)
+	if name == "" {
+		return t.base.RoundTrip(req)
+	}
+	transport, ok := t.tests[name]
+	if !ok {
+		transport = t.base
+	}
+	req = req.Clone(req.Context())
+	req.Header.Del(testTLSHeader)
+	return transport.RoundTrip(req)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestTestTLS(t *testing.T) {
+	ca, err := GenerateCA()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, clientKey, err := GenerateCert(ca.Template, ca.Signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM([]byte(ca.PEM))
+
+	var tagged bool
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tagged = tagged || req.Header.Get(testTLSHeader) != ""
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	srv.StartTLS()
+	defer srv.Close()
+
+	dir := t.TempDir()
+	write := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	tlsConfig := &TLSConfig{
+		CACert:     write("ca.pem", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))),
+		ClientCert: write("client.pem", clientCert),
+		ClientKey:  write("client-key.pem", clientKey),
+	}
+	if err := tlsConfig.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := &CertAuth{pathPrefix: "/v1/auth/cert"}
+	withTLS := BenchmarkTarget{Name: "login", Builder: builder, TLS: tlsConfig}
+	withTLS.ConfigureTarget(client)
+	without := BenchmarkTarget{Name: "other", Builder: builder}
+	without.ConfigureTarget(client)
+	tm := &TargetMulti{targets: []BenchmarkTarget{withTLS, without}}
+
+	send := func(target BenchmarkTarget) error {
+		tgt := target.Target(client)
+		req, err := tgt.Request()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := attackClient(client, &AttackConfig{testTLS: tm.testTLS()}).Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	if err := send(withTLS); err != nil {
+		t.Fatalf("expected the request to be sent with the test's certificates, got %v", err)
+	}
+	if tagged {
+		t.Fatal("expected the test's header to be removed before sending")
+	}
+	if err := send(without); err == nil {
+		t.Fatal("expected the request of a test without TLS settings to be sent with the client's")
+	}
+
+	testClient, err := withTLS.client(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := testClient.Logical().Read("sys/health"); err != nil {
+		t.Fatalf("expected the test to be set up with its certificates, got %v", err)
+	}
+
+	if err := (&TLSConfig{ClientCert: tlsConfig.ClientCert}).Validate(); err == nil {
+		t.Fatal("expected a client certificate without a key to be rejected")
+	}
+	if _, err := (&TLSConfig{CACert: filepath.Join(dir, "missing.pem")}).apply(nil); err == nil {
+		t.Fatal("expected a missing CA certificate to be rejected")
+	}
+}
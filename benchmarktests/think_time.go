@@ -0,0 +1,100 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	// FixedThinkTime always waits exactly the configured think time
+	FixedThinkTime = "fixed"
+
+	// UniformThinkTime waits a random time between zero and twice the
+	// configured think time
+	UniformThinkTime = "uniform"
+
+	// ExponentialThinkTime draws the wait from an exponential distribution
+	// whose mean is the configured think time
+	ExponentialThinkTime = "exponential"
+)
+
+// thinkTimer decides how long a closed loop worker waits after each
+// request. Each step of a workload, i.e. each target, may have its own
+// think time; the global one is used for the rest.
+type thinkTimer struct {
+	distribution string
+	global       time.Duration
+	addrs        []string
+	nodes        *NodeSet
+	targets      []thinkTarget
+}
+
+type thinkTarget struct {
+	method     string
+	pathPrefix string
+	mean       time.Duration
+}
+
+func newThinkTimer(tm *TargetMulti, addrs []string, config *AttackConfig) (*thinkTimer, error) {
+	distribution := config.ThinkTimeDistribution
+	switch distribution {
+	case FixedThinkTime, UniformThinkTime, ExponentialThinkTime:
+	case "":
+		distribution = FixedThinkTime
+	default:
+		return nil, fmt.Errorf("unknown think time distribution: %v", distribution)
+	}
+
+	t := &thinkTimer{distribution: distribution, global: config.ThinkTime, addrs: addrs}
+	for _, target := range tm.targets {
+		mean, err := target.ThinkTimeDuration()
+		if err != nil {
+			return nil, fmt.Errorf("invalid think_time for %v: %w", target.Name, err)
+		}
+		if target.ThinkTime == "" {
+			continue
+		}
+		t.targets = append(t.targets, thinkTarget{
+			method:     target.Method,
+			pathPrefix: target.PathPrefix,
+			mean:       mean,
+		})
+	}
+	return t, nil
+}
+
+// after returns the time to wait after the passed in result before the
+// worker sends its next request
+func (t *thinkTimer) after(res *vegeta.Result) time.Duration {
+	mean := t.global
+	addrs := t.addrs
+	if t.nodes != nil {
+		addrs = t.nodes.Known()
+	}
+	_, path := splitAddr(res.URL, addrs)
+	for _, target := range t.targets {
+		if res.Method == target.method && strings.HasPrefix(path, target.pathPrefix) {
+			mean = target.mean
+			break
+		}
+	}
+	if mean <= 0 {
+		return 0
+	}
+
+	switch t.distribution {
+	case UniformThinkTime:
+		return time.Duration(rand.Int63n(int64(2 * mean)))
+	case ExponentialThinkTime:
+		return time.Duration(rand.ExpFloat64() * float64(mean))
+	default:
+		return mean
+	}
+}
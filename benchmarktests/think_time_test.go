@@ -0,0 +1,95 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestThinkTimer_PerTarget(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "login", Method: "POST", PathPrefix: "/v1/auth/userpass/login"},
+		{Name: "read", Method: "GET", PathPrefix: "/v1/secret/data", ThinkTime: "2s"},
+	}}
+	think, err := newThinkTimer(tm, []string{"http://vault:8200"}, &AttackConfig{ThinkTime: time.Second})
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+
+	login := &vegeta.Result{Method: "POST", URL: "http://vault:8200/v1/auth/userpass/login/user"}
+	if got := think.after(login); got != time.Second {
+		t.Fatalf("expected the global think time after login, got: %v", got)
+	}
+	read := &vegeta.Result{Method: "GET", URL: "http://vault:8200/v1/secret/data/foo"}
+	if got := think.after(read); got != 2*time.Second {
+		t.Fatalf("expected the test's own think time after read, got: %v", got)
+	}
+}
+
+func TestThinkTimer_Distributions(t *testing.T) {
+	const samples = 10000
+	mean := 100 * time.Millisecond
+	res := &vegeta.Result{}
+
+	for _, distribution := range []string{UniformThinkTime, ExponentialThinkTime} {
+		think, err := newThinkTimer(&TargetMulti{}, nil, &AttackConfig{ThinkTime: mean, ThinkTimeDistribution: distribution})
+		if err != nil {
+			t.Fatalf("expected no error, got: %v", err)
+		}
+
+		var total time.Duration
+		for i := 0; i < samples; i++ {
+			wait := think.after(res)
+			if wait < 0 || (distribution == UniformThinkTime && wait >= 2*mean) {
+				t.Fatalf("%v: think time %v out of range", distribution, wait)
+			}
+			total += wait
+		}
+		if got := total / samples; got < 90*time.Millisecond || got > 110*time.Millisecond {
+			t.Fatalf("%v: expected a mean think time near %v, got: %v", distribution, mean, got)
+		}
+	}
+
+	if _, err := newThinkTimer(&TargetMulti{}, nil, &AttackConfig{ThinkTimeDistribution: "gaussian"}); err == nil {
+		t.Fatal("expected an error for an unknown distribution")
+	}
+}
+
+func TestClosedLoopAttack_StopDuringThinkTime(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	config := &AttackConfig{Workers: 2, ThinkTime: time.Hour}
+	think, err := newThinkTimer(&TargetMulti{}, nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := func(tgt *vegeta.Target) error {
+		*tgt = vegeta.Target{Method: "GET", URL: srv.URL}
+		return nil
+	}
+	stop := make(chan struct{})
+	results := closedLoopAttack([]*http.Client{srv.Client()}, tr, nil, think, config, stop)
+	for i := 0; i < config.Workers; i++ {
+		<-results
+	}
+
+	// Workers waiting out their think time end as soon as they are stopped
+	close(stop)
+	select {
+	case _, ok := <-results:
+		if ok {
+			t.Fatal("expected no more results once stopped")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the attack to end once stopped")
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"time"
+)
+
+// DefaultTimeseriesInterval is the length of each point of the time series
+// included in JSON reports
+const DefaultTimeseriesInterval = 10 * time.Second
+
+// TimeseriesPoint summarizes the results of a test which completed during
+// one interval of the attack
+type TimeseriesPoint struct {
+	Start      time.Time     `json:"start"`
+	Requests   uint64        `json:"requests"`
+	Throughput float64       `json:"throughput"`
+	ErrorRate  float64       `json:"error_rate"`
+	Mean       time.Duration `json:"mean"`
+	P50        time.Duration `json:"50th"`
+	P90        time.Duration `json:"90th"`
+	P95        time.Duration `json:"95th"`
+	P99        time.Duration `json:"99th"`
+	Max        time.Duration `json:"max"`
+
+	// Events are the chaos events which happened during the interval
+	Events []string `json:"events,omitempty"`
+}
+
+// timeseries collects the interval reports of an attack into a series of
+// points for every test. It is only written to by a single reporter, so
+// isn't safe for concurrent use.
+type timeseries struct {
+	points map[string][]TimeseriesPoint
+}
+
+func (ts *timeseries) Write(report *IntervalReport) error {
+	for name, m := range report.Metrics {
+		if m.Requests == 0 {
+			continue
+		}
+		ts.points[name] = append(ts.points[name], TimeseriesPoint{
+			Start:      report.Start,
+			Requests:   m.Requests,
+			Throughput: m.Throughput,
+			ErrorRate:  1 - m.Success,
+			Mean:       m.Latencies.Mean,
+			P50:        m.Latencies.P50,
+			P90:        m.Latencies.P90,
+			P95:        m.Latencies.P95,
+			P99:        m.Latencies.P99,
+			Max:        m.Latencies.Max,
+		})
+	}
+	return nil
+}
+
+// trackTimeseries enables recording a point of the throughput, error rate
+// and latencies of every test each interval, so degradation over the course
+// of the attack can be seen. It must be called after startWarmup.
+func (r *Reporter) trackTimeseries(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	r.seriesInterval = interval
+	r.timeseries = make(map[string][]TimeseriesPoint, len(names))
+	r.series = newIntervalRecorder(&timeseries{points: r.timeseries}, interval, r.began, names)
+}
@@ -0,0 +1,63 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestReporter_Timeseries(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{
+		{Name: "read", Method: "GET", PathPrefix: "/v1/secret/data"},
+		{Name: "write", Method: "POST", PathPrefix: "/v1/secret/data"},
+	}}
+	rpt := newReporter(tm, nil)
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rpt.startWarmup(began, 0)
+	rpt.trackTimeseries(10 * time.Second)
+
+	// Reads slow down and start failing in the second interval, while
+	// writes only run in the first
+	results := []vegeta.Result{
+		{Method: "GET", Code: 200, Timestamp: began.Add(1 * time.Second), Latency: time.Millisecond},
+		{Method: "POST", Code: 200, Timestamp: began.Add(2 * time.Second), Latency: time.Millisecond},
+		{Method: "GET", Code: 200, Timestamp: began.Add(11 * time.Second), Latency: 100 * time.Millisecond},
+		{Method: "GET", Code: 503, Timestamp: began.Add(12 * time.Second), Latency: 100 * time.Millisecond, Error: "503 Service Unavailable"},
+	}
+	for i := range results {
+		results[i].URL = "N/A/v1/secret/data/foo"
+		rpt.Add(&results[i])
+	}
+	rpt.Close()
+
+	read := rpt.timeseries["read"]
+	if len(read) != 2 {
+		t.Fatalf("expected 2 points for read, got: %d", len(read))
+	}
+	if !read[1].Start.Equal(began.Add(10*time.Second)) || read[1].Requests != 2 || read[1].ErrorRate != 0.5 || read[1].P99 != 100*time.Millisecond {
+		t.Fatalf("unexpected second point for read: %+v", read[1])
+	}
+	if got := len(rpt.timeseries["write"]); got != 1 {
+		t.Fatalf("expected only intervals with requests to have points, got %d for write", got)
+	}
+	if got := len(rpt.timeseries["total"]); got != 2 {
+		t.Fatalf("expected 2 points for total, got: %d", got)
+	}
+
+	var buf bytes.Buffer
+	if err := rpt.ReportJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rpts, err := FromReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpts[0].seriesInterval != 10*time.Second || len(rpts[0].timeseries["read"]) != 2 {
+		t.Fatalf("expected the time series to round trip, got: %v %+v", rpts[0].seriesInterval, rpts[0].timeseries)
+	}
+}
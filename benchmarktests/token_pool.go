@@ -0,0 +1,147 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/openbao/openbao/api/v2"
+)
+
+const (
+	// ServiceTokenType tokens are persisted to storage when created and
+	// can be renewed and revoked
+	ServiceTokenType = "service"
+
+	// BatchTokenType tokens are encrypted blobs which aren't persisted,
+	// and can't be renewed or revoked on their own
+	BatchTokenType = "batch"
+)
+
+// tokenPoolDisplayName is the display name of the tokens of a pool
+const tokenPoolDisplayName = "benchmark-pool"
+
+// TokenPoolConfig describes the tokens the requests of the attack are sent
+// with instead of the benchmark's token
+type TokenPoolConfig struct {
+	Size int
+
+	// Type is the type of the tokens, ServiceTokenType or BatchTokenType
+	Type string
+
+	// Policies are the policies of the tokens, those of the benchmark's
+	// token when empty
+	Policies []string
+}
+
+// Validate checks the pool can be created, filling in the default type
+func (c *TokenPoolConfig) Validate() error {
+	if c.Size < 0 {
+		return fmt.Errorf("size must not be negative")
+	}
+	if c.Type == "" {
+		c.Type = ServiceTokenType
+	}
+	switch c.Type {
+	case ServiceTokenType, BatchTokenType:
+	default:
+		return fmt.Errorf("token type must be one of %s or %s", ServiceTokenType, BatchTokenType)
+	}
+	return nil
+}
+
+// TokenPool is a set of tokens the requests of the attack are spread
+// across, so the lookups of a single token, and the quotas and hot spots
+// tied to it, don't dominate the results. The tokens are children of the
+// benchmark's token, sharing its policies unless others are given.
+type TokenPool struct {
+	tokenType string
+	original  string
+	tokens    []string
+	next      atomic.Uint64
+}
+
+// NewTokenPool creates the tokens of the pool with the client's token,
+// which keeps being used to set up and clean up tests
+func NewTokenPool(client *api.Client, config *TokenPoolConfig) (*TokenPool, error) {
+	pool := &TokenPool{tokenType: config.Type, original: client.Token()}
+	if pool.tokenType == "" {
+		pool.tokenType = ServiceTokenType
+	}
+	for i := 0; i < config.Size; i++ {
+		secret, err := client.Auth().Token().Create(&api.TokenCreateRequest{
+			DisplayName: tokenPoolDisplayName,
+			Policies:    config.Policies,
+			Type:        pool.tokenType,
+		})
+		if err == nil && (secret == nil || secret.Auth == nil) {
+			err = fmt.Errorf("token creation returned no token")
+		}
+		if err != nil {
+			// Don't leave the tokens created so far behind
+			_ = pool.Revoke(client)
+			return nil, fmt.Errorf("error creating token %d of the pool: %v", i+1, err)
+		}
+		pool.tokens = append(pool.tokens, secret.Auth.ClientToken)
+	}
+	return pool, nil
+}
+
+// Size returns the number of tokens in the pool
+func (p *TokenPool) Size() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.tokens)
+}
+
+// Type returns the type of the tokens in the pool
+func (p *TokenPool) Type() string {
+	if p == nil {
+		return ""
+	}
+	return p.tokenType
+}
+
+// Revoke revokes the tokens of the pool. Batch tokens can't be revoked on
+// their own, and are left to expire, or be revoked along with their parent.
+func (p *TokenPool) Revoke(client *api.Client) error {
+	if p.tokenType == BatchTokenType {
+		return nil
+	}
+	var failed int
+	var lastErr error
+	for _, token := range p.tokens {
+		if err := client.Auth().Token().RevokeTree(token); err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("error revoking %d tokens of the pool: %v", failed, lastErr)
+	}
+	return nil
+}
+
+// token returns the next token of the pool, in turn
+func (p *TokenPool) token() string {
+	return p.tokens[(p.next.Add(1)-1)%uint64(len(p.tokens))]
+}
+
+// tokenPoolTransport sends requests made with the benchmark's token with
+// the tokens of a pool in turn
+type tokenPoolTransport struct {
+	base http.RoundTripper
+	pool *TokenPool
+}
+
+func (t *tokenPoolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("X-Vault-Token") == t.pool.original {
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Vault-Token", t.pool.token())
+	}
+	return t.base.RoundTrip(req)
+}
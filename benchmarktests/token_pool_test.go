@@ -0,0 +1,103 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestTokenPool(t *testing.T) {
+	var mu sync.Mutex
+	var created int
+	var sent, revoked, types []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/v1/auth/token/create":
+			var body struct {
+				Type string `json:"type"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			types = append(types, body.Type)
+			created++
+			fmt.Fprintf(w, `{"auth": {"client_token": "pool-%d"}}`, created)
+		case "/v1/auth/token/revoke":
+			revoked = append(revoked, req.Header.Get("X-Vault-Token"))
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			sent = append(sent, req.Header.Get("X-Vault-Token"))
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+
+	pool, err := NewTokenPool(client, &TokenPoolConfig{Size: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.Size() != 3 {
+		t.Fatalf("expected 3 tokens, got %d", pool.Size())
+	}
+
+	httpClient := attackClient(client, &AttackConfig{TokenPool: pool})
+	for i := 0; i < 4; i++ {
+		req, err := http.NewRequest("GET", srv.URL+"/v1/secret/data/test", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Vault-Token", "root")
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	expected := []string{"pool-1", "pool-2", "pool-3", "pool-1"}
+	if fmt.Sprint(sent) != fmt.Sprint(expected) {
+		t.Fatalf("expected the requests to be spread across the pool as %v, got %v", expected, sent)
+	}
+
+	if err := pool.Revoke(client); err != nil {
+		t.Fatal(err)
+	}
+	if len(revoked) != 3 {
+		t.Fatalf("expected the 3 tokens to be revoked, got %d revocations", len(revoked))
+	}
+
+	config := &TokenPoolConfig{Size: 1, Type: BatchTokenType}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	batch, err := NewTokenPool(client, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if types[len(types)-1] != BatchTokenType {
+		t.Fatalf("expected a batch token to be created, got %v", types[len(types)-1])
+	}
+	// Batch tokens can't be revoked on their own
+	if err := batch.Revoke(client); err != nil || len(revoked) != 3 {
+		t.Fatalf("expected batch tokens to be left to expire, got %v and %d revocations", err, len(revoked))
+	}
+	if err := (&TokenPoolConfig{Type: "default"}).Validate(); err == nil {
+		t.Fatal("expected an unknown token type to be rejected")
+	}
+}
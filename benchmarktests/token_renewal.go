@@ -0,0 +1,217 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+)
+
+// minRenewalWait is the shortest time between two renewals of the token
+const minRenewalWait = time.Second
+
+// TokenRenewalStats counts the renewals of the benchmark's token and the
+// logins for a new one during the run
+type TokenRenewalStats struct {
+	Renewals        uint64 `json:"renewals"`
+	RenewalFailures uint64 `json:"renewal_failures"`
+	Logins          uint64 `json:"logins"`
+	LoginFailures   uint64 `json:"login_failures"`
+	LastError       string `json:"last_error,omitempty"`
+}
+
+// TokenRenewer keeps the token of the benchmark's clients alive during long
+// runs, renewing it every half of its TTL. When renewal fails, the token
+// isn't renewable or it is about to reach its max TTL, Login is called for
+// a new token, which replaces the original in the clients and in the
+// requests of the attack.
+type TokenRenewer struct {
+	Clients []*api.Client
+
+	// Login gets a new token, nil when the token can only be renewed
+	Login func() (string, error)
+
+	Logger hclog.Logger
+
+	mu       sync.Mutex
+	original string
+	current  string
+	stats    TokenRenewalStats
+}
+
+// Start looks the token up and renews it in the background until stop is
+// closed. Tokens without a TTL, such as root tokens, are left alone.
+func (r *TokenRenewer) Start(stop <-chan struct{}) error {
+	client := r.Clients[0]
+	r.original = client.Token()
+	r.current = r.original
+
+	secret, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		return fmt.Errorf("error looking up token: %v", err)
+	}
+	ttl, err := secret.TokenTTL()
+	if err != nil {
+		return err
+	}
+	if ttl == 0 {
+		r.Logger.Debug("token has no ttl, not renewing it")
+		return nil
+	}
+	creationTTL := ttl
+	if v, ok := secret.Data["creation_ttl"]; ok {
+		if seconds, err := parseSeconds(v); err == nil && seconds > 0 {
+			creationTTL = seconds
+		}
+	}
+	renewable, _ := secret.TokenIsRenewable()
+
+	go r.run(ttl, creationTTL, renewable, stop)
+	return nil
+}
+
+func (r *TokenRenewer) run(ttl, creationTTL time.Duration, renewable bool, stop <-chan struct{}) {
+	for {
+		timer := time.NewTimer(max(ttl/2, minRenewalWait))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		var err error
+		if renewable {
+			ttl, err = r.renew()
+		}
+		// Renewals stop extending the TTL once it reaches the max TTL, so
+		// a new token is logged in for before the old one expires
+		if r.Login != nil && (!renewable || err != nil || ttl < creationTTL/2) {
+			ttl, creationTTL, renewable = r.login(creationTTL)
+		}
+		if ttl == 0 {
+			return
+		}
+	}
+}
+
+// renew renews the current token and returns its new TTL
+func (r *TokenRenewer) renew() (time.Duration, error) {
+	secret, err := r.Clients[0].Auth().Token().RenewSelf(0)
+	if err == nil && (secret == nil || secret.Auth == nil) {
+		err = fmt.Errorf("renewal returned no token")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.stats.RenewalFailures++
+		r.stats.LastError = err.Error()
+		r.Logger.Warn("error renewing token", "error", hclog.Fmt("%v", err))
+		return 0, err
+	}
+	r.stats.Renewals++
+	ttl := time.Duration(secret.Auth.LeaseDuration) * time.Second
+	r.Logger.Debug("renewed token", "ttl", ttl)
+	return ttl, nil
+}
+
+// login logs in for a new token, replacing the current one, and returns
+// its TTL. When the login fails, it is retried after the shortest wait.
+func (r *TokenRenewer) login(creationTTL time.Duration) (time.Duration, time.Duration, bool) {
+	token, err := r.Login()
+	var secret *api.Secret
+	if err == nil {
+		client, cloneErr := r.Clients[0].CloneWithHeaders()
+		if cloneErr != nil {
+			err = cloneErr
+		} else {
+			client.SetToken(token)
+			secret, err = client.Auth().Token().LookupSelf()
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.stats.LoginFailures++
+		r.stats.LastError = err.Error()
+		r.Logger.Error("error logging in for a new token", "error", hclog.Fmt("%v", err))
+		return minRenewalWait * 2, creationTTL, false
+	}
+	r.stats.Logins++
+	r.current = token
+	for _, client := range r.Clients {
+		client.SetToken(token)
+	}
+	ttl, _ := secret.TokenTTL()
+	renewable, _ := secret.TokenIsRenewable()
+	r.Logger.Info("logged in for a new token", "ttl", ttl)
+	return ttl, max(ttl, time.Second), renewable
+}
+
+// Stats returns the renewals and logins so far
+func (r *TokenRenewer) Stats() TokenRenewalStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stats
+}
+
+// token returns the token requests sent with the original token are sent
+// with instead
+func (r *TokenRenewer) token() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// tokenTransport sends requests made with the original token of the
+// renewer, such as those of tests set up before a new token was logged in
+// for, with its current token
+type tokenTransport struct {
+	base    http.RoundTripper
+	renewer *TokenRenewer
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if token := t.renewer.token(); token != t.renewer.original && req.Header.Get("X-Vault-Token") == t.renewer.original {
+		req = req.Clone(req.Context())
+		req.Header.Set("X-Vault-Token", token)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// parseSeconds parses a number of seconds from the JSON of a response
+func parseSeconds(v interface{}) (time.Duration, error) {
+	switch n := v.(type) {
+	case float64:
+		return time.Duration(n) * time.Second, nil
+	case interface{ Int64() (int64, error) }:
+		seconds, err := n.Int64()
+		return time.Duration(seconds) * time.Second, err
+	}
+	return 0, fmt.Errorf("unexpected number of seconds: %v", v)
+}
+
+// SetTokenRenewal records the renewals of the benchmark's token during the
+// run, when it was renewed or logged in for
+func (r *Reporter) SetTokenRenewal(stats TokenRenewalStats) {
+	if stats.Renewals+stats.RenewalFailures+stats.Logins+stats.LoginFailures == 0 {
+		return
+	}
+	r.tokenRenewal = &stats
+}
+
+// report writes a single line summarizing the renewals
+func (s *TokenRenewalStats) report(w io.Writer) {
+	fmt.Fprintf(w, "Token renewal: %d renewals, %d failed, %d logins, %d failed", s.Renewals, s.RenewalFailures, s.Logins, s.LoginFailures)
+	if s.LastError != "" {
+		fmt.Fprintf(w, ", last error: %s", s.LastError)
+	}
+	fmt.Fprintln(w)
+}
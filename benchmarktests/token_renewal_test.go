@@ -0,0 +1,128 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestTokenRenewer(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token := req.Header.Get("X-Vault-Token")
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			if token == "old" {
+				fmt.Fprint(w, `{"data": {"ttl": 2, "creation_ttl": 4, "renewable": true}}`)
+			} else {
+				fmt.Fprint(w, `{"data": {"ttl": 0, "renewable": false}}`)
+			}
+		case "/v1/auth/token/renew-self":
+			// The token is close to its max TTL
+			fmt.Fprint(w, `{"auth": {"client_token": "old", "lease_duration": 1, "renewable": true}}`)
+		default:
+			mu.Lock()
+			sent = append(sent, token)
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("old")
+
+	renewer := &TokenRenewer{
+		Clients: []*api.Client{client},
+		Login:   func() (string, error) { return "new", nil },
+		Logger:  hclog.NewNullLogger(),
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := renewer.Start(stop); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for renewer.Stats().Logins == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	stats := renewer.Stats()
+	if stats.Renewals != 1 || stats.Logins != 1 || stats.RenewalFailures+stats.LoginFailures != 0 {
+		t.Fatalf("expected a renewal followed by a login, got %+v", stats)
+	}
+	if client.Token() != "new" {
+		t.Fatalf("expected the client to use the new token, got %v", client.Token())
+	}
+
+	// Targets set up with the original token are sent with the new one
+	req, err := http.NewRequest("GET", srv.URL+"/v1/secret/data/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Vault-Token", "old")
+	resp, err := attackClient(client, &AttackConfig{TokenRenewer: renewer}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(sent) != 1 || sent[0] != "new" {
+		t.Fatalf("expected the request to be sent with the new token, got %v", sent)
+	}
+
+	rpt := newReporter(&TargetMulti{}, client)
+	rpt.SetTokenRenewal(stats)
+	var b bytes.Buffer
+	if err := rpt.ReportVerbose(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Token renewal: 1 renewals, 0 failed, 1 logins, 0 failed") {
+		t.Errorf("expected the renewals in the report:\n%s", b.String())
+	}
+}
+
+func TestTokenRenewer_NoTTL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"data": {"ttl": 0, "renewable": false}}`)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+
+	renewer := &TokenRenewer{Clients: []*api.Client{client}, Logger: hclog.NewNullLogger()}
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := renewer.Start(stop); err != nil {
+		t.Fatal(err)
+	}
+	rpt := newReporter(&TargetMulti{}, client)
+	rpt.SetTokenRenewal(renewer.Stats())
+	if rpt.tokenRenewal != nil {
+		t.Fatalf("expected nothing to be reported for a token without a ttl, got %+v", rpt.tokenRenewal)
+	}
+}
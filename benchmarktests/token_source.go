@@ -0,0 +1,96 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+const (
+	FileTokenSource       = "file"
+	EnvTokenSource        = "env"
+	AppRoleTokenSource    = "approle"
+	KubernetesTokenSource = "kubernetes"
+	ExecTokenSource       = "exec"
+
+	DefaultKubernetesJWTFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+// TokenSourceConfig gets the token the benchmark, or a single test,
+// authenticates with when it starts instead of it being given with a
+// token: read from a file or environment variable, logged in for with
+// AppRole or Kubernetes auth, or printed by a helper command
+type TokenSourceConfig struct {
+	Type         string   `hcl:"type,label"`
+	Path         string   `hcl:"path,optional"`
+	Variable     string   `hcl:"variable,optional"`
+	Mount        string   `hcl:"mount,optional"`
+	RoleID       string   `hcl:"role_id,optional"`
+	SecretID     string   `hcl:"secret_id,optional"`
+	SecretIDFile string   `hcl:"secret_id_file,optional"`
+	Role         string   `hcl:"role,optional"`
+	JWTFile      string   `hcl:"jwt_file,optional"`
+	Command      []string `hcl:"command,optional"`
+}
+
+// Validate checks the options needed by the type of the source are given
+// and fills in the default mount and Kubernetes service account token
+func (c *TokenSourceConfig) Validate() error {
+	switch c.Type {
+	case FileTokenSource:
+		if c.Path == "" {
+			return fmt.Errorf("path is required")
+		}
+	case EnvTokenSource:
+		if c.Variable == "" {
+			return fmt.Errorf("variable is required")
+		}
+	case AppRoleTokenSource:
+		if c.RoleID == "" {
+			return fmt.Errorf("role_id is required")
+		}
+		if c.SecretID != "" && c.SecretIDFile != "" {
+			return fmt.Errorf("only one of secret_id or secret_id_file may be set")
+		}
+		if c.Mount == "" {
+			c.Mount = "approle"
+		}
+	case KubernetesTokenSource:
+		if c.Role == "" {
+			return fmt.Errorf("role is required")
+		}
+		if c.Mount == "" {
+			c.Mount = "kubernetes"
+		}
+		if c.JWTFile == "" {
+			c.JWTFile = DefaultKubernetesJWTFile
+		}
+	case ExecTokenSource:
+		if len(c.Command) == 0 {
+			return fmt.Errorf("command is required")
+		}
+	default:
+		return fmt.Errorf("type must be one of %v", strings.Join([]string{FileTokenSource, EnvTokenSource, AppRoleTokenSource, KubernetesTokenSource, ExecTokenSource}, ", "))
+	}
+	return nil
+}
+
+// tokenTarget returns the targets of a test sent with its own token in
+// place of the benchmark's
+func tokenTarget(token string, target func(*api.Client) vegeta.Target) func(*api.Client) vegeta.Target {
+	return func(client *api.Client) vegeta.Target {
+		tgt := target(client)
+		tgt.Header = tgt.Header.Clone()
+		if tgt.Header == nil {
+			tgt.Header = make(http.Header)
+		}
+		tgt.Header.Set("X-Vault-Token", token)
+		return tgt
+	}
+}
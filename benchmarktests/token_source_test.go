@@ -0,0 +1,35 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/openbao/openbao/api/v2"
+)
+
+func TestTestToken(t *testing.T) {
+	client, err := api.NewClient(api.DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetToken("root")
+
+	builder := &StatusCheck{pathPrefix: "/v1/sys/health", header: http.Header{"X-Vault-Token": {"root"}}}
+	withToken := BenchmarkTarget{Name: "tenant-a", Builder: builder, Token: "s.tenant-a"}
+	withToken.ConfigureTarget(client)
+	if token := withToken.Target(client).Header.Get("X-Vault-Token"); token != "s.tenant-a" {
+		t.Fatalf("expected the attack to be sent with the token of the test, got %q", token)
+	}
+	if token := builder.header.Get("X-Vault-Token"); token != "root" {
+		t.Fatalf("expected the headers of the test to be left alone, got %q", token)
+	}
+
+	without := BenchmarkTarget{Name: "tenant-b", Builder: builder}
+	without.ConfigureTarget(client)
+	if token := without.Target(client).Header.Get("X-Vault-Token"); token != "root" {
+		t.Fatalf("expected the attack to be sent with the benchmark's token, got %q", token)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	"golang.org/x/net/http2"
+)
+
+const (
+	// HTTP2Negotiated uses HTTP/2 with servers which offer it over TLS, and
+	// HTTP/1.1 otherwise
+	HTTP2Negotiated = "negotiated"
+
+	// HTTP2Disabled always uses HTTP/1.1
+	HTTP2Disabled = "disabled"
+
+	// HTTP2Forced always uses HTTP/2, over cleartext (h2c) for http://
+	// addresses, failing requests to servers which don't support it
+	HTTP2Forced = "forced"
+
+	// NoProxy sends requests straight to the server, even when the proxy
+	// environment variables are set
+	NoProxy = "none"
+)
+
+// TransportConfig tunes the HTTP transport the requests of the attack are
+// sent with, as the defaults of the API client's transport limit how many
+// connections are kept open to each server at high rates. Zero values keep
+// those defaults. Reports record the settings the attack was run with.
+type TransportConfig struct {
+	MaxIdleConnsPerHost int           `json:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `json:"idle_conn_timeout"`
+	TLSHandshakeTimeout time.Duration `json:"tls_handshake_timeout"`
+	HTTP2               string        `json:"http2"`
+
+	// Proxy is the HTTP, HTTPS or SOCKS5 proxy requests are sent through
+	// instead of the one of the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables, or NoProxy
+	Proxy string `json:"proxy,omitempty"`
+
+	// WorkerConns, when set, gives each closed loop worker a pool of its
+	// own of at most this many connections, as distinct clients would
+	// have, instead of all workers sharing one pool
+	WorkerConns int `json:"worker_connections,omitempty"`
+
+	// configured is whether any of the settings were changed from the
+	// defaults of the client, which terse reports only show them for
+	configured bool
+}
+
+// Validate checks the settings are usable
+func (c *TransportConfig) Validate() error {
+	if c.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("max idle connections per host must not be negative")
+	}
+	if c.WorkerConns < 0 {
+		return fmt.Errorf("worker connections must not be negative")
+	}
+	if c.IdleConnTimeout < 0 || c.TLSHandshakeTimeout < 0 {
+		return fmt.Errorf("timeouts must not be negative")
+	}
+	switch c.HTTP2 {
+	case "", HTTP2Negotiated, HTTP2Disabled, HTTP2Forced:
+	default:
+		return fmt.Errorf("http2 must be one of %s, %s or %s", HTTP2Negotiated, HTTP2Disabled, HTTP2Forced)
+	}
+	if c.Proxy != "" && c.Proxy != NoProxy {
+		u, err := url.Parse(c.Proxy)
+		if err != nil {
+			return fmt.Errorf("invalid proxy: %v", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return fmt.Errorf("proxy scheme must be one of http, https, socks5 or socks5h")
+		}
+		if u.Host == "" {
+			return fmt.Errorf("proxy %q has no host", u.Redacted())
+		}
+		if c.HTTP2 == HTTP2Forced {
+			return fmt.Errorf("requests cannot be sent through a proxy when HTTP/2 is forced")
+		}
+	}
+	return nil
+}
+
+// ProxyFunc returns the proxy function of transports sending requests
+// through Proxy, or false when the proxy of the environment is used
+func (c *TransportConfig) ProxyFunc() (func(*http.Request) (*url.URL, error), bool) {
+	switch c.Proxy {
+	case "":
+		return nil, false
+	case NoProxy:
+		return nil, true
+	}
+	// Proxies are parsed when the settings are validated
+	u, _ := url.Parse(c.Proxy)
+	return http.ProxyURL(u), true
+}
+
+// tune returns a copy of base with the settings applied
+func (c *TransportConfig) tune(base *http.Transport) *http.Transport {
+	t := base.Clone()
+	if c.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+		if t.MaxIdleConns > 0 && t.MaxIdleConns < c.MaxIdleConnsPerHost {
+			t.MaxIdleConns = c.MaxIdleConnsPerHost
+		}
+	}
+	if c.WorkerConns > 0 {
+		t.MaxConnsPerHost = c.WorkerConns
+		t.MaxIdleConnsPerHost = c.WorkerConns
+	}
+	if c.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = c.IdleConnTimeout
+	}
+	if c.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = c.TLSHandshakeTimeout
+	}
+	if proxy, ok := c.ProxyFunc(); ok {
+		t.Proxy = proxy
+	}
+	if c.HTTP2 == HTTP2Disabled {
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.NextProtos = []string{"http/1.1"}
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		t.ForceAttemptHTTP2 = false
+	}
+	return t
+}
+
+// forcedHTTP2Transport returns an HTTP/2 transport dialing connections
+// like t, negotiating HTTP/2 with TLS when addr is an https:// address and
+// speaking it over cleartext otherwise. Connections are made straight to
+// the server, without going through a proxy.
+func forcedHTTP2Transport(t *http.Transport, addr string) *http2.Transport {
+	dial := t.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	useTLS := strings.HasPrefix(addr, "https://")
+	return &http2.Transport{
+		TLSClientConfig: t.TLSClientConfig,
+		AllowHTTP:       true,
+		IdleConnTimeout: t.IdleConnTimeout,
+		DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil || !useTLS {
+				return conn, err
+			}
+			if t.TLSHandshakeTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, t.TLSHandshakeTimeout)
+				defer cancel()
+			}
+			tlsConn := tls.Client(conn, cfg)
+			if err := tlsConn.HandshakeContext(ctx); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		},
+	}
+}
+
+// transportSettings returns the settings requests of the client are sent
+// with once config is applied, filling in the defaults of its transport
+func transportSettings(client *api.Client, config *TransportConfig) *TransportConfig {
+	if client == nil || config == nil {
+		return nil
+	}
+	settings := *config
+	settings.configured = *config != TransportConfig{}
+	if settings.HTTP2 == "" {
+		settings.HTTP2 = HTTP2Negotiated
+	}
+	// Proxies may carry credentials, which are left out of reports
+	if u, err := url.Parse(settings.Proxy); err == nil && u.User != nil {
+		settings.Proxy = u.Redacted()
+	}
+	transport, ok := client.CloneConfig().HttpClient.Transport.(*http.Transport)
+	if !ok {
+		return &settings
+	}
+	transport = config.tune(transport)
+	settings.MaxIdleConnsPerHost = transport.MaxIdleConnsPerHost
+	if settings.MaxIdleConnsPerHost == 0 {
+		settings.MaxIdleConnsPerHost = http.DefaultMaxIdleConnsPerHost
+	}
+	settings.IdleConnTimeout = transport.IdleConnTimeout
+	settings.TLSHandshakeTimeout = transport.TLSHandshakeTimeout
+	return &settings
+}
+
+// report writes a single line describing the transport settings
+func (c *TransportConfig) report(w io.Writer) {
+	extra := ""
+	if c.Proxy != "" {
+		extra += ", proxy " + c.Proxy
+	}
+	if c.WorkerConns > 0 {
+		extra += fmt.Sprintf(", %d conns per worker", c.WorkerConns)
+	}
+	fmt.Fprintf(w, "Transport: %d idle conns per host, idle timeout %s, TLS handshake timeout %s, HTTP/2 %s%s\n",
+		c.MaxIdleConnsPerHost, c.IdleConnTimeout, c.TLSHandshakeTimeout, c.HTTP2, extra)
+}
@@ -0,0 +1,179 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestTransportConfig(t *testing.T) {
+	srv := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("X-Proto", req.Proto)
+		w.WriteHeader(http.StatusNoContent)
+	}), &http2.Server{}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(config *AttackConfig) string {
+		req, err := http.NewRequest("GET", srv.URL+"/v1/sys/health", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := attackClient(client, config).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.Header.Get("X-Proto")
+	}
+	if proto := send(&AttackConfig{}); proto != "HTTP/1.1" {
+		t.Fatalf("expected HTTP/1.1 over cleartext by default, got %v", proto)
+	}
+	if proto := send(&AttackConfig{Transport: &TransportConfig{HTTP2: HTTP2Forced}}); proto != "HTTP/2.0" {
+		t.Fatalf("expected HTTP/2 to be forced, got %v", proto)
+	}
+
+	transport := &TransportConfig{MaxIdleConnsPerHost: 64, IdleConnTimeout: time.Minute}
+	tuned := transport.tune(client.CloneConfig().HttpClient.Transport.(*http.Transport))
+	if tuned.MaxIdleConnsPerHost != 64 || tuned.IdleConnTimeout != time.Minute {
+		t.Fatalf("expected the settings to be applied, got %d and %s", tuned.MaxIdleConnsPerHost, tuned.IdleConnTimeout)
+	}
+	if client.CloneConfig().HttpClient.Transport.(*http.Transport).MaxIdleConnsPerHost == 64 {
+		t.Fatal("expected the client's transport to be left alone")
+	}
+
+	settings := transportSettings(client, transport)
+	if settings.MaxIdleConnsPerHost != 64 || settings.HTTP2 != HTTP2Negotiated || settings.TLSHandshakeTimeout == 0 {
+		t.Fatalf("expected the settings to be filled in with the client's defaults, got %+v", settings)
+	}
+	rpt := newReporter(&TargetMulti{}, client)
+	rpt.transport = settings
+	var b bytes.Buffer
+	if err := rpt.ReportVerbose(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Transport: 64 idle conns per host, idle timeout 1m0s") {
+		t.Errorf("expected the settings in the report:\n%s", b.String())
+	}
+
+	// Terse reports only show the settings when they were changed
+	for _, config := range []*TransportConfig{transport, {}} {
+		rpt.transport = transportSettings(client, config)
+		b.Reset()
+		if err := rpt.ReportTerse(&b); err != nil {
+			t.Fatal(err)
+		}
+		if shown := strings.Contains(b.String(), "Transport:"); shown != (config == transport) {
+			t.Errorf("expected the settings %+v to be shown in the terse report only when changed:\n%s", config, b.String())
+		}
+	}
+
+	if err := (&TransportConfig{HTTP2: "sometimes"}).Validate(); err == nil {
+		t.Fatal("expected an unknown http2 mode to be rejected")
+	}
+}
+
+func TestTransportConfig_Proxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		proxied = append(proxied, req.URL.String())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = "http://openbao.invalid:8200"
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &TransportConfig{Proxy: strings.Replace(proxy.URL, "http://", "http://bench:secret@", 1)}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", client.Address()+"/v1/sys/health", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := attackClient(client, &AttackConfig{Transport: config}).Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(proxied) != 1 || proxied[0] != "http://openbao.invalid:8200/v1/sys/health" {
+		t.Fatalf("expected the request to be sent through the proxy, got %v", proxied)
+	}
+
+	if settings := transportSettings(client, config); strings.Contains(settings.Proxy, "secret") {
+		t.Fatalf("expected the proxy password to be left out of reports, got %v", settings.Proxy)
+	}
+	for _, invalid := range []*TransportConfig{
+		{Proxy: "ftp://proxy:21"},
+		{Proxy: "socks5://proxy:1080", HTTP2: HTTP2Forced},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestTransportConfig_WorkerConns(t *testing.T) {
+	var mu sync.Mutex
+	conns := make(map[string]bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		conns[req.RemoteAddr] = true
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := &TargetMulti{targets: []BenchmarkTarget{{
+		Name:       "health",
+		Method:     "GET",
+		PathPrefix: "/v1/sys/health",
+		Weight:     100,
+		Builder:    &StatusCheck{},
+		Target: func(client *api.Client) vegeta.Target {
+			return vegeta.Target{Method: "GET", URL: client.Address() + "/v1/sys/health"}
+		},
+	}}}
+
+	_, err = Attack(tm, client, &AttackConfig{
+		Mode:      ClosedLoopAttackMode,
+		Workers:   3,
+		Duration:  200 * time.Millisecond,
+		Transport: &TransportConfig{WorkerConns: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conns) != 3 {
+		t.Fatalf("expected each of the 3 workers to send its requests on a connection of its own, got %d connections", len(conns))
+	}
+}
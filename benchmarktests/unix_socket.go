@@ -0,0 +1,28 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"strings"
+
+	"github.com/openbao/openbao/api/v2"
+)
+
+// UnixSocketPrefix starts the addresses of servers, proxies and agents
+// listening on a unix domain socket
+const UnixSocketPrefix = "unix://"
+
+// configuredAddr returns the address of a client as it was configured,
+// such as a unix:// socket, rather than the HTTP address requests are sent
+// to
+func configuredAddr(client *api.Client) string {
+	return client.CloneConfig().Address
+}
+
+// isUnixSocket reports whether the client sends its requests to a unix
+// domain socket. The requests are addressed to http://localhost, and
+// reach the socket through the dialer of the client's transport.
+func isUnixSocket(client *api.Client) bool {
+	return strings.HasPrefix(configuredAddr(client), UnixSocketPrefix)
+}
@@ -0,0 +1,64 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestUnixSocketTarget(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "bao.sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = UnixSocketPrefix + sock
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isUnixSocket(client) {
+		t.Fatalf("expected %v to be a unix socket", configuredAddr(client))
+	}
+
+	tgt := vegeta.Target{Method: "GET", URL: client.Address() + "/v1/secret/data/foo"}
+	req, err := tgt.Request()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := attackClient(client, &AttackConfig{DNSRefresh: time.Second}).Do(req)
+	if err != nil {
+		t.Fatalf("expected the request to reach the socket, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("got %v", resp.Status)
+	}
+
+	rpt := newReporter(&TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}, client)
+	rpt.Add(&vegeta.Result{Method: "GET", URL: tgt.URL, Code: 204, Timestamp: time.Now(), Latency: time.Millisecond})
+	rpt.Close()
+	if rpt.clientAddr != cfg.Address {
+		t.Fatalf("expected the report to be named after the socket, got %v", rpt.clientAddr)
+	}
+	if m := rpt.metrics["read"]; m == nil || m.Requests != 1 {
+		t.Fatalf("expected the result to be recorded for its test, got %+v", rpt.metrics)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openbao/openbao/api/v2"
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestReporter_Warmup(t *testing.T) {
+	tm := &TargetMulti{targets: []BenchmarkTarget{{Name: "read", Method: "GET", PathPrefix: "/v1/secret"}}}
+	rpt := newReporter(tm, nil)
+	began := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rpt.startWarmup(began, 10*time.Second)
+
+	// Slow while warming up, and fast from the end of the warmup on
+	for i := 0; i < 20; i++ {
+		latency := time.Second
+		if i >= 10 {
+			latency = time.Millisecond
+		}
+		rpt.Add(&vegeta.Result{
+			Method:    "GET",
+			URL:       "N/A/v1/secret/foo",
+			Code:      200,
+			Timestamp: began.Add(time.Duration(i) * time.Second),
+			Latency:   latency,
+		})
+	}
+	rpt.Close()
+
+	for _, name := range []string{"read", "total"} {
+		metrics, warmup := rpt.Metrics()[name], rpt.warmupMetrics[name]
+		if metrics.Requests != 10 || metrics.Latencies.Max != time.Millisecond {
+			t.Fatalf("expected 10 requests of 1ms in the metrics of %v, got %d with a max of %v", name, metrics.Requests, metrics.Latencies.Max)
+		}
+		if warmup.Requests != 10 || warmup.Latencies.Min != time.Second {
+			t.Fatalf("expected 10 requests of 1s in the warmup metrics of %v, got %d with a min of %v", name, warmup.Requests, warmup.Latencies.Min)
+		}
+	}
+}
+
+func TestAttack_WarmupAfterStartOffset(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := api.DefaultConfig()
+	cfg.Address = srv.URL
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test := func(name string) BenchmarkTarget {
+		return BenchmarkTarget{
+			Name:       name,
+			Method:     "GET",
+			PathPrefix: "/v1/" + name,
+			Weight:     100,
+			Target: func(client *api.Client) vegeta.Target {
+				return vegeta.Target{Method: "GET", URL: client.Address() + "/v1/" + name}
+			},
+		}
+	}
+	// The late test starts after the warmup of the attack would have ended,
+	// so its own warmup only counts from its start
+	late := test("late")
+	late.StartAfter, late.Warmup = "300ms", "200ms"
+	tm := &TargetMulti{targets: []BenchmarkTarget{test("first"), late}}
+	rpt, err := Attack(tm, client, &AttackConfig{Duration: 800 * time.Millisecond, RPS: 50, Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 200ms at 50 requests per second is ten requests in the warmup, and
+	// 300ms fifteen after it
+	if got := rpt.warmupMetrics["late"].Requests; got < 5 || got > 15 {
+		t.Fatalf("expected about ten warmup requests to the late test, got %d", got)
+	}
+	if got := rpt.metrics["late"].Requests; got < 8 || got > 20 {
+		t.Fatalf("expected about fifteen requests to the late test after its warmup, got %d", got)
+	}
+	if got := rpt.warmupMetrics["first"].Requests; got != 0 {
+		t.Fatalf("expected no warmup requests to the test without a warmup, got %d", got)
+	}
+}
@@ -0,0 +1,299 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Events webhooks are sent on
+	WebhookStartEvent    = "start"
+	WebhookSLOEvent      = "slo_violation"
+	WebhookCompleteEvent = "complete"
+
+	// Formats of the requests sent to webhooks
+	WebhookJSONFormat  = "json"
+	WebhookSlackFormat = "slack"
+
+	// DefaultWebhookTimeout is how long a webhook may take to answer when
+	// it doesn't set its own timeout
+	DefaultWebhookTimeout = 10 * time.Second
+)
+
+// WebhookConfig is an endpoint notified of the events of a run, such as a
+// Slack incoming webhook or any endpoint accepting a JSON POST
+type WebhookConfig struct {
+	Name    string            `hcl:"name,label"`
+	URL     string            `hcl:"url"`
+	Format  string            `hcl:"format,optional"`
+	Events  []string          `hcl:"events,optional"`
+	Headers map[string]string `hcl:"headers,optional"`
+	Timeout string            `hcl:"timeout,optional"`
+}
+
+// Validate checks the URL, format, events and timeout of the webhook
+func (c *WebhookConfig) Validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	switch c.Format {
+	case "", WebhookJSONFormat, WebhookSlackFormat:
+	default:
+		return fmt.Errorf("format must be one of %v or %v", WebhookJSONFormat, WebhookSlackFormat)
+	}
+	for _, event := range c.Events {
+		switch event {
+		case WebhookStartEvent, WebhookSLOEvent, WebhookCompleteEvent:
+		default:
+			return fmt.Errorf("unknown event %q, must be one of %v, %v or %v", event, WebhookStartEvent, WebhookSLOEvent, WebhookCompleteEvent)
+		}
+	}
+	if c.Timeout != "" {
+		timeout, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %v", err)
+		}
+		if timeout <= 0 {
+			return fmt.Errorf("timeout must be positive")
+		}
+	}
+	return nil
+}
+
+// sends reports whether the webhook is sent the event, which is every event
+// when it lists none
+func (c *WebhookConfig) sends(event string) bool {
+	if len(c.Events) == 0 {
+		return true
+	}
+	for _, e := range c.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *WebhookConfig) timeout() time.Duration {
+	if c.Timeout == "" {
+		return DefaultWebhookTimeout
+	}
+	// The timeout is checked by Validate when the config is parsed
+	timeout, _ := time.ParseDuration(c.Timeout)
+	return timeout
+}
+
+// WebhookEvent is an event of a run sent to webhooks. Which fields are set
+// depends on the event: the tests and planned duration of the run when it
+// starts, the missed SLOs on an SLO violation, and whether the run passed,
+// why it failed and the summary of its results once it completes.
+type WebhookEvent struct {
+	Event    string            `json:"event"`
+	Time     time.Time         `json:"time"`
+	RunID    string            `json:"run_id,omitempty"`
+	Targets  []string          `json:"targets,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Tests    []string          `json:"tests,omitempty"`
+	Duration string            `json:"duration,omitempty"`
+	Passed   *bool             `json:"passed,omitempty"`
+	Failures []string          `json:"failures,omitempty"`
+	SLOs     []*WebhookSLO     `json:"slos,omitempty"`
+	Summary  []*WebhookSummary `json:"summary,omitempty"`
+
+	// reports are the results the summary was made from, which Slack
+	// messages show as a table
+	reports []*Reporter
+}
+
+// WebhookSLO is an SLO a test missed
+type WebhookSLO struct {
+	Test      string `json:"test"`
+	Phase     string `json:"phase,omitempty"`
+	Objective string `json:"objective"`
+	Actual    string `json:"actual"`
+}
+
+// WebhookSummary is the summary of the results of a test
+type WebhookSummary struct {
+	Target       string  `json:"target"`
+	Phase        string  `json:"phase,omitempty"`
+	Test         string  `json:"test"`
+	Requests     uint64  `json:"requests"`
+	Throughput   float64 `json:"throughput"`
+	SuccessRatio float64 `json:"success_ratio"`
+	P50Ms        float64 `json:"p50_ms"`
+	P99Ms        float64 `json:"p99_ms"`
+	Failures     uint64  `json:"failures"`
+}
+
+// NewWebhookSLOs returns the SLOs of the results which were missed
+func NewWebhookSLOs(results []*SLOResult) []*WebhookSLO {
+	var missed []*WebhookSLO
+	for _, result := range results {
+		if !result.Passed {
+			missed = append(missed, &WebhookSLO{
+				Test:      result.Test,
+				Phase:     result.Phase,
+				Objective: result.Objective,
+				Actual:    result.Actual,
+			})
+		}
+	}
+	return missed
+}
+
+// SetSummary summarizes the results of each test of the reports, and of
+// all of them as "total", in the event
+func (e *WebhookEvent) SetSummary(rpts []*Reporter) {
+	e.reports = rpts
+	e.Summary = nil
+	for _, rpt := range rpts {
+		names := make([]string, 0, len(rpt.metrics))
+		for name := range rpt.metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			m, h := rpt.metrics[name], rpt.histograms[name]
+			p50, _ := rpt.latencyPercentile(m, h, 50)
+			p99, _ := rpt.latencyPercentile(m, h, 99)
+			e.Summary = append(e.Summary, &WebhookSummary{
+				Target:       rpt.clientAddr,
+				Phase:        rpt.phase,
+				Test:         name,
+				Requests:     m.Requests,
+				Throughput:   m.Throughput,
+				SuccessRatio: m.Success,
+				P50Ms:        float64(p50) / float64(time.Millisecond),
+				P99Ms:        float64(p99) / float64(time.Millisecond),
+				Failures:     uint64(math.Round(float64(m.Requests) * (1 - m.Success))),
+			})
+		}
+	}
+}
+
+// Webhooks sends the events of a run to the webhooks of its config
+type Webhooks struct {
+	configs []*WebhookConfig
+	client  *http.Client
+}
+
+func NewWebhooks(configs []*WebhookConfig) *Webhooks {
+	return &Webhooks{configs: configs, client: &http.Client{}}
+}
+
+// Send sends the event to every webhook it is sent to at once, returning
+// once all of them have answered or timed out. A failed webhook doesn't
+// stop the others being sent the event.
+func (w *Webhooks) Send(event *WebhookEvent) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(w.configs))
+	for i, config := range w.configs {
+		if !config.sends(event.Event) {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.send(config, event); err != nil {
+				errs[i] = fmt.Errorf("error sending %v event to webhook %v: %v", event.Event, config.Name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (w *Webhooks) send(config *WebhookConfig, event *WebhookEvent) error {
+	var payload interface{} = event
+	if config.Format == WebhookSlackFormat {
+		payload = map[string]string{"text": slackText(event)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range config.Headers {
+		req.Header.Set(name, value)
+	}
+	client := *w.client
+	client.Timeout = config.timeout()
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+// slackText is the message a Slack webhook is sent for the event, with the
+// summary of a completed run as a table of its results
+func slackText(event *WebhookEvent) string {
+	var b strings.Builder
+	b.WriteString("*vault-benchmark* ")
+	switch event.Event {
+	case WebhookStartEvent:
+		fmt.Fprintf(&b, "run started against %v: %d tests", strings.Join(event.Targets, ", "), len(event.Tests))
+		if event.Duration != "" {
+			fmt.Fprintf(&b, " for %v", event.Duration)
+		}
+	case WebhookSLOEvent:
+		fmt.Fprintf(&b, "run against %v missed %d SLOs:", strings.Join(event.Targets, ", "), len(event.SLOs))
+		for _, slo := range event.SLOs {
+			test := slo.Test
+			if slo.Phase != "" {
+				test = slo.Phase + "/" + test
+			}
+			fmt.Fprintf(&b, "\n• %v: %v (actual %v)", test, slo.Objective, slo.Actual)
+		}
+	case WebhookCompleteEvent:
+		status := "passed"
+		if event.Passed != nil && !*event.Passed {
+			status = "failed"
+		}
+		fmt.Fprintf(&b, "run against %v %v", strings.Join(event.Targets, ", "), status)
+		if event.Duration != "" {
+			fmt.Fprintf(&b, " after %v", event.Duration)
+		}
+		if len(event.Failures) > 0 {
+			fmt.Fprintf(&b, ": %v", strings.Join(event.Failures, ", "))
+		}
+		if len(event.reports) > 0 {
+			var table strings.Builder
+			if err := ReportMarkdown(&table, event.reports, nil); err == nil {
+				fmt.Fprintf(&b, "\n```\n%v```", table.String())
+			}
+		}
+	}
+	if event.RunID != "" {
+		fmt.Fprintf(&b, "\nRun ID: %v", event.RunID)
+	}
+	return b.String()
+}
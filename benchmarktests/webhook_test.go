@@ -0,0 +1,124 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package benchmarktests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vegeta "github.com/tsenart/vegeta/v12/lib"
+)
+
+func TestWebhooks(t *testing.T) {
+	var lock sync.Mutex
+	received := make(map[string][]map[string]interface{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %v %v", req.Method, req.Header)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("err: %v", err)
+		}
+		if req.URL.Path == "/json" && req.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("expected the headers of the webhook to be sent, got %v", req.Header)
+		}
+		lock.Lock()
+		received[req.URL.Path] = append(received[req.URL.Path], body)
+		lock.Unlock()
+	}))
+	defer srv.Close()
+
+	webhooks := NewWebhooks([]*WebhookConfig{
+		{Name: "json", URL: srv.URL + "/json", Headers: map[string]string{"Authorization": "Bearer secret"}},
+		{Name: "slack", URL: srv.URL + "/slack", Format: WebhookSlackFormat, Events: []string{WebhookSLOEvent, WebhookCompleteEvent}},
+	})
+	start := &WebhookEvent{Event: WebhookStartEvent, RunID: "run-1", Targets: []string{"http://127.0.0.1:8200"}, Tests: []string{"kvv2_read_test"}, Duration: "10s"}
+	if err := webhooks.Send(start); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	rpt := &Reporter{
+		clientAddr: "http://127.0.0.1:8200",
+		metrics:    map[string]*vegeta.Metrics{"total": {}, "kvv2_read_test": {}},
+		histograms: map[string]*Histogram{},
+	}
+	for _, name := range []string{"total", "kvv2_read_test"} {
+		m := rpt.metrics[name]
+		for i := 0; i < 10; i++ {
+			code := uint16(200)
+			if i == 0 {
+				code = 500
+			}
+			m.Add(&vegeta.Result{Code: code, Latency: time.Duration(i+1) * time.Millisecond, Timestamp: time.Unix(int64(i), 0)})
+		}
+		m.Close()
+	}
+	passed := false
+	complete := &WebhookEvent{Event: WebhookCompleteEvent, RunID: "run-1", Targets: []string{"http://127.0.0.1:8200"}, Duration: "10s", Passed: &passed, Failures: []string{"slos missed"}}
+	complete.SetSummary([]*Reporter{rpt})
+	if err := webhooks.Send(complete); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The JSON webhook is sent every event, and the Slack one only those it
+	// lists
+	if len(received["/json"]) != 2 || len(received["/slack"]) != 1 {
+		t.Fatalf("unexpected requests: %v", received)
+	}
+	if event := received["/json"][0]; event["event"] != "start" || event["run_id"] != "run-1" || event["duration"] != "10s" {
+		t.Fatalf("unexpected start event: %v", event)
+	}
+	summary := received["/json"][1]["summary"].([]interface{})
+	test := summary[0].(map[string]interface{})
+	if len(summary) != 2 || test["test"] != "kvv2_read_test" || test["requests"] != float64(10) || test["failures"] != float64(1) || test["success_ratio"] != 0.9 {
+		t.Fatalf("unexpected summary: %v", summary)
+	}
+	text := received["/slack"][0]["text"].(string)
+	for _, expected := range []string{"run against http://127.0.0.1:8200 failed after 10s: slos missed", "| kvv2_read_test |", "Run ID: run-1"} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("expected slack message to contain %q, got:\n%v", expected, text)
+		}
+	}
+
+	// Failed webhooks don't stop the others being sent the event
+	webhooks = NewWebhooks([]*WebhookConfig{
+		{Name: "broken", URL: srv.URL + "/broken"},
+		{Name: "json", URL: srv.URL + "/json", Headers: map[string]string{"Authorization": "Bearer secret"}},
+	})
+	err := webhooks.Send(&WebhookEvent{Event: WebhookSLOEvent, SLOs: NewWebhookSLOs([]*SLOResult{{Test: "a", Objective: "p99 <= 50ms", Actual: "80ms"}, {Test: "b", Passed: true}})})
+	if err == nil || !strings.Contains(err.Error(), "webhook broken: status 500") {
+		t.Fatalf("expected the broken webhook to fail, got: %v", err)
+	}
+	slos := received["/json"][2]["slos"].([]interface{})
+	if len(slos) != 1 || slos[0].(map[string]interface{})["actual"] != "80ms" {
+		t.Fatalf("unexpected missed slos: %v", slos)
+	}
+}
+
+func TestWebhookConfig_Validate(t *testing.T) {
+	cases := map[string]*WebhookConfig{
+		"url must be an http or https URL": {URL: "ftp://example.com"},
+		"format must be one of":            {URL: "https://example.com", Format: "teams"},
+		"unknown event \"stop\"":           {URL: "https://example.com", Events: []string{"stop"}},
+		"invalid timeout":                  {URL: "https://example.com", Timeout: "soon"},
+	}
+	for expected, config := range cases {
+		if err := config.Validate(); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error %q, got: %v", expected, err)
+		}
+	}
+	if err := (&WebhookConfig{URL: "https://example.com", Format: WebhookSlackFormat, Events: []string{WebhookCompleteEvent}}).Validate(); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	vaultapi "github.com/openbao/openbao/api/v2"
+)
+
+// activePod is the kubernetes_delete_pod value deleting the pod of the
+// active node at the time of the event
+const activePod = "active"
+
+// chaosPoints are the points of the run the chaos events of the config
+// happen at: those of every chaos block, in order, of the failover
+// scenario and of the snapshot scenario
+type chaosPoints struct {
+	chaos    [][]time.Duration
+	failover []time.Duration
+	snapshot []time.Duration
+}
+
+// parseChaosPoints parses the at of every chaos block and scenario of the
+// config against the duration of the run, so mistakes are reported before
+// anything is set up
+func parseChaosPoints(conf *vbConfig.VaultBenchmarkCoreConfig, duration time.Duration) (*chaosPoints, error) {
+	points := &chaosPoints{chaos: make([][]time.Duration, len(conf.Chaos))}
+	var err error
+	for i, c := range conf.Chaos {
+		points.chaos[i], err = benchmarktests.ParseProfilePoints(c.At, duration)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing at of chaos %v: %w", c.Name, err)
+		}
+	}
+	if conf.Failover != nil {
+		points.failover, err = benchmarktests.ParseProfilePoints(conf.Failover.At, duration)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing at of failover: %w", err)
+		}
+	}
+	if conf.Snapshot != nil {
+		points.snapshot, err = benchmarktests.ParseProfilePoints(conf.Snapshot.At, duration)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing at of raft_snapshot: %w", err)
+		}
+	}
+	return points, nil
+}
+
+// scheduleChaos schedules the action of every chaos block at each of its
+// points of the run, the step-down of the failover scenario at each of its
+// points and the snapshot of the snapshot scenario at each of its points.
+// The active node is looked up, stepped down and snapshotted through
+// client when an event happens.
+func scheduleChaos(conf *vbConfig.VaultBenchmarkCoreConfig, points *chaosPoints, client *vaultapi.Client) ([]*benchmarktests.ChaosEvent, error) {
+	var events []*benchmarktests.ChaosEvent
+	for i, c := range conf.Chaos {
+		window, err := c.Window()
+		if err != nil {
+			return nil, err
+		}
+
+		var action string
+		var run func(ctx context.Context, result *benchmarktests.ChaosEventResult) error
+		switch {
+		case len(c.Command) > 0:
+			action = strings.Join(c.Command, " ")
+			run = func(ctx context.Context, result *benchmarktests.ChaosEventResult) error {
+				cmd := exec.CommandContext(ctx, c.Command[0], c.Command[1:]...)
+				cmd.Env = append(os.Environ(),
+					"VAULT_BENCHMARK_CHAOS_EVENT="+c.Name,
+					"VAULT_BENCHMARK_ACTIVE_ADDR="+activeAddr(client),
+				)
+				_, err := cmd.Output()
+				return commandError(err)
+			}
+		case c.DockerRestart != "":
+			action = "docker restart " + c.DockerRestart
+			run = func(ctx context.Context, result *benchmarktests.ChaosEventResult) error {
+				_, err := exec.CommandContext(ctx, "docker", "restart", c.DockerRestart).Output()
+				return commandError(err)
+			}
+		case c.DeletePod != "":
+			if conf.Kubernetes == nil {
+				return nil, fmt.Errorf("chaos %v: kubernetes_delete_pod requires a kubernetes block", c.Name)
+			}
+			k := &kubeClient{Kubeconfig: conf.Kubernetes.Kubeconfig, Context: conf.Kubernetes.Context, Namespace: conf.Kubernetes.Namespace}
+			if err := k.resolveNamespace(); err != nil {
+				return nil, err
+			}
+			action = "delete pod " + c.DeletePod
+			run = func(ctx context.Context, result *benchmarktests.ChaosEventResult) error {
+				pod := c.DeletePod
+				if pod == activePod {
+					var err error
+					if pod, err = activeKubePod(k, conf.Kubernetes.Selector, activeAddr(client)); err != nil {
+						return err
+					}
+				}
+				return k.delete("/api/v1/namespaces/" + url.PathEscape(k.Namespace) + "/pods/" + url.PathEscape(pod))
+			}
+		}
+
+		for _, at := range points.chaos[i] {
+			events = append(events, &benchmarktests.ChaosEvent{
+				Name:   c.Name,
+				At:     at,
+				Action: action,
+				Window: window,
+				Run:    run,
+			})
+		}
+	}
+
+	if conf.Failover != nil {
+		window, err := conf.Failover.Window()
+		if err != nil {
+			return nil, err
+		}
+		for _, at := range points.failover {
+			events = append(events, &benchmarktests.ChaosEvent{
+				Name:   benchmarktests.FailoverEvent,
+				At:     at,
+				Action: "step-down",
+				Window: window,
+				Run: func(ctx context.Context, result *benchmarktests.ChaosEventResult) error {
+					result.Failover = &benchmarktests.FailoverResult{}
+					// Standbys forward the step-down to the active node
+					return client.Sys().StepDownWithContext(ctx)
+				},
+			})
+		}
+	}
+
+	if conf.Snapshot != nil {
+		var restore *vaultapi.Client
+		if conf.Snapshot.RestoreAddr != "" {
+			var err error
+			if restore, err = client.Clone(); err != nil {
+				return nil, fmt.Errorf("error creating restore client: %v", err)
+			}
+			if err := restore.SetAddress(conf.Snapshot.RestoreAddr); err != nil {
+				return nil, fmt.Errorf("error setting restore_addr: %v", err)
+			}
+			restore.SetToken(client.Token())
+			if conf.Snapshot.RestoreToken != "" {
+				restore.SetToken(conf.Snapshot.RestoreToken)
+			}
+		}
+		for _, at := range points.snapshot {
+			events = append(events, &benchmarktests.ChaosEvent{
+				Name:   benchmarktests.SnapshotEvent,
+				At:     at,
+				Action: "raft snapshot",
+				Window: benchmarktests.DefaultRecoveryWindow,
+				Run: func(ctx context.Context, result *benchmarktests.ChaosEventResult) error {
+					return raftSnapshot(ctx, client, restore, result)
+				},
+			})
+		}
+	}
+	return events, nil
+}
+
+// raftSnapshot saves a snapshot of the Raft storage of the cluster to a
+// temporary file, timing how long it takes, and restores it on the scratch
+// cluster of restore when given
+func raftSnapshot(ctx context.Context, client, restore *vaultapi.Client, result *benchmarktests.ChaosEventResult) error {
+	f, err := os.CreateTemp("", "vault-benchmark-*.snap")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	result.Snapshot = &benchmarktests.SnapshotResult{}
+	started := time.Now()
+	if err := client.Sys().RaftSnapshotWithContext(ctx, f); err != nil {
+		return fmt.Errorf("error saving snapshot: %v", err)
+	}
+	result.Snapshot.Took = time.Since(started)
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("error reading snapshot file: %v", err)
+	}
+	result.Snapshot.Size = info.Size()
+
+	if restore == nil {
+		return nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error reading snapshot file: %v", err)
+	}
+	// The scratch cluster is a different cluster, so the restore is forced
+	started = time.Now()
+	if err := restore.Sys().RaftSnapshotRestoreWithContext(ctx, f, true); err != nil {
+		return fmt.Errorf("error restoring snapshot on %v: %v", restore.Address(), err)
+	}
+	took := time.Since(started)
+	result.Snapshot.RestoreTook = &took
+	return nil
+}
+
+// activeAddr returns the address of the active node, as advertised by the
+// cluster, or an empty string when it can't be found
+func activeAddr(client *vaultapi.Client) string {
+	leader, err := client.Sys().Leader()
+	if err != nil {
+		return ""
+	}
+	return leader.LeaderAddress
+}
+
+// activeKubePod returns the name of the pod of the active node, matching
+// the host of its address against the IP and DNS names of the pods
+func activeKubePod(k *kubeClient, selector, addr string) (string, error) {
+	if addr == "" {
+		return "", fmt.Errorf("the active node could not be found")
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", fmt.Errorf("error parsing active address %q: %v", addr, err)
+	}
+	host := u.Hostname()
+
+	var pods struct {
+		Items []*kubePod `json:"items"`
+	}
+	path := "/api/v1/namespaces/" + url.PathEscape(k.Namespace) + "/pods"
+	if selector != "" {
+		path += "?labelSelector=" + url.QueryEscape(selector)
+	}
+	if err := k.get(path, &pods); err != nil {
+		return "", fmt.Errorf("error listing pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		name := pod.Metadata.Name
+		if (net.ParseIP(host) != nil && host == pod.Status.PodIP) || host == name || strings.HasPrefix(host, name+".") {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no pod found for the active node at %v", addr)
+}
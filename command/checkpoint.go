@@ -0,0 +1,142 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	vaultapi "github.com/openbao/openbao/api/v2"
+)
+
+// runCheckpoint is the checkpoint_file of a run, along with the run it was
+// left by when an earlier run was interrupted
+type runCheckpoint struct {
+	hash        string
+	interrupted *benchmarktests.Checkpoint
+	resume      bool
+}
+
+// loadCheckpoint reads the checkpoint_file of the config, when it has one.
+// The run resumes the interrupted run of the same configuration, if there
+// is one.
+func (r *RunCommand) loadCheckpoint(conf *vbConfig.VaultBenchmarkCoreConfig, args []string, logger hclog.Logger) (*runCheckpoint, error) {
+	c := &runCheckpoint{}
+	if conf.Checkpoint == "" {
+		return c, nil
+	}
+	var err error
+	c.hash, err = configHash(slices.Concat([]string{r.flagVBCoreConfigPath}, conf.Includes, conf.VarFiles), args)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing configuration: %v", err)
+	}
+	c.interrupted, err = benchmarktests.LoadCheckpoint(conf.Checkpoint)
+	if err != nil {
+		return nil, fmt.Errorf("error loading checkpoint: %v", err)
+	}
+	c.resume = c.interrupted.Resumable(c.hash)
+	switch {
+	case c.resume:
+		logger.Info("resuming run from checkpoint", "path", conf.Checkpoint, "started", c.interrupted.Started.Format(time.RFC3339))
+	case c.interrupted != nil:
+		logger.Warn("checkpoint is of another configuration, starting over", "path", conf.Checkpoint)
+	}
+	return c, nil
+}
+
+// checkpointInterval returns how often the progress of the run is saved to
+// its checkpoint_file, or zero when the config has none
+func checkpointInterval(conf *vbConfig.VaultBenchmarkCoreConfig) (time.Duration, error) {
+	if conf.Checkpoint == "" {
+		return 0, nil
+	}
+	if conf.Search != nil || conf.ReplayFile != "" {
+		return 0, fmt.Errorf("checkpoint_file cannot be combined with throughput_search or replay_file")
+	}
+	interval, err := time.ParseDuration(conf.CheckpointIntv)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("checkpoint_interval must be a positive duration")
+	}
+	return interval, nil
+}
+
+// runID returns the id of the interrupted run when the run resumes it
+func (c *runCheckpoint) runID() string {
+	if !c.resume {
+		return ""
+	}
+	return c.interrupted.RunID
+}
+
+// resolveRunID returns the id of the run, which is that of the config or of
+// the run it resumes. Otherwise one is generated when the run is exported,
+// recorded or told to hooks, so what they do can be tied to the run.
+func resolveRunID(conf *vbConfig.VaultBenchmarkCoreConfig, resumed *runCheckpoint) (string, error) {
+	if conf.RunID != "" {
+		return conf.RunID, nil
+	}
+	if runID := resumed.runID(); runID != "" {
+		return runID, nil
+	}
+	if conf.RemoteWriteURL != "" || conf.InfluxFile != "" || conf.InfluxURL != "" || conf.OTLPEndpoint != "" || conf.OTLPTraces != "" || conf.HistoryDB != "" || hasHooks(conf) {
+		return uuid.GenerateUUID()
+	}
+	return "", nil
+}
+
+// file returns the file the progress of the run is saved to every interval,
+// starting from that of the interrupted run when resuming it, or nil when
+// the config has no checkpoint_file
+func (c *runCheckpoint) file(conf *vbConfig.VaultBenchmarkCoreConfig, runID string, interval time.Duration) *benchmarktests.CheckpointFile {
+	if conf.Checkpoint == "" {
+		return nil
+	}
+	state := &benchmarktests.Checkpoint{ConfigHash: c.hash, RunID: runID, Started: time.Now()}
+	if c.resume {
+		state = c.interrupted
+	}
+	return benchmarktests.NewCheckpointFile(conf.Checkpoint, interval, state)
+}
+
+// cleanup removes the mounts of the interrupted run, whether it is resumed
+// or not, as its tests are set up again
+func (c *runCheckpoint) cleanup(client *vaultapi.Client, logger hclog.Logger) {
+	if c.interrupted == nil || (len(c.interrupted.Mounts) == 0 && len(c.interrupted.Namespaces) == 0) {
+		return
+	}
+	logger.Info("removing mounts of the interrupted run", "mounts", len(c.interrupted.Mounts), "namespaces", len(c.interrupted.Namespaces))
+	if err := c.interrupted.Cleanup(client); err != nil {
+		logger.Warn("error removing mounts of the interrupted run", "error", hclog.Fmt("%v", err))
+	}
+}
+
+// configHash identifies the configuration of a run by the contents of its
+// config file, those it includes, its var files and its arguments, so it is
+// only resumed from the checkpoint of a run of the same configuration
+func configHash(paths []string, args []string) (string, error) {
+	h := sha256.New()
+	for i, path := range paths {
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("error reading config file: %v", err)
+		}
+		if i > 0 {
+			h.Write([]byte{0})
+		}
+		h.Write(contents)
+	}
+	for _, arg := range args {
+		h.Write([]byte{0})
+		h.Write([]byte(arg))
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,183 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vaultapi "github.com/openbao/openbao/api/v2"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*CleanupCommand)(nil)
+	_ cli.CommandAutocomplete = (*CleanupCommand)(nil)
+)
+
+// CleanupCommand removes the mounts and namespaces left behind by runs which
+// weren't cleaned up, such as those which crashed
+type CleanupCommand struct {
+	*BaseCommand
+	flagVaultAddr      string
+	flagVaultToken     string
+	flagVaultNamespace string
+	flagCAPEMFile      string
+	flagDryRun         bool
+}
+
+func (c *CleanupCommand) Synopsis() string {
+	return "Remove the mounts left behind by earlier runs"
+}
+
+func (c *CleanupCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark cleanup [options]
+
+ This command finds the secrets engines and auth methods enabled by the tests
+ of earlier runs, and the namespaces tests were fanned out into, and removes
+ them. Use it to recover from runs which crashed or were killed before they
+ cleaned up. Mounts are recognized by the description tests give them, and
+ namespaces by their prefix, within the namespace given. The mounts of runs
+ still in progress are removed as well.
+
+	$ vault-benchmark cleanup -vault_addr=https://vault.example.com:8200 -dry_run
+
+ For a full list of examples, please see the documentation.
+
+` + c.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *CleanupCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *CleanupCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *CleanupCommand) Flags() *FlagSets {
+	set := c.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:    "vault_addr",
+		EnvVar:  "VAULT_ADDR",
+		Target:  &c.flagVaultAddr,
+		Default: "http://127.0.0.1:8200",
+		Usage:   "Target Vault API Address.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "vault_token",
+		EnvVar:  "VAULT_TOKEN",
+		Target:  &c.flagVaultToken,
+		Default: "",
+		Usage:   "Vault Token to be used to find and remove mounts.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "vault_namespace",
+		EnvVar:  "VAULT_NAMESPACE",
+		Target:  &c.flagVaultNamespace,
+		Default: "",
+		Usage:   "Vault Namespace the tests were set up in.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "ca_pem_file",
+		Target:  &c.flagCAPEMFile,
+		EnvVar:  "VAULT_CACERT",
+		Default: "",
+		Usage:   "Path to PEM encoded CA file to verify external Vault.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "dry_run",
+		Target:  &c.flagDryRun,
+		Default: false,
+		Usage:   "List the mounts and namespaces which would be removed without removing them.",
+	})
+	return set
+}
+
+func (c *CleanupCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.flagVaultToken == "" {
+		c.UI.Error("no vault token set")
+		return 1
+	}
+
+	cfg := vaultapi.DefaultConfig()
+	if c.flagCAPEMFile != "" {
+		if err := cfg.ConfigureTLS(&vaultapi.TLSConfig{CACert: c.flagCAPEMFile}); err != nil {
+			c.UI.Error(fmt.Sprintf("error configuring TLS: %v", err))
+			return 1
+		}
+	}
+	cfg.Address = c.flagVaultAddr
+	client, err := vaultapi.NewClient(cfg)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error creating vault client: %v", err))
+		return 1
+	}
+	client.SetToken(c.flagVaultToken)
+	client.SetNamespace(c.flagVaultNamespace)
+
+	mounts, err := benchmarktests.DiscoverMounts(client)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	// Servers without namespaces have none to remove
+	namespaces, err := benchmarktests.DiscoverNamespaces(client)
+	if err != nil {
+		c.UI.Warn(fmt.Sprintf("skipping namespaces: %v", err))
+	}
+
+	if len(mounts) == 0 && len(namespaces) == 0 {
+		c.UI.Output("No mounts or namespaces of earlier runs found")
+		return 0
+	}
+
+	action := "Removed"
+	if c.flagDryRun {
+		action = "Would remove"
+	}
+	var failed int
+	for _, mount := range mounts {
+		if !c.flagDryRun {
+			if err := benchmarktests.RemoveMount(client, mount.Path); err != nil {
+				c.UI.Error(fmt.Sprintf("error removing %v: %v", mount.Path, err))
+				failed++
+				continue
+			}
+		}
+		c.UI.Output(fmt.Sprintf("%v %v (%v) of test %v", action, mount.Path, mount.Type, mount.Test))
+	}
+	for _, ns := range namespaces {
+		if !c.flagDryRun {
+			if err := benchmarktests.RemoveNamespace(client, ns); err != nil {
+				c.UI.Error(fmt.Sprintf("error removing namespace %v: %v", ns, err))
+				failed++
+				continue
+			}
+		}
+		c.UI.Output(fmt.Sprintf("%v namespace %v", action, ns))
+	}
+	if failed > 0 {
+		c.UI.Error(fmt.Sprintf("failed to remove %d of %d mounts and namespaces", failed, len(mounts)+len(namespaces)))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,203 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// chooseCluster applies the cluster chosen by the cluster flag to the config,
+// returning whether the run is instead against every cluster of the config
+func (r *RunCommand) chooseCluster(conf *vbConfig.VaultBenchmarkCoreConfig) (bool, error) {
+	if r.flagCluster != "" {
+		if err := conf.ApplyCluster(r.flagCluster); err != nil {
+			return false, fmt.Errorf("error applying cluster: %w", err)
+		}
+		return false, nil
+	}
+	if len(conf.Clusters) == 0 {
+		return false, nil
+	}
+
+	switch {
+	// The runs of every cluster would share the checkpoint
+	case conf.Checkpoint != "":
+		return false, fmt.Errorf("checkpoint_file cannot be combined with clusters unless one is chosen")
+	case r.flagSetupOnly || r.flagAttackOnly:
+		return false, fmt.Errorf("setup_only and attack_only cannot be combined with clusters unless one is chosen")
+	}
+	return true, nil
+}
+
+// runClusters runs the tests of the config against each of its clusters,
+// one after another or all at once, and writes the results of every
+// cluster alongside a comparison of them. Each cluster is run as its own
+// benchmark, re-running the command with the cluster chosen.
+func (r *RunCommand) runClusters(args []string, conf *vbConfig.VaultBenchmarkCoreConfig, logger hclog.Logger) int {
+	switch conf.ReportMode {
+	case "terse", "verbose", "json", "csv", "markdown":
+	default:
+		logger.Error("report_mode must be one of terse, verbose, json, csv, or markdown")
+		return 1
+	}
+
+	var percentiles []float64
+	if conf.Percentiles != "" {
+		var err error
+		percentiles, err = benchmarktests.ParsePercentiles(conf.Percentiles)
+		if err != nil {
+			logger.Error("error parsing report_percentiles", "error", hclog.Fmt("%v", err))
+			return 1
+		}
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		logger.Error("error locating executable", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	names := make([]string, len(conf.Clusters))
+	outputs := make([][]byte, len(conf.Clusters))
+	errs := make([]error, len(conf.Clusters))
+	run := func(i int) {
+		logger.Info("running benchmark against cluster", "cluster", names[i])
+		outputs[i], errs[i] = runCluster(executable, clusterRunArgs(args, names[i]), names[i])
+	}
+	for i, cluster := range conf.Clusters {
+		names[i] = cluster.Name
+	}
+	if conf.ClusterMode == vbConfig.ConcurrentClusterMode {
+		var wg sync.WaitGroup
+		for i := range names {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range names {
+			run(i)
+		}
+	}
+
+	// Compare whatever the clusters gathered, even when some runs failed
+	failed := false
+	clusterRpts := make([][]*benchmarktests.Reporter, len(names))
+	for i, name := range names {
+		if errs[i] != nil {
+			logger.Error("benchmark failed against cluster", "cluster", name, "error", hclog.Fmt("%v", errs[i]))
+			failed = true
+		}
+		rpts, err := benchmarktests.FromReader(bytes.NewReader(outputs[i]))
+		if err != nil {
+			logger.Error("error reading cluster results", "cluster", name, "error", hclog.Fmt("%v", err))
+			failed = true
+			continue
+		}
+		for _, rpt := range rpts {
+			rpt.SetPercentiles(percentiles)
+		}
+		clusterRpts[i] = rpts
+	}
+
+	if err := writeClusterReports(names, clusterRpts, conf.ReportMode); err != nil {
+		logger.Error("error writing report", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// clusterRunArgs are the arguments of the run command against one of the
+// clusters of the config. Results are returned to the comparing run, so the
+// outputs recording them are disabled here.
+func clusterRunArgs(args []string, name string) []string {
+	return append(append([]string{"run"}, args...),
+		"-cluster="+name,
+		"-report_mode=json",
+		"-baseline=",
+		"-history_db=",
+		"-junit_file=",
+		"-live=false",
+	)
+}
+
+// runCluster runs the benchmark against a cluster, returning its JSON
+// results. Its logs are passed through, prefixed with the name of the
+// cluster to tell apart the logs of concurrent runs.
+func runCluster(executable string, args []string, name string) ([]byte, error) {
+	cmd := exec.Command(executable, args...)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		fmt.Fprintf(os.Stderr, "[%s] %s\n", name, scanner.Text())
+	}
+
+	err = cmd.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.Bytes(), fmt.Errorf("exit code %d", exitErr.ExitCode())
+	}
+	return stdout.Bytes(), err
+}
+
+// writeClusterReports writes the results of each cluster to stdout in the
+// given report mode. Text reports end with a table comparing the clusters,
+// which is all terse reports show.
+func writeClusterReports(names []string, clusterRpts [][]*benchmarktests.Reporter, reportMode string) error {
+	var rpts []*benchmarktests.Reporter
+	for _, cRpts := range clusterRpts {
+		rpts = append(rpts, cRpts...)
+	}
+	switch reportMode {
+	case "csv":
+		return benchmarktests.ReportCSV(os.Stdout, rpts)
+	case "markdown":
+		return benchmarktests.ReportMarkdown(os.Stdout, rpts, nil)
+	case "json":
+		for _, rpt := range rpts {
+			if err := rpt.ReportJSON(os.Stdout); err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+		return nil
+	case "verbose":
+		for i, cRpts := range clusterRpts {
+			fmt.Printf("Cluster %s:\n", names[i])
+			for _, rpt := range cRpts {
+				if err := rpt.ReportVerbose(os.Stdout); err != nil {
+					return err
+				}
+				fmt.Println()
+			}
+		}
+		fmt.Println("Comparison:")
+	}
+	return benchmarktests.WriteComparison(os.Stdout, names, benchmarktests.CompareClusters(clusterRpts))
+}
@@ -0,0 +1,458 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+var (
+	_ cli.Command             = (*CoordinatorCommand)(nil)
+	_ cli.CommandAutocomplete = (*CoordinatorCommand)(nil)
+)
+
+// CoordinatorCommand splits a benchmark between workers and merges their
+// results
+type CoordinatorCommand struct {
+	*BaseCommand
+	flagConfigPath  string
+	flagVars        map[string]string
+	flagVarFiles    []string
+	flagWorkers     string
+	flagReportMode  string
+	flagPercentiles string
+	flagToken       string
+	flagTLSCAFile   string
+	flagTLSCertFile string
+	flagTLSKeyFile  string
+	flagStartDelay  time.Duration
+}
+
+func (c *CoordinatorCommand) Synopsis() string {
+	return "Run a benchmark across several workers"
+}
+
+func (c *CoordinatorCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark coordinator [options]
+
+ This command sends a benchmark configuration to a set of workers, each
+ running an equal share of the load and starting at the same time, then
+ merges their results into a single report.
+
+	$ vault-benchmark coordinator -config=config.hcl -workers=10.0.0.1:8210,10.0.0.2:8210
+
+ For a full list of examples, please see the documentation.
+
+` + c.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *CoordinatorCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *CoordinatorCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *CoordinatorCommand) Flags() *FlagSets {
+	set := c.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:   "config",
+		Target: &c.flagConfigPath,
+		Completion: complete.PredictOr(
+			complete.PredictFiles("*.hcl"),
+			complete.PredictFiles("*.json"),
+		),
+		Usage: "Path to a vault-benchmark test configuration file. The files it reads, such as included files and " +
+			"data files, are sent to the workers along with it, so must be within the working directory or below " +
+			"a directory it shares with the config.",
+	})
+
+	f.StringMapVar(&StringMapVar{
+		Name:   "var",
+		Target: &c.flagVars,
+		Usage: "Value of a variable declared by the config, as name=value, overriding its default and var_file. " +
+			"Can be given multiple times.",
+	})
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:       "var_file",
+		Target:     &c.flagVarFiles,
+		Completion: complete.PredictOr(complete.PredictFiles("*.hcl"), complete.PredictFiles("*.json")),
+		Usage: "Path to a file setting the values of variables declared by the config, overriding their defaults. " +
+			"Can be given multiple times; later files override earlier ones. Var files are sent to the workers.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "workers",
+		Target:  &c.flagWorkers,
+		Default: "",
+		Usage:   "Comma-separated addresses of the workers to run the benchmark on.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "report_mode",
+		Target:  &c.flagReportMode,
+		Default: "terse",
+		Usage:   "Reporting Mode. Options are: terse, verbose, json, csv, markdown.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "report_percentiles",
+		Target:  &c.flagPercentiles,
+		Default: "",
+		Usage:   "Comma-separated latency percentiles to report, e.g. p50,p99.9,max.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "start_delay",
+		Target:  &c.flagStartDelay,
+		Default: 30 * time.Second,
+		Usage:   "Time given to workers to set up their tests before all of them start attacking together.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "tls_ca_file",
+		Target:     &c.flagTLSCAFile,
+		Default:    "",
+		Completion: complete.PredictFiles("*.pem"),
+		Usage:      "Path to a PEM encoded CA certificate to verify the workers with. Workers are connected to without TLS when not set.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "tls_cert_file",
+		Target:     &c.flagTLSCertFile,
+		Default:    "",
+		Completion: complete.PredictFiles("*.pem"),
+		Usage:      "Path to a PEM encoded client certificate to present to workers requiring one. Requires tls_ca_file.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "tls_key_file",
+		Target:     &c.flagTLSKeyFile,
+		Default:    "",
+		Completion: complete.PredictFiles("*.pem"),
+		Usage:      "Path to the PEM encoded private key of tls_cert_file.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "token",
+		Target:  &c.flagToken,
+		Default: "",
+		EnvVar:  "VAULT_BENCHMARK_WORKER_TOKEN",
+		Usage:   "Token the workers require with every benchmark.",
+	})
+	return set
+}
+
+func (c *CoordinatorCommand) Run(args []string) int {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:  "vault-benchmark-coordinator",
+		Level: hclog.Info,
+	})
+
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.flagConfigPath == "" {
+		c.UI.Error("no config file location passed")
+		return 1
+	}
+	var workers []string
+	for _, addr := range strings.Split(c.flagWorkers, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			workers = append(workers, addr)
+		}
+	}
+	if len(workers) == 0 {
+		c.UI.Error("no workers passed")
+		return 1
+	}
+
+	switch c.flagReportMode {
+	case "terse", "verbose", "json", "csv", "markdown":
+	default:
+		c.UI.Error("report_mode must be one of terse, verbose, json, csv, or markdown")
+		return 1
+	}
+
+	var percentiles []float64
+	if c.flagPercentiles != "" {
+		var err error
+		percentiles, err = benchmarktests.ParsePercentiles(c.flagPercentiles)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("error parsing report percentiles: %v", err))
+			return 1
+		}
+	}
+
+	// Workers run the config from a copy of the files it reads, so paths
+	// are made relative to the working directory, which the copy recreates
+	cwd, err := os.Getwd()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error getting working directory: %v", err))
+		return 1
+	}
+	configPath, err := relativePath(cwd, c.flagConfigPath)
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	varFiles := make([]string, len(c.flagVarFiles))
+	for i, varFile := range c.flagVarFiles {
+		if varFiles[i], err = relativePath(cwd, varFile); err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+	}
+
+	// Check the config before handing it out, so mistakes are reported
+	// once rather than by every worker
+	conf := vbConfig.NewVaultBenchmarkCoreConfig()
+	conf.Vars = c.flagVars
+	conf.VarFiles = varFiles
+	if err := conf.LoadConfig(configPath); err != nil {
+		c.UI.Error(fmt.Sprintf("error loading config: %v", err))
+		return 1
+	}
+	if conf.Search != nil || conf.ReplayFile != "" {
+		c.UI.Error("throughput_search and replay_file cannot be split between workers")
+		return 1
+	}
+	if err := conf.ApplyLoadShare(1, len(workers)); err != nil {
+		c.UI.Error(fmt.Sprintf("error sharing load between workers: %v", err))
+		return 1
+	}
+	bundle, err := bundleConfig(cwd, configPath, conf.ReferencedFiles())
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error bundling config for workers: %v", err))
+		return 1
+	}
+	bundle.Vars = c.flagVars
+	for _, varFile := range varFiles {
+		bundle.VarFiles = append(bundle.VarFiles, filepath.ToSlash(varFile))
+	}
+
+	creds := insecure.NewCredentials()
+	switch {
+	case (c.flagTLSCertFile == "") != (c.flagTLSKeyFile == ""):
+		c.UI.Error("tls_cert_file and tls_key_file must be set together")
+		return 1
+	case c.flagTLSCertFile != "" && c.flagTLSCAFile == "":
+		c.UI.Error("tls_cert_file requires tls_ca_file")
+		return 1
+	case c.flagTLSCAFile != "":
+		tlsConfig, err := coordinatorTLSConfig(c.flagTLSCAFile, c.flagTLSCertFile, c.flagTLSKeyFile)
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+
+	startAt := time.Now().Add(c.flagStartDelay)
+	logger.Info("starting benchmark on workers", "workers", len(workers), "start_at", startAt.Format(time.RFC3339))
+
+	responses := make([]*benchmarktests.WorkerRunResponse, len(workers))
+	errs := make([]error, len(workers))
+	var wg sync.WaitGroup
+	for i, addr := range workers {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer conn.Close()
+
+			req := *bundle
+			req.Part = i + 1
+			req.Parts = len(workers)
+			req.StartAt = startAt
+			responses[i], errs[i] = benchmarktests.CallWorker(context.Background(), conn, c.flagToken, &req)
+		}(i, addr)
+	}
+	wg.Wait()
+
+	// Report whatever the workers gathered, even when some failed
+	failed := false
+	var rpts []*benchmarktests.Reporter
+	for i, addr := range workers {
+		if errs[i] != nil {
+			logger.Error("worker failed", "worker", addr, "error", hclog.Fmt("%v", errs[i]))
+			failed = true
+			continue
+		}
+		if responses[i].ExitCode != 0 {
+			logger.Error("benchmark failed on worker", "worker", addr, "exit_code", responses[i].ExitCode)
+			failed = true
+		}
+		workerRpts, err := benchmarktests.FromReader(bytes.NewReader(responses[i].Results))
+		if err != nil {
+			logger.Error("error reading worker results", "worker", addr, "error", hclog.Fmt("%v", err))
+			failed = true
+			continue
+		}
+		rpts = append(rpts, workerRpts...)
+	}
+	if len(rpts) == 0 {
+		c.UI.Error("no worker returned results")
+		return 1
+	}
+
+	if err := writeMergedReports(rpts, c.flagReportMode, percentiles); err != nil {
+		c.UI.Error(fmt.Sprintf("error writing report: %v", err))
+		return 1
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// relativePath returns path relative to the working directory cwd
+func relativePath(cwd, path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return filepath.Clean(path), nil
+	}
+	rel, err := filepath.Rel(cwd, path)
+	if err != nil {
+		return "", fmt.Errorf("error resolving %v against the working directory: %v", path, err)
+	}
+	return rel, nil
+}
+
+// bundleConfig reads the config and the files it reads, given relative to
+// the working directory cwd, into a request for workers. The bundle is
+// rooted at the deepest directory holding both the working directory and
+// every file, and runs are started in the working directory's place in it,
+// so relative paths, such as those of data files, resolve on workers as they
+// do here. Absolute paths written in the config would be read from the
+// filesystem of the workers rather than the bundle, so are rejected.
+func bundleConfig(cwd, configPath string, files []string) (*benchmarktests.WorkerRunRequest, error) {
+	paths := append([]string{configPath}, files...)
+	root := cwd
+	for _, path := range paths {
+		if filepath.IsAbs(path) {
+			return nil, fmt.Errorf("%v is an absolute path, which workers would read from their own filesystem; "+
+				"refer to it relative to the config instead", path)
+		}
+		for !isWithin(root, filepath.Join(cwd, path)) {
+			root = filepath.Dir(root)
+		}
+	}
+
+	dir, err := filepath.Rel(root, cwd)
+	if err != nil {
+		return nil, err
+	}
+	req := &benchmarktests.WorkerRunRequest{
+		Files:  make(map[string][]byte, len(paths)),
+		Dir:    filepath.ToSlash(dir),
+		Config: filepath.ToSlash(configPath),
+	}
+	for _, path := range paths {
+		abs := filepath.Join(cwd, path)
+		name, err := filepath.Rel(root, abs)
+		if err != nil {
+			return nil, err
+		}
+		buf, err := os.ReadFile(abs)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %v: %v", path, err)
+		}
+		req.Files[filepath.ToSlash(name)] = buf
+	}
+	return req, nil
+}
+
+// isWithin reports whether path is below the directory dir
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// coordinatorTLSConfig verifies workers with the CA, and presents the client
+// certificate, when one is given, to workers requiring one
+func coordinatorTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading TLS CA certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %v", caFile)
+	}
+	config := &tls.Config{
+		RootCAs:    pool,
+		MinVersion: tls.VersionTLS12,
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading TLS client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// writeMergedReports merges the results of the shares of a distributed run
+// and writes them to stdout in the given report mode
+func writeMergedReports(rpts []*benchmarktests.Reporter, reportMode string, percentiles []float64) error {
+	rpts = benchmarktests.MergeReports(rpts)
+	for _, rpt := range rpts {
+		rpt.SetPercentiles(percentiles)
+	}
+	switch reportMode {
+	case "csv":
+		return benchmarktests.ReportCSV(os.Stdout, rpts)
+	case "markdown":
+		return benchmarktests.ReportMarkdown(os.Stdout, rpts, nil)
+	}
+	for _, rpt := range rpts {
+		var err error
+		switch reportMode {
+		case "json":
+			err = rpt.ReportJSON(os.Stdout)
+		case "verbose":
+			err = rpt.ReportVerbose(os.Stdout)
+		default:
+			err = rpt.ReportTerse(os.Stdout)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// chdir changes the working directory for the rest of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestCoordinator_BundlesConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, contents string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("configs/main.hcl", `
+variable "rps" {}
+variable "duration" {}
+include  = ["common.hcl"]
+rps      = var.rps
+duration = var.duration
+test "kvv2_write" "write" {
+  weight = 100
+}
+`)
+	writeFile("configs/common.hcl", `
+vault_addr  = "http://127.0.0.1:8200"
+vault_token = trimspace(file("token"))
+`)
+	writeFile("configs/token", "s.token\n")
+	writeFile("work/vars.hcl", `rps = 20`)
+
+	// The coordinator is run from a directory beside the config, so the
+	// bundle must hold both
+	cwd := filepath.Join(dir, "work")
+	chdir(t, cwd)
+	conf := vbConfig.NewVaultBenchmarkCoreConfig()
+	conf.VarFiles = []string{"vars.hcl"}
+	conf.Vars = map[string]string{"duration": "1m"}
+	configPath := filepath.Join("..", "configs", "main.hcl")
+	if err := conf.LoadConfig(configPath); err != nil {
+		t.Fatal(err)
+	}
+	req, err := bundleConfig(cwd, configPath, conf.ReferencedFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.VarFiles = []string{"vars.hcl"}
+	req.Vars = conf.Vars
+	var names []string
+	for name := range req.Files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	wantNames := []string{"configs/common.hcl", "configs/main.hcl", "configs/token", "work/vars.hcl"}
+	if !reflect.DeepEqual(names, wantNames) || req.Dir != "work" || req.Config != "../configs/main.hcl" {
+		t.Fatalf("unexpected bundle: files %v, dir %q, config %q", names, req.Dir, req.Config)
+	}
+
+	// Workers load the config from the bundle as the coordinator did
+	workerDir := t.TempDir()
+	runDir, err := writeWorkerBundle(workerDir, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, runDir)
+	workerConf := vbConfig.NewVaultBenchmarkCoreConfig()
+	workerConf.VarFiles = req.VarFiles
+	workerConf.Vars = req.Vars
+	if err := workerConf.LoadConfig(req.Config); err != nil {
+		t.Fatalf("error loading bundled config: %v", err)
+	}
+	if workerConf.RPS != 20 || workerConf.Duration != "1m" || workerConf.VaultToken != "s.token" {
+		t.Fatalf("expected the options of every bundled file, got: %+v", workerConf)
+	}
+
+	// The worker runs the share from the run directory of the bundle, with
+	// the variables given to the coordinator
+	executable := filepath.Join(t.TempDir(), "vault-benchmark")
+	if err := os.WriteFile(executable, []byte("#!/bin/sh\necho \"$@\"\npwd\ncat vars.hcl\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	worker := &workerServer{executable: executable, logger: hclog.NewNullLogger()}
+	resp, err := worker.Run(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(resp.Results)
+	for _, want := range []string{"-config=../configs/main.hcl", "-var_file=vars.hcl", "-var=duration=1m", "/work\nrps = 20"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected worker run output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// Paths which would be read from the worker's own filesystem, or
+	// written outside of the bundle, are rejected
+	if _, err := bundleConfig(cwd, configPath, []string{"/etc/hosts"}); err == nil || !strings.Contains(err.Error(), "absolute path") {
+		t.Fatalf("expected absolute paths to be rejected, got: %v", err)
+	}
+	for _, bad := range []*benchmarktests.WorkerRunRequest{
+		{Dir: ".", Config: "config.hcl", Files: map[string][]byte{"../escape.hcl": nil}},
+		{Dir: "..", Config: "config.hcl"},
+		{Dir: ".", Config: "../../config.hcl"},
+	} {
+		if _, err := writeWorkerBundle(t.TempDir(), bad); err == nil {
+			t.Fatalf("expected bundle %+v to be rejected", bad)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*DashboardCommand)(nil)
+	_ cli.CommandAutocomplete = (*DashboardCommand)(nil)
+)
+
+type DashboardCommand struct {
+	*BaseCommand
+	flagMetrics  string
+	flagRunLabel string
+	flagRunID    string
+	flagTitle    string
+	flagOutput   string
+}
+
+func (d *DashboardCommand) Synopsis() string {
+	return "Generate a Grafana dashboard for exported metrics"
+}
+
+func (d *DashboardCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark dashboard [options]
+
+ This command prints a Grafana dashboard, ready to import, which charts the
+ metrics pushed to a Prometheus remote-write endpoint or exported over OTLP.
+
+	$ vault-benchmark dashboard -metrics=remote_write -output=dashboard.json
+
+ For a full list of examples, please see the documentation.
+
+` + d.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (d *DashboardCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (d *DashboardCommand) AutocompleteFlags() complete.Flags {
+	return d.Flags().Completions()
+}
+
+func (d *DashboardCommand) Flags() *FlagSets {
+	set := d.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "metrics",
+		Target:     &d.flagMetrics,
+		Default:    benchmarktests.RemoteWriteDashboard,
+		Completion: complete.PredictSet(benchmarktests.RemoteWriteDashboard, benchmarktests.OTelDashboard),
+		Usage:      "Exported metrics to chart. Options are: remote_write, otel.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "run_label",
+		Target:  &d.flagRunLabel,
+		Default: "",
+		Usage:   "Label holding the run ID of each series. Defaults to run_id, or benchmark_run_id for otel.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "run_id",
+		Target:  &d.flagRunID,
+		Default: "",
+		Usage:   "Run selected when the dashboard is opened. Defaults to all runs.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "title",
+		Target:  &d.flagTitle,
+		Default: "OpenBao Benchmark",
+		Usage:   "Title of the dashboard.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "output",
+		Target:     &d.flagOutput,
+		Default:    "",
+		Completion: complete.PredictFiles("*.json"),
+		Usage:      "Path to file to write the dashboard to. Defaults to stdout.",
+	})
+	return set
+}
+
+func (d *DashboardCommand) Run(args []string) int {
+	f := d.Flags()
+
+	if err := f.Parse(args); err != nil {
+		d.UI.Error(err.Error())
+		return 1
+	}
+
+	dashboard, err := benchmarktests.GrafanaDashboard(&benchmarktests.DashboardConfig{
+		Title:    d.flagTitle,
+		Metrics:  d.flagMetrics,
+		RunLabel: d.flagRunLabel,
+		RunID:    d.flagRunID,
+	})
+	if err != nil {
+		d.UI.Error(fmt.Sprintf("error generating dashboard: %v", err))
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if d.flagOutput != "" {
+		out, err := os.Create(d.flagOutput)
+		if err != nil {
+			d.UI.Error(fmt.Sprintf("error creating dashboard file: %v", err))
+			return 1
+		}
+		defer out.Close()
+		w = out
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(dashboard); err != nil {
+		d.UI.Error(fmt.Sprintf("error writing dashboard: %v", err))
+		return 1
+	}
+	return 0
+}
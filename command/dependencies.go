@@ -0,0 +1,112 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// dependencyTimeout is how long a dependency has to become ready
+const dependencyTimeout = 3 * time.Minute
+
+// dependency is a backing service container started for the length of a
+// run
+type dependency struct {
+	config    *vbConfig.DependencyConfig
+	logger    hclog.Logger
+	container string
+}
+
+// startDependencies starts a container for each dependency and waits for
+// all of them to become ready, removing those already started when one
+// fails
+func startDependencies(deps []*vbConfig.DependencyConfig, logger hclog.Logger) ([]*dependency, error) {
+	var started []*dependency
+	for _, d := range deps {
+		dep, err := startDependency(d, logger.Named(d.Kind))
+		if err != nil {
+			for _, s := range started {
+				s.Stop()
+			}
+			return nil, fmt.Errorf("error starting dependency %v: %v", d.Kind, err)
+		}
+		started = append(started, dep)
+	}
+	return started, nil
+}
+
+// startDependency starts the container of a dependency, publishing the
+// service on the planned local port, and waits for it to become ready
+func startDependency(d *vbConfig.DependencyConfig, logger hclog.Logger) (*dependency, error) {
+	// Ports are only published on the loopback interface unless OpenBao
+	// reaches them on another host, such as from a container
+	publish := fmt.Sprintf("%d:%d", d.Port, d.ContainerPort)
+	if d.Host == vbConfig.DefaultDependencyHost {
+		publish = vbConfig.DefaultDependencyHost + ":" + publish
+	}
+	args := []string{"run", "--detach", "--rm", "--publish", publish}
+	names := make([]string, 0, len(d.Env))
+	for name := range d.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "--env", name+"="+d.Env[name])
+	}
+	out, err := exec.Command("docker", append(args, d.Image)...).Output()
+	if err != nil {
+		return nil, commandError(err)
+	}
+
+	dep := &dependency{
+		config:    d,
+		logger:    logger,
+		container: string(bytes.TrimSpace(out)),
+	}
+	logger.Info("started dependency container", "image", d.Image, "container", dep.container, "port", d.Port)
+	if err := dep.waitReady(); err != nil {
+		dep.Stop()
+		return nil, err
+	}
+	return dep, nil
+}
+
+// waitReady runs the readiness check of the dependency in its container
+// until it succeeds
+func (d *dependency) waitReady() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dependencyTimeout)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		args := append([]string{"exec", d.container}, d.config.Ready...)
+		if err := exec.CommandContext(ctx, "docker", args...).Run(); err == nil {
+			d.logger.Info("dependency is ready")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("container did not become ready within %v", dependencyTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop removes the container of the dependency
+func (d *dependency) Stop() {
+	if _, err := exec.Command("docker", "rm", "--force", d.container).Output(); err != nil {
+		d.logger.Error("error removing dependency container", "container", d.container, "error", hclog.Fmt("%v", commandError(err)))
+		return
+	}
+	d.logger.Info("removed dependency container", "container", d.container)
+}
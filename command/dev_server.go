@@ -0,0 +1,164 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
+)
+
+// devImagePrefix marks a dev_target naming a docker image rather than an
+// OpenBao binary
+const devImagePrefix = "docker://"
+
+// devServerTimeout is how long a dev server has to become ready
+const devServerTimeout = time.Minute
+
+// devServer is an OpenBao dev server started for the length of a run
+type devServer struct {
+	Addr  string
+	Token string
+
+	logger    hclog.Logger
+	cmd       *exec.Cmd
+	output    bytes.Buffer
+	exited    chan struct{}
+	container string
+}
+
+// startDevServer starts a dev server from an OpenBao binary, or a docker
+// image when target is prefixed with docker://, listening on a free local
+// port, and waits for it to become ready
+func startDevServer(target string, logger hclog.Logger) (*devServer, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("error finding a free port: %v", err)
+	}
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	token, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, fmt.Errorf("error generating root token: %v", err)
+	}
+
+	s := &devServer{
+		Addr:   "http://127.0.0.1:" + strconv.Itoa(port),
+		Token:  token,
+		logger: logger,
+		exited: make(chan struct{}),
+	}
+	args := []string{"server", "-dev", "-dev-root-token-id=" + token}
+	if image, ok := strings.CutPrefix(target, devImagePrefix); ok {
+		runArgs := []string{"run", "--detach", "--rm", "--publish", fmt.Sprintf("127.0.0.1:%d:8200", port), image}
+		out, err := exec.Command("docker", append(runArgs, append(args, "-dev-listen-address=0.0.0.0:8200")...)...).Output()
+		if err != nil {
+			return nil, fmt.Errorf("error starting dev server container: %v", commandError(err))
+		}
+		s.container = string(bytes.TrimSpace(out))
+		logger.Info("started dev server container", "image", image, "container", s.container, "address", s.Addr)
+	} else {
+		s.cmd = exec.Command(target, append(args, "-dev-listen-address="+strings.TrimPrefix(s.Addr, "http://"))...)
+		s.cmd.Stdout = &s.output
+		s.cmd.Stderr = &s.output
+		if err := s.cmd.Start(); err != nil {
+			return nil, fmt.Errorf("error starting dev server: %v", err)
+		}
+		go func() {
+			_ = s.cmd.Wait()
+			close(s.exited)
+		}()
+		logger.Info("started dev server", "binary", target, "pid", s.cmd.Process.Pid, "address", s.Addr)
+	}
+
+	if err := s.waitReady(); err != nil {
+		s.Stop()
+		return nil, err
+	}
+	return s, nil
+}
+
+// waitReady polls the health of the server until it is unsealed and
+// active
+func (s *devServer) waitReady() error {
+	ctx, cancel := context.WithTimeout(context.Background(), devServerTimeout)
+	defer cancel()
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Addr+"/v1/sys/health", nil)
+		if err != nil {
+			return err
+		}
+		if resp, err := http.DefaultClient.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("dev server did not become ready within %v", devServerTimeout)
+		case <-s.exitedEarly():
+			return fmt.Errorf("dev server exited: %s", bytes.TrimSpace(s.output.Bytes()))
+		case <-ticker.C:
+		}
+	}
+}
+
+// exitedEarly is closed when a dev server binary exits. The exit of a
+// container is only noticed by its health checks timing out.
+func (s *devServer) exitedEarly() <-chan struct{} {
+	if s.cmd == nil {
+		return nil
+	}
+	return s.exited
+}
+
+// Stop tears the dev server down, giving a binary a few seconds to shut
+// down before killing it
+func (s *devServer) Stop() {
+	if s.container != "" {
+		if _, err := exec.Command("docker", "rm", "--force", s.container).Output(); err != nil {
+			s.logger.Error("error removing dev server container", "container", s.container, "error", hclog.Fmt("%v", commandError(err)))
+			return
+		}
+		s.logger.Info("removed dev server container", "container", s.container)
+		return
+	}
+	if err := s.cmd.Process.Signal(os.Interrupt); err == nil {
+		select {
+		case <-s.exited:
+			s.logger.Info("stopped dev server")
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
+	_ = s.cmd.Process.Kill()
+	<-s.exited
+	s.logger.Info("stopped dev server")
+}
+
+// commandError adds what a command, such as docker, wrote to stderr to the
+// error of it failing
+func commandError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+		return fmt.Errorf("%v: %s", err, bytes.TrimSpace(exitErr.Stderr))
+	}
+	return err
+}
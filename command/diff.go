@@ -0,0 +1,119 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*DiffCommand)(nil)
+	_ cli.CommandAutocomplete = (*DiffCommand)(nil)
+)
+
+type DiffCommand struct {
+	*BaseCommand
+	flagThreshold float64
+}
+
+func (d *DiffCommand) Synopsis() string {
+	return "Compare the results of two test runs"
+}
+
+func (d *DiffCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark diff [options] BASE_RESULTS NEW_RESULTS
+
+ This command compares two JSON test results files, such as runs of the same
+ tests against two OpenBao versions, and prints the change of the
+ throughput, latency and error rate of each test.
+
+	$ vault-benchmark diff -threshold=10 before.json after.json
+
+ For a full list of examples, please see the documentation.
+
+` + d.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (d *DiffCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*.json")
+}
+
+func (d *DiffCommand) AutocompleteFlags() complete.Flags {
+	return d.Flags().Completions()
+}
+
+func (d *DiffCommand) Flags() *FlagSets {
+	set := d.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.Float64Var(&Float64Var{
+		Name:    "threshold",
+		Target:  &d.flagThreshold,
+		Default: benchmarktests.DefaultDiffThreshold,
+		Usage:   "Percentage change beyond which a difference is marked as better or worse.",
+	})
+	return set
+}
+
+func (d *DiffCommand) Run(args []string) int {
+	f := d.Flags()
+
+	if err := f.Parse(args); err != nil {
+		d.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	if len(args) != 2 {
+		d.UI.Error(fmt.Sprintf("expected 2 results files, got %d", len(args)))
+		return 1
+	}
+	if d.flagThreshold < 0 {
+		d.UI.Error("threshold must not be negative")
+		return 1
+	}
+
+	var runs [2][]*benchmarktests.Reporter
+	for i, path := range args {
+		rpts, err := readResultsFile(path)
+		if err != nil {
+			d.UI.Error(fmt.Sprintf("error reading %v: %v", path, err))
+			return 1
+		}
+		runs[i] = rpts
+	}
+
+	diffs := benchmarktests.DiffReports(runs[0], runs[1])
+	if err := benchmarktests.WriteDiff(os.Stdout, diffs, d.flagThreshold); err != nil {
+		d.UI.Error(fmt.Sprintf("error writing diff: %v", err))
+		return 1
+	}
+	return 0
+}
+
+// readResultsFile reads every report from a JSON results file
+func readResultsFile(path string) ([]*benchmarktests.Reporter, error) {
+	fReader, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer fReader.Close()
+
+	rpts, err := benchmarktests.FromReader(fReader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading report: %v", err)
+	}
+	if len(rpts) == 0 {
+		return nil, fmt.Errorf("results file contains no valid reports")
+	}
+	return rpts, nil
+}
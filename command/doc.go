@@ -0,0 +1,104 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*DocCommand)(nil)
+	_ cli.CommandAutocomplete = (*DocCommand)(nil)
+)
+
+// DocCommand prints a reference of the options each test type accepts
+type DocCommand struct {
+	*BaseCommand
+	flagFormat string
+}
+
+func (d *DocCommand) Synopsis() string {
+	return "Prints a reference of the options of each test"
+}
+
+func (d *DocCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark doc [options] [TEST_TYPE...]
+
+ This command prints the options the config block of each test type accepts,
+ with their types, whether they are required, their defaults and the
+ environment variables they are read from. Only the given test types are
+ described, or every test type when none are given.
+
+	$ vault-benchmark doc postgresql_secret
+
+ For a full list of examples, please see the documentation.
+
+` + d.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (d *DocCommand) AutocompleteArgs() complete.Predictor {
+	testTypes := make([]string, 0, len(benchmarktests.TestList))
+	for testType := range benchmarktests.TestList {
+		testTypes = append(testTypes, testType)
+	}
+	sort.Strings(testTypes)
+	return complete.PredictSet(testTypes...)
+}
+
+func (d *DocCommand) AutocompleteFlags() complete.Flags {
+	return d.Flags().Completions()
+}
+
+func (d *DocCommand) Flags() *FlagSets {
+	set := d.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "format",
+		Target:     &d.flagFormat,
+		Default:    "markdown",
+		Completion: complete.PredictSet("markdown", "json"),
+		Usage:      "Format of the reference. Options are: markdown, json.",
+	})
+	return set
+}
+
+func (d *DocCommand) Run(args []string) int {
+	f := d.Flags()
+
+	if err := f.Parse(args); err != nil {
+		d.UI.Error(err.Error())
+		return 1
+	}
+
+	refs, err := benchmarktests.TestReferences(f.Args()...)
+	if err != nil {
+		d.UI.Error(fmt.Sprintf("error describing tests: %v", err))
+		return 1
+	}
+
+	switch d.flagFormat {
+	case "markdown":
+		err = benchmarktests.WriteTestReferenceMarkdown(os.Stdout, refs)
+	case "json":
+		err = benchmarktests.WriteTestReferenceJSON(os.Stdout, refs)
+	default:
+		d.UI.Error("format must be one of markdown or json")
+		return 1
+	}
+	if err != nil {
+		d.UI.Error(fmt.Sprintf("error writing reference: %v", err))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,41 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// dryRun prints what each test of the config would do during a run: the
+// mounts it would enable, the requests it would set up with and samples of
+// the requests it would send, with their secrets redacted. Nothing is sent
+// to the target, whose address is only used to show the targets.
+func (r *RunCommand) dryRun(conf *vbConfig.VaultBenchmarkCoreConfig, duration time.Duration, ignoreWeights bool, logger hclog.Logger) int {
+	addr := conf.VaultAddr
+	if len(conf.VaultAddrs) > 0 {
+		addr = conf.VaultAddrs[0]
+	}
+
+	logger.Info("dry run, setting up targets without contacting the target")
+	plans, err := benchmarktests.DryRun(addr, conf.Tests, &logger, &benchmarktests.TopLevelTargetConfig{
+		Duration:     duration,
+		RandomMounts: conf.RandomMounts,
+
+		IgnoreWeights: ignoreWeights,
+	})
+	if err != nil {
+		logger.Error("dry run setup failed", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	if err := benchmarktests.WriteDryRun(os.Stdout, plans); err != nil {
+		logger.Error("error writing dry run", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	vaultapi "github.com/openbao/openbao/api/v2"
+)
+
+// exportTargets writes the targets of the tests set up against the client
+// to the export file, for another load generator to send. The tests are
+// left set up.
+func (r *RunCommand) exportTargets(tm *benchmarktests.TargetMulti, client *vaultapi.Client, conf *vbConfig.VaultBenchmarkCoreConfig, duration time.Duration, logger hclog.Logger) int {
+	// The targets hold the tokens of the tests, so only the user may read them
+	file, err := os.OpenFile(r.flagExportFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		logger.Error("error creating export file", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	defer file.Close()
+
+	err = benchmarktests.ExportTargets(file, tm, client, &benchmarktests.ExportConfig{
+		Format:   r.flagExportFormat,
+		Requests: r.flagExportRequests,
+		Duration: duration,
+		RPS:      conf.RPS,
+		Workers:  conf.Workers,
+		Mode:     conf.AttackMode,
+	})
+	if err != nil {
+		logger.Error("error exporting targets", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	if err := file.Close(); err != nil {
+		logger.Error("error writing export file", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	logger.Info("exported targets", "path", r.flagExportFile, "format", r.flagExportFormat, "requests", r.flagExportRequests)
+	return 0
+}
@@ -0,0 +1,156 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// runExporters are the files and services the metrics of a run are
+// exported to while it runs
+type runExporters struct {
+	logger hclog.Logger
+
+	intervalWriter *benchmarktests.IntervalWriter
+	remoteWriter   *benchmarktests.RemoteWriter
+	influxWriters  []*benchmarktests.InfluxWriter
+	outputs        benchmarktests.MultiIntervalOutput
+
+	// stops close the files and shut down the exporters started, in
+	// reverse order
+	stops []func()
+}
+
+// startExporters opens the report_interval_file and influx_file of the
+// config and starts pushing metrics to remote_write_url, influx_url and
+// the OTLP endpoints, tagged with the run id. The exporters must be
+// stopped once the run ends.
+func startExporters(conf *vbConfig.VaultBenchmarkCoreConfig, runID string, reportInterval time.Duration, logger hclog.Logger) (*runExporters, error) {
+	e := &runExporters{logger: logger}
+	if err := e.start(conf, runID, reportInterval); err != nil {
+		e.Stop()
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *runExporters) start(conf *vbConfig.VaultBenchmarkCoreConfig, runID string, reportInterval time.Duration) error {
+	if reportInterval > 0 {
+		if conf.IntervalFile == "" && conf.RemoteWriteURL == "" && conf.InfluxFile == "" && conf.InfluxURL == "" {
+			return errors.New("report_interval requires report_interval_file, remote_write_url, influx_file or influx_url to be set")
+		}
+		if conf.IntervalFile != "" {
+			intervalFile, err := os.Create(conf.IntervalFile)
+			if err != nil {
+				return fmt.Errorf("error creating report interval file: %w", err)
+			}
+			e.stops = append(e.stops, func() { intervalFile.Close() })
+			e.intervalWriter = benchmarktests.NewIntervalWriter(intervalFile)
+			e.outputs = append(e.outputs, e.intervalWriter)
+		}
+	}
+
+	if conf.RemoteWriteURL != "" {
+		if reportInterval == 0 {
+			return errors.New("remote_write_url requires report_interval to be set")
+		}
+		e.logger.Info("pushing interval metrics", "url", conf.RemoteWriteURL, "run_id", runID)
+		e.remoteWriter = benchmarktests.NewRemoteWriter(conf.RemoteWriteURL, runID, conf.Labels)
+		e.outputs = append(e.outputs, e.remoteWriter)
+	}
+	if conf.InfluxFile != "" || conf.InfluxURL != "" {
+		if reportInterval == 0 {
+			return errors.New("influx_file and influx_url require report_interval to be set")
+		}
+		if conf.InfluxFile != "" {
+			influxFile, err := os.Create(conf.InfluxFile)
+			if err != nil {
+				return fmt.Errorf("error creating influx file: %w", err)
+			}
+			e.stops = append(e.stops, func() { influxFile.Close() })
+			e.influxWriters = append(e.influxWriters, benchmarktests.NewInfluxWriter(influxFile, runID, conf.Labels))
+		}
+		if conf.InfluxURL != "" {
+			e.logger.Info("pushing interval metrics to influx", "url", conf.InfluxURL, "run_id", runID)
+			e.influxWriters = append(e.influxWriters, benchmarktests.NewInfluxHTTPWriter(conf.InfluxURL, conf.InfluxToken, runID, conf.Labels))
+		}
+		for _, influxWriter := range e.influxWriters {
+			e.outputs = append(e.outputs, influxWriter)
+		}
+	}
+
+	if conf.OTLPEndpoint != "" {
+		e.logger.Info("exporting OTLP metrics", "endpoint", conf.OTLPEndpoint, "protocol", conf.OTLPProtocol, "run_id", runID)
+		shutdownOTel, err := benchmarktests.StartOTelMetrics(context.Background(), conf.OTLPEndpoint, conf.OTLPProtocol, runID, conf.Labels, reportInterval)
+		if err != nil {
+			return fmt.Errorf("error starting OTLP metrics export: %w", err)
+		}
+		e.stops = append(e.stops, func() {
+			if err := shutdownOTel(context.Background()); err != nil {
+				e.logger.Error("exported OTLP metrics may be incomplete", "error", hclog.Fmt("%v", err))
+			}
+		})
+	}
+
+	if conf.OTLPTraces != "" {
+		e.logger.Info("tracing sampled requests", "endpoint", conf.OTLPTraces, "protocol", conf.OTLPTraceProto, "sample_ratio", conf.TraceSampling, "run_id", runID)
+		shutdownTracing, err := benchmarktests.StartOTelTracing(context.Background(), conf.OTLPTraces, conf.OTLPTraceProto, runID, conf.Labels, conf.TraceSampling)
+		if err != nil {
+			return fmt.Errorf("error starting OTLP trace export: %w", err)
+		}
+		e.stops = append(e.stops, func() {
+			if err := shutdownTracing(context.Background()); err != nil {
+				e.logger.Error("exported OTLP spans may be incomplete", "error", hclog.Fmt("%v", err))
+			}
+		})
+	}
+	return nil
+}
+
+// intervalOutput returns the output the interval reports of the attack are
+// written to, or nil when there are none
+func (e *runExporters) intervalOutput() benchmarktests.IntervalOutput {
+	if len(e.outputs) == 0 {
+		return nil
+	}
+	return e.outputs
+}
+
+// flush closes the writers of the interval reports once the attack has
+// ended, logging any reports which couldn't be written
+func (e *runExporters) flush() {
+	if e.intervalWriter != nil {
+		if err := e.intervalWriter.Err(); err != nil {
+			e.logger.Error("interval reports may be incomplete", "error", hclog.Fmt("%v", err))
+		}
+	}
+	if e.remoteWriter != nil {
+		e.remoteWriter.Close()
+		if err := e.remoteWriter.Err(); err != nil {
+			e.logger.Error("pushed interval metrics may be incomplete", "error", hclog.Fmt("%v", err))
+		}
+	}
+	for _, influxWriter := range e.influxWriters {
+		influxWriter.Close()
+		if err := influxWriter.Err(); err != nil {
+			e.logger.Error("influx interval metrics may be incomplete", "error", hclog.Fmt("%v", err))
+		}
+	}
+}
+
+// Stop closes the files of the exporters and shuts down the exporters of
+// OTLP metrics and traces
+func (e *runExporters) Stop() {
+	for i := len(e.stops) - 1; i >= 0; i-- {
+		e.stops[i]()
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*HistoryCommand)(nil)
+	_ cli.CommandAutocomplete = (*HistoryCommand)(nil)
+)
+
+type HistoryCommand struct {
+	*BaseCommand
+	flagHistoryDB string
+	flagPhase     string
+	flagMetric    string
+	flagLimit     int
+}
+
+func (h *HistoryCommand) Synopsis() string {
+	return "List recorded runs and the trend of a test over time"
+}
+
+func (h *HistoryCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark history [options] [TEST]
+
+ This command reads the history database runs were recorded in with the
+ history_db option of the run command. Without a test it lists the most
+ recent runs. Given a test it shows the results of the test in each run,
+ plotting one of its metrics.
+
+	$ vault-benchmark history -history_db=bench.db
+
+	$ vault-benchmark history -history_db=bench.db -metric=throughput kvv2_read
+
+ For a full list of examples, please see the documentation.
+
+` + h.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (h *HistoryCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (h *HistoryCommand) AutocompleteFlags() complete.Flags {
+	return h.Flags().Completions()
+}
+
+func (h *HistoryCommand) Flags() *FlagSets {
+	set := h.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "history_db",
+		Target:     &h.flagHistoryDB,
+		Completion: complete.PredictFiles("*"),
+		Default:    "",
+		Usage:      "Path to the SQLite database runs were recorded in.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "phase",
+		Target:  &h.flagPhase,
+		Default: "",
+		Usage:   "Phase of the test to show the results of, for runs with phases.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "metric",
+		Target:     &h.flagMetric,
+		Completion: complete.PredictSet(benchmarktests.HistoryMetrics()...),
+		Default:    "p99",
+		Usage:      "Metric of the test to plot. Options are: " + strings.Join(benchmarktests.HistoryMetrics(), ", ") + ".",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "limit",
+		Target:  &h.flagLimit,
+		Default: benchmarktests.DefaultHistoryLimit,
+		Usage:   "Number of most recent runs to show.",
+	})
+	return set
+}
+
+func (h *HistoryCommand) Run(args []string) int {
+	f := h.Flags()
+
+	if err := f.Parse(args); err != nil {
+		h.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	switch {
+	case len(args) > 1:
+		h.UI.Error(fmt.Sprintf("expected at most 1 test, got %d", len(args)))
+		return 1
+	case h.flagHistoryDB == "":
+		h.UI.Error("history_db must be set")
+		return 1
+	case h.flagLimit <= 0:
+		h.UI.Error("limit must be positive")
+		return 1
+	}
+
+	// Opening would otherwise create an empty database
+	if _, err := os.Stat(h.flagHistoryDB); err != nil {
+		h.UI.Error(fmt.Sprintf("error opening history database: %v", err))
+		return 1
+	}
+	history, err := benchmarktests.OpenHistoryDB(h.flagHistoryDB)
+	if err != nil {
+		h.UI.Error(err.Error())
+		return 1
+	}
+	defer history.Close()
+
+	if len(args) == 0 {
+		runs, err := history.Runs(h.flagLimit)
+		if err != nil {
+			h.UI.Error(err.Error())
+			return 1
+		}
+		if err := benchmarktests.WriteHistoryRuns(os.Stdout, runs); err != nil {
+			h.UI.Error(fmt.Sprintf("error writing runs: %v", err))
+			return 1
+		}
+		return 0
+	}
+
+	points, err := history.Trend(args[0], h.flagPhase, h.flagLimit)
+	if err != nil {
+		h.UI.Error(err.Error())
+		return 1
+	}
+	if len(points) == 0 {
+		h.UI.Error(fmt.Sprintf("no recorded runs of test %q", args[0]))
+		return 1
+	}
+	if err := benchmarktests.WriteTrend(os.Stdout, points, h.flagMetric); err != nil {
+		h.UI.Error(fmt.Sprintf("error writing trend: %v", err))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,40 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// hasHooks returns whether the config has hooks of the run or of any of
+// its tests
+func hasHooks(conf *vbConfig.VaultBenchmarkCoreConfig) bool {
+	if len(conf.BeforeRun) > 0 || len(conf.AfterRun) > 0 {
+		return true
+	}
+	for _, vbTest := range conf.Tests {
+		if len(vbTest.Before) > 0 || len(vbTest.After) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// runBeforeHooks runs the before_run hooks of the config, stopping at the
+// first which fails. run describes the run to the hooks. The hooks of each
+// test are run by the attacks, around each of the test's own.
+func runBeforeHooks(conf *vbConfig.VaultBenchmarkCoreConfig, run benchmarktests.HookRun, logger hclog.Logger) error {
+	run.Hook = benchmarktests.BeforeRunHook
+	return benchmarktests.RunHooks(conf.BeforeRun, &run, logger)
+}
+
+// runAfterHooks runs the after_run hooks of the config, all of them even
+// when one fails. run describes the run to the hooks, including whether it
+// passed so far.
+func runAfterHooks(conf *vbConfig.VaultBenchmarkCoreConfig, run benchmarktests.HookRun, logger hclog.Logger) error {
+	run.Hook = benchmarktests.AfterRunHook
+	return benchmarktests.RunHooks(conf.AfterRun, &run, logger)
+}
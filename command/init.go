@@ -0,0 +1,118 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*InitCommand)(nil)
+	_ cli.CommandAutocomplete = (*InitCommand)(nil)
+)
+
+// InitCommand writes example test blocks for test types
+type InitCommand struct {
+	*BaseCommand
+	flagOutput string
+}
+
+func (i *InitCommand) Synopsis() string {
+	return "Writes an example config for test types"
+}
+
+func (i *InitCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark init [options] TEST_TYPE...
+
+ This command writes an example test block for each of the given test
+ types, with every option of its config block and a comment on its type,
+ whether it is required and the environment variable it is read from.
+ Options with defaults are set to them and required options to empty
+ values which must be filled in, while the others are commented out.
+
+	$ vault-benchmark init -output=config.hcl postgresql_secret
+
+ For a full list of examples, please see the documentation.
+
+` + i.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (i *InitCommand) AutocompleteArgs() complete.Predictor {
+	testTypes := make([]string, 0, len(benchmarktests.TestList))
+	for testType := range benchmarktests.TestList {
+		testTypes = append(testTypes, testType)
+	}
+	sort.Strings(testTypes)
+	return complete.PredictSet(testTypes...)
+}
+
+func (i *InitCommand) AutocompleteFlags() complete.Flags {
+	return i.Flags().Completions()
+}
+
+func (i *InitCommand) Flags() *FlagSets {
+	set := i.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "output",
+		Target:     &i.flagOutput,
+		Default:    "",
+		Completion: complete.PredictFiles("*.hcl"),
+		Usage:      "Path of the config file to write, which must not exist. Written to stdout when unset.",
+	})
+	return set
+}
+
+func (i *InitCommand) Run(args []string) int {
+	f := i.Flags()
+
+	if err := f.Parse(args); err != nil {
+		i.UI.Error(err.Error())
+		return 1
+	}
+
+	if len(f.Args()) == 0 {
+		i.UI.Error("at least one test type is required")
+		return 1
+	}
+
+	refs, err := benchmarktests.TestReferences(f.Args()...)
+	if err != nil {
+		i.UI.Error(fmt.Sprintf("error describing tests: %v", err))
+		return 1
+	}
+
+	var w io.Writer = os.Stdout
+	if i.flagOutput != "" {
+		file, err := os.OpenFile(i.flagOutput, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, os.ErrExist) {
+			i.UI.Error(fmt.Sprintf("config file %v already exists", i.flagOutput))
+			return 1
+		}
+		if err != nil {
+			i.UI.Error(fmt.Sprintf("error creating config file: %v", err))
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := benchmarktests.WriteTestExample(w, refs); err != nil {
+		i.UI.Error(fmt.Sprintf("error writing example config: %v", err))
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,233 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// serviceAccountDir is where Kubernetes mounts the credentials of the
+// service account of a pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// kubeClient reads from the Kubernetes API, with the service account of
+// the pod when running in a cluster and through kubectl otherwise
+type kubeClient struct {
+	Kubeconfig string
+	Context    string
+	Namespace  string
+}
+
+// inCluster is whether to use the service account of the pod, which is
+// the case in a cluster unless a kubeconfig or context is given
+func (c *kubeClient) inCluster() bool {
+	return c.Kubeconfig == "" && c.Context == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+}
+
+// kubectl runs kubectl with the kubeconfig, context and namespace of the
+// client, writing stdin to it when given
+func (c *kubeClient) kubectl(stdin []byte, args ...string) ([]byte, error) {
+	var flags []string
+	if c.Kubeconfig != "" {
+		flags = append(flags, "--kubeconfig="+c.Kubeconfig)
+	}
+	if c.Context != "" {
+		flags = append(flags, "--context="+c.Context)
+	}
+	if c.Namespace != "" {
+		flags = append(flags, "--namespace="+c.Namespace)
+	}
+	cmd := exec.Command("kubectl", append(flags, args...)...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, commandError(err)
+	}
+	return out, nil
+}
+
+// resolveNamespace defaults the namespace of the client to that of the pod
+// in a cluster, or of the kubeconfig context otherwise
+func (c *kubeClient) resolveNamespace() error {
+	if c.Namespace != "" {
+		return nil
+	}
+	if c.inCluster() {
+		ns, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace"))
+		if err != nil {
+			return fmt.Errorf("error reading service account namespace: %v", err)
+		}
+		c.Namespace = strings.TrimSpace(string(ns))
+		return nil
+	}
+	out, err := c.kubectl(nil, "config", "view", "--minify", "--output=jsonpath={..namespace}")
+	if err != nil {
+		return fmt.Errorf("error reading kubeconfig namespace: %v", err)
+	}
+	c.Namespace = strings.TrimSpace(string(out))
+	if c.Namespace == "" {
+		c.Namespace = "default"
+	}
+	return nil
+}
+
+// get reads the object or list at path of the Kubernetes API into v
+func (c *kubeClient) get(path string, v interface{}) error {
+	var body []byte
+	var err error
+	if c.inCluster() {
+		body, err = c.doInCluster(http.MethodGet, path)
+	} else {
+		body, err = c.kubectl(nil, "get", "--raw", path)
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error decoding %v: %v", path, err)
+	}
+	return nil
+}
+
+// delete deletes the object at path of the Kubernetes API
+func (c *kubeClient) delete(path string) error {
+	var err error
+	if c.inCluster() {
+		_, err = c.doInCluster(http.MethodDelete, path)
+	} else {
+		_, err = c.kubectl(nil, "delete", "--raw", path)
+	}
+	return err
+}
+
+// doInCluster sends a request for path of the Kubernetes API with the
+// service account of the pod
+func (c *kubeClient) doInCluster(method, path string) ([]byte, error) {
+	token, err := os.ReadFile(filepath.Join(serviceAccountDir, "token"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account token: %v", err)
+	}
+	caPEM, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in service account CA")
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	host := net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"))
+	req, err := http.NewRequest(method, "https://"+host+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error requesting %v: %v: %s", path, resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
+
+// kubePod is the part of a Kubernetes pod discovery looks at
+type kubePod struct {
+	Metadata struct {
+		Name              string  `json:"name"`
+		DeletionTimestamp *string `json:"deletionTimestamp"`
+	} `json:"metadata"`
+	Status struct {
+		Phase      string `json:"phase"`
+		PodIP      string `json:"podIP"`
+		Conditions []struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// ready is whether the pod is running, ready and not being deleted, so
+// requests to it are expected to succeed
+func (p *kubePod) ready() bool {
+	if p.Status.Phase != "Running" || p.Status.PodIP == "" || p.Metadata.DeletionTimestamp != nil {
+		return false
+	}
+	for _, cond := range p.Status.Conditions {
+		if cond.Type == "Ready" {
+			return cond.Status == "True"
+		}
+	}
+	return false
+}
+
+// discoverKubernetes returns the addresses to benchmark in a Kubernetes
+// cluster: the address of the service when one is given and the pod IP of
+// every ready pod matching the selector otherwise, sorted by pod name
+func discoverKubernetes(k *vbConfig.KubernetesConfig) ([]string, error) {
+	c := &kubeClient{Kubeconfig: k.Kubeconfig, Context: k.Context, Namespace: k.Namespace}
+	if err := c.resolveNamespace(); err != nil {
+		return nil, err
+	}
+	ns := url.PathEscape(c.Namespace)
+
+	if k.Service != "" {
+		var service struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		}
+		if err := c.get("/api/v1/namespaces/"+ns+"/services/"+url.PathEscape(k.Service), &service); err != nil {
+			return nil, fmt.Errorf("error reading service %v: %v", k.Service, err)
+		}
+		host := service.Metadata.Name + "." + c.Namespace + ".svc"
+		return []string{fmt.Sprintf("%s://%s", k.Scheme, net.JoinHostPort(host, strconv.Itoa(k.Port)))}, nil
+	}
+
+	var pods struct {
+		Items []*kubePod `json:"items"`
+	}
+	path := "/api/v1/namespaces/" + ns + "/pods?labelSelector=" + url.QueryEscape(k.Selector)
+	if err := c.get(path, &pods); err != nil {
+		return nil, fmt.Errorf("error listing pods: %v", err)
+	}
+	sort.Slice(pods.Items, func(i, j int) bool {
+		return pods.Items[i].Metadata.Name < pods.Items[j].Metadata.Name
+	})
+	var addrs []string
+	for _, pod := range pods.Items {
+		if pod.ready() {
+			addrs = append(addrs, fmt.Sprintf("%s://%s", k.Scheme, net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(k.Port))))
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no ready pods match %q in namespace %v", k.Selector, c.Namespace)
+	}
+	return addrs, nil
+}
@@ -0,0 +1,467 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*KubernetesCommand)(nil)
+	_ cli.CommandAutocomplete = (*KubernetesCommand)(nil)
+)
+
+const (
+	// kubeRunLabel labels the objects created for a run, so they can be
+	// found and removed together
+	kubeRunLabel = "vault-benchmark-run"
+
+	// kubeConfigDir is where the config of a run is mounted in its pods
+	kubeConfigDir = "/config"
+)
+
+// KubernetesCommand shards a benchmark across parallel Kubernetes Jobs and
+// merges their results
+type KubernetesCommand struct {
+	*BaseCommand
+	flagConfigPath     string
+	flagShards         int
+	flagImage          string
+	flagBinary         string
+	flagNamespace      string
+	flagServiceAccount string
+	flagKubeconfig     string
+	flagContext        string
+	flagReportMode     string
+	flagPercentiles    string
+	flagStartDelay     time.Duration
+	flagTimeout        time.Duration
+	flagKeep           bool
+}
+
+func (c *KubernetesCommand) Synopsis() string {
+	return "Run a benchmark across parallel Kubernetes Jobs"
+}
+
+func (c *KubernetesCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark kubernetes [options]
+
+ This command runs a benchmark in a Kubernetes cluster as a set of Jobs,
+ each running an equal share of the load and starting at the same time,
+ then merges their results into a single report.
+
+	$ vault-benchmark kubernetes -config=config.hcl -shards=4 -namespace=openbao
+
+ For a full list of examples, please see the documentation.
+
+` + c.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (c *KubernetesCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *KubernetesCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *KubernetesCommand) Flags() *FlagSets {
+	set := c.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:   "config",
+		Target: &c.flagConfigPath,
+		Completion: complete.PredictOr(
+			complete.PredictFiles("*.hcl"),
+			complete.PredictFiles("*.json"),
+		),
+		Usage: "Path to a vault-benchmark test configuration file. Files it refers to are read in the Job pods.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "shards",
+		Target:  &c.flagShards,
+		Default: 1,
+		Usage:   "Number of Jobs to split the benchmark between.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "image",
+		Target:  &c.flagImage,
+		Default: "hashicorp/vault-benchmark:latest",
+		Usage:   "Image to run the Jobs with.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "binary",
+		Target:  &c.flagBinary,
+		Default: "vault-benchmark",
+		Usage:   "Path or name of the vault-benchmark binary in the image.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "namespace",
+		Target:  &c.flagNamespace,
+		Default: "",
+		Usage:   "Namespace to run the Jobs in. Defaults to the namespace of the kubeconfig context.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "service_account",
+		Target:  &c.flagServiceAccount,
+		Default: "",
+		Usage:   "Service account to run the Jobs as. Defaults to the default service account of the namespace.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "kubeconfig",
+		Target:     &c.flagKubeconfig,
+		Default:    "",
+		Completion: complete.PredictFiles("*"),
+		Usage:      "Path to the kubeconfig file to use. Defaults to that of kubectl.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "context",
+		Target:  &c.flagContext,
+		Default: "",
+		Usage:   "Kubeconfig context to use. Defaults to the current context.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "report_mode",
+		Target:  &c.flagReportMode,
+		Default: "terse",
+		Usage:   "Reporting Mode. Options are: terse, verbose, json, csv, markdown.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "report_percentiles",
+		Target:  &c.flagPercentiles,
+		Default: "",
+		Usage:   "Comma-separated latency percentiles to report, e.g. p50,p99.9,max.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "start_delay",
+		Target:  &c.flagStartDelay,
+		Default: time.Minute,
+		Usage:   "Time given to the Jobs to be scheduled and set up their tests before all of them start attacking together.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "timeout",
+		Target:  &c.flagTimeout,
+		Default: time.Hour,
+		Usage:   "Time to wait for the Jobs to finish before giving up on them.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "keep",
+		Target:  &c.flagKeep,
+		Default: false,
+		Usage:   "Keep the Jobs and their config map once the run ends, rather than removing them.",
+	})
+	return set
+}
+
+func (c *KubernetesCommand) Run(args []string) int {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:  "vault-benchmark-kubernetes",
+		Level: hclog.Info,
+	})
+
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if c.flagConfigPath == "" {
+		c.UI.Error("no config file location passed")
+		return 1
+	}
+	if c.flagShards < 1 {
+		c.UI.Error("shards must be at least 1")
+		return 1
+	}
+
+	switch c.flagReportMode {
+	case "terse", "verbose", "json", "csv", "markdown":
+	default:
+		c.UI.Error("report_mode must be one of terse, verbose, json, csv, or markdown")
+		return 1
+	}
+
+	var percentiles []float64
+	if c.flagPercentiles != "" {
+		var err error
+		percentiles, err = benchmarktests.ParsePercentiles(c.flagPercentiles)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("error parsing report percentiles: %v", err))
+			return 1
+		}
+	}
+
+	// Check the config before handing it out, so mistakes are reported
+	// once rather than by every Job
+	conf := vbConfig.NewVaultBenchmarkCoreConfig()
+	if err := conf.LoadConfig(c.flagConfigPath); err != nil {
+		c.UI.Error(fmt.Sprintf("error loading config: %v", err))
+		return 1
+	}
+	if c.flagShards > 1 && (conf.Search != nil || conf.ReplayFile != "") {
+		c.UI.Error("throughput_search and replay_file cannot be split between shards")
+		return 1
+	}
+	if err := conf.ApplyLoadShare(1, c.flagShards); err != nil {
+		c.UI.Error(fmt.Sprintf("error sharing load between shards: %v", err))
+		return 1
+	}
+	configBuf, err := os.ReadFile(c.flagConfigPath)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error reading config: %v", err))
+		return 1
+	}
+
+	kube := &kubeClient{Kubeconfig: c.flagKubeconfig, Context: c.flagContext, Namespace: c.flagNamespace}
+	if err := kube.resolveNamespace(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error generating run ID: %v", err))
+		return 1
+	}
+	runID := id[:8]
+	startAt := time.Now().Add(c.flagStartDelay)
+	manifest, jobs, err := c.manifest(runID, configBuf, startAt)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error building jobs: %v", err))
+		return 1
+	}
+
+	if _, err := kube.kubectl(manifest, "create", "--filename=-"); err != nil {
+		c.UI.Error(fmt.Sprintf("error creating jobs: %v", err))
+		return 1
+	}
+	logger.Info("started benchmark jobs", "namespace", kube.Namespace, "run", runID, "jobs", len(jobs), "start_at", startAt.Format(time.RFC3339))
+
+	// Remove the jobs once the run ends, or is interrupted
+	cleanup := func() {
+		if c.flagKeep {
+			return
+		}
+		if _, err := kube.kubectl(nil, "delete", "jobs,configmaps", "--selector="+kubeRunLabel+"="+runID); err != nil {
+			logger.Error("error removing jobs", "run", runID, "error", hclog.Fmt("%v", err))
+			return
+		}
+		logger.Info("removed benchmark jobs", "run", runID)
+	}
+	defer cleanup()
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupted
+		cleanup()
+		os.Exit(1)
+	}()
+
+	status, err := waitForJobs(kube, runID, len(jobs), c.flagTimeout, logger)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error waiting for jobs: %v", err))
+		return 1
+	}
+
+	// Report whatever the jobs gathered, even when some failed
+	failed := false
+	var rpts []*benchmarktests.Reporter
+	for _, job := range jobs {
+		if !status[job] {
+			logger.Error("benchmark failed in job", "job", job)
+			failed = true
+		}
+		logs, err := kube.kubectl(nil, "logs", "job/"+job)
+		if err != nil {
+			logger.Error("error reading job logs", "job", job, "error", hclog.Fmt("%v", err))
+			failed = true
+			continue
+		}
+		jobRpts, err := benchmarktests.FromReader(reportLines(logs))
+		if err != nil {
+			logger.Error("error reading job results", "job", job, "error", hclog.Fmt("%v", err))
+			failed = true
+			continue
+		}
+		rpts = append(rpts, jobRpts...)
+	}
+	if len(rpts) == 0 {
+		c.UI.Error("no job returned results")
+		return 1
+	}
+
+	if err := writeMergedReports(rpts, c.flagReportMode, percentiles); err != nil {
+		c.UI.Error(fmt.Sprintf("error writing report: %v", err))
+		return 1
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// manifest returns a list of the config map holding the config of the run
+// and a Job for each shard, along with the names of the Jobs. Each Job
+// runs its share of the benchmark like a worker does.
+func (c *KubernetesCommand) manifest(runID string, configBuf []byte, startAt time.Time) ([]byte, []string, error) {
+	name := "vault-benchmark-" + runID
+	labels := map[string]string{"app": "vault-benchmark", kubeRunLabel: runID}
+	configName := filepath.Base(c.flagConfigPath)
+
+	items := []map[string]interface{}{{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name, "labels": labels},
+		"data":       map[string]string{configName: string(configBuf)},
+	}}
+	var jobs []string
+	for part := 1; part <= c.flagShards; part++ {
+		job := fmt.Sprintf("%s-%d", name, part)
+		jobs = append(jobs, job)
+		args := workerRunArgs(&benchmarktests.WorkerRunRequest{
+			Config:  kubeConfigDir + "/" + configName,
+			Part:    part,
+			Parts:   c.flagShards,
+			StartAt: startAt,
+		})
+		podSpec := map[string]interface{}{
+			"restartPolicy": "Never",
+			"containers": []map[string]interface{}{{
+				"name":            "vault-benchmark",
+				"image":           c.flagImage,
+				"imagePullPolicy": "IfNotPresent",
+				"command":         []string{c.flagBinary},
+				"args":            args,
+				"volumeMounts": []map[string]interface{}{{
+					"name":      "benchmark-config",
+					"mountPath": kubeConfigDir,
+					"readOnly":  true,
+				}},
+			}},
+			"volumes": []map[string]interface{}{{
+				"name":      "benchmark-config",
+				"configMap": map[string]string{"name": name},
+			}},
+		}
+		if c.flagServiceAccount != "" {
+			podSpec["serviceAccountName"] = c.flagServiceAccount
+		}
+		items = append(items, map[string]interface{}{
+			"apiVersion": "batch/v1",
+			"kind":       "Job",
+			"metadata":   map[string]interface{}{"name": job, "labels": labels},
+			"spec": map[string]interface{}{
+				"backoffLimit": 0,
+				"template": map[string]interface{}{
+					"metadata": map[string]interface{}{"labels": labels},
+					"spec":     podSpec,
+				},
+			},
+		})
+	}
+
+	manifest, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+	return manifest, jobs, err
+}
+
+// waitForJobs polls the Jobs of a run until all of them have completed or
+// failed, returning whether each completed
+func waitForJobs(kube *kubeClient, runID string, count int, timeout time.Duration, logger hclog.Logger) (map[string]bool, error) {
+	deadline := time.Now().Add(timeout)
+	status := make(map[string]bool, count)
+	for {
+		out, err := kube.kubectl(nil, "get", "jobs", "--selector="+kubeRunLabel+"="+runID, "--output=json")
+		if err != nil {
+			return nil, err
+		}
+		var jobs struct {
+			Items []struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+				Status struct {
+					Conditions []struct {
+						Type   string `json:"type"`
+						Status string `json:"status"`
+					} `json:"conditions"`
+				} `json:"status"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal(out, &jobs); err != nil {
+			return nil, fmt.Errorf("error decoding jobs: %v", err)
+		}
+		for _, job := range jobs.Items {
+			if _, ok := status[job.Metadata.Name]; ok {
+				continue
+			}
+			for _, cond := range job.Status.Conditions {
+				if cond.Status != "True" || (cond.Type != "Complete" && cond.Type != "Failed") {
+					continue
+				}
+				status[job.Metadata.Name] = cond.Type == "Complete"
+				logger.Info("job finished", "job", job.Metadata.Name, "complete", cond.Type == "Complete", "remaining", count-len(status))
+				break
+			}
+		}
+		if len(status) == count {
+			return status, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%d of %d jobs did not finish within %v", count-len(status), count, timeout)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// reportLines picks the JSON reports out of the logs of a Job, where they
+// are interleaved with the log lines the benchmark writes to stderr
+func reportLines(logs []byte) *bytes.Buffer {
+	var reports bytes.Buffer
+	scanner := bufio.NewScanner(bytes.NewReader(logs))
+	scanner.Buffer(nil, len(logs)+1)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); bytes.HasPrefix(line, []byte("{")) {
+			reports.Write(line)
+			reports.WriteByte('\n')
+		}
+	}
+	return &reports
+}
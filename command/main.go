@@ -22,6 +22,19 @@ import (
 var commonCommands = []string{
 	"run",
 	"review",
+	"dashboard",
+	"diff",
+	"history",
+	"schema",
+	"doc",
+	"init",
+	"worker",
+	"coordinator",
+	"kubernetes",
+	"server",
+	"validate",
+	"cleanup",
+	"record",
 }
 
 type VaultUI struct {
@@ -110,6 +123,97 @@ func RunCustom(args []string, runOpts *RunOptions) int {
 				},
 			}, nil
 		},
+		"dashboard": func() (cli.Command, error) {
+			return &DashboardCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"diff": func() (cli.Command, error) {
+			return &DiffCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"history": func() (cli.Command, error) {
+			return &HistoryCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"schema": func() (cli.Command, error) {
+			return &SchemaCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"worker": func() (cli.Command, error) {
+			return &WorkerCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"coordinator": func() (cli.Command, error) {
+			return &CoordinatorCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"kubernetes": func() (cli.Command, error) {
+			return &KubernetesCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"server": func() (cli.Command, error) {
+			return &ServerCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"doc": func() (cli.Command, error) {
+			return &DocCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"init": func() (cli.Command, error) {
+			return &InitCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"validate": func() (cli.Command, error) {
+			return &ValidateCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"cleanup": func() (cli.Command, error) {
+			return &CleanupCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
+		"record": func() (cli.Command, error) {
+			return &RecordCommand{
+				BaseCommand: &BaseCommand{
+					UI: ui,
+				},
+			}, nil
+		},
 		"version": func() (cli.Command, error) {
 			return &VersionCommand{
 				BaseCommand: &BaseCommand{
@@ -0,0 +1,107 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	vaultapi "github.com/openbao/openbao/api/v2"
+)
+
+// runMonitors watch a run while its attack is going, capturing profiles of
+// the target, running chaos events, sampling resource usage and showing the
+// live view, each when the config asks for it
+type runMonitors struct {
+	clients   []*vaultapi.Client
+	profiles  *benchmarktests.ProfileCapture
+	chaos     *benchmarktests.ChaosRunner
+	resources *benchmarktests.ResourceMonitor
+	live      *benchmarktests.LiveView
+
+	chaosResults []benchmarktests.ChaosEventResult
+	liveStop     chan struct{}
+	liveDone     chan struct{}
+}
+
+func newRunMonitors(conf *vbConfig.VaultBenchmarkCoreConfig, clients []*vaultapi.Client, profiles *benchmarktests.ProfileCapture, chaosEvents []*benchmarktests.ChaosEvent, live *benchmarktests.LiveView, seriesInterval time.Duration, logger hclog.Logger) *runMonitors {
+	m := &runMonitors{
+		clients:  clients,
+		profiles: profiles,
+		live:     live,
+		liveStop: make(chan struct{}),
+		liveDone: make(chan struct{}),
+	}
+	if len(chaosEvents) > 0 {
+		m.chaos = &benchmarktests.ChaosRunner{Events: chaosEvents, Logger: logger.Named("chaos")}
+	}
+	// Resource usage is sampled every point of the time series
+	if conf.ResourceURL != "" {
+		m.resources = benchmarktests.NewResourceMonitor(conf.ResourceURL, seriesInterval, logger)
+	}
+	return m
+}
+
+// liveView returns the live view of the run when the config asks for it and
+// stderr is a terminal it can be drawn on
+func liveView(conf *vbConfig.VaultBenchmarkCoreConfig, logger hclog.Logger) *benchmarktests.LiveView {
+	if !conf.Live {
+		return nil
+	}
+	if fi, err := os.Stderr.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		logger.Warn("stderr is not a terminal, not showing the live view")
+		return nil
+	}
+	return benchmarktests.NewLiveView()
+}
+
+// start starts the monitors of the run started at began. All but the live
+// view stop once ended is closed.
+func (m *runMonitors) start(began time.Time, ended <-chan struct{}) {
+	if m.profiles != nil {
+		m.profiles.Start(m.clients, began, ended)
+	}
+	if m.chaos != nil {
+		m.chaos.Start(began, ended)
+	}
+	if m.resources != nil {
+		go m.resources.Run(ended)
+	}
+	if m.live != nil {
+		go func() {
+			defer close(m.liveDone)
+			m.live.Run(os.Stderr, time.Second, m.liveStop)
+		}()
+	} else {
+		close(m.liveDone)
+	}
+}
+
+// wait waits for the monitors to finish once the run has ended, stopping
+// the live view
+func (m *runMonitors) wait() {
+	if m.profiles != nil {
+		// Profiles being captured at the end of the run are still written
+		m.profiles.Wait()
+	}
+	if m.chaos != nil {
+		m.chaos.Wait()
+		m.chaosResults = m.chaos.Results()
+	}
+	close(m.liveStop)
+	<-m.liveDone
+}
+
+// annotate adds what the monitors saw during the run to a report of it
+func (m *runMonitors) annotate(rpt *benchmarktests.Reporter) {
+	if m.resources != nil {
+		rpt.SetResources(m.resources.Usage())
+	}
+	if m.chaos != nil {
+		rpt.SetChaosEvents(m.chaosResults)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vaultapi "github.com/openbao/openbao/api/v2"
+)
+
+// discoverNodes lists the API addresses of the nodes of the cluster the
+// client is connected to from sys/ha-status. When it lists none, such as
+// when the nodes don't advertise an API address, they are read from the
+// Raft configuration instead, reaching each node at the host of its Raft
+// address on the scheme and port of the client's address.
+func discoverNodes(client *vaultapi.Client) ([]string, error) {
+	var addrs []string
+	status, haErr := client.Sys().HAStatus()
+	if haErr == nil {
+		for _, node := range status.Nodes {
+			addr := strings.TrimSuffix(node.APIAddress, "/")
+			if addr != "" && !slices.Contains(addrs, addr) {
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	if len(addrs) > 0 {
+		slices.Sort(addrs)
+		return addrs, nil
+	}
+
+	secret, err := client.Logical().Read("sys/storage/raft/configuration")
+	if err != nil {
+		if haErr != nil {
+			return nil, fmt.Errorf("error reading sys/ha-status: %v, and the raft configuration: %v", haErr, err)
+		}
+		return nil, fmt.Errorf("error reading raft configuration: %v", err)
+	}
+	if secret == nil {
+		return nil, fmt.Errorf("no nodes listed by sys/ha-status or the raft configuration")
+	}
+	var raft struct {
+		Config struct {
+			Servers []struct {
+				Address string `json:"address"`
+			} `json:"servers"`
+		} `json:"config"`
+	}
+	b, err := json.Marshal(secret.Data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &raft); err != nil {
+		return nil, fmt.Errorf("error decoding raft configuration: %v", err)
+	}
+
+	u, err := url.Parse(client.Address())
+	if err != nil {
+		return nil, err
+	}
+	for _, server := range raft.Config.Servers {
+		host, _, err := net.SplitHostPort(server.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid raft address %q: %v", server.Address, err)
+		}
+		if port := u.Port(); port != "" {
+			host = net.JoinHostPort(host, port)
+		}
+		addr := u.Scheme + "://" + host
+		if !slices.Contains(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no nodes listed by sys/ha-status or the raft configuration")
+	}
+	slices.Sort(addrs)
+	return addrs, nil
+}
+
+// refreshNodes discovers the nodes of the cluster every interval until
+// stop is closed, balancing the attack across them as they join and leave
+func refreshNodes(client *vaultapi.Client, nodes *benchmarktests.NodeSet, interval time.Duration, stop <-chan struct{}, logger hclog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		addrs, err := discoverNodes(client)
+		if err != nil {
+			logger.Warn("error discovering nodes", "error", hclog.Fmt("%v", err))
+			continue
+		}
+		joined, left := nodes.Set(addrs)
+		if len(joined) > 0 {
+			logger.Info("nodes joined", "addresses", strings.Join(joined, ","))
+		}
+		if len(left) > 0 {
+			logger.Info("nodes left", "addresses", strings.Join(left, ","))
+		}
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	vaultapi "github.com/openbao/openbao/api/v2"
+)
+
+func buildSearchConfig(conf *vbConfig.SearchConfig) (*benchmarktests.SearchConfig, error) {
+	search := &benchmarktests.SearchConfig{
+		MinSuccessRatio: 0.99,
+		MinRPS:          conf.MinRPS,
+		MaxRPS:          conf.MaxRPS,
+		StepDuration:    10 * time.Second,
+		Tolerance:       conf.Tolerance,
+	}
+
+	var err error
+	search.MaxP99, err = time.ParseDuration(conf.MaxP99)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max_p99: %v", err)
+	}
+	if conf.StepDuration != "" {
+		search.StepDuration, err = time.ParseDuration(conf.StepDuration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid step_duration: %v", err)
+		}
+	}
+	if conf.MinSuccessRatio != nil {
+		search.MinSuccessRatio = *conf.MinSuccessRatio
+	}
+	if search.MinRPS == 0 {
+		search.MinRPS = 1
+	}
+	return search, nil
+}
+
+func buildBurstConfig(conf *vbConfig.BurstConfig) (*benchmarktests.BurstConfig, error) {
+	burst := &benchmarktests.BurstConfig{RPS: conf.RPS}
+
+	var err error
+	burst.Duration, err = time.ParseDuration(conf.Duration)
+	if err != nil {
+		return nil, fmt.Errorf("invalid burst duration: %v", err)
+	}
+	burst.Interval, err = time.ParseDuration(conf.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid burst interval: %v", err)
+	}
+	return burst, nil
+}
+
+// buildAttackModes adds the requests and burst of the config to the attack
+// config, then returns the phases the attack runs in, or the throughput
+// search replacing them when the config has one
+func buildAttackModes(conf *vbConfig.VaultBenchmarkCoreConfig, tm *benchmarktests.TargetMulti, attackConfig *benchmarktests.AttackConfig) ([]runPhase, *benchmarktests.SearchConfig, error) {
+	// A request count replaces the duration as the condition for ending the
+	// attack
+	if conf.Requests > 0 {
+		attackConfig.Requests = uint64(conf.Requests)
+		attackConfig.Duration = 0
+	}
+
+	if conf.Burst != nil {
+		burst, err := buildBurstConfig(conf.Burst)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error configuring burst: %w", err)
+		}
+		attackConfig.Burst = burst
+	}
+
+	phases, err := buildRunPhases(conf, tm, attackConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error configuring phases: %w", err)
+	}
+
+	var search *benchmarktests.SearchConfig
+	if conf.Search != nil {
+		search, err = buildSearchConfig(conf.Search)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error configuring throughput search: %w", err)
+		}
+	}
+	return phases, search, nil
+}
+
+// runPhase pairs the attack settings of one stage of a run with the
+// targets it attacks. Runs without phase blocks have a single, unnamed
+// phase covering all targets.
+type runPhase struct {
+	tm     *benchmarktests.TargetMulti
+	config benchmarktests.AttackConfig
+}
+
+func buildRunPhases(conf *vbConfig.VaultBenchmarkCoreConfig, tm *benchmarktests.TargetMulti, attackConfig *benchmarktests.AttackConfig) ([]runPhase, error) {
+	if len(conf.Phases) == 0 {
+		return []runPhase{{tm: tm, config: *attackConfig}}, nil
+	}
+
+	phases := make([]runPhase, 0, len(conf.Phases))
+	for _, phase := range conf.Phases {
+		phaseConfig := *attackConfig
+		phaseConfig.Phase = phase.Name
+
+		var err error
+		phaseConfig.Duration, err = time.ParseDuration(phase.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration for phase %v: %v", phase.Name, err)
+		}
+		if phase.RPS != nil {
+			phaseConfig.RPS = *phase.RPS
+		}
+		if phase.Workers != 0 {
+			phaseConfig.Workers = phase.Workers
+		}
+
+		phaseTM := tm
+		if len(phase.Tests) > 0 {
+			phaseTM, err = tm.Subset(phase.Tests)
+			if err != nil {
+				return nil, fmt.Errorf("error selecting tests for phase %v: %v", phase.Name, err)
+			}
+		}
+		phases = append(phases, runPhase{tm: phaseTM, config: phaseConfig})
+	}
+	return phases, nil
+}
+
+// runAttack is the attack of a run on each of its targets, gathering the
+// results of every target
+type runAttack struct {
+	conf         *vbConfig.VaultBenchmarkCoreConfig
+	tm           *benchmarktests.TargetMulti
+	fanOut       *benchmarktests.NamespaceFanOut
+	phases       []runPhase
+	search       *benchmarktests.SearchConfig
+	attackConfig *benchmarktests.AttackConfig
+	checkpoint   *benchmarktests.CheckpointFile
+	debug        bool
+	logger       hclog.Logger
+
+	l             sync.Mutex
+	results       map[string][]*benchmarktests.Reporter
+	searchResults map[string]*benchmarktests.SearchResult
+
+	// failed is set when the attack on a target failed, and
+	// budgetExceeded when it was aborted by an error budget
+	failed         bool
+	budgetExceeded bool
+}
+
+// target searches the throughput of the target of the client, or attacks
+// it with each phase in turn, then cleans its tests up. It is safe to call
+// for several targets at once.
+func (a *runAttack) target(client *vaultapi.Client) {
+	if a.debug {
+		if !a.logger.IsTrace() {
+			a.logger.SetLevel(hclog.Debug)
+		}
+		a.l.Lock()
+		a.logger.Debug("=== Debug Info ===")
+		a.logger.Debug(fmt.Sprintf("Client: %s", client.Address()))
+		a.tm.DebugInfo(client)
+		a.l.Unlock()
+	}
+
+	if a.search != nil {
+		searchResult, err := benchmarktests.Search(a.tm, client, a.attackConfig, a.search, a.logger.Named("search"))
+		a.l.Lock()
+		if err != nil {
+			a.logger.Error("throughput search error", "err", hclog.Fmt("%v", err))
+			a.failed = true
+		} else {
+			a.searchResults[client.Address()] = searchResult
+			if searchResult.Report != nil {
+				a.results[client.Address()] = append(a.results[client.Address()], searchResult.Report)
+			}
+		}
+		a.l.Unlock()
+	} else {
+		a.attackPhases(client)
+	}
+
+	a.cleanupTarget(client)
+}
+
+// finishCheckpoint removes the checkpoint once the attack completed, so the
+// next run starts over, unless it failed and the next run may resume it
+func (a *runAttack) finishCheckpoint() {
+	if a.checkpoint == nil {
+		return
+	}
+	if err := a.checkpoint.Err(); err != nil {
+		a.logger.Error("checkpoint may be out of date", "error", hclog.Fmt("%v", err))
+	}
+	if !a.failed {
+		if err := a.checkpoint.Remove(); err != nil {
+			a.logger.Error("error removing checkpoint", "error", hclog.Fmt("%v", err))
+		}
+	}
+}
+
+// cleanupTarget cleans the tests up on the target of the client, along with
+// the audit device of the run, when the config asks for it
+func (a *runAttack) cleanupTarget(client *vaultapi.Client) {
+	if !a.conf.Cleanup {
+		return
+	}
+	a.logger.Info("cleaning up targets")
+	if err := a.cleanup(client); err != nil {
+		a.logger.Error("cleanup error", "err", hclog.Fmt("%v", err))
+	}
+	if a.conf.AuditPath != "" {
+		_, err := client.Logical().Delete("/sys/audit/bench-audit")
+		if err != nil {
+			a.logger.Error("error disabling bench-audit audit device", "error", hclog.Fmt("%v", err))
+		}
+	}
+}
+
+// attackPhases attacks the target of the client with each phase in turn,
+// resuming from the checkpoint when there is one. The phases left are
+// skipped when an attack fails or exceeds its error budget.
+func (a *runAttack) attackPhases(client *vaultapi.Client) {
+	for i, phase := range a.phases {
+		phaseConfig := phase.config
+		if a.checkpoint != nil {
+			progress, err := a.checkpoint.Phase(client.Address(), i, phase.config.Phase)
+			if err != nil {
+				a.logger.Error("error resuming from checkpoint", "err", hclog.Fmt("%v", err))
+				a.l.Lock()
+				a.failed = true
+				a.l.Unlock()
+				return
+			}
+			if progress.Elapsed() > 0 {
+				a.logger.Info("resuming attack", "target", client.Address(), "phase", phase.config.Phase, "elapsed", progress.Elapsed().String())
+			}
+			phaseConfig.Checkpoint = progress
+		}
+		if phase.config.Phase != "" {
+			a.logger.Info("starting phase", "phase", phase.config.Phase, "duration", phase.config.Duration.String())
+		}
+		rpt, err := benchmarktests.Attack(phase.tm, client, &phaseConfig)
+		aborted := errors.Is(err, benchmarktests.ErrErrorBudgetExceeded)
+		if err != nil && !aborted {
+			a.logger.Error("attack error", "err", hclog.Fmt("%v", err))
+			a.l.Lock()
+			a.failed = true
+			a.l.Unlock()
+			return
+		}
+
+		a.l.Lock()
+		// TODO rethink how we present results when multiple nodes are attacked
+		a.results[client.Address()] = append(a.results[client.Address()], rpt)
+		a.l.Unlock()
+
+		// Skip any remaining phases but still clean up and
+		// report what was gathered
+		if aborted {
+			a.logger.Error("attack aborted", "target", client.Address(), "error", hclog.Fmt("%v", err))
+			a.l.Lock()
+			a.budgetExceeded = true
+			a.l.Unlock()
+			return
+		}
+	}
+}
+
+// cleanup removes the tests set up on the target of the client, along with
+// the namespaces they were fanned out into
+func (a *runAttack) cleanup(client *vaultapi.Client) error {
+	if a.fanOut != nil {
+		return a.fanOut.Cleanup(client)
+	}
+	return a.tm.Cleanup(client)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// profileCapture returns the capture of the profiles of the targets at the
+// profile_at points of a run lasting duration into profile_dir, which is
+// created, or nil when the config has no profile_dir
+func profileCapture(conf *vbConfig.VaultBenchmarkCoreConfig, duration time.Duration, logger hclog.Logger) (*benchmarktests.ProfileCapture, error) {
+	if conf.ProfileDir == "" {
+		return nil, nil
+	}
+	points, err := benchmarktests.ParseProfilePoints(conf.ProfileAt, duration)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing profile_at: %v", err)
+	}
+	length, err := time.ParseDuration(conf.ProfileLength)
+	if err != nil || length <= 0 {
+		return nil, fmt.Errorf("profile_duration must be a positive duration")
+	}
+	if err := os.MkdirAll(conf.ProfileDir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating profile directory: %v", err)
+	}
+	return &benchmarktests.ProfileCapture{
+		Dir:         conf.ProfileDir,
+		Points:      points,
+		CPUDuration: length,
+		Logger:      logger,
+	}, nil
+}
+
+// startDebug runs vault debug against addr for twice the length of the run,
+// capturing pprof profiles every interval. The debug process is added to wg,
+// and is stopped by the returned func.
+func startDebug(conf *vbConfig.VaultBenchmarkCoreConfig, addr, token string, interval, duration time.Duration, wg *sync.WaitGroup, logger hclog.Logger) func() {
+	_ = os.Setenv("VAULT_ADDR", addr)
+	_ = os.Setenv("VAULT_TOKEN", token)
+	if conf.CAPEMFile != "" {
+		_ = os.Setenv("VAULT_CACERT", conf.CAPEMFile)
+	}
+	cmd := exec.Command("vault", "debug", "-duration", (2 * duration).String(),
+		"-interval", interval.String(), "-compress=false")
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			logger.Error("error running pprof", "error", hclog.Fmt("%v", err))
+		}
+		logger.Info(fmt.Sprintf("pprof: %s", out))
+	}()
+
+	return func() {
+		// We can't use CommandContext because that uses sigkill, and we
+		// want the debug process to wrap things up and write indexes/etc.
+		logger.Info("stopping pprof")
+		cmd.Process.Signal(os.Interrupt)
+	}
+}
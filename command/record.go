@@ -0,0 +1,260 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*RecordCommand)(nil)
+	_ cli.CommandAutocomplete = (*RecordCommand)(nil)
+)
+
+// RecordCommand writes a workload replaying the requests of audit logs
+type RecordCommand struct {
+	*BaseCommand
+	flagOutput   string
+	flagListen   string
+	flagDuration time.Duration
+	flagMaxTests int
+	flagSpeed    float64
+
+	flagAnonymizePaths bool
+}
+
+func (r *RecordCommand) Synopsis() string {
+	return "Records a workload from audit logs"
+}
+
+func (r *RecordCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark record [options] [AUDIT_LOG...]
+
+ This command reads the requests of OpenBao audit logs and writes a config
+ replaying them: a raw test for each of the busiest kinds of request, with
+ the paths requested and anonymized payloads, and a trace of when each
+ request was sent, which the config replays with replay_file.
+
+ Audit logs are read from the files given, or from stdin when the file is
+ "-". With -listen, the entries of a socket audit device are read until
+ -duration has passed or the command is interrupted.
+
+	$ vault-benchmark record -output=workload /var/log/openbao/audit.log
+
+	$ vault-benchmark record -output=workload -listen=127.0.0.1:9090 -duration=1h
+
+ For a full list of examples, please see the documentation.
+
+` + r.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (r *RecordCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (r *RecordCommand) AutocompleteFlags() complete.Flags {
+	return r.Flags().Completions()
+}
+
+func (r *RecordCommand) Flags() *FlagSets {
+	set := r.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "output",
+		Target:     &r.flagOutput,
+		Default:    "workload",
+		Completion: complete.PredictDirs("*"),
+		Usage:      "Directory to write the config, trace and data files to, which must not already hold a config.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "listen",
+		Target:  &r.flagListen,
+		Default: "",
+		Usage: "Address to accept the connections of a socket audit device on, either host:port for TCP " +
+			"or unix:PATH for a Unix socket.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "duration",
+		Target:  &r.flagDuration,
+		Default: 0,
+		Usage:   "How long to record the entries of a socket audit device for. Defaults to recording until interrupted.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "max_tests",
+		Target:  &r.flagMaxTests,
+		Default: benchmarktests.RecordDefaultMaxTests,
+		Usage:   "Number of the busiest kinds of request to write tests for, up to 100. Requests of any others are left out.",
+	})
+
+	f.Float64Var(&Float64Var{
+		Name:    "speed",
+		Target:  &r.flagSpeed,
+		Default: 1,
+		Usage:   "How many times faster than recorded to replay the requests.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "anonymize_paths",
+		Target:  &r.flagAnonymizePaths,
+		Default: false,
+		Usage: "Write generated names in place of the recorded segments of paths below the prefix of a test, " +
+			"such as the names of users or secrets. Recorded paths are kept otherwise.",
+	})
+	return set
+}
+
+func (r *RecordCommand) Run(args []string) int {
+	f := r.Flags()
+
+	if err := f.Parse(args); err != nil {
+		r.UI.Error(err.Error())
+		return 1
+	}
+
+	switch {
+	case len(f.Args()) == 0 && r.flagListen == "":
+		r.UI.Error("an audit log or -listen is required")
+		return 1
+	case r.flagSpeed <= 0:
+		r.UI.Error("speed must be positive")
+		return 1
+	case r.flagDuration != 0 && r.flagListen == "":
+		r.UI.Error("duration requires listen")
+		return 1
+	}
+
+	recorder := &benchmarktests.AuditRecorder{}
+	for _, path := range f.Args() {
+		var in io.Reader = os.Stdin
+		if path != "-" {
+			file, err := os.Open(path)
+			if err != nil {
+				r.UI.Error(fmt.Sprintf("error opening audit log: %v", err))
+				return 1
+			}
+			defer file.Close()
+			in = file
+		}
+		if err := recorder.Read(in); err != nil {
+			r.UI.Error(fmt.Sprintf("error reading %v: %v", path, err))
+			return 1
+		}
+	}
+
+	if r.flagListen != "" {
+		if err := r.listen(recorder); err != nil {
+			r.UI.Error(err.Error())
+			return 1
+		}
+	}
+
+	workload, err := recorder.WriteWorkload(&benchmarktests.RecordOptions{
+		Dir:      r.flagOutput,
+		MaxTests: r.flagMaxTests,
+		Speed:    r.flagSpeed,
+
+		AnonymizePaths: r.flagAnonymizePaths,
+	})
+	if err != nil {
+		r.UI.Error(fmt.Sprintf("error writing workload: %v", err))
+		return 1
+	}
+
+	r.UI.Output(fmt.Sprintf("Recorded %d requests as %d tests replaying over %v to %v",
+		workload.Requests, workload.Tests, workload.Duration.Round(time.Millisecond), workload.ConfigPath))
+	if workload.Dropped > 0 {
+		r.UI.Warn(fmt.Sprintf("Left out %d requests of less busy kinds; raise -max_tests to keep them", workload.Dropped))
+	}
+	if skipped := recorder.Skipped(); skipped > 0 {
+		r.UI.Warn(fmt.Sprintf("Skipped %d audit log entries which couldn't be read or replayed", skipped))
+	}
+	return 0
+}
+
+// listen records the entries of the connections of a socket audit device
+// until the duration has passed or the command is interrupted
+func (r *RecordCommand) listen(recorder *benchmarktests.AuditRecorder) error {
+	network, address := "tcp", r.flagListen
+	if path, ok := strings.CutPrefix(r.flagListen, "unix:"); ok {
+		network, address = "unix", path
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return fmt.Errorf("error listening: %v", err)
+	}
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupted)
+	var timeout <-chan time.Time
+	if r.flagDuration > 0 {
+		timeout = time.After(r.flagDuration)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-interrupted:
+		case <-timeout:
+		case <-done:
+		}
+		ln.Close()
+	}()
+
+	r.UI.Info(fmt.Sprintf("Recording audit log entries sent to %v", ln.Addr()))
+	var lock sync.Mutex
+	conns := make(map[net.Conn]bool)
+	var wg sync.WaitGroup
+	for {
+		conn, err := ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error accepting connection: %v", err)
+		}
+		lock.Lock()
+		conns[conn] = true
+		lock.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Entries cut off when the connection is closed are skipped
+			recorder.Read(conn)
+			conn.Close()
+			lock.Lock()
+			delete(conns, conn)
+			lock.Unlock()
+		}()
+	}
+
+	// Connections are closed once recording stops, rather than waiting for
+	// the device to close them
+	lock.Lock()
+	for conn := range conns {
+		conn.Close()
+	}
+	lock.Unlock()
+	wg.Wait()
+	return nil
+}
@@ -0,0 +1,203 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// isTextReport returns whether the report_mode of the config prints text
+// reports, which search steps and SLO results are printed along with
+func isTextReport(conf *vbConfig.VaultBenchmarkCoreConfig) bool {
+	return conf.ReportMode != "json" && conf.ReportMode != "csv" && conf.ReportMode != "markdown"
+}
+
+// reportPercentiles returns the percentiles the reports of the run show, or
+// nil for the defaults. An unknown report_mode is only logged, as the
+// reports are then printed tersely.
+func reportPercentiles(conf *vbConfig.VaultBenchmarkCoreConfig, logger hclog.Logger) ([]float64, error) {
+	switch conf.ReportMode {
+	case "terse", "verbose", "json", "csv", "markdown":
+	default:
+		logger.Error("report_mode must be one of terse, verbose, json, csv, or markdown")
+	}
+
+	if conf.Percentiles == "" {
+		return nil, nil
+	}
+	return benchmarktests.ParsePercentiles(conf.Percentiles)
+}
+
+// printReports prints the reports of the attack on each address in the
+// report_mode of the config, after the steps of its throughput search.
+// Markdown tables compare the reports with the baseline, when there is one.
+func printReports(conf *vbConfig.VaultBenchmarkCoreConfig, addrs []string, attack *runAttack, baseline []*benchmarktests.Reporter, logger hclog.Logger) {
+	var tableReports []*benchmarktests.Reporter
+	for _, addr := range addrs {
+		if searchResult, ok := attack.searchResults[addr]; ok && isTextReport(conf) {
+			fmt.Printf("Target: %v\n", addr)
+			searchResult.ReportSteps(os.Stdout)
+			fmt.Println()
+		}
+		for _, rpt := range attack.results[addr] {
+			if conf.ReportMode == "csv" || conf.ReportMode == "markdown" {
+				// All reports share a single table, so are written at once
+				tableReports = append(tableReports, rpt)
+				continue
+			}
+			switch conf.ReportMode {
+			case "json":
+				rpt.ReportJSON(os.Stdout)
+			case "verbose":
+				rpt.ReportVerbose(os.Stdout)
+			default:
+				rpt.ReportTerse(os.Stdout)
+			}
+			fmt.Println()
+		}
+	}
+	switch conf.ReportMode {
+	case "csv":
+		if err := benchmarktests.ReportCSV(os.Stdout, tableReports); err != nil {
+			logger.Error("error writing report", "error", hclog.Fmt("%v", err))
+		}
+	case "markdown":
+		if err := benchmarktests.ReportMarkdown(os.Stdout, tableReports, baseline); err != nil {
+			logger.Error("error writing report", "error", hclog.Fmt("%v", err))
+		}
+	}
+}
+
+// runVerdict is whether a run passed, with the reasons it failed and the
+// results of the checks it was judged by
+type runVerdict struct {
+	failures      []string
+	sloResults    []*benchmarktests.SLOResult
+	baselineDiffs []*benchmarktests.TestDiff
+	regressions   []*benchmarktests.Regression
+}
+
+// testSLOs returns the slos of each test of the config. Those judged per
+// interval require the run to record a time series with seriesInterval.
+func testSLOs(conf *vbConfig.VaultBenchmarkCoreConfig, seriesInterval time.Duration) (map[string][]*benchmarktests.SLOConfig, error) {
+	slos := make(map[string][]*benchmarktests.SLOConfig)
+	for _, vbTest := range conf.Tests {
+		for _, slo := range vbTest.SLOs {
+			if slo.PerInterval && seriesInterval <= 0 {
+				return nil, fmt.Errorf("per_interval slo of %v requires timeseries_interval to be set", vbTest.Name)
+			}
+			slos[vbTest.Name] = append(slos[vbTest.Name], slo)
+		}
+	}
+	return slos, nil
+}
+
+// judgeRun checks whether the attack failed or exceeded its error budget,
+// and the current reports against the slos and the baseline, when there is
+// one. Missed SLOs are sent to the webhooks with notify.
+func judgeRun(conf *vbConfig.VaultBenchmarkCoreConfig, attack *runAttack, current []*benchmarktests.Reporter, slos map[string][]*benchmarktests.SLOConfig, baseline []*benchmarktests.Reporter, notify func(*benchmarktests.WebhookEvent), logger hclog.Logger) *runVerdict {
+	v := &runVerdict{}
+	if attack.failed {
+		logger.Error("benchmark failed: attack error")
+		v.fail("attack error")
+	}
+	if len(slos) > 0 {
+		v.sloResults = benchmarktests.CheckSLOs(current, slos)
+		if isTextReport(conf) {
+			if err := benchmarktests.WriteSLOResults(os.Stdout, v.sloResults); err != nil {
+				logger.Error("error writing slo results", "error", hclog.Fmt("%v", err))
+			}
+		}
+		var missed int
+		for _, result := range v.sloResults {
+			if !result.Passed {
+				logger.Error("slo missed", "test", result.Test, "phase", result.Phase, "slo", result.Objective, "actual", result.Actual)
+				missed++
+			}
+		}
+		if missed > 0 {
+			logger.Error("benchmark failed: slos missed", "missed", missed)
+			v.fail("slos missed")
+			notify(&benchmarktests.WebhookEvent{
+				Event: benchmarktests.WebhookSLOEvent,
+				SLOs:  benchmarktests.NewWebhookSLOs(v.sloResults),
+			})
+		}
+	}
+	if attack.budgetExceeded {
+		logger.Error("benchmark failed: error budget exceeded")
+		v.fail("error budget exceeded")
+	}
+	if baseline != nil {
+		regressionConfig := conf.Regression
+		if regressionConfig == nil {
+			regressionConfig = benchmarktests.DefaultRegressionConfig()
+		}
+		testConfigs := make(map[string]*benchmarktests.RegressionConfig, len(conf.Tests))
+		for _, vbTest := range conf.Tests {
+			testConfigs[vbTest.Name] = vbTest.Regression
+		}
+
+		v.baselineDiffs = benchmarktests.DiffReports(baseline, current)
+		v.regressions = benchmarktests.CheckRegressions(v.baselineDiffs, regressionConfig, testConfigs)
+		for _, regression := range v.regressions {
+			logger.Error("regression from baseline", "regression", regression.String())
+		}
+		if len(v.regressions) > 0 {
+			logger.Error("benchmark failed: results regressed from baseline", "regressions", len(v.regressions))
+			v.fail("results regressed from baseline")
+		} else {
+			logger.Info("no regressions from baseline", "baseline", conf.Baseline)
+		}
+	}
+	return v
+}
+
+// fail records a reason the run failed
+func (v *runVerdict) fail(reason string) {
+	v.failures = append(v.failures, reason)
+}
+
+// passed returns whether the run passed every check
+func (v *runVerdict) passed() bool {
+	return len(v.failures) == 0
+}
+
+// junitReport returns the JUnit report of the current reports and the
+// checks of the verdict, with the error budget when the config has one
+func (v *runVerdict) junitReport(conf *vbConfig.VaultBenchmarkCoreConfig, current []*benchmarktests.Reporter, budgetExceeded bool) *benchmarktests.JUnitReport {
+	junit := benchmarktests.NewJUnitReport(current)
+	junit.AddSLOResults(v.sloResults)
+	junit.AddRegressions(v.baselineDiffs, v.regressions)
+	hasBudget := conf.ErrorBudget != nil
+	for _, vbTest := range conf.Tests {
+		hasBudget = hasBudget || vbTest.ErrorBudget != nil
+	}
+	if hasBudget {
+		junit.AddErrorBudget(budgetExceeded)
+	}
+	return junit
+}
+
+// recordHistory records the run along with its reports in the history
+// database of the config, with the version and fingerprint of the server
+// it ran against when known
+func recordHistory(conf *vbConfig.VaultBenchmarkCoreConfig, history *benchmarktests.HistoryDB, run *benchmarktests.HistoryRun, info *benchmarktests.ServerInfo, current []*benchmarktests.Reporter, logger hclog.Logger) {
+	if info != nil {
+		run.Version = info.Version
+		run.Fingerprint = info.Fingerprint
+	}
+	id, err := history.Record(run, current)
+	if err != nil {
+		logger.Error("error recording run in history database", "error", hclog.Fmt("%v", err))
+		return
+	}
+	logger.Info("recorded run in history database", "path", conf.HistoryDB, "run", id)
+}
@@ -22,6 +22,7 @@ type ReviewCommand struct {
 	*BaseCommand
 	flagReviewResultsFile string
 	flagReportMode        string
+	flagPercentiles       string
 }
 
 func (r *ReviewCommand) Synopsis() string {
@@ -67,7 +68,14 @@ func (r *ReviewCommand) Flags() *FlagSets {
 		Name:    "report_mode",
 		Target:  &r.flagReportMode,
 		Default: "terse",
-		Usage:   "Reporting Mode. Options are: terse, verbose, json.",
+		Usage:   "Reporting Mode. Options are: terse, verbose, json, csv, markdown.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "report_percentiles",
+		Target:  &r.flagPercentiles,
+		Default: "",
+		Usage:   "Comma-separated latency percentiles to report, e.g. p50,p99.9,max.",
 	})
 	return set
 }
@@ -80,6 +88,16 @@ func (r *ReviewCommand) Run(args []string) int {
 		return 1
 	}
 
+	var percentiles []float64
+	if r.flagPercentiles != "" {
+		var err error
+		percentiles, err = benchmarktests.ParsePercentiles(r.flagPercentiles)
+		if err != nil {
+			r.UI.Error(fmt.Sprintf("error parsing report percentiles: %v", err))
+			return 1
+		}
+	}
+
 	// File Validity checking
 	fStat, err := os.Stat(r.flagReviewResultsFile)
 	if err != nil {
@@ -107,6 +125,23 @@ func (r *ReviewCommand) Run(args []string) int {
 		r.UI.Error("results file contains no valid reports")
 		return 1
 	}
+	for _, rpt := range rpts {
+		rpt.SetPercentiles(percentiles)
+	}
+	switch r.flagReportMode {
+	case "csv":
+		if err := benchmarktests.ReportCSV(os.Stdout, rpts); err != nil {
+			r.UI.Error(fmt.Sprintf("error writing report: %v", err))
+			return 1
+		}
+		return 0
+	case "markdown":
+		if err := benchmarktests.ReportMarkdown(os.Stdout, rpts, nil); err != nil {
+			r.UI.Error(fmt.Sprintf("error writing report: %v", err))
+			return 1
+		}
+		return 0
+	}
 	for _, rpt := range rpts {
 		switch r.flagReportMode {
 		case "json":
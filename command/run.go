@@ -4,13 +4,9 @@
 package command
 
 import (
-	"crypto/tls"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"net/http"
 	"os"
-	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,8 +18,6 @@ import (
 	vaultapi "github.com/openbao/openbao/api/v2"
 	"github.com/mitchellh/cli"
 	"github.com/posener/complete"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 const (
@@ -40,7 +34,28 @@ type RunCommand struct {
 	*BaseCommand
 	flagDuration         time.Duration
 	flagPPROFInterval    time.Duration
+	flagProfileLength    time.Duration
+	flagThinkTime        time.Duration
+	flagWarmup           time.Duration
+	flagReportInterval   time.Duration
+	flagDNSRefresh       time.Duration
+	flagIdleTimeout      time.Duration
+	flagTLSHandshake     time.Duration
+	flagSeriesInterval   time.Duration
+	flagCheckpointIntv   time.Duration
 	flagVaultAddr        string
+	flagVaultAddrs       []string
+	flagTokenPolicies    []string
+	flagVarFiles         []string
+	flagInclude          []string
+	flagExclude          []string
+	flagOverrides        []string
+	flagLoadBalance      string
+	flagReadAddr         string
+	flagNodeHeader       string
+	flagHTTPProxy        string
+	flagProxyAddr        string
+	flagDevTarget        string
 	flagVaultToken       string
 	flagAuditPath        string
 	flagVBCoreConfigPath string
@@ -49,13 +64,64 @@ type RunCommand struct {
 	flagReportMode       string
 	flagAnnotate         string
 	flagClusterJson      string
+	flagCluster          string
+	flagClusterMode      string
 	flagLogLevel         string
+	flagAttackMode       string
+	flagArrival          string
+	flagTokenType        string
+	flagThinkTimeDist    string
+	flagIntervalFile     string
+	flagRemoteWriteURL   string
+	flagRunID            string
+	flagInfluxFile       string
+	flagInfluxURL        string
+	flagInfluxToken      string
+	flagOTLPEndpoint     string
+	flagOTLPProtocol     string
+	flagOTLPTraces       string
+	flagOTLPTraceProto   string
+	flagTraceSampling    float64
+	flagReplayFile       string
+	flagBaseline         string
+	flagHistoryDB        string
+	flagJUnitFile        string
+	flagProfileDir       string
+	flagProfileAt        string
+	flagResourceURL      string
+	flagPercentiles      string
+	flagLoadShare        string
+	flagStartAt          string
+	flagCheckpoint       string
+	flagStateFile        string
 	flagWorkers          int
+	flagMaxInFlight      int
+	flagTokenPool        int
+	flagNamespaces       int
 	flagRPS              int
+	flagRequests         int
 	flagRandomMounts     bool
 	flagCleanup          bool
+	flagDryRun           bool
+	flagSetupOnly        bool
+	flagAttackOnly       bool
+	flagExportFile       string
+	flagExportFormat     string
+	flagExportRequests   int
 	flagDebug            bool
 	flagDisableHTTP2     bool
+	flagForceHTTP2       bool
+	flagCorrectOmission  bool
+	flagFollowRedirects  bool
+	flagLive             bool
+	flagResultLog        string
+	flagResultLogMaxMB   int
+	flagResultLogFiles   int
+	flagErrorSamples     int
+	flagMaxIdlePerHost   int
+	flagWorkerConns      int
+	flagLabels           map[string]string
+	flagVars             map[string]string
 }
 
 func (r *RunCommand) Synopsis() string {
@@ -98,6 +164,58 @@ func (r *RunCommand) Flags() *FlagSets {
 		Usage:   "Target Vault API Address.",
 	})
 
+	f.StringSliceVar(&StringSliceVar{
+		Name:   "vault_addrs",
+		Target: &r.flagVaultAddrs,
+		Usage: "Target Vault API Addresses, such as the nodes of a cluster. Each is attacked on its own " +
+			"unless load_balance is set. Can be given multiple times.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "load_balance",
+		Target:  &r.flagLoadBalance,
+		Default: "",
+		Usage: "Spread a single attack across all target addresses instead of attacking each on its own. " +
+			"Options are: round_robin, random, sticky, weighted, standby_reads.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "read_addr",
+		Target:  &r.flagReadAddr,
+		Default: "",
+		Usage:   "Read-only address, such as a load balancer in front of the standby nodes, to send reads to with the standby_reads load_balance strategy.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "node_header",
+		Target:  &r.flagNodeHeader,
+		Default: "",
+		Usage:   "Response header naming the node which served each request, such as one added by a load balancer, to break results down by node.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "proxy_addr",
+		Target:  &r.flagProxyAddr,
+		Default: "",
+		Usage:   "Address of an OpenBao Proxy or Agent, such as unix:///run/openbao/proxy.sock, to send benchmark requests through. Tests are set up directly against vault_addr.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "http_proxy",
+		Target:  &r.flagHTTPProxy,
+		Default: "",
+		Usage: "HTTP, HTTPS or SOCKS5 proxy, such as socks5://127.0.0.1:1080, to send all requests through instead of the one of " +
+			"the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables, or none to ignore them.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "dev_target",
+		Target:  &r.flagDevTarget,
+		Default: "",
+		Usage: "Start an OpenBao dev server to benchmark for the length of the run, from the given binary, e.g. bao, " +
+			"or docker image, e.g. docker://openbao/openbao:latest, instead of targeting vault_addr.",
+	})
+
 	f.StringVar(&StringVar{
 		Name:    "vault_token",
 		EnvVar:  "VAULT_TOKEN",
@@ -119,10 +237,47 @@ func (r *RunCommand) Flags() *FlagSets {
 		Target: &r.flagVBCoreConfigPath,
 		Completion: complete.PredictOr(
 			complete.PredictFiles("*.hcl"),
+			complete.PredictFiles("*.json"),
 		),
 		Usage: "Path to a vault-benchmark test configuration file.",
 	})
 
+	f.StringMapVar(&StringMapVar{
+		Name:   "var",
+		Target: &r.flagVars,
+		Usage: "Value of a variable declared by the config, as name=value, overriding its default and var_file. " +
+			"Can be given multiple times.",
+	})
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:       "var_file",
+		Target:     &r.flagVarFiles,
+		Completion: complete.PredictOr(complete.PredictFiles("*.hcl"), complete.PredictFiles("*.json")),
+		Usage: "Path to a file setting the values of variables declared by the config, overriding their defaults. " +
+			"Can be given multiple times; later files override earlier ones.",
+	})
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:   "include",
+		Target: &r.flagInclude,
+		Usage: "Glob matching the names or types of the tests of the config to run, such as kvv2_*, leaving out the others. " +
+			"Can be given multiple times.",
+	})
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:   "exclude",
+		Target: &r.flagExclude,
+		Usage: "Glob matching the names or types of the tests of the config to leave out, even when included. " +
+			"Can be given multiple times.",
+	})
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:   "override",
+		Target: &r.flagOverrides,
+		Usage: "Option of the tests of the config to override, as test.option=value, where test is a glob matching " +
+			"the names or types of tests. Options are: rps, duration, workers. Can be given multiple times.",
+	})
+
 	f.IntVar(&IntVar{
 		Name:    "workers",
 		Target:  &r.flagWorkers,
@@ -130,6 +285,45 @@ func (r *RunCommand) Flags() *FlagSets {
 		Usage:   "Number of workers",
 	})
 
+	f.IntVar(&IntVar{
+		Name:    "max_in_flight",
+		Target:  &r.flagMaxInFlight,
+		Default: 0,
+		Usage: "Maximum number of requests outstanding at once in the open attack mode. " +
+			"Setting to 0 caps it at the number of workers, otherwise more workers are started up to the cap.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "attack_token_type",
+		Target:  &r.flagTokenType,
+		Default: "",
+		Usage: "Type of the tokens created for the requests of the attack, while the benchmark's token " +
+			"keeps setting up and cleaning up tests. Options are: service, batch.",
+	})
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:   "attack_token_policies",
+		Target: &r.flagTokenPolicies,
+		Usage: "Policies of the tokens created for the requests of the attack, instead of those of " +
+			"the benchmark's token. Can be given multiple times.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "namespace_fanout",
+		Target:  &r.flagNamespaces,
+		Default: 0,
+		Usage: "Number of namespaces to create under vault_namespace, set every test up in each and " +
+			"spread the requests of each test across them.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "token_pool",
+		Target:  &r.flagTokenPool,
+		Default: 0,
+		Usage: "Number of tokens to create during setup and spread the requests of the attack across, " +
+			"instead of sending them all with the benchmark's token.",
+	})
+
 	f.IntVar(&IntVar{
 		Name:    "rps",
 		Target:  &r.flagRPS,
@@ -144,11 +338,252 @@ func (r *RunCommand) Flags() *FlagSets {
 		Usage:   "Test Duration.",
 	})
 
+	f.IntVar(&IntVar{
+		Name:    "requests",
+		Target:  &r.flagRequests,
+		Default: 0,
+		Usage: "Total number of requests to send. When set the test runs until all requests " +
+			"have been sent and duration is ignored.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "load_share",
+		Target:  &r.flagLoadShare,
+		Default: "",
+		Usage: "Share of the load to send when several instances attack together, as part/parts, e.g. 2/3. " +
+			"Rates, request counts and closed mode workers are divided between the parts.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "start_at",
+		Target:  &r.flagStartAt,
+		Default: "",
+		Usage:   "RFC 3339 time to wait for before starting the attack, so several instances start together.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "checkpoint_file",
+		Target:  &r.flagCheckpoint,
+		Default: "",
+		Usage: "Path to file to save the progress of the run to, which an interrupted run of the same " +
+			"configuration is resumed from.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "checkpoint_interval",
+		Target:  &r.flagCheckpointIntv,
+		Default: benchmarktests.DefaultCheckpointInterval,
+		Usage:   "Interval at which the progress of the run is saved to checkpoint_file.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "warmup",
+		Target:  &r.flagWarmup,
+		Default: 0,
+		Usage: "Period at the start of the attack during which requests are sent but " +
+			"excluded from the reported statistics. Warmup results are reported separately.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "attack_mode",
+		Target:  &r.flagAttackMode,
+		Default: "open",
+		Usage: "Attack Mode. Options are: open, closed. An open attack sends requests at the " +
+			"configured rps, a closed attack has each worker send requests back-to-back.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "arrival",
+		Target:  &r.flagArrival,
+		Default: "constant",
+		Usage: "Arrival process used to pace requests in the open attack mode. Options are: " +
+			"constant, poisson.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "think_time",
+		Target:  &r.flagThinkTime,
+		Default: 0,
+		Usage:   "Time each worker waits between requests when using the closed attack mode.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "think_time_distribution",
+		Target:  &r.flagThinkTimeDist,
+		Default: "fixed",
+		Usage: "Distribution the think time after each request is drawn from. Options are: " +
+			"fixed, uniform, exponential.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:   "replay_file",
+		Target: &r.flagReplayFile,
+		Completion: complete.PredictOr(
+			complete.PredictFiles("*.csv"),
+			complete.PredictFiles("*.ndjson"),
+		),
+		Default: "",
+		Usage: "Path to a trace of request arrival offsets and test names to replay instead of " +
+			"pacing requests at rps. Read as CSV if the file name ends in .csv, NDJSON otherwise.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "baseline",
+		Target:     &r.flagBaseline,
+		Completion: complete.PredictFiles("*.json"),
+		Default:    "",
+		Usage: "Path to a JSON results file to compare the results against. The benchmark fails when any test " +
+			"regressed by more than the limits of the regression block.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "history_db",
+		Target:  &r.flagHistoryDB,
+		Default: "",
+		Usage:   "Path to a SQLite database to record the results of the run in, for the history command.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "junit_file",
+		Target:  &r.flagJUnitFile,
+		Default: "",
+		Usage:   "Path to file to write a JUnit XML report of the results, SLOs and regression checks of each test to.",
+	})
+
 	f.StringVar(&StringVar{
 		Name:    "report_mode",
 		Target:  &r.flagReportMode,
 		Default: "terse",
-		Usage:   "Reporting Mode. Options are: terse, verbose, json.",
+		Usage:   "Reporting Mode. Options are: terse, verbose, json, csv, markdown.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "report_percentiles",
+		Target:  &r.flagPercentiles,
+		Default: "",
+		Usage:   "Comma-separated latency percentiles to report, e.g. p50,p99.9,max.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "dns_refresh_interval",
+		Target:  &r.flagDNSRefresh,
+		Default: 0,
+		Usage: "Interval at which the host names of the targets are resolved again, spreading connections across every " +
+			"address they resolve to, as clients of DNS load balanced or failover endpoints would.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "idle_conn_timeout",
+		Target:  &r.flagIdleTimeout,
+		Default: 0,
+		Usage:   "How long idle connections of the attack are kept open before being closed. Defaults to that of the Vault client.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "tls_handshake_timeout",
+		Target:  &r.flagTLSHandshake,
+		Default: 0,
+		Usage:   "How long the attack waits for TLS handshakes to complete. Defaults to that of the Vault client.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "report_interval",
+		Target:  &r.flagReportInterval,
+		Default: 0,
+		Usage: "Interval at which a summary of the results is written to report_interval_file, remote_write_url, " +
+			"influx_file and influx_url while the benchmark runs. Useful for long running soak tests.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "timeseries_interval",
+		Target:  &r.flagSeriesInterval,
+		Default: benchmarktests.DefaultTimeseriesInterval,
+		Usage:   "Length of each point of the time series of every test included in JSON reports. Setting to 0 disables it.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "report_interval_file",
+		Target:  &r.flagIntervalFile,
+		Default: "",
+		Usage:   "Path to file to write interval reports to as newline delimited JSON.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "remote_write_url",
+		Target:  &r.flagRemoteWriteURL,
+		Default: "",
+		Usage:   "Prometheus remote-write endpoint to push interval metrics to.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "run_id",
+		Target:  &r.flagRunID,
+		Default: "",
+		Usage:   "Identifier of the run in pushed metrics. Defaults to a random UUID.",
+	})
+
+	f.StringMapVar(&StringMapVar{
+		Name:   "label",
+		Target: &r.flagLabels,
+		Usage: "Label to attach to the results and exported metrics of the run, as key=value. " +
+			"Can be given multiple times.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "influx_file",
+		Target:  &r.flagInfluxFile,
+		Default: "",
+		Usage:   "Path to file to write interval metrics to in InfluxDB line protocol.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "influx_url",
+		Target:  &r.flagInfluxURL,
+		Default: "",
+		Usage:   "InfluxDB write URL to push interval metrics to in line protocol.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "influx_token",
+		Target:  &r.flagInfluxToken,
+		Default: "",
+		EnvVar:  "INFLUX_TOKEN",
+		Usage:   "Token used to authenticate to influx_url.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "otlp_metrics_endpoint",
+		Target:  &r.flagOTLPEndpoint,
+		Default: "",
+		Usage:   "OTLP endpoint URL to export benchmark metrics to.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "otlp_metrics_protocol",
+		Target:  &r.flagOTLPProtocol,
+		Default: benchmarktests.OTLPProtocolGRPC,
+		Usage:   "Protocol used to export OTLP metrics, either grpc or http.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "otlp_traces_endpoint",
+		Target:  &r.flagOTLPTraces,
+		Default: "",
+		Usage:   "OTLP endpoint URL to export spans of sampled requests to.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "otlp_traces_protocol",
+		Target:  &r.flagOTLPTraceProto,
+		Default: benchmarktests.OTLPProtocolGRPC,
+		Usage:   "Protocol used to export OTLP spans, either grpc or http.",
+	})
+
+	f.Float64Var(&Float64Var{
+		Name:    "trace_sample_ratio",
+		Target:  &r.flagTraceSampling,
+		Default: 0.01,
+		Usage:   "Share of requests traced when otlp_traces_endpoint is set, between 0 and 1.",
 	})
 
 	f.DurationVar(&DurationVar{
@@ -158,6 +593,36 @@ func (r *RunCommand) Flags() *FlagSets {
 		Usage:   "Collection interval for vault debug pprof profiling.",
 	})
 
+	f.StringVar(&StringVar{
+		Name:    "profile_dir",
+		Target:  &r.flagProfileDir,
+		Default: "",
+		Usage:   "Path to a directory to write CPU and heap profiles of the target, captured during the run, to.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "profile_at",
+		Target:  &r.flagProfileAt,
+		Default: benchmarktests.DefaultProfileAt,
+		Usage: "Comma separated points of the run to capture profiles at when profile_dir is set, each a " +
+			"duration from the start of the run or a percentage of its duration.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "profile_duration",
+		Target:  &r.flagProfileLength,
+		Default: benchmarktests.DefaultProfileDuration,
+		Usage:   "Duration CPU profiles are recorded for when profile_dir is set.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "resource_metrics_url",
+		Target:  &r.flagResourceURL,
+		Default: "",
+		Usage: "Prometheus metrics endpoint, such as node_exporter or cAdvisor, to sample the CPU, memory and GC pauses " +
+			"of the target from every timeseries_interval, correlated with the latency of the run in reports.",
+	})
+
 	f.StringVar(&StringVar{
 		Name:    "annotate",
 		Target:  &r.flagAnnotate,
@@ -187,6 +652,20 @@ func (r *RunCommand) Flags() *FlagSets {
 		Usage:   "Path to cluster.json file",
 	})
 
+	f.StringVar(&StringVar{
+		Name:    "cluster",
+		Target:  &r.flagCluster,
+		Default: "",
+		Usage:   "Name of the cluster block of the config to run against, instead of comparing all of them.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "cluster_mode",
+		Target:  &r.flagClusterMode,
+		Default: vbConfig.SequentialClusterMode,
+		Usage:   "Whether the clusters of the config are run against one after another or all at once. Options are: sequential, concurrent.",
+	})
+
 	f.BoolVar(&BoolVar{
 		Name:    "random_mounts",
 		Target:  &r.flagRandomMounts,
@@ -201,6 +680,55 @@ func (r *RunCommand) Flags() *FlagSets {
 		Usage:   "Cleanup benchmark artifacts after run.",
 	})
 
+	f.BoolVar(&BoolVar{
+		Name:    "dry_run",
+		Target:  &r.flagDryRun,
+		Default: false,
+		Usage:   "Print the mounts, setup requests and sample targets of each test instead of running them, without contacting the target.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "setup_only",
+		Target:  &r.flagSetupOnly,
+		Default: false,
+		Usage:   "Set the tests up and save their setup to state_file without attacking them, for attack_only runs to reuse.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "attack_only",
+		Target:  &r.flagAttackOnly,
+		Default: false,
+		Usage:   "Attack the tests set up by a setup_only run, loading their setup from state_file instead of setting them up.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "state_file",
+		Target:  &r.flagStateFile,
+		Default: "vault-benchmark-setup.json",
+		Usage:   "Path to file the setup of the tests is saved to by setup_only runs and loaded from by attack_only runs.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "export_file",
+		Target:  &r.flagExportFile,
+		Default: "",
+		Usage:   "Set the tests up and write the targets they would be attacked with to this file instead of attacking them, for other load generators to send.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "export_format",
+		Target:  &r.flagExportFormat,
+		Default: benchmarktests.ExportFormatVegeta,
+		Usage:   "Format of export_file. Options are: vegeta, k6.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "export_requests",
+		Target:  &r.flagExportRequests,
+		Default: benchmarktests.ExportDefaultRequests,
+		Usage:   "Number of targets to write to export_file, picked by the weights of the tests.",
+	})
+
 	f.StringVar(&StringVar{
 		Name:    "log_level",
 		Target:  &r.flagLogLevel,
@@ -223,6 +751,77 @@ func (r *RunCommand) Flags() *FlagSets {
 		Usage:   "Force HTTP/1.1",
 	})
 
+	f.BoolVar(&BoolVar{
+		Name:    "force_http2",
+		Target:  &r.flagForceHTTP2,
+		Default: false,
+		Usage:   "Force HTTP/2 for the attack, over cleartext (h2c) for http:// addresses.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "correct_coordinated_omission",
+		Target:  &r.flagCorrectOmission,
+		Default: false,
+		Usage:   "Measure open loop latencies from when requests were scheduled to be sent.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "follow_redirects",
+		Target:  &r.flagFollowRedirects,
+		Default: false,
+		Usage:   "Follow the redirects of standby nodes to the active node, reporting redirected requests and the latency redirects added separately.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "result_log",
+		Target:  &r.flagResultLog,
+		Default: "",
+		Usage:   "Path to file to stream every result to as newline delimited JSON, or - for stdout.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "result_log_max_size_mb",
+		Target:  &r.flagResultLogMaxMB,
+		Default: 0,
+		Usage:   "Size in megabytes at which the result log is rotated. Setting to 0 never rotates it.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "result_log_max_files",
+		Target:  &r.flagResultLogFiles,
+		Default: 5,
+		Usage:   "Number of rotated result log files to keep.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "error_samples",
+		Target:  &r.flagErrorSamples,
+		Default: benchmarktests.DefaultErrorSamples,
+		Usage:   "Number of response bodies to keep in the results for each group of failed requests.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "max_idle_conns_per_host",
+		Target:  &r.flagMaxIdlePerHost,
+		Default: 0,
+		Usage:   "Number of idle connections of the attack kept open to each server. Defaults to that of the Vault client.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "worker_connections",
+		Target:  &r.flagWorkerConns,
+		Default: 0,
+		Usage: "Number of connections each worker of the closed attack mode may open to each server, " +
+			"on its own instead of sharing a pool with the other workers. Setting to 0 shares one pool.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:    "live",
+		Target:  &r.flagLive,
+		Default: false,
+		Usage:   "Show live rates, latency percentiles and errors of each test on the terminal while the benchmark runs.",
+	})
+
 	// Add any additional flags from tests
 	for _, vbTest := range benchmarktests.TestList {
 		vbTest().Flags(f.mainSet)
@@ -245,171 +844,244 @@ func (r *RunCommand) Run(args []string) int {
 		return 1
 	}
 
-	// Load config from File
-	if r.flagVBCoreConfigPath == "" {
-		benchmarkLogger.Error("no config file location passed")
+	defer benchmarktests.StopPlugins()
+	conf, err := r.loadConfig(f, benchmarkLogger)
+	if err != nil {
+		benchmarkLogger.Error("invalid config", "error", hclog.Fmt("%v", err))
 		return 1
 	}
 
-	conf := vbConfig.NewVaultBenchmarkCoreConfig()
-	err := conf.LoadConfig(r.flagVBCoreConfigPath)
+	// Configs with clusters run against each of them in turn, unless one
+	// is chosen, comparing the results
+	allClusters, err := r.chooseCluster(conf)
 	if err != nil {
-		benchmarkLogger.Error("error loading config", "error", hclog.Fmt("%v", err))
+		benchmarkLogger.Error("error choosing cluster", "error", hclog.Fmt("%v", err))
 		return 1
 	}
+	if allClusters {
+		return r.runClusters(args, conf, benchmarkLogger)
+	}
 
-	r.applyConfigOverrides(f, conf)
-	benchmarkLogger.SetLevel(hclog.LevelFromString(conf.LogLevel))
+	startAt, err := applyLoadShare(conf)
+	if err != nil {
+		benchmarkLogger.Error("invalid share of distributed run", "error", hclog.Fmt("%v", err))
+		return 1
+	}
 
 	// Parse Duration from configuration string
 	parsedDuration, err := time.ParseDuration(conf.Duration)
 	if err != nil {
 		benchmarkLogger.Error("error parsing test duration from configuration", "error", hclog.Fmt("%v", err))
 	}
+	// The duration may have been set by a flag after the config was checked
+	if err := conf.CheckDuration(); err != nil {
+		benchmarkLogger.Error("invalid duration", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	// The run lasts for longer with phases, tests with their own duration
+	// or a replay file
+	parsedDuration, replay, err := runLength(conf, parsedDuration)
+	if err != nil {
+		benchmarkLogger.Error("error configuring the length of the run", "error", hclog.Fmt("%v", err))
+		return 1
+	}
 
-	// Parse pprof Interval from configuration string
-	var parsedPPROFinterval time.Duration
-	if conf.PPROFInterval != "" {
-		parsedPPROFinterval, err = time.ParseDuration(conf.PPROFInterval)
+	// Read the baseline up front so a bad file doesn't waste a run
+	var baseline []*benchmarktests.Reporter
+	if conf.Baseline != "" {
+		baseline, err = readResultsFile(conf.Baseline)
 		if err != nil {
-			benchmarkLogger.Error("error parsing pprof interval from configuration", "error", hclog.Fmt("%v", err))
+			benchmarkLogger.Error("error reading baseline", "error", hclog.Fmt("%v", err))
 			return 1
 		}
 	}
 
-	if (!conf.RandomMounts) && (conf.Cleanup) {
-		benchmarkLogger.Error("cleanup can only be enabled when random mounts is enabled")
+	// Likewise open the history database before running
+	var history *benchmarktests.HistoryDB
+	if conf.HistoryDB != "" {
+		history, err = benchmarktests.OpenHistoryDB(conf.HistoryDB)
+		if err != nil {
+			benchmarkLogger.Error("error opening history database", "error", hclog.Fmt("%v", err))
+			return 1
+		}
+		defer history.Close()
+	}
+
+	intervals, err := parseIntervals(conf)
+	if err != nil {
+		benchmarkLogger.Error("error parsing intervals from configuration", "error", hclog.Fmt("%v", err))
 		return 1
 	}
 
-	switch conf.ReportMode {
-	case "terse", "verbose", "json":
-	default:
-		benchmarkLogger.Error("report_mode must be one of terse, verbose, or json")
+	// Parse the points to capture profiles at against the length of the run
+	profiles, err := profileCapture(conf, parsedDuration, benchmarkLogger)
+	if err != nil {
+		benchmarkLogger.Error("error configuring profiles", "error", hclog.Fmt("%v", err))
+		return 1
 	}
 
-	var cluster struct {
-		Token      string   `json:"token"`
-		VaultAddrs []string `json:"vault_addrs"`
+	// Parse the points to run chaos events at against the length of the run
+	chaosPoints, err := parseChaosPoints(conf, parsedDuration)
+	if err != nil {
+		benchmarkLogger.Error("error configuring chaos", "error", hclog.Fmt("%v", err))
+		return 1
 	}
 
-	switch {
-	case conf.ClusterJSON != "":
-		b, err := os.ReadFile(conf.ClusterJSON)
-		if err != nil {
-			benchmarkLogger.Error(fmt.Sprintf("error reading cluster_json file: %q", conf.ClusterJSON), "error", hclog.Fmt("%v", err))
-			return 1
-		}
-		err = json.Unmarshal(b, &cluster)
-		if err != nil {
-			benchmarkLogger.Error(fmt.Sprintf("error decoding cluster_json file: %q", conf.ClusterJSON), "error", hclog.Fmt("%v", err))
-			return 1
-		}
-	case conf.VaultAddr != "":
-		cluster.VaultAddrs = []string{conf.VaultAddr}
-	default:
-		benchmarkLogger.Error("must specify one of cluster_json, vault_addr, or $VAULT_ADDR")
+	if err := checkLimits(conf); err != nil {
+		benchmarkLogger.Error("invalid run limits", "error", hclog.Fmt("%v", err))
+		return 1
 	}
 
-	if conf.VaultToken != "" {
-		cluster.Token = conf.VaultToken
+	if err := r.checkRunModes(conf); err != nil {
+		benchmarkLogger.Error("invalid run mode", "error", hclog.Fmt("%v", err))
+		return 1
 	}
-	if conf.VaultToken == "" && cluster.Token == "" {
-		benchmarkLogger.Error("must specify one of the following: cluster_json, vault_token, or $VAULT_TOKEN")
+
+	poolConfig, err := tokenPoolConfig(conf, benchmarkLogger)
+	if err != nil {
+		benchmarkLogger.Error("invalid token_pool or attack_token_type", "error", hclog.Fmt("%v", err))
 		return 1
 	}
 
-	// Setup annotations and testRunning metric
-	var annoLabels []string
-	var annoValues []string
-	if conf.Annotate != "" {
-		for _, kv := range strings.Split(conf.Annotate, ",") {
-			kvPair := strings.SplitN(kv, "=", 2)
-			if len(kvPair) != 2 || kvPair[0] == "" {
-				benchmarkLogger.Error("annotate should contain comma-separated list of name=value pairs", "got", conf.Annotate)
-				return 1
-			}
-			annoLabels = append(annoLabels, kvPair[0])
-			annoValues = append(annoValues, kvPair[1])
-		}
+	checkpointInterval, err := checkpointInterval(conf)
+	if err != nil {
+		benchmarkLogger.Error("invalid checkpoint", "error", hclog.Fmt("%v", err))
+		return 1
 	}
 
-	testRunning := prometheus.NewGaugeVec(prometheus.GaugeOpts{
-		Name: "bench_running",
-		Help: "is the benchmark attack executing",
-	}, annoLabels)
-	prometheus.MustRegister(testRunning)
-	testRunning.WithLabelValues(annoValues...).Set(0)
-
-	// Setup our prometheus listener
-	http.Handle("/metrics", promhttp.Handler())
-	go func() {
-		_ = http.ListenAndServe(":2112", nil)
-	}()
-
-	// Create vault clients
-	var clients []*vaultapi.Client
-	for _, addr := range cluster.VaultAddrs {
-		tlsCfg := &vaultapi.TLSConfig{}
-		cfg := vaultapi.DefaultConfig()
-		if conf.CAPEMFile != "" {
-			tlsCfg.CACert = conf.CAPEMFile
-		}
+	transport, err := transportConfig(conf, intervals.dnsRefresh)
+	if err != nil {
+		benchmarkLogger.Error("invalid transport settings", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	// Plugins and credential helpers are only run by runs setting the tests
+	// up, once the config has been checked
+	if err := conf.StartPlugins(); err != nil {
+		benchmarkLogger.Error("error starting plugins", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	if err := conf.ResolveCredentials(); err != nil {
+		benchmarkLogger.Error("error loading config", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	// A dry run sets the tests up against a stand-in for the server and
+	// prints what they would do, before anything is written or started
+	if r.flagDryRun {
+		return r.dryRun(conf, parsedDuration, len(replay) > 0, benchmarkLogger)
+	}
 
-		err := cfg.ConfigureTLS(tlsCfg)
+	slos, err := testSLOs(conf, intervals.series)
+	if err != nil {
+		benchmarkLogger.Error("invalid slo", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	// Resource usage is sampled every point of the time series, so it can
+	// be correlated with the latency of each point
+	if conf.ResourceURL != "" && intervals.series <= 0 {
+		benchmarkLogger.Error("resource_metrics_url requires timeseries_interval to be set")
+		return 1
+	}
+
+	if err := benchmarktests.ValidateLabels(conf.Labels); err != nil {
+		benchmarkLogger.Error("invalid labels", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	// Runs with a checkpoint file resume the interrupted run of the same
+	// configuration, if there is one. The mounts of an interrupted run are
+	// removed either way, as its tests are set up again.
+	resumed, err := r.loadCheckpoint(conf, args, benchmarkLogger)
+	if err != nil {
+		benchmarkLogger.Error("error configuring checkpoint", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	// Attack only runs attack the tests as a setup only run left them
+	var setupState *benchmarktests.SetupState
+	if r.flagAttackOnly {
+		setupState, err = benchmarktests.LoadSetupState(r.flagStateFile)
 		if err != nil {
-			benchmarkLogger.Error("error creating vault client", "error", hclog.Fmt("%v", err))
+			benchmarkLogger.Error("error loading setup state", "error", hclog.Fmt("%v", err))
 			return 1
 		}
+	}
 
-		// Check if we're forcing HTTP/1.1. Used to make sure benchmark traffic
-		// is spread across nodes when Vault is behind a load balancer.
-		if conf.DisableHTTP2 {
-			benchmarkLogger.Warn("http2 disabled, using http/1.1")
-			transport := cfg.HttpClient.Transport.(*http.Transport)
-			transport.TLSClientConfig.NextProtos = []string{"http/1.1"}
-			transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
-			transport.ForceAttemptHTTP2 = false
-			cfg.HttpClient.Transport = transport
-		}
+	runID, err := resolveRunID(conf, resumed)
+	if err != nil {
+		benchmarkLogger.Error("error generating run id", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	checkpoint := resumed.file(conf, runID, checkpointInterval)
+	exporters, err := startExporters(conf, runID, intervals.report, benchmarkLogger)
+	if err != nil {
+		benchmarkLogger.Error("error starting exporters", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	defer exporters.Stop()
 
-		cfg.Address = addr
-		client, err := vaultapi.NewClient(cfg)
-		if err != nil {
-			benchmarkLogger.Error("error creating vault client", "error", hclog.Fmt("%v", err))
+	if err := checkAttackMode(conf, intervals.thinkTime, benchmarkLogger); err != nil {
+		benchmarkLogger.Error("invalid attack mode", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	percentiles, err := reportPercentiles(conf, benchmarkLogger)
+	if err != nil {
+		benchmarkLogger.Error("error parsing report percentiles", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	// Services started for the run, such as a dev server or dependency
+	// containers, are torn down in reverse order when it ends or is
+	// interrupted
+	services := newRunServices()
+	defer services.Stop()
+	if len(conf.Dependencies) > 0 {
+		if err := services.startDependencies(conf, benchmarkLogger.Named("dependency")); err != nil {
+			benchmarkLogger.Error("error starting dependencies", "error", hclog.Fmt("%v", err))
 			return 1
 		}
-		client.SetToken(cluster.Token)
-		client.SetNamespace(conf.VaultNamespace)
-		clients = append(clients, client)
 	}
 
-	var wg sync.WaitGroup
+	metrics, err := serveMetrics(conf)
+	if err != nil {
+		benchmarkLogger.Error("error serving metrics", "error", hclog.Fmt("%v", err))
+		return 1
+	}
 
-	if parsedPPROFinterval.Seconds() != 0 {
-		_ = os.Setenv("VAULT_ADDR", cluster.VaultAddrs[0])
-		_ = os.Setenv("VAULT_TOKEN", cluster.Token)
-		if conf.CAPEMFile != "" {
-			_ = os.Setenv("VAULT_CACERT", conf.CAPEMFile)
-		}
-		cmd := exec.Command("vault", "debug", "-duration", (2 * parsedDuration).String(),
-			"-interval", parsedPPROFinterval.String(), "-compress=false")
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			out, err := cmd.CombinedOutput()
-			if err != nil {
-				benchmarkLogger.Error("error running pprof", "error", hclog.Fmt("%v", err))
-			}
-			benchmarkLogger.Info(fmt.Sprintf("pprof: %s", out))
-		}()
+	targets, err := connectTargets(conf, transport, services.add, benchmarkLogger)
+	if err != nil {
+		benchmarkLogger.Error("error connecting to the targets", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	clients := targets.clients
 
-		defer func() {
-			// We can't use CommandContext because that uses sigkill, and we
-			// want the debug process to wrap things up and write indexes/etc.
-			benchmarkLogger.Info("stopping pprof")
-			cmd.Process.Signal(os.Interrupt)
-		}()
+	stopRenewal := make(chan struct{})
+	defer close(stopRenewal)
+	tokenRenewer := targets.renewToken(stopRenewal)
+
+	serverInfo := targets.serverInfo()
+
+	chaosEvents, err := scheduleChaos(conf, chaosPoints, clients[0])
+	if err != nil {
+		benchmarkLogger.Error("error configuring chaos", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	loadBalance, attackClients, err := targets.loadBalance(serverInfo)
+	if err != nil {
+		benchmarkLogger.Error("error balancing the load", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	var wg sync.WaitGroup
+
+	if intervals.pprof.Seconds() != 0 {
+		stopDebug := startDebug(conf, targets.addrs[0], targets.token, intervals.pprof, parsedDuration, &wg, benchmarkLogger)
+		defer stopDebug()
 	}
 
 	// Enable file audit device at specified path if flag set
@@ -426,84 +1098,284 @@ func (r *RunCommand) Run(args []string) int {
 		}
 	}
 
-	testRunning.WithLabelValues(annoValues...).Set(1)
-	benchmarkLogger.Info("setting up targets")
-
-	topLevelConfig := benchmarktests.TopLevelTargetConfig{
-		Duration:     parsedDuration,
-		RandomMounts: conf.RandomMounts,
+	metrics.setRunning(true)
+	resumed.cleanup(clients[0], benchmarkLogger)
+	benchmarkLogger.Info("setting up targets")
+
+	topLevelConfig := benchmarktests.TopLevelTargetConfig{
+		Duration:     parsedDuration,
+		RandomMounts: conf.RandomMounts,
+
+		IgnoreWeights: len(replay) > 0,
+	}
+
+	if r.flagSetupOnly {
+		return r.setupOnly(clients[0], conf, &topLevelConfig, benchmarkLogger)
+	}
+
+	tm, fanOut, err := r.buildTargets(clients[0], conf, setupState, &topLevelConfig, benchmarkLogger)
+	if err != nil {
+		benchmarkLogger.Error(fmt.Sprintf("target setup failed: %v", err))
+		return 1
+	}
+	if r.flagExportFile != "" {
+		return r.exportTargets(tm, clients[0], conf, parsedDuration, benchmarkLogger)
+	}
+	if checkpoint != nil {
+		if err := checkpoint.SetTargets(tm, fanOut.Names()); err != nil {
+			benchmarkLogger.Error("error saving checkpoint", "error", hclog.Fmt("%v", err))
+			return 1
+		}
+	}
+
+	// Spread the requests of the attack across a pool of tokens, which are
+	// revoked once the run ends
+	var tokenPool *benchmarktests.TokenPool
+	if poolConfig.Size > 0 {
+		benchmarkLogger.Info("creating token pool", "tokens", poolConfig.Size, "type", poolConfig.Type)
+		tokenPool, err = benchmarktests.NewTokenPool(clients[0], poolConfig)
+		if err != nil {
+			benchmarkLogger.Error("error creating token pool", "error", hclog.Fmt("%v", err))
+			return 1
+		}
+		defer func() {
+			if err := tokenPool.Revoke(clients[0]); err != nil {
+				benchmarkLogger.Error("error revoking token pool", "error", hclog.Fmt("%v", err))
+			}
+		}()
+	}
+
+	attackConfig := benchmarktests.AttackConfig{
+		Duration:  parsedDuration,
+		RPS:       conf.RPS,
+		Workers:   conf.Workers,
+		Mode:      conf.AttackMode,
+		Arrival:   conf.Arrival,
+		ThinkTime: intervals.thinkTime,
+		Warmup:    intervals.warmup,
+
+		ThinkTimeDistribution: conf.ThinkTimeDist,
+
+		ReportInterval: intervals.report,
+		IntervalOutput: exporters.intervalOutput(),
+		ErrorBudget:    conf.ErrorBudget,
+		MaxInFlight:    conf.MaxInFlight,
+		Replay:         replay,
+
+		CorrectOmission: conf.COCorrection,
+		ErrorSamples:    *conf.ErrorSamples,
+		LoadBalance:     loadBalance,
+		NodeHeader:      conf.NodeHeader,
+		Proxy:           targets.proxy,
+		FollowRedirects: conf.FollowRedirect,
+		DNSRefresh:      intervals.dnsRefresh,
+		Transport:       transport,
+		TokenRenewer:    tokenRenewer,
+		TokenPool:       tokenPool,
+		Namespaces:      fanOut.Names(),
+
+		TimeseriesInterval: intervals.series,
+		TrackRecovery:      len(conf.Chaos) > 0 || conf.Failover != nil || conf.Snapshot != nil,
+	}
+
+	resultLog, err := openResultLog(conf)
+	if err != nil {
+		benchmarkLogger.Error("error opening result log", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	attackConfig.ResultLog = resultLog
+
+	attackConfig.Live = liveView(conf, benchmarkLogger)
+
+	phases, search, err := buildAttackModes(conf, tm, &attackConfig)
+	if err != nil {
+		benchmarkLogger.Error("error configuring the attack", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+
+	waitToStart(startAt, benchmarkLogger)
+
+	if conf.Requests > 0 {
+		benchmarkLogger.Info("starting benchmarks", "requests", conf.Requests, "mode", conf.AttackMode)
+	} else {
+		benchmarkLogger.Info("starting benchmarks", "duration", hclog.Fmt("%v", parsedDuration.String()), "mode", conf.AttackMode)
+	}
+	runTargets := make([]string, 0, len(attackClients))
+	for _, client := range attackClients {
+		runTargets = append(runTargets, client.Address())
+	}
+	var plannedDuration string
+	if conf.Requests == 0 && len(replay) == 0 {
+		plannedDuration = parsedDuration.String()
+	}
+
+	attack := &runAttack{
+		conf:          conf,
+		tm:            tm,
+		fanOut:        fanOut,
+		phases:        phases,
+		search:        search,
+		attackConfig:  &attackConfig,
+		checkpoint:    checkpoint,
+		debug:         r.flagDebug,
+		logger:        benchmarkLogger,
+		results:       make(map[string][]*benchmarktests.Reporter),
+		searchResults: make(map[string]*benchmarktests.SearchResult),
+	}
+
+	// Hooks of the run are run once the tests are set up, and a failed one
+	// stops the attack from starting. Those of each test are run around
+	// its own attacks.
+	hookLogger := benchmarkLogger.Named("hook")
+	attackConfig.TestHooks = &benchmarktests.TestHooks{RunID: runID, Logger: hookLogger}
+	if err := runBeforeHooks(conf, benchmarktests.HookRun{RunID: runID, Targets: runTargets, Duration: plannedDuration}, hookLogger); err != nil {
+		benchmarkLogger.Error("benchmark failed: hook failed before the attack", "error", hclog.Fmt("%v", err))
+		for _, client := range attackClients {
+			attack.cleanupTarget(client)
+		}
+		return 1
+	}
+
+	// Webhooks are notified before the attack starts, so a slow webhook
+	// doesn't take from the run
+	webhooks := newRunWebhooks(conf, runID, runTargets, benchmarkLogger)
+	webhooks.started(plannedDuration)
+
+	runStarted := time.Now()
+	runEnded := make(chan struct{})
+	monitors := newRunMonitors(conf, clients, profiles, chaosEvents, attackConfig.Live, intervals.series, benchmarkLogger)
+	monitors.start(runStarted, runEnded)
+	if loadBalance != nil && loadBalance.Nodes != nil {
+		go refreshNodes(targets.seed, loadBalance.Nodes, targets.nodeRefresh, runEnded, benchmarkLogger.Named("discovery"))
+	}
+
+	// Attacks which fail still clean up and end the run as usual, so the
+	// services of the run are torn down and its hooks and webhooks run
+	for _, client := range attackClients {
+		wg.Add(1)
+		go func(client *vaultapi.Client) {
+			defer wg.Done()
+			attack.target(client)
+		}(client)
+	}
+
+	wg.Wait()
+	runDuration := time.Since(runStarted)
+	close(runEnded)
+	monitors.wait()
+
+	attack.finishCheckpoint()
+
+	if resultLog != nil {
+		resultLog.Close()
+		if err := resultLog.Err(); err != nil {
+			benchmarkLogger.Error("result log may be incomplete", "error", hclog.Fmt("%v", err))
+		}
+	}
+
+	exporters.flush()
+
+	metrics.setRunning(false)
+	benchmarkLogger.Info("benchmark complete")
+
+	var current []*benchmarktests.Reporter
+	for _, addr := range runTargets {
+		for _, rpt := range attack.results[addr] {
+			rpt.SetPercentiles(percentiles)
+			rpt.SetServerInfo(serverInfo[addr])
+			rpt.SetLabels(conf.Labels)
+			rpt.SetTokenRenewal(tokenRenewer.Stats())
+			monitors.annotate(rpt)
+			current = append(current, rpt)
+		}
+	}
+	printReports(conf, runTargets, attack, baseline, benchmarkLogger)
+	verdict := judgeRun(conf, attack, current, slos, baseline, webhooks.notify, benchmarkLogger)
+	if err := attackConfig.TestHooks.Err(); err != nil {
+		benchmarkLogger.Error("benchmark failed: hook of a test failed", "error", hclog.Fmt("%v", err))
+		verdict.fail("hook failed")
+	}
+
+	// Hooks after the attack are told whether the run passed so far, and
+	// all of them run even when one fails
+	passed := verdict.passed()
+	if err := runAfterHooks(conf, benchmarktests.HookRun{RunID: runID, Targets: runTargets, Duration: runDuration.Round(time.Millisecond).String(), Passed: &passed}, hookLogger); err != nil {
+		benchmarkLogger.Error("benchmark failed: hook failed after the attack", "error", hclog.Fmt("%v", err))
+		verdict.fail("hook failed")
+	}
+	if conf.JUnitFile != "" {
+		if err := writeJUnitFile(conf.JUnitFile, verdict.junitReport(conf, current, attack.budgetExceeded)); err != nil {
+			benchmarkLogger.Error("error writing junit report", "error", hclog.Fmt("%v", err))
+		}
+	}
+	if history != nil {
+		run := &benchmarktests.HistoryRun{
+			RunID:    runID,
+			Started:  runStarted,
+			Duration: runDuration,
+			Labels:   conf.Labels,
+			Passed:   verdict.passed(),
+		}
+		// Clusters run a single version, so the first target describes it
+		recordHistory(conf, history, run, serverInfo[clients[0].Address()], current, benchmarkLogger)
+	}
+	webhooks.completed(verdict, runDuration, current)
+	if !verdict.passed() {
+		return 1
 	}
+	return 0
+}
 
-	tm, err := benchmarktests.BuildTargets(clients[0], conf.Tests, &benchmarkLogger, &topLevelConfig)
+// writeJUnitFile writes the JUnit report of the run to path
+func writeJUnitFile(path string, junit *benchmarktests.JUnitReport) error {
+	f, err := os.Create(path)
 	if err != nil {
-		benchmarkLogger.Error(fmt.Sprintf("target setup failed: %v", err))
-		return 1
+		return fmt.Errorf("error creating file: %v", err)
+	}
+	if err := junit.Write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing file: %v", err)
 	}
+	return f.Close()
+}
 
-	var l sync.Mutex
-	results := make(map[string]*benchmarktests.Reporter)
-	benchmarkLogger.Info("starting benchmarks", "duration", hclog.Fmt("%v", parsedDuration.String()))
-	for _, client := range clients {
-		wg.Add(1)
-		go func(client *vaultapi.Client) {
-			defer wg.Done()
+// loadConfig loads the config of the run, along with the flags overriding
+// it and the tests it is filtered to
+func (r *RunCommand) loadConfig(f *FlagSets, logger hclog.Logger) (*vbConfig.VaultBenchmarkCoreConfig, error) {
+	if r.flagVBCoreConfigPath == "" {
+		return nil, fmt.Errorf("no config file location passed")
+	}
 
-			if r.flagDebug {
-				if !benchmarkLogger.IsTrace() {
-					benchmarkLogger.SetLevel(hclog.Debug)
-				}
-				l.Lock()
-				benchmarkLogger.Debug("=== Debug Info ===")
-				benchmarkLogger.Debug(fmt.Sprintf("Client: %s", client.Address()))
-				tm.DebugInfo(client)
-				l.Unlock()
-			}
+	conf := vbConfig.NewVaultBenchmarkCoreConfig()
+	conf.Vars = r.flagVars
+	conf.VarFiles = r.flagVarFiles
+	conf.Prompt = r.UI.AskSecret
+	if err := conf.LoadConfig(r.flagVBCoreConfigPath); err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
 
-			rpt, err := benchmarktests.Attack(tm, client, parsedDuration, conf.RPS, conf.Workers)
-			if err != nil {
-				benchmarkLogger.Error("attack error", "err", hclog.Fmt("%v", err))
-				os.Exit(1)
-			}
+	r.applyConfigOverrides(f, conf)
+	logger.SetLevel(hclog.LevelFromString(conf.LogLevel))
 
-			l.Lock()
-			// TODO rethink how we present results when multiple nodes are attacked
-			results[client.Address()] = rpt
-			l.Unlock()
-
-			if conf.Cleanup {
-				benchmarkLogger.Info("cleaning up targets")
-				err := tm.Cleanup(client)
-				if err != nil {
-					benchmarkLogger.Error("cleanup error", "err", hclog.Fmt("%v", err))
-				}
-				if conf.AuditPath != "" {
-					_, err := client.Logical().Delete("/sys/audit/bench-audit")
-					if err != nil {
-						benchmarkLogger.Error("error disabling bench-audit audit device", "error", hclog.Fmt("%v", err))
-					}
-				}
-			}
-		}(client)
+	// Overrides adjust tests without editing the config, such as in sweeps
+	if len(r.flagOverrides) > 0 {
+		if err := conf.ApplyTestOverrides(r.flagOverrides); err != nil {
+			return nil, fmt.Errorf("error applying overrides: %w", err)
+		}
 	}
 
-	wg.Wait()
-
-	testRunning.WithLabelValues(annoValues...).Set(0)
-	benchmarkLogger.Info("benchmark complete")
-	for _, client := range clients {
-		addr := client.Address()
-		rpt := results[addr]
-		switch conf.ReportMode {
-		case "json":
-			rpt.ReportJSON(os.Stdout)
-		case "verbose":
-			rpt.ReportVerbose(os.Stdout)
-		default:
-			rpt.ReportTerse(os.Stdout)
+	// Filters run a subset of the tests of the config
+	if len(r.flagInclude) > 0 || len(r.flagExclude) > 0 {
+		if err := conf.FilterTests(r.flagInclude, r.flagExclude); err != nil {
+			return nil, fmt.Errorf("error filtering tests: %w", err)
+		}
+		names := make([]string, len(conf.Tests))
+		for i, vbTest := range conf.Tests {
+			names[i] = vbTest.Name
 		}
-		fmt.Println()
+		logger.Info("running filtered tests", "tests", strings.Join(names, ", "))
 	}
-	return 0
+	return conf, nil
 }
 
 func (r *RunCommand) applyConfigOverrides(f *FlagSets, config *vbConfig.VaultBenchmarkCoreConfig) {
@@ -514,6 +1386,34 @@ func (r *RunCommand) applyConfigOverrides(f *FlagSets, config *vbConfig.VaultBen
 	})
 	config.PPROFInterval = r.flagPPROFInterval.String()
 
+	r.setStringFlag(f, config.ProfileDir, &StringVar{
+		Name:    "profile_dir",
+		Target:  &r.flagProfileDir,
+		Default: "",
+	})
+	config.ProfileDir = r.flagProfileDir
+
+	r.setStringFlag(f, config.ProfileAt, &StringVar{
+		Name:    "profile_at",
+		Target:  &r.flagProfileAt,
+		Default: benchmarktests.DefaultProfileAt,
+	})
+	config.ProfileAt = r.flagProfileAt
+
+	r.setDurationFlag(f, config.ProfileLength, &DurationVar{
+		Name:    "profile_duration",
+		Target:  &r.flagProfileLength,
+		Default: benchmarktests.DefaultProfileDuration,
+	})
+	config.ProfileLength = r.flagProfileLength.String()
+
+	r.setStringFlag(f, config.ResourceURL, &StringVar{
+		Name:    "resource_metrics_url",
+		Target:  &r.flagResourceURL,
+		Default: "",
+	})
+	config.ResourceURL = r.flagResourceURL
+
 	r.setDurationFlag(f, config.Duration, &DurationVar{
 		Name:    "duration",
 		Target:  &r.flagDuration,
@@ -521,6 +1421,191 @@ func (r *RunCommand) applyConfigOverrides(f *FlagSets, config *vbConfig.VaultBen
 	})
 	config.Duration = r.flagDuration.String()
 
+	r.setDurationFlag(f, config.Warmup, &DurationVar{
+		Name:    "warmup",
+		Target:  &r.flagWarmup,
+		Default: 0,
+	})
+	config.Warmup = r.flagWarmup.String()
+
+	r.setDurationFlag(f, config.DNSRefresh, &DurationVar{
+		Name:    "dns_refresh_interval",
+		Target:  &r.flagDNSRefresh,
+		Default: 0,
+	})
+	config.DNSRefresh = r.flagDNSRefresh.String()
+
+	r.setDurationFlag(f, config.IdleTimeout, &DurationVar{
+		Name:    "idle_conn_timeout",
+		Target:  &r.flagIdleTimeout,
+		Default: 0,
+	})
+	config.IdleTimeout = r.flagIdleTimeout.String()
+
+	r.setDurationFlag(f, config.TLSHandshake, &DurationVar{
+		Name:    "tls_handshake_timeout",
+		Target:  &r.flagTLSHandshake,
+		Default: 0,
+	})
+	config.TLSHandshake = r.flagTLSHandshake.String()
+
+	r.setDurationFlag(f, config.ReportInterval, &DurationVar{
+		Name:    "report_interval",
+		Target:  &r.flagReportInterval,
+		Default: 0,
+	})
+	config.ReportInterval = r.flagReportInterval.String()
+
+	r.setDurationFlag(f, config.SeriesInterval, &DurationVar{
+		Name:    "timeseries_interval",
+		Target:  &r.flagSeriesInterval,
+		Default: benchmarktests.DefaultTimeseriesInterval,
+	})
+	config.SeriesInterval = r.flagSeriesInterval.String()
+
+	r.setStringFlag(f, config.IntervalFile, &StringVar{
+		Name:    "report_interval_file",
+		Target:  &r.flagIntervalFile,
+		Default: "",
+	})
+	config.IntervalFile = r.flagIntervalFile
+
+	r.setStringFlag(f, config.RemoteWriteURL, &StringVar{
+		Name:    "remote_write_url",
+		Target:  &r.flagRemoteWriteURL,
+		Default: "",
+	})
+	config.RemoteWriteURL = r.flagRemoteWriteURL
+
+	r.setStringFlag(f, config.RunID, &StringVar{
+		Name:    "run_id",
+		Target:  &r.flagRunID,
+		Default: "",
+	})
+	config.RunID = r.flagRunID
+
+	// Labels given as flags are added to those of the config, replacing
+	// any with the same name
+	if len(r.flagLabels) > 0 && config.Labels == nil {
+		config.Labels = make(map[string]string, len(r.flagLabels))
+	}
+	for name, value := range r.flagLabels {
+		config.Labels[name] = value
+	}
+
+	r.setStringFlag(f, config.InfluxFile, &StringVar{
+		Name:    "influx_file",
+		Target:  &r.flagInfluxFile,
+		Default: "",
+	})
+	config.InfluxFile = r.flagInfluxFile
+
+	r.setStringFlag(f, config.InfluxURL, &StringVar{
+		Name:    "influx_url",
+		Target:  &r.flagInfluxURL,
+		Default: "",
+	})
+	config.InfluxURL = r.flagInfluxURL
+
+	r.setStringFlag(f, config.InfluxToken, &StringVar{
+		Name:    "influx_token",
+		Target:  &r.flagInfluxToken,
+		Default: "",
+		EnvVar:  "INFLUX_TOKEN",
+	})
+	config.InfluxToken = r.flagInfluxToken
+
+	r.setStringFlag(f, config.OTLPEndpoint, &StringVar{
+		Name:    "otlp_metrics_endpoint",
+		Target:  &r.flagOTLPEndpoint,
+		Default: "",
+	})
+	config.OTLPEndpoint = r.flagOTLPEndpoint
+
+	r.setStringFlag(f, config.OTLPProtocol, &StringVar{
+		Name:    "otlp_metrics_protocol",
+		Target:  &r.flagOTLPProtocol,
+		Default: benchmarktests.OTLPProtocolGRPC,
+	})
+	config.OTLPProtocol = r.flagOTLPProtocol
+
+	r.setStringFlag(f, config.OTLPTraces, &StringVar{
+		Name:    "otlp_traces_endpoint",
+		Target:  &r.flagOTLPTraces,
+		Default: "",
+	})
+	config.OTLPTraces = r.flagOTLPTraces
+
+	r.setStringFlag(f, config.OTLPTraceProto, &StringVar{
+		Name:    "otlp_traces_protocol",
+		Target:  &r.flagOTLPTraceProto,
+		Default: benchmarktests.OTLPProtocolGRPC,
+	})
+	config.OTLPTraceProto = r.flagOTLPTraceProto
+
+	r.setFloat64Flag(f, config.TraceSampling, &Float64Var{
+		Name:    "trace_sample_ratio",
+		Target:  &r.flagTraceSampling,
+		Default: 0.01,
+	})
+	config.TraceSampling = r.flagTraceSampling
+
+	r.setStringFlag(f, config.ReplayFile, &StringVar{
+		Name:    "replay_file",
+		Target:  &r.flagReplayFile,
+		Default: "",
+	})
+	config.ReplayFile = r.flagReplayFile
+
+	r.setStringFlag(f, config.Baseline, &StringVar{
+		Name:    "baseline",
+		Target:  &r.flagBaseline,
+		Default: "",
+	})
+	config.Baseline = r.flagBaseline
+
+	r.setStringFlag(f, config.HistoryDB, &StringVar{
+		Name:    "history_db",
+		Target:  &r.flagHistoryDB,
+		Default: "",
+	})
+	config.HistoryDB = r.flagHistoryDB
+
+	r.setStringFlag(f, config.JUnitFile, &StringVar{
+		Name:    "junit_file",
+		Target:  &r.flagJUnitFile,
+		Default: "",
+	})
+	config.JUnitFile = r.flagJUnitFile
+
+	r.setStringFlag(f, config.AttackMode, &StringVar{
+		Name:    "attack_mode",
+		Target:  &r.flagAttackMode,
+		Default: "open",
+	})
+	config.AttackMode = r.flagAttackMode
+
+	r.setStringFlag(f, config.Arrival, &StringVar{
+		Name:    "arrival",
+		Target:  &r.flagArrival,
+		Default: "constant",
+	})
+	config.Arrival = r.flagArrival
+
+	r.setDurationFlag(f, config.ThinkTime, &DurationVar{
+		Name:    "think_time",
+		Target:  &r.flagThinkTime,
+		Default: 0,
+	})
+	config.ThinkTime = r.flagThinkTime.String()
+
+	r.setStringFlag(f, config.ThinkTimeDist, &StringVar{
+		Name:    "think_time_distribution",
+		Target:  &r.flagThinkTimeDist,
+		Default: "fixed",
+	})
+	config.ThinkTimeDist = r.flagThinkTimeDist
+
 	r.setIntFlag(f, config.RPS, &IntVar{
 		Name:    "rps",
 		Target:  &r.flagRPS,
@@ -528,6 +1613,45 @@ func (r *RunCommand) applyConfigOverrides(f *FlagSets, config *vbConfig.VaultBen
 	})
 	config.RPS = r.flagRPS
 
+	r.setIntFlag(f, config.Requests, &IntVar{
+		Name:    "requests",
+		Target:  &r.flagRequests,
+		Default: 0,
+	})
+	config.Requests = r.flagRequests
+
+	r.setIntFlag(f, config.MaxInFlight, &IntVar{
+		Name:    "max_in_flight",
+		Target:  &r.flagMaxInFlight,
+		Default: 0,
+	})
+	config.MaxInFlight = r.flagMaxInFlight
+
+	r.setIntFlag(f, config.TokenPool, &IntVar{
+		Name:    "token_pool",
+		Target:  &r.flagTokenPool,
+		Default: 0,
+	})
+	config.TokenPool = r.flagTokenPool
+
+	r.setIntFlag(f, config.Namespaces, &IntVar{
+		Name:    "namespace_fanout",
+		Target:  &r.flagNamespaces,
+		Default: 0,
+	})
+	config.Namespaces = r.flagNamespaces
+
+	r.setStringFlag(f, config.TokenType, &StringVar{
+		Name:    "attack_token_type",
+		Target:  &r.flagTokenType,
+		Default: "",
+	})
+	config.TokenType = r.flagTokenType
+
+	if len(r.flagTokenPolicies) > 0 {
+		config.TokenPolicies = r.flagTokenPolicies
+	}
+
 	r.setIntFlag(f, config.Workers, &IntVar{
 		Name:    "workers",
 		Target:  &r.flagWorkers,
@@ -551,6 +1675,59 @@ func (r *RunCommand) applyConfigOverrides(f *FlagSets, config *vbConfig.VaultBen
 	})
 	config.VaultAddr = r.flagVaultAddr
 
+	// vault_addrs takes precedence over vault_addr given the same way, but
+	// a vault_addr given as a flag or in $VAULT_ADDR takes precedence over
+	// the vault_addrs of the config
+	_, vaultAddrEnvSet := os.LookupEnv("VAULT_ADDR")
+	switch {
+	case len(r.flagVaultAddrs) > 0:
+		config.VaultAddrs = r.flagVaultAddrs
+	case vaultAddrEnvSet || isFlagSet(f, "vault_addr"):
+		config.VaultAddrs = nil
+	}
+
+	r.setStringFlag(f, config.LoadBalance, &StringVar{
+		Name:    "load_balance",
+		Target:  &r.flagLoadBalance,
+		Default: "",
+	})
+	config.LoadBalance = r.flagLoadBalance
+
+	r.setStringFlag(f, config.ReadAddr, &StringVar{
+		Name:    "read_addr",
+		Target:  &r.flagReadAddr,
+		Default: "",
+	})
+	config.ReadAddr = r.flagReadAddr
+
+	r.setStringFlag(f, config.NodeHeader, &StringVar{
+		Name:    "node_header",
+		Target:  &r.flagNodeHeader,
+		Default: "",
+	})
+	config.NodeHeader = r.flagNodeHeader
+
+	r.setStringFlag(f, config.ProxyAddr, &StringVar{
+		Name:    "proxy_addr",
+		Target:  &r.flagProxyAddr,
+		Default: "",
+	})
+	config.ProxyAddr = r.flagProxyAddr
+
+	r.setStringFlag(f, config.HTTPProxy, &StringVar{
+		Name:    "http_proxy",
+		Target:  &r.flagHTTPProxy,
+		Default: "",
+	})
+	config.HTTPProxy = r.flagHTTPProxy
+
+	r.setStringFlag(f, config.DevTarget, &StringVar{
+		Name:    "dev_target",
+		Target:  &r.flagDevTarget,
+		Default: "",
+	})
+	config.DevTarget = r.flagDevTarget
+
 	r.setStringFlag(f, config.VaultNamespace, &StringVar{
 		Name:    "vault_namespace",
 		EnvVar:  "VAULT_NAMESPACE",
@@ -566,6 +1743,41 @@ func (r *RunCommand) applyConfigOverrides(f *FlagSets, config *vbConfig.VaultBen
 	})
 	config.ReportMode = r.flagReportMode
 
+	r.setStringFlag(f, config.Percentiles, &StringVar{
+		Name:    "report_percentiles",
+		Target:  &r.flagPercentiles,
+		Default: "",
+	})
+	config.Percentiles = r.flagPercentiles
+
+	r.setStringFlag(f, config.LoadShare, &StringVar{
+		Name:    "load_share",
+		Target:  &r.flagLoadShare,
+		Default: "",
+	})
+	config.LoadShare = r.flagLoadShare
+
+	r.setStringFlag(f, config.StartAt, &StringVar{
+		Name:    "start_at",
+		Target:  &r.flagStartAt,
+		Default: "",
+	})
+	config.StartAt = r.flagStartAt
+
+	r.setStringFlag(f, config.Checkpoint, &StringVar{
+		Name:    "checkpoint_file",
+		Target:  &r.flagCheckpoint,
+		Default: "",
+	})
+	config.Checkpoint = r.flagCheckpoint
+
+	r.setDurationFlag(f, config.CheckpointIntv, &DurationVar{
+		Name:    "checkpoint_interval",
+		Target:  &r.flagCheckpointIntv,
+		Default: benchmarktests.DefaultCheckpointInterval,
+	})
+	config.CheckpointIntv = r.flagCheckpointIntv.String()
+
 	r.setStringFlag(f, config.Annotate, &StringVar{
 		Name:    "annotate",
 		Target:  &r.flagAnnotate,
@@ -595,6 +1807,13 @@ func (r *RunCommand) applyConfigOverrides(f *FlagSets, config *vbConfig.VaultBen
 	})
 	config.ClusterJSON = r.flagClusterJson
 
+	r.setStringFlag(f, config.ClusterMode, &StringVar{
+		Name:    "cluster_mode",
+		Target:  &r.flagClusterMode,
+		Default: vbConfig.SequentialClusterMode,
+	})
+	config.ClusterMode = r.flagClusterMode
+
 	r.setBoolFlag(f, config.Cleanup, &BoolVar{
 		Name:    "cleanup",
 		Target:  &r.flagCleanup,
@@ -623,6 +1842,88 @@ func (r *RunCommand) applyConfigOverrides(f *FlagSets, config *vbConfig.VaultBen
 		Default: false,
 	})
 	config.DisableHTTP2 = r.flagDisableHTTP2
+
+	r.setBoolFlag(f, config.ForceHTTP2, &BoolVar{
+		Name:    "force_http2",
+		Target:  &r.flagForceHTTP2,
+		Default: false,
+	})
+	config.ForceHTTP2 = r.flagForceHTTP2
+
+	r.setBoolFlag(f, config.COCorrection, &BoolVar{
+		Name:    "correct_coordinated_omission",
+		Target:  &r.flagCorrectOmission,
+		Default: false,
+	})
+	config.COCorrection = r.flagCorrectOmission
+
+	r.setBoolFlag(f, config.FollowRedirect, &BoolVar{
+		Name:    "follow_redirects",
+		Target:  &r.flagFollowRedirects,
+		Default: false,
+	})
+	config.FollowRedirect = r.flagFollowRedirects
+
+	r.setBoolFlag(f, config.Live, &BoolVar{
+		Name:    "live",
+		Target:  &r.flagLive,
+		Default: false,
+	})
+	config.Live = r.flagLive
+
+	r.setStringFlag(f, config.ResultLog, &StringVar{
+		Name:    "result_log",
+		Target:  &r.flagResultLog,
+		Default: "",
+	})
+	config.ResultLog = r.flagResultLog
+
+	r.setIntFlag(f, config.ResultLogMaxMB, &IntVar{
+		Name:    "result_log_max_size_mb",
+		Target:  &r.flagResultLogMaxMB,
+		Default: 0,
+	})
+	config.ResultLogMaxMB = r.flagResultLogMaxMB
+
+	r.setOptionalIntFlag(f, config.ResultLogFiles, &IntVar{
+		Name:    "result_log_max_files",
+		Target:  &r.flagResultLogFiles,
+		Default: 5,
+	})
+	config.ResultLogFiles = &r.flagResultLogFiles
+
+	r.setOptionalIntFlag(f, config.ErrorSamples, &IntVar{
+		Name:    "error_samples",
+		Target:  &r.flagErrorSamples,
+		Default: benchmarktests.DefaultErrorSamples,
+	})
+	config.ErrorSamples = &r.flagErrorSamples
+
+	r.setIntFlag(f, config.MaxIdlePerHost, &IntVar{
+		Name:    "max_idle_conns_per_host",
+		Target:  &r.flagMaxIdlePerHost,
+		Default: 0,
+	})
+	config.MaxIdlePerHost = r.flagMaxIdlePerHost
+
+	r.setIntFlag(f, config.WorkerConns, &IntVar{
+		Name:    "worker_connections",
+		Target:  &r.flagWorkerConns,
+		Default: 0,
+	})
+	config.WorkerConns = r.flagWorkerConns
+}
+
+// isFlagSet returns whether the flag of the name was given on the command
+// line
+func isFlagSet(f *FlagSets, name string) bool {
+	var isFlagSet bool
+	f.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			isFlagSet = true
+		}
+	})
+	return isFlagSet
 }
 
 func (r *RunCommand) setBoolFlag(f *FlagSets, configVal bool, fVar *BoolVar) {
@@ -700,6 +2001,62 @@ func (r *RunCommand) setIntFlag(f *FlagSets, configVal int, fVar *IntVar) {
 	}
 }
 
+// setOptionalIntFlag is setIntFlag for options whose config value may be
+// set to 0 in place of a default which isn't, so is only unset when nil
+func (r *RunCommand) setOptionalIntFlag(f *FlagSets, configVal *int, fVar *IntVar) {
+	var isFlagSet bool
+	f.Visit(func(f *flag.Flag) {
+		if f.Name == fVar.Name {
+			isFlagSet = true
+		}
+	})
+
+	flagEnvValue, flagEnvSet := os.LookupEnv(fVar.EnvVar)
+	switch {
+	case isFlagSet:
+		// Don't do anything as the flag is already set from the command line
+	case flagEnvSet:
+		// Use value from env var
+		tVal, err := strconv.Atoi(flagEnvValue)
+		if err != nil {
+			return
+		}
+		*fVar.Target = tVal
+	case configVal != nil:
+		*fVar.Target = *configVal
+	default:
+		// Use the default value
+		*fVar.Target = fVar.Default
+	}
+}
+
+func (r *RunCommand) setFloat64Flag(f *FlagSets, configVal float64, fVar *Float64Var) {
+	var isFlagSet bool
+	f.Visit(func(f *flag.Flag) {
+		if f.Name == fVar.Name {
+			isFlagSet = true
+		}
+	})
+
+	flagEnvValue, flagEnvSet := os.LookupEnv(fVar.EnvVar)
+	switch {
+	case isFlagSet:
+		// Don't do anything as the flag is already set from the command line
+	case flagEnvSet:
+		// Use value from env var
+		tVal, err := strconv.ParseFloat(flagEnvValue, 64)
+		if err != nil {
+			return
+		}
+		*fVar.Target = tVal
+	case configVal != 0:
+		*fVar.Target = configVal
+	default:
+		// Use the default value
+		*fVar.Target = fVar.Default
+	}
+}
+
 func (r *RunCommand) setDurationFlag(f *FlagSets, configVal string, fVar *DurationVar) {
 	var isFlagSet bool
 	f.Visit(func(f *flag.Flag) {
@@ -0,0 +1,343 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// runLength returns how long the run lasts, starting from its configured
+// duration, along with the events of its replay file when it has one
+func runLength(conf *vbConfig.VaultBenchmarkCoreConfig, duration time.Duration) (time.Duration, []benchmarktests.ReplayEvent, error) {
+	// When phases are configured the run lasts for the sum of their
+	// durations rather than the top-level duration
+	if len(conf.Phases) > 0 {
+		duration = 0
+		for _, phase := range conf.Phases {
+			// Phase durations are validated when the config is loaded
+			phaseDuration, _ := time.ParseDuration(phase.Duration)
+			duration += phaseDuration
+		}
+	}
+
+	// Tests with their own duration may run for longer than the global
+	// duration, also counting the time before they start
+	for _, vbTest := range conf.Tests {
+		// Test durations and offsets are validated when the config is loaded
+		testDuration, _ := vbTest.AttackDuration()
+		if testDuration == 0 {
+			continue
+		}
+		startOffset, _ := vbTest.StartOffset()
+		if startOffset+testDuration > duration {
+			duration = startOffset + testDuration
+		}
+	}
+
+	if conf.ReplayFile == "" {
+		return duration, nil, nil
+	}
+	switch {
+	case conf.AttackMode != benchmarktests.OpenLoopAttackMode:
+		return 0, nil, fmt.Errorf("replay_file requires the open attack mode")
+	case len(conf.Phases) > 0 || conf.Search != nil || conf.Burst != nil || conf.Requests > 0:
+		return 0, nil, fmt.Errorf("replay_file cannot be combined with phases, throughput_search, burst or requests")
+	}
+
+	replay, err := benchmarktests.LoadReplayFile(conf.ReplayFile)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error loading replay file: %v", err)
+	}
+
+	testNames := make(map[string]struct{}, len(conf.Tests))
+	for _, vbTest := range conf.Tests {
+		testNames[vbTest.Name] = struct{}{}
+	}
+	for _, event := range replay {
+		if _, ok := testNames[event.Test]; !ok {
+			return 0, nil, fmt.Errorf("replay file references unknown test %v", event.Test)
+		}
+	}
+
+	// The replay lasts until its last event
+	return replay[len(replay)-1].Offset, replay, nil
+}
+
+// transportConfig returns the settings of the HTTP transport the requests
+// of the run are sent with
+func transportConfig(conf *vbConfig.VaultBenchmarkCoreConfig, dnsRefresh time.Duration) (*benchmarktests.TransportConfig, error) {
+	var err error
+	transport := &benchmarktests.TransportConfig{MaxIdleConnsPerHost: conf.MaxIdlePerHost, Proxy: conf.HTTPProxy, WorkerConns: conf.WorkerConns}
+	if conf.IdleTimeout != "" {
+		transport.IdleConnTimeout, err = time.ParseDuration(conf.IdleTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing idle connection timeout: %w", err)
+		}
+	}
+	if conf.TLSHandshake != "" {
+		transport.TLSHandshakeTimeout, err = time.ParseDuration(conf.TLSHandshake)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing tls handshake timeout: %w", err)
+		}
+	}
+	switch {
+	case conf.DisableHTTP2 && conf.ForceHTTP2:
+		return nil, fmt.Errorf("disable_http2 cannot be combined with force_http2")
+	case conf.ForceHTTP2 && dnsRefresh > 0:
+		return nil, fmt.Errorf("force_http2 cannot be combined with dns_refresh_interval")
+	case conf.DisableHTTP2:
+		transport.HTTP2 = benchmarktests.HTTP2Disabled
+	case conf.ForceHTTP2:
+		transport.HTTP2 = benchmarktests.HTTP2Forced
+	}
+	if err := transport.Validate(); err != nil {
+		return nil, err
+	}
+	// Open loop workers aren't told apart, so can't be given connections
+	// of their own
+	if transport.WorkerConns > 0 && conf.AttackMode != benchmarktests.ClosedLoopAttackMode {
+		return nil, fmt.Errorf("worker_connections requires the closed attack mode")
+	}
+	// Requests to sockets are addressed to localhost, which would be sent
+	// to the proxy
+	if conf.HTTPProxy != "" && conf.HTTPProxy != benchmarktests.NoProxy &&
+		slices.ContainsFunc(append([]string{conf.VaultAddr, conf.ProxyAddr}, conf.VaultAddrs...), func(addr string) bool {
+			return strings.HasPrefix(addr, benchmarktests.UnixSocketPrefix)
+		}) {
+		return nil, fmt.Errorf("http_proxy cannot be combined with unix socket addresses")
+	}
+	return transport, nil
+}
+
+// checkAttackMode checks the attack mode of the run along with the options
+// it uses, warning of those it ignores
+func checkAttackMode(conf *vbConfig.VaultBenchmarkCoreConfig, thinkTime time.Duration, logger hclog.Logger) error {
+	switch conf.ThinkTimeDist {
+	case benchmarktests.FixedThinkTime, benchmarktests.UniformThinkTime, benchmarktests.ExponentialThinkTime:
+	default:
+		return fmt.Errorf("think_time_distribution must be one of fixed, uniform or exponential")
+	}
+
+	switch conf.AttackMode {
+	case benchmarktests.OpenLoopAttackMode:
+		if thinkTime != 0 {
+			logger.Warn("think_time is only used with the closed attack mode")
+		}
+	case benchmarktests.ClosedLoopAttackMode:
+		if conf.COCorrection {
+			return fmt.Errorf("correct_coordinated_omission requires the open attack mode")
+		}
+		if conf.MaxInFlight != 0 {
+			logger.Warn("max_in_flight is ignored with the closed attack mode, workers already bounds it")
+		}
+		if conf.RPS != 0 {
+			logger.Warn("rps is ignored with the closed attack mode")
+		}
+		if conf.Arrival != benchmarktests.ConstantArrival {
+			logger.Warn("arrival is ignored with the closed attack mode")
+		}
+	default:
+		return fmt.Errorf("attack_mode must be one of open or closed")
+	}
+
+	switch conf.Arrival {
+	case benchmarktests.ConstantArrival:
+	case benchmarktests.PoissonArrival:
+		if conf.RPS == 0 {
+			return fmt.Errorf("poisson arrival requires rps to be set")
+		}
+	default:
+		return fmt.Errorf("arrival must be one of constant or poisson")
+	}
+	return nil
+}
+
+// checkRunModes checks the flags choosing what the run does other than
+// attacking the tests can be combined with each other and with the config
+func (r *RunCommand) checkRunModes(conf *vbConfig.VaultBenchmarkCoreConfig) error {
+	// Setup only runs leave the tests set up for attack only runs, which
+	// replay the setup of each test in order
+	if r.flagSetupOnly || r.flagAttackOnly {
+		switch {
+		case r.flagSetupOnly && r.flagAttackOnly:
+			return fmt.Errorf("setup_only and attack_only cannot be combined")
+		case r.flagDryRun:
+			return fmt.Errorf("dry_run cannot be combined with setup_only or attack_only")
+		case r.flagStateFile == "":
+			return fmt.Errorf("setup_only and attack_only require state_file to be set")
+		case conf.Namespaces > 0 || conf.Checkpoint != "":
+			return fmt.Errorf("setup_only and attack_only cannot be combined with namespace_fanout or checkpoint_file")
+		case r.flagSetupOnly && conf.Cleanup:
+			return fmt.Errorf("cleanup cannot be combined with setup_only, whose mounts are left for attack_only runs")
+		}
+	}
+
+	// Exporting sets the tests up like any run, but leaves them set up for
+	// the targets to be sent by another tool
+	if r.flagExportFile != "" {
+		switch {
+		case r.flagDryRun || r.flagSetupOnly:
+			return fmt.Errorf("export_file cannot be combined with dry_run or setup_only")
+		case conf.Cleanup:
+			return fmt.Errorf("cleanup cannot be combined with export_file, whose targets need the mounts left")
+		case conf.ReplayFile != "" || conf.Checkpoint != "":
+			return fmt.Errorf("export_file cannot be combined with replay_file or checkpoint_file")
+		case r.flagExportFormat != benchmarktests.ExportFormatVegeta && r.flagExportFormat != benchmarktests.ExportFormatK6:
+			return fmt.Errorf("export_format must be vegeta or k6")
+		case r.flagExportRequests <= 0:
+			return fmt.Errorf("export_requests must be positive")
+		}
+	}
+	return nil
+}
+
+// applyLoadShare adjusts the config of a share of a distributed run to send
+// its part of the configured load, returning when the shares all start
+func applyLoadShare(conf *vbConfig.VaultBenchmarkCoreConfig) (time.Time, error) {
+	if conf.LoadShare != "" {
+		part, parts, err := vbConfig.ParseLoadShare(conf.LoadShare)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error parsing load_share: %w", err)
+		}
+		if conf.Search != nil {
+			return time.Time{}, fmt.Errorf("load_share cannot be combined with throughput_search")
+		}
+		if err := conf.ApplyLoadShare(part, parts); err != nil {
+			return time.Time{}, fmt.Errorf("error applying load_share: %w", err)
+		}
+	}
+
+	var startAt time.Time
+	if conf.StartAt != "" {
+		var err error
+		startAt, err = time.Parse(time.RFC3339Nano, conf.StartAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error parsing start_at: %w", err)
+		}
+	}
+	return startAt, nil
+}
+
+// waitToStart waits until startAt, so the shares of a distributed run start
+// together, unless it is zero or has already passed
+func waitToStart(startAt time.Time, logger hclog.Logger) {
+	if startAt.IsZero() {
+		return
+	}
+	wait := time.Until(startAt)
+	if wait < 0 {
+		logger.Warn("start_at has already passed, starting late", "late", wait.Abs().String())
+		return
+	}
+	logger.Info("waiting to start", "start_at", startAt.Format(time.RFC3339Nano))
+	time.Sleep(wait)
+}
+
+// checkLimits checks the limits the run is held to, along with the mounts
+// its cleanup removes
+func checkLimits(conf *vbConfig.VaultBenchmarkCoreConfig) error {
+	// Fixed mounts may have been there before the run, so are only cleaned
+	// up when random
+	if conf.Cleanup {
+		for _, vbTest := range conf.Tests {
+			if !vbTest.UsesRandomMount(conf.RandomMounts) && !vbTest.SkipCleanup && vbTest.SharedMount == "" {
+				return fmt.Errorf("cleanup can only be enabled when random mounts is enabled, unless skip_cleanup is set on test %v", vbTest.Name)
+			}
+		}
+	}
+
+	switch {
+	case conf.Requests < 0:
+		return fmt.Errorf("requests must not be negative")
+	case conf.MaxInFlight < 0:
+		return fmt.Errorf("max_in_flight must not be negative")
+	case conf.Namespaces < 0:
+		return fmt.Errorf("namespace_fanout must not be negative")
+	case conf.Requests > 0 && (len(conf.Phases) > 0 || conf.Search != nil || conf.Burst != nil):
+		return fmt.Errorf("requests cannot be combined with phases, throughput_search or burst")
+	}
+	return nil
+}
+
+// openResultLog opens the result_log every result of the run is written to,
+// rotated at result_log_max_size_mb, or returns nil when the config has none
+func openResultLog(conf *vbConfig.VaultBenchmarkCoreConfig) (*benchmarktests.ResultLog, error) {
+	if conf.ResultLog == "" {
+		return nil, nil
+	}
+	if conf.ResultLogMaxMB < 0 || *conf.ResultLogFiles < 0 {
+		return nil, fmt.Errorf("result_log_max_size_mb and result_log_max_files must not be negative")
+	}
+	return benchmarktests.NewResultLog(conf.ResultLog, int64(conf.ResultLogMaxMB)<<20, *conf.ResultLogFiles)
+}
+
+// runIntervals are the intervals set in the config of a run, zero when unset
+type runIntervals struct {
+	pprof      time.Duration
+	thinkTime  time.Duration
+	warmup     time.Duration
+	dnsRefresh time.Duration
+	report     time.Duration
+	series     time.Duration
+}
+
+// parseIntervals parses the intervals set in the config of the run
+func parseIntervals(conf *vbConfig.VaultBenchmarkCoreConfig) (runIntervals, error) {
+	var intervals runIntervals
+	for _, interval := range []struct {
+		option string
+		value  string
+		parsed *time.Duration
+	}{
+		{"pprof_interval", conf.PPROFInterval, &intervals.pprof},
+		{"think_time", conf.ThinkTime, &intervals.thinkTime},
+		{"warmup", conf.Warmup, &intervals.warmup},
+		{"dns_refresh_interval", conf.DNSRefresh, &intervals.dnsRefresh},
+		{"report_interval", conf.ReportInterval, &intervals.report},
+		{"timeseries_interval", conf.SeriesInterval, &intervals.series},
+	} {
+		if interval.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(interval.value)
+		if err != nil {
+			return runIntervals{}, fmt.Errorf("error parsing %v: %w", interval.option, err)
+		}
+		*interval.parsed = parsed
+	}
+
+	if intervals.dnsRefresh > 0 && conf.ProxyAddr != "" {
+		return runIntervals{}, fmt.Errorf("dns_refresh_interval cannot be combined with proxy_addr")
+	}
+	return intervals, nil
+}
+
+// tokenPoolConfig returns the config of the pool of tokens the requests of
+// the run are sent with, warning of the options it ignores
+func tokenPoolConfig(conf *vbConfig.VaultBenchmarkCoreConfig, logger hclog.Logger) (*benchmarktests.TokenPoolConfig, error) {
+	poolConfig := &benchmarktests.TokenPoolConfig{
+		Size:     conf.TokenPool,
+		Type:     conf.TokenType,
+		Policies: conf.TokenPolicies,
+	}
+	// Batch tokens are always sent from a pool, of a single token unless
+	// token_pool says otherwise
+	if poolConfig.Type == benchmarktests.BatchTokenType && poolConfig.Size == 0 {
+		poolConfig.Size = 1
+	}
+	if err := poolConfig.Validate(); err != nil {
+		return nil, err
+	}
+	if poolConfig.Size == 0 && len(conf.TokenPolicies) > 0 {
+		logger.Warn("attack_token_policies is only used with token_pool or attack_token_type")
+	}
+	return poolConfig, nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*SchemaCommand)(nil)
+	_ cli.CommandAutocomplete = (*SchemaCommand)(nil)
+)
+
+// SchemaCommand prints the JSON Schema of the results written by the json
+// report mode
+type SchemaCommand struct {
+	*BaseCommand
+}
+
+func (s *SchemaCommand) Synopsis() string {
+	return "Prints the JSON Schema of JSON results"
+}
+
+func (s *SchemaCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark schema
+
+  Prints the JSON Schema of the reports written by the json report mode, for
+  tooling reading results files. Results files hold one report per line, for
+  each target and phase of the run. Each report records the version of its
+  layout in schema_version.
+
+      $ vault-benchmark schema > result-schema.json
+
+  There are no arguments or flags to this command. Any additional arguments or
+  flags are ignored.
+`
+	return strings.TrimSpace(helpText)
+}
+
+func (s *SchemaCommand) Flags() *FlagSets {
+	return nil
+}
+
+func (s *SchemaCommand) AutocompleteArgs() complete.Predictor {
+	return nil
+}
+
+func (s *SchemaCommand) AutocompleteFlags() complete.Flags {
+	return nil
+}
+
+func (s *SchemaCommand) Run(_ []string) int {
+	if err := benchmarktests.WriteResultSchema(os.Stdout); err != nil {
+		s.UI.Error(fmt.Sprintf("error writing schema: %v", err))
+		return 1
+	}
+	return 0
+}
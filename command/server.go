@@ -0,0 +1,980 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
+	"github.com/mitchellh/cli"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*ServerCommand)(nil)
+	_ cli.CommandAutocomplete = (*ServerCommand)(nil)
+)
+
+const (
+	// serverResultsFile, serverIntervalsFile and serverLogFile are the
+	// files in the directory of every run holding its JSON results, its
+	// interval reports and its logs
+	serverResultsFile   = "results.json"
+	serverIntervalsFile = "intervals.json"
+	serverLogFile       = "run.log"
+
+	// serverMetadataFile is the file in the directory of every
+	// configuration and run describing it, from which they are served again
+	// once the server is restarted
+	serverMetadataFile = "metadata.json"
+
+	// serverMaxConfigSize caps the size of submitted configurations
+	serverMaxConfigSize = 10 << 20
+
+	// serverPollInterval is how often streamed metrics are checked for new
+	// interval reports
+	serverPollInterval = 500 * time.Millisecond
+)
+
+// ServerCommand serves an HTTP API to submit configurations to and start,
+// stop and fetch the results of runs of them
+type ServerCommand struct {
+	*BaseCommand
+	flagListen         string
+	flagDataDir        string
+	flagToken          string
+	flagTLSCertFile    string
+	flagTLSKeyFile     string
+	flagClientCAFile   string
+	flagReportInterval time.Duration
+}
+
+func (s *ServerCommand) Synopsis() string {
+	return "Serve an HTTP API to drive benchmarks"
+}
+
+func (s *ServerCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark server [options]
+
+ This command serves an HTTP API to submit benchmark configurations to, start
+ and stop runs of them, stream their interval metrics while they run and
+ fetch their result files, so benchmarks can be driven by dashboards or
+ automation.
+
+	$ vault-benchmark server -listen=0.0.0.0:8220 -token=$TOKEN \
+	    -tls_cert_file=server.pem -tls_key_file=server-key.pem
+
+ For a full list of examples, please see the documentation.
+
+` + s.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (s *ServerCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (s *ServerCommand) AutocompleteFlags() complete.Flags {
+	return s.Flags().Completions()
+}
+
+func (s *ServerCommand) Flags() *FlagSets {
+	set := s.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:    "listen",
+		Target:  &s.flagListen,
+		Default: "127.0.0.1:8220",
+		Usage:   "Address to serve the API on. Addresses other than loopback addresses require tls_cert_file, and token or tls_client_ca_file.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "data_dir",
+		Target:     &s.flagDataDir,
+		Default:    "vault-benchmark-data",
+		Completion: complete.PredictDirs("*"),
+		Usage:      "Directory to keep submitted configurations and the files of runs in.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "token",
+		Target:  &s.flagToken,
+		Default: "",
+		EnvVar:  "VAULT_BENCHMARK_SERVER_TOKEN",
+		Usage:   "Token requests to the API must send as a bearer token. Defaults to allowing every request.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "tls_cert_file",
+		Target:     &s.flagTLSCertFile,
+		Default:    "",
+		Completion: complete.PredictFiles("*.pem"),
+		Usage:      "Path to a PEM encoded certificate to serve TLS with. Required when listening on an address other than a loopback address.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "tls_key_file",
+		Target:     &s.flagTLSKeyFile,
+		Default:    "",
+		Completion: complete.PredictFiles("*.pem"),
+		Usage:      "Path to the PEM encoded private key of tls_cert_file.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "tls_client_ca_file",
+		Target:     &s.flagClientCAFile,
+		Default:    "",
+		Completion: complete.PredictFiles("*.pem"),
+		Usage:      "Path to a PEM encoded CA certificate the client certificates of requests must be signed by. Requires tls_cert_file.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:    "report_interval",
+		Target:  &s.flagReportInterval,
+		Default: 10 * time.Second,
+		Usage:   "Interval at which runs write the interval reports streamed as their metrics, unless a run sets its own.",
+	})
+	return set
+}
+
+func (s *ServerCommand) Run(args []string) int {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:  "vault-benchmark-server",
+		Level: hclog.Info,
+	})
+
+	f := s.Flags()
+	if err := f.Parse(args); err != nil {
+		s.UI.Error(err.Error())
+		return 1
+	}
+
+	if (s.flagTLSCertFile == "") != (s.flagTLSKeyFile == "") {
+		s.UI.Error("tls_cert_file and tls_key_file must be set together")
+		return 1
+	}
+	if s.flagReportInterval <= 0 {
+		s.UI.Error("report_interval must be a positive duration")
+		return 1
+	}
+	if s.flagClientCAFile != "" && s.flagTLSCertFile == "" {
+		s.UI.Error("tls_client_ca_file requires tls_cert_file and tls_key_file")
+		return 1
+	}
+	// Configurations can run commands on the server, such as hooks and
+	// credential helpers, so only trusted clients may submit them
+	if s.flagToken == "" && s.flagClientCAFile == "" && !isLoopbackAddr(s.flagListen) {
+		s.UI.Error("listening on an address other than a loopback address requires token or tls_client_ca_file")
+		return 1
+	}
+	// Configurations and the token are sent with every request, so they
+	// must not cross the network in the clear
+	if s.flagTLSCertFile == "" && !isLoopbackAddr(s.flagListen) {
+		s.UI.Error("listening on an address other than a loopback address requires tls_cert_file and tls_key_file")
+		return 1
+	}
+	if s.flagToken == "" {
+		logger.Warn("serving without a token; anyone who can reach the server can run benchmarks")
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		s.UI.Error(fmt.Sprintf("error locating executable: %v", err))
+		return 1
+	}
+
+	api, err := newBenchmarkServer(s.flagDataDir, executable, s.flagReportInterval, logger)
+	if err != nil {
+		s.UI.Error(err.Error())
+		return 1
+	}
+
+	srv := &http.Server{
+		Addr:              s.flagListen,
+		Handler:           api.handler(s.flagToken),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if s.flagTLSCertFile != "" {
+		srv.TLSConfig, err = workerTLSConfig(s.flagTLSCertFile, s.flagTLSKeyFile, s.flagClientCAFile)
+		if err != nil {
+			s.UI.Error(err.Error())
+			return 1
+		}
+	}
+
+	// The running benchmark is stopped along with the server, so it doesn't
+	// outlive it with its results unreachable
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupted
+		logger.Info("shutting down")
+		api.stopAll()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	logger.Info("serving API", "address", s.flagListen, "data_dir", s.flagDataDir)
+	if s.flagTLSCertFile != "" {
+		err = srv.ListenAndServeTLS("", "")
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.UI.Error(fmt.Sprintf("error serving: %v", err))
+		return 1
+	}
+	return 0
+}
+
+// serverConfig is a configuration submitted to the server
+type serverConfig struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+// serverRun is a run of a submitted configuration
+type serverRun struct {
+	ID       string     `json:"id"`
+	ConfigID string     `json:"config_id"`
+	Args     []string   `json:"args,omitempty"`
+	Status   string     `json:"status"`
+	Started  time.Time  `json:"started"`
+	Ended    *time.Time `json:"ended,omitempty"`
+	ExitCode *int       `json:"exit_code,omitempty"`
+	Error    string     `json:"error,omitempty"`
+
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+// Statuses of runs
+const (
+	serverRunRunning   = "running"
+	serverRunStopping  = "stopping"
+	serverRunSucceeded = "succeeded"
+	serverRunFailed    = "failed"
+	serverRunStopped   = "stopped"
+)
+
+// serverStartRequest is the body of requests starting a run
+type serverStartRequest struct {
+	ConfigID string   `json:"config_id"`
+	Args     []string `json:"args"`
+}
+
+// benchmarkServer runs each benchmark as a run command of its own, like
+// workers, in a directory of its own holding its configuration and result
+// files. Only one benchmark is run at a time, so runs don't skew the
+// results of each other.
+type benchmarkServer struct {
+	dataDir        string
+	executable     string
+	reportInterval time.Duration
+	logger         hclog.Logger
+
+	l       sync.Mutex
+	configs map[string]*serverConfig
+	runs    map[string]*serverRun
+	active  *serverRun
+}
+
+func newBenchmarkServer(dataDir, executable string, reportInterval time.Duration, logger hclog.Logger) (*benchmarkServer, error) {
+	for _, dir := range []string{"configs", "runs"} {
+		if err := os.MkdirAll(filepath.Join(dataDir, dir), 0o700); err != nil {
+			return nil, fmt.Errorf("error creating data directory: %v", err)
+		}
+	}
+	absDir, err := filepath.Abs(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving data directory: %v", err)
+	}
+	b := &benchmarkServer{
+		dataDir:        absDir,
+		executable:     executable,
+		reportInterval: reportInterval,
+		logger:         logger,
+		configs:        make(map[string]*serverConfig),
+		runs:           make(map[string]*serverRun),
+	}
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// load serves the configurations and runs kept in the data directory by
+// an earlier server again. Runs which were in progress when it stopped
+// without ending them are marked as failed.
+func (b *benchmarkServer) load() error {
+	configs, err := os.ReadDir(filepath.Join(b.dataDir, "configs"))
+	if err != nil {
+		return fmt.Errorf("error reading data directory: %v", err)
+	}
+	for _, entry := range configs {
+		var cfg serverConfig
+		if err := readServerMetadata(filepath.Join(b.dataDir, "configs", entry.Name()), &cfg); err != nil {
+			b.logger.Warn("skipping config", "id", entry.Name(), "error", hclog.Fmt("%v", err))
+			continue
+		}
+		cfg.ID = entry.Name()
+		b.configs[cfg.ID] = &cfg
+	}
+
+	runs, err := os.ReadDir(filepath.Join(b.dataDir, "runs"))
+	if err != nil {
+		return fmt.Errorf("error reading data directory: %v", err)
+	}
+	for _, entry := range runs {
+		dir := filepath.Join(b.dataDir, "runs", entry.Name())
+		run := &serverRun{done: make(chan struct{})}
+		if err := readServerMetadata(dir, run); err != nil {
+			b.logger.Warn("skipping run", "id", entry.Name(), "error", hclog.Fmt("%v", err))
+			continue
+		}
+		run.ID = entry.Name()
+		close(run.done)
+		if run.Ended == nil {
+			run.Status = serverRunFailed
+			run.Error = "the server stopped during the run"
+			if err := writeServerMetadata(dir, run); err != nil {
+				b.logger.Warn("error storing run", "id", run.ID, "error", hclog.Fmt("%v", err))
+			}
+		}
+		b.runs[run.ID] = run
+	}
+	if len(configs) > 0 || len(runs) > 0 {
+		b.logger.Info("loaded data directory", "configs", len(b.configs), "runs", len(b.runs))
+	}
+	return nil
+}
+
+// readServerMetadata decodes the metadata file in dir into v
+func readServerMetadata(dir string, v interface{}) error {
+	contents, err := os.ReadFile(filepath.Join(dir, serverMetadataFile))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(contents, v)
+}
+
+// writeServerMetadata stores v as the metadata file in dir, replacing it
+// at once so it is never left partially written
+func writeServerMetadata(dir string, v interface{}) error {
+	contents, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, serverMetadataFile+".tmp")
+	if err := os.WriteFile(tmp, contents, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dir, serverMetadataFile))
+}
+
+func (b *benchmarkServer) handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/configs", b.handleCreateConfig)
+	mux.HandleFunc("GET /v1/configs", b.handleListConfigs)
+	mux.HandleFunc("GET /v1/configs/{id}", b.handleGetConfig)
+	mux.HandleFunc("DELETE /v1/configs/{id}", b.handleDeleteConfig)
+	mux.HandleFunc("POST /v1/runs", b.handleStartRun)
+	mux.HandleFunc("GET /v1/runs", b.handleListRuns)
+	mux.HandleFunc("GET /v1/runs/{id}", b.handleGetRun)
+	mux.HandleFunc("POST /v1/runs/{id}/stop", b.handleStopRun)
+	mux.HandleFunc("GET /v1/runs/{id}/metrics", b.handleStreamMetrics)
+	mux.HandleFunc("GET /v1/runs/{id}/files", b.handleListFiles)
+	mux.HandleFunc("GET /v1/runs/{id}/files/{name}", b.handleGetFile)
+	if token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			writeServerError(w, http.StatusUnauthorized, "missing or invalid token")
+			return
+		}
+		mux.ServeHTTP(w, req)
+	})
+}
+
+// handleCreateConfig stores the configuration in the body under the file
+// name given by the name query parameter, once it is checked. Runs only
+// get a copy of the configuration itself, so configurations reading other
+// files, such as included files or data files, are rejected rather than
+// failing once a run starts.
+func (b *benchmarkServer) handleCreateConfig(w http.ResponseWriter, req *http.Request) {
+	name := filepath.Base(req.URL.Query().Get("name"))
+	if name == "." || name == string(filepath.Separator) {
+		name = "config.hcl"
+	}
+	// The config is copied into the directory of each run, next to the
+	// files the server writes there
+	switch name {
+	case serverResultsFile, serverIntervalsFile, serverLogFile, serverMetadataFile:
+		writeServerError(w, http.StatusBadRequest, fmt.Sprintf("config name %q is reserved for the files of runs", name))
+		return
+	}
+	contents, err := io.ReadAll(io.LimitReader(req.Body, serverMaxConfigSize+1))
+	if err != nil {
+		writeServerError(w, http.StatusBadRequest, fmt.Sprintf("error reading config: %v", err))
+		return
+	}
+	if len(contents) > serverMaxConfigSize {
+		writeServerError(w, http.StatusRequestEntityTooLarge, "config is too large")
+		return
+	}
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		writeServerError(w, http.StatusInternalServerError, fmt.Sprintf("error generating config ID: %v", err))
+		return
+	}
+	dir := filepath.Join(b.dataDir, "configs", id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		writeServerError(w, http.StatusInternalServerError, fmt.Sprintf("error storing config: %v", err))
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), contents, 0o600); err != nil {
+		writeServerError(w, http.StatusInternalServerError, fmt.Sprintf("error storing config: %v", err))
+		return
+	}
+	if err := checkServerConfig(filepath.Join(dir, name)); err != nil {
+		os.RemoveAll(dir)
+		writeServerError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	cfg := &serverConfig{ID: id, Name: name, Created: time.Now()}
+	if err := writeServerMetadata(dir, cfg); err != nil {
+		os.RemoveAll(dir)
+		writeServerError(w, http.StatusInternalServerError, fmt.Sprintf("error storing config: %v", err))
+		return
+	}
+	b.l.Lock()
+	b.configs[id] = cfg
+	b.l.Unlock()
+	b.logger.Info("config submitted", "id", id, "name", name)
+	writeServerJSON(w, http.StatusCreated, cfg)
+}
+
+// checkServerConfig loads the configuration, without contacting OpenBao,
+// and checks it reads no other files and leaves the results written to
+// stdout intact
+func checkServerConfig(path string) error {
+	conf := vbConfig.NewVaultBenchmarkCoreConfig()
+	conf.CheckOnly = true
+	if err := conf.LoadConfig(path); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+	if conf.ResultLog == "-" {
+		return fmt.Errorf("result_log can't be written to stdout, which holds the results of runs")
+	}
+	if files := conf.ReferencedFiles(); len(files) > 0 {
+		return fmt.Errorf("config reads other files, which the server doesn't store: %v", strings.Join(files, ", "))
+	}
+	return nil
+}
+
+func (b *benchmarkServer) handleListConfigs(w http.ResponseWriter, req *http.Request) {
+	b.l.Lock()
+	configs := make([]*serverConfig, 0, len(b.configs))
+	for _, cfg := range b.configs {
+		configs = append(configs, cfg)
+	}
+	b.l.Unlock()
+	sort.Slice(configs, func(i, j int) bool { return configs[i].Created.Before(configs[j].Created) })
+	writeServerJSON(w, http.StatusOK, map[string]interface{}{"configs": configs})
+}
+
+// handleGetConfig returns the contents of the configuration
+func (b *benchmarkServer) handleGetConfig(w http.ResponseWriter, req *http.Request) {
+	cfg := b.config(req.PathValue("id"))
+	if cfg == nil {
+		writeServerError(w, http.StatusNotFound, "config not found")
+		return
+	}
+	http.ServeFile(w, req, filepath.Join(b.dataDir, "configs", cfg.ID, cfg.Name))
+}
+
+// handleDeleteConfig removes the configuration. Runs keep a copy of theirs,
+// so remain intact.
+func (b *benchmarkServer) handleDeleteConfig(w http.ResponseWriter, req *http.Request) {
+	id := req.PathValue("id")
+	b.l.Lock()
+	_, ok := b.configs[id]
+	delete(b.configs, id)
+	b.l.Unlock()
+	if !ok {
+		writeServerError(w, http.StatusNotFound, "config not found")
+		return
+	}
+	if err := os.RemoveAll(filepath.Join(b.dataDir, "configs", id)); err != nil {
+		writeServerError(w, http.StatusInternalServerError, fmt.Sprintf("error removing config: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStartRun starts a run of a submitted configuration with the extra
+// arguments of the run command, unless a run is already in progress
+func (b *benchmarkServer) handleStartRun(w http.ResponseWriter, req *http.Request) {
+	var start serverStartRequest
+	if err := json.NewDecoder(req.Body).Decode(&start); err != nil {
+		writeServerError(w, http.StatusBadRequest, fmt.Sprintf("error decoding request: %v", err))
+		return
+	}
+	runFlags := (&RunCommand{BaseCommand: &BaseCommand{}}).Flags()
+	for _, arg := range start.Args {
+		if err := checkRunArg(runFlags.mainSet, arg); err != nil {
+			writeServerError(w, http.StatusBadRequest, fmt.Sprintf("invalid argument %q: %v", arg, err))
+			return
+		}
+	}
+	cfg := b.config(start.ConfigID)
+	if cfg == nil {
+		writeServerError(w, http.StatusNotFound, "config not found")
+		return
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.active != nil {
+		writeServerError(w, http.StatusConflict, fmt.Sprintf("run %s is already in progress", b.active.ID))
+		return
+	}
+	run, err := b.startRun(cfg, start.Args)
+	if err != nil {
+		writeServerError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeServerJSON(w, http.StatusCreated, run)
+}
+
+// serverRunFlags are the flags of the run command which runs of the server
+// may be given. Flags changing the outputs the server relies on, such as
+// report_mode, result_log and dry_run, or reading and writing other files
+// on the server, such as var_file and junit_file, are left out, so args
+// can't replace or corrupt the results of a run.
+var serverRunFlags = map[string]bool{
+	"vault_addr":                   true,
+	"vault_addrs":                  true,
+	"load_balance":                 true,
+	"read_addr":                    true,
+	"node_header":                  true,
+	"proxy_addr":                   true,
+	"http_proxy":                   true,
+	"vault_token":                  true,
+	"vault_namespace":              true,
+	"var":                          true,
+	"include":                      true,
+	"exclude":                      true,
+	"override":                     true,
+	"cluster":                      true,
+	"cluster_mode":                 true,
+	"workers":                      true,
+	"max_in_flight":                true,
+	"attack_token_type":            true,
+	"attack_token_policies":        true,
+	"namespace_fanout":             true,
+	"token_pool":                   true,
+	"rps":                          true,
+	"duration":                     true,
+	"requests":                     true,
+	"warmup":                       true,
+	"attack_mode":                  true,
+	"arrival":                      true,
+	"think_time":                   true,
+	"think_time_distribution":      true,
+	"report_percentiles":           true,
+	"report_interval":              true,
+	"timeseries_interval":          true,
+	"dns_refresh_interval":         true,
+	"idle_conn_timeout":            true,
+	"tls_handshake_timeout":        true,
+	"remote_write_url":             true,
+	"run_id":                       true,
+	"label":                        true,
+	"influx_url":                   true,
+	"influx_token":                 true,
+	"otlp_metrics_endpoint":        true,
+	"otlp_metrics_protocol":        true,
+	"otlp_traces_endpoint":         true,
+	"otlp_traces_protocol":         true,
+	"trace_sample_ratio":           true,
+	"resource_metrics_url":         true,
+	"random_mounts":                true,
+	"cleanup":                      true,
+	"log_level":                    true,
+	"debug":                        true,
+	"disable_http2":                true,
+	"force_http2":                  true,
+	"correct_coordinated_omission": true,
+	"follow_redirects":             true,
+	"error_samples":                true,
+	"max_idle_conns_per_host":      true,
+	"worker_connections":           true,
+}
+
+// checkRunArg checks the arg of a run is one of the serverRunFlags. The
+// outputs the server relies on are appended after the args, so flags which
+// take a value must be given it in the same arg: given on its own, a flag
+// would take the next arg as its value, and flags after a "--" or a value
+// would be taken as arguments.
+func checkRunArg(runFlags *flag.FlagSet, arg string) error {
+	name, _, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+	if !strings.HasPrefix(arg, "-") || name == "" || strings.HasPrefix(name, "-") {
+		return fmt.Errorf("args must be flags of the run command")
+	}
+	f := runFlags.Lookup(name)
+	switch {
+	case f == nil:
+		return fmt.Errorf("flag %v is not a flag of the run command", name)
+	case name == "config":
+		return fmt.Errorf("the config of a run is set by config_id")
+	case !serverRunFlags[name]:
+		return fmt.Errorf("flag %v can't be set for runs of the server", name)
+	}
+	if b, ok := f.Value.(interface{ IsBoolFlag() bool }); (!ok || !b.IsBoolFlag()) && !hasValue {
+		return fmt.Errorf("flag %v must be given its value as -%v=value", name, name)
+	}
+	return nil
+}
+
+// startRun runs the run command in a new directory holding a copy of the
+// configuration, so relative paths of the files the run writes, such as
+// junit_file, are kept with its results. Results are written as JSON and
+// interval reports to a file of their own, which are streamed as metrics.
+// Must be called with the lock held.
+func (b *benchmarkServer) startRun(cfg *serverConfig, args []string) (*serverRun, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, fmt.Errorf("error generating run ID: %v", err)
+	}
+	dir := filepath.Join(b.dataDir, "runs", id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating run directory: %v", err)
+	}
+	contents, err := os.ReadFile(filepath.Join(b.dataDir, "configs", cfg.ID, cfg.Name))
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, cfg.Name), contents, 0o600); err != nil {
+		return nil, fmt.Errorf("error copying config: %v", err)
+	}
+	stdout, err := os.Create(filepath.Join(dir, serverResultsFile))
+	if err != nil {
+		return nil, fmt.Errorf("error creating results file: %v", err)
+	}
+	stderr, err := os.Create(filepath.Join(dir, serverLogFile))
+	if err != nil {
+		stdout.Close()
+		return nil, fmt.Errorf("error creating log file: %v", err)
+	}
+
+	cmd := exec.Command(b.executable, b.runArgs(cfg.Name, args)...)
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		stdout.Close()
+		stderr.Close()
+		return nil, fmt.Errorf("error starting run: %v", err)
+	}
+
+	run := &serverRun{
+		ID:       id,
+		ConfigID: cfg.ID,
+		Args:     args,
+		Status:   serverRunRunning,
+		Started:  time.Now(),
+		cmd:      cmd,
+		done:     make(chan struct{}),
+	}
+	b.runs[id] = run
+	b.active = run
+	b.logger.Info("run started", "id", id, "config", cfg.ID)
+	if err := writeServerMetadata(dir, run); err != nil {
+		b.logger.Warn("error storing run", "id", id, "error", hclog.Fmt("%v", err))
+	}
+
+	go func() {
+		err := cmd.Wait()
+		stdout.Close()
+		stderr.Close()
+		b.finishRun(run, err)
+	}()
+	return run, nil
+}
+
+// runArgs are the arguments of the run command for a run. Those given for
+// the run come after the report interval, so it can be changed, but before
+// the outputs the server relies on, so they can't be.
+func (b *benchmarkServer) runArgs(configName string, args []string) []string {
+	runArgs := []string{
+		"run",
+		"-config=" + configName,
+		"-report_interval=" + b.reportInterval.String(),
+	}
+	runArgs = append(runArgs, args...)
+	return append(runArgs,
+		"-report_mode=json",
+		"-report_interval_file="+serverIntervalsFile,
+		"-live=false",
+	)
+}
+
+// finishRun records how the run ended
+func (b *benchmarkServer) finishRun(run *serverRun, err error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	now := time.Now()
+	run.Ended = &now
+	code := run.cmd.ProcessState.ExitCode()
+	run.ExitCode = &code
+
+	var exitErr *exec.ExitError
+	switch {
+	case run.Status == serverRunStopping:
+		run.Status = serverRunStopped
+	case err == nil:
+		run.Status = serverRunSucceeded
+	default:
+		run.Status = serverRunFailed
+	}
+	if err != nil && !errors.As(err, &exitErr) {
+		run.Error = err.Error()
+	}
+	if b.active == run {
+		b.active = nil
+	}
+	if err := writeServerMetadata(filepath.Join(b.dataDir, "runs", run.ID), run); err != nil {
+		b.logger.Warn("error storing run", "id", run.ID, "error", hclog.Fmt("%v", err))
+	}
+	close(run.done)
+	b.logger.Info("run ended", "id", run.ID, "status", run.Status, "exit_code", code)
+}
+
+func (b *benchmarkServer) handleListRuns(w http.ResponseWriter, req *http.Request) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	runs := make([]*serverRun, 0, len(b.runs))
+	for _, run := range b.runs {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Started.Before(runs[j].Started) })
+	writeServerJSON(w, http.StatusOK, map[string]interface{}{"runs": runs})
+}
+
+func (b *benchmarkServer) handleGetRun(w http.ResponseWriter, req *http.Request) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	run, ok := b.runs[req.PathValue("id")]
+	if !ok {
+		writeServerError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	writeServerJSON(w, http.StatusOK, run)
+}
+
+// handleStopRun interrupts the run, which cleans up as it would when
+// interrupted on a terminal
+func (b *benchmarkServer) handleStopRun(w http.ResponseWriter, req *http.Request) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	run, ok := b.runs[req.PathValue("id")]
+	if !ok {
+		writeServerError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	if run.Status == serverRunRunning {
+		if err := run.cmd.Process.Signal(os.Interrupt); err != nil {
+			writeServerError(w, http.StatusInternalServerError, fmt.Sprintf("error stopping run: %v", err))
+			return
+		}
+		run.Status = serverRunStopping
+		b.logger.Info("stopping run", "id", run.ID)
+	}
+	writeServerJSON(w, http.StatusAccepted, run)
+}
+
+// stopAll interrupts the active run and waits for it to end
+func (b *benchmarkServer) stopAll() {
+	b.l.Lock()
+	run := b.active
+	if run != nil && run.Status == serverRunRunning {
+		run.cmd.Process.Signal(os.Interrupt)
+		run.Status = serverRunStopping
+	}
+	b.l.Unlock()
+	if run != nil {
+		<-run.done
+	}
+}
+
+// handleStreamMetrics streams the interval reports of the run as newline
+// delimited JSON as they are written, until the run ends or the client
+// goes away
+func (b *benchmarkServer) handleStreamMetrics(w http.ResponseWriter, req *http.Request) {
+	b.l.Lock()
+	run, ok := b.runs[req.PathValue("id")]
+	b.l.Unlock()
+	if !ok {
+		writeServerError(w, http.StatusNotFound, "run not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	path := filepath.Join(b.dataDir, "runs", run.ID, serverIntervalsFile)
+	var offset int64
+	ticker := time.NewTicker(serverPollInterval)
+	defer ticker.Stop()
+	for {
+		// The run may end between reading and checking, so the file is
+		// read once more after it has
+		var ended bool
+		select {
+		case <-run.done:
+			ended = true
+		default:
+		}
+		n, err := copyLines(w, path, offset)
+		offset += n
+		if err != nil {
+			b.logger.Warn("error streaming metrics", "id", run.ID, "error", hclog.Fmt("%v", err))
+			return
+		}
+		if n > 0 && flusher != nil {
+			flusher.Flush()
+		}
+		if ended {
+			return
+		}
+		select {
+		case <-req.Context().Done():
+			return
+		case <-run.done:
+		case <-ticker.C:
+		}
+	}
+}
+
+// copyLines writes the complete lines of the file from offset onwards,
+// returning the number of bytes written. The file may not exist yet.
+func copyLines(w io.Writer, path string, offset int64) (int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	var n int64
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			// A partial line is sent once the rest of it is written
+			return n, nil
+		}
+		if _, err := w.Write(line); err != nil {
+			return n, err
+		}
+		n += int64(len(line))
+	}
+}
+
+// handleListFiles lists the files in the directory of the run
+func (b *benchmarkServer) handleListFiles(w http.ResponseWriter, req *http.Request) {
+	b.l.Lock()
+	_, ok := b.runs[req.PathValue("id")]
+	b.l.Unlock()
+	if !ok {
+		writeServerError(w, http.StatusNotFound, "run not found")
+		return
+	}
+	entries, err := os.ReadDir(filepath.Join(b.dataDir, "runs", req.PathValue("id")))
+	if err != nil {
+		writeServerError(w, http.StatusInternalServerError, fmt.Sprintf("error listing files: %v", err))
+		return
+	}
+	type file struct {
+		Name     string    `json:"name"`
+		Size     int64     `json:"size"`
+		Modified time.Time `json:"modified"`
+	}
+	files := make([]file, 0, len(entries))
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, file{Name: entry.Name(), Size: info.Size(), Modified: info.ModTime()})
+	}
+	writeServerJSON(w, http.StatusOK, map[string]interface{}{"files": files})
+}
+
+// handleGetFile returns a file in the directory of the run, such as its
+// results or logs
+func (b *benchmarkServer) handleGetFile(w http.ResponseWriter, req *http.Request) {
+	b.l.Lock()
+	_, ok := b.runs[req.PathValue("id")]
+	b.l.Unlock()
+	name := req.PathValue("name")
+	if !ok || name != filepath.Base(name) || name == "." || name == ".." {
+		writeServerError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	path := filepath.Join(b.dataDir, "runs", req.PathValue("id"), name)
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		writeServerError(w, http.StatusNotFound, "file not found")
+		return
+	}
+	http.ServeFile(w, req, path)
+}
+
+func (b *benchmarkServer) config(id string) *serverConfig {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.configs[id]
+}
+
+func writeServerJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeServerError(w http.ResponseWriter, code int, msg string) {
+	writeServerJSON(w, code, map[string][]string{"errors": {msg}})
+}
@@ -0,0 +1,247 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+)
+
+// testBenchmarkServer serves the API of a server whose runs record their
+// arguments and wait to be stopped, in place of running benchmarks
+func testBenchmarkServer(t *testing.T, token string) (*benchmarkServer, *httptest.Server) {
+	t.Helper()
+	dir := t.TempDir()
+	executable := filepath.Join(dir, "vault-benchmark")
+	if err := os.WriteFile(executable, []byte("#!/bin/sh\necho \"$@\" > args\nexec sleep 30\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	api, err := newBenchmarkServer(filepath.Join(dir, "data"), executable, 10*time.Second, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(api.handler(token))
+	t.Cleanup(func() {
+		api.stopAll()
+		srv.Close()
+	})
+	return api, srv
+}
+
+func serverRequest(t *testing.T, srv *httptest.Server, method, path, token, body string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("error decoding response of %v %v: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestBenchmarkServer_Token(t *testing.T) {
+	_, srv := testBenchmarkServer(t, "secret")
+	for _, token := range []string{"", "wrong"} {
+		if code := serverRequest(t, srv, "GET", "/v1/configs", token, "", nil); code != http.StatusUnauthorized {
+			t.Fatalf("expected token %q to be rejected, got %d", token, code)
+		}
+	}
+	if code := serverRequest(t, srv, "GET", "/v1/configs", "secret", "", nil); code != http.StatusOK {
+		t.Fatalf("expected the token to be accepted, got %d", code)
+	}
+}
+
+func TestServerCommand_RequiresAuthOffLoopback(t *testing.T) {
+	ui := cli.NewMockUi()
+	cmd := &ServerCommand{BaseCommand: &BaseCommand{UI: ui}}
+	if code := cmd.Run([]string{"-listen=0.0.0.0:0", "-data_dir=" + t.TempDir()}); code != 1 {
+		t.Fatalf("expected the server to refuse to start, got %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "requires token or tls_client_ca_file") {
+		t.Fatalf("unexpected error: %v", ui.ErrorWriter.String())
+	}
+}
+
+func TestServerCommand_RequiresTLSOffLoopback(t *testing.T) {
+	ui := cli.NewMockUi()
+	cmd := &ServerCommand{BaseCommand: &BaseCommand{UI: ui}}
+	if code := cmd.Run([]string{"-listen=0.0.0.0:0", "-token=secret", "-data_dir=" + t.TempDir()}); code != 1 {
+		t.Fatalf("expected the server to refuse to start, got %d", code)
+	}
+	if !strings.Contains(ui.ErrorWriter.String(), "requires tls_cert_file and tls_key_file") {
+		t.Fatalf("unexpected error: %v", ui.ErrorWriter.String())
+	}
+}
+
+func TestBenchmarkServer_Runs(t *testing.T) {
+	api, srv := testBenchmarkServer(t, "")
+
+	var cfg serverConfig
+	if code := serverRequest(t, srv, "POST", "/v1/configs?name=../kv.hcl", "", `rps = 10`, &cfg); code != http.StatusCreated {
+		t.Fatalf("expected the config to be created, got %d", code)
+	}
+	if cfg.Name != "kv.hcl" {
+		t.Fatalf("expected the directory of the name to be dropped, got %q", cfg.Name)
+	}
+
+	// Configs can't be named after the files of runs
+	for _, name := range []string{"results.json", "run.log", "intervals.json", "metadata.json"} {
+		if code := serverRequest(t, srv, "POST", "/v1/configs?name="+name, "", `rps = 10`, nil); code != http.StatusBadRequest {
+			t.Fatalf("expected config name %q to be rejected, got %d", name, code)
+		}
+	}
+
+	// Configs are checked when submitted, and those reading other files,
+	// which runs wouldn't get a copy of, are rejected
+	dataFile := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(dataFile, []byte("username\nalice\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, config := range []string{
+		`rps = "ten"`,
+		`result_log = "-"`,
+		`include = ["common.hcl"]`,
+		`vault_token = file("token")`,
+		`test "raw" "raw" {
+  config {
+    method = "GET"
+    path   = "/v1/sys/health"
+    data_file {
+      path = ` + strconv.Quote(dataFile) + `
+    }
+  }
+}`,
+	} {
+		if code := serverRequest(t, srv, "POST", "/v1/configs", "", config, nil); code != http.StatusBadRequest {
+			t.Fatalf("expected config %q to be rejected, got %d", config, code)
+		}
+	}
+	if entries, err := os.ReadDir(filepath.Join(api.dataDir, "configs")); err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the valid config to be stored, got %v: %v", len(entries), err)
+	}
+
+	// Args must each be a flag runs of the server may be given, with the
+	// value of flags which aren't boolean
+	for _, arg := range []string{
+		"--", "-", "--=x", "---rps=1", "10", "-config=other.hcl", "--config=other.hcl", "-state_file", "-report_mode", "-nope=1",
+		"-result_log=-", "-dry_run", "-report_interval_file=other.json", "-report_mode=terse", "-var_file=vars.hcl", "-junit_file=results.json",
+	} {
+		body := `{"config_id": "` + cfg.ID + `", "args": ["-rps=10", ` + strconv.Quote(arg) + `]}`
+		if code := serverRequest(t, srv, "POST", "/v1/runs", "", body, nil); code != http.StatusBadRequest {
+			t.Fatalf("expected arg %q to be rejected, got %d", arg, code)
+		}
+	}
+	if code := serverRequest(t, srv, "POST", "/v1/runs", "", `{"config_id": "missing"}`, nil); code != http.StatusNotFound {
+		t.Fatalf("expected an unknown config to be rejected, got %d", code)
+	}
+
+	var run serverRun
+	body := `{"config_id": "` + cfg.ID + `", "args": ["-duration=1h", "-cleanup"]}`
+	if code := serverRequest(t, srv, "POST", "/v1/runs", "", body, &run); code != http.StatusCreated {
+		t.Fatalf("expected the run to start, got %d", code)
+	}
+
+	// Only one run is in progress at a time
+	if code := serverRequest(t, srv, "POST", "/v1/runs", "", body, nil); code != http.StatusConflict {
+		t.Fatalf("expected a second run to be rejected, got %d", code)
+	}
+
+	// The outputs the server relies on come after the args of the run
+	argsPath := filepath.Join(api.dataDir, "runs", run.ID, "args")
+	var args []byte
+	for deadline := time.Now().Add(10 * time.Second); len(args) == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		args, _ = os.ReadFile(argsPath)
+	}
+	expected := "run -config=kv.hcl -report_interval=10s -duration=1h -cleanup -report_mode=json -report_interval_file=intervals.json -live=false\n"
+	if string(args) != expected {
+		t.Fatalf("expected args %q, got %q", expected, args)
+	}
+
+	// Files are only served from the directory of the run
+	if code := serverRequest(t, srv, "GET", "/v1/runs/"+run.ID+"/files/kv.hcl", "", "", nil); code != http.StatusOK {
+		t.Fatalf("expected the config of the run to be served, got %d", code)
+	}
+	for _, name := range []string{"%2e%2e", "..%2F..%2Fconfigs%2F" + cfg.ID + "%2Fkv.hcl", "missing"} {
+		if code := serverRequest(t, srv, "GET", "/v1/runs/"+run.ID+"/files/"+name, "", "", nil); code != http.StatusNotFound {
+			t.Fatalf("expected file %q not to be served, got %d", name, code)
+		}
+	}
+
+	if code := serverRequest(t, srv, "POST", "/v1/runs/"+run.ID+"/stop", "", "", nil); code != http.StatusAccepted {
+		t.Fatalf("expected the run to be stopped, got %d", code)
+	}
+	api.stopAll()
+	if code := serverRequest(t, srv, "GET", "/v1/runs/"+run.ID, "", "", &run); code != http.StatusOK || run.Status != serverRunStopped {
+		t.Fatalf("expected the run to be stopped, got %d: %+v", code, run)
+	}
+	if code := serverRequest(t, srv, "POST", "/v1/runs", "", body, &run); code != http.StatusCreated {
+		t.Fatalf("expected a run to start once the last ended, got %d", code)
+	}
+}
+
+func TestBenchmarkServer_Restart(t *testing.T) {
+	api, srv := testBenchmarkServer(t, "")
+	var cfg serverConfig
+	if code := serverRequest(t, srv, "POST", "/v1/configs", "", `rps = 10`, &cfg); code != http.StatusCreated {
+		t.Fatalf("expected the config to be created, got %d", code)
+	}
+	var run serverRun
+	if code := serverRequest(t, srv, "POST", "/v1/runs", "", `{"config_id": "`+cfg.ID+`"}`, &run); code != http.StatusCreated {
+		t.Fatalf("expected the run to start, got %d", code)
+	}
+	api.stopAll()
+
+	// A run in progress when its server went away without stopping it
+	crashed := filepath.Join(api.dataDir, "runs", "crashed")
+	if err := os.MkdirAll(crashed, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeServerMetadata(crashed, &serverRun{ConfigID: cfg.ID, Status: serverRunRunning}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Configs and runs are served again by the next server
+	restarted, err := newBenchmarkServer(api.dataDir, api.executable, 10*time.Second, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv = httptest.NewServer(restarted.handler(""))
+	defer srv.Close()
+	var configs struct {
+		Configs []serverConfig `json:"configs"`
+	}
+	if code := serverRequest(t, srv, "GET", "/v1/configs", "", "", &configs); code != http.StatusOK || len(configs.Configs) != 1 || configs.Configs[0] != cfg {
+		t.Fatalf("expected the config to be served, got %d: %+v", code, configs)
+	}
+	if code := serverRequest(t, srv, "GET", "/v1/runs/"+run.ID, "", "", &run); code != http.StatusOK || run.Status != serverRunStopped || run.ConfigID != cfg.ID {
+		t.Fatalf("expected the stopped run to be served, got %d: %+v", code, run)
+	}
+	if code := serverRequest(t, srv, "GET", "/v1/runs/"+run.ID+"/files/config.hcl", "", "", nil); code != http.StatusOK {
+		t.Fatalf("expected the files of the run to be served, got %d", code)
+	}
+	if code := serverRequest(t, srv, "GET", "/v1/runs/crashed", "", "", &run); code != http.StatusOK || run.Status != serverRunFailed {
+		t.Fatalf("expected the run in progress to have failed, got %d: %+v", code, run)
+	}
+}
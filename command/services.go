@@ -0,0 +1,123 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// runServices are the services started for a run, such as a dev server or
+// dependency containers, which are torn down in reverse order when it ends
+// or is interrupted
+type runServices struct {
+	services []interface{ Stop() }
+	stop     func()
+}
+
+func newRunServices() *runServices {
+	s := &runServices{}
+	s.stop = sync.OnceFunc(func() {
+		for i := len(s.services) - 1; i >= 0; i-- {
+			s.services[i].Stop()
+		}
+	})
+	return s
+}
+
+// add tears the service down along with the others, stopping all of them
+// when the run is interrupted
+func (s *runServices) add(service interface{ Stop() }) {
+	if len(s.services) == 0 {
+		interrupted := make(chan os.Signal, 1)
+		signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-interrupted
+			s.Stop()
+			os.Exit(1)
+		}()
+	}
+	s.services = append(s.services, service)
+}
+
+// startDependencies starts the dependency containers of the config, waiting
+// for each of them to be ready
+func (s *runServices) startDependencies(conf *vbConfig.VaultBenchmarkCoreConfig, logger hclog.Logger) error {
+	deps, err := startDependencies(conf.Dependencies, logger)
+	if err != nil {
+		return err
+	}
+	for _, dep := range deps {
+		s.add(dep)
+	}
+	return nil
+}
+
+// Stop tears down the services started, once
+func (s *runServices) Stop() {
+	s.stop()
+}
+
+// runMetrics is the gauge telling whether the attack of a run is executing,
+// annotated with the annotate labels of the config
+type runMetrics struct {
+	running prometheus.Gauge
+}
+
+// serveMetrics registers the bench_running gauge and serves it along with
+// the other metrics of the run on :2112, labeled with the labels of the
+// config
+func serveMetrics(conf *vbConfig.VaultBenchmarkCoreConfig) (*runMetrics, error) {
+	var annoLabels []string
+	var annoValues []string
+	if conf.Annotate != "" {
+		for _, kv := range strings.Split(conf.Annotate, ",") {
+			kvPair := strings.SplitN(kv, "=", 2)
+			if len(kvPair) != 2 || kvPair[0] == "" {
+				return nil, fmt.Errorf("annotate should contain comma-separated list of name=value pairs, got %q", conf.Annotate)
+			}
+			annoLabels = append(annoLabels, kvPair[0])
+			annoValues = append(annoValues, kvPair[1])
+		}
+	}
+
+	testRunning := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "bench_running",
+		Help: "is the benchmark attack executing",
+	}, annoLabels)
+	prometheus.MustRegister(testRunning)
+	m := &runMetrics{running: testRunning.WithLabelValues(annoValues...)}
+	m.setRunning(false)
+
+	metricsHandler := promhttp.Handler()
+	if len(conf.Labels) > 0 {
+		metricsHandler = promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+			promhttp.HandlerFor(benchmarktests.LabeledGatherer(prometheus.DefaultGatherer, conf.Labels), promhttp.HandlerOpts{}))
+	}
+	http.Handle("/metrics", metricsHandler)
+	go func() {
+		_ = http.ListenAndServe(":2112", nil)
+	}()
+	return m, nil
+}
+
+// setRunning sets whether the attack is executing
+func (m *runMetrics) setRunning(running bool) {
+	if running {
+		m.running.Set(1)
+	} else {
+		m.running.Set(0)
+	}
+}
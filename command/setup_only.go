@@ -0,0 +1,28 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	vaultapi "github.com/openbao/openbao/api/v2"
+)
+
+// setupOnly sets the tests of the config up against the client and saves
+// their setup to the state file, so attack only runs can attack them
+// without setting them up again. The tests are left set up.
+func (r *RunCommand) setupOnly(client *vaultapi.Client, conf *vbConfig.VaultBenchmarkCoreConfig, topLevelConfig *benchmarktests.TopLevelTargetConfig, logger hclog.Logger) int {
+	state, err := benchmarktests.SetupOnly(client, conf.Tests, &logger, topLevelConfig)
+	if err != nil {
+		logger.Error("target setup failed", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	if err := state.Save(r.flagStateFile); err != nil {
+		logger.Error("error saving setup state", "error", hclog.Fmt("%v", err))
+		return 1
+	}
+	logger.Info("saved setup of targets", "path", r.flagStateFile, "tests", len(state.Tests), "mounts", len(state.Mounts()))
+	return 0
+}
@@ -0,0 +1,368 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	vaultapi "github.com/openbao/openbao/api/v2"
+)
+
+// runTargets are the servers a run attacks, with a client for each of them
+type runTargets struct {
+	conf      *vbConfig.VaultBenchmarkCoreConfig
+	transport *benchmarktests.TransportConfig
+	logger    hclog.Logger
+
+	addrs   []string
+	token   string
+	clients []*vaultapi.Client
+
+	// proxy, when set, is the OpenBao Proxy or Agent requests are sent
+	// through, while tests are set up directly against the server
+	proxy *vaultapi.Client
+
+	// login gets a new token from the token_source of the config, when it
+	// has one
+	login func() (string, error)
+
+	// seed is the address nodes are discovered from, every nodeRefresh
+	// during the run when it is set
+	seed        *vaultapi.Client
+	nodeRefresh time.Duration
+}
+
+// connectTargets finds the addresses the run attacks, whether of a dev
+// server, pods in kubernetes, cluster_json, vault_addrs or vault_addr, gets
+// the token to attack them with and creates their clients. Services the
+// targets need, such as a dev server, are handed to addService.
+func connectTargets(conf *vbConfig.VaultBenchmarkCoreConfig, transport *benchmarktests.TransportConfig, addService func(interface{ Stop() }), logger hclog.Logger) (*runTargets, error) {
+	t := &runTargets{conf: conf, transport: transport, logger: logger}
+
+	var cluster struct {
+		Token      string   `json:"token"`
+		VaultAddrs []string `json:"vault_addrs"`
+	}
+
+	switch {
+	case conf.DevTarget != "":
+		if conf.ClusterJSON != "" || len(conf.VaultAddrs) > 0 || conf.Kubernetes != nil {
+			return nil, errors.New("dev_target cannot be combined with cluster_json, vault_addrs or kubernetes")
+		}
+		dev, err := startDevServer(conf.DevTarget, logger.Named("dev"))
+		if err != nil {
+			return nil, fmt.Errorf("error starting dev server: %w", err)
+		}
+		addService(dev)
+
+		cluster.VaultAddrs = []string{dev.Addr}
+		cluster.Token = dev.Token
+	case conf.Kubernetes != nil:
+		if conf.ClusterJSON != "" || len(conf.VaultAddrs) > 0 {
+			return nil, errors.New("kubernetes cannot be combined with cluster_json or vault_addrs")
+		}
+		addrs, err := discoverKubernetes(conf.Kubernetes)
+		if err != nil {
+			return nil, fmt.Errorf("error discovering OpenBao in kubernetes: %w", err)
+		}
+		logger.Info("discovered OpenBao in kubernetes", "addresses", strings.Join(addrs, ","))
+		cluster.VaultAddrs = addrs
+	case conf.ClusterJSON != "":
+		b, err := os.ReadFile(conf.ClusterJSON)
+		if err != nil {
+			return nil, fmt.Errorf("error reading cluster_json file %q: %w", conf.ClusterJSON, err)
+		}
+		err = json.Unmarshal(b, &cluster)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding cluster_json file %q: %w", conf.ClusterJSON, err)
+		}
+	case len(conf.VaultAddrs) > 0:
+		cluster.VaultAddrs = conf.VaultAddrs
+	case conf.VaultAddr != "":
+		cluster.VaultAddrs = []string{conf.VaultAddr}
+	}
+	if len(cluster.VaultAddrs) == 0 {
+		return nil, errors.New("must specify one of cluster_json, vault_addr, or $VAULT_ADDR")
+	}
+
+	// Requests to unix sockets are all addressed to localhost, so a socket
+	// can't be told apart from other targets
+	if len(cluster.VaultAddrs) > 1 && slices.ContainsFunc(cluster.VaultAddrs, func(addr string) bool {
+		return strings.HasPrefix(addr, benchmarktests.UnixSocketPrefix)
+	}) {
+		return nil, errors.New("unix socket addresses cannot be combined with other target addresses")
+	}
+
+	if conf.VaultToken != "" && conf.DevTarget == "" {
+		cluster.Token = conf.VaultToken
+	}
+	if conf.VaultToken == "" && cluster.Token == "" && conf.TokenSource == nil {
+		return nil, errors.New("must specify one of the following: cluster_json, vault_token, token_source, or $VAULT_TOKEN")
+	}
+	t.addrs, t.token = cluster.VaultAddrs, cluster.Token
+
+	// A token source replaces the token given with vault_token, logging in
+	// through the first address when needed. It is also used to get a new
+	// token when the current one can no longer be renewed.
+	if conf.TokenSource != nil {
+		loginClient, err := t.newClient(t.addrs[0])
+		if err != nil {
+			return nil, fmt.Errorf("error creating vault client: %w", err)
+		}
+		t.login = func() (string, error) {
+			return sourceToken(loginClient, conf.TokenSource)
+		}
+		t.token, err = t.login()
+		if err != nil {
+			return nil, fmt.Errorf("error getting token from token_source: %w", err)
+		}
+		logger.Info("got token from token_source", "type", conf.TokenSource.Type)
+	}
+
+	if err := t.connect(t.addrs); err != nil {
+		return nil, err
+	}
+
+	// The nodes of the cluster may be discovered from the target address,
+	// which is kept to discover them again during the run
+	if conf.NodeDiscovery != nil {
+		t.seed = t.clients[0]
+		// Refresh intervals are validated when the config is loaded
+		t.nodeRefresh, _ = conf.NodeDiscovery.Refresh()
+		addrs, err := discoverNodes(t.seed)
+		if err != nil {
+			return nil, fmt.Errorf("error discovering cluster nodes: %w", err)
+		}
+		logger.Info("discovered cluster nodes", "addresses", strings.Join(addrs, ","))
+		if err := t.connect(addrs); err != nil {
+			return nil, err
+		}
+		if conf.LoadBalance == "" {
+			conf.LoadBalance = benchmarktests.RoundRobinBalance
+		}
+	}
+
+	// Tests with their own token source attack with the token it gives,
+	// logging in through the first address when needed
+	for _, vbTest := range conf.Tests {
+		if vbTest.TokenSource == nil {
+			continue
+		}
+		var err error
+		vbTest.Token, err = sourceToken(t.clients[0], vbTest.TokenSource)
+		if err != nil {
+			return nil, fmt.Errorf("error getting token from token_source of test %v: %w", vbTest.Name, err)
+		}
+		logger.Info("got token from token_source of test", "test", vbTest.Name, "type", vbTest.TokenSource.Type)
+	}
+
+	// Requests may be sent through an OpenBao Proxy or Agent, while tests
+	// are set up directly against the server
+	if conf.ProxyAddr != "" {
+		if len(t.clients) > 1 || conf.LoadBalance != "" {
+			return nil, errors.New("proxy_addr cannot be combined with several target addresses")
+		}
+		var err error
+		t.proxy, err = t.newClient(conf.ProxyAddr)
+		if err != nil {
+			return nil, fmt.Errorf("error creating proxy client: %w", err)
+		}
+	}
+	return t, nil
+}
+
+// connect replaces the addresses and clients of the targets with those of
+// addrs
+func (t *runTargets) connect(addrs []string) error {
+	clients := make([]*vaultapi.Client, 0, len(addrs))
+	for _, addr := range addrs {
+		client, err := t.newClient(addr)
+		if err != nil {
+			return fmt.Errorf("error creating vault client: %w", err)
+		}
+		clients = append(clients, client)
+	}
+	t.addrs, t.clients = addrs, clients
+	return nil
+}
+
+// newClient returns a client of the address with the token of the targets
+func (t *runTargets) newClient(addr string) (*vaultapi.Client, error) {
+	tlsCfg := &vaultapi.TLSConfig{}
+	cfg := vaultapi.DefaultConfig()
+	if t.conf.CAPEMFile != "" {
+		tlsCfg.CACert = t.conf.CAPEMFile
+	}
+
+	err := cfg.ConfigureTLS(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check if we're forcing HTTP/1.1. Used to make sure benchmark traffic
+	// is spread across nodes when Vault is behind a load balancer.
+	if t.conf.DisableHTTP2 {
+		t.logger.Warn("http2 disabled, using http/1.1")
+		transport := cfg.HttpClient.Transport.(*http.Transport)
+		transport.TLSClientConfig.NextProtos = []string{"http/1.1"}
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		transport.ForceAttemptHTTP2 = false
+		cfg.HttpClient.Transport = transport
+	}
+
+	// Setup requests go through the same HTTP or SOCKS proxy as the
+	// benchmark requests
+	if proxy, ok := t.transport.ProxyFunc(); ok {
+		cfg.HttpClient.Transport.(*http.Transport).Proxy = proxy
+	}
+
+	cfg.Address = addr
+	client, err := vaultapi.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken(t.token)
+	client.SetNamespace(t.conf.VaultNamespace)
+	return client, nil
+}
+
+// renewToken keeps the token alive through setup, the run and cleanup of
+// long runs, for all clients made with it, until stop is closed
+func (t *runTargets) renewToken(stop <-chan struct{}) *benchmarktests.TokenRenewer {
+	renewer := &benchmarktests.TokenRenewer{
+		Clients: t.clients,
+		Login:   t.login,
+		Logger:  t.logger.Named("token"),
+	}
+	if t.proxy != nil {
+		renewer.Clients = append(slices.Clone(t.clients), t.proxy)
+	}
+	if err := renewer.Start(stop); err != nil {
+		t.logger.Warn("unable to renew token during the run", "error", hclog.Fmt("%v", err))
+	}
+	return renewer
+}
+
+// serverInfo records what each target is running, so results files
+// describe the servers they were measured against
+func (t *runTargets) serverInfo() map[string]*benchmarktests.ServerInfo {
+	serverInfo := make(map[string]*benchmarktests.ServerInfo, len(t.clients))
+	for _, client := range t.clients {
+		info, err := benchmarktests.CollectServerInfo(client)
+		if err != nil {
+			t.logger.Warn("unable to read server information", "target", client.Address(), "error", hclog.Fmt("%v", err))
+			continue
+		}
+		t.logger.Debug("server information", "target", client.Address(), "version", info.Version, "storage", info.StorageType, "seal", info.SealType)
+		serverInfo[client.Address()] = info
+	}
+	return serverInfo
+}
+
+// loadBalance returns the load_balance of the run, if any, and the clients
+// to attack with. A balanced attack is sent through the first client, or
+// the active node when routing reads to standbys, and spread across the
+// addresses of all of them.
+func (t *runTargets) loadBalance(serverInfo map[string]*benchmarktests.ServerInfo) (*benchmarktests.LoadBalanceConfig, []*vaultapi.Client, error) {
+	conf := t.conf
+	if conf.ReadAddr != "" && conf.LoadBalance != benchmarktests.StandbyReadsBalance {
+		t.logger.Warn("read_addr is only used with the standby_reads load_balance strategy")
+	}
+	if conf.LoadBalance == "" {
+		if len(conf.LBWeights) > 0 {
+			t.logger.Warn("load_balance_weights is only used with the weighted load_balance strategy")
+		}
+		return nil, t.clients, nil
+	}
+
+	loadBalance := &benchmarktests.LoadBalanceConfig{Strategy: conf.LoadBalance}
+	for i, client := range t.clients {
+		loadBalance.Addrs = append(loadBalance.Addrs, client.Address())
+		if conf.LoadBalance == benchmarktests.WeightedBalance {
+			weight, ok := conf.LBWeights[t.addrs[i]]
+			if !ok {
+				weight = 1
+			}
+			loadBalance.Weights = append(loadBalance.Weights, weight)
+		}
+	}
+	for addr := range conf.LBWeights {
+		if !slices.Contains(t.addrs, addr) {
+			return nil, nil, fmt.Errorf("load_balance_weights has a weight for an unknown address: %v", addr)
+		}
+	}
+	if conf.LoadBalance == benchmarktests.StandbyReadsBalance {
+		// Writes go to the node which isn't a standby, or to the
+		// only address when it fronts the whole cluster
+		for _, addr := range loadBalance.Addrs {
+			info, ok := serverInfo[addr]
+			switch {
+			case loadBalance.Active != "" && ok && !info.Standby:
+				return nil, nil, fmt.Errorf("standby_reads requires a single active node, found %v and %v", loadBalance.Active, addr)
+			case len(loadBalance.Addrs) == 1, ok && !info.Standby:
+				loadBalance.Active = addr
+			case conf.ReadAddr == "" && ok:
+				loadBalance.ReadAddrs = append(loadBalance.ReadAddrs, addr)
+			}
+		}
+		if conf.ReadAddr != "" {
+			if !slices.Contains(loadBalance.Addrs, conf.ReadAddr) {
+				loadBalance.Addrs = append(loadBalance.Addrs, conf.ReadAddr)
+			}
+			loadBalance.ReadAddrs = []string{conf.ReadAddr}
+		}
+		t.logger.Info("routing reads to standby nodes", "active", loadBalance.Active, "reads", strings.Join(loadBalance.ReadAddrs, ","))
+	}
+	if t.nodeRefresh > 0 {
+		loadBalance.Nodes = benchmarktests.NewNodeSet(loadBalance.Addrs)
+	}
+	if err := loadBalance.Validate(conf.AttackMode); err != nil {
+		return nil, nil, fmt.Errorf("invalid load_balance: %w", err)
+	}
+	if loadBalance.Active != "" {
+		// Set the tests up on the active node rather than have a
+		// standby forward every request
+		i := slices.IndexFunc(t.clients, func(client *vaultapi.Client) bool { return client.Address() == loadBalance.Active })
+		return loadBalance, t.clients[i : i+1], nil
+	}
+	return loadBalance, t.clients[:1], nil
+}
+
+// buildTargets sets the tests of the config up on the target of the
+// client. Tests may be fanned out into namespaces of their own, each set up
+// the same way, or set up as the setup only run which left setupState set
+// them up.
+func (r *RunCommand) buildTargets(client *vaultapi.Client, conf *vbConfig.VaultBenchmarkCoreConfig, setupState *benchmarktests.SetupState, topLevelConfig *benchmarktests.TopLevelTargetConfig, logger hclog.Logger) (*benchmarktests.TargetMulti, *benchmarktests.NamespaceFanOut, error) {
+	switch {
+	case conf.Namespaces > 0:
+		logger.Info("creating namespaces", "count", conf.Namespaces)
+		fanOut, err := benchmarktests.CreateNamespaces(client, conf.Namespaces, conf.RandomMounts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error creating namespaces: %v", err)
+		}
+		tm, err := fanOut.BuildTargets(conf.Tests, &logger, topLevelConfig)
+		return tm, fanOut, err
+	case setupState != nil:
+		if setupState.Addr != client.Address() {
+			logger.Warn("tests were set up against another address", "address", setupState.Addr)
+		}
+		logger.Info("replaying setup of targets", "path", r.flagStateFile, "created", setupState.Created.Format(time.RFC3339))
+		tm, err := setupState.BuildTargets(client, conf.Tests, &logger, topLevelConfig)
+		return tm, nil, err
+	default:
+		tm, err := benchmarktests.BuildTargets(client, conf.Tests, &logger, topLevelConfig)
+		return tm, nil, err
+	}
+}
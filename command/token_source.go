@@ -0,0 +1,99 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vaultapi "github.com/openbao/openbao/api/v2"
+)
+
+// tokenExecTimeout is how long the helper command of an exec token source
+// may take to print the token
+const tokenExecTimeout = time.Minute
+
+// sourceToken gets the token the benchmark authenticates with from the
+// token source, logging in with the client for AppRole and Kubernetes auth
+func sourceToken(client *vaultapi.Client, source *benchmarktests.TokenSourceConfig) (string, error) {
+	var token string
+	switch source.Type {
+	case benchmarktests.FileTokenSource:
+		b, err := os.ReadFile(source.Path)
+		if err != nil {
+			return "", fmt.Errorf("error reading token file: %v", err)
+		}
+		token = string(b)
+	case benchmarktests.EnvTokenSource:
+		token = os.Getenv(source.Variable)
+	case benchmarktests.AppRoleTokenSource:
+		secretID := source.SecretID
+		if source.SecretIDFile != "" {
+			b, err := os.ReadFile(source.SecretIDFile)
+			if err != nil {
+				return "", fmt.Errorf("error reading secret_id_file: %v", err)
+			}
+			secretID = strings.TrimSpace(string(b))
+		}
+		data := map[string]interface{}{"role_id": source.RoleID}
+		if secretID != "" {
+			data["secret_id"] = secretID
+		}
+		return login(client, source.Mount, data)
+	case benchmarktests.KubernetesTokenSource:
+		jwt, err := os.ReadFile(source.JWTFile)
+		if err != nil {
+			return "", fmt.Errorf("error reading service account token: %v", err)
+		}
+		return login(client, source.Mount, map[string]interface{}{
+			"role": source.Role,
+			"jwt":  strings.TrimSpace(string(jwt)),
+		})
+	case benchmarktests.ExecTokenSource:
+		ctx, cancel := context.WithTimeout(context.Background(), tokenExecTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, source.Command[0], source.Command[1:]...).Output()
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+				return "", fmt.Errorf("error running token command: %v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return "", fmt.Errorf("error running token command: %v", err)
+		}
+		token = string(out)
+	default:
+		return "", fmt.Errorf("unknown token source: %v", source.Type)
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("%v token source gave an empty token", source.Type)
+	}
+	return token, nil
+}
+
+// login logs in to the auth mount with the data and returns the token it
+// issued
+func login(client *vaultapi.Client, mount string, data map[string]interface{}) (string, error) {
+	// Keep the namespace, which is sent as a header
+	client, err := client.CloneWithHeaders()
+	if err != nil {
+		return "", err
+	}
+	client.ClearToken()
+	secret, err := client.Logical().Write("auth/"+strings.Trim(mount, "/")+"/login", data)
+	if err != nil {
+		return "", fmt.Errorf("error logging in to %v: %v", mount, err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return "", fmt.Errorf("login to %v returned no token", mount)
+	}
+	return secret.Auth.ClientToken, nil
+}
@@ -0,0 +1,250 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*ValidateCommand)(nil)
+	_ cli.CommandAutocomplete = (*ValidateCommand)(nil)
+)
+
+type ValidateCommand struct {
+	*BaseCommand
+	flagConfigPath string
+	flagVars       map[string]string
+	flagVarFiles   []string
+}
+
+func (v *ValidateCommand) Synopsis() string {
+	return "Check a benchmark config for problems without running it"
+}
+
+func (v *ValidateCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark validate [options]
+
+ This command loads a benchmark config, parses the config of every test and
+ checks the options of the run, reporting every problem found rather than
+ only the first. The target is never contacted, so configs can be checked
+ before a run, such as in CI.
+
+	$ vault-benchmark validate -config=config.hcl
+
+ For a full list of examples, please see the documentation.
+
+` + v.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (v *ValidateCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (v *ValidateCommand) AutocompleteFlags() complete.Flags {
+	return v.Flags().Completions()
+}
+
+func (v *ValidateCommand) Flags() *FlagSets {
+	set := v.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:       "config",
+		Target:     &v.flagConfigPath,
+		Default:    "",
+		Completion: complete.PredictOr(complete.PredictFiles("*.hcl"), complete.PredictFiles("*.json")),
+		Usage:      "Path to a vault-benchmark test configuration file.",
+	})
+
+	f.StringMapVar(&StringMapVar{
+		Name:    "var",
+		Target:  &v.flagVars,
+		Default: nil,
+		Usage:   "Value of a variable declared by the config, as name=value. This can be specified multiple times.",
+	})
+
+	f.StringSliceVar(&StringSliceVar{
+		Name:       "var_file",
+		Target:     &v.flagVarFiles,
+		Default:    nil,
+		Completion: complete.PredictOr(complete.PredictFiles("*.hcl"), complete.PredictFiles("*.json")),
+		Usage:      "Path to a file setting variables declared by the config. This can be specified multiple times.",
+	})
+	return set
+}
+
+func (v *ValidateCommand) Run(args []string) int {
+	f := v.Flags()
+
+	if err := f.Parse(args); err != nil {
+		v.UI.Error(err.Error())
+		return 1
+	}
+
+	if v.flagConfigPath == "" {
+		v.UI.Error("no config file location passed")
+		return 1
+	}
+
+	conf := vbConfig.NewVaultBenchmarkCoreConfig()
+	conf.Vars = v.flagVars
+	conf.VarFiles = v.flagVarFiles
+	conf.Prompt = v.UI.AskSecret
+	conf.CheckOnly = true
+
+	// Problems with the options of a config are joined together, while a
+	// config that cannot be read or decoded stops at the first
+	var problems []error
+	if err := conf.LoadConfig(v.flagConfigPath); err != nil {
+		var joined interface{ Unwrap() []error }
+		if !errors.As(err, &joined) {
+			v.UI.Error(fmt.Sprintf("error loading config: %v", err))
+			return 1
+		}
+		problems = joined.Unwrap()
+	}
+	problems = append(problems, validateRunOptions(conf)...)
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			v.UI.Error(problem.Error())
+		}
+		v.UI.Error(fmt.Sprintf("found %d problem(s) with %v", len(problems), v.flagConfigPath))
+		return 1
+	}
+
+	v.UI.Output(fmt.Sprintf("%v is valid", v.flagConfigPath))
+	return 0
+}
+
+// validateRunOptions checks the options of a config which the run command
+// only checks once it starts, such as durations and credentials, without
+// contacting the target
+func validateRunOptions(conf *vbConfig.VaultBenchmarkCoreConfig) []error {
+	var problems []error
+
+	durations := []struct {
+		name  string
+		value string
+	}{
+		{"duration", conf.Duration},
+		{"warmup", conf.Warmup},
+		{"think_time", conf.ThinkTime},
+		{"report_interval", conf.ReportInterval},
+		{"timeseries_interval", conf.SeriesInterval},
+		{"checkpoint_interval", conf.CheckpointIntv},
+		{"pprof_interval", conf.PPROFInterval},
+		{"profile_duration", conf.ProfileLength},
+		{"dns_refresh_interval", conf.DNSRefresh},
+		{"idle_conn_timeout", conf.IdleTimeout},
+		{"tls_handshake_timeout", conf.TLSHandshake},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d.value); err != nil {
+			problems = append(problems, fmt.Errorf("invalid %v: %v", d.name, err))
+		}
+	}
+
+	switch conf.ThinkTimeDist {
+	case benchmarktests.FixedThinkTime, benchmarktests.UniformThinkTime, benchmarktests.ExponentialThinkTime:
+	default:
+		problems = append(problems, fmt.Errorf("think_time_distribution must be one of fixed, uniform or exponential"))
+	}
+
+	switch conf.AttackMode {
+	case benchmarktests.OpenLoopAttackMode:
+	case benchmarktests.ClosedLoopAttackMode:
+		if conf.COCorrection {
+			problems = append(problems, fmt.Errorf("correct_coordinated_omission requires the open attack mode"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("attack_mode must be one of open or closed"))
+	}
+
+	switch conf.Arrival {
+	case benchmarktests.ConstantArrival:
+	case benchmarktests.PoissonArrival:
+		if conf.RPS == 0 {
+			problems = append(problems, fmt.Errorf("poisson arrival requires rps to be set"))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("arrival must be one of constant or poisson"))
+	}
+
+	switch conf.ReportMode {
+	case "terse", "verbose", "json", "csv", "markdown":
+	default:
+		problems = append(problems, fmt.Errorf("report_mode must be one of terse, verbose, json, csv, or markdown"))
+	}
+
+	if conf.Percentiles != "" {
+		if _, err := benchmarktests.ParsePercentiles(conf.Percentiles); err != nil {
+			problems = append(problems, fmt.Errorf("invalid report_percentiles: %v", err))
+		}
+	}
+	if conf.LoadShare != "" {
+		if _, _, err := vbConfig.ParseLoadShare(conf.LoadShare); err != nil {
+			problems = append(problems, fmt.Errorf("invalid load_share: %v", err))
+		}
+	}
+	if conf.StartAt != "" {
+		if _, err := time.Parse(time.RFC3339Nano, conf.StartAt); err != nil {
+			problems = append(problems, fmt.Errorf("invalid start_at: %v", err))
+		}
+	}
+
+	if err := checkLimits(conf); err != nil {
+		problems = append(problems, err)
+	}
+
+	// Each cluster of a comparison may bring its own token
+	hasToken := conf.VaultToken != "" || os.Getenv("VAULT_TOKEN") != "" ||
+		conf.TokenSource != nil || conf.ClusterJSON != "" || conf.DevTarget != ""
+	if !hasToken && len(conf.Clusters) > 0 {
+		hasToken = true
+		for _, cluster := range conf.Clusters {
+			if cluster.VaultToken == "" && cluster.ClusterJSON == "" && cluster.DevTarget == "" {
+				hasToken = false
+			}
+		}
+	}
+	if !hasToken {
+		problems = append(problems, fmt.Errorf("must specify one of the following: cluster_json, vault_token, token_source, or $VAULT_TOKEN"))
+	}
+
+	files := []struct {
+		name string
+		path string
+	}{
+		{"cluster_json", conf.ClusterJSON},
+		{"ca_pem_file", conf.CAPEMFile},
+		{"replay_file", conf.ReplayFile},
+		{"baseline", conf.Baseline},
+	}
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		if _, err := os.Stat(file.path); err != nil {
+			problems = append(problems, fmt.Errorf("invalid %v: %v", file.name, err))
+		}
+	}
+
+	return problems
+}
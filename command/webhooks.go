@@ -0,0 +1,73 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	vbConfig "github.com/openbao/benchmark-openbao/config"
+)
+
+// runWebhooks sends the events of a run to the webhooks of its config,
+// describing the run they are about
+type runWebhooks struct {
+	conf     *vbConfig.VaultBenchmarkCoreConfig
+	webhooks *benchmarktests.Webhooks
+	runID    string
+	targets  []string
+	logger   hclog.Logger
+}
+
+func newRunWebhooks(conf *vbConfig.VaultBenchmarkCoreConfig, runID string, targets []string, logger hclog.Logger) *runWebhooks {
+	return &runWebhooks{
+		conf:     conf,
+		webhooks: benchmarktests.NewWebhooks(conf.Webhooks),
+		runID:    runID,
+		targets:  targets,
+		logger:   logger,
+	}
+}
+
+// notify sends the event to the webhooks. A webhook which fails doesn't
+// fail the run.
+func (w *runWebhooks) notify(event *benchmarktests.WebhookEvent) {
+	event.RunID = w.runID
+	event.Targets = w.targets
+	event.Labels = w.conf.Labels
+	if err := w.webhooks.Send(event); err != nil {
+		w.logger.Warn("error notifying webhooks", "error", hclog.Fmt("%v", err))
+	}
+}
+
+// started notifies the webhooks that the run is starting, for as long as
+// planned when it is known in advance
+func (w *runWebhooks) started(plannedDuration string) {
+	if len(w.conf.Webhooks) == 0 {
+		return
+	}
+	event := &benchmarktests.WebhookEvent{Event: benchmarktests.WebhookStartEvent, Duration: plannedDuration}
+	for _, vbTest := range w.conf.Tests {
+		event.Tests = append(event.Tests, vbTest.Name)
+	}
+	w.notify(event)
+}
+
+// completed notifies the webhooks of the verdict and a summary of the
+// reports of the run once it has ended
+func (w *runWebhooks) completed(verdict *runVerdict, duration time.Duration, current []*benchmarktests.Reporter) {
+	if len(w.conf.Webhooks) == 0 {
+		return
+	}
+	passed := verdict.passed()
+	event := &benchmarktests.WebhookEvent{
+		Event:    benchmarktests.WebhookCompleteEvent,
+		Duration: duration.Round(time.Millisecond).String(),
+		Passed:   &passed,
+		Failures: verdict.failures,
+	}
+	event.SetSummary(current)
+	w.notify(event)
+}
@@ -0,0 +1,341 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	_ cli.Command             = (*WorkerCommand)(nil)
+	_ cli.CommandAutocomplete = (*WorkerCommand)(nil)
+)
+
+// WorkerCommand serves runs of a distributed benchmark to a coordinator
+type WorkerCommand struct {
+	*BaseCommand
+	flagListen          string
+	flagToken           string
+	flagTLSCertFile     string
+	flagTLSKeyFile      string
+	flagTLSClientCAFile string
+}
+
+func (w *WorkerCommand) Synopsis() string {
+	return "Run shares of distributed benchmarks for a coordinator"
+}
+
+func (w *WorkerCommand) Help() string {
+	helpText := `
+Usage: vault-benchmark worker [options]
+
+ This command waits for a coordinator to send it a benchmark configuration,
+ then runs its share of the load and returns the JSON results. Start a worker
+ on each host generating load, then run the coordinator command. Configurations
+ can run commands, so workers reachable from other hosts must serve TLS and
+ authenticate the coordinator with a token or client certificates.
+
+	$ VAULT_BENCHMARK_WORKER_TOKEN=... vault-benchmark worker -listen=0.0.0.0:8210 \
+	    -tls_cert_file=worker.pem -tls_key_file=worker-key.pem
+
+ For a full list of examples, please see the documentation.
+
+` + w.Flags().Help()
+	return strings.TrimSpace(helpText)
+}
+
+func (w *WorkerCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (w *WorkerCommand) AutocompleteFlags() complete.Flags {
+	return w.Flags().Completions()
+}
+
+func (w *WorkerCommand) Flags() *FlagSets {
+	set := w.flagSet()
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:    "listen",
+		Target:  &w.flagListen,
+		Default: "127.0.0.1:8210",
+		Usage:   "Address to serve the coordinator on. Addresses other than loopback addresses require tls_cert_file, and token or tls_client_ca_file.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "token",
+		Target:  &w.flagToken,
+		Default: "",
+		EnvVar:  "VAULT_BENCHMARK_WORKER_TOKEN",
+		Usage:   "Token the coordinator must send with every benchmark.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "tls_cert_file",
+		Target:     &w.flagTLSCertFile,
+		Default:    "",
+		Completion: complete.PredictFiles("*.pem"),
+		Usage:      "Path to a PEM encoded certificate to serve TLS with. Required when listening on an address other than a loopback address.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "tls_key_file",
+		Target:     &w.flagTLSKeyFile,
+		Default:    "",
+		Completion: complete.PredictFiles("*.pem"),
+		Usage:      "Path to the PEM encoded private key of tls_cert_file.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:       "tls_client_ca_file",
+		Target:     &w.flagTLSClientCAFile,
+		Default:    "",
+		Completion: complete.PredictFiles("*.pem"),
+		Usage:      "Path to a PEM encoded CA certificate the coordinator's client certificate must be signed by. Requires tls_cert_file.",
+	})
+	return set
+}
+
+func (w *WorkerCommand) Run(args []string) int {
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:  "vault-benchmark-worker",
+		Level: hclog.Info,
+	})
+
+	f := w.Flags()
+	if err := f.Parse(args); err != nil {
+		w.UI.Error(err.Error())
+		return 1
+	}
+
+	// Configurations can run commands on the worker, such as hooks and
+	// chaos commands, so only the coordinator may send them
+	if w.flagToken == "" && w.flagTLSClientCAFile == "" && !isLoopbackAddr(w.flagListen) {
+		w.UI.Error("listening on an address other than a loopback address requires token or tls_client_ca_file")
+		return 1
+	}
+	// The token and configurations, which may hold secrets, would otherwise
+	// cross the network in plaintext, letting anyone who sees them run
+	// commands on the worker
+	if w.flagTLSCertFile == "" && !isLoopbackAddr(w.flagListen) {
+		w.UI.Error("listening on an address other than a loopback address requires tls_cert_file and tls_key_file")
+		return 1
+	}
+
+	var opts []grpc.ServerOption
+	switch {
+	case w.flagTLSCertFile != "" && w.flagTLSKeyFile != "":
+		tlsConfig, err := workerTLSConfig(w.flagTLSCertFile, w.flagTLSKeyFile, w.flagTLSClientCAFile)
+		if err != nil {
+			w.UI.Error(err.Error())
+			return 1
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	case w.flagTLSCertFile != "" || w.flagTLSKeyFile != "":
+		w.UI.Error("tls_cert_file and tls_key_file must be set together")
+		return 1
+	case w.flagTLSClientCAFile != "":
+		w.UI.Error("tls_client_ca_file requires tls_cert_file and tls_key_file")
+		return 1
+	}
+	if w.flagToken != "" {
+		opts = append(opts, grpc.UnaryInterceptor(benchmarktests.WorkerTokenInterceptor(w.flagToken)))
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		w.UI.Error(fmt.Sprintf("error locating executable: %v", err))
+		return 1
+	}
+
+	l, err := net.Listen("tcp", w.flagListen)
+	if err != nil {
+		w.UI.Error(fmt.Sprintf("error listening: %v", err))
+		return 1
+	}
+
+	opts = append(opts, grpc.MaxRecvMsgSize(benchmarktests.MaxWorkerRequestSize))
+	s := grpc.NewServer(opts...)
+	benchmarktests.RegisterWorkerServer(s, &workerServer{executable: executable, logger: logger})
+	logger.Info("waiting for coordinator", "address", l.Addr().String())
+	if err := s.Serve(l); err != nil {
+		w.UI.Error(fmt.Sprintf("error serving: %v", err))
+		return 1
+	}
+	return 0
+}
+
+// isLoopbackAddr reports whether the host of addr is a loopback address,
+// which only the host itself can reach
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// workerTLSConfig serves TLS with the certificate, and requires clients to
+// present a certificate signed by the client CA when one is given. The
+// server command serves its API with it too.
+func workerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading TLS certificate: %v", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		pem, err := os.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading TLS client CA certificate: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %v", clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// workerServer runs each share of a benchmark as a run command of its own,
+// so runs start from a clean state. Only one share is run at a time.
+type workerServer struct {
+	executable string
+	logger     hclog.Logger
+	running    sync.Mutex
+}
+
+func (s *workerServer) Run(ctx context.Context, req *benchmarktests.WorkerRunRequest) (*benchmarktests.WorkerRunResponse, error) {
+	if !s.running.TryLock() {
+		return nil, status.Error(codes.Unavailable, "worker is already running a benchmark")
+	}
+	defer s.running.Unlock()
+
+	dir, err := os.MkdirTemp("", "vault-benchmark-worker")
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "error creating config directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	runDir, err := writeWorkerBundle(dir, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("running share of benchmark", "part", req.Part, "parts", req.Parts, "start_at", req.StartAt.Format(time.RFC3339Nano))
+	cmd := exec.CommandContext(ctx, s.executable, workerRunArgs(req)...)
+	cmd.Dir = runDir
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr) && ctx.Err() == nil:
+		s.logger.Warn("benchmark failed", "exit_code", exitErr.ExitCode())
+		return &benchmarktests.WorkerRunResponse{Results: stdout.Bytes(), ExitCode: exitErr.ExitCode()}, nil
+	default:
+		return nil, status.Errorf(codes.Internal, "error running benchmark: %v", err)
+	}
+	s.logger.Info("benchmark complete")
+	return &benchmarktests.WorkerRunResponse{Results: stdout.Bytes()}, nil
+}
+
+// writeWorkerBundle writes the config and the files it reads below dir,
+// returning the directory the run is started in. Every path must stay below
+// dir, so a request can't write elsewhere on the worker.
+func writeWorkerBundle(dir string, req *benchmarktests.WorkerRunRequest) (string, error) {
+	runDir := filepath.FromSlash(req.Dir)
+	if !filepath.IsLocal(runDir) {
+		return "", status.Errorf(codes.InvalidArgument, "invalid run directory %q", req.Dir)
+	}
+	runDir = filepath.Join(dir, runDir)
+	for _, path := range append([]string{req.Config}, req.VarFiles...) {
+		if !isWithin(dir, filepath.Join(runDir, filepath.FromSlash(path))) {
+			return "", status.Errorf(codes.InvalidArgument, "invalid config path %q", path)
+		}
+	}
+	if err := os.MkdirAll(runDir, 0o700); err != nil {
+		return "", status.Errorf(codes.Internal, "error creating run directory: %v", err)
+	}
+	for name, contents := range req.Files {
+		path := filepath.FromSlash(name)
+		if !filepath.IsLocal(path) {
+			return "", status.Errorf(codes.InvalidArgument, "invalid file path %q", name)
+		}
+		path = filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return "", status.Errorf(codes.Internal, "error creating directory of %v: %v", name, err)
+		}
+		if err := os.WriteFile(path, contents, 0o600); err != nil {
+			return "", status.Errorf(codes.Internal, "error writing %v: %v", name, err)
+		}
+	}
+	return runDir, nil
+}
+
+// workerRunArgs are the arguments of the run command for a share of a
+// benchmark, which is started in the run directory of its bundle. Results
+// are returned to the coordinator, which compares and records the merged
+// results, so the outputs doing so are disabled here.
+func workerRunArgs(req *benchmarktests.WorkerRunRequest) []string {
+	args := []string{
+		"run",
+		"-config=" + req.Config,
+	}
+	for _, varFile := range req.VarFiles {
+		args = append(args, "-var_file="+varFile)
+	}
+	names := make([]string, 0, len(req.Vars))
+	for name := range req.Vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-var="+name+"="+req.Vars[name])
+	}
+	return append(args,
+		"-report_mode=json",
+		fmt.Sprintf("-load_share=%d/%d", req.Part, req.Parts),
+		"-start_at="+req.StartAt.Format(time.RFC3339Nano),
+		"-baseline=",
+		"-history_db=",
+		"-junit_file=",
+		"-live=false",
+	)
+}
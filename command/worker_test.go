@@ -0,0 +1,31 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package command
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mitchellh/cli"
+)
+
+func TestWorkerCommand_RequiresTLSOffLoopback(t *testing.T) {
+	cases := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-listen=0.0.0.0:0"}, "requires token or tls_client_ca_file"},
+		{[]string{"-listen=0.0.0.0:0", "-token=secret"}, "requires tls_cert_file and tls_key_file"},
+	}
+	for _, tc := range cases {
+		ui := cli.NewMockUi()
+		cmd := &WorkerCommand{BaseCommand: &BaseCommand{UI: ui}}
+		if code := cmd.Run(tc.args); code != 1 {
+			t.Fatalf("expected the worker to refuse to start with %v, got %d", tc.args, code)
+		}
+		if !strings.Contains(ui.ErrorWriter.String(), tc.err) {
+			t.Fatalf("unexpected error with %v: %v", tc.args, ui.ErrorWriter.String())
+		}
+	}
+}
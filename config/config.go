@@ -4,8 +4,17 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
@@ -21,13 +30,33 @@ const (
 	DefaultRandomMounts = true
 	DefaultCleanup      = false
 	DefaultLogLevel     = "INFO"
+	DefaultAttackMode   = "open"
+	DefaultArrival      = "constant"
+
+	DefaultThinkTimeDistribution = "fixed"
+	DefaultKubernetesPort        = 8200
+	DefaultNodeRefresh           = 30 * time.Second
+
+	SequentialClusterMode = "sequential"
+	ConcurrentClusterMode = "concurrent"
 )
 
 type VaultBenchmarkCoreConfig struct {
 	Remain         hcl.Body                          `hcl:",remain"`
 	VaultAddr      string                            `hcl:"vault_addr,optional"`
+	VaultAddrs     []string                          `hcl:"vault_addrs,optional"`
+	LoadBalance    string                            `hcl:"load_balance,optional"`
+	LBWeights      map[string]int                    `hcl:"load_balance_weights,optional"`
+	ReadAddr       string                            `hcl:"read_addr,optional"`
+	NodeHeader     string                            `hcl:"node_header,optional"`
+	ProxyAddr      string                            `hcl:"proxy_addr,optional"`
+	HTTPProxy      string                            `hcl:"http_proxy,optional"`
+	DevTarget      string                            `hcl:"dev_target,optional"`
+	ClusterMode    string                            `hcl:"cluster_mode,optional"`
 	VaultToken     string                            `hcl:"vault_token,optional"`
 	VaultNamespace string                            `hcl:"vault_namespace,optional"`
+	TokenType      string                            `hcl:"attack_token_type,optional"`
+	TokenPolicies  []string                          `hcl:"attack_token_policies,optional"`
 	Duration       string                            `hcl:"duration,optional"`
 	ReportMode     string                            `hcl:"report_mode,optional"`
 	AuditPath      string                            `hcl:"audit_path,optional"`
@@ -35,15 +64,250 @@ type VaultBenchmarkCoreConfig struct {
 	ClusterJSON    string                            `hcl:"cluster_json,optional"`
 	CAPEMFile      string                            `hcl:"ca_pem_file,optional"`
 	PPROFInterval  string                            `hcl:"pprof_interval,optional"`
+	ProfileDir     string                            `hcl:"profile_dir,optional"`
+	ProfileAt      string                            `hcl:"profile_at,optional"`
+	ProfileLength  string                            `hcl:"profile_duration,optional"`
+	ResourceURL    string                            `hcl:"resource_metrics_url,optional"`
 	LogLevel       string                            `hcl:"log_level,optional"`
+	AttackMode     string                            `hcl:"attack_mode,optional"`
+	ThinkTime      string                            `hcl:"think_time,optional"`
+	ThinkTimeDist  string                            `hcl:"think_time_distribution,optional"`
+	Arrival        string                            `hcl:"arrival,optional"`
+	Warmup         string                            `hcl:"warmup,optional"`
+	ReportInterval string                            `hcl:"report_interval,optional"`
+	DNSRefresh     string                            `hcl:"dns_refresh_interval,optional"`
+	IdleTimeout    string                            `hcl:"idle_conn_timeout,optional"`
+	TLSHandshake   string                            `hcl:"tls_handshake_timeout,optional"`
+	SeriesInterval string                            `hcl:"timeseries_interval,optional"`
+	IntervalFile   string                            `hcl:"report_interval_file,optional"`
+	RemoteWriteURL string                            `hcl:"remote_write_url,optional"`
+	RunID          string                            `hcl:"run_id,optional"`
+	InfluxFile     string                            `hcl:"influx_file,optional"`
+	InfluxURL      string                            `hcl:"influx_url,optional"`
+	InfluxToken    string                            `hcl:"influx_token,optional"`
+	ResultLog      string                            `hcl:"result_log,optional"`
+	OTLPEndpoint   string                            `hcl:"otlp_metrics_endpoint,optional"`
+	OTLPProtocol   string                            `hcl:"otlp_metrics_protocol,optional"`
+	OTLPTraces     string                            `hcl:"otlp_traces_endpoint,optional"`
+	OTLPTraceProto string                            `hcl:"otlp_traces_protocol,optional"`
+	ReplayFile     string                            `hcl:"replay_file,optional"`
+	Baseline       string                            `hcl:"baseline,optional"`
+	HistoryDB      string                            `hcl:"history_db,optional"`
+	JUnitFile      string                            `hcl:"junit_file,optional"`
+	Percentiles    string                            `hcl:"report_percentiles,optional"`
+	LoadShare      string                            `hcl:"load_share,optional"`
+	StartAt        string                            `hcl:"start_at,optional"`
+	Checkpoint     string                            `hcl:"checkpoint_file,optional"`
+	CheckpointIntv string                            `hcl:"checkpoint_interval,optional"`
+	Labels         map[string]string                 `hcl:"labels,optional"`
 	Tests          []*benchmarktests.BenchmarkTarget `hcl:"test,block"`
+	Phases         []*PhaseConfig                    `hcl:"phase,block"`
+	Dependencies   []*DependencyConfig               `hcl:"dependency,block"`
+	Search         *SearchConfig                     `hcl:"throughput_search,block"`
+	Burst          *BurstConfig                      `hcl:"burst,block"`
+	Kubernetes     *KubernetesConfig                 `hcl:"kubernetes,block"`
+	NodeDiscovery  *NodeDiscoveryConfig              `hcl:"node_discovery,block"`
+	TokenSource    *benchmarktests.TokenSourceConfig `hcl:"token_source,block"`
+	Chaos          []*benchmarktests.ChaosConfig     `hcl:"chaos,block"`
+	Failover       *benchmarktests.FailoverConfig    `hcl:"failover,block"`
+	Snapshot       *benchmarktests.SnapshotConfig    `hcl:"raft_snapshot,block"`
+	Clusters       []*ClusterConfig                  `hcl:"cluster,block"`
+	ErrorBudget    *benchmarktests.ErrorBudgetConfig `hcl:"error_budget,block"`
+	Regression     *benchmarktests.RegressionConfig  `hcl:"regression,block"`
+	Plugins        []*benchmarktests.PluginConfig    `hcl:"plugin,block"`
+	Webhooks       []*benchmarktests.WebhookConfig   `hcl:"webhook,block"`
+	BeforeRun      []*benchmarktests.HookConfig      `hcl:"before_run,block"`
+	AfterRun       []*benchmarktests.HookConfig      `hcl:"after_run,block"`
 	RPS            int                               `hcl:"rps,optional"`
+	Requests       int                               `hcl:"requests,optional"`
 	Workers        int                               `hcl:"workers,optional"`
+	MaxInFlight    int                               `hcl:"max_in_flight,optional"`
+	TokenPool      int                               `hcl:"token_pool,optional"`
+	Namespaces     int                               `hcl:"namespace_fanout,optional"`
+	ResultLogMaxMB int                               `hcl:"result_log_max_size_mb,optional"`
+	ResultLogFiles *int                              `hcl:"result_log_max_files,optional"`
+	ErrorSamples   *int                              `hcl:"error_samples,optional"`
+	MaxIdlePerHost int                               `hcl:"max_idle_conns_per_host,optional"`
+	WorkerConns    int                               `hcl:"worker_connections,optional"`
+	TraceSampling  float64                           `hcl:"trace_sample_ratio,optional"`
 	RandomMounts   bool                              `hcl:"random_mounts,optional"`
 	InputResults   bool                              `hcl:"input_results,optional"`
 	Cleanup        bool                              `hcl:"cleanup,optional"`
 	Debug          bool                              `hcl:"debug,optional"`
 	DisableHTTP2   bool                              `hcl:"disable_http2,optional"`
+	ForceHTTP2     bool                              `hcl:"force_http2,optional"`
+	COCorrection   bool                              `hcl:"correct_coordinated_omission,optional"`
+	FollowRedirect bool                              `hcl:"follow_redirects,optional"`
+	Live           bool                              `hcl:"live,optional"`
+
+	// Includes are the paths of the files included by the config, and
+	// FilesRead those of the files it reads with the file function
+	Includes  []string
+	FilesRead []string
+
+	// Vars are the values of variables given with -var and VarFiles the
+	// paths of the files given with -var-file, set before loading the config
+	Vars     map[string]string
+	VarFiles []string
+
+	// Prompt, when set before loading the config, asks for the values of
+	// the prompt function without echoing them
+	Prompt func(query string) (string, error)
+
+	// CheckOnly, when set before loading the config, loads it only to
+	// check it, without contacting OpenBao: the kv function returns a
+	// placeholder rather than reading the secret
+	CheckOnly bool
+}
+
+// PhaseConfig describes one stage of a multi-phase run. Phases are run
+// one after another, each attacking the listed tests (or all tests when
+// none are listed) for its own duration. Rate and workers fall back to the
+// global values when not set.
+type PhaseConfig struct {
+	Name     string   `hcl:"name,label"`
+	Duration string   `hcl:"duration"`
+	RPS      *int     `hcl:"rps,optional"`
+	Workers  int      `hcl:"workers,optional"`
+	Tests    []string `hcl:"tests,optional"`
+}
+
+// SearchConfig enables the throughput search mode, which looks for the
+// highest rate at which the p99 latency and success ratio objectives still
+// hold instead of attacking at a fixed rate
+type SearchConfig struct {
+	MaxP99          string   `hcl:"max_p99"`
+	MinSuccessRatio *float64 `hcl:"min_success_ratio,optional"`
+	MinRPS          int      `hcl:"min_rps,optional"`
+	MaxRPS          int      `hcl:"max_rps"`
+	StepDuration    string   `hcl:"step_duration,optional"`
+	Tolerance       int      `hcl:"tolerance,optional"`
+}
+
+// BurstConfig adds periodic spikes of extra load on top of the base rate.
+// Every interval, requests are sent at the additional burst rate for the
+// burst duration.
+type BurstConfig struct {
+	RPS      int    `hcl:"rps"`
+	Duration string `hcl:"duration"`
+	Interval string `hcl:"interval"`
+}
+
+// KubernetesConfig discovers the OpenBao nodes to benchmark in a
+// Kubernetes cluster, either the ready pods matching a label selector or a
+// service in front of them
+type KubernetesConfig struct {
+	Selector   string `hcl:"selector,optional"`
+	Service    string `hcl:"service,optional"`
+	Namespace  string `hcl:"namespace,optional"`
+	Port       int    `hcl:"port,optional"`
+	Scheme     string `hcl:"scheme,optional"`
+	Kubeconfig string `hcl:"kubeconfig,optional"`
+	Context    string `hcl:"context,optional"`
+}
+
+// Validate checks that exactly one of a selector or service is given and
+// fills in the default port and scheme
+func (k *KubernetesConfig) Validate() error {
+	if (k.Selector == "") == (k.Service == "") {
+		return fmt.Errorf("exactly one of selector or service must be set")
+	}
+	if k.Port == 0 {
+		k.Port = DefaultKubernetesPort
+	}
+	if k.Port < 0 || k.Port > 65535 {
+		return fmt.Errorf("invalid port: %d", k.Port)
+	}
+	switch k.Scheme {
+	case "":
+		k.Scheme = "http"
+	case "http", "https":
+	default:
+		return fmt.Errorf("scheme must be one of http or https")
+	}
+	return nil
+}
+
+// NodeDiscoveryConfig discovers the nodes of the cluster from the target
+// address before the attack, with sys/ha-status or the Raft configuration,
+// and balances the attack across them. The nodes are discovered again
+// every refresh interval, so nodes joining or leaving during long runs are
+// followed.
+type NodeDiscoveryConfig struct {
+	RefreshInterval string `hcl:"refresh_interval,optional"`
+}
+
+// Validate checks the refresh interval of the discovery
+func (c *NodeDiscoveryConfig) Validate() error {
+	_, err := c.Refresh()
+	return err
+}
+
+// Refresh returns how often the nodes are discovered again, zero when they
+// are only discovered before the attack
+func (c *NodeDiscoveryConfig) Refresh() (time.Duration, error) {
+	if c.RefreshInterval == "" {
+		return DefaultNodeRefresh, nil
+	}
+	refresh, err := time.ParseDuration(c.RefreshInterval)
+	if err != nil || (refresh != 0 && refresh < time.Second) {
+		return 0, fmt.Errorf("invalid refresh_interval %q: must be 0 or a duration of at least 1s", c.RefreshInterval)
+	}
+	return refresh, nil
+}
+
+// ClusterConfig is a named cluster to run the tests of the config against,
+// so clusters such as ones with different storage backends can be
+// compared. Exactly one way of reaching the cluster is given.
+type ClusterConfig struct {
+	Name        string            `hcl:"name,label"`
+	VaultAddr   string            `hcl:"vault_addr,optional"`
+	VaultAddrs  []string          `hcl:"vault_addrs,optional"`
+	ClusterJSON string            `hcl:"cluster_json,optional"`
+	DevTarget   string            `hcl:"dev_target,optional"`
+	VaultToken  string            `hcl:"vault_token,optional"`
+	Labels      map[string]string `hcl:"labels,optional"`
+}
+
+// Validate checks that exactly one of an address, addresses, cluster JSON
+// file or dev server is given
+func (c *ClusterConfig) Validate() error {
+	targets := 0
+	for _, set := range []bool{c.VaultAddr != "", len(c.VaultAddrs) > 0, c.ClusterJSON != "", c.DevTarget != ""} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("exactly one of vault_addr, vault_addrs, cluster_json or dev_target must be set")
+	}
+	return nil
+}
+
+// ApplyCluster targets the run at the named cluster of the config, in
+// place of the global target, and labels its results with the name of the
+// cluster
+func (c *VaultBenchmarkCoreConfig) ApplyCluster(name string) error {
+	i := slices.IndexFunc(c.Clusters, func(cluster *ClusterConfig) bool { return cluster.Name == name })
+	if i == -1 {
+		return fmt.Errorf("no cluster named %v", name)
+	}
+	cluster := c.Clusters[i]
+	c.VaultAddr = cluster.VaultAddr
+	c.VaultAddrs = cluster.VaultAddrs
+	c.ClusterJSON = cluster.ClusterJSON
+	c.DevTarget = cluster.DevTarget
+	if cluster.VaultToken != "" {
+		c.VaultToken = cluster.VaultToken
+	}
+
+	labels := make(map[string]string, len(c.Labels)+len(cluster.Labels)+1)
+	maps.Copy(labels, c.Labels)
+	maps.Copy(labels, cluster.Labels)
+	labels["cluster"] = name
+	c.Labels = labels
+	c.Clusters = nil
+	return nil
 }
 
 func NewVaultBenchmarkCoreConfig() *VaultBenchmarkCoreConfig {
@@ -56,11 +320,15 @@ func NewVaultBenchmarkCoreConfig() *VaultBenchmarkCoreConfig {
 		RandomMounts: DefaultRandomMounts,
 		Cleanup:      DefaultCleanup,
 		LogLevel:     DefaultLogLevel,
+		AttackMode:   DefaultAttackMode,
+		Arrival:      DefaultArrival,
+
+		ThinkTimeDist: DefaultThinkTimeDistribution,
 	}
 }
 
 // LoadConfig populates a VaultBenchmarkCoreConfig struct from the
-// passed in HCL config file
+// passed in HCL or JSON config file
 func (c *VaultBenchmarkCoreConfig) LoadConfig(path string) error {
 	var fileBuf []byte
 
@@ -76,47 +344,408 @@ func (c *VaultBenchmarkCoreConfig) LoadConfig(path string) error {
 
 	err := ParseConfig(fileBuf, path, c)
 	if err != nil {
-		return fmt.Errorf("failed to parse config: %v", err)
+		return fmt.Errorf("failed to parse config: %w", err)
 	}
 	return nil
 }
 
+// ReferencedFiles returns the paths of the files the loaded config reads
+// besides itself: the files it includes, its var files, the files read with
+// the file function, its replay file and the files its tests read, such as
+// data files and scripts. Relative paths are relative to the working
+// directory. Tests with a credential helper aren't parsed until a run sets
+// them up, so the files they read aren't known.
+func (c *VaultBenchmarkCoreConfig) ReferencedFiles() []string {
+	files := slices.Concat(c.Includes, c.VarFiles, c.FilesRead)
+	if c.ReplayFile != "" {
+		files = append(files, c.ReplayFile)
+	}
+	for _, vbTest := range c.Tests {
+		if reader, ok := vbTest.Builder.(benchmarktests.FileReader); ok {
+			files = append(files, reader.Files()...)
+		}
+	}
+	for i, file := range files {
+		files[i] = filepath.Clean(file)
+	}
+	slices.Sort(files)
+	return slices.Compact(files)
+}
+
 func ParseConfig(hclBuf []byte, pathName string, configStruct *VaultBenchmarkCoreConfig) error {
-	// HCL V2 Parsing
+	// HCL V2 Parsing. Configs with a .json extension are parsed as the
+	// JSON syntax of HCL, so they can be generated by other tools.
 	parser := hclparse.NewParser()
-	confFile, confDiags := parser.ParseHCL(hclBuf, pathName)
+	confFile, confDiags := parseFile(parser, hclBuf, pathName)
 	if confDiags.HasErrors() {
 		return fmt.Errorf("error parsing hcl: %v", confDiags)
 	}
 
+	// Merge in the files the config includes, with environment variables
+	// and functions available to the expressions of every file
+	configStruct.Includes = nil
+	configStruct.FilesRead = nil
+	evalCtx := newEvalContext(filepath.Dir(pathName), configStruct.Prompt, configStruct.CheckOnly, &configStruct.FilesRead)
+	seen := make(map[string]bool)
+	if abs, err := filepath.Abs(pathName); err == nil {
+		seen[abs] = true
+	}
+	body, err := includeFiles(parser, confFile, pathName, evalCtx, seen, &configStruct.Includes, &configStruct.FilesRead)
+	if err != nil {
+		return err
+	}
+
+	// Variables are evaluated first, as the rest of the config refers to
+	// them
+	body, err = declareVariables(body, evalCtx, configStruct.Vars, configStruct.VarFiles, &configStruct.FilesRead)
+	if err != nil {
+		return err
+	}
+
 	// Decode HCL Body into Core Config Struct
-	moreDiags := gohcl.DecodeBody(confFile.Body, nil, configStruct)
+	moreDiags := gohcl.DecodeBody(body, nil, configStruct)
 	if moreDiags.HasErrors() {
-		return fmt.Errorf("error decoding hcl: %v", confDiags)
+		return fmt.Errorf("error decoding hcl: %v", moreDiags)
+	}
+
+	// Only check the plugins here, they are started by StartPlugins once a
+	// run sets the tests up
+	if err := checkPlugins(configStruct.Plugins); err != nil {
+		return err
+	}
+
+	// Plan the containers of any dependencies so test configs can refer to
+	// their connection details
+	if err := planDependencies(configStruct.Dependencies, evalCtx); err != nil {
+		return err
+	}
+
+	return errors.Join(validateConfig(configStruct)...)
+}
+
+// checkPlugins checks the plugins of the config and that their commands
+// can be found, without starting them
+func checkPlugins(configs []*benchmarktests.PluginConfig) error {
+	names := make(map[string]bool, len(configs))
+	for _, plugin := range configs {
+		if names[plugin.Name] {
+			return fmt.Errorf("plugin %v declared more than once", plugin.Name)
+		}
+		names[plugin.Name] = true
+		if err := plugin.Validate(); err != nil {
+			return fmt.Errorf("invalid plugin %v: %v", plugin.Name, err)
+		}
+		if _, err := exec.LookPath(plugin.Command[0]); err != nil {
+			return fmt.Errorf("invalid plugin %v: %v", plugin.Name, err)
+		}
 	}
+	return nil
+}
+
+// StartPlugins starts the plugins of the config and parses the config of
+// every test of a type they provide. Runs call it before setting the tests
+// up, so checking a config doesn't start them. The plugins are stopped with
+// benchmarktests.StopPlugins.
+func (c *VaultBenchmarkCoreConfig) StartPlugins() error {
+	if err := benchmarktests.RegisterPlugins(c.Plugins); err != nil {
+		return err
+	}
+	for _, vbTest := range c.Tests {
+		if vbTest.Builder != nil || vbTest.CredentialHelper != "" {
+			continue
+		}
+		currTest, ok := benchmarktests.TestList[vbTest.Type]
+		if !ok {
+			return fmt.Errorf("invalid test type found: %v", vbTest.Type)
+		}
+		currBuilder := currTest()
+		if err := currBuilder.ParseConfig(vbTest.Remain); err != nil {
+			return fmt.Errorf("invalid config for test %v: %w", vbTest.Name, err)
+		}
+		vbTest.Builder = currBuilder
+	}
+	return nil
+}
+
+// validateConfig parses the config of every test and checks the options of
+// the config, returning every problem found rather than only the first, so
+// they can all be fixed at once
+func validateConfig(configStruct *VaultBenchmarkCoreConfig) []error {
+	var problems []error
 
 	// Check to see if we have more than one Cert auth and fail if we do
 	if moreThanOneTest(configStruct.Tests, benchmarktests.CertAuthTestType) {
-		return fmt.Errorf("only one cert auth test supported")
+		problems = append(problems, fmt.Errorf("only one cert auth test supported"))
 	}
 
 	// Loop through all found tests and check if they are part of the test list
 	// then parse each test config based on provided test structs
 	for _, vbTest := range configStruct.Tests {
-		if currTest, ok := benchmarktests.TestList[vbTest.Type]; ok {
-			currBuilder := currTest()
-			err := currBuilder.ParseConfig(vbTest.Remain)
-			if err != nil {
-				return err
-			}
-			vbTest.Builder = currBuilder
-		} else {
-			return fmt.Errorf("invalid test type found: %v", vbTest.Type)
+		if err := parseTest(vbTest, len(configStruct.Plugins) > 0); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if configStruct.Search != nil && len(configStruct.Phases) > 0 {
+		problems = append(problems, fmt.Errorf("throughput_search cannot be combined with phases"))
+	}
+	if configStruct.Search != nil && configStruct.Burst != nil {
+		problems = append(problems, fmt.Errorf("throughput_search cannot be combined with burst"))
+	}
+	if configStruct.ErrorBudget != nil {
+		if configStruct.Search != nil {
+			problems = append(problems, fmt.Errorf("throughput_search cannot be combined with error_budget"))
+		}
+		if err := configStruct.ErrorBudget.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid error_budget: %v", err))
+		}
+	}
+	if configStruct.Regression != nil {
+		if err := configStruct.Regression.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid regression: %v", err))
+		}
+	}
+	if configStruct.Kubernetes != nil {
+		if err := configStruct.Kubernetes.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid kubernetes: %v", err))
+		}
+	}
+	if configStruct.NodeDiscovery != nil {
+		if configStruct.Kubernetes != nil || configStruct.DevTarget != "" {
+			problems = append(problems, fmt.Errorf("node_discovery cannot be combined with kubernetes or dev_target"))
+		}
+		if err := configStruct.NodeDiscovery.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid node_discovery: %v", err))
+		}
+	}
+	if configStruct.TokenSource != nil {
+		if configStruct.DevTarget != "" {
+			problems = append(problems, fmt.Errorf("token_source cannot be combined with dev_target"))
+		}
+		if err := configStruct.TokenSource.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid token_source %v: %v", configStruct.TokenSource.Type, err))
+		}
+	}
+	if configStruct.Kubernetes != nil && len(configStruct.Clusters) > 0 {
+		problems = append(problems, fmt.Errorf("kubernetes cannot be combined with clusters"))
+	}
+	clusterNames := make(map[string]bool, len(configStruct.Clusters))
+	for _, cluster := range configStruct.Clusters {
+		if clusterNames[cluster.Name] {
+			problems = append(problems, fmt.Errorf("cluster %v declared more than once", cluster.Name))
+		}
+		clusterNames[cluster.Name] = true
+		if err := cluster.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid cluster %v: %v", cluster.Name, err))
+		}
+	}
+	switch configStruct.ClusterMode {
+	case "", SequentialClusterMode, ConcurrentClusterMode:
+	default:
+		problems = append(problems, fmt.Errorf("cluster_mode must be one of %v or %v", SequentialClusterMode, ConcurrentClusterMode))
+	}
+	if configStruct.Search != nil && len(configStruct.Chaos) > 0 {
+		problems = append(problems, fmt.Errorf("throughput_search cannot be combined with chaos"))
+	}
+	chaosNames := make(map[string]bool, len(configStruct.Chaos))
+	for _, c := range configStruct.Chaos {
+		if chaosNames[c.Name] {
+			problems = append(problems, fmt.Errorf("chaos %v declared more than once", c.Name))
+		}
+		chaosNames[c.Name] = true
+		if err := c.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid chaos %v: %v", c.Name, err))
+		}
+	}
+	webhookNames := make(map[string]bool, len(configStruct.Webhooks))
+	for _, webhook := range configStruct.Webhooks {
+		if webhookNames[webhook.Name] {
+			problems = append(problems, fmt.Errorf("webhook %v declared more than once", webhook.Name))
+		}
+		webhookNames[webhook.Name] = true
+		if err := webhook.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid webhook %v: %v", webhook.Name, err))
+		}
+	}
+	for _, hook := range configStruct.BeforeRun {
+		if err := hook.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid before_run hook: %v", err))
+		}
+	}
+	for _, hook := range configStruct.AfterRun {
+		if err := hook.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid after_run hook: %v", err))
+		}
+	}
+	if configStruct.Failover != nil {
+		if configStruct.Search != nil {
+			problems = append(problems, fmt.Errorf("throughput_search cannot be combined with failover"))
+		}
+		if chaosNames[benchmarktests.FailoverEvent] {
+			problems = append(problems, fmt.Errorf("chaos %v conflicts with the failover scenario", benchmarktests.FailoverEvent))
+		}
+		if err := configStruct.Failover.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid failover: %v", err))
+		}
+	}
+	if configStruct.Snapshot != nil {
+		if configStruct.Search != nil {
+			problems = append(problems, fmt.Errorf("throughput_search cannot be combined with raft_snapshot"))
+		}
+		if chaosNames[benchmarktests.SnapshotEvent] {
+			problems = append(problems, fmt.Errorf("chaos %v conflicts with the raft_snapshot scenario", benchmarktests.SnapshotEvent))
+		}
+		if err := configStruct.Snapshot.Validate(); err != nil {
+			problems = append(problems, fmt.Errorf("invalid raft_snapshot: %v", err))
+		}
+	}
+
+	if err := configStruct.CheckDuration(); err != nil {
+		problems = append(problems, err)
+	}
+
+	return append(problems, validatePhases(configStruct)...)
+}
+
+// CheckDuration checks the attack ends: a zero duration is only allowed
+// when it sends a number of requests, or when phases, a throughput search
+// or a replay file decide how long it lasts instead, or when every test
+// has a duration or number of requests of its own
+func (c *VaultBenchmarkCoreConfig) CheckDuration() error {
+	if c.Requests > 0 || len(c.Phases) > 0 || c.Search != nil || c.ReplayFile != "" {
+		return nil
+	}
+	if duration, err := time.ParseDuration(c.Duration); err != nil || duration > 0 {
+		return nil
+	}
+	for _, vbTest := range c.Tests {
+		if vbTest.Duration == "" && vbTest.Requests == 0 {
+			return fmt.Errorf("duration must be greater than zero unless requests is set")
 		}
 	}
 	return nil
 }
 
+// parseTest parses the config of the test with the builder of its type and
+// checks its test options. With plugins, a type that isn't built in is
+// left to the plugins, and its config is parsed by StartPlugins.
+func parseTest(vbTest *benchmarktests.BenchmarkTarget, plugins bool) error {
+	currTest, ok := benchmarktests.TestList[vbTest.Type]
+	if !ok && !plugins {
+		return fmt.Errorf("invalid test type found: %v", vbTest.Type)
+	}
+	// The config of a test with a credential helper is parsed by
+	// ResolveCredentials once a run sets it up, so checking the config
+	// doesn't run the helper
+	var currBuilder benchmarktests.BenchmarkBuilder
+	if ok && vbTest.CredentialHelper == "" {
+		currBuilder = currTest()
+		if err := currBuilder.ParseConfig(vbTest.Remain); err != nil {
+			return fmt.Errorf("invalid config for test %v: %w", vbTest.Name, err)
+		}
+	}
+	if _, err := vbTest.WarmupDuration(); err != nil {
+		return fmt.Errorf("invalid warmup for test %v: %v", vbTest.Name, err)
+	}
+	if _, err := vbTest.AttackDuration(); err != nil {
+		return fmt.Errorf("invalid duration for test %v: %v", vbTest.Name, err)
+	}
+	if _, err := vbTest.StartOffset(); err != nil {
+		return fmt.Errorf("invalid start_offset for test %v: %v", vbTest.Name, err)
+	}
+	if _, err := vbTest.ThinkTimeDuration(); err != nil {
+		return fmt.Errorf("invalid think_time for test %v: %v", vbTest.Name, err)
+	}
+	if _, err := vbTest.RequestPolicy(); err != nil {
+		return fmt.Errorf("invalid request policy for test %v: %v", vbTest.Name, err)
+	}
+	if vbTest.Requests < 0 {
+		return fmt.Errorf("invalid requests for test %v: must not be negative", vbTest.Name)
+	}
+	if vbTest.Workers < 0 {
+		return fmt.Errorf("invalid workers for test %v: must not be negative", vbTest.Name)
+	}
+	if vbTest.ErrorBudget != nil {
+		if err := vbTest.ErrorBudget.Validate(); err != nil {
+			return fmt.Errorf("invalid error_budget for test %v: %v", vbTest.Name, err)
+		}
+	}
+	if vbTest.Regression != nil {
+		if err := vbTest.Regression.Validate(); err != nil {
+			return fmt.Errorf("invalid regression for test %v: %v", vbTest.Name, err)
+		}
+	}
+	for _, slo := range vbTest.SLOs {
+		if err := slo.Validate(); err != nil {
+			return fmt.Errorf("invalid slo for test %v: %v", vbTest.Name, err)
+		}
+	}
+	if vbTest.Expect != nil {
+		if err := vbTest.Expect.Validate(); err != nil {
+			return fmt.Errorf("invalid expect for test %v: %v", vbTest.Name, err)
+		}
+	}
+	for _, hook := range vbTest.Before {
+		if err := hook.Validate(); err != nil {
+			return fmt.Errorf("invalid before hook for test %v: %v", vbTest.Name, err)
+		}
+	}
+	for _, hook := range vbTest.After {
+		if err := hook.Validate(); err != nil {
+			return fmt.Errorf("invalid after hook for test %v: %v", vbTest.Name, err)
+		}
+	}
+	if vbTest.TLS != nil {
+		if err := vbTest.TLS.Validate(); err != nil {
+			return fmt.Errorf("invalid tls for test %v: %v", vbTest.Name, err)
+		}
+	}
+	if err := benchmarktests.ValidateHeaders(vbTest.Headers); err != nil {
+		return fmt.Errorf("invalid headers for test %v: %v", vbTest.Name, err)
+	}
+	if vbTest.TokenSource != nil {
+		if vbTest.Token != "" {
+			return fmt.Errorf("only one of token or token_source may be set for test %v", vbTest.Name)
+		}
+		if err := vbTest.TokenSource.Validate(); err != nil {
+			return fmt.Errorf("invalid token_source %v for test %v: %v", vbTest.TokenSource.Type, vbTest.Name, err)
+		}
+	}
+	if currBuilder != nil {
+		vbTest.Builder = currBuilder
+	}
+	return nil
+}
+
+// validatePhases checks that phase names are unique and that every test a
+// phase enables is defined in the config
+func validatePhases(configStruct *VaultBenchmarkCoreConfig) []error {
+	testNames := make(map[string]struct{}, len(configStruct.Tests))
+	for _, vbTest := range configStruct.Tests {
+		testNames[vbTest.Name] = struct{}{}
+	}
+
+	var problems []error
+	phaseNames := make(map[string]struct{}, len(configStruct.Phases))
+	for _, phase := range configStruct.Phases {
+		if _, ok := phaseNames[phase.Name]; ok {
+			problems = append(problems, fmt.Errorf("duplicate phase found: %v", phase.Name))
+		}
+		phaseNames[phase.Name] = struct{}{}
+
+		if _, err := time.ParseDuration(phase.Duration); err != nil {
+			problems = append(problems, fmt.Errorf("invalid duration for phase %v: %v", phase.Name, err))
+		}
+
+		for _, name := range phase.Tests {
+			if _, ok := testNames[name]; !ok {
+				problems = append(problems, fmt.Errorf("phase %v references unknown test: %v", phase.Name, name))
+			}
+		}
+	}
+	return problems
+}
+
 // moreThanOneTest will fail out of config parsing we have more than one of the
 // specified testType provided. This is to account for scenarios where due to other
 // restrictions only one test type can be run for a given instance of vault-benchmark
@@ -133,3 +762,270 @@ func moreThanOneTest(tests []*benchmarktests.BenchmarkTarget, testType string) b
 	}
 	return false
 }
+
+// ParseLoadShare parses a share of the load of a distributed run, written
+// as part/parts with parts numbered from 1, such as 2/3 for the second of
+// three load generators
+func ParseLoadShare(share string) (part, parts int, err error) {
+	partStr, partsStr, ok := strings.Cut(share, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("expected part/parts, got %q", share)
+	}
+	part, err = strconv.Atoi(strings.TrimSpace(partStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid part %q: %v", partStr, err)
+	}
+	parts, err = strconv.Atoi(strings.TrimSpace(partsStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid parts %q: %v", partsStr, err)
+	}
+	if parts < 1 || part < 1 || part > parts {
+		return 0, 0, fmt.Errorf("part must be between 1 and %d, got %d", max(parts, 1), part)
+	}
+	return part, parts, nil
+}
+
+// ApplyLoadShare divides the load of the run between parts load
+// generators, keeping the share of the given part. Rates, request counts
+// and, in the closed attack mode, workers are split as evenly as possible,
+// with the remainder going to the first parts, so the shares of all parts
+// add up to the configured load. A zero rate or request count means no
+// limit, so values too small to give every part a share are rejected.
+func (c *VaultBenchmarkCoreConfig) ApplyLoadShare(part, parts int) error {
+	var err error
+	share := func(name string, v int) int {
+		if v == 0 {
+			return 0
+		}
+		if v < parts && err == nil {
+			err = fmt.Errorf("%v of %d cannot be shared between %d parts", name, v, parts)
+		}
+		s := v / parts
+		if part <= v%parts {
+			s++
+		}
+		return s
+	}
+
+	c.RPS = share("rps", c.RPS)
+	c.Requests = share("requests", c.Requests)
+	if c.AttackMode == "closed" {
+		c.Workers = share("workers", c.Workers)
+	}
+	for _, vbTest := range c.Tests {
+		if vbTest.RPS != nil {
+			rps := share("rps of test "+vbTest.Name, *vbTest.RPS)
+			vbTest.RPS = &rps
+		}
+		vbTest.Requests = share("requests of test "+vbTest.Name, vbTest.Requests)
+		if c.AttackMode == "closed" {
+			vbTest.Workers = share("workers of test "+vbTest.Name, vbTest.Workers)
+		}
+	}
+	for _, phase := range c.Phases {
+		if phase.RPS != nil {
+			rps := share("rps of phase "+phase.Name, *phase.RPS)
+			phase.RPS = &rps
+		}
+		if c.AttackMode == "closed" {
+			phase.Workers = share("workers of phase "+phase.Name, phase.Workers)
+		}
+	}
+	if c.Burst != nil {
+		c.Burst.RPS = share("burst rps", c.Burst.RPS)
+	}
+	// Chaos events, failovers, snapshots and hooks are only run by the
+	// first part, so each happens once, and only it notifies webhooks
+	if part != 1 {
+		c.Chaos = nil
+		c.Failover = nil
+		c.Snapshot = nil
+		c.Webhooks = nil
+		c.BeforeRun = nil
+		c.AfterRun = nil
+		for _, vbTest := range c.Tests {
+			vbTest.Before = nil
+			vbTest.After = nil
+		}
+	}
+	return err
+}
+
+// FilterTests keeps only the tests whose name or type matches one of the
+// include globs, or every test when there are none, and drops those whose
+// name or type matches one of the exclude globs. The weights of the tests
+// kept are scaled to add up to 100 again. Phases only enable the tests
+// they name which are kept. Filtering out every test of a phase is an
+// error, as is keeping a test without the test whose mount it shares.
+func (c *VaultBenchmarkCoreConfig) FilterTests(include, exclude []string) error {
+	kept := make(map[string]struct{}, len(c.Tests))
+	tests := make([]*benchmarktests.BenchmarkTarget, 0, len(c.Tests))
+	for _, vbTest := range c.Tests {
+		included, err := matchesTest(include, vbTest)
+		if err != nil {
+			return fmt.Errorf("invalid test filter %v", err)
+		}
+		excluded, err := matchesTest(exclude, vbTest)
+		if err != nil {
+			return fmt.Errorf("invalid test filter %v", err)
+		}
+		if (len(include) == 0 || included) && !excluded {
+			kept[vbTest.Name] = struct{}{}
+			tests = append(tests, vbTest)
+		}
+	}
+	if len(tests) == 0 {
+		return fmt.Errorf("no tests match the test filters")
+	}
+
+	for _, vbTest := range tests {
+		if _, ok := kept[vbTest.SharedMount]; vbTest.SharedMount != "" && !ok {
+			return fmt.Errorf("test %v shares the mount of test %v, which is filtered out", vbTest.Name, vbTest.SharedMount)
+		}
+	}
+	// Phases without tests enable every test, so phases whose tests are
+	// all filtered out cannot be kept as they are
+	for _, phase := range c.Phases {
+		if len(phase.Tests) == 0 {
+			continue
+		}
+		phaseTests := make([]string, 0, len(phase.Tests))
+		for _, name := range phase.Tests {
+			if _, ok := kept[name]; ok {
+				phaseTests = append(phaseTests, name)
+			}
+		}
+		if len(phaseTests) == 0 {
+			return fmt.Errorf("every test of phase %v is filtered out", phase.Name)
+		}
+		phase.Tests = phaseTests
+	}
+
+	if len(tests) < len(c.Tests) {
+		scaleWeights(tests)
+	}
+	c.Tests = tests
+	return nil
+}
+
+// ApplyTestOverrides sets options of tests from overrides written as
+// test.option=value, where test is a glob matching the names or types of
+// the tests to override, as given on the command line. The rps, duration
+// and workers of tests can be overridden, which makes them run as their
+// own attacks, so the weights of the tests left sharing the global rate
+// are scaled to add up to 100 again.
+func (c *VaultBenchmarkCoreConfig) ApplyTestOverrides(overrides []string) error {
+	shared := 0
+	for _, vbTest := range c.Tests {
+		if !vbTest.Independent() {
+			shared++
+		}
+	}
+
+	for _, override := range overrides {
+		key, value, ok := strings.Cut(override, "=")
+		dot := strings.LastIndex(key, ".")
+		if !ok || dot <= 0 {
+			return fmt.Errorf("invalid override %q: expected test.option=value", override)
+		}
+		pattern, option := strings.TrimSpace(key[:dot]), strings.TrimSpace(key[dot+1:])
+		value = strings.TrimSpace(value)
+
+		var set func(vbTest *benchmarktests.BenchmarkTarget)
+		switch option {
+		case "rps":
+			rps, err := strconv.Atoi(value)
+			if err != nil || rps < 0 {
+				return fmt.Errorf("invalid override %q: rps must be a non-negative integer", override)
+			}
+			set = func(vbTest *benchmarktests.BenchmarkTarget) { vbTest.RPS = &rps }
+		case "duration":
+			if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+				return fmt.Errorf("invalid override %q: duration must be a positive duration", override)
+			}
+			set = func(vbTest *benchmarktests.BenchmarkTarget) { vbTest.Duration = value }
+		case "workers":
+			workers, err := strconv.Atoi(value)
+			if err != nil || workers <= 0 {
+				return fmt.Errorf("invalid override %q: workers must be a positive integer", override)
+			}
+			set = func(vbTest *benchmarktests.BenchmarkTarget) { vbTest.Workers = workers }
+		default:
+			return fmt.Errorf("invalid override %q: option must be one of rps, duration or workers", override)
+		}
+
+		matched := false
+		for _, vbTest := range c.Tests {
+			ok, err := matchesTest([]string{pattern}, vbTest)
+			if err != nil {
+				return fmt.Errorf("invalid override %q: %v", override, err)
+			}
+			if ok {
+				set(vbTest)
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("invalid override %q: no tests match %v", override, pattern)
+		}
+	}
+
+	weighted := 0
+	for _, vbTest := range c.Tests {
+		if !vbTest.Independent() {
+			weighted++
+		}
+	}
+	if weighted < shared {
+		scaleWeights(c.Tests)
+	}
+	return nil
+}
+
+// matchesTest reports whether any of the globs matches the name or type of
+// a test
+func matchesTest(patterns []string, vbTest *benchmarktests.BenchmarkTarget) (bool, error) {
+	for _, pattern := range patterns {
+		for _, s := range []string{vbTest.Name, vbTest.Type} {
+			ok, err := path.Match(pattern, s)
+			if err != nil {
+				return false, fmt.Errorf("%q: %v", pattern, err)
+			}
+			if ok {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// scaleWeights scales the weights of the tests sharing the global rate to
+// add up to 100, after some of them were dropped or made to run as their
+// own attacks. The remainder goes to the first tests with a weight.
+func scaleWeights(tests []*benchmarktests.BenchmarkTarget) {
+	var weighted []*benchmarktests.BenchmarkTarget
+	total := 0
+	for _, vbTest := range tests {
+		if !vbTest.Independent() {
+			weighted = append(weighted, vbTest)
+			total += vbTest.Weight
+		}
+	}
+	if total == 0 {
+		return
+	}
+
+	remainder := 100
+	var shares []*benchmarktests.BenchmarkTarget
+	for _, vbTest := range weighted {
+		if vbTest.Weight > 0 {
+			shares = append(shares, vbTest)
+		}
+		vbTest.Weight = vbTest.Weight * 100 / total
+		remainder -= vbTest.Weight
+	}
+	for i := 0; remainder > 0; i = (i + 1) % len(shares) {
+		shares[i].Weight++
+		remainder--
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/openbao/benchmark-openbao/benchmarktests"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// credentialHelperTimeout is how long the credential helper of a test may
+// take to print its credentials
+const credentialHelperTimeout = time.Minute
+
+// ResolveCredentials runs the credential helpers of the tests and parses
+// their configs with the credentials printed. Runs call it just before
+// setting the tests up, so loading a config only to check it, such as with
+// the validate command, doesn't run the helpers.
+func (c *VaultBenchmarkCoreConfig) ResolveCredentials() error {
+	for _, vbTest := range c.Tests {
+		if vbTest.CredentialHelper == "" || vbTest.Builder != nil {
+			continue
+		}
+		body, err := credentialBody(vbTest)
+		if err != nil {
+			return fmt.Errorf("invalid credential_helper for test %v: %v", vbTest.Name, err)
+		}
+		currTest, ok := benchmarktests.TestList[vbTest.Type]
+		if !ok {
+			return fmt.Errorf("invalid test type found: %v", vbTest.Type)
+		}
+		builder := currTest()
+		if err := builder.ParseConfig(body); err != nil {
+			return fmt.Errorf("invalid config for test %v: %w", vbTest.Name, err)
+		}
+		vbTest.Remain = body
+		vbTest.Builder = builder
+	}
+	return nil
+}
+
+// credentialBody runs the credential helper of the test and returns the
+// config of the test evaluated with the JSON object it printed as the
+// credential variable, such as credential.password
+func credentialBody(vbTest *benchmarktests.BenchmarkTarget) (hcl.Body, error) {
+	credential, err := runCredentialHelper(vbTest)
+	if err != nil {
+		return nil, err
+	}
+
+	// Expressions of the config are evaluated in the context of their file,
+	// which the credential is added to for this test only
+	var ctx *hcl.EvalContext
+	if body, ok := vbTest.Remain.(evalBody); ok {
+		ctx = body.ctx.NewChild()
+	} else {
+		ctx = &hcl.EvalContext{}
+	}
+	ctx.Variables = map[string]cty.Value{"credential": credential}
+	return evalBody{Body: vbTest.Remain, ctx: ctx}, nil
+}
+
+// runCredentialHelper runs the credential helper of the test with the shell,
+// telling it the name and type of the test, and decodes the JSON object it
+// printed
+func runCredentialHelper(vbTest *benchmarktests.BenchmarkTarget) (cty.Value, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), credentialHelperTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", vbTest.CredentialHelper)
+	cmd.Env = append(os.Environ(),
+		"VAULT_BENCHMARK_TEST_NAME="+vbTest.Name,
+		"VAULT_BENCHMARK_TEST_TYPE="+vbTest.Type,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return cty.NilVal, fmt.Errorf("error running command: %v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return cty.NilVal, fmt.Errorf("error running command: %v", err)
+	}
+
+	ty, err := ctyjson.ImpliedType(out)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("error decoding output: %v", err)
+	}
+	if !ty.IsObjectType() {
+		return cty.NilVal, fmt.Errorf("output must be a JSON object, got %v", ty.FriendlyName())
+	}
+	credential, err := ctyjson.Unmarshal(out, ty)
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("error decoding output: %v", err)
+	}
+	return credential, nil
+}
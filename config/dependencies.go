@@ -0,0 +1,208 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	PostgresDependency = "postgres"
+	MSSQLDependency    = "mssql"
+	OpenLDAPDependency = "openldap"
+	RabbitMQDependency = "rabbitmq"
+
+	// DefaultDependencyHost is the host OpenBao reaches the ports of
+	// dependency containers on when it runs on the same machine
+	DefaultDependencyHost = "127.0.0.1"
+)
+
+// DependencyConfig declares a backing service, such as a database, which
+// is started in a container before the tests are set up and removed when
+// the run ends. Tests refer to its connection details, such as
+// dependency.postgres.connection_url, in their config.
+type DependencyConfig struct {
+	Kind  string `hcl:"kind,label"`
+	Image string `hcl:"image,optional"`
+	Host  string `hcl:"host,optional"`
+
+	// Port is the free local port the service is published on
+	Port int
+	// ContainerPort is the port the service listens on in the container
+	ContainerPort int
+	// Env is the environment the container is started with
+	Env map[string]string
+	// Ready is the command run in the container to check the service is
+	// ready
+	Ready []string
+	// Attributes are the connection details tests may refer to
+	Attributes map[string]string
+}
+
+// planDependency fills in the image, credentials and local port a
+// dependency is started with, so its connection details are known before
+// the container is
+func planDependency(d *DependencyConfig) error {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("error finding a free port: %v", err)
+	}
+	d.Port = l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	if d.Host == "" {
+		d.Host = DefaultDependencyHost
+	}
+	password, err := uuid.GenerateUUID()
+	if err != nil {
+		return fmt.Errorf("error generating password: %v", err)
+	}
+	addr := net.JoinHostPort(d.Host, strconv.Itoa(d.Port))
+	d.Attributes = map[string]string{
+		"host": d.Host,
+		"port": strconv.Itoa(d.Port),
+	}
+
+	image := ""
+	switch d.Kind {
+	case PostgresDependency:
+		image = "postgres:16"
+		d.ContainerPort = 5432
+		d.Env = map[string]string{"POSTGRES_USER": "benchmark", "POSTGRES_PASSWORD": password}
+		d.Ready = []string{"pg_isready", "--host=127.0.0.1", "--username=benchmark"}
+		d.Attributes["username"] = "benchmark"
+		d.Attributes["connection_url"] = "postgresql://{{username}}:{{password}}@" + addr + "/postgres?sslmode=disable"
+	case MSSQLDependency:
+		// SQL Server requires passwords with upper and lower case letters,
+		// digits and symbols
+		password = "Bench-" + password
+		image = "mcr.microsoft.com/mssql/server:2022-latest"
+		d.ContainerPort = 1433
+		d.Env = map[string]string{"ACCEPT_EULA": "Y", "MSSQL_SA_PASSWORD": password}
+		d.Ready = []string{"/opt/mssql-tools18/bin/sqlcmd", "-C", "-S", "localhost", "-U", "sa", "-P", password, "-Q", "SELECT 1"}
+		d.Attributes["username"] = "sa"
+		d.Attributes["connection_url"] = "sqlserver://{{username}}:{{password}}@" + addr
+	case OpenLDAPDependency:
+		image = "bitnami/openldap:2.6"
+		d.ContainerPort = 1389
+		d.Env = map[string]string{"LDAP_ROOT": "dc=example,dc=org", "LDAP_ADMIN_USERNAME": "admin", "LDAP_ADMIN_PASSWORD": password}
+		d.Ready = []string{"ldapsearch", "-x", "-H", "ldap://localhost:1389", "-D", "cn=admin,dc=example,dc=org", "-w", password, "-b", "dc=example,dc=org", "-s", "base"}
+		d.Attributes["username"] = "cn=admin,dc=example,dc=org"
+		d.Attributes["base_dn"] = "dc=example,dc=org"
+		d.Attributes["connection_url"] = "ldap://" + addr
+	case RabbitMQDependency:
+		image = "rabbitmq:3-management"
+		d.ContainerPort = 15672
+		d.Env = map[string]string{"RABBITMQ_DEFAULT_USER": "benchmark", "RABBITMQ_DEFAULT_PASS": password}
+		d.Ready = []string{"rabbitmq-diagnostics", "-q", "check_port_listener", "15672"}
+		d.Attributes["username"] = "benchmark"
+		d.Attributes["connection_url"] = "http://" + addr
+	default:
+		return fmt.Errorf("unknown dependency: %v", d.Kind)
+	}
+	if d.Image == "" {
+		d.Image = image
+	}
+	d.Attributes["password"] = password
+	return nil
+}
+
+// planDependencies plans every dependency of the config and adds their
+// connection details to the context test configs are evaluated in
+func planDependencies(deps []*DependencyConfig, ctx *hcl.EvalContext) error {
+	if len(deps) == 0 {
+		return nil
+	}
+	vars := make(map[string]cty.Value, len(deps))
+	for _, d := range deps {
+		if _, ok := vars[d.Kind]; ok {
+			return fmt.Errorf("dependency %v declared more than once", d.Kind)
+		}
+		if err := planDependency(d); err != nil {
+			return err
+		}
+		attrs := make(map[string]cty.Value, len(d.Attributes))
+		for name, value := range d.Attributes {
+			attrs[name] = cty.StringVal(value)
+		}
+		vars[d.Kind] = cty.ObjectVal(attrs)
+	}
+	ctx.Variables["dependency"] = cty.ObjectVal(vars)
+	return nil
+}
+
+// evalBody evaluates the expressions of a body in a context, so test
+// configs can refer to variables although builders decode them without one
+type evalBody struct {
+	hcl.Body
+	ctx *hcl.EvalContext
+}
+
+func (b evalBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	content, diags := b.Body.Content(schema)
+	return b.content(content), diags
+}
+
+func (b evalBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	content, remain, diags := b.Body.PartialContent(schema)
+	if remain != nil {
+		remain = evalBody{Body: remain, ctx: b.ctx}
+	}
+	return b.content(content), remain, diags
+}
+
+func (b evalBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	attrs, diags := b.Body.JustAttributes()
+	return b.attributes(attrs), diags
+}
+
+func (b evalBody) content(content *hcl.BodyContent) *hcl.BodyContent {
+	if content == nil {
+		return nil
+	}
+	blocks := make(hcl.Blocks, 0, len(content.Blocks))
+	for _, block := range content.Blocks {
+		wrapped := *block
+		wrapped.Body = evalBody{Body: block.Body, ctx: b.ctx}
+		blocks = append(blocks, &wrapped)
+	}
+	return &hcl.BodyContent{
+		Attributes:       b.attributes(content.Attributes),
+		Blocks:           blocks,
+		MissingItemRange: content.MissingItemRange,
+	}
+}
+
+func (b evalBody) attributes(attrs hcl.Attributes) hcl.Attributes {
+	if attrs == nil {
+		return nil
+	}
+	wrapped := make(hcl.Attributes, len(attrs))
+	for name, attr := range attrs {
+		a := *attr
+		a.Expr = evalExpr{Expression: attr.Expr, ctx: b.ctx}
+		wrapped[name] = &a
+	}
+	return wrapped
+}
+
+// evalExpr evaluates an expression in its context when it is decoded
+// without one
+type evalExpr struct {
+	hcl.Expression
+	ctx *hcl.EvalContext
+}
+
+func (e evalExpr) Value(ctx *hcl.EvalContext) (cty.Value, hcl.Diagnostics) {
+	if ctx == nil {
+		ctx = e.ctx
+	}
+	return e.Expression.Value(ctx)
+}
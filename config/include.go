@@ -0,0 +1,93 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+)
+
+// includeSchema picks the include attribute out of a config file, which
+// lists the paths, or glob patterns, of the files merged into it
+var includeSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{{Name: "include"}},
+}
+
+// parseFile parses a config file, as JSON when its name has a .json
+// extension and as HCL otherwise
+func parseFile(parser *hclparse.Parser, buf []byte, path string) (*hcl.File, hcl.Diagnostics) {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return parser.ParseJSON(buf, path)
+	}
+	return parser.ParseHCL(buf, path)
+}
+
+// includeFiles returns the body of the config file merged with those of the
+// files it includes, and those they include in turn. Each body is evaluated
+// in a context of its own, so paths are relative to the file they are
+// written in. The paths of the included files are added to includes; a file
+// may only be included once, as its tests would otherwise be defined twice.
+// The paths of the files read with the file function are added to files.
+func includeFiles(parser *hclparse.Parser, file *hcl.File, path string, ctx *hcl.EvalContext, seen map[string]bool, includes, files *[]string) (hcl.Body, error) {
+	dir := filepath.Dir(path)
+	fileCtx := fileEvalContext(ctx, dir, files)
+	content, remain, diags := file.Body.PartialContent(includeSchema)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("error parsing hcl: %v", diags)
+	}
+	bodies := []hcl.Body{evalBody{Body: remain, ctx: fileCtx}}
+
+	attr, ok := content.Attributes["include"]
+	if !ok {
+		return bodies[0], nil
+	}
+	var patterns []string
+	if diags := gohcl.DecodeExpression(attr.Expr, fileCtx, &patterns); diags.HasErrors() {
+		return nil, fmt.Errorf("error decoding include: %v", diags)
+	}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include %v: %v", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("include %v matched no files", pattern)
+		}
+		for _, match := range matches {
+			abs, err := filepath.Abs(match)
+			if err != nil {
+				return nil, fmt.Errorf("error resolving include %v: %v", match, err)
+			}
+			if seen[abs] {
+				return nil, fmt.Errorf("%v is included more than once", match)
+			}
+			seen[abs] = true
+			*includes = append(*includes, match)
+
+			buf, err := os.ReadFile(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open included file: %v", err)
+			}
+			included, diags := parseFile(parser, buf, match)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("error parsing hcl: %v", diags)
+			}
+			body, err := includeFiles(parser, included, match, ctx, seen, includes, files)
+			if err != nil {
+				return nil, err
+			}
+			bodies = append(bodies, body)
+		}
+	}
+	return hcl.MergeBodies(bodies), nil
+}
@@ -0,0 +1,204 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/openbao/openbao/api/v2"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// newEvalContext returns the context configs are evaluated in. Every
+// environment variable can be referred to by name, such as
+// "${VAULT_ADDR}", and read with the env function, which fails when it is
+// unset unless given a default. The file function reads a file relative to
+// the directory of the config, adding its path to files, and trimspace removes the whitespace around
+// a string, such as the newline ending a file. Credentials can also be
+// asked for with the prompt function, which fails when prompt is nil, and
+// read from a KV secret of OpenBao with the kv function, unless the config
+// is only checked.
+func newEvalContext(dir string, prompt func(string) (string, error), checkOnly bool, files *[]string) *hcl.EvalContext {
+	vars := make(map[string]cty.Value)
+	for _, kv := range os.Environ() {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !hclsyntax.ValidIdentifier(name) {
+			continue
+		}
+		vars[name] = cty.StringVal(value)
+	}
+	return fileEvalContext(&hcl.EvalContext{
+		Variables: vars,
+		Functions: map[string]function.Function{
+			"env":       envFunc,
+			"trimspace": stdlib.TrimSpaceFunc,
+			"prompt":    promptFunc(prompt),
+			"kv":        kvFunc(checkOnly),
+		},
+	}, dir, files)
+}
+
+// fileEvalContext returns the context a config file in dir is evaluated in,
+// which shares the variables and functions of ctx, so files included by a
+// config read files relative to their own directory. The paths of the files
+// read are added to files.
+func fileEvalContext(ctx *hcl.EvalContext, dir string, files *[]string) *hcl.EvalContext {
+	funcs := make(map[string]function.Function, len(ctx.Functions)+1)
+	for name, fn := range ctx.Functions {
+		funcs[name] = fn
+	}
+	funcs["file"] = fileFunc(dir, files)
+	return &hcl.EvalContext{
+		Variables: ctx.Variables,
+		Functions: funcs,
+	}
+}
+
+// envFunc returns the value of an environment variable, or the default
+// given when it is unset
+var envFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{Name: "name", Type: cty.String},
+	},
+	VarParam: &function.Parameter{Name: "default", Type: cty.String},
+	Type:     function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		if len(args) > 2 {
+			return cty.NilVal, fmt.Errorf("env takes a name and at most one default")
+		}
+		name := args[0].AsString()
+		if value, ok := os.LookupEnv(name); ok {
+			return cty.StringVal(value), nil
+		}
+		if len(args) == 2 {
+			return args[1], nil
+		}
+		return cty.NilVal, fmt.Errorf("environment variable %v is not set", name)
+	},
+})
+
+// fileFunc returns a function reading the contents of a file, relative to
+// dir unless its path is absolute, adding its path to files
+func fileFunc(dir string, files *[]string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path := args[0].AsString()
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			contents, err := os.ReadFile(path)
+			if err != nil {
+				return cty.NilVal, fmt.Errorf("error reading file: %v", err)
+			}
+			*files = append(*files, path)
+			return cty.StringVal(string(contents)), nil
+		},
+	})
+}
+
+// promptFunc returns a function asking for a value with the query, without
+// echoing it, such as a password which shouldn't be written to the config
+// or the environment. Each query is only asked once, however often the
+// config refers to it.
+func promptFunc(prompt func(string) (string, error)) function.Function {
+	var lock sync.Mutex
+	answers := make(map[string]string)
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "query", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			query := args[0].AsString()
+			if prompt == nil {
+				return cty.NilVal, fmt.Errorf("cannot prompt for %q, as prompts aren't available here", query)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if answer, ok := answers[query]; ok {
+				return cty.StringVal(answer), nil
+			}
+			answer, err := prompt(strings.TrimSuffix(query, ":") + ":")
+			if err != nil {
+				return cty.NilVal, fmt.Errorf("error prompting for %q: %v", query, err)
+			}
+			answers[query] = answer
+			return cty.StringVal(answer), nil
+		},
+	})
+}
+
+// kvFunc returns a function reading a key of a KV secret from the OpenBao
+// server of the environment, given by VAULT_ADDR and VAULT_TOKEN, which
+// need not be the one benchmarked. Secrets of KV version 2 mounts are read
+// from their data path, such as secret/data/benchmark. Each secret is only
+// read once, however often the config refers to it. When the config is only
+// checked, the function returns a placeholder naming the key instead, so
+// checking a config doesn't contact OpenBao.
+func kvFunc(checkOnly bool) function.Function {
+	var lock sync.Mutex
+	var client *api.Client
+	secrets := make(map[string]map[string]interface{})
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{Name: "path", Type: cty.String},
+			{Name: "key", Type: cty.String},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path, key := strings.Trim(args[0].AsString(), "/"), args[1].AsString()
+			if checkOnly {
+				return cty.StringVal("kv:" + path + ":" + key), nil
+			}
+			lock.Lock()
+			defer lock.Unlock()
+
+			data, ok := secrets[path]
+			if !ok {
+				if client == nil {
+					c, err := api.NewClient(api.DefaultConfig())
+					if err != nil {
+						return cty.NilVal, fmt.Errorf("error creating client for kv: %v", err)
+					}
+					client = c
+				}
+				secret, err := client.Logical().Read(path)
+				if err != nil {
+					return cty.NilVal, fmt.Errorf("error reading secret %v: %v", path, err)
+				}
+				if secret == nil || secret.Data == nil {
+					return cty.NilVal, fmt.Errorf("no secret found at %v", path)
+				}
+				data = secret.Data
+				// KV version 2 nests the data of secrets alongside their
+				// metadata
+				if nested, ok := data["data"].(map[string]interface{}); ok && data["metadata"] != nil {
+					data = nested
+				}
+				secrets[path] = data
+			}
+
+			value, ok := data[key]
+			if !ok || value == nil {
+				return cty.NilVal, fmt.Errorf("secret %v has no key %v", path, key)
+			}
+			if s, ok := value.(string); ok {
+				return cty.StringVal(s), nil
+			}
+			return cty.StringVal(fmt.Sprint(value)), nil
+		},
+	})
+}
@@ -0,0 +1,117 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// VariableConfig declares a variable of the config, which its expressions
+// refer to as var.<name>, so one config can be reused across environments.
+// Its value is given with -var or -var-file, or is its default.
+type VariableConfig struct {
+	Name        string         `hcl:"name,label"`
+	Default     hcl.Expression `hcl:"default,optional"`
+	Description string         `hcl:"description,optional"`
+}
+
+// variableSchema picks the variable blocks out of a config, which are
+// evaluated before the rest of it
+var variableSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{{Type: "variable", LabelNames: []string{"name"}}},
+}
+
+// declareVariables evaluates the variables declared by the config and adds
+// them to the context it is evaluated in, returning the rest of the config.
+// Values of var files override defaults, and values given on the command
+// line override both. Every variable must have a value, and every value
+// given must be of a declared variable. The paths of the files read with the
+// file function by var files are added to files.
+func declareVariables(body hcl.Body, ctx *hcl.EvalContext, vars map[string]string, varFiles []string, files *[]string) (hcl.Body, error) {
+	content, remain, diags := body.PartialContent(variableSchema)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("error decoding hcl: %v", diags)
+	}
+
+	var declared []string
+	values := make(map[string]cty.Value)
+	isDeclared := make(map[string]bool)
+	for _, block := range content.Blocks {
+		v := &VariableConfig{Name: block.Labels[0]}
+		if !hclsyntax.ValidIdentifier(v.Name) {
+			return nil, fmt.Errorf("invalid variable name %q", v.Name)
+		}
+		if isDeclared[v.Name] {
+			return nil, fmt.Errorf("variable %v declared more than once", v.Name)
+		}
+		if diags := gohcl.DecodeBody(block.Body, nil, v); diags.HasErrors() {
+			return nil, fmt.Errorf("error decoding variable %v: %v", v.Name, diags)
+		}
+		value, diags := v.Default.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("invalid default of variable %v: %v", v.Name, diags)
+		}
+		if !value.IsNull() {
+			values[v.Name] = value
+		}
+		isDeclared[v.Name] = true
+		declared = append(declared, v.Name)
+	}
+
+	parser := hclparse.NewParser()
+	for _, path := range varFiles {
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open var file: %v", err)
+		}
+		file, diags := parseFile(parser, buf, path)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("error parsing var file: %v", diags)
+		}
+		attrs, diags := file.Body.JustAttributes()
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("error parsing var file: %v", diags)
+		}
+		fileCtx := fileEvalContext(ctx, filepath.Dir(path), files)
+		for name, attr := range attrs {
+			if !isDeclared[name] {
+				return nil, fmt.Errorf("var file %v sets undeclared variable %v", path, name)
+			}
+			value, diags := attr.Expr.Value(fileCtx)
+			if diags.HasErrors() {
+				return nil, fmt.Errorf("invalid value of variable %v: %v", name, diags)
+			}
+			values[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !isDeclared[name] {
+			return nil, fmt.Errorf("-var sets undeclared variable %v", name)
+		}
+		values[name] = cty.StringVal(vars[name])
+	}
+
+	for _, name := range declared {
+		if _, ok := values[name]; !ok {
+			return nil, fmt.Errorf("variable %v has no value; set it with -var or -var-file", name)
+		}
+	}
+	ctx.Variables["var"] = cty.ObjectVal(values)
+	return remain, nil
+}
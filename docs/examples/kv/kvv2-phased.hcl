@@ -0,0 +1,31 @@
+vault_addr = "http://127.0.0.1:8200"
+vault_token = "root"
+cleanup = true
+
+test "kvv2_read" "kvv2_read_test" {
+    weight = 50
+    config {
+        numkvs = 100
+        kvsize = 1000
+    }
+}
+
+test "kvv2_write" "kvv2_write_test" {
+    weight = 50
+    config {
+        numkvs = 100
+        kvsize = 1000
+    }
+}
+
+# Warm the cache with reads only before switching to a mixed workload
+phase "warm" {
+    duration = "30s"
+    rps = 100
+    tests = ["kvv2_read_test"]
+}
+
+phase "mixed" {
+    duration = "2m"
+    rps = 500
+}
@@ -0,0 +1,29 @@
+vault_addr = "http://127.0.0.1:8200"
+vault_token = "root"
+cleanup = true
+
+test "kvv2_read" "kvv2_read_test" {
+    weight = 60
+    config {
+        numkvs = 100
+        kvsize = 1000
+    }
+}
+
+# Write to and list the secrets of the read test's mount
+test "kvv2_write" "kvv2_write_test" {
+    weight = 30
+    shared_mount = "kvv2_read_test"
+    config {
+        numkvs = 100
+        kvsize = 1000
+    }
+}
+
+test "kvv2_list" "kvv2_list_test" {
+    weight = 10
+    shared_mount = "kvv2_read_test"
+    config {
+        numkvs = 100
+    }
+}
@@ -0,0 +1,22 @@
+vault_addr = "http://127.0.0.1:8200"
+vault_token = "root"
+duration = "5m"
+cleanup = true
+
+test "kvv2_read" "kvv2_read_test" {
+    rps = 200
+    config {
+        numkvs = 100
+        kvsize = 1000
+    }
+}
+
+# Start writing two minutes in to see how writes affect read latency
+test "kvv2_write" "kvv2_write_test" {
+    rps = 100
+    start_offset = "2m"
+    config {
+        numkvs = 100
+        kvsize = 1000
+    }
+}
@@ -1,7 +1,7 @@
 # Copyright (c) HashiCorp, Inc.
 # SPDX-License-Identifier: MPL-2.0
 
-vault_addrs = "https://127.0.0.1:8200"
+vault_addrs = ["https://127.0.0.1:8200"]
 vault_token = "sometoken"
 duration = "30s"
 report_mode = "terse"